@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"emaild/backend/database"
 	"emaild/backend/models"
 	"emaild/backend/services"
+	"emaild/backend/utils"
 
 	"github.com/sirupsen/logrus"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
@@ -22,13 +24,14 @@ import (
 
 // App 主应用结构体
 type App struct {
-	ctx             context.Context
-	cancel          context.CancelFunc
-	db              *database.Database
-	downloadService *services.DownloadService
-	emailService    *services.EmailService
-	trayService     *services.TrayService
-	logger          *logrus.Logger
+	ctx              context.Context
+	cancel           context.CancelFunc
+	db               *database.Database
+	downloadService  *services.DownloadService
+	emailService     *services.EmailService
+	trayService      *services.TrayService
+	telemetryService *services.TelemetryService
+	logger           *logrus.Logger
 	
 	// 服务状态
 	isInitialized   bool
@@ -37,7 +40,14 @@ type App struct {
 	// 优雅关闭相关
 	shutdownOnce    sync.Once
 	isShuttingDown  bool
+	quitRequested   bool
 	shutdownMutex   sync.RWMutex
+	
+	// 托盘不可用的提示只发送一次
+	trayNoticeOnce  sync.Once
+	
+	// 后台检查失败的通知按账户和错误类别去重，避免持续失败的账户反复通知
+	errorNotifier   *services.ErrorNotifier
 }
 
 // NewApp 创建应用实例
@@ -51,6 +61,10 @@ func NewApp() *App {
 		FullTimestamp: true,
 		ForceColors:   true,
 	})
+	// 同时写入日志文件，供应用内诊断面板查看
+	if err := utils.SetupLogger(logger); err != nil {
+		logger.Warnf("启用文件日志失败: %v", err)
+	}
 
 	return &App{
 		ctx:            ctx,
@@ -88,6 +102,10 @@ func (a *App) OnShutdown(ctx context.Context) {
 		a.downloadService.Stop()
 	}
 
+	if a.telemetryService != nil {
+		a.telemetryService.Stop()
+	}
+
 	if a.trayService != nil {
 		a.trayService.Stop()
 	}
@@ -102,6 +120,20 @@ func (a *App) OnShutdown(ctx context.Context) {
 	a.logger.Info("应用已关闭")
 }
 
+// OnBeforeClose 关闭窗口前的回调，托盘可用时隐藏到托盘，否则正常退出
+// 托盘不可用时隐藏窗口会让应用看起来"消失"，因此不再拦截关闭；托盘仍在启动时等待启动结果
+func (a *App) OnBeforeClose(ctx context.Context) bool {
+	a.shutdownMutex.RLock()
+	quitting := a.isShuttingDown || a.quitRequested
+	a.shutdownMutex.RUnlock()
+	if quitting || a.trayService == nil || !a.trayService.WaitAvailable() {
+		return false
+	}
+	
+	runtime.WindowHide(ctx)
+	return true
+}
+
 // OnDomReady 前端DOM准备完成时的回调
 func (a *App) OnDomReady(ctx context.Context) {
 	// 检查是否需要在启动时最小化
@@ -119,17 +151,58 @@ func (a *App) getOrCreateDefaultConfig() (*models.AppConfig, error) {
 		homeDir, _ := os.UserHomeDir()
 		now := time.Now()
 		defaultConfig := models.AppConfig{
-			DownloadPath:       filepath.Join(homeDir, "Downloads", "EmailPDFs"),
-			MaxConcurrent:      3,
-			CheckInterval:      300, // 5分钟
-			AutoCheck:          false,
-			MinimizeToTray:     true,
-			StartMinimized:     false,
-			EnableNotification: true,
-			Theme:              "auto",
-			Language:           "zh-CN",
-			CreatedAt:          models.TimeToString(now),
-			UpdatedAt:          models.TimeToString(now),
+			DownloadPath:               filepath.Join(homeDir, "Downloads", "EmailPDFs"),
+			MaxConcurrent:              3,
+			CheckInterval:              300, // 5分钟
+			AutoCheck:                  false,
+			MinimizeToTray:             true,
+			StartMinimized:             false,
+			EnableNotification:         true,
+			Theme:                      "auto",
+			Language:                   "zh-CN",
+			MaxTasksPerCheck:           100,
+			ChecksumAlgorithm:          "sha256",
+			DownloadDeadlineMinutes:    5,
+			CheckScope:                 models.HistoryScopeSinceAccountAdded,
+			CheckScopeDays:             7,
+			DuplicateSourcePreference:  models.PreferAttachmentSource,
+			AutoOpenFile:               false,
+			AutoOpenFolder:             false,
+			QuarantineInvalidFiles:     false,
+			FetchBatchSize:             50,
+			CertPinningMode:            models.CertPinningOff,
+			RelativePaths:              false,
+			MaxRedirects:               5,
+			QuickRetryAttempts:         2,
+			MaxTaskRetries:             3,
+			TaskRetryBackoffSeconds:    30,
+			AccountCheckTimeoutMinutes: 5,
+			ExtractZipAttachments:      false,
+			StatisticsDateSource:       models.StatisticsDateCompleted,
+			CompletionSound:            false,
+			OrganizeBy:                 models.OrganizeByNone,
+			RecoveryDelaySeconds:       2,
+			RecoveryTasksPerMinute:     30,
+			RecoveryMaxAgeHours:        24,
+			ScanWindowDays:             7,
+			InitialScanDays:            0,
+			ProxyURL:                   "",
+			StrictPDFValidation:        false,
+			PendingExpiryHours:         24,
+			DeduplicateDownloads:       false,
+			WebhookURL:                 "",
+			WebhookSecret:              "",
+			MaxFileSizeMB:              0,
+			SenderAllowlist:            "",
+			SenderBlocklist:            "",
+			IndexPDFMetadata:           false,
+			PDFThumbnails:              false,
+			MergePDFPattern:            "",
+			MarkAsRead:                 false,
+			MaxIMAPConnections:         0,
+			MinFreeMemoryMB:            0,
+			CreatedAt:                  models.TimeToString(now),
+			UpdatedAt:                  models.TimeToString(now),
 		}
 		
 		if err := a.CreateConfig(defaultConfig); err != nil {
@@ -153,21 +226,46 @@ func (a *App) GetEmailAccounts() ([]models.EmailAccount, error) {
 	return a.db.GetEmailAccounts()
 }
 
+// AccountCreateResult 创建邮箱账户的结果
+type AccountCreateResult struct {
+	Account models.EmailAccount `json:"account"`
+	Warning string              `json:"warning"` // 不影响创建的提示，如与已有账户读取同一个邮箱
+}
+
 // CreateEmailAccount 创建邮箱账户
-func (a *App) CreateEmailAccount(account models.EmailAccount) error {
+func (a *App) CreateEmailAccount(account models.EmailAccount) (AccountCreateResult, error) {
 	// 验证邮箱格式
-	if account.Email == "" || account.Password == "" || account.IMAPServer == "" {
-		return fmt.Errorf("邮箱地址、密码和IMAP服务器不能为空")
+	if err := validateAccountCredentials(&account); err != nil {
+		return AccountCreateResult{}, err
+	}
+	if _, err := services.ParseSearchCriteria(account.SearchCriteria); err != nil {
+		return AccountCreateResult{}, fmt.Errorf("自定义搜索条件无效: %v", err)
+	}
+	account.Folder = services.NormalizeAccountFolder(account.Folder)
+	account.ScanFolders = services.NormalizeScanFolders(account.ScanFolders, account.Folder)
+	downloadPath, err := services.NormalizeAccountDownloadPath(account.DownloadPath)
+	if err != nil {
+		return AccountCreateResult{}, err
+	}
+	account.DownloadPath = downloadPath
+	if account.AllowedExtensions, err = services.NormalizeAccountExtensions(account.AllowedExtensions); err != nil {
+		return AccountCreateResult{}, err
+	}
+	if err := services.NormalizeSSHTunnel(&account); err != nil {
+		return AccountCreateResult{}, err
 	}
 
-	// 测试连接
+	// 测试连接（通过SSH隧道时只接受用户已确认的跳板机主机密钥）
 	if err := a.emailService.TestConnection(&account); err != nil {
-		return fmt.Errorf("邮箱连接测试失败: %v", err)
+		return AccountCreateResult{}, fmt.Errorf("邮箱连接测试失败: %v", err)
 	}
 
+	// 与已有账户读取同一个邮箱时只提示，仍然创建账户
+	warning := a.emailService.FindDuplicateMailbox(&account)
+
 	// 保存邮箱账户
 	if err := a.db.CreateEmailAccount(&account); err != nil {
-		return err
+		return AccountCreateResult{}, err
 	}
 
 	// 如果账户是激活状态，立即触发一次邮件检查
@@ -180,14 +278,131 @@ func (a *App) CreateEmailAccount(account models.EmailAccount) error {
 		}()
 	}
 
+	return AccountCreateResult{Account: account, Warning: warning}, nil
+}
+
+// AccountValidatedEvent 异步验证账户完成时发送给前端的事件
+const AccountValidatedEvent = "account:validated"
+
+// AccountCheckedEvent 后台检查中每个账户检查结束（完成、失败或超时）时发送给前端的事件
+const AccountCheckedEvent = "account:checked"
+
+// TrayUnavailableEvent 检测到系统托盘不可用时发送给前端的事件，携带不可用的原因
+const TrayUnavailableEvent = "tray:unavailable"
+
+// DownloadProgressEvent 下载任务的每条进度更新发送给前端的事件，携带 services.ProgressUpdate
+const DownloadProgressEvent = "download:progress"
+
+// DownloadBatchCompletedEvent 开启完成提示音时，一批下载全部完成后发送给前端的事件，携带本批完成的下载数
+const DownloadBatchCompletedEvent = "download:batch-completed"
+
+// validateAccountCredentials 检查账户的必填项：密码账户需要密码，OAuth2 账户需要客户端ID和刷新令牌
+func validateAccountCredentials(account *models.EmailAccount) error {
+	if account.Email == "" || account.IMAPServer == "" {
+		return fmt.Errorf("邮箱地址和IMAP服务器不能为空")
+	}
+	if err := services.NormalizeOAuthSettings(account); err != nil {
+		return err
+	}
+	if !account.UsesOAuth2() && account.Password == "" {
+		return fmt.Errorf("邮箱地址、密码和IMAP服务器不能为空")
+	}
 	return nil
 }
 
+// AccountValidationResult 异步账户验证结果
+type AccountValidationResult struct {
+	AccountID uint   `json:"account_id"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	Warning   string `json:"warning"` // 验证成功但需要提醒用户的情况，如与已有账户读取同一个邮箱
+}
+
+// CreateEmailAccountAsync 立即保存邮箱账户并在后台验证连接，避免慢速服务器阻塞界面
+// 验证完成前账户处于停用状态；验证成功后按请求的状态启用，失败时记录错误并保持停用
+// 验证结果通过 account:validated 事件通知前端
+func (a *App) CreateEmailAccountAsync(account models.EmailAccount) (models.EmailAccount, error) {
+	if err := validateAccountCredentials(&account); err != nil {
+		return account, err
+	}
+	if _, err := services.ParseSearchCriteria(account.SearchCriteria); err != nil {
+		return account, fmt.Errorf("自定义搜索条件无效: %v", err)
+	}
+	account.Folder = services.NormalizeAccountFolder(account.Folder)
+	account.ScanFolders = services.NormalizeScanFolders(account.ScanFolders, account.Folder)
+	downloadPath, err := services.NormalizeAccountDownloadPath(account.DownloadPath)
+	if err != nil {
+		return account, err
+	}
+	account.DownloadPath = downloadPath
+	if account.AllowedExtensions, err = services.NormalizeAccountExtensions(account.AllowedExtensions); err != nil {
+		return account, err
+	}
+	if err := services.NormalizeSSHTunnel(&account); err != nil {
+		return account, err
+	}
+
+	requestedActive := account.IsActive
+	account.IsActive = false
+	if err := a.db.CreateEmailAccount(&account); err != nil {
+		return account, err
+	}
+
+	go a.validateAccountAsync(account, requestedActive)
+
+	return account, nil
+}
+
+// validateAccountAsync 后台验证账户连接并保存结果
+func (a *App) validateAccountAsync(account models.EmailAccount, requestedActive bool) {
+	result := AccountValidationResult{AccountID: account.ID, Success: true}
+
+	if err := a.emailService.TestConnection(&account); err != nil {
+		a.logger.Warnf("账户%d异步验证失败: %v", account.ID, err)
+		result.Success = false
+		result.Error = err.Error()
+	} else {
+		result.Warning = a.emailService.FindDuplicateMailbox(&account)
+	}
+
+	isActive := requestedActive && result.Success
+	if err := a.db.SetAccountValidationResult(account.ID, isActive, result.Error); err != nil {
+		// 账户可能已在验证期间被删除
+		a.logger.Warnf("保存账户%d验证结果失败: %v", account.ID, err)
+		return
+	}
+
+	if a.ctx.Err() == nil {
+		runtime.EventsEmit(a.ctx, AccountValidatedEvent, result)
+	}
+
+	if isActive {
+		account.IsActive = true
+		a.emailService.CheckAccountWithResult(&account)
+	}
+}
+
 // UpdateEmailAccount 更新邮箱账户
 func (a *App) UpdateEmailAccount(account models.EmailAccount) error {
 	// 验证数据
-	if account.Email == "" || account.Password == "" || account.IMAPServer == "" {
-		return fmt.Errorf("邮箱地址、密码和IMAP服务器不能为空")
+	if err := validateAccountCredentials(&account); err != nil {
+		return err
+	}
+	if _, err := services.ParseSearchCriteria(account.SearchCriteria); err != nil {
+		return fmt.Errorf("自定义搜索条件无效: %v", err)
+	}
+	account.Folder = services.NormalizeAccountFolder(account.Folder)
+	account.ScanFolders = services.NormalizeScanFolders(account.ScanFolders, account.Folder)
+	downloadPath, err := services.NormalizeAccountDownloadPath(account.DownloadPath)
+	if err != nil {
+		return err
+	}
+	account.DownloadPath = downloadPath
+	if account.AllowedExtensions, err = services.NormalizeAccountExtensions(account.AllowedExtensions); err != nil {
+		return err
+	}
+	if err := services.NormalizeSSHTunnel(&account); err != nil {
+		return err
 	}
 
 	// 测试连接（如果邮箱设置有变化）
@@ -196,14 +411,118 @@ func (a *App) UpdateEmailAccount(account models.EmailAccount) error {
 		return fmt.Errorf("获取原账户信息失败: %v", err)
 	}
 
-	if oldAccount.Email != account.Email || oldAccount.Password != account.Password || 
-	   oldAccount.IMAPServer != account.IMAPServer || oldAccount.IMAPPort != account.IMAPPort {
+	// 证书指纹只由连接过程维护，不使用前端传入的值；服务器地址变更后按新服务器重新记录
+	endpointChanged := oldAccount.IMAPServer != account.IMAPServer || oldAccount.IMAPPort != account.IMAPPort
+	account.CertFingerprint, account.PendingCertFingerprint = oldAccount.CertFingerprint, oldAccount.PendingCertFingerprint
+	if endpointChanged {
+		account.CertFingerprint, account.PendingCertFingerprint = "", ""
+	}
+
+	// SSH主机密钥由用户核对后确认，变更后同样重新测试连接
+	tunnelChanged := oldAccount.SSHHost != account.SSHHost || oldAccount.SSHPort != account.SSHPort ||
+		oldAccount.SSHUser != account.SSHUser || oldAccount.SSHKeyPath != account.SSHKeyPath ||
+		oldAccount.SSHHostKey != account.SSHHostKey
+
+	authChanged := oldAccount.AuthType != account.AuthType || oldAccount.Password != account.Password ||
+		oldAccount.OAuthClientID != account.OAuthClientID || oldAccount.OAuthClientSecret != account.OAuthClientSecret ||
+		oldAccount.OAuthRefreshToken != account.OAuthRefreshToken || oldAccount.OAuthTokenURL != account.OAuthTokenURL
+
+	serverChanged := oldAccount.Email != account.Email || oldAccount.IMAPServer != account.IMAPServer
+	folderChanged := oldAccount.MainFolder() != account.MainFolder()
+	if serverChanged || folderChanged || tunnelChanged || authChanged || oldAccount.IMAPPort != account.IMAPPort {
 		if err := a.emailService.TestConnection(&account); err != nil {
 			return fmt.Errorf("邮箱连接测试失败: %v", err)
 		}
 	}
 
-	return a.db.UpdateEmailAccount(&account)
+	if err := a.db.UpdateEmailAccount(&account); err != nil {
+		return err
+	}
+
+	// 服务器或邮箱变更后，原有的文件夹缓存不再可信
+	if serverChanged {
+		if err := a.db.UpdateAccountFolderCache(account.ID, "", nil); err != nil {
+			a.logger.Warnf("清除账户%d文件夹缓存失败: %v", account.ID, err)
+		}
+	}
+
+	// 隧道或认证设置变更后，已有连接仍使用原来的设置，需要重新连接
+	if tunnelChanged || authChanged {
+		a.emailService.CloseConnection(account.ID)
+	}
+
+	// 主文件夹变更后重新连接；推迟处理的邮件UID属于原文件夹，不再有效
+	if folderChanged {
+		if err := a.db.SetDeferredMessageUIDs(account.ID, nil); err != nil {
+			a.logger.Warnf("清除账户%d推迟邮件列表失败: %v", account.ID, err)
+		}
+		a.emailService.CloseConnection(account.ID)
+	}
+
+	// 连接测试时已记录新服务器的证书（开启证书固定时），否则清除旧服务器的指纹
+	if endpointChanged {
+		if err := a.db.SetCertFingerprint(account.ID, account.CertFingerprint); err != nil {
+			a.logger.Warnf("更新账户%d证书指纹失败: %v", account.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetCertificateFingerprint 获取账户已记录的服务器证书指纹，尚未记录时返回空字符串
+func (a *App) GetCertificateFingerprint(accountID uint) (string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return "", err
+	}
+
+	account, err := a.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return "", fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	return account.CertFingerprint, nil
+}
+
+// GetSSHHostKeyFingerprint 读取账户SSH跳板机的主机密钥指纹，用户核对后填入账户的 ssh_host_key 保存
+func (a *App) GetSSHHostKeyFingerprint(account models.EmailAccount) (string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return "", err
+	}
+
+	return a.emailService.SSHHostKeyFingerprint(&account)
+}
+
+// AcceptNewCertificate 确认服务器证书变更，信任账户最近一次连接时看到的新证书
+func (a *App) AcceptNewCertificate(accountID uint) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+
+	return a.emailService.AcceptNewCertificate(accountID)
+}
+
+// ResetAccountSyncState 清除账户的增量扫描状态（各文件夹已处理到的UID和历史导入标记），
+// 下次检查时重新扫描配置的时间范围，用于补回漏检的邮件
+func (a *App) ResetAccountSyncState(accountID uint) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+	account, err := a.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	if err := a.db.ResetAccountSyncState(account.ID); err != nil {
+		return fmt.Errorf("重置账户扫描状态失败: %v", err)
+	}
+	a.logger.Infof("账户 %s 的增量扫描状态已重置，下次检查将重新扫描", account.Email)
+	return nil
+}
+
+// ValidateSearchCriteria 校验自定义IMAP SEARCH条件的语法，空字符串表示使用默认搜索
+func (a *App) ValidateSearchCriteria(raw string) error {
+	if _, err := services.ParseSearchCriteria(raw); err != nil {
+		return fmt.Errorf("自定义搜索条件无效: %v", err)
+	}
+	return nil
 }
 
 // DeleteEmailAccount 删除邮箱账户
@@ -211,6 +530,55 @@ func (a *App) DeleteEmailAccount(id uint) error {
 	return a.db.DeleteEmailAccount(id)
 }
 
+// BulkSetAccountsActive 批量启用或停用邮箱账户，返回每个账户的处理结果，部分账户失败不影响其他账户
+func (a *App) BulkSetAccountsActive(ids []uint, active bool) ([]models.BulkAccountResult, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("未选择邮箱账户")
+	}
+
+	results, err := a.db.BulkSetAccountsActive(ids, active)
+	if err != nil {
+		return nil, fmt.Errorf("批量更新邮箱账户失败: %v", err)
+	}
+
+	// 停用的账户不再需要保持连接
+	if !active && a.emailService != nil {
+		for _, result := range results {
+			if result.Success {
+				a.emailService.CloseConnection(result.ID)
+			}
+		}
+	}
+	return results, nil
+}
+
+// BulkDeleteAccounts 批量删除邮箱账户及其下载任务和邮件记录，返回每个账户的处理结果
+func (a *App) BulkDeleteAccounts(ids []uint) ([]models.BulkAccountResult, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("未选择邮箱账户")
+	}
+
+	results, err := a.db.BulkDeleteEmailAccounts(ids)
+	if err != nil {
+		return nil, fmt.Errorf("批量删除邮箱账户失败: %v", err)
+	}
+
+	if a.emailService != nil {
+		for _, result := range results {
+			if result.Success {
+				a.emailService.CloseConnection(result.ID)
+			}
+		}
+	}
+	return results, nil
+}
+
 // TestEmailConnection 测试邮箱连接
 func (a *App) TestEmailConnection(account models.EmailAccount) error {
 	return a.emailService.TestConnection(&account)
@@ -225,11 +593,59 @@ func (a *App) TestEmailConnectionByID(accountID uint) error {
 	return a.emailService.TestConnection(account)
 }
 
+// TestConnectionAndUpdate 测试已保存账户的连接，并在账户中记录测试时间和结果（失败时包括错误分类）
+// 添加账户前尚未保存时使用 TestEmailConnection，只测试不写入
+func (a *App) TestConnectionAndUpdate(accountID uint) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+	return a.emailService.TestConnectionAndUpdate(accountID)
+}
+
+// DiagnoseConnection 逐个阶段测试邮箱连接（DNS解析、TCP连接、TLS握手、登录、打开主文件夹），返回各阶段的结果和耗时
+// 某一阶段失败时返回的结果中记录失败的阶段，error 只在账户信息无效等无法开始诊断时返回
+func (a *App) DiagnoseConnection(account models.EmailAccount) (models.ConnectionDiagnostics, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.ConnectionDiagnostics{}, err
+	}
+	return a.emailService.DiagnoseConnection(&account)
+}
+
+// TestProxy 经代理发送一次请求，测试代理能否转发，用于在保存设置前确认代理可用
+func (a *App) TestProxy(proxyURL string) error {
+	return services.TestProxy(proxyURL)
+}
+
+// SetAccountMonitoring 暂停或恢复指定账户的后台监控，不影响账户启用状态和手动检查
+func (a *App) SetAccountMonitoring(accountID uint, enabled bool) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+	return a.db.SetAccountMonitoringPaused(accountID, !enabled)
+}
+
+// GetAccountSpecialFolders 获取账户的特殊用途文件夹（已发送、垃圾邮件、归档等）
+// refresh 为 true 时忽略缓存重新向服务器探测
+func (a *App) GetAccountSpecialFolders(accountID uint, refresh bool) (map[string]string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	return a.emailService.GetSpecialFolders(accountID, refresh)
+}
+
+// ValidateFolders 检查账户配置的额外扫描文件夹在服务器上是否存在，不存在时给出名称相近的文件夹
+func (a *App) ValidateFolders(accountID uint) ([]models.FolderCheck, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	return a.emailService.ValidateFolders(accountID)
+}
+
 // ====================
 // 邮件检查 API
 // ====================
 
-// CheckAllEmails 检查所有邮箱
+// CheckAllEmails 检查所有启用的邮箱，按最大并发数同时检查多个账户，返回每个账户的结果
 func (a *App) CheckAllEmails() ([]models.EmailCheckResult, error) {
 	if err := a.ensureServicesReady(); err != nil {
 		return nil, err
@@ -240,19 +656,15 @@ func (a *App) CheckAllEmails() ([]models.EmailCheckResult, error) {
 		return nil, fmt.Errorf("获取邮箱账户失败: %v", err)
 	}
 
-	results := make([]models.EmailCheckResult, 0, len(accounts))
-	
+	active := make([]models.EmailAccount, 0, len(accounts))
 	for _, account := range accounts {
-		if !account.IsActive {
-			continue
+		if account.IsActive {
+			active = append(active, account)
 		}
-		
-		// 调用实际的邮件检查逻辑
-		serviceResult := a.emailService.CheckAccountWithResult(&account)
-		results = append(results, serviceResult)
 	}
 	
-	return results, nil
+	// 并发检查，单个账户失败或超时只体现在该账户的结果中
+	return a.emailService.CheckAccounts(active), nil
 }
 
 // CheckSingleEmail 检查单个邮箱
@@ -272,48 +684,359 @@ func (a *App) CheckSingleEmail(accountID uint) (models.EmailCheckResult, error)
 		}, err
 	}
 
-	// 调用实际的邮件检查逻辑
-	serviceResult := a.emailService.CheckAccountWithResult(account)
-	return serviceResult, nil
+	// 调用实际的邮件检查逻辑
+	serviceResult := a.emailService.CheckAccountWithResult(account)
+	return serviceResult, nil
+}
+
+// ScanAccountRange 扫描账户中接收日期在 [since, before) 内的全部邮件（包括已读），按正常流程创建下载任务
+// 日期格式为 YYYY-MM-DD（before 当天不含在内），before 为空表示扫描到最新的邮件；已处理过的邮件会被跳过
+func (a *App) ScanAccountRange(accountID uint, since, before string) (models.EmailCheckResult, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.EmailCheckResult{Error: err.Error()}, err
+	}
+
+	sinceDate, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(since), time.Local)
+	if err != nil {
+		return models.EmailCheckResult{}, fmt.Errorf("开始日期格式错误: %s", since)
+	}
+	var beforeDate time.Time
+	if before = strings.TrimSpace(before); before != "" {
+		if beforeDate, err = time.ParseInLocation("2006-01-02", before, time.Local); err != nil {
+			return models.EmailCheckResult{}, fmt.Errorf("结束日期格式错误: %s", before)
+		}
+		if !sinceDate.Before(beforeDate) {
+			return models.EmailCheckResult{}, fmt.Errorf("开始日期必须早于结束日期")
+		}
+	}
+
+	account, err := a.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return models.EmailCheckResult{}, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+
+	return a.emailService.ScanAccountRange(account, sinceDate, beforeDate), nil
+}
+
+// BulkImport 按指定的历史范围导入单个账户的邮件，返回创建的任务数
+// 每个账户只能导入一次，需要再次导入时使用 ReprocessAll
+func (a *App) BulkImport(accountID uint, scope string, days int) (int, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return 0, err
+	}
+
+	account, err := a.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return 0, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	if account.HistoricalImportDone {
+		return 0, fmt.Errorf("账户 %s 已完成历史导入", account.Email)
+	}
+
+	return a.emailService.ImportHistory(account, scope, days)
+}
+
+// ReprocessAll 按指定的历史范围重新处理所有启用账户的邮件，返回创建的任务数
+// 已处理过的邮件会被跳过，忽略账户是否已完成历史导入
+func (a *App) ReprocessAll(scope string, days int) (int, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return 0, err
+	}
+
+	accounts, err := a.db.GetActiveEmailAccounts()
+	if err != nil {
+		return 0, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+
+	total := 0
+	for i := range accounts {
+		created, err := a.emailService.ImportHistory(&accounts[i], scope, days)
+		total += created
+		if err != nil {
+			return total, fmt.Errorf("账户 %s 处理失败: %v", accounts[i].Email, err)
+		}
+	}
+	return total, nil
+}
+
+// StartEmailMonitoring 启动邮件监控
+func (a *App) StartEmailMonitoring() error {
+	if a.emailService == nil {
+		return fmt.Errorf("邮件服务未初始化")
+	}
+	return a.emailService.StartEmailMonitoring()
+}
+
+// StopEmailMonitoring 停止邮件监控
+func (a *App) StopEmailMonitoring() {
+	if a.emailService != nil {
+		a.emailService.StopEmailMonitoring()
+	}
+}
+
+// ====================
+// 下载任务管理 API
+// ====================
+
+// GetDownloadTasksResponse 下载任务列表响应
+type GetDownloadTasksResponse struct {
+	Tasks []models.DownloadTask `json:"tasks"`
+	Total int                   `json:"total"`
+}
+
+// GetDownloadTasks 获取下载任务列表
+func (a *App) GetDownloadTasks(page, pageSize int) (GetDownloadTasksResponse, error) {
+	offset := (page - 1) * pageSize
+	tasks, total, err := a.db.GetDownloadTasks(pageSize, offset)
+	if err != nil {
+		return GetDownloadTasksResponse{}, err
+	}
+
+	return GetDownloadTasksResponse{
+		Tasks: tasks,
+		Total: int(total),
+	}, nil
+}
+
+// 筛选下载任务时每页数量的默认值和上限
+const (
+	defaultTaskPageSize = 20
+	maxTaskPageSize     = 500
+)
+
+// GetDownloadTasksFiltered 按状态、账户筛选下载任务，支持按大小、名称等排序，总数为筛选后的数量
+func (a *App) GetDownloadTasksFiltered(filter models.DownloadTaskFilter) (GetDownloadTasksResponse, error) {
+	if filter.SortBy != "" {
+		switch filter.SortBy {
+		case models.TaskSortCreatedAt, models.TaskSortUpdatedAt, models.TaskSortFileSize, models.TaskSortFileName, models.TaskSortStatus:
+		default:
+			return GetDownloadTasksResponse{}, fmt.Errorf("不支持的排序字段: %s", filter.SortBy)
+		}
+	}
+	if filter.SortOrder != "" && !strings.EqualFold(filter.SortOrder, "asc") && !strings.EqualFold(filter.SortOrder, "desc") {
+		return GetDownloadTasksResponse{}, fmt.Errorf("排序方向只能是 asc 或 desc")
+	}
+
+	pageSize := filter.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultTaskPageSize
+	} else if pageSize > maxTaskPageSize {
+		pageSize = maxTaskPageSize
+	}
+	page := filter.Page
+	if page < 1 {
+		page = 1
+	}
+
+	tasks, total, err := a.db.GetDownloadTasksFiltered(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return GetDownloadTasksResponse{}, fmt.Errorf("获取下载任务失败: %v", err)
+	}
+
+	return GetDownloadTasksResponse{
+		Tasks: tasks,
+		Total: int(total),
+	}, nil
+}
+
+// TaskChecksumResponse 下载文件校验和
+type TaskChecksumResponse struct {
+	TaskID    uint   `json:"task_id"`
+	Algorithm string `json:"algorithm"` // md5 或 sha256
+	Checksum  string `json:"checksum"`  // 十六进制校验和
+}
+
+// GetTaskFiles 获取ZIP附件任务解压出的文件
+func (a *App) GetTaskFiles(taskID uint) ([]models.TaskFile, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	return a.db.GetTaskFiles(taskID)
+}
+
+// GetTaskSpeedSamples 获取任务下载过程中的速度采样，用于绘制速度曲线
+func (a *App) GetTaskSpeedSamples(taskID uint) ([]models.SpeedSample, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	return a.db.GetSpeedSamples(taskID)
+}
+
+// GetTaskLog 获取任务的事件记录（开始、重试、续传、状态变化及失败原因），按时间顺序，每个任务保留最近的记录
+func (a *App) GetTaskLog(taskID uint) ([]models.TaskLogEntry, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	return a.db.GetTaskLogs(taskID)
+}
+
+// GetTaskDetail 获取任务详情，包含来源邮件记录和所属邮箱账户
+func (a *App) GetTaskDetail(taskID uint) (models.TaskDetail, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.TaskDetail{}, err
+	}
+
+	detail, err := a.db.GetTaskDetail(taskID)
+	if err != nil {
+		return models.TaskDetail{}, fmt.Errorf("获取任务详情失败: %v", err)
+	}
+	return *detail, nil
+}
+
+// GetTaskChecksum 获取已完成任务的文件校验和，用于与邮件中提供的校验值比对
+func (a *App) GetTaskChecksum(taskID uint) (TaskChecksumResponse, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return TaskChecksumResponse{}, err
+	}
+
+	algorithm, checksum, err := a.downloadService.GetTaskChecksum(taskID)
+	if err != nil {
+		return TaskChecksumResponse{}, err
+	}
+
+	return TaskChecksumResponse{
+		TaskID:    taskID,
+		Algorithm: algorithm,
+		Checksum:  checksum,
+	}, nil
+}
+
+// GetPDFMetadata 获取已完成PDF的标题、作者、页数和缩略图，尚未建立索引时立即读取文件建立
+func (a *App) GetPDFMetadata(taskID uint) (*models.PDFMetadata, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	return a.downloadService.GetPDFMetadata(taskID)
+}
+
+// CleanOrphanedTempFiles 清理下载目录中崩溃遗留的临时文件，返回清理的文件数
+func (a *App) CleanOrphanedTempFiles() (int, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return 0, err
+	}
+
+	return a.downloadService.CleanOrphanedTempFiles()
+}
+
+// GetFailedTasksSummary 按错误原因（认证、网络、不存在、无效PDF、大小、其他）汇总失败的任务
+func (a *App) GetFailedTasksSummary() ([]models.FailureCategorySummary, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	return a.downloadService.GetFailedTasksSummary()
+}
+
+// GenerateMonthlyReport 按账户和发件人汇总指定月份完成的下载，保存为CSV或iCal文件
+// 弹出保存对话框选择保存位置，返回保存的路径，用户取消时返回空字符串
+func (a *App) GenerateMonthlyReport(year, month int, format string) (string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return "", err
+	}
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = models.ReportFormatCSV
+	}
+	if month < 1 || month > 12 {
+		return "", fmt.Errorf("月份无效: %d", month)
+	}
+
+	options := runtime.SaveDialogOptions{
+		Title:           "保存月度报表",
+		DefaultFilename: services.MonthlyReportFileName(year, month, format),
+	}
+	switch format {
+	case models.ReportFormatCSV:
+		options.Filters = []runtime.FileFilter{{DisplayName: "CSV文件 (*.csv)", Pattern: "*.csv"}}
+	case models.ReportFormatICal:
+		options.Filters = []runtime.FileFilter{{DisplayName: "日历文件 (*.ics)", Pattern: "*.ics"}}
+	default:
+		return "", fmt.Errorf("不支持的报表格式: %s", format)
+	}
+	if config, err := a.GetConfig(); err == nil {
+		options.DefaultDirectory = config.DownloadPath
+	}
+
+	path, err := runtime.SaveFileDialog(a.ctx, options)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return "", nil
+	}
+	if filepath.Ext(path) == "" {
+		path += "." + format
+	}
+
+	if err := a.downloadService.GenerateMonthlyReport(year, month, format, path); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// StartEmailMonitoring 启动邮件监控
-func (a *App) StartEmailMonitoring() error {
-	if a.emailService == nil {
-		return fmt.Errorf("邮件服务未初始化")
+// ExportTasks 把创建日期在 [fromDate, toDate] 内的下载任务导出为CSV或JSON文件，保存到下载目录，返回文件路径
+// 日期格式为 YYYY-MM-DD（本地时间，包含结束日期当天），为空表示不限制
+func (a *App) ExportTasks(format string, fromDate, toDate string) (string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return "", err
 	}
-	return a.emailService.StartEmailMonitoring()
-}
 
-// StopEmailMonitoring 停止邮件监控
-func (a *App) StopEmailMonitoring() {
-	if a.emailService != nil {
-		a.emailService.StopEmailMonitoring()
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		format = models.ReportFormatCSV
+	}
+	if format != models.ReportFormatCSV && format != models.ReportFormatJSON {
+		return "", fmt.Errorf("不支持的导出格式: %s", format)
 	}
-}
 
-// ====================
-// 下载任务管理 API
-// ====================
+	fromDate, toDate = strings.TrimSpace(fromDate), strings.TrimSpace(toDate)
+	var from, to time.Time
+	var err error
+	if fromDate != "" {
+		if from, err = time.ParseInLocation("2006-01-02", fromDate, time.Local); err != nil {
+			return "", fmt.Errorf("开始日期格式错误: %s", fromDate)
+		}
+	}
+	if toDate != "" {
+		if to, err = time.ParseInLocation("2006-01-02", toDate, time.Local); err != nil {
+			return "", fmt.Errorf("结束日期格式错误: %s", toDate)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return "", fmt.Errorf("开始日期不能晚于结束日期")
+	}
 
-// GetDownloadTasksResponse 下载任务列表响应
-type GetDownloadTasksResponse struct {
-	Tasks []models.DownloadTask `json:"tasks"`
-	Total int                   `json:"total"`
+	config, err := a.GetConfig()
+	if err != nil {
+		return "", fmt.Errorf("获取配置失败: %v", err)
+	}
+	if err := os.MkdirAll(config.DownloadPath, 0755); err != nil {
+		return "", fmt.Errorf("创建下载目录失败: %v", err)
+	}
+	path := filepath.Join(config.DownloadPath, services.TaskExportFileName(fromDate, toDate, format))
+
+	if _, err := a.downloadService.ExportTasks(format, from, to, path); err != nil {
+		return "", err
+	}
+	return path, nil
 }
 
-// GetDownloadTasks 获取下载任务列表
-func (a *App) GetDownloadTasks(page, pageSize int) (GetDownloadTasksResponse, error) {
-	offset := (page - 1) * pageSize
-	tasks, total, err := a.db.GetDownloadTasks(pageSize, offset)
-	if err != nil {
-		return GetDownloadTasksResponse{}, err
+// ImportEMLFile 从本地 .eml 文件中提取PDF附件并保存到下载目录，不需要邮箱账户
+func (a *App) ImportEMLFile(path string) ([]models.DownloadTask, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
 	}
 
-	return GetDownloadTasksResponse{
-		Tasks: tasks,
-		Total: int(total),
-	}, nil
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("未指定EML文件")
+	}
+	return a.downloadService.ImportEMLFile(path)
 }
 
 // GetDownloadTasksByStatus 根据状态获取下载任务
@@ -354,11 +1077,49 @@ func (a *App) ResumeDownloadTask(taskID uint) error {
 	return a.downloadService.StartDownload(taskID)
 }
 
+// RetryDownloadTask 重试失败或已取消的任务：重置为等待状态、清除错误信息后重新排队
+// 重试次数达到配置的最大重试次数后拒绝
+func (a *App) RetryDownloadTask(taskID uint) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+
+	return a.downloadService.RetryTask(taskID)
+}
+
+// RevalidateTask 重新验证已完成任务的本地文件，文件缺失或损坏时任务标记为失败
+func (a *App) RevalidateTask(taskID uint) (models.ValidationResult, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.ValidationResult{}, err
+	}
+
+	return a.downloadService.RevalidateTask(taskID)
+}
+
 // CancelDownloadTask 取消下载任务
 func (a *App) CancelDownloadTask(taskID uint) error {
 	return a.downloadService.CancelDownload(taskID)
 }
 
+// CancelAllDownloads 取消所有正在下载和等待中的任务，返回取消的任务数
+func (a *App) CancelAllDownloads() (int, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return 0, err
+	}
+
+	return a.downloadService.CancelAllDownloads()
+}
+
+// DeleteDownloadTask 从列表中删除下载任务，deleteFile 为true时同时删除已下载的本地文件
+// 正在下载的任务需要先取消
+func (a *App) DeleteDownloadTask(taskID uint, deleteFile bool) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+
+	return a.downloadService.DeleteTask(taskID, deleteFile)
+}
+
 // GetActiveDownloads 获取活跃的下载任务
 func (a *App) GetActiveDownloads() []models.DownloadTask {
 	tasks, err := a.downloadService.GetAllTasks()
@@ -376,6 +1137,24 @@ func (a *App) GetActiveDownloads() []models.DownloadTask {
 	return activeTasks
 }
 
+// GetNetworkActivity 获取下载中任务的实时速度、进度和连接主机，以及总吞吐量
+func (a *App) GetNetworkActivity() (models.NetworkActivity, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.NetworkActivity{}, err
+	}
+
+	return a.downloadService.GetNetworkActivity(), nil
+}
+
+// GetDownloadMetrics 获取本次运行以来完成和失败的下载数以及收到的字节数
+func (a *App) GetDownloadMetrics() (models.DownloadMetrics, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return models.DownloadMetrics{}, err
+	}
+
+	return a.downloadService.GetMetrics(), nil
+}
+
 // ====================
 // 配置管理 API
 // ====================
@@ -402,6 +1181,137 @@ func (a *App) UpdateConfig(config models.AppConfig) error {
 		return err
 	}
 
+	// 验证下载时间窗口格式
+	for _, value := range []string{config.DownloadWindowStart, config.DownloadWindowEnd} {
+		if value == "" {
+			continue
+		}
+		if _, err := utils.ParseTimeOfDay(value); err != nil {
+			return fmt.Errorf("下载时间窗口设置无效: %v", err)
+		}
+	}
+
+	// 验证免打扰时段，开始和结束需要同时填写
+	if (config.QuietHoursStart == "") != (config.QuietHoursEnd == "") {
+		return fmt.Errorf("免打扰时段需要同时设置开始和结束时间")
+	}
+	if config.QuietHoursStart != "" {
+		start, err := utils.ParseTimeOfDay(config.QuietHoursStart)
+		if err != nil {
+			return fmt.Errorf("免打扰时段设置无效: %v", err)
+		}
+		end, err := utils.ParseTimeOfDay(config.QuietHoursEnd)
+		if err != nil {
+			return fmt.Errorf("免打扰时段设置无效: %v", err)
+		}
+		if start == end {
+			return fmt.Errorf("免打扰时段的开始和结束时间不能相同")
+		}
+	}
+
+	// 验证后台检查的历史范围
+	if _, err := services.HistorySince(config.CheckScope, config.CheckScopeDays, &models.EmailAccount{}); err != nil {
+		return fmt.Errorf("历史范围设置无效: %v", err)
+	}
+
+	if config.FetchBatchSize < 0 || config.FetchBatchSize > services.MaxFetchBatchSize {
+		return fmt.Errorf("每批获取邮件数必须在 0 到 %d 之间（0表示使用默认值）", services.MaxFetchBatchSize)
+	}
+	if config.MaxRedirects < 0 || config.MaxRedirects > services.MaxRedirectsLimit {
+		return fmt.Errorf("最大重定向次数必须在 0 到 %d 之间（0表示使用默认值）", services.MaxRedirectsLimit)
+	}
+	if config.QuickRetryAttempts < 0 || config.QuickRetryAttempts > services.MaxQuickRetryAttempts {
+		return fmt.Errorf("原地重试次数必须在 0 到 %d 之间（0表示不重试）", services.MaxQuickRetryAttempts)
+	}
+	if config.MaxTaskRetries < 0 || config.MaxTaskRetries > services.MaxTaskRetriesLimit {
+		return fmt.Errorf("任务最大重试次数必须在 0 到 %d 之间（0表示不允许重试）", services.MaxTaskRetriesLimit)
+	}
+	if config.TaskRetryBackoffSeconds < services.MinTaskRetryBackoffSeconds || config.TaskRetryBackoffSeconds > services.MaxTaskRetryBackoffSeconds {
+		return fmt.Errorf("自动重试等待时间必须在 %d 到 %d 秒之间", services.MinTaskRetryBackoffSeconds, services.MaxTaskRetryBackoffSeconds)
+	}
+	if config.AccountCheckTimeoutMinutes < 0 || config.AccountCheckTimeoutMinutes > services.MaxAccountCheckTimeoutMinutes {
+		return fmt.Errorf("单个账户检查期限必须在 0 到 %d 分钟之间（0表示使用默认值）", services.MaxAccountCheckTimeoutMinutes)
+	}
+	if config.RecoveryDelaySeconds < 0 || config.RecoveryDelaySeconds > services.MaxRecoveryDelaySeconds {
+		return fmt.Errorf("恢复任务前的等待时间必须在 0 到 %d 秒之间", services.MaxRecoveryDelaySeconds)
+	}
+	if config.RecoveryTasksPerMinute < 0 || config.RecoveryTasksPerMinute > services.MaxRecoveryTasksPerMinute {
+		return fmt.Errorf("每分钟恢复任务数必须在 0 到 %d 之间（0表示不限速）", services.MaxRecoveryTasksPerMinute)
+	}
+	if config.RecoveryMaxAgeHours < 0 || config.RecoveryMaxAgeHours > services.MaxRecoveryMaxAgeHours {
+		return fmt.Errorf("恢复任务的期限必须在 0 到 %d 小时之间（0表示使用默认值）", services.MaxRecoveryMaxAgeHours)
+	}
+	if config.PendingExpiryHours < 0 || config.PendingExpiryHours > services.MaxPendingExpiryHours {
+		return fmt.Errorf("等待任务的过期时间必须在 0 到 %d 小时之间（0表示不清理）", services.MaxPendingExpiryHours)
+	}
+	if config.ScanWindowDays < 1 || config.ScanWindowDays > services.MaxScanWindowDays {
+		return fmt.Errorf("邮件搜索时间窗口必须在 1 到 %d 天之间", services.MaxScanWindowDays)
+	}
+	if config.InitialScanDays < 0 || config.InitialScanDays > services.MaxScanWindowDays {
+		return fmt.Errorf("首次检查导入的天数必须在 0 到 %d 天之间（0表示不导入）", services.MaxScanWindowDays)
+	}
+	config.ProxyURL = strings.TrimSpace(config.ProxyURL)
+	if _, err := services.ParseProxyURL(config.ProxyURL); err != nil {
+		return err
+	}
+	if config.MaxFileSizeMB < 0 {
+		return fmt.Errorf("最大文件大小不能为负数（0表示不限制）")
+	}
+	if config.MinFreeMemoryMB < 0 {
+		return fmt.Errorf("可用内存下限不能为负数（0表示不限制）")
+	}
+	if config.MaxIMAPConnections < 0 {
+		return fmt.Errorf("IMAP连接数上限不能为负数（0表示不限制）")
+	}
+	if err := services.ValidateSenderPatterns(config.SenderAllowlist); err != nil {
+		return err
+	}
+	if err := services.ValidateSenderPatterns(config.SenderBlocklist); err != nil {
+		return err
+	}
+	config.MergePDFPattern = strings.TrimSpace(config.MergePDFPattern)
+	if err := services.ValidateMergePattern(config.MergePDFPattern); err != nil {
+		return err
+	}
+	config.WebhookURL = strings.TrimSpace(config.WebhookURL)
+	if err := services.ValidateWebhookURL(config.WebhookURL); err != nil {
+		return err
+	}
+	config.AllowedExtensions = models.NormalizeExtensions(config.AllowedExtensions)
+	for _, ext := range config.AllowedExtensions {
+		if !utils.IsValidExtension(ext) {
+			return fmt.Errorf("附件类型无效: %s（只能包含字母和数字）", ext)
+		}
+	}
+
+	switch config.CertPinningMode {
+	case "", models.CertPinningOff, models.CertPinningWarn, models.CertPinningEnforce:
+	default:
+		return fmt.Errorf("不支持的证书固定模式: %s", config.CertPinningMode)
+	}
+
+	switch config.DuplicateSourcePreference {
+	case "", models.PreferAttachmentSource, models.PreferLinkSource:
+	default:
+		return fmt.Errorf("不支持的重复来源偏好: %s", config.DuplicateSourcePreference)
+	}
+
+	switch config.StatisticsDateSource {
+	case "":
+		config.StatisticsDateSource = models.StatisticsDateCompleted
+	case models.StatisticsDateCompleted, models.StatisticsDateReceived:
+	default:
+		return fmt.Errorf("不支持的统计日期依据: %s", config.StatisticsDateSource)
+	}
+
+	switch config.OrganizeBy {
+	case "":
+		config.OrganizeBy = models.OrganizeByNone
+	case models.OrganizeByNone, models.OrganizeByAccountSender:
+	default:
+		return fmt.Errorf("不支持的文件整理方式: %s", config.OrganizeBy)
+	}
+
 	// 更新配置
 	if err := a.db.UpdateConfig(&config); err != nil {
 		return err
@@ -413,6 +1323,43 @@ func (a *App) UpdateConfig(config models.AppConfig) error {
 	return nil
 }
 
+// SetTelemetryEnabled 开启或关闭匿名错误遥测
+// 遥测只包含错误分类和服务商域名，不包含邮件内容、邮箱地址或凭据
+func (a *App) SetTelemetryEnabled(enabled bool) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+
+	config, err := a.GetConfig()
+	if err != nil {
+		return fmt.Errorf("获取配置失败: %v", err)
+	}
+
+	config.TelemetryEnabled = enabled
+	return a.UpdateConfig(config)
+}
+
+// GetRules 按顺序获取全部下载规则
+func (a *App) GetRules() ([]models.Rule, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	return a.db.GetRules()
+}
+
+// SetRules 用新的列表替换全部下载规则，所有规则验证通过后在一个事务中保存，任一规则无效时原有规则保持不变
+func (a *App) SetRules(rules []models.Rule) error {
+	if err := a.ensureServicesReady(); err != nil {
+		return err
+	}
+	for i := range rules {
+		if err := services.ValidateRule(&rules[i]); err != nil {
+			return err
+		}
+	}
+	return a.db.ReplaceRules(rules)
+}
+
 // handleConfigChange 处理配置变更
 func (a *App) handleConfigChange(oldConfig, newConfig *models.AppConfig) {
 	// 更新下载服务的最大并发数
@@ -420,6 +1367,10 @@ func (a *App) handleConfigChange(oldConfig, newConfig *models.AppConfig) {
 		a.downloadService.SetMaxConcurrent(newConfig.MaxConcurrent)
 	}
 
+	if oldConfig.MaxIMAPConnections != newConfig.MaxIMAPConnections {
+		a.emailService.SetMaxConnections(newConfig.MaxIMAPConnections)
+	}
+
 	// 更新邮件检查间隔
 	if oldConfig.CheckInterval != newConfig.CheckInterval {
 		a.emailService.SetCheckInterval(time.Duration(newConfig.CheckInterval) * time.Second)
@@ -436,12 +1387,42 @@ func (a *App) handleConfigChange(oldConfig, newConfig *models.AppConfig) {
 		}
 	}
 
+	// 开启相对路径模式或更换下载目录后，将新下载目录内已有的绝对路径转换为相对路径
+	if newConfig.RelativePaths && (!oldConfig.RelativePaths || oldConfig.DownloadPath != newConfig.DownloadPath) {
+		if converted, err := a.db.ConvertTaskPathsToRelative(); err != nil {
+			a.logger.Errorf("转换下载路径失败: %v", err)
+		} else if converted > 0 {
+			a.logger.Infof("已将 %d 个下载任务的路径转换为相对路径", converted)
+		}
+	}
+
+	// 更新下载时间窗口
+	if oldConfig.DownloadWindowStart != newConfig.DownloadWindowStart || oldConfig.DownloadWindowEnd != newConfig.DownloadWindowEnd {
+		if err := a.downloadService.SetDownloadWindow(newConfig.DownloadWindowStart, newConfig.DownloadWindowEnd); err != nil {
+			a.logger.Errorf("设置下载时间窗口失败: %v", err)
+		}
+	}
+
+	// 更新免打扰时段
+	if oldConfig.QuietHoursStart != newConfig.QuietHoursStart || oldConfig.QuietHoursEnd != newConfig.QuietHoursEnd {
+		if err := a.emailService.SetQuietHours(newConfig.QuietHoursStart, newConfig.QuietHoursEnd); err != nil {
+			a.logger.Errorf("设置免打扰时段失败: %v", err)
+		}
+	}
+
+	// 更新错误遥测设置
+	if oldConfig.TelemetryEnabled != newConfig.TelemetryEnabled || oldConfig.TelemetryEndpoint != newConfig.TelemetryEndpoint {
+		a.telemetryService.Configure(newConfig.TelemetryEnabled, newConfig.TelemetryEndpoint)
+	}
+
 	// 处理托盘状态变更
 	if oldConfig.MinimizeToTray != newConfig.MinimizeToTray {
 		if newConfig.MinimizeToTray {
-			if err := a.trayService.Start(); err != nil {
+			// 等待托盘就绪最多需要数秒，不阻塞保存配置
+			a.trayService.Start(func(err error) {
 				a.logger.Errorf("启动系统托盘失败: %v", err)
-			}
+				a.notifyTrayUnavailable()
+			})
 		} else {
 			a.trayService.Stop()
 		}
@@ -495,15 +1476,63 @@ func (a *App) SelectDownloadFolder() (string, error) {
 	return selectedPath, nil
 }
 
+// SelectEMLFile 选择要导入的邮件文件
+func (a *App) SelectEMLFile() (string, error) {
+	options := runtime.OpenDialogOptions{
+		Title: "选择邮件文件",
+		Filters: []runtime.FileFilter{
+			{DisplayName: "邮件文件 (*.eml)", Pattern: "*.eml"},
+		},
+	}
+
+	return runtime.OpenFileDialog(a.ctx, options)
+}
+
 // ====================
 // 窗口和通知管理 API
 // ====================
 
-// MinimizeToTray 最小化到托盘
+// ValidateDownloadPath 检测下载目录是否存在、可写及可用空间，路径为空时检测当前配置的下载目录
+func (a *App) ValidateDownloadPath(path string) (models.PathCheck, error) {
+	if strings.TrimSpace(path) == "" {
+		config, err := a.GetConfig()
+		if err != nil {
+			return models.PathCheck{}, fmt.Errorf("获取配置失败: %v", err)
+		}
+		path = config.DownloadPath
+	}
+	if strings.TrimSpace(path) == "" {
+		return models.PathCheck{}, fmt.Errorf("下载目录未设置")
+	}
+
+	return services.CheckDownloadPath(path, 0), nil
+}
+
+// MinimizeToTray 最小化到托盘，托盘不可用时改为普通最小化
 func (a *App) MinimizeToTray() {
+	if !a.trayAvailable() {
+		runtime.WindowMinimise(a.ctx)
+		return
+	}
 	runtime.WindowHide(a.ctx)
 }
 
+// trayAvailable 系统托盘是否可用
+func (a *App) trayAvailable() bool {
+	return a.trayService != nil && a.trayService.IsAvailable()
+}
+
+// notifyTrayUnavailable 通知前端系统托盘不可用，每次运行只通知一次
+func (a *App) notifyTrayUnavailable() {
+	reason := a.trayService.UnsupportedReason()
+	if reason == "" {
+		return
+	}
+	a.trayNoticeOnce.Do(func() {
+		runtime.EventsEmit(a.ctx, TrayUnavailableEvent, reason)
+	})
+}
+
 // RestoreFromTray 从托盘恢复
 func (a *App) RestoreFromTray() {
 	runtime.WindowShow(a.ctx)
@@ -512,9 +1541,39 @@ func (a *App) RestoreFromTray() {
 
 // QuitApp 退出应用
 func (a *App) QuitApp() {
+	a.shutdownMutex.Lock()
+	a.quitRequested = true
+	a.shutdownMutex.Unlock()
 	runtime.Quit(a.ctx)
 }
 
+// onDownloadBatchCompleted 一批下载全部完成后通知前端播放提示音
+// 提示音和桌面通知使用同一个开关，关闭通知时不播放
+func (a *App) onDownloadBatchCompleted(count int) {
+	config, err := a.GetConfig()
+	if err != nil || !config.CompletionSound || !config.EnableNotification {
+		return
+	}
+	runtime.EventsEmit(a.ctx, DownloadBatchCompletedEvent, count)
+}
+
+// notifyCheckResult 后台检查失败时发送经过去重和限流的通知，检查成功时结束该账户的失败记录
+func (a *App) notifyCheckResult(result models.EmailCheckResult) {
+	if a.errorNotifier == nil || result.Account == nil {
+		return
+	}
+	if result.Success {
+		a.errorNotifier.Succeeded(result.Account.ID)
+		return
+	}
+	if result.Error == "" {
+		return
+	}
+	if title, message, send := a.errorNotifier.Failed(result.Account, result.Error); send {
+		a.ShowNotification(title, message)
+	}
+}
+
 // ShowNotification 显示通知
 func (a *App) ShowNotification(title, message string) {
 	config, err := a.GetConfig()
@@ -541,6 +1600,20 @@ func (a *App) GetAppInfo() map[string]interface{} {
 	}
 }
 
+// GetRecentLogs 获取最近的日志行（已脱敏），用于应用内诊断
+func (a *App) GetRecentLogs(lines int) ([]string, error) {
+	return utils.TailLogFile(lines)
+}
+
+// ClearLogs 清空日志文件
+func (a *App) ClearLogs() error {
+	if err := utils.ClearLogFile(); err != nil {
+		return err
+	}
+	a.logger.Info("日志已清空")
+	return nil
+}
+
 // IsEmailServiceRunning 检查邮件服务是否运行
 func (a *App) IsEmailServiceRunning() bool {
 	return a.emailService != nil && a.emailService.IsRunning()
@@ -552,20 +1625,71 @@ func (a *App) GetActiveDownloadsCount() int {
 }
 
 // GetServiceStatus 获取服务状态
-func (a *App) GetServiceStatus() map[string]bool {
-	return map[string]bool{
-		"email":    a.IsEmailServiceRunning(),
-		"download": a.downloadService != nil,
-		"tray":     a.trayService != nil,
+// imapConnections 为当前的IMAP连接数（检查、IDLE推送和附件下载）
+func (a *App) GetServiceStatus() map[string]interface{} {
+	imapConnections := 0
+	if a.emailService != nil {
+		imapConnections = a.emailService.ConnectionCount()
+	}
+	return map[string]interface{}{
+		"email":                     a.IsEmailServiceRunning(),
+		"download":                  a.downloadService != nil,
+		"tray":                      a.trayAvailable(),
+		"trayUnsupported":           a.trayService != nil && a.trayService.UnsupportedReason() != "",
+		"downloadsPausedBySchedule": a.IsDownloadPausedBySchedule(),
+		"imapConnections":           imapConnections,
 	}
 }
 
+// IsDownloadPausedBySchedule 当前是否因不在下载时间窗口内而暂停下载
+func (a *App) IsDownloadPausedBySchedule() bool {
+	return a.downloadService != nil && a.downloadService.IsPausedBySchedule()
+}
+
 // GetEmailMessages 获取邮件消息列表
 func (a *App) GetEmailMessages(page, pageSize int) ([]models.EmailMessage, error) {
 	offset := (page - 1) * pageSize
 	return a.emailService.GetEmailMessages(pageSize, offset)
 }
 
+// SearchEmailMessages 按主题、发件人、收件人搜索已记录的邮件
+func (a *App) SearchEmailMessages(query string, page, pageSize int) ([]models.EmailMessage, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = defaultTaskPageSize
+	} else if pageSize > maxTaskPageSize {
+		pageSize = maxTaskPageSize
+	}
+	return a.db.SearchEmailMessages(query, pageSize, (page-1)*pageSize)
+}
+
+// PreviewAccount 预览检查账户时会下载的文件，不创建任务，也不修改邮件的已读状态
+func (a *App) PreviewAccount(accountID uint) ([]models.SourcePreview, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+
+	account, err := a.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	return a.emailService.PreviewAccount(account)
+}
+
+// GetMessageHeaders 获取邮件的原始头信息，用于编写按邮件头匹配的规则和排查检测规则未生效的原因
+// 不会将邮件标记为已读
+func (a *App) GetMessageHeaders(messageID string) (map[string][]string, error) {
+	if err := a.ensureServicesReady(); err != nil {
+		return nil, err
+	}
+	return a.emailService.GetMessageHeaders(messageID)
+}
+
 // initializeServices 初始化所有服务
 func (a *App) initializeServices() error {
 	a.initMutex.Lock()
@@ -578,21 +1702,59 @@ func (a *App) initializeServices() error {
 	a.logger.Info("开始初始化应用服务")
 	
 	// 初始化数据库
-	db, err := database.NewDatabase()
+	db, err := database.NewDatabase(database.DefaultDatabaseOptions())
 	if err != nil {
 		return fmt.Errorf("初始化数据库失败: %v", err)
 	}
 	a.db = db
+	if check := db.StartupCheck(); check.NeedsAttention() {
+		a.logger.Warnf("数据库上次未正常关闭，已将遗留的WAL（%d 字节）写回数据库文件", check.WALSize)
+		if check.CheckpointBusy {
+			a.logger.Warn("数据库被占用，WAL检查点未完成，将在之后自动写回")
+		}
+		if check.IntegrityError != "" {
+			a.logger.Errorf("数据库完整性检查发现问题，建议从备份恢复: %s", check.IntegrityError)
+		}
+	}
+	if check := db.StartupCheck(); check.MessageSearchError != "" {
+		a.logger.Warnf("邮件全文索引不可用，搜索将使用LIKE匹配: %s", check.MessageSearchError)
+	}
 	a.logger.Info("数据库初始化完成")
 	
 	// 初始化下载服务
 	a.downloadService = services.NewDownloadService(db)
+	if config, err := db.GetConfig(); err == nil {
+		if err := a.downloadService.SetDownloadWindow(config.DownloadWindowStart, config.DownloadWindowEnd); err != nil {
+			a.logger.Warnf("下载时间窗口配置无效，已忽略: %v", err)
+		}
+	}
+	a.downloadService.SetBatchCompletedHandler(a.onDownloadBatchCompleted)
+	a.downloadService.AddProgressReporter(services.ProgressReporterFunc(func(update services.ProgressUpdate) error {
+		runtime.EventsEmit(a.ctx, DownloadProgressEvent, update)
+		return nil
+	}))
 	a.logger.Info("下载服务初始化完成")
 	
 	// 初始化邮件服务
 	a.emailService = services.NewEmailService(db, a.downloadService, a.logger)
+	if config, err := db.GetConfig(); err == nil {
+		a.emailService.SetMaxConnections(config.MaxIMAPConnections)
+		if err := a.emailService.SetQuietHours(config.QuietHoursStart, config.QuietHoursEnd); err != nil {
+			a.logger.Warnf("免打扰时段配置无效，已忽略: %v", err)
+		}
+	}
+	a.errorNotifier = services.NewErrorNotifier(services.DefaultErrorNotifyCooldown, services.DefaultErrorReminderInterval)
+	a.emailService.SetAccountCheckedHandler(func(result models.EmailCheckResult) {
+		runtime.EventsEmit(a.ctx, AccountCheckedEvent, result)
+		a.notifyCheckResult(result)
+	})
 	a.logger.Info("邮件服务初始化完成")
 	
+	// 初始化错误遥测服务（默认关闭，需用户在设置中开启）
+	a.telemetryService = services.NewTelemetryService(db, a.logger)
+	a.downloadService.SetTelemetry(a.telemetryService)
+	a.emailService.SetTelemetry(a.telemetryService)
+	
 	// 初始化托盘服务
 	a.trayService = services.NewTrayService(db, a.logger)
 	a.logger.Info("托盘服务初始化完成")
@@ -600,11 +1762,12 @@ func (a *App) initializeServices() error {
 	// 设置托盘回调
 	a.setupTrayCallbacks()
 	
-	// 启动托盘服务
-	if err := a.trayService.Start(); err != nil {
+	// 启动托盘服务，等待托盘就绪期间不阻塞其他服务
+	a.trayService.Start(func(err error) {
 		a.logger.Errorf("启动托盘服务失败: %v", err)
-		// 托盘服务失败不应该阻止应用启动
-	}
+		// 托盘服务失败不应该阻止应用启动，关闭和最小化窗口按普通窗口处理
+		a.notifyTrayUnavailable()
+	})
 	
 	a.isInitialized = true
 	a.logger.Info("所有服务初始化完成")
@@ -691,6 +1854,16 @@ func (a *App) shutdown() {
 			}()
 		}
 		
+		// 停止遥测服务
+		if a.telemetryService != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.telemetryService.Stop()
+				a.logger.Info("遥测服务已停止")
+			}()
+		}
+		
 		// 停止托盘服务
 		if a.trayService != nil {
 			wg.Add(1)
@@ -3,11 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"emaild/backend/models"
@@ -19,6 +21,17 @@ import (
 type Database struct {
 	DB *sql.DB
 	mu sync.RWMutex // 保护数据库操作的读写锁
+
+	// 下载任务路径的存储方式，随配置更新（见 task_paths.go）
+	pathMutex     sync.RWMutex
+	downloadRoot  string // 当前下载目录，相对路径以此为根
+	relativePaths bool   // 是否以相对路径保存下载目录内的文件
+
+	// 邮件全文索引是否可用，不可用时搜索退回 LIKE 匹配（见 message_search.go）
+	messageSearchFTS bool
+
+	// 启动时的WAL恢复、完整性检查和全文索引创建结果（见 wal_recovery.go）
+	startupCheck StartupCheck
 }
 
 // WithTransaction 执行事务的通用方法（增强版）
@@ -109,24 +122,94 @@ func isRetryableError(err error) bool {
 	return false
 }
 
-// NewDatabase 创建新的数据库连接
-func NewDatabase() (*Database, error) {
+// DatabaseOptions 数据库打开选项
+type DatabaseOptions struct {
+	Path        string // 数据库文件路径，为空时使用 ~/.emaild/emaild.db
+	InMemory    bool   // 使用内存数据库（用于测试，关闭后数据丢失）
+	CacheSize   int    // SQLite cache_size，正数为页数，负数为KiB
+	JournalMode string // SQLite journal_mode，如 WAL、DELETE、MEMORY
+}
+
+// DefaultDatabaseOptions 生产环境的默认数据库选项
+func DefaultDatabaseOptions() DatabaseOptions {
+	return DatabaseOptions{
+		CacheSize:   10000,
+		JournalMode: "WAL",
+	}
+}
+
+// validJournalModes SQLite支持的journal_mode
+var validJournalModes = map[string]bool{
+	"DELETE":   true,
+	"TRUNCATE": true,
+	"PERSIST":  true,
+	"MEMORY":   true,
+	"WAL":      true,
+	"OFF":      true,
+}
+
+// memoryDatabaseSeq 区分不同的内存数据库实例
+var memoryDatabaseSeq int64
+
+// dataSourceName 根据选项确定数据库连接字符串
+func (opts DatabaseOptions) dataSourceName() (string, error) {
+	if opts.InMemory {
+		// 使用共享缓存的命名内存数据库，使连接池中的所有连接访问同一个库
+		seq := atomic.AddInt64(&memoryDatabaseSeq, 1)
+		return fmt.Sprintf("file:emaild-memory-%d?mode=memory&cache=shared", seq), nil
+	}
+
+	if opts.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.Path), 0755); err != nil {
+			return "", fmt.Errorf("创建数据目录失败: %v", err)
+		}
+		return opts.Path, nil
+	}
+
 	// 获取用户目录
 	userDir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("获取用户目录失败: %v", err)
+		return "", fmt.Errorf("获取用户目录失败: %v", err)
 	}
 
 	// 创建应用数据目录
 	appDataDir := filepath.Join(userDir, ".emaild")
 	if err := os.MkdirAll(appDataDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+		return "", fmt.Errorf("创建数据目录失败: %v", err)
 	}
 
-	dbPath := filepath.Join(appDataDir, "emaild.db")
+	return filepath.Join(appDataDir, "emaild.db"), nil
+}
+
+// NewDatabase 创建新的数据库连接
+func NewDatabase(opts DatabaseOptions) (*Database, error) {
+	defaults := DefaultDatabaseOptions()
+	if opts.CacheSize == 0 {
+		opts.CacheSize = defaults.CacheSize
+	}
+	opts.JournalMode = strings.ToUpper(strings.TrimSpace(opts.JournalMode))
+	if opts.JournalMode == "" {
+		opts.JournalMode = defaults.JournalMode
+		if opts.InMemory {
+			opts.JournalMode = "MEMORY" // 内存数据库不支持WAL
+		}
+	}
+	if !validJournalModes[opts.JournalMode] {
+		return nil, fmt.Errorf("不支持的journal_mode: %s", opts.JournalMode)
+	}
+
+	dsn, err := opts.dataSourceName()
+	if err != nil {
+		return nil, err
+	}
+	// 打开数据库前记录是否遗留了WAL文件，打开后SQLite会开始使用它
+	var walSize int64
+	if !opts.InMemory && opts.JournalMode == "WAL" {
+		walSize = leftoverWALSize(dsn)
+	}
 	
 	// 打开SQLite数据库
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("连接数据库失败: %v", err)
 	}
@@ -135,13 +218,17 @@ func NewDatabase() (*Database, error) {
 	db.SetMaxOpenConns(10)        // 减少最大连接数，避免资源竞争
 	db.SetMaxIdleConns(5)         // 设置合理的空闲连接数
 	db.SetConnMaxLifetime(15 * time.Minute) // 延长连接生命周期
+	if opts.InMemory {
+		// 所有连接关闭后内存数据库即被销毁，保持连接常驻
+		db.SetConnMaxLifetime(0)
+	}
 
 	// 启用关键的SQLite配置
 	pragmas := []string{
 		"PRAGMA foreign_keys = ON",           // 启用外键约束
-		"PRAGMA journal_mode = WAL",          // 启用WAL模式
+		fmt.Sprintf("PRAGMA journal_mode = %s", opts.JournalMode), // 默认启用WAL模式
 		"PRAGMA synchronous = NORMAL",        // 平衡性能和安全性
-		"PRAGMA cache_size = 10000",          // 增加缓存大小
+		fmt.Sprintf("PRAGMA cache_size = %d", opts.CacheSize),     // 默认增加缓存大小
 		"PRAGMA temp_store = memory",         // 临时表存储在内存中
 		"PRAGMA busy_timeout = 30000",        // 设置忙碌超时为30秒
 	}
@@ -155,18 +242,36 @@ func NewDatabase() (*Database, error) {
 
 	database := &Database{DB: db}
 
+	// 上次被强制结束时先把WAL写回数据库文件并检查完整性
+	if walSize > 0 {
+		if err := database.recoverWAL(walSize); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("恢复数据库失败: %v", err)
+		}
+	}
+
 	// 创建表结构
 	if err := database.createTables(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("创建表结构失败: %v", err)
 	}
 
+	// 为旧版本数据库补充新增的列
+	if err := database.migrateTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("迁移表结构失败: %v", err)
+	}
+
 	// 初始化默认配置
 	if err := database.initDefaultConfig(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("初始化默认配置失败: %v", err)
 	}
 
+	if config, err := database.GetConfig(); err == nil {
+		database.setPathSettings(&config)
+	}
+
 	return database, nil
 }
 
@@ -254,6 +359,105 @@ func (d *Database) createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+		
+		`CREATE TABLE IF NOT EXISTS partial_fragments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email_id INTEGER NOT NULL,
+			partial_id TEXT NOT NULL,
+			number INTEGER NOT NULL,
+			total INTEGER DEFAULT 0,
+			content BLOB,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (email_id, partial_id, number),
+			FOREIGN KEY (email_id) REFERENCES email_accounts(id) ON DELETE CASCADE
+		)`,
+		
+		`CREATE TABLE IF NOT EXISTS folder_states (
+			email_id INTEGER NOT NULL,
+			folder TEXT NOT NULL,
+			uid_validity INTEGER DEFAULT 0,
+			last_uid INTEGER DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (email_id, folder),
+			FOREIGN KEY (email_id) REFERENCES email_accounts(id) ON DELETE CASCADE
+		)`,
+		
+		`CREATE TABLE IF NOT EXISTS task_files (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			file_name TEXT NOT NULL,
+			local_path TEXT NOT NULL,
+			file_size INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (task_id) REFERENCES download_tasks(id) ON DELETE CASCADE
+		)`,
+		
+		`CREATE TABLE IF NOT EXISTS telemetry_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			source TEXT DEFAULT '',
+			category TEXT DEFAULT '',
+			domain TEXT DEFAULT '',
+			date TEXT DEFAULT ''
+		)`,
+		
+		`CREATE TABLE IF NOT EXISTS download_speed_samples (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			timestamp DATETIME NOT NULL,
+			bytes_per_second REAL DEFAULT 0,
+			FOREIGN KEY (task_id) REFERENCES download_tasks(id) ON DELETE CASCADE
+		)`,
+		
+		// foreign_keys 只对执行该PRAGMA的连接生效，用触发器保证删除任务时总会清理速度采样
+		`CREATE TRIGGER IF NOT EXISTS download_speed_samples_prune AFTER DELETE ON download_tasks BEGIN
+			DELETE FROM download_speed_samples WHERE task_id = old.id;
+		END`,
+		
+		`CREATE TABLE IF NOT EXISTS pdf_metadata (
+			task_id INTEGER PRIMARY KEY,
+			title TEXT DEFAULT '',
+			author TEXT DEFAULT '',
+			page_count INTEGER DEFAULT 0,
+			thumbnail TEXT DEFAULT '',
+			indexed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (task_id) REFERENCES download_tasks(id) ON DELETE CASCADE
+		)`,
+		
+		`CREATE TRIGGER IF NOT EXISTS pdf_metadata_prune AFTER DELETE ON download_tasks BEGIN
+			DELETE FROM pdf_metadata WHERE task_id = old.id;
+		END`,
+		
+		`CREATE TABLE IF NOT EXISTS download_task_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			level TEXT NOT NULL DEFAULT 'info',
+			message TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (task_id) REFERENCES download_tasks(id) ON DELETE CASCADE
+		)`,
+		
+		`CREATE TRIGGER IF NOT EXISTS download_task_logs_prune AFTER DELETE ON download_tasks BEGIN
+			DELETE FROM download_task_logs WHERE task_id = old.id;
+		END`,
+		
+		`CREATE TABLE IF NOT EXISTS rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL DEFAULT '',
+			account_id INTEGER,
+			field TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			action TEXT NOT NULL,
+			enabled BOOLEAN DEFAULT 1,
+			position INTEGER DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (account_id) REFERENCES email_accounts(id)
+		)`,
+		
+		// 账户删除后其专属规则由触发器清理，不依赖只在部分连接上生效的外键级联
+		`CREATE TRIGGER IF NOT EXISTS rules_prune AFTER DELETE ON email_accounts BEGIN
+			DELETE FROM rules WHERE account_id = old.id;
+		END`,
 	}
 
 	for _, table := range tables {
@@ -269,6 +473,9 @@ func (d *Database) createTables() error {
 		"CREATE INDEX IF NOT EXISTS idx_email_messages_message_id ON email_messages(message_id)",
 		"CREATE INDEX IF NOT EXISTS idx_email_messages_email_id ON email_messages(email_id)",
 		"CREATE INDEX IF NOT EXISTS idx_download_statistics_date ON download_statistics(date)",
+		"CREATE INDEX IF NOT EXISTS idx_task_files_task_id ON task_files(task_id)",
+		"CREATE INDEX IF NOT EXISTS idx_download_speed_samples_task_id ON download_speed_samples(task_id)",
+		"CREATE INDEX IF NOT EXISTS idx_download_task_logs_task_id ON download_task_logs(task_id)",
 	}
 
 	for _, index := range indexes {
@@ -280,6 +487,164 @@ func (d *Database) createTables() error {
 	return nil
 }
 
+// migrateTables 迁移表结构，为已有数据库补充后续版本新增的列
+// 新增列统一在这里声明，CREATE TABLE 中只保留初始表结构
+func (d *Database) migrateTables() error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"email_accounts", "folder_delimiter", "TEXT DEFAULT ''"},
+		{"email_accounts", "special_folders", "TEXT DEFAULT ''"},
+		{"email_accounts", "monitoring_paused", "BOOLEAN DEFAULT 0"},
+		{"email_accounts", "deferred_uids", "TEXT DEFAULT ''"},
+		{"email_accounts", "auth_mechanism", "TEXT DEFAULT 'auto'"},
+		{"email_accounts", "last_error", "TEXT DEFAULT ''"},
+		{"email_accounts", "historical_import_done", "BOOLEAN DEFAULT 0"},
+		{"email_accounts", "search_criteria", "TEXT DEFAULT ''"},
+		{"email_accounts", "scan_folders", "TEXT DEFAULT ''"},
+		{"email_accounts", "can_modify_flags", "BOOLEAN DEFAULT 1"},
+		{"email_accounts", "can_move_messages", "BOOLEAN DEFAULT 1"},
+		{"email_accounts", "cert_fingerprint", "TEXT DEFAULT ''"},
+		{"email_accounts", "pending_cert_fingerprint", "TEXT DEFAULT ''"},
+		{"email_accounts", "download_path", "TEXT DEFAULT ''"},
+		{"email_accounts", "folder", "TEXT DEFAULT 'INBOX'"},
+		{"email_accounts", "use_idle", "BOOLEAN DEFAULT 0"},
+		{"email_accounts", "ssh_host", "TEXT DEFAULT ''"},
+		{"email_accounts", "ssh_port", "INTEGER DEFAULT 22"},
+		{"email_accounts", "ssh_user", "TEXT DEFAULT ''"},
+		{"email_accounts", "ssh_key_path", "TEXT DEFAULT ''"},
+		{"email_accounts", "ssh_host_key", "TEXT DEFAULT ''"},
+		{"email_accounts", "auth_type", "TEXT DEFAULT 'password'"},
+		{"email_accounts", "oauth_client_id", "TEXT DEFAULT ''"},
+		{"email_accounts", "oauth_client_secret", "TEXT DEFAULT ''"},
+		{"email_accounts", "oauth_refresh_token", "TEXT DEFAULT ''"},
+		{"email_accounts", "oauth_token_url", "TEXT DEFAULT ''"},
+		{"email_accounts", "allowed_extensions", "TEXT DEFAULT ''"},
+		{"email_accounts", "last_error_category", "TEXT DEFAULT ''"},
+		{"email_accounts", "last_checked_at", "DATETIME"},
+		{"app_configs", "max_tasks_per_check", "INTEGER DEFAULT 100"},
+		{"app_configs", "download_deadline_minutes", "INTEGER DEFAULT 5"},
+		{"app_configs", "check_scope", "TEXT DEFAULT 'since-account-added'"},
+		{"app_configs", "check_scope_days", "INTEGER DEFAULT 7"},
+		{"app_configs", "telemetry_enabled", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "telemetry_endpoint", "TEXT DEFAULT ''"},
+		{"app_configs", "path_template", "TEXT DEFAULT ''"},
+		{"app_configs", "checksum_algorithm", "TEXT DEFAULT 'sha256'"},
+		{"app_configs", "download_window_start", "TEXT DEFAULT ''"},
+		{"app_configs", "download_window_end", "TEXT DEFAULT ''"},
+		{"app_configs", "duplicate_source_preference", "TEXT DEFAULT 'attachment'"},
+		{"app_configs", "auto_open_file", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "auto_open_folder", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "quarantine_invalid_files", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "fetch_batch_size", "INTEGER DEFAULT 50"},
+		{"app_configs", "cert_pinning_mode", "TEXT DEFAULT 'off'"},
+		{"app_configs", "relative_paths", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "max_redirects", "INTEGER DEFAULT 5"},
+		{"app_configs", "quick_retry_attempts", "INTEGER DEFAULT 2"},
+		{"app_configs", "account_check_timeout_minutes", "INTEGER DEFAULT 5"},
+		{"app_configs", "extract_zip_attachments", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "allowed_extensions", "TEXT DEFAULT ''"},
+		{"app_configs", "statistics_date_source", "TEXT DEFAULT 'completed'"},
+		{"app_configs", "completion_sound", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "max_task_retries", "INTEGER DEFAULT 3"},
+		{"app_configs", "task_retry_backoff_seconds", "INTEGER DEFAULT 30"},
+		{"app_configs", "organize_by", "TEXT DEFAULT 'none'"},
+		{"app_configs", "recovery_delay_seconds", "INTEGER DEFAULT 2"},
+		{"app_configs", "recovery_tasks_per_minute", "INTEGER DEFAULT 30"},
+		{"app_configs", "recovery_max_age_hours", "INTEGER DEFAULT 24"},
+		{"app_configs", "scan_window_days", "INTEGER DEFAULT 7"},
+		{"app_configs", "initial_scan_days", "INTEGER DEFAULT 0"},
+		{"app_configs", "proxy_url", "TEXT DEFAULT ''"},
+		{"app_configs", "strict_pdf_validation", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "pending_expiry_hours", "INTEGER DEFAULT 24"},
+		{"app_configs", "deduplicate_downloads", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "webhook_url", "TEXT DEFAULT ''"},
+		{"app_configs", "webhook_secret", "TEXT DEFAULT ''"},
+		{"app_configs", "max_file_size_mb", "INTEGER DEFAULT 0"},
+		{"app_configs", "sender_allowlist", "TEXT DEFAULT ''"},
+		{"app_configs", "sender_blocklist", "TEXT DEFAULT ''"},
+		{"app_configs", "index_pdf_metadata", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "pdf_thumbnails", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "merge_pdf_pattern", "TEXT DEFAULT ''"},
+		{"app_configs", "mark_as_read", "BOOLEAN DEFAULT 0"},
+		{"app_configs", "max_imap_connections", "INTEGER DEFAULT 0"},
+		{"app_configs", "min_free_memory_mb", "INTEGER DEFAULT 0"},
+		{"app_configs", "quiet_hours_start", "TEXT DEFAULT ''"},
+		{"app_configs", "quiet_hours_end", "TEXT DEFAULT ''"},
+		{"download_tasks", "checksum", "TEXT DEFAULT ''"},
+		{"download_tasks", "checksum_algorithm", "TEXT DEFAULT ''"},
+		{"download_tasks", "folder", "TEXT DEFAULT ''"},
+		{"download_tasks", "is_container", "BOOLEAN DEFAULT 0"},
+		{"download_tasks", "email_date", "DATETIME"},
+		{"download_tasks", "retry_count", "INTEGER DEFAULT 0"},
+		{"download_tasks", "message_id", "INTEGER REFERENCES email_messages(id) ON DELETE SET NULL"},
+		{"download_tasks", "hash", "TEXT DEFAULT ''"},
+		{"download_tasks", "duplicate_of", "INTEGER DEFAULT 0"},
+		{"download_tasks", "merged_into", "INTEGER DEFAULT 0"},
+		{"download_tasks", "resume_validator", "TEXT DEFAULT ''"},
+		{"download_statistics", "deduplicated_downloads", "INTEGER DEFAULT 0"},
+		{"download_statistics", "deduplicated_size", "INTEGER DEFAULT 0"},
+		{"email_messages", "gm_msgid", "TEXT DEFAULT ''"},
+		{"email_messages", "gm_thrid", "TEXT DEFAULT ''"},
+		{"email_messages", "pdf_total", "INTEGER DEFAULT 0"},
+		{"email_messages", "tasks_created", "INTEGER DEFAULT 0"},
+		{"email_messages", "pdf_downloaded", "INTEGER DEFAULT 0"},
+		{"email_messages", "pdf_failed", "INTEGER DEFAULT 0"},
+		{"email_messages", "extraction_status", "TEXT DEFAULT ''"},
+	}
+
+	for _, c := range columns {
+		if err := d.addColumnIfNotExists(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("添加列 %s.%s 失败: %v", c.table, c.column, err)
+		}
+	}
+
+	// 依赖新增列的索引需要在迁移之后创建
+	if _, err := d.DB.Exec("CREATE INDEX IF NOT EXISTS idx_email_messages_gm_msgid ON email_messages(email_id, gm_msgid)"); err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+	if _, err := d.DB.Exec("CREATE INDEX IF NOT EXISTS idx_download_tasks_hash ON download_tasks(hash)"); err != nil {
+		return fmt.Errorf("创建索引失败: %v", err)
+	}
+
+	// 全文索引不可用时不影响启动，记录原因由应用写入日志
+	if err := d.setupMessageSearch(); err != nil {
+		d.startupCheck.MessageSearchError = err.Error()
+	}
+
+	return nil
+}
+
+// addColumnIfNotExists 当列不存在时添加列
+func (d *Database) addColumnIfNotExists(table, column, definition string) error {
+	rows, err := d.DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if strings.EqualFold(name, column) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = d.DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 // initDefaultConfig 初始化默认配置
 func (d *Database) initDefaultConfig() error {
 	var count int
@@ -311,13 +676,19 @@ func (d *Database) CreateEmailAccount(account *models.EmailAccount) error {
 	
 	return d.WithTransaction(func(tx *sql.Tx) error {
 		query := `
-			INSERT INTO email_accounts (name, email, password, imap_server, imap_port, use_ssl, is_active, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			INSERT INTO email_accounts (name, email, password, imap_server, imap_port, use_ssl, is_active, auth_mechanism, search_criteria,
+				scan_folders, download_path, folder, use_idle, ssh_host, ssh_port, ssh_user, ssh_key_path, ssh_host_key,
+				auth_type, oauth_client_id, oauth_client_secret, oauth_refresh_token, oauth_token_url, allowed_extensions, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`
 		
 		result, err := tx.Exec(query,
 			account.Name, account.Email, account.Password, account.IMAPServer,
-			account.IMAPPort, account.UseSSL, account.IsActive, now, now,
+			account.IMAPPort, account.UseSSL, account.IsActive, account.AuthMechanism, account.SearchCriteria,
+			encodeScanFolders(account.ScanFolders), account.DownloadPath, account.MainFolder(), account.UseIdle,
+			account.SSHHost, account.SSHPort, account.SSHUser, account.SSHKeyPath, account.SSHHostKey,
+			authType(account), account.OAuthClientID, account.OAuthClientSecret, account.OAuthRefreshToken, account.OAuthTokenURL,
+			strings.Join(models.NormalizeExtensions(account.AllowedExtensions), ","), now, now,
 		)
 		if err != nil {
 			return err
@@ -336,11 +707,103 @@ func (d *Database) CreateEmailAccount(account *models.EmailAccount) error {
 	})
 }
 
-// GetEmailAccounts 获取所有邮箱账户
-func (d *Database) GetEmailAccounts() ([]models.EmailAccount, error) {
-	query := `SELECT id, name, email, password, imap_server, imap_port, use_ssl, is_active, created_at, updated_at FROM email_accounts ORDER BY created_at DESC`
-	
-	rows, err := d.DB.Query(query)
+// authType 返回账户保存的认证方式，未设置时为密码
+func authType(account *models.EmailAccount) string {
+	if account.UsesOAuth2() {
+		return models.AuthTypeOAuth2
+	}
+	return models.AuthTypePassword
+}
+
+// emailAccountColumns 邮箱账户查询列，与scanEmailAccount的扫描顺序保持一致
+const emailAccountColumns = `id, name, email, password, imap_server, imap_port, use_ssl, is_active,
+	monitoring_paused, auth_mechanism, last_error, historical_import_done, folder_delimiter, special_folders,
+	search_criteria, scan_folders, can_modify_flags, can_move_messages, cert_fingerprint, pending_cert_fingerprint,
+	download_path, folder, use_idle, ssh_host, ssh_port, ssh_user, ssh_key_path, ssh_host_key,
+	auth_type, oauth_client_id, oauth_client_secret, oauth_refresh_token, oauth_token_url, allowed_extensions,
+	last_error_category, last_checked_at, created_at, updated_at`
+
+// rowScanner 兼容 *sql.Row 和 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEmailAccount 扫描一行邮箱账户数据
+func scanEmailAccount(row rowScanner) (*models.EmailAccount, error) {
+	var account models.EmailAccount
+	var createdAt, updatedAt time.Time
+	var authMechanism, lastError, folderDelimiter, specialFolders, searchCriteria, scanFolders sql.NullString
+	var certFingerprint, pendingCertFingerprint, downloadPath, folder sql.NullString
+	var useIdle sql.NullBool
+	var sshHost, sshUser, sshKeyPath, sshHostKey sql.NullString
+	var sshPort sql.NullInt64
+	var authTypeValue, oauthClientID, oauthClientSecret, oauthRefreshToken, oauthTokenURL sql.NullString
+	var allowedExtensions, lastErrorCategory sql.NullString
+	var lastCheckedAt sql.NullTime
+
+	err := row.Scan(
+		&account.ID, &account.Name, &account.Email, &account.Password,
+		&account.IMAPServer, &account.IMAPPort, &account.UseSSL, &account.IsActive,
+		&account.MonitoringPaused, &authMechanism, &lastError, &account.HistoricalImportDone,
+		&folderDelimiter, &specialFolders,
+		&searchCriteria, &scanFolders, &account.CanModifyFlags, &account.CanMoveMessages,
+		&certFingerprint, &pendingCertFingerprint,
+		&downloadPath, &folder, &useIdle,
+		&sshHost, &sshPort, &sshUser, &sshKeyPath, &sshHostKey,
+		&authTypeValue, &oauthClientID, &oauthClientSecret, &oauthRefreshToken, &oauthTokenURL, &allowedExtensions,
+		&lastErrorCategory, &lastCheckedAt,
+		&createdAt, &updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	account.AuthMechanism = authMechanism.String
+	account.LastError = lastError.String
+	account.LastErrorCategory = lastErrorCategory.String
+	if lastCheckedAt.Valid {
+		account.LastCheckedAt = models.TimeToString(lastCheckedAt.Time)
+	}
+	account.FolderDelimiter = folderDelimiter.String
+	account.SearchCriteria = searchCriteria.String
+	account.CertFingerprint = certFingerprint.String
+	account.PendingCertFingerprint = pendingCertFingerprint.String
+	account.DownloadPath = downloadPath.String
+	account.Folder = folder.String
+	account.UseIdle = useIdle.Bool
+	account.SSHHost = sshHost.String
+	account.SSHPort = int(sshPort.Int64)
+	account.SSHUser = sshUser.String
+	account.SSHKeyPath = sshKeyPath.String
+	account.SSHHostKey = sshHostKey.String
+	account.AuthType = authTypeValue.String
+	account.OAuthClientID = oauthClientID.String
+	account.OAuthClientSecret = oauthClientSecret.String
+	account.OAuthRefreshToken = oauthRefreshToken.String
+	account.OAuthTokenURL = oauthTokenURL.String
+	account.AllowedExtensions = models.NormalizeExtensions(strings.Split(allowedExtensions.String, ","))
+	if account.AuthType == "" {
+		account.AuthType = models.AuthTypePassword
+	}
+	if account.Folder == "" {
+		account.Folder = models.DefaultFolder
+	}
+	if specialFolders.String != "" {
+		// 缓存损坏时忽略，下次连接会重新探测
+		json.Unmarshal([]byte(specialFolders.String), &account.SpecialFolders)
+	}
+	if scanFolders.String != "" {
+		json.Unmarshal([]byte(scanFolders.String), &account.ScanFolders)
+	}
+	account.CreatedAt = models.TimeToString(createdAt)
+	account.UpdatedAt = models.TimeToString(updatedAt)
+
+	return &account, nil
+}
+
+// queryEmailAccounts 查询邮箱账户列表
+func (d *Database) queryEmailAccounts(query string, args ...interface{}) ([]models.EmailAccount, error) {
+	rows, err := d.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -348,47 +811,30 @@ func (d *Database) GetEmailAccounts() ([]models.EmailAccount, error) {
 	
 	var accounts []models.EmailAccount
 	for rows.Next() {
-		var account models.EmailAccount
-		var createdAt, updatedAt time.Time
-		
-		err := rows.Scan(
-			&account.ID, &account.Name, &account.Email, &account.Password,
-			&account.IMAPServer, &account.IMAPPort, &account.UseSSL, &account.IsActive,
-			&createdAt, &updatedAt,
-		)
+		account, err := scanEmailAccount(rows)
 		if err != nil {
 			continue
 		}
-		
-		account.CreatedAt = models.TimeToString(createdAt)
-		account.UpdatedAt = models.TimeToString(updatedAt)
-		accounts = append(accounts, account)
+		accounts = append(accounts, *account)
 	}
 	
 	return accounts, nil
 }
 
+// GetEmailAccounts 获取所有邮箱账户
+func (d *Database) GetEmailAccounts() ([]models.EmailAccount, error) {
+	return d.queryEmailAccounts(`SELECT ` + emailAccountColumns + ` FROM email_accounts ORDER BY created_at DESC`)
+}
+
+// GetActiveEmailAccounts 获取所有启用的邮箱账户
+func (d *Database) GetActiveEmailAccounts() ([]models.EmailAccount, error) {
+	return d.queryEmailAccounts(`SELECT ` + emailAccountColumns + ` FROM email_accounts WHERE is_active = 1`)
+}
+
 // GetEmailAccountByID 根据ID获取邮箱账户
 func (d *Database) GetEmailAccountByID(id uint) (*models.EmailAccount, error) {
-	query := `SELECT id, name, email, password, imap_server, imap_port, use_ssl, is_active, created_at, updated_at FROM email_accounts WHERE id = ?`
-	
-	row := d.DB.QueryRow(query, id)
-	
-	var account models.EmailAccount
-	var createdAt, updatedAt time.Time
-	err := row.Scan(
-		&account.ID, &account.Name, &account.Email, &account.Password,
-		&account.IMAPServer, &account.IMAPPort, &account.UseSSL, &account.IsActive,
-		&createdAt, &updatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	
-	account.CreatedAt = models.TimeToString(createdAt)
-	account.UpdatedAt = models.TimeToString(updatedAt)
-	
-	return &account, nil
+	row := d.DB.QueryRow(`SELECT `+emailAccountColumns+` FROM email_accounts WHERE id = ?`, id)
+	return scanEmailAccount(row)
 }
 
 // UpdateEmailAccount 更新邮箱账户
@@ -399,13 +845,20 @@ func (d *Database) UpdateEmailAccount(account *models.EmailAccount) error {
 		query := `
 			UPDATE email_accounts 
 			SET name = ?, email = ?, password = ?, imap_server = ?, imap_port = ?, 
-				use_ssl = ?, is_active = ?, updated_at = ?
+				use_ssl = ?, is_active = ?, auth_mechanism = ?, search_criteria = ?, scan_folders = ?,
+				download_path = ?, folder = ?, use_idle = ?, ssh_host = ?, ssh_port = ?, ssh_user = ?, ssh_key_path = ?,
+				ssh_host_key = ?, auth_type = ?, oauth_client_id = ?, oauth_client_secret = ?, oauth_refresh_token = ?,
+				oauth_token_url = ?, allowed_extensions = ?, updated_at = ?
 			WHERE id = ?
 		`
 		
 		_, err := tx.Exec(query,
 			account.Name, account.Email, account.Password, account.IMAPServer,
-			account.IMAPPort, account.UseSSL, account.IsActive, now, account.ID,
+			account.IMAPPort, account.UseSSL, account.IsActive, account.AuthMechanism, account.SearchCriteria,
+			encodeScanFolders(account.ScanFolders), account.DownloadPath, account.MainFolder(), account.UseIdle,
+			account.SSHHost, account.SSHPort, account.SSHUser, account.SSHKeyPath, account.SSHHostKey,
+			authType(account), account.OAuthClientID, account.OAuthClientSecret, account.OAuthRefreshToken, account.OAuthTokenURL,
+			strings.Join(models.NormalizeExtensions(account.AllowedExtensions), ","), now, account.ID,
 		)
 		if err != nil {
 			return err
@@ -416,6 +869,181 @@ func (d *Database) UpdateEmailAccount(account *models.EmailAccount) error {
 	})
 }
 
+// UpdateAccountFolderCache 更新账户的文件夹分隔符和特殊用途文件夹缓存
+// specialFolders 为 nil 时清空缓存，下次连接时重新探测
+func (d *Database) UpdateAccountFolderCache(id uint, delimiter string, specialFolders map[string]string) error {
+	data := ""
+	if specialFolders != nil {
+		encoded, err := json.Marshal(specialFolders)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+
+	_, err := d.DB.Exec(`UPDATE email_accounts SET folder_delimiter = ?, special_folders = ? WHERE id = ?`,
+		delimiter, data, id)
+	return err
+}
+
+// encodeScanFolders 将额外扫描的文件夹列表编码为JSON，列表为空时返回空字符串
+func encodeScanFolders(folders []string) string {
+	if len(folders) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(folders)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
+
+// GetFolderState 获取账户某个文件夹的增量扫描状态，未扫描过时返回零值
+func (d *Database) GetFolderState(accountID uint, folder string) (uidValidity, lastUID uint32, err error) {
+	err = d.DB.QueryRow(`SELECT uid_validity, last_uid FROM folder_states WHERE email_id = ? AND folder = ?`,
+		accountID, folder).Scan(&uidValidity, &lastUID)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return uidValidity, lastUID, err
+}
+
+// SaveFolderState 保存账户某个文件夹已处理到的UID
+func (d *Database) SaveFolderState(accountID uint, folder string, uidValidity, lastUID uint32) error {
+	_, err := d.DB.Exec(`INSERT INTO folder_states (email_id, folder, uid_validity, last_uid, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(email_id, folder) DO UPDATE SET uid_validity = excluded.uid_validity,
+			last_uid = excluded.last_uid, updated_at = excluded.updated_at`,
+		accountID, folder, uidValidity, lastUID, time.Now())
+	return err
+}
+
+// ResetAccountSyncState 清除账户所有文件夹的增量扫描状态和历史导入标记，
+// 之后的检查从头扫描额外文件夹并重新导入首次检查的时间范围，已处理过的邮件仍会跳过
+func (d *Database) ResetAccountSyncState(accountID uint) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM folder_states WHERE email_id = ?`, accountID); err != nil {
+			return fmt.Errorf("清除文件夹扫描状态失败: %v", err)
+		}
+		if _, err := tx.Exec(`UPDATE email_accounts SET historical_import_done = 0, updated_at = ? WHERE id = ?`,
+			time.Now(), accountID); err != nil {
+			return fmt.Errorf("清除历史导入标记失败: %v", err)
+		}
+		return nil
+	})
+}
+
+// SetAccountPermissions 记录连接时探测到的账户权限
+func (d *Database) SetAccountPermissions(id uint, canModifyFlags, canMoveMessages bool) error {
+	_, err := d.DB.Exec(`UPDATE email_accounts SET can_modify_flags = ?, can_move_messages = ? WHERE id = ?`,
+		canModifyFlags, canMoveMessages, id)
+	return err
+}
+
+// SetCertFingerprint 保存账户信任的服务器证书指纹，同时清除待确认的指纹，指纹为空表示下次连接时重新记录
+func (d *Database) SetCertFingerprint(id uint, fingerprint string) error {
+	result, err := d.DB.Exec(`UPDATE email_accounts SET cert_fingerprint = ?, pending_cert_fingerprint = '', updated_at = ? WHERE id = ?`,
+		fingerprint, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("邮箱账户不存在: %d", id)
+	}
+	return nil
+}
+
+// SetOAuthRefreshToken 保存令牌端点轮换后返回的新刷新令牌
+func (d *Database) SetOAuthRefreshToken(id uint, refreshToken string) error {
+	_, err := d.DB.Exec(`UPDATE email_accounts SET oauth_refresh_token = ?, updated_at = ? WHERE id = ?`, refreshToken, time.Now(), id)
+	return err
+}
+
+// SetPendingCertFingerprint 记录与已保存指纹不一致的新证书指纹，等待用户确认
+func (d *Database) SetPendingCertFingerprint(id uint, fingerprint string) error {
+	_, err := d.DB.Exec(`UPDATE email_accounts SET pending_cert_fingerprint = ? WHERE id = ?`, fingerprint, id)
+	return err
+}
+
+// SetAccountMonitoringPaused 设置账户是否暂停后台监控
+func (d *Database) SetAccountMonitoringPaused(id uint, paused bool) error {
+	result, err := d.DB.Exec(`UPDATE email_accounts SET monitoring_paused = ?, updated_at = ? WHERE id = ?`,
+		paused, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("邮箱账户不存在: %d", id)
+	}
+	return nil
+}
+
+// SetAccountValidationResult 保存账户连接验证结果，验证失败时同时停用账户
+func (d *Database) SetAccountValidationResult(id uint, isActive bool, lastError string) error {
+	result, err := d.DB.Exec(`UPDATE email_accounts SET is_active = ?, last_error = ?, updated_at = ? WHERE id = ?`,
+		isActive, lastError, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("邮箱账户不存在: %d", id)
+	}
+	return nil
+}
+
+// SetAccountTestResult 保存手动测试连接的结果和测试时间，成功时 lastError 和 category 为空
+func (d *Database) SetAccountTestResult(id uint, lastError, category string) error {
+	now := time.Now()
+	result, err := d.DB.Exec(`UPDATE email_accounts SET last_error = ?, last_error_category = ?, last_checked_at = ?, updated_at = ? WHERE id = ?`,
+		lastError, category, now, now, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("邮箱账户不存在: %d", id)
+	}
+	return nil
+}
+
+// SetHistoricalImportDone 记录账户已完成历史邮件导入
+func (d *Database) SetHistoricalImportDone(id uint, done bool) error {
+	_, err := d.DB.Exec(`UPDATE email_accounts SET historical_import_done = ? WHERE id = ?`, done, id)
+	return err
+}
+
+// GetDeferredMessageUIDs 获取账户上次检查因任务数限制而推迟处理的邮件UID
+func (d *Database) GetDeferredMessageUIDs(accountID uint) ([]uint32, error) {
+	var data sql.NullString
+	err := d.DB.QueryRow(`SELECT deferred_uids FROM email_accounts WHERE id = ?`, accountID).Scan(&data)
+	if err != nil {
+		return nil, err
+	}
+	if data.String == "" {
+		return nil, nil
+	}
+
+	var uids []uint32
+	if err := json.Unmarshal([]byte(data.String), &uids); err != nil {
+		return nil, fmt.Errorf("解析推迟邮件列表失败: %v", err)
+	}
+	return uids, nil
+}
+
+// SetDeferredMessageUIDs 保存账户推迟处理的邮件UID，列表为空时清除
+func (d *Database) SetDeferredMessageUIDs(accountID uint, uids []uint32) error {
+	data := ""
+	if len(uids) > 0 {
+		encoded, err := json.Marshal(uids)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+
+	_, err := d.DB.Exec(`UPDATE email_accounts SET deferred_uids = ? WHERE id = ?`, data, accountID)
+	return err
+}
+
 // DeleteEmailAccount 删除邮箱账户
 func (d *Database) DeleteEmailAccount(id uint) error {
 	tx, err := d.DB.Begin()
@@ -428,25 +1056,95 @@ func (d *Database) DeleteEmailAccount(id uint) error {
 		}
 	}()
 
+	if err = deleteEmailAccountTx(tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// deleteEmailAccountTx 在事务中删除邮箱账户及其下载任务和邮件记录
+func deleteEmailAccountTx(tx *sql.Tx, id uint) error {
 	// 删除相关的下载任务
-	_, err = tx.Exec("DELETE FROM download_tasks WHERE email_id = ?", id)
-	if err != nil {
+	if _, err := tx.Exec("DELETE FROM download_tasks WHERE email_id = ?", id); err != nil {
 		return err
 	}
 
 	// 删除相关的邮件消息
-	_, err = tx.Exec("DELETE FROM email_messages WHERE email_id = ?", id)
-	if err != nil {
+	if _, err := tx.Exec("DELETE FROM email_messages WHERE email_id = ?", id); err != nil {
 		return err
 	}
 
 	// 删除邮箱账户
-	_, err = tx.Exec("DELETE FROM email_accounts WHERE id = ?", id)
+	_, err := tx.Exec("DELETE FROM email_accounts WHERE id = ?", id)
+	return err
+}
+
+// BulkSetAccountsActive 在同一事务中批量启用或停用邮箱账户，返回每个账户的处理结果
+func (d *Database) BulkSetAccountsActive(ids []uint, active bool) ([]models.BulkAccountResult, error) {
+	return d.bulkAccountOperation(ids, func(tx *sql.Tx, id uint) error {
+		result, err := tx.Exec(`UPDATE email_accounts SET is_active = ?, updated_at = ? WHERE id = ?`,
+			active, time.Now(), id)
+		if err != nil {
+			return err
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("邮箱账户不存在: %d", id)
+		}
+		return nil
+	})
+}
+
+// BulkDeleteEmailAccounts 在同一事务中批量删除邮箱账户及其下载任务和邮件记录，返回每个账户的处理结果
+func (d *Database) BulkDeleteEmailAccounts(ids []uint) ([]models.BulkAccountResult, error) {
+	return d.bulkAccountOperation(ids, func(tx *sql.Tx, id uint) error {
+		var count int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM email_accounts WHERE id = ?", id).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("邮箱账户不存在: %d", id)
+		}
+		return deleteEmailAccountTx(tx, id)
+	})
+}
+
+// bulkAccountOperation 在同一事务中逐个处理账户，每个账户使用独立的保存点
+// 单个账户失败时只回滚该账户的修改并记录错误，不影响其他账户；重复的ID只处理一次
+func (d *Database) bulkAccountOperation(ids []uint, fn func(*sql.Tx, uint) error) ([]models.BulkAccountResult, error) {
+	results := make([]models.BulkAccountResult, 0, len(ids))
+	seen := make(map[uint]bool, len(ids))
+
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if _, err := tx.Exec("SAVEPOINT bulk_account"); err != nil {
+				return err
+			}
+
+			result := models.BulkAccountResult{ID: id, Success: true}
+			if err := fn(tx, id); err != nil {
+				if _, rollbackErr := tx.Exec("ROLLBACK TO bulk_account"); rollbackErr != nil {
+					return rollbackErr
+				}
+				result.Success, result.Error = false, err.Error()
+			}
+
+			if _, err := tx.Exec("RELEASE bulk_account"); err != nil {
+				return err
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return tx.Commit()
+	return results, nil
 }
 
 // 数据库桶名称
@@ -474,15 +1172,28 @@ func (d *Database) CreateDownloadTask(task *models.DownloadTask) error {
 	query := `
 		INSERT INTO download_tasks (
 			email_id, subject, sender, file_name, file_size, downloaded_size,
-			status, type, source, local_path, error, progress, speed, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			status, type, source, local_path, error, progress, speed, folder, email_date, message_id, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
+
+	var emailDate interface{}
+	if task.EmailDate != "" {
+		if t, parseErr := models.StringToTime(task.EmailDate); parseErr == nil {
+			emailDate = t
+		}
+	}
+
+	// 0 表示未关联邮件记录，保存为NULL以满足外键约束
+	var messageID interface{}
+	if task.MessageID != 0 {
+		messageID = task.MessageID
+	}
 	
 	result, err := tx.Exec(query,
 		task.EmailID, task.Subject, task.Sender, task.FileName,
 		task.FileSize, task.DownloadedSize, task.Status, task.Type,
-		task.Source, task.LocalPath, task.Error, task.Progress,
-		task.Speed, now, now,
+		task.Source, d.storedTaskPath(task.LocalPath), task.Error, task.Progress,
+		task.Speed, task.Folder, emailDate, messageID, now, now,
 	)
 	if err != nil {
 		return err
@@ -515,31 +1226,377 @@ func (d *Database) GetDownloadTasks(limit, offset int) ([]models.DownloadTask, i
 	}
 
 	// 获取任务列表，统一查询逻辑
-	tasks, err := d.queryDownloadTasksWithJoin(`
-		SELECT dt.id, dt.email_id, dt.subject, dt.sender, dt.file_name, dt.file_size,
-		dt.downloaded_size, dt.status, dt.type, dt.source, dt.local_path, dt.error,
-		dt.progress, dt.speed, dt.created_at, dt.updated_at,
-		ea.id, ea.name, ea.email, ea.password, ea.imap_server, ea.imap_port, 
-		ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
-		FROM download_tasks dt
-		LEFT JOIN email_accounts ea ON dt.email_id = ea.id
+	tasks, err := d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
 		ORDER BY dt.created_at DESC LIMIT ? OFFSET ?`, limit, offset)
 	
 	return tasks, total, err
 }
 
+// taskSortColumns 允许排序的字段与SQL表达式，排序字段只能取自这里，不拼接调用方传入的字符串
+var taskSortColumns = map[string]string{
+	models.TaskSortCreatedAt: "dt.created_at",
+	models.TaskSortUpdatedAt: "dt.updated_at",
+	models.TaskSortFileSize:  "dt.file_size",
+	models.TaskSortFileName:  "dt.file_name COLLATE NOCASE",
+	models.TaskSortStatus:    "dt.status",
+}
+
+// GetDownloadTasksFiltered 按状态和账户筛选下载任务并排序分页，返回筛选后的总数
+func (d *Database) GetDownloadTasksFiltered(filter models.DownloadTaskFilter, limit, offset int) ([]models.DownloadTask, int64, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Status != "" {
+		conditions = append(conditions, "dt.status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.AccountID != 0 {
+		conditions = append(conditions, "dt.email_id = ?")
+		args = append(args, filter.AccountID)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := d.DB.QueryRow("SELECT COUNT(*) FROM download_tasks dt"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	column, ok := taskSortColumns[filter.SortBy]
+	if !ok {
+		column = taskSortColumns[models.TaskSortCreatedAt]
+	}
+	direction := "DESC"
+	if strings.EqualFold(filter.SortOrder, "asc") {
+		direction = "ASC"
+	}
+
+	// 排序值相同时按ID排序，保证分页结果稳定
+	query := downloadTaskJoinQuery + where + fmt.Sprintf(" ORDER BY %s %s, dt.id %s LIMIT ? OFFSET ?", column, direction, direction)
+	tasks, err := d.queryDownloadTasksWithJoin(query, append(args, limit, offset)...)
+	return tasks, total, err
+}
+
 // GetDownloadTasksByStatus 根据状态获取下载任务
 func (d *Database) GetDownloadTasksByStatus(status models.DownloadStatus) ([]models.DownloadTask, error) {
-	return d.queryDownloadTasksWithJoin(`
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.status = ? ORDER BY dt.created_at DESC`, status)
+}
+
+// GetAllDownloadTasks 获取所有下载任务
+func (d *Database) GetAllDownloadTasks() ([]models.DownloadTask, error) {
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery + `
+		ORDER BY dt.created_at DESC`)
+}
+
+// GetDownloadTasksCreatedBetween 获取创建时间在 [from, to) 内的下载任务，按创建时间排序，零值表示不限制
+func (d *Database) GetDownloadTasksCreatedBetween(from, to time.Time) ([]models.DownloadTask, error) {
+	var conditions []string
+	var args []interface{}
+	if !from.IsZero() {
+		conditions = append(conditions, "dt.created_at >= ?")
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "dt.created_at < ?")
+		args = append(args, to)
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+where+` ORDER BY dt.created_at ASC, dt.id ASC`, args...)
+}
+
+// GetUnfinishedDownloadTasks 获取未完成（下载中或等待中）的任务，按创建时间排序
+func (d *Database) GetUnfinishedDownloadTasks() ([]models.DownloadTask, error) {
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery + `
+		WHERE dt.status IN ('downloading', 'pending')
+		ORDER BY dt.created_at ASC`)
+}
+
+// GetDownloadTaskByID 根据ID获取下载任务
+func (d *Database) GetDownloadTaskByID(id uint) (*models.DownloadTask, error) {
+	tasks, err := d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &tasks[0], nil
+}
+
+// UpdateTaskChecksum 保存下载任务的文件校验和
+func (d *Database) UpdateTaskChecksum(taskID uint, algorithm, checksum string) error {
+	_, err := d.DB.Exec(`UPDATE download_tasks SET checksum_algorithm = ?, checksum = ? WHERE id = ?`,
+		algorithm, checksum, taskID)
+	return err
+}
+
+// UpdateTaskHash 保存任务下载内容的SHA-256
+func (d *Database) UpdateTaskHash(taskID uint, hash string) error {
+	_, err := d.DB.Exec(`UPDATE download_tasks SET hash = ? WHERE id = ?`, hash, taskID)
+	return err
+}
+
+// GetTaskResumeValidator 获取链接任务续传时使用的 If-Range 校验值（ETag 或 Last-Modified）
+func (d *Database) GetTaskResumeValidator(taskID uint) (string, error) {
+	var validator sql.NullString
+	err := d.DB.QueryRow(`SELECT resume_validator FROM download_tasks WHERE id = ?`, taskID).Scan(&validator)
+	return validator.String, err
+}
+
+// UpdateTaskResumeValidator 更新链接任务续传时使用的 If-Range 校验值
+func (d *Database) UpdateTaskResumeValidator(taskID uint, validator string) error {
+	_, err := d.DB.Exec(`UPDATE download_tasks SET resume_validator = ? WHERE id = ?`, validator, taskID)
+	return err
+}
+
+// FindCompletedTaskByHash 查找内容哈希相同的已完成任务（本身不是重复项），不存在时返回 sql.ErrNoRows
+// 同一封邮件（按邮件记录区分）中的其他任务不算在内，由下载完成后的来源去重处理；
+// 主题和发件人相同的另一封邮件（如重新发送的同一张发票）照常去重
+func (d *Database) FindCompletedTaskByHash(task *models.DownloadTask, hash string) (*models.DownloadTask, error) {
+	tasks, err := d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.hash = ? AND dt.id != ? AND dt.status = 'completed' AND dt.duplicate_of = 0
+			AND (? = 0 OR dt.message_id IS NULL OR dt.message_id != ?)
+		ORDER BY dt.id ASC LIMIT 1`, hash, task.ID, task.MessageID, task.MessageID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &tasks[0], nil
+}
+
+// MarkTaskDuplicate 把任务记录为已完成任务 originalID 的重复项，本地路径指向已有文件
+func (d *Database) MarkTaskDuplicate(taskID, originalID uint, hash, localPath string) error {
+	_, err := d.DB.Exec(`UPDATE download_tasks SET hash = ?, duplicate_of = ?, local_path = ?, updated_at = ? WHERE id = ?`,
+		hash, originalID, d.storedTaskPath(localPath), time.Now(), taskID)
+	return err
+}
+
+// CountTasksUsingFile 统计本地路径指向该文件的任务数，去重后多个任务共用同一文件
+func (d *Database) CountTasksUsingFile(localPath string) (int, error) {
+	var count int
+	err := d.DB.QueryRow(`SELECT COUNT(*) FROM download_tasks WHERE local_path = ?`, d.storedTaskPath(localPath)).Scan(&count)
+	return count, err
+}
+
+// GetDownloadTaskBySource 根据账户、类型和源查找最近的下载任务，不存在时返回 sql.ErrNoRows
+func (d *Database) GetDownloadTaskBySource(emailID uint, taskType models.DownloadType, source string) (*models.DownloadTask, error) {
+	tasks, err := d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.email_id = ? AND dt.type = ? AND dt.source = ?
+		ORDER BY dt.id DESC LIMIT 1`, emailID, taskType, source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &tasks[0], nil
+}
+
+// GetMessageTasks 获取同一封邮件的全部下载任务，按创建顺序排列，不含合并生成的任务
+// 按任务记录的邮件ID匹配；没有邮件ID的旧任务按相同账户、主题和发件人匹配
+func (d *Database) GetMessageTasks(task *models.DownloadTask) ([]models.DownloadTask, error) {
+	if task.MessageID != 0 {
+		return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.message_id = ? AND dt.type != ?
+		ORDER BY dt.id ASC`,
+			task.MessageID, models.TypeMerged)
+	}
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.email_id = ? AND dt.subject = ? AND dt.sender = ? AND dt.type != ?
+		ORDER BY dt.id ASC`,
+		task.EmailID, task.Subject, task.Sender, models.TypeMerged)
+}
+
+// MarkTasksMerged 记录分卷任务已合并到任务 mergedID
+func (d *Database) MarkTasksMerged(taskIDs []uint, mergedID uint) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		for _, id := range taskIDs {
+			if _, err := tx.Exec(`UPDATE download_tasks SET merged_into = ?, updated_at = ? WHERE id = ?`,
+				mergedID, time.Now(), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetCompletedSiblingTasks 获取同一封邮件（相同账户、主题和发件人）中其他已完成的下载任务
+func (d *Database) GetCompletedSiblingTasks(task *models.DownloadTask) ([]models.DownloadTask, error) {
+	return d.queryDownloadTasksWithJoin(downloadTaskJoinQuery+`
+		WHERE dt.email_id = ? AND dt.subject = ? AND dt.sender = ? AND dt.id != ? AND dt.status = 'completed'`,
+		task.EmailID, task.Subject, task.Sender, task.ID)
+}
+
+// DeleteDownloadTask 删除下载任务记录（容器任务解压出的文件记录随之删除），正在下载的任务需要先取消
+func (d *Database) DeleteDownloadTask(id uint) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		var status models.DownloadStatus
+		err := tx.QueryRow(`SELECT status FROM download_tasks WHERE id = ?`, id).Scan(&status)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("任务 %d 不存在", id)
+		}
+		if err != nil {
+			return err
+		}
+		if status == models.StatusDownloading {
+			return fmt.Errorf("任务 %d 正在下载，请先取消后再删除", id)
+		}
+
+		_, err = tx.Exec(`DELETE FROM download_tasks WHERE id = ?`, id)
+		return err
+	})
+}
+
+// UpdateTaskFile 更新任务的文件名和保存路径
+func (d *Database) UpdateTaskFile(taskID uint, fileName, localPath string) error {
+	_, err := d.DB.Exec(`UPDATE download_tasks SET file_name = ?, local_path = ?, updated_at = ? WHERE id = ?`,
+		fileName, d.storedTaskPath(localPath), time.Now(), taskID)
+	return err
+}
+
+// ResetTaskForRetry 把失败或已取消的任务重置为等待状态、清除错误信息并累加重试次数
+// 任务不是这两种状态或重试次数已达到 maxRetries 时不修改，返回false
+// 失败的任务保留已下载的进度以便链接续传，取消的任务临时文件已删除，从头开始
+func (d *Database) ResetTaskForRetry(taskID uint, maxRetries int) (bool, error) {
+	result, err := d.DB.Exec(`UPDATE download_tasks
+		SET status = 'pending', error = '', speed = '', retry_count = retry_count + 1,
+			downloaded_size = CASE WHEN status = 'cancelled' THEN 0 ELSE downloaded_size END,
+			progress = CASE WHEN status = 'cancelled' THEN 0 ELSE progress END,
+			updated_at = ?
+		WHERE id = ? AND status IN ('failed', 'cancelled', 'expired') AND retry_count < ?`,
+		time.Now(), taskID, maxRetries)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// CancelActiveTasks 在同一事务中把所有等待中和下载中的任务标记为已取消
+// 返回被取消的任务（只包含ID、本地路径和去重来源），已结束的任务不受影响
+func (d *Database) CancelActiveTasks() ([]models.DownloadTask, error) {
+	var tasks []models.DownloadTask
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`UPDATE download_tasks
+			SET status = 'cancelled', error = '', downloaded_size = 0, progress = 0, speed = '', updated_at = ?
+			WHERE status IN ('pending', 'downloading')
+			RETURNING id, local_path, duplicate_of`, time.Now())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		tasks = nil
+		for rows.Next() {
+			var task models.DownloadTask
+			var localPath sql.NullString
+			var duplicateOf sql.NullInt64
+			if err := rows.Scan(&task.ID, &localPath, &duplicateOf); err != nil {
+				return err
+			}
+			task.LocalPath = d.resolveTaskPath(localPath.String)
+			task.DuplicateOf = uint(duplicateOf.Int64)
+			tasks = append(tasks, task)
+		}
+		return rows.Err()
+	})
+	return tasks, err
+}
+
+// FinishCancelledTask 写入工作者结束时的取消状态（用户取消或跳过重复的来源），errorMsg 为取消原因
+// 只在任务仍为已取消、下载中或本次下载写入的已完成时写入，之后被重新排队的任务保持原状态；返回是否写入
+func (d *Database) FinishCancelledTask(taskID uint, errorMsg string) (bool, error) {
+	result, err := d.DB.Exec(`UPDATE download_tasks
+		SET status = 'cancelled', error = ?, downloaded_size = 0, progress = 0, speed = '', updated_at = ?
+		WHERE id = ? AND status IN ('cancelled', 'downloading', 'completed')`,
+		errorMsg, time.Now(), taskID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ExpirePendingTasks 把排队时间早于 cutoff 的等待任务标记为已过期，返回处理的任务数
+// 排队时间与恢复任务时一致：重试过的任务从最近一次重新排队算起，否则从创建时间算起
+func (d *Database) ExpirePendingTasks(cutoff time.Time, reason string) (int64, error) {
+	result, err := d.DB.Exec(`UPDATE download_tasks
+		SET status = 'expired', error = ?, speed = '', updated_at = ?
+		WHERE status = 'pending'
+			AND (CASE WHEN retry_count > 0 THEN updated_at ELSE created_at END) < ?`,
+		reason, time.Now(), cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SavePartialFragment 保存分段邮件片段，重复收到的片段被忽略
+func (d *Database) SavePartialFragment(fragment *models.PartialFragment) error {
+	_, err := d.DB.Exec(`INSERT OR IGNORE INTO partial_fragments (email_id, partial_id, number, total, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		fragment.EmailID, fragment.PartialID, fragment.Number, fragment.Total, fragment.Content, time.Now())
+	return err
+}
+
+// GetPartialFragmentCount 获取已收到的片段数和已知的总片段数（尚未收到最后一段时为0）
+func (d *Database) GetPartialFragmentCount(emailID uint, partialID string) (int, int, error) {
+	var received, total int
+	err := d.DB.QueryRow(`SELECT COUNT(*), COALESCE(MAX(total), 0) FROM partial_fragments
+		WHERE email_id = ? AND partial_id = ?`, emailID, partialID).Scan(&received, &total)
+	return received, total, err
+}
+
+// GetPartialFragments 获取分段邮件的所有片段，按序号排序
+func (d *Database) GetPartialFragments(emailID uint, partialID string) ([]models.PartialFragment, error) {
+	rows, err := d.DB.Query(`SELECT id, email_id, partial_id, number, total, content FROM partial_fragments
+		WHERE email_id = ? AND partial_id = ? ORDER BY number ASC`, emailID, partialID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fragments []models.PartialFragment
+	for rows.Next() {
+		var fragment models.PartialFragment
+		if err := rows.Scan(&fragment.ID, &fragment.EmailID, &fragment.PartialID,
+			&fragment.Number, &fragment.Total, &fragment.Content); err != nil {
+			return nil, err
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments, rows.Err()
+}
+
+// DeletePartialFragments 删除分段邮件的所有片段（重组完成后调用）
+func (d *Database) DeletePartialFragments(emailID uint, partialID string) error {
+	_, err := d.DB.Exec(`DELETE FROM partial_fragments WHERE email_id = ? AND partial_id = ?`, emailID, partialID)
+	return err
+}
+
+// downloadTaskJoinQuery 下载任务及关联账户的查询，调用方追加 WHERE/ORDER BY 子句
+const downloadTaskJoinQuery = `
 		SELECT dt.id, dt.email_id, dt.subject, dt.sender, dt.file_name, dt.file_size,
 		dt.downloaded_size, dt.status, dt.type, dt.source, dt.local_path, dt.error,
-		dt.progress, dt.speed, dt.created_at, dt.updated_at,
+		dt.progress, dt.speed, dt.checksum, dt.checksum_algorithm, dt.folder, dt.is_container, dt.email_date, dt.retry_count, dt.message_id, dt.hash, dt.duplicate_of, dt.merged_into, dt.created_at, dt.updated_at,
 		ea.id, ea.name, ea.email, ea.password, ea.imap_server, ea.imap_port, 
 		ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
 		FROM download_tasks dt
-		LEFT JOIN email_accounts ea ON dt.email_id = ea.id
-		WHERE dt.status = ? ORDER BY dt.created_at DESC`, status)
-}
+		LEFT JOIN email_accounts ea ON dt.email_id = ea.id`
 
 // queryDownloadTasksWithJoin 统一的下载任务查询方法，消除重复代码
 func (d *Database) queryDownloadTasksWithJoin(query string, args ...interface{}) ([]models.DownloadTask, error) {
@@ -559,15 +1616,33 @@ func (d *Database) queryDownloadTasksWithJoin(query string, args ...interface{})
 		var accountName, accountEmail, accountPassword, accountIMAPServer sql.NullString
 		var accountIMAPPort sql.NullInt64
 		var accountUseSSL, accountIsActive sql.NullBool
+		var checksum, checksumAlgorithm, folder sql.NullString
+		var isContainer sql.NullBool
+		var emailDate sql.NullTime
+		var retryCount, messageID, duplicateOf, mergedInto sql.NullInt64
+		var hash sql.NullString
 		
 		if err := rows.Scan(&task.ID, &task.EmailID, &task.Subject, &task.Sender,
 			&task.FileName, &task.FileSize, &task.DownloadedSize, &task.Status,
 			&task.Type, &task.Source, &task.LocalPath, &task.Error,
-			&task.Progress, &task.Speed, &taskCreatedAt, &taskUpdatedAt,
+			&task.Progress, &task.Speed, &checksum, &checksumAlgorithm, &folder, &isContainer, &emailDate, &retryCount, &messageID, &hash, &duplicateOf, &mergedInto, &taskCreatedAt, &taskUpdatedAt,
 			&accountID, &accountName, &accountEmail, &accountPassword, &accountIMAPServer,
 			&accountIMAPPort, &accountUseSSL, &accountIsActive, &accountCreatedAt, &accountUpdatedAt); err != nil {
 			return nil, err
 		}
+		task.Checksum = checksum.String
+		task.ChecksumAlgorithm = checksumAlgorithm.String
+		task.Folder = folder.String
+		task.IsContainer = isContainer.Bool
+		task.RetryCount = int(retryCount.Int64)
+		task.MessageID = uint(messageID.Int64)
+		task.Hash = hash.String
+		task.DuplicateOf = uint(duplicateOf.Int64)
+		task.MergedInto = uint(mergedInto.Int64)
+		if emailDate.Valid {
+			task.EmailDate = models.TimeToString(emailDate.Time)
+		}
+		task.LocalPath = d.resolveTaskPath(task.LocalPath)
 		
 		// 转换时间 - 处理NULL值
 		if taskCreatedAt.Valid {
@@ -645,14 +1720,14 @@ func (d *Database) CreateEmailMessage(message *models.EmailMessage) error {
 	query := `
 		INSERT INTO email_messages (
 			email_id, message_id, subject, sender, recipients, date,
-			has_pdf, is_processed, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			has_pdf, is_processed, gm_msgid, gm_thrid, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	result, err := tx.Exec(query,
 		message.EmailID, message.MessageID, message.Subject, message.Sender,
 		message.Recipients, message.Date, message.HasPDF, message.IsProcessed,
-		now, now,
+		message.GmailMessageID, message.GmailThreadID, now, now,
 	)
 	if err != nil {
 		return err
@@ -672,25 +1747,19 @@ func (d *Database) CreateEmailMessage(message *models.EmailMessage) error {
 
 // GetEmailMessageByMessageID 根据消息ID获取邮件记录
 func (d *Database) GetEmailMessageByMessageID(messageID string) (*models.EmailMessage, error) {
-	message := &models.EmailMessage{}
-	var createdAt, updatedAt time.Time
+	return scanEmailMessage(d.DB.QueryRow(`SELECT `+emailMessageColumns+` FROM email_messages WHERE message_id = ?`, messageID))
+}
 
+// HasGmailMessage 检查账户下是否已记录指定 X-GM-MSGID 的邮件
+func (d *Database) HasGmailMessage(accountID uint, gmailMessageID string) (bool, error) {
+	var count int
 	err := d.DB.QueryRow(`
-		SELECT id, email_id, message_id, subject, sender, recipients, date,
-		has_pdf, is_processed, created_at, updated_at 
-		FROM email_messages WHERE message_id = ?`, messageID).Scan(
-		&message.ID, &message.EmailID, &message.MessageID, &message.Subject,
-		&message.Sender, &message.Recipients, &message.Date, &message.HasPDF,
-		&message.IsProcessed, &createdAt, &updatedAt)
-	
+		SELECT COUNT(*) FROM email_messages WHERE email_id = ? AND gm_msgid = ?`,
+		accountID, gmailMessageID).Scan(&count)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	
-	message.CreatedAt = models.TimeToString(createdAt)
-	message.UpdatedAt = models.TimeToString(updatedAt)
-	
-	return message, nil
+	return count > 0, nil
 }
 
 // UpdateEmailMessage 更新邮件记录
@@ -726,7 +1795,8 @@ func (d *Database) UpdateEmailMessage(message *models.EmailMessage) error {
 }
 
 // CreateOrUpdateStatistics 创建或更新统计数据
-func (d *Database) CreateOrUpdateStatistics(date time.Time, totalDownloads, successDownloads, failedDownloads int, totalSize int64) error {
+// dedupedDownloads、dedupedSize 为去重后没有重复保存的文件数和大小
+func (d *Database) CreateOrUpdateStatistics(date time.Time, totalDownloads, successDownloads, failedDownloads int, totalSize int64, dedupedDownloads int, dedupedSize int64) error {
 	tx, err := d.DB.Begin()
 	if err != nil {
 		return err
@@ -742,11 +1812,13 @@ func (d *Database) CreateOrUpdateStatistics(date time.Time, totalDownloads, succ
 	
 	query := `
 		INSERT OR REPLACE INTO download_statistics 
-		(date, total_downloads, success_downloads, failed_downloads, total_size, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		(date, total_downloads, success_downloads, failed_downloads, total_size,
+		deduplicated_downloads, deduplicated_size, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
-	_, err = tx.Exec(query, dateStr, totalDownloads, successDownloads, failedDownloads, totalSize, now, now)
+	_, err = tx.Exec(query, dateStr, totalDownloads, successDownloads, failedDownloads, totalSize,
+		dedupedDownloads, dedupedSize, now, now)
 	if err != nil {
 		return err
 	}
@@ -754,11 +1826,41 @@ func (d *Database) CreateOrUpdateStatistics(date time.Time, totalDownloads, succ
 	return tx.Commit()
 }
 
+// RecordDailyStatistics 汇总指定日期的下载任务（完成或失败）并写入统计表
+// byReceivedDate 为 true 时按邮件接收日期归档，没有接收日期的任务仍按完成日期归档
+func (d *Database) RecordDailyStatistics(date time.Time, byReceivedDate bool) error {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	end := start.AddDate(0, 0, 1)
+
+	dateColumn := "updated_at"
+	if byReceivedDate {
+		dateColumn = "COALESCE(email_date, updated_at)"
+	}
+
+	var success, failed, deduped int
+	var totalSize, dedupedSize int64
+	err := d.DB.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN file_size ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND duplicate_of > 0 THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? AND duplicate_of > 0 THEN file_size ELSE 0 END), 0)
+		FROM download_tasks WHERE `+dateColumn+` >= ? AND `+dateColumn+` < ?`,
+		models.StatusCompleted, models.StatusFailed, models.StatusCompleted,
+		models.StatusCompleted, models.StatusCompleted, start, end,
+	).Scan(&success, &failed, &totalSize, &deduped, &dedupedSize)
+	if err != nil {
+		return fmt.Errorf("汇总下载统计失败: %v", err)
+	}
+
+	return d.CreateOrUpdateStatistics(start, success+failed, success, failed, totalSize, deduped, dedupedSize)
+}
+
 // GetStatistics 获取统计数据
 func (d *Database) GetStatistics(days int) ([]models.DownloadStatistics, error) {
 	rows, err := d.DB.Query(`
 		SELECT id, date, total_downloads, success_downloads, failed_downloads, total_size,
-		created_at, updated_at FROM download_statistics 
+		deduplicated_downloads, deduplicated_size, created_at, updated_at FROM download_statistics 
 		WHERE date >= DATE('now', '-' || ? || ' days')
 		ORDER BY date DESC`, days)
 	
@@ -775,6 +1877,7 @@ func (d *Database) GetStatistics(days int) ([]models.DownloadStatistics, error)
 		
 		if err := rows.Scan(&stat.ID, &dateStr, &stat.TotalDownloads,
 			&stat.SuccessDownloads, &stat.FailedDownloads, &stat.TotalSize,
+			&stat.DeduplicatedDownloads, &stat.DeduplicatedSize,
 			&createdAt, &updatedAt); err != nil {
 			return nil, err
 		}
@@ -801,7 +1904,7 @@ func (d *Database) CleanOldData(days int) error {
 	// 清理旧的下载任务
 	if _, err := tx.Exec(`
 		DELETE FROM download_tasks 
-		WHERE status IN ('completed', 'failed', 'cancelled') 
+		WHERE status IN ('completed', 'failed', 'cancelled', 'expired') 
 		AND created_at < DATE('now', '-' || ? || ' days')`, days); err != nil {
 		return err
 	}
@@ -825,22 +1928,67 @@ func (d *Database) CleanOldData(days int) error {
 
 // GetConfig 获取应用配置
 func (d *Database) GetConfig() (models.AppConfig, error) {
-	query := `SELECT id, download_path, max_concurrent, check_interval, auto_check, minimize_to_tray, start_minimized, enable_notification, theme, language, created_at, updated_at FROM app_configs LIMIT 1`
+	query := `SELECT id, download_path, max_concurrent, check_interval, auto_check, minimize_to_tray, start_minimized, enable_notification, theme, language, max_tasks_per_check, telemetry_enabled, telemetry_endpoint, path_template, checksum_algorithm, download_window_start, download_window_end, download_deadline_minutes, check_scope, check_scope_days, duplicate_source_preference, auto_open_file, auto_open_folder, quarantine_invalid_files, fetch_batch_size, cert_pinning_mode, relative_paths, max_redirects, quick_retry_attempts, account_check_timeout_minutes, extract_zip_attachments, allowed_extensions, statistics_date_source, completion_sound, max_task_retries, task_retry_backoff_seconds, organize_by, recovery_delay_seconds, recovery_tasks_per_minute, recovery_max_age_hours, scan_window_days, initial_scan_days, proxy_url, strict_pdf_validation, pending_expiry_hours, deduplicate_downloads, webhook_url, webhook_secret, max_file_size_mb, sender_allowlist, sender_blocklist, index_pdf_metadata, pdf_thumbnails, merge_pdf_pattern, mark_as_read, max_imap_connections, min_free_memory_mb, quiet_hours_start, quiet_hours_end, created_at, updated_at FROM app_configs LIMIT 1`
 	
 	row := d.DB.QueryRow(query)
 	
 	var config models.AppConfig
 	var createdAt, updatedAt time.Time
+	var allowedExtensions sql.NullString
 	err := row.Scan(
 		&config.ID, &config.DownloadPath, &config.MaxConcurrent, &config.CheckInterval,
 		&config.AutoCheck, &config.MinimizeToTray, &config.StartMinimized,
 		&config.EnableNotification, &config.Theme, &config.Language,
+		&config.MaxTasksPerCheck, &config.TelemetryEnabled, &config.TelemetryEndpoint,
+		&config.PathTemplate, &config.ChecksumAlgorithm,
+		&config.DownloadWindowStart, &config.DownloadWindowEnd, &config.DownloadDeadlineMinutes,
+		&config.CheckScope, &config.CheckScopeDays, &config.DuplicateSourcePreference,
+		&config.AutoOpenFile,
+		&config.AutoOpenFolder,
+		&config.QuarantineInvalidFiles,
+		&config.FetchBatchSize,
+		&config.CertPinningMode,
+		&config.RelativePaths,
+		&config.MaxRedirects,
+		&config.QuickRetryAttempts,
+		&config.AccountCheckTimeoutMinutes,
+		&config.ExtractZipAttachments,
+		&allowedExtensions,
+		&config.StatisticsDateSource,
+		&config.CompletionSound,
+		&config.MaxTaskRetries,
+		&config.TaskRetryBackoffSeconds,
+		&config.OrganizeBy,
+		&config.RecoveryDelaySeconds,
+		&config.RecoveryTasksPerMinute,
+		&config.RecoveryMaxAgeHours,
+		&config.ScanWindowDays,
+		&config.InitialScanDays,
+		&config.ProxyURL,
+		&config.StrictPDFValidation,
+		&config.PendingExpiryHours,
+		&config.DeduplicateDownloads,
+		&config.WebhookURL,
+		&config.WebhookSecret,
+		&config.MaxFileSizeMB,
+		&config.SenderAllowlist,
+		&config.SenderBlocklist,
+		&config.IndexPDFMetadata,
+		&config.PDFThumbnails,
+		&config.MergePDFPattern,
+		&config.MarkAsRead,
+		&config.MaxIMAPConnections,
+		&config.MinFreeMemoryMB,
+		&config.QuietHoursStart,
+		&config.QuietHoursEnd,
 		&createdAt, &updatedAt,
 	)
 	if err != nil {
 		return config, err
 	}
 	
+	config.AllowedExtensions = models.NormalizeExtensions(strings.Split(allowedExtensions.String, ","))
+
 	config.CreatedAt = models.TimeToString(createdAt)
 	config.UpdatedAt = models.TimeToString(updatedAt)
 	
@@ -864,20 +2012,40 @@ func (d *Database) CreateConfig(config models.AppConfig) error {
 		INSERT INTO app_configs (
 			download_path, max_concurrent, check_interval, auto_check,
 			minimize_to_tray, start_minimized, enable_notification,
-			theme, language, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			theme, language, max_tasks_per_check, telemetry_enabled, telemetry_endpoint,
+			path_template, checksum_algorithm, download_window_start, download_window_end,
+			download_deadline_minutes, check_scope, check_scope_days, duplicate_source_preference,
+			auto_open_file, auto_open_folder, quarantine_invalid_files, fetch_batch_size, cert_pinning_mode,
+			relative_paths, max_redirects, quick_retry_attempts, account_check_timeout_minutes,
+			extract_zip_attachments, allowed_extensions, statistics_date_source, completion_sound,
+			max_task_retries, task_retry_backoff_seconds, organize_by, recovery_delay_seconds,
+			recovery_tasks_per_minute, recovery_max_age_hours, scan_window_days, initial_scan_days,
+			proxy_url, strict_pdf_validation, pending_expiry_hours, deduplicate_downloads, webhook_url,
+			webhook_secret, max_file_size_mb, sender_allowlist, sender_blocklist, index_pdf_metadata,
+			pdf_thumbnails, merge_pdf_pattern, mark_as_read, max_imap_connections, min_free_memory_mb,
+			quiet_hours_start, quiet_hours_end, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	_, err = tx.Exec(query,
 		config.DownloadPath, config.MaxConcurrent, config.CheckInterval,
 		config.AutoCheck, config.MinimizeToTray, config.StartMinimized,
-		config.EnableNotification, config.Theme, config.Language, now, now,
+		config.EnableNotification, config.Theme, config.Language,
+		config.MaxTasksPerCheck, config.TelemetryEnabled, config.TelemetryEndpoint,
+		config.PathTemplate, config.ChecksumAlgorithm,
+		config.DownloadWindowStart, config.DownloadWindowEnd, config.DownloadDeadlineMinutes,
+		config.CheckScope, config.CheckScopeDays, config.DuplicateSourcePreference, config.AutoOpenFile, config.AutoOpenFolder, config.QuarantineInvalidFiles, config.FetchBatchSize, config.CertPinningMode, config.RelativePaths, config.MaxRedirects, config.QuickRetryAttempts, config.AccountCheckTimeoutMinutes, config.ExtractZipAttachments, strings.Join(models.NormalizeExtensions(config.AllowedExtensions), ","), config.StatisticsDateSource, config.CompletionSound, config.MaxTaskRetries, config.TaskRetryBackoffSeconds, config.OrganizeBy, config.RecoveryDelaySeconds, config.RecoveryTasksPerMinute, config.RecoveryMaxAgeHours, config.ScanWindowDays, config.InitialScanDays, config.ProxyURL, config.StrictPDFValidation, config.PendingExpiryHours, config.DeduplicateDownloads, config.WebhookURL, config.WebhookSecret, config.MaxFileSizeMB, config.SenderAllowlist, config.SenderBlocklist, config.IndexPDFMetadata, config.PDFThumbnails, config.MergePDFPattern, config.MarkAsRead, config.MaxIMAPConnections, config.MinFreeMemoryMB, config.QuietHoursStart, config.QuietHoursEnd, now, now,
 	)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	d.setPathSettings(&config)
+	return nil
 }
 
 // UpdateConfig 更新应用配置
@@ -897,19 +2065,87 @@ func (d *Database) UpdateConfig(config *models.AppConfig) error {
 		UPDATE app_configs 
 		SET download_path = ?, max_concurrent = ?, check_interval = ?, auto_check = ?, 
 			minimize_to_tray = ?, start_minimized = ?, enable_notification = ?, 
-			theme = ?, language = ?, updated_at = ?
+			theme = ?, language = ?, max_tasks_per_check = ?,
+			telemetry_enabled = ?, telemetry_endpoint = ?, path_template = ?,
+			checksum_algorithm = ?, download_window_start = ?, download_window_end = ?,
+			download_deadline_minutes = ?, check_scope = ?, check_scope_days = ?, duplicate_source_preference = ?,
+			auto_open_file = ?, auto_open_folder = ?, quarantine_invalid_files = ?, fetch_batch_size = ?,
+			cert_pinning_mode = ?, relative_paths = ?, max_redirects = ?, quick_retry_attempts = ?,
+			account_check_timeout_minutes = ?, extract_zip_attachments = ?, allowed_extensions = ?,
+			statistics_date_source = ?, completion_sound = ?, max_task_retries = ?, task_retry_backoff_seconds = ?,
+			organize_by = ?, recovery_delay_seconds = ?, recovery_tasks_per_minute = ?, recovery_max_age_hours = ?,
+			scan_window_days = ?, initial_scan_days = ?, proxy_url = ?, strict_pdf_validation = ?,
+			pending_expiry_hours = ?, deduplicate_downloads = ?, webhook_url = ?, webhook_secret = ?,
+			max_file_size_mb = ?, sender_allowlist = ?, sender_blocklist = ?, index_pdf_metadata = ?,
+			pdf_thumbnails = ?, merge_pdf_pattern = ?, mark_as_read = ?, max_imap_connections = ?,
+			min_free_memory_mb = ?, quiet_hours_start = ?, quiet_hours_end = ?, updated_at = ?
 		WHERE id = ?
 	`
 	
 	_, err = tx.Exec(query,
 		config.DownloadPath, config.MaxConcurrent, config.CheckInterval,
 		config.AutoCheck, config.MinimizeToTray, config.StartMinimized,
-		config.EnableNotification, config.Theme, config.Language, now, config.ID,
+		config.EnableNotification, config.Theme, config.Language,
+		config.MaxTasksPerCheck, config.TelemetryEnabled, config.TelemetryEndpoint,
+		config.PathTemplate, config.ChecksumAlgorithm,
+		config.DownloadWindowStart, config.DownloadWindowEnd, config.DownloadDeadlineMinutes,
+		config.CheckScope, config.CheckScopeDays, config.DuplicateSourcePreference, config.AutoOpenFile, config.AutoOpenFolder, config.QuarantineInvalidFiles, config.FetchBatchSize, config.CertPinningMode, config.RelativePaths, config.MaxRedirects, config.QuickRetryAttempts, config.AccountCheckTimeoutMinutes, config.ExtractZipAttachments, strings.Join(models.NormalizeExtensions(config.AllowedExtensions), ","), config.StatisticsDateSource, config.CompletionSound, config.MaxTaskRetries, config.TaskRetryBackoffSeconds, config.OrganizeBy, config.RecoveryDelaySeconds, config.RecoveryTasksPerMinute, config.RecoveryMaxAgeHours, config.ScanWindowDays, config.InitialScanDays, config.ProxyURL, config.StrictPDFValidation, config.PendingExpiryHours, config.DeduplicateDownloads, config.WebhookURL, config.WebhookSecret, config.MaxFileSizeMB, config.SenderAllowlist, config.SenderBlocklist, config.IndexPDFMetadata, config.PDFThumbnails, config.MergePDFPattern, config.MarkAsRead, config.MaxIMAPConnections, config.MinFreeMemoryMB, config.QuietHoursStart, config.QuietHoursEnd, now, config.ID,
 	)
 	if err != nil {
 		return err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
 	config.UpdatedAt = models.TimeToString(now)
-	return tx.Commit()
+	d.setPathSettings(config)
+	return nil
 } 
+
+// AddTelemetryEvent 缓存一条遥测事件，超过 maxEvents 时丢弃最旧的事件
+func (d *Database) AddTelemetryEvent(event *models.TelemetryEvent, maxEvents int) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO telemetry_events (source, category, domain, date) VALUES (?, ?, ?, ?)`,
+			event.Source, event.Category, event.Domain, event.Date)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`DELETE FROM telemetry_events WHERE id NOT IN (
+			SELECT id FROM telemetry_events ORDER BY id DESC LIMIT ?)`, maxEvents)
+		return err
+	})
+}
+
+// GetTelemetryEvents 按时间顺序获取缓存的遥测事件
+func (d *Database) GetTelemetryEvents(limit int) ([]models.TelemetryEvent, error) {
+	rows, err := d.DB.Query(`SELECT id, source, category, domain, date FROM telemetry_events ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.TelemetryEvent
+	for rows.Next() {
+		var event models.TelemetryEvent
+		if err := rows.Scan(&event.ID, &event.Source, &event.Category, &event.Domain, &event.Date); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// DeleteTelemetryEvents 删除ID不大于 maxID 的已上报事件
+func (d *Database) DeleteTelemetryEvents(maxID uint) error {
+	_, err := d.DB.Exec(`DELETE FROM telemetry_events WHERE id <= ?`, maxID)
+	return err
+}
+
+// ClearTelemetryEvents 清空所有缓存的遥测事件
+func (d *Database) ClearTelemetryEvents() error {
+	_, err := d.DB.Exec(`DELETE FROM telemetry_events`)
+	return err
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"time"
+
+	"emaild/backend/models"
+)
+
+// refreshExtractionQuery 按邮件关联的任务重新统计提取结果，WHERE 条件由调用方拼接
+// 失败数包括失败、过期的任务和未能创建任务的来源；仍有未结束的任务时为 pending
+// 分卷合并生成的任务不是邮件中的来源，不计入成功数
+const refreshExtractionQuery = `
+	UPDATE email_messages SET
+		pdf_downloaded = (SELECT COUNT(*) FROM download_tasks dt
+			WHERE dt.message_id = email_messages.id AND dt.status = 'completed' AND dt.type != '` + string(models.TypeMerged) + `'),
+		pdf_failed = (SELECT COUNT(*) FROM download_tasks dt
+			WHERE dt.message_id = email_messages.id AND dt.status IN ('failed', 'expired'))
+			+ MAX(pdf_total - tasks_created, 0),
+		updated_at = ?
+	WHERE `
+
+// extractionStatusQuery 根据统计结果更新提取状态，WHERE 条件与 refreshExtractionQuery 相同
+const extractionStatusQuery = `
+	UPDATE email_messages SET extraction_status = CASE
+		WHEN pdf_total = 0 THEN ''
+		WHEN EXISTS (SELECT 1 FROM download_tasks dt WHERE dt.message_id = email_messages.id
+			AND dt.status IN ('pending', 'downloading', 'paused', 'incomplete')) THEN '` + models.ExtractionPending + `'
+		WHEN pdf_failed = 0 THEN '` + models.ExtractionComplete + `'
+		WHEN pdf_downloaded > 0 THEN '` + models.ExtractionPartial + `'
+		ELSE '` + models.ExtractionFailed + `'
+	END
+	WHERE `
+
+// refreshMessageExtraction 重新统计满足条件的邮件的提取结果
+func (d *Database) refreshMessageExtraction(where string, args ...interface{}) error {
+	if _, err := d.DB.Exec(refreshExtractionQuery+where, append([]interface{}{time.Now()}, args...)...); err != nil {
+		return err
+	}
+	_, err := d.DB.Exec(extractionStatusQuery+where, args...)
+	return err
+}
+
+// SetMessageExtraction 记录处理邮件时发现的文件来源数和成功创建的任务数，并统计提取结果
+func (d *Database) SetMessageExtraction(messageID uint, total, created int) error {
+	if _, err := d.DB.Exec(`UPDATE email_messages SET pdf_total = ?, tasks_created = ? WHERE id = ?`,
+		total, created, messageID); err != nil {
+		return err
+	}
+	return d.refreshMessageExtraction(`id = ?`, messageID)
+}
+
+// RefreshTaskMessageExtraction 任务状态变化后重新统计所属邮件的提取结果，未关联邮件的任务忽略
+func (d *Database) RefreshTaskMessageExtraction(taskID uint) error {
+	return d.refreshMessageExtraction(`id = (SELECT message_id FROM download_tasks WHERE id = ?)`, taskID)
+}
+
+// RefreshPendingMessageExtractions 重新统计所有仍在提取中的邮件，批量修改任务状态后使用
+func (d *Database) RefreshPendingMessageExtractions() error {
+	return d.refreshMessageExtraction(`extraction_status = ?`, models.ExtractionPending)
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// minFTSQueryLength trigram 分词按三个字符建立索引，更短的关键词无法通过全文索引匹配
+const minFTSQueryLength = 3
+
+// messageSearchSchema 邮件主题、发件人、收件人的全文索引
+// 使用外部内容表，不重复保存邮件数据；触发器在插入、更新、删除邮件时同步索引，
+// 因此 CreateEmailMessage、UpdateEmailMessage 以及删除账户时的级联删除都会在同一事务内更新索引
+var messageSearchSchema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS email_messages_fts USING fts5(
+		subject, sender, recipients,
+		content='email_messages', content_rowid='id', tokenize='trigram'
+	)`,
+	`CREATE TRIGGER IF NOT EXISTS email_messages_fts_insert AFTER INSERT ON email_messages BEGIN
+		INSERT INTO email_messages_fts(rowid, subject, sender, recipients)
+		VALUES (new.id, new.subject, new.sender, new.recipients);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS email_messages_fts_delete AFTER DELETE ON email_messages BEGIN
+		INSERT INTO email_messages_fts(email_messages_fts, rowid, subject, sender, recipients)
+		VALUES ('delete', old.id, old.subject, old.sender, old.recipients);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS email_messages_fts_update AFTER UPDATE OF subject, sender, recipients ON email_messages BEGIN
+		INSERT INTO email_messages_fts(email_messages_fts, rowid, subject, sender, recipients)
+		VALUES ('delete', old.id, old.subject, old.sender, old.recipients);
+		INSERT INTO email_messages_fts(rowid, subject, sender, recipients)
+		VALUES (new.id, new.subject, new.sender, new.recipients);
+	END`,
+}
+
+// setupMessageSearch 创建邮件全文索引，首次创建时为已有邮件建立索引
+// 失败时（如 SQLite 未编译 FTS5）返回错误，全文索引不可用，搜索退回 LIKE 匹配
+func (d *Database) setupMessageSearch() error {
+	var existing int
+	if err := d.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'email_messages_fts'`).Scan(&existing); err != nil {
+		return fmt.Errorf("检查邮件全文索引失败: %v", err)
+	}
+
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		for _, stmt := range messageSearchSchema {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		if existing == 0 {
+			_, err := tx.Exec(`INSERT INTO email_messages_fts(email_messages_fts) VALUES ('rebuild')`)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("创建邮件全文索引失败: %v", err)
+	}
+	d.messageSearchFTS = true
+	return nil
+}
+
+// SearchEmailMessages 按主题、发件人、收件人搜索邮件，返回结果包含关联的邮箱账户，按记录时间倒序
+// 关键词不少于三个字符且全文索引可用时使用索引，否则使用 LIKE 匹配；两种方式都不区分大小写
+func (d *Database) SearchEmailMessages(query string, limit, offset int) ([]models.EmailMessage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return []models.EmailMessage{}, nil
+	}
+
+	var where string
+	var args []interface{}
+	if d.messageSearchFTS && len([]rune(query)) >= minFTSQueryLength {
+		// 整体作为短语匹配，避免关键词中的引号、AND、* 等被当作查询语法
+		where = `em.id IN (SELECT rowid FROM email_messages_fts WHERE email_messages_fts MATCH ?)`
+		args = append(args, `"`+strings.ReplaceAll(query, `"`, `""`)+`"`)
+	} else {
+		pattern := "%" + escapeLike(query) + "%"
+		where = `(em.subject LIKE ? ESCAPE '\' OR em.sender LIKE ? ESCAPE '\' OR em.recipients LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern, pattern)
+	}
+	args = append(args, limit, offset)
+
+	rows, err := d.DB.Query(`
+		SELECT em.id, em.email_id, em.message_id, em.subject, em.sender, em.recipients, em.date,
+			em.has_pdf, em.is_processed, em.gm_msgid, em.gm_thrid, em.pdf_total, em.tasks_created,
+			em.pdf_downloaded, em.pdf_failed, em.extraction_status, em.created_at, em.updated_at,
+			ea.id, ea.name, ea.email, ea.imap_server, ea.imap_port, ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
+		FROM email_messages em
+		LEFT JOIN email_accounts ea ON em.email_id = ea.id
+		WHERE `+where+`
+		ORDER BY em.created_at DESC, em.id DESC
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件失败: %v", err)
+	}
+	defer rows.Close()
+
+	messages := []models.EmailMessage{}
+	for rows.Next() {
+		var msg models.EmailMessage
+		var createdAt, updatedAt time.Time
+		var accountID sql.NullInt64
+		var accountName, accountEmail, imapServer sql.NullString
+		var imapPort sql.NullInt64
+		var useSSL, isActive sql.NullBool
+		var accountCreatedAt, accountUpdatedAt sql.NullTime
+
+		if err := rows.Scan(
+			&msg.ID, &msg.EmailID, &msg.MessageID, &msg.Subject, &msg.Sender, &msg.Recipients, &msg.Date,
+			&msg.HasPDF, &msg.IsProcessed, &msg.GmailMessageID, &msg.GmailThreadID, &msg.PDFTotal, &msg.TasksCreated,
+			&msg.PDFDownloaded, &msg.PDFFailed, &msg.ExtractionStatus, &createdAt, &updatedAt,
+			&accountID, &accountName, &accountEmail, &imapServer, &imapPort, &useSSL, &isActive,
+			&accountCreatedAt, &accountUpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("读取搜索结果失败: %v", err)
+		}
+		msg.CreatedAt = models.TimeToString(createdAt)
+		msg.UpdatedAt = models.TimeToString(updatedAt)
+
+		// 账户已删除的邮件不带账户信息，密码等凭据不返回给前端
+		if accountID.Valid {
+			msg.EmailAccount = models.EmailAccount{
+				ID:         uint(accountID.Int64),
+				Name:       accountName.String,
+				Email:      accountEmail.String,
+				IMAPServer: imapServer.String,
+				IMAPPort:   int(imapPort.Int64),
+				UseSSL:     useSSL.Bool,
+				IsActive:   isActive.Bool,
+			}
+			if accountCreatedAt.Valid {
+				msg.EmailAccount.CreatedAt = models.TimeToString(accountCreatedAt.Time)
+			}
+			if accountUpdatedAt.Valid {
+				msg.EmailAccount.UpdatedAt = models.TimeToString(accountUpdatedAt.Time)
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，配合 ESCAPE '\' 使用
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
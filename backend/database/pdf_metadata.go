@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// SavePDFMetadata 保存任务PDF的元数据索引，替换该任务之前的记录
+func (d *Database) SavePDFMetadata(metadata *models.PDFMetadata) error {
+	now := time.Now()
+	_, err := d.DB.Exec(`INSERT OR REPLACE INTO pdf_metadata (task_id, title, author, page_count, thumbnail, indexed_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		metadata.TaskID, metadata.Title, metadata.Author, metadata.PageCount, metadata.Thumbnail, now)
+	if err != nil {
+		return err
+	}
+	metadata.IndexedAt = models.TimeToString(now)
+	return nil
+}
+
+// GetPDFMetadata 获取任务PDF的元数据索引，尚未索引时返回 sql.ErrNoRows
+func (d *Database) GetPDFMetadata(taskID uint) (*models.PDFMetadata, error) {
+	metadata := &models.PDFMetadata{TaskID: taskID}
+	var indexedAt sql.NullTime
+	err := d.DB.QueryRow(`SELECT title, author, page_count, thumbnail, indexed_at FROM pdf_metadata WHERE task_id = ?`, taskID).
+		Scan(&metadata.Title, &metadata.Author, &metadata.PageCount, &metadata.Thumbnail, &indexedAt)
+	if err != nil {
+		return nil, err
+	}
+	if indexedAt.Valid {
+		metadata.IndexedAt = models.TimeToString(indexedAt.Time)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// GetRules 按顺序获取全部下载规则
+func (d *Database) GetRules() ([]models.Rule, error) {
+	rows, err := d.DB.Query(`SELECT id, name, account_id, field, pattern, action, enabled, position, created_at, updated_at
+		FROM rules ORDER BY position ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.Rule{}
+	for rows.Next() {
+		var rule models.Rule
+		var accountID sql.NullInt64
+		var createdAt, updatedAt sql.NullTime
+		if err := rows.Scan(&rule.ID, &rule.Name, &accountID, &rule.Field, &rule.Pattern, &rule.Action,
+			&rule.Enabled, &rule.Position, &createdAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		rule.AccountID = uint(accountID.Int64)
+		if createdAt.Valid {
+			rule.CreatedAt = models.TimeToString(createdAt.Time)
+		}
+		if updatedAt.Valid {
+			rule.UpdatedAt = models.TimeToString(updatedAt.Time)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// ReplaceRules 在一个事务中用新的规则列表替换全部下载规则，任一规则的账户不存在时整体不生效
+// 规则按列表顺序重新编号并写回 ID、Position 和时间
+func (d *Database) ReplaceRules(rules []models.Rule) error {
+	now := time.Now()
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM rules`); err != nil {
+			return fmt.Errorf("清除原有规则失败: %v", err)
+		}
+
+		for i := range rules {
+			rule := &rules[i]
+			// 0 表示全部账户，保存为NULL以满足外键约束
+			var accountID interface{}
+			if rule.AccountID != 0 {
+				var exists bool
+				if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM email_accounts WHERE id = ?)`, rule.AccountID).Scan(&exists); err != nil {
+					return err
+				}
+				if !exists {
+					return fmt.Errorf("规则「%s」的邮箱账户 %d 不存在", rule.Name, rule.AccountID)
+				}
+				accountID = rule.AccountID
+			}
+
+			result, err := tx.Exec(`INSERT INTO rules (name, account_id, field, pattern, action, enabled, position, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				rule.Name, accountID, rule.Field, rule.Pattern, rule.Action, rule.Enabled, i, now, now)
+			if err != nil {
+				return fmt.Errorf("保存规则「%s」失败: %v", rule.Name, err)
+			}
+			id, err := result.LastInsertId()
+			if err != nil {
+				return err
+			}
+			rule.ID = uint(id)
+			rule.Position = i
+			rule.CreatedAt = models.TimeToString(now)
+			rule.UpdatedAt = models.TimeToString(now)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// speedSampleTimeLayout 采样时间精确到毫秒（采样间隔约500毫秒），前端可直接用 Date 解析
+const speedSampleTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// AddSpeedSample 记录任务的一次下载速度采样
+func (d *Database) AddSpeedSample(taskID uint, at time.Time, bytesPerSecond float64) error {
+	_, err := d.DB.Exec(`INSERT INTO download_speed_samples (task_id, timestamp, bytes_per_second) VALUES (?, ?, ?)`,
+		taskID, at, bytesPerSecond)
+	return err
+}
+
+// ClearSpeedSamples 删除任务的速度采样，任务重新开始下载时调用，速度曲线只显示最近一次尝试
+func (d *Database) ClearSpeedSamples(taskID uint) error {
+	_, err := d.DB.Exec(`DELETE FROM download_speed_samples WHERE task_id = ?`, taskID)
+	return err
+}
+
+// GetSpeedSamples 按时间顺序获取任务的下载速度采样
+func (d *Database) GetSpeedSamples(taskID uint) ([]models.SpeedSample, error) {
+	rows, err := d.DB.Query(`SELECT timestamp, bytes_per_second FROM download_speed_samples
+		WHERE task_id = ? ORDER BY timestamp, id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []models.SpeedSample{}
+	for rows.Next() {
+		var sample models.SpeedSample
+		var timestamp sql.NullTime
+		if err := rows.Scan(&timestamp, &sample.BytesPerSecond); err != nil {
+			return nil, err
+		}
+		if timestamp.Valid {
+			sample.Timestamp = timestamp.Time.Format(speedSampleTimeLayout)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, rows.Err()
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// emailMessageColumns 读取邮件记录时使用的列，顺序与 scanEmailMessage 一致
+const emailMessageColumns = `id, email_id, message_id, subject, sender, recipients, date,
+		has_pdf, is_processed, gm_msgid, gm_thrid, pdf_total, tasks_created, pdf_downloaded,
+		pdf_failed, extraction_status, created_at, updated_at`
+
+// scanEmailMessage 读取一条邮件记录
+func scanEmailMessage(row rowScanner) (*models.EmailMessage, error) {
+	message := &models.EmailMessage{}
+	var createdAt, updatedAt time.Time
+	if err := row.Scan(
+		&message.ID, &message.EmailID, &message.MessageID, &message.Subject,
+		&message.Sender, &message.Recipients, &message.Date, &message.HasPDF,
+		&message.IsProcessed, &message.GmailMessageID, &message.GmailThreadID,
+		&message.PDFTotal, &message.TasksCreated, &message.PDFDownloaded,
+		&message.PDFFailed, &message.ExtractionStatus, &createdAt, &updatedAt,
+	); err != nil {
+		return nil, err
+	}
+	message.CreatedAt = models.TimeToString(createdAt)
+	message.UpdatedAt = models.TimeToString(updatedAt)
+	return message, nil
+}
+
+// GetEmailMessageByID 根据记录ID获取邮件记录
+func (d *Database) GetEmailMessageByID(id uint) (*models.EmailMessage, error) {
+	return scanEmailMessage(d.DB.QueryRow(`SELECT `+emailMessageColumns+` FROM email_messages WHERE id = ?`, id))
+}
+
+// findTaskEmailMessage 查找任务的来源邮件记录：优先使用任务保存的记录ID，
+// 未关联的旧任务按账户、主题和发件人匹配最近的一封邮件，找不到时返回 nil
+func (d *Database) findTaskEmailMessage(task *models.DownloadTask) (*models.EmailMessage, error) {
+	if task.MessageID != 0 {
+		message, err := d.GetEmailMessageByID(task.MessageID)
+		if err != sql.ErrNoRows {
+			return message, err
+		}
+	}
+	if task.EmailID == 0 {
+		return nil, nil
+	}
+
+	message, err := scanEmailMessage(d.DB.QueryRow(`
+		SELECT `+emailMessageColumns+` FROM email_messages
+		WHERE email_id = ? AND subject = ? AND sender = ?
+		ORDER BY id DESC LIMIT 1`, task.EmailID, task.Subject, task.Sender))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return message, err
+}
+
+// GetTaskDetail 获取任务详情，包含来源邮件记录和所属邮箱账户
+// 账户的密码、OAuth密钥等凭据不返回
+func (d *Database) GetTaskDetail(taskID uint) (*models.TaskDetail, error) {
+	task, err := d.GetDownloadTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	task.EmailAccount.Password = ""
+
+	detail := &models.TaskDetail{Task: *task}
+
+	detail.Message, err = d.findTaskEmailMessage(task)
+	if err != nil {
+		return nil, fmt.Errorf("获取来源邮件失败: %v", err)
+	}
+
+	if task.EmailID != 0 {
+		account, err := d.GetEmailAccountByID(task.EmailID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("获取邮箱账户失败: %v", err)
+		}
+		if account != nil {
+			account.Password = ""
+			account.OAuthClientSecret = ""
+			account.OAuthRefreshToken = ""
+			detail.Account = account
+		}
+	}
+	return detail, nil
+}
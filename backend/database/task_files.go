@@ -0,0 +1,53 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// SaveTaskFiles 记录容器任务解压出的文件并将任务标记为容器，替换该任务之前的记录
+func (d *Database) SaveTaskFiles(taskID uint, files []models.TaskFile) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM task_files WHERE task_id = ?`, taskID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, file := range files {
+			if _, err := tx.Exec(`INSERT INTO task_files (task_id, file_name, local_path, file_size, created_at) VALUES (?, ?, ?, ?, ?)`,
+				taskID, file.FileName, d.storedTaskPath(file.LocalPath), file.FileSize, now); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.Exec(`UPDATE download_tasks SET is_container = 1, updated_at = ? WHERE id = ?`, now, taskID)
+		return err
+	})
+}
+
+// GetTaskFiles 获取容器任务解压出的文件
+func (d *Database) GetTaskFiles(taskID uint) ([]models.TaskFile, error) {
+	rows, err := d.DB.Query(`SELECT id, task_id, file_name, local_path, file_size, created_at
+		FROM task_files WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []models.TaskFile
+	for rows.Next() {
+		var file models.TaskFile
+		var createdAt sql.NullTime
+		if err := rows.Scan(&file.ID, &file.TaskID, &file.FileName, &file.LocalPath, &file.FileSize, &createdAt); err != nil {
+			return nil, err
+		}
+		file.LocalPath = d.resolveTaskPath(file.LocalPath)
+		if createdAt.Valid {
+			file.CreatedAt = models.TimeToString(createdAt.Time)
+		}
+		files = append(files, file)
+	}
+	return files, rows.Err()
+}
@@ -0,0 +1,51 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// MaxTaskLogEntries 每个任务保留的日志条数，超过时删除最早的记录
+const MaxTaskLogEntries = 200
+
+// AddTaskLog 记录任务的一条事件，并删除超出保留条数的旧记录
+func (d *Database) AddTaskLog(taskID uint, level, message string) error {
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		// 任务已被删除时不再写入，避免留下无主的记录
+		if _, err := tx.Exec(`INSERT INTO download_task_logs (task_id, level, message, created_at)
+			SELECT ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM download_tasks WHERE id = ?)`,
+			taskID, level, message, time.Now(), taskID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM download_task_logs WHERE task_id = ? AND id <= (
+			SELECT id FROM download_task_logs WHERE task_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)`, taskID, taskID, MaxTaskLogEntries)
+		return err
+	})
+}
+
+// GetTaskLogs 按时间顺序获取任务的事件记录
+func (d *Database) GetTaskLogs(taskID uint) ([]models.TaskLogEntry, error) {
+	rows, err := d.DB.Query(`SELECT id, task_id, level, message, created_at FROM download_task_logs
+		WHERE task_id = ? ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TaskLogEntry{}
+	for rows.Next() {
+		var entry models.TaskLogEntry
+		var createdAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &entry.TaskID, &entry.Level, &entry.Message, &createdAt); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			entry.CreatedAt = createdAt.Time.Format(speedSampleTimeLayout)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
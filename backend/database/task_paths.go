@@ -0,0 +1,119 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+
+	"emaild/backend/models"
+)
+
+// setPathSettings 更新下载路径的存储方式，读取配置和保存配置后调用
+func (d *Database) setPathSettings(config *models.AppConfig) {
+	d.pathMutex.Lock()
+	defer d.pathMutex.Unlock()
+
+	d.downloadRoot = config.DownloadPath
+	d.relativePaths = config.RelativePaths
+}
+
+// relativeToRoot 路径位于下载目录内时返回以 "/" 分隔的相对路径
+func relativeToRoot(root, path string) (string, bool) {
+	if root == "" || path == "" || !filepath.IsAbs(path) {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(filepath.Clean(root), filepath.Clean(path))
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// storedTaskPath 开启相对路径模式时，将下载目录内的路径转换为相对路径后保存
+func (d *Database) storedTaskPath(localPath string) string {
+	d.pathMutex.RLock()
+	defer d.pathMutex.RUnlock()
+
+	if !d.relativePaths {
+		return localPath
+	}
+	if rel, ok := relativeToRoot(d.downloadRoot, localPath); ok {
+		return rel
+	}
+	return localPath
+}
+
+// resolveTaskPath 将保存的相对路径解析为当前下载目录下的绝对路径
+// 无论是否开启相对路径模式都会解析，关闭模式后之前保存的相对路径仍然可用
+func (d *Database) resolveTaskPath(stored string) string {
+	if stored == "" || filepath.IsAbs(stored) {
+		return stored
+	}
+
+	d.pathMutex.RLock()
+	defer d.pathMutex.RUnlock()
+
+	return filepath.Join(d.downloadRoot, filepath.FromSlash(stored))
+}
+
+// ConvertTaskPathsToRelative 将位于当前下载目录内的绝对路径转换为相对路径，返回转换的任务数
+// 开启相对路径模式或更换下载目录时调用，下载目录外的路径保持不变；容器任务解压出的文件一并转换
+func (d *Database) ConvertTaskPathsToRelative() (int, error) {
+	d.pathMutex.RLock()
+	root := d.downloadRoot
+	d.pathMutex.RUnlock()
+
+	updates, err := d.relativePathUpdates(root, `SELECT id, local_path FROM download_tasks WHERE local_path IS NOT NULL AND local_path != ''`)
+	if err != nil {
+		return 0, err
+	}
+	fileUpdates, err := d.relativePathUpdates(root, `SELECT id, local_path FROM task_files WHERE local_path != ''`)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(updates) == 0 && len(fileUpdates) == 0 {
+		return 0, nil
+	}
+
+	err = d.WithTransaction(func(tx *sql.Tx) error {
+		for id, rel := range updates {
+			if _, err := tx.Exec(`UPDATE download_tasks SET local_path = ? WHERE id = ?`, rel, id); err != nil {
+				return err
+			}
+		}
+		for id, rel := range fileUpdates {
+			if _, err := tx.Exec(`UPDATE task_files SET local_path = ? WHERE id = ?`, rel, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(updates), nil
+}
+
+// relativePathUpdates 查询 (id, local_path) 并返回位于下载目录内、需要转换为相对路径的记录
+func (d *Database) relativePathUpdates(root, query string) (map[uint]string, error) {
+	rows, err := d.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := make(map[uint]string)
+	for rows.Next() {
+		var id uint
+		var localPath string
+		if err := rows.Scan(&id, &localPath); err != nil {
+			return nil, err
+		}
+		if rel, ok := relativeToRoot(root, localPath); ok {
+			updates[id] = rel
+		}
+	}
+	return updates, rows.Err()
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// StartupCheck 启动时对上次未正常关闭的数据库所做的恢复和检查
+type StartupCheck struct {
+	UncleanShutdown    bool   // 启动时存在上次遗留的WAL文件，说明应用上次被强制结束
+	WALSize            int64  // 遗留WAL文件的大小（字节）
+	CheckpointBusy     bool   // 检查点因数据库被占用未能完成，WAL会在之后的检查点写回
+	IntegrityError     string // 完整性检查发现的问题，为空表示正常
+	MessageSearchError string // 创建邮件全文索引失败的原因，为空表示可用；失败时搜索退回 LIKE 匹配
+}
+
+// NeedsAttention 是否进行过恢复或发现问题，需要记录日志
+func (c StartupCheck) NeedsAttention() bool {
+	return c.UncleanShutdown || c.IntegrityError != ""
+}
+
+// leftoverWALSize 返回数据库文件旁遗留的WAL文件大小，不存在时返回0
+// 正常关闭时最后一个连接会执行检查点并删除WAL文件，遗留的WAL说明进程被强制结束
+func leftoverWALSize(path string) int64 {
+	info, err := os.Stat(path + "-wal")
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// recoverWAL 将上次遗留的WAL写回数据库文件并截断WAL，然后快速检查数据库完整性
+// SQLite打开数据库时只会重放WAL中已提交的事务，未提交的部分被丢弃
+func (d *Database) recoverWAL(walSize int64) error {
+	check := StartupCheck{UncleanShutdown: true, WALSize: walSize}
+
+	var busy, walFrames, checkpointed int
+	if err := d.DB.QueryRow("PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &walFrames, &checkpointed); err != nil {
+		return fmt.Errorf("执行WAL检查点失败: %v", err)
+	}
+	check.CheckpointBusy = busy != 0
+
+	rows, err := d.DB.Query("PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("检查数据库完整性失败: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("读取完整性检查结果失败: %v", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("读取完整性检查结果失败: %v", err)
+	}
+	check.IntegrityError = strings.Join(problems, "; ")
+
+	d.startupCheck = check
+	return nil
+}
+
+// StartupCheck 返回启动时的WAL恢复和完整性检查结果
+func (d *Database) StartupCheck() StartupCheck {
+	return d.startupCheck
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -16,8 +18,139 @@ type EmailAccount struct {
 	IsActive    bool   `json:"is_active"`   // 是否启用
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
+
+	MonitoringPaused bool   `json:"monitoring_paused"` // 是否暂停后台监控（仍可手动检查）
+	AuthMechanism    string `json:"auth_mechanism"`    // 认证机制（auto/plain/login/cram-md5）
+	AuthType         string `json:"auth_type"`         // 认证方式（password/oauth2），oauth2 时使用XOAUTH2并忽略密码和认证机制
+	LastError        string `json:"last_error"`        // 最近一次连接验证的错误信息，成功时为空
+	SearchCriteria   string `json:"search_criteria"`   // 自定义IMAP SEARCH条件，非空时替代内置的未读邮件搜索
+
+	// 最近一次手动测试连接的结果：错误分类（auth/network 等，与失败任务汇总的分类相同，成功时为空）和测试时间
+	LastErrorCategory string `json:"last_error_category"`
+	LastCheckedAt     string `json:"last_checked_at"`
+
+	// 是否已完成一次历史邮件导入（避免重复导入）
+	HistoricalImportDone bool `json:"historical_import_done"`
+
+	// 连接时探测的账户权限，只读账户无法标记已读或移动邮件（未探测前默认可用）
+	CanModifyFlags  bool `json:"can_modify_flags"`  // 能否修改邮件标志（如 \Seen）
+	CanMoveMessages bool `json:"can_move_messages"` // 能否移动或删除邮件
+
+	// 除收件箱外额外扫描的文件夹（角色如 sent/drafts，或以 "/" 分隔的路径）
+	// 这些文件夹中的邮件通常已读，按UID增量处理而不依赖未读状态
+	ScanFolders []string `json:"scan_folders"`
+
+	// 服务器TLS证书的SHA-256指纹（证书固定），启用证书固定后首次连接成功时记录
+	CertFingerprint        string `json:"cert_fingerprint"`
+	PendingCertFingerprint string `json:"pending_cert_fingerprint"` // 与已记录指纹不同、等待用户确认的新证书指纹
+
+	// 账户专用下载目录，非空时替代全局下载目录保存该账户的文件
+	DownloadPath string `json:"download_path"`
+
+	// 账户下载的附件扩展名，非空时替代全局设置的附件类型
+	AllowedExtensions []string `json:"allowed_extensions"`
+
+	// 检查新邮件的主文件夹（如 Gmail 标签 Invoices/2024，以 "/" 分隔层级），默认为收件箱
+	Folder string `json:"folder"`
+
+	// 通过IMAP IDLE接收新邮件推送，代替定时检查；服务器不支持IDLE时仍按检查间隔定时检查
+	UseIdle bool `json:"use_idle"`
+
+	// 通过SSH隧道连接只能从内网访问的IMAP服务器，SSHHost 为空时直接连接
+	SSHHost    string `json:"ssh_host"`     // SSH跳板机地址
+	SSHPort    int    `json:"ssh_port"`     // SSH端口，默认22
+	SSHUser    string `json:"ssh_user"`     // SSH用户名
+	SSHKeyPath string `json:"ssh_key_path"` // SSH私钥文件路径（不支持带密码的私钥）
+	SSHHostKey string `json:"ssh_host_key"` // 用户核对后确认的跳板机主机密钥指纹（SHA256:…），建立隧道时校验
+
+	// OAuth2 授权信息（AuthType 为 oauth2 时使用），连接前用刷新令牌换取访问令牌
+	OAuthClientID     string `json:"oauth_client_id"`
+	OAuthClientSecret string `json:"oauth_client_secret"`
+	OAuthRefreshToken string `json:"oauth_refresh_token"`
+	OAuthTokenURL     string `json:"oauth_token_url"` // 令牌端点，为空时按IMAP服务器识别Gmail和Outlook
+
+	// 服务器文件夹缓存（首次连接时探测）
+	FolderDelimiter string            `json:"folder_delimiter"` // 文件夹层级分隔符
+	SpecialFolders  map[string]string `json:"special_folders"`  // 特殊用途文件夹（角色 -> 文件夹名）
+}
+
+// DefaultSSHPort SSH隧道默认端口
+const DefaultSSHPort = 22
+
+// UsesSSHTunnel 账户是否通过SSH隧道连接
+func (a *EmailAccount) UsesSSHTunnel() bool {
+	return strings.TrimSpace(a.SSHHost) != ""
+}
+
+// DefaultFolder 账户默认检查的文件夹
+const DefaultFolder = "INBOX"
+
+// MainFolder 返回账户检查的主文件夹，未配置时为收件箱
+func (a *EmailAccount) MainFolder() string {
+	if folder := strings.TrimSpace(a.Folder); folder != "" {
+		return folder
+	}
+	return DefaultFolder
+}
+
+// 历史邮件范围（后台检查和批量导入处理哪些时间的邮件）
+const (
+	HistoryScopeSinceAccountAdded = "since-account-added" // 只处理添加账户之后的邮件
+	HistoryScopeLastNDays         = "last-n-days"         // 只处理最近N天的邮件
+	HistoryScopeAll               = "all"                 // 处理全部历史邮件
+)
+
+// 证书固定模式（服务器证书指纹与首次连接时记录的不一致时如何处理）
+const (
+	CertPinningOff     = "off"     // 不检查
+	CertPinningWarn    = "warn"    // 记录警告并继续连接
+	CertPinningEnforce = "enforce" // 拒绝连接，直到用户确认新证书
+)
+
+// 下载统计的日期依据
+// 按完成日期统计反映每天实际完成的下载量；按接收日期统计把文件归入邮件到达的那一天，
+// 便于按账期对账，但补下载历史邮件时会改写过去日期的统计
+const (
+	StatisticsDateCompleted = "completed" // 下载完成（或失败）的日期
+	StatisticsDateReceived  = "received"  // 邮件的接收日期
+)
+
+// 下载文件的整理方式
+const (
+	OrganizeByNone          = "none"           // 直接保存到下载目录（或按路径模板）
+	OrganizeByAccountSender = "account-sender" // 按 账户名/发件人域名 分目录保存
+)
+
+// 账户认证方式
+const (
+	AuthTypePassword = "password" // 密码或授权码
+	AuthTypeOAuth2   = "oauth2"   // OAuth2 刷新令牌，通过SASL XOAUTH2登录
+)
+
+// UsesOAuth2 账户是否使用OAuth2认证
+func (a *EmailAccount) UsesOAuth2() bool {
+	return strings.EqualFold(a.AuthType, AuthTypeOAuth2)
 }
 
+// IMAP认证机制
+const (
+	AuthMechanismAuto    = "auto"     // 默认使用LOGIN命令，服务器禁用时自动选择
+	AuthMechanismPlain   = "plain"    // SASL PLAIN
+	AuthMechanismLogin   = "login"    // SASL LOGIN
+	AuthMechanismCRAMMD5 = "cram-md5" // SASL CRAM-MD5
+)
+
+// 特殊用途文件夹角色（RFC 6154 SPECIAL-USE）
+const (
+	FolderRoleAll     = "all"
+	FolderRoleArchive = "archive"
+	FolderRoleDrafts  = "drafts"
+	FolderRoleFlagged = "flagged"
+	FolderRoleJunk    = "junk"
+	FolderRoleSent    = "sent"
+	FolderRoleTrash   = "trash"
+)
+
 // DownloadTask 下载任务
 type DownloadTask struct {
 	ID             uint          `json:"id"`
@@ -37,6 +170,116 @@ type DownloadTask struct {
 	Speed          string        `json:"speed"`           // 下载速度
 	CreatedAt      string        `json:"created_at"`
 	UpdatedAt      string        `json:"updated_at"`
+
+	// 邮件所在文件夹，为空表示收件箱
+	Folder string `json:"folder"`
+
+	// 下载完成后计算的文件校验和，供用户与邮件中提供的校验值比对
+	Checksum          string `json:"checksum"`           // 十六进制校验和
+	ChecksumAlgorithm string `json:"checksum_algorithm"` // 校验和算法（md5/sha256）
+
+	// ZIP附件解压后任务作为容器，解压出的文件记录在 task_files 中
+	IsContainer bool `json:"is_container"`
+
+	// 邮件的接收时间（服务器内部日期，缺失时取信头日期），按接收日期统计时使用
+	EmailDate string `json:"email_date"`
+
+	// 任务已重新排队的次数（自动和手动重试合计）
+	RetryCount int `json:"retry_count"`
+
+	// 来源邮件在 email_messages 中的记录ID，0 表示未关联（旧任务或手动添加的任务）
+	MessageID uint `json:"message_id"`
+
+	// 下载内容的SHA-256，用于发现不同邮件中的相同文件
+	Hash string `json:"hash"`
+	// 内容与之相同的已完成任务ID，本地路径指向该任务的文件；0表示不是重复文件
+	DuplicateOf uint `json:"duplicate_of"`
+	// 分卷PDF合并后生成的任务ID，0表示未被合并
+	MergedInto uint `json:"merged_into"`
+}
+
+// TaskDetail 任务详情：任务本身、来源邮件记录和所属邮箱账户
+type TaskDetail struct {
+	Task    DownloadTask  `json:"task"`
+	Message *EmailMessage `json:"message"` // 来源邮件记录，找不到时为空
+	Account *EmailAccount `json:"account"` // 所属邮箱账户（不含凭据），账户已删除时为空
+}
+
+// DownloadTaskFilter 下载任务列表的筛选、排序和分页条件，空值表示不筛选或使用默认值
+type DownloadTaskFilter struct {
+	Status    DownloadStatus `json:"status"`     // 按状态筛选
+	AccountID uint           `json:"account_id"` // 按邮箱账户筛选
+	SortBy    string         `json:"sort_by"`    // 排序字段（created_at/updated_at/file_size/file_name/status），默认 created_at
+	SortOrder string         `json:"sort_order"` // 排序方向（asc/desc），默认 desc
+	Page      int            `json:"page"`       // 页码，从1开始
+	PageSize  int            `json:"page_size"`  // 每页数量
+}
+
+// 下载任务排序字段
+const (
+	TaskSortCreatedAt = "created_at"
+	TaskSortUpdatedAt = "updated_at"
+	TaskSortFileSize  = "file_size"
+	TaskSortFileName  = "file_name"
+	TaskSortStatus    = "status"
+)
+
+// TaskFile 容器任务（ZIP附件）解压出的单个文件
+type TaskFile struct {
+	ID        uint   `json:"id"`
+	TaskID    uint   `json:"task_id"`    // 所属的下载任务
+	FileName  string `json:"file_name"`  // 文件名
+	LocalPath string `json:"local_path"` // 本地保存路径
+	FileSize  int64  `json:"file_size"`  // 文件大小（字节）
+	CreatedAt string `json:"created_at"`
+}
+
+// SpeedSample 下载过程中按进度更新间隔记录的一次速度采样，用于绘制速度曲线
+type SpeedSample struct {
+	Timestamp      string  `json:"timestamp"`        // RFC3339格式，精确到毫秒
+	BytesPerSecond float64 `json:"bytes_per_second"` // 距上一次采样期间的平均速度
+}
+
+// 任务日志的级别
+const (
+	TaskLogInfo  = "info"
+	TaskLogWarn  = "warning"
+	TaskLogError = "error"
+)
+
+// TaskLogEntry 下载任务的一条事件记录（开始、重试、续传、失败原因等），用于在界面上查看任务经过
+type TaskLogEntry struct {
+	ID        uint   `json:"id"`
+	TaskID    uint   `json:"task_id"`
+	Level     string `json:"level"`     // info、warning 或 error
+	Message   string `json:"message"`
+	CreatedAt string `json:"created_at"` // RFC3339格式，精确到毫秒
+}
+
+// PDFMetadata 已完成PDF的元数据索引，浏览大量PDF时无需逐个打开文件
+type PDFMetadata struct {
+	TaskID    uint   `json:"task_id"`
+	Title     string `json:"title"`     // 文档信息字典中的标题
+	Author    string `json:"author"`    // 文档信息字典中的作者
+	PageCount int    `json:"page_count"`
+	Thumbnail string `json:"thumbnail"` // 第一页图像的JPEG缩略图（data URL），未生成时为空
+	IndexedAt string `json:"indexed_at"`
+}
+
+// ValidationResult 重新验证单个任务文件的结果
+type ValidationResult struct {
+	TaskID            uint           `json:"task_id"`
+	LocalPath         string         `json:"local_path"`
+	Valid             bool           `json:"valid"`              // 是否通过全部检查
+	Exists            bool           `json:"exists"`             // 文件是否存在
+	FileSize          int64          `json:"file_size"`          // 当前文件大小（字节）
+	ContentError      string         `json:"content_error"`      // 内容检查（PDF结构或文件头）未通过的原因
+	ChecksumAlgorithm string         `json:"checksum_algorithm"` // 比对校验和使用的算法，未记录校验和时为空
+	ChecksumMatches   bool           `json:"checksum_matches"`   // 文件校验和是否与下载完成时记录的一致
+	ExtractedFiles    int            `json:"extracted_files"`    // 容器任务解压出的文件数
+	ExtractedErrors   []string       `json:"extracted_errors"`   // 容器任务中未通过检查的文件及原因
+	Error             string         `json:"error"`              // 未通过时的原因汇总
+	Status            DownloadStatus `json:"status"`             // 验证后的任务状态
 }
 
 // DownloadStatus 下载状态枚举
@@ -49,6 +292,8 @@ const (
 	StatusFailed      DownloadStatus = "failed"      // 失败
 	StatusPaused      DownloadStatus = "paused"      // 暂停
 	StatusCancelled   DownloadStatus = "cancelled"   // 已取消
+	StatusIncomplete  DownloadStatus = "incomplete"  // 分段附件尚未收齐
+	StatusExpired     DownloadStatus = "expired"     // 排队过久未开始，启动时清理
 )
 
 // DownloadType 下载类型枚举
@@ -57,8 +302,26 @@ type DownloadType string
 const (
 	TypeAttachment DownloadType = "attachment" // 附件
 	TypeLink       DownloadType = "link"       // 链接
+	TypePartial    DownloadType = "partial"    // 分段附件（message/partial，源为分段ID）
+	TypeMerged     DownloadType = "merged"     // 合并的分卷PDF（源为各分卷任务ID，逗号分隔）
 )
 
+// 同一PDF同时以附件和链接出现时的来源偏好
+const (
+	PreferAttachmentSource = "attachment" // 保留附件，跳过链接
+	PreferLinkSource       = "link"       // 保留链接，跳过附件
+)
+
+// PartialFragment message/partial 分段邮件的一个片段（RFC 2046）
+type PartialFragment struct {
+	ID        uint   `json:"id"`
+	EmailID   uint   `json:"email_id"`   // 关联的邮箱ID
+	PartialID string `json:"partial_id"` // 分段ID，同一原始邮件的所有片段相同
+	Number    int    `json:"number"`     // 片段序号（从1开始）
+	Total     int    `json:"total"`      // 总片段数（只有最后一段必须携带，其余可能为0）
+	Content   []byte `json:"-"`          // 片段内容
+}
+
 // EmailMessage 邮件信息
 type EmailMessage struct {
 	ID           uint         `json:"id"`
@@ -73,8 +336,29 @@ type EmailMessage struct {
 	IsProcessed  bool         `json:"is_processed"`  // 是否已处理
 	CreatedAt    string       `json:"created_at"`
 	UpdatedAt    string       `json:"updated_at"`
+
+	// Gmail 扩展标识（X-GM-EXT-1），在邮件移动或重新标记后保持不变，非Gmail邮箱为空
+	// 使用字符串保存，避免64位整数在前端丢失精度
+	GmailMessageID string `json:"gmail_message_id"` // X-GM-MSGID
+	GmailThreadID  string `json:"gmail_thread_id"`  // X-GM-THRID
+
+	// 邮件中文件的提取结果：发现的来源数、成功创建的任务数、已下载数和失败数（含未能创建任务的来源）
+	// 各任务独立下载，部分失败时其余文件照常下载，提取状态为 partial
+	PDFTotal         int    `json:"pdf_total"`
+	TasksCreated     int    `json:"tasks_created"`
+	PDFDownloaded    int    `json:"pdf_downloaded"`
+	PDFFailed        int    `json:"pdf_failed"`
+	ExtractionStatus string `json:"extraction_status"` // 见 Extraction* 常量，没有文件的邮件为空
 }
 
+// 邮件的文件提取状态
+const (
+	ExtractionPending  = "pending"  // 仍有任务未结束
+	ExtractionComplete = "complete" // 全部任务结束且没有失败（跳过的重复来源不算失败）
+	ExtractionPartial  = "partial"  // 部分文件下载成功，部分失败
+	ExtractionFailed   = "failed"   // 没有文件下载成功
+)
+
 // AppConfig 应用配置
 type AppConfig struct {
 	ID                 uint   `json:"id"`
@@ -87,10 +371,179 @@ type AppConfig struct {
 	EnableNotification bool   `json:"enable_notification"` // 启用通知
 	Theme              string `json:"theme"`               // 主题（light/dark/auto）
 	Language           string `json:"language"`            // 语言
+	MaxTasksPerCheck   int    `json:"max_tasks_per_check"` // 单次检查最多创建的下载任务数（0表示不限制）
+	TelemetryEnabled   bool   `json:"telemetry_enabled"`   // 是否开启匿名错误遥测（默认关闭）
+	TelemetryEndpoint  string `json:"telemetry_endpoint"`  // 遥测上报地址
+	PathTemplate       string `json:"path_template"`       // 下载子目录模板（如 {account}/{sender_domain}），为空时直接保存到下载目录
+	ChecksumAlgorithm  string `json:"checksum_algorithm"`  // 下载完成后计算的校验和算法（md5/sha256，为空不计算）
+
+	// 下载时间窗口（独立于邮件检查，窗口外发现的任务保持等待）
+	DownloadWindowStart string `json:"download_window_start"` // 开始时间（HH:MM，为空不限制）
+	DownloadWindowEnd   string `json:"download_window_end"`   // 结束时间（HH:MM，可跨午夜）
+
+	// 免打扰时段，时段内后台不检查邮件、暂停IDLE推送，手动检查不受影响
+	QuietHoursStart string `json:"quiet_hours_start"` // 开始时间（HH:MM，为空不限制）
+	QuietHoursEnd   string `json:"quiet_hours_end"`   // 结束时间（HH:MM，可跨午夜）
+
+	// 单个附件下载的整体期限（分钟），超时后中止传输并释放并发槽位，0表示使用默认值
+	DownloadDeadlineMinutes int `json:"download_deadline_minutes"`
+
+	// 后台检查的历史范围（since-account-added/last-n-days/all），避免首次检查处理大量旧邮件
+	CheckScope     string `json:"check_scope"`
+	CheckScopeDays int    `json:"check_scope_days"` // last-n-days 范围的天数
+
+	// 同一PDF同时以附件和链接出现时保留哪种来源（attachment/link）
+	DuplicateSourcePreference string `json:"duplicate_source_preference"`
+
+	// 下载完成后自动打开（默认关闭），同一批完成的下载合并处理，避免一次打开大量窗口
+	AutoOpenFile   bool `json:"auto_open_file"`   // 自动打开下载的PDF文件
+	AutoOpenFolder bool `json:"auto_open_folder"` // 自动打开文件所在目录（每批每个目录只打开一次）
+
+	// 未通过PDF验证的下载移入 ~/.emaild/quarantine/ 并附带失败原因，而不是直接删除
+	QuarantineInvalidFiles bool `json:"quarantine_invalid_files"`
+
+	// 批量获取邮件信息时每次FETCH的邮件数，候选邮件较多时分批获取，避免单次响应过大，0表示使用默认值
+	FetchBatchSize int `json:"fetch_batch_size"`
+
+	// IMAP服务器证书固定模式（off/warn/enforce），用于发现可能的中间人攻击
+	CertPinningMode string `json:"cert_pinning_mode"`
+
+	// 下载目录内的文件以相对路径保存，整个下载目录移动后只需修改下载路径即可继续使用
+	RelativePaths bool `json:"relative_paths"`
+
+	// 下载链接最多跟随的重定向次数（默认5，上限10），0表示使用默认值
+	MaxRedirects int `json:"max_redirects"`
+
+	// 下载因网络抖动中断时原地重试的次数（链接续传、附件重新连接），0表示不重试，与任务重新排队无关
+	QuickRetryAttempts int `json:"quick_retry_attempts"`
+
+	// 失败任务最多重新排队的次数（自动和手动重试合计），0表示不允许重试
+	MaxTaskRetries int `json:"max_task_retries"`
+
+	// 网络错误导致失败的任务自动重试前的等待时间（秒），每次重试翻倍
+	TaskRetryBackoffSeconds int `json:"task_retry_backoff_seconds"`
+
+	// 单个账户检查的期限（分钟），超时的账户被强制中止，不影响其他账户，0表示使用默认值
+	AccountCheckTimeoutMinutes int `json:"account_check_timeout_minutes"`
+
+	// 附件为ZIP压缩包时下载并解压其中的PDF，作为该任务的文件单独保存
+	ExtractZipAttachments bool `json:"extract_zip_attachments"`
+
+	// 下载的附件类型（扩展名，如 pdf、docx、xlsx），为空时只下载PDF
+	AllowedExtensions []string `json:"allowed_extensions"`
+
+	// 下载统计按哪个日期归档（completed/received），默认按下载完成日期
+	StatisticsDateSource string `json:"statistics_date_source"`
+
+	// 一批下载全部完成后播放提示音（整批只播放一次），关闭桌面通知时不播放
+	CompletionSound bool `json:"completion_sound"`
+
+	// 下载文件的整理方式（none/account-sender），按账户和发件人整理时忽略路径模板
+	OrganizeBy string `json:"organize_by"`
+
+	// 启动时恢复未完成任务：启动后等待的秒数、每分钟重新排队的任务数（0表示不限速）、
+	// 超过多少小时的任务不再恢复
+	RecoveryDelaySeconds   int `json:"recovery_delay_seconds"`
+	RecoveryTasksPerMinute int `json:"recovery_tasks_per_minute"`
+	RecoveryMaxAgeHours    int `json:"recovery_max_age_hours"`
+
+	// 启动时把排队超过多少小时仍未开始的等待任务标记为已过期，0表示不清理
+	PendingExpiryHours int `json:"pending_expiry_hours"`
+
+	// 搜索最近邮件的时间窗口（天），定位下载任务的邮件和未读搜索的备用策略使用，默认7天
+	ScanWindowDays int `json:"scan_window_days"`
+	// 账户首次检查时额外导入最近多少天的邮件（包括已读邮件），只进行一次，0表示不导入
+	InitialScanDays int `json:"initial_scan_days"`
+
+	// 下载链接使用的代理（http/https/socks5），为空表示不使用，例如 socks5://127.0.0.1:1080
+	ProxyURL string `json:"proxy_url"`
+
+	// 下载PDF后严格验证：除文件头和EOF标记外，还检查 startxref 指向的交叉引用表
+	StrictPDFValidation bool `json:"strict_pdf_validation"`
+
+	// 下载的文件与已完成任务的内容相同时不再保存，任务直接指向已有文件
+	DeduplicateDownloads bool `json:"deduplicate_downloads"`
+
+	// 任务完成或失败时POST通知的地址（http/https），为空表示不通知
+	WebhookURL string `json:"webhook_url"`
+	// Webhook签名密钥，填写后请求头 X-Emaild-Signature 携带请求体的 HMAC-SHA256 签名
+	WebhookSecret string `json:"webhook_secret"`
+
+	// 下载链接时单个文件的大小上限（MB），超过时中止下载并删除临时文件，0表示不限制
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+
+	// 可用内存低于该值（MB）时暂缓启动新的下载任务，只保留一个下载进行，0表示不限制
+	MinFreeMemoryMB int `json:"min_free_memory_mb"`
+
+	// 发件人白名单和黑名单，逗号分隔，支持完整地址、域名（vendor.com 或 @vendor.com）和通配符（*@vendor.com）
+	// 黑名单中的发件人只保存邮件记录不创建下载任务；白名单不为空时只为匹配的发件人创建下载任务
+	SenderAllowlist string `json:"sender_allowlist"`
+	SenderBlocklist string `json:"sender_blocklist"`
+
+	// 在后台为下载完成的PDF建立元数据索引（标题、作者、页数），并可生成第一页图像的缩略图
+	IndexPDFMetadata bool `json:"index_pdf_metadata"`
+	PDFThumbnails    bool `json:"pdf_thumbnails"`
+
+	// 分卷PDF合并规则：匹配文件名中分卷标记的正则表达式（如 [_-]?part\d+），为空表示不合并
+	// 同一邮件中去掉标记后文件名相同的多个PDF全部下载完成后，按邮件中的顺序合并为一个文件
+	MergePDFPattern string `json:"merge_pdf_pattern"`
+
+	// 为主文件夹中已创建下载任务的邮件添加 \Seen 标志，下次检查不再获取；会修改邮箱中的邮件状态，默认关闭
+	MarkAsRead bool `json:"mark_as_read"`
+
+	// 同时保持的IMAP连接数上限（检查、IDLE推送和附件下载的连接一起计数），达到上限时关闭最久未使用的空闲连接，0表示不限制
+	MaxIMAPConnections int `json:"max_imap_connections"`
+
 	CreatedAt          string `json:"created_at"`
 	UpdatedAt          string `json:"updated_at"`
 }
 
+// DownloadMetrics 本次运行以来的下载指标，应用重启后重新计数
+type DownloadMetrics struct {
+	Completed       int64 `json:"completed"`        // 完成的下载数
+	Failed          int64 `json:"failed"`           // 失败的下载数
+	BytesDownloaded int64 `json:"bytes_downloaded"` // 下载中收到的字节数
+	ProgressUpdates int64 `json:"progress_updates"` // 处理的进度更新数
+}
+
+// PathCheck 下载目录检测结果
+type PathCheck struct {
+	Path      string `json:"path"`       // 检测的目录（绝对路径）
+	Exists    bool   `json:"exists"`     // 是否存在
+	IsDir     bool   `json:"is_dir"`     // 是否为目录
+	Writable  bool   `json:"writable"`   // 是否可写（通过临时文件实际写入检测）
+	FreeBytes int64  `json:"free_bytes"` // 可用空间（字节），无法获取时为-1
+	Error     string `json:"error"`      // 检测发现的问题，正常时为空
+}
+
+// TaskNetworkActivity 单个下载中任务的实时网络状态
+type TaskNetworkActivity struct {
+	TaskID         uint    `json:"task_id"`
+	FileName       string  `json:"file_name"`
+	Type           string  `json:"type"`             // 下载类型（attachment/link/partial）
+	Host           string  `json:"host"`             // 正在连接的主机（IMAP服务器或链接域名）
+	BytesPerSecond float64 `json:"bytes_per_second"` // 当前速度（字节/秒）
+	DownloadedSize int64   `json:"downloaded_size"`  // 已下载字节数
+	TotalSize      int64   `json:"total_size"`       // 总字节数，未知时为0
+	StalledSeconds float64 `json:"stalled_seconds"`  // 距离上次收到数据的秒数，用于发现卡住的下载
+}
+
+// NetworkActivity 实时网络活动汇总
+type NetworkActivity struct {
+	Tasks               []TaskNetworkActivity `json:"tasks"`
+	ActiveTasks         int                   `json:"active_tasks"`           // 下载中的任务数
+	TotalBytesPerSecond float64               `json:"total_bytes_per_second"` // 总吞吐量（字节/秒）
+}
+
+// TelemetryEvent 匿名错误遥测事件（只包含分类信息，不含任何邮件内容或个人信息）
+type TelemetryEvent struct {
+	ID       uint   `json:"-"`
+	Source   string `json:"source"`   // 事件来源（链接下载/附件下载/邮件检查）
+	Category string `json:"category"` // 错误分类
+	Domain   string `json:"domain"`   // 服务商主域名
+	Date     string `json:"date"`     // 发生日期（仅精确到天）
+}
+
 // DownloadStatistics 下载统计
 type DownloadStatistics struct {
 	ID               uint   `json:"id"`
@@ -101,14 +554,33 @@ type DownloadStatistics struct {
 	TotalSize        int64  `json:"total_size"`       // 总下载大小
 	CreatedAt        string `json:"created_at"`
 	UpdatedAt        string `json:"updated_at"`
+
+	// 内容与已有文件相同、没有重复保存的下载数和大小
+	DeduplicatedDownloads int   `json:"deduplicated_downloads"`
+	DeduplicatedSize      int64 `json:"deduplicated_size"`
 }
 
+// TimestampLayout 模型中时间字符串的格式（本地时间）
+const TimestampLayout = "2006-01-02 15:04:05"
+
 // 辅助函数：time.Time 到 string 的转换
 func TimeToString(t time.Time) string {
 	if t.IsZero() {
 		return ""
 	}
-	return t.Format("2006-01-02 15:04:05")
+	return t.Format(TimestampLayout)
+}
+
+// SourcePreview 预览账户时发现的一个待下载文件，预览不会创建任务或修改邮件状态
+type SourcePreview struct {
+	MessageID string       `json:"message_id"` // 所在邮件的 Message-ID
+	Subject   string       `json:"subject"`    // 所在邮件的主题
+	Sender    string       `json:"sender"`     // 所在邮件的发件人
+	Type      DownloadType `json:"type"`       // attachment 或 link
+	Source    string       `json:"source"`     // 附件名称或链接URL
+	FileName  string       `json:"file_name"`  // 保存的文件名
+	FileSize  int64        `json:"file_size"`  // 预计大小（附件按邮件部分估算，链接为0表示未知）
+	LocalPath string       `json:"local_path"` // 预计的保存路径
 }
 
 // EmailCheckResult 邮件检查结果
@@ -118,12 +590,180 @@ type EmailCheckResult struct {
 	PDFsFound int           `json:"pdfs_found"`
 	Error     string        `json:"error,omitempty"`
 	Success   bool          `json:"success"`
+	Retries   int           `json:"retries"` // 选择收件箱和搜索阶段因临时性错误重试的次数
+}
+
+// BulkAccountResult 批量账户操作中单个账户的处理结果
+type BulkAccountResult struct {
+	ID      uint   `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 失败任务的错误分类
+const (
+	FailureAuth       = "auth"        // 认证失败（密码错误、无权访问）
+	FailureNetwork    = "network"     // 网络问题（连接失败、超时、证书）
+	FailureNotFound   = "not-found"   // 邮件、附件或链接不存在
+	FailureInvalidPDF = "invalid-pdf" // 下载的内容不是有效的PDF
+	FailureSize       = "size"        // 文件过大或磁盘空间不足
+	FailureOther      = "other"       // 其他错误
+)
+
+// FailureCategorySummary 一类失败原因的汇总
+type FailureCategorySummary struct {
+	Category string       `json:"category"` // 错误分类
+	Count    int          `json:"count"`    // 失败任务数
+	TaskIDs  []uint       `json:"task_ids"` // 该分类下的任务ID，用于筛选任务列表
+	Sample   DownloadTask `json:"sample"`   // 最近的一个失败任务，便于查看具体错误
+}
+
+// DefaultExtension 未配置附件类型时下载的文件扩展名
+const DefaultExtension = "pdf"
+
+// NormalizeExtensions 规范化扩展名列表：去掉空白和前导点、转为小写并去重
+func NormalizeExtensions(extensions []string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimLeft(strings.TrimSpace(ext), "."))
+		if ext == "" || seen[ext] {
+			continue
+		}
+		seen[ext] = true
+		result = append(result, ext)
+	}
+	return result
+}
+
+// EffectiveExtensions 返回实际下载的附件扩展名，未配置时只下载PDF
+func (c *AppConfig) EffectiveExtensions() []string {
+	extensions := NormalizeExtensions(c.AllowedExtensions)
+	if len(extensions) == 0 {
+		return []string{DefaultExtension}
+	}
+	return extensions
+}
+
+// ExtensionsFor 返回账户实际下载的附件扩展名，账户设置了附件类型时优先使用账户的设置
+func (c *AppConfig) ExtensionsFor(account *EmailAccount) []string {
+	if account != nil {
+		if extensions := NormalizeExtensions(account.AllowedExtensions); len(extensions) > 0 {
+			return extensions
+		}
+	}
+	return c.EffectiveExtensions()
+}
+
+// 月度报表和任务明细的导出格式
+const (
+	ReportFormatCSV  = "csv"  // CSV表格，月度报表按账户和发件人分行，任务明细每个任务一行
+	ReportFormatICal = "ics"  // iCalendar日历，每个账户一个当月汇总事件
+	ReportFormatJSON = "json" // JSON数组，只用于任务明细
+)
+
+// zonedTimestampLayouts 带时区的时间格式：RFC3339、SQLite驱动写入的格式以及 time.Time.String() 的格式
+var zonedTimestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999 -0700",
+}
+
+// localTimestampLayouts 不带时区的时间格式，按本地时间解析（与 TimeToString 一致）
+var localTimestampLayouts = []string{
+	TimestampLayout,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// Rule 下载规则：邮件主题、发件人或文件名匹配正则表达式时决定是否创建下载任务
+// 规则按顺序检查，第一条匹配的规则生效，没有规则匹配时正常下载
+type Rule struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	AccountID uint   `json:"account_id"` // 适用的邮箱账户，0表示全部账户
+	Field     string `json:"field"`      // 匹配的字段，见 RuleField* 常量
+	Pattern   string `json:"pattern"`    // 正则表达式，匹配时不区分大小写
+	Action    string `json:"action"`     // 匹配时的动作，见 RuleAction* 常量
+	Enabled   bool   `json:"enabled"`
+	Position  int    `json:"position"` // 规则顺序，保存时按列表顺序重新编号
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// 下载规则匹配的字段
+const (
+	RuleFieldSubject  = "subject"  // 邮件主题
+	RuleFieldSender   = "sender"   // 发件人地址
+	RuleFieldFileName = "filename" // 附件或链接的文件名
+)
+
+// 下载规则的动作
+const (
+	RuleActionSkip     = "skip"     // 不创建下载任务
+	RuleActionDownload = "download" // 创建下载任务，不再检查后面的规则
+)
+
+// FolderCheck 账户配置的扫描文件夹在服务器上的检查结果
+type FolderCheck struct {
+	Folder      string   `json:"folder"`      // 配置的文件夹（角色或路径）
+	Resolved    string   `json:"resolved"`    // 解析后的服务器文件夹名
+	Exists      bool     `json:"exists"`      // 服务器上是否存在
+	Suggestions []string `json:"suggestions"` // 不存在时名称相近的服务器文件夹
+}
+
+// 连接诊断的阶段
+const (
+	DiagnosticStageDNS   = "dns"
+	DiagnosticStageTCP   = "tcp"
+	DiagnosticStageTLS   = "tls"
+	DiagnosticStageLogin = "login"
+	DiagnosticStageInbox = "inbox"
+)
+
+// DiagnosticStage 连接诊断中单个阶段的结果
+type DiagnosticStage struct {
+	Attempted  bool   `json:"attempted"`   // 是否进行了该阶段，前面的阶段失败或该阶段不适用时为false
+	Success    bool   `json:"success"`     // 是否成功
+	DurationMs int64  `json:"duration_ms"` // 耗时（毫秒）
+	Detail     string `json:"detail"`      // 成功时的补充信息（如解析到的地址、认证方式），失败时为错误信息
+}
+
+// ConnectionDiagnostics 按连接顺序逐个阶段测试账户连接的结果，某一阶段失败后不再进行之后的阶段
+type ConnectionDiagnostics struct {
+	Server      string          `json:"server"`       // 测试的服务器地址（主机:端口）
+	DNS         DiagnosticStage `json:"dns"`          // 解析服务器地址
+	TCP         DiagnosticStage `json:"tcp"`          // 建立TCP连接
+	TLS         DiagnosticStage `json:"tls"`          // TLS握手，未使用SSL时不进行
+	Login       DiagnosticStage `json:"login"`        // 读取服务器问候并登录
+	Inbox       DiagnosticStage `json:"inbox"`        // 打开主文件夹
+	FailedStage string          `json:"failed_stage"` // 失败的阶段（dns、tcp、tls、login、inbox），全部成功时为空
 }
 
 // 辅助函数：string 到 time.Time 的转换
+// 除 TimeToString 的格式外，也接受RFC3339及带时区偏移的格式，迁移或手动修改后的数据同样可以解析
 func StringToTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
 	if s == "" {
 		return time.Time{}, nil
 	}
-	return time.Parse("2006-01-02 15:04:05", s)
+	// time.Time.String() 可能带有单调时钟读数
+	if i := strings.Index(s, " m="); i > 0 {
+		s = s[:i]
+	}
+
+	for _, layout := range zonedTimestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range localTimestampLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析时间: %q", s)
 } 
\ No newline at end of file
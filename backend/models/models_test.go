@@ -0,0 +1,64 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStringToTime(t *testing.T) {
+	want := time.Date(2024, 3, 5, 8, 30, 15, 0, time.UTC)
+	local := time.Date(2024, 3, 5, 8, 30, 15, 0, time.Local)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"TimeToString格式按本地时间", "2024-03-05 08:30:15", local},
+		{"RFC3339", "2024-03-05T08:30:15Z", want},
+		{"RFC3339带时区偏移", "2024-03-05T16:30:15+08:00", want},
+		{"RFC3339带小数秒", "2024-03-05T08:30:15.000Z", want},
+		{"SQLite驱动格式", "2024-03-05 08:30:15+00:00", want},
+		{"time.String格式", "2024-03-05 16:30:15 +0800 CST", want},
+		{"time.String带单调时钟", "2024-03-05 08:30:15 +0000 UTC m=+12.345678901", want},
+		{"ISO格式不带时区", "2024-03-05T08:30:15", local},
+		{"只到分钟", "2024-03-05 08:30", local.Add(-15 * time.Second)},
+		{"只有日期", "2024-03-05", time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)},
+		{"前后空白", "  2024-03-05 08:30:15\n", local},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StringToTime(tt.input)
+			if err != nil {
+				t.Fatalf("StringToTime(%q) 出错: %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("StringToTime(%q) = %v，期望 %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringToTimeEmptyAndInvalid(t *testing.T) {
+	if got, err := StringToTime(""); err != nil || !got.IsZero() {
+		t.Errorf("空字符串应返回零值，实际为 %v, %v", got, err)
+	}
+	if _, err := StringToTime("05/03/2024"); err == nil {
+		t.Error("无法识别的格式应返回错误")
+	}
+}
+
+func TestTimeToStringRoundTrip(t *testing.T) {
+	original := time.Date(2024, 12, 31, 23, 59, 59, 0, time.Local)
+
+	got, err := StringToTime(TimeToString(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(original) {
+		t.Errorf("往返转换后为 %v，期望 %v", got, original)
+	}
+	if TimeToString(time.Time{}) != "" {
+		t.Error("零值应转换为空字符串")
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// defaultAccountCheckTimeout 未配置时单个账户检查的期限
+	defaultAccountCheckTimeout = 5 * time.Minute
+	// MaxAccountCheckTimeoutMinutes 单个账户检查期限的上限（分钟）
+	MaxAccountCheckTimeoutMinutes = 60
+	// imapDialTimeout 建立IMAP连接（含TLS握手和服务器问候）的超时时间
+	imapDialTimeout = 30 * time.Second
+	// defaultCheckWorkers 未配置最大并发数时同时检查的账户数
+	defaultCheckWorkers = 3
+)
+
+// imapDialer 返回带超时的拨号器，避免服务器无响应时连接一直挂起
+func imapDialer() *net.Dialer {
+	return &net.Dialer{Timeout: imapDialTimeout}
+}
+
+// SetAccountCheckedHandler 设置每个账户检查结束（完成、失败或超时）时的回调
+func (es *EmailService) SetAccountCheckedHandler(handler func(models.EmailCheckResult)) {
+	es.onAccountChecked = handler
+}
+
+// accountCheckTimeout 获取配置的单个账户检查期限
+func (es *EmailService) accountCheckTimeout() time.Duration {
+	config, err := es.getDownloadConfig()
+	if err != nil || config.AccountCheckTimeoutMinutes <= 0 {
+		return defaultAccountCheckTimeout
+	}
+	if config.AccountCheckTimeoutMinutes > MaxAccountCheckTimeoutMinutes {
+		return MaxAccountCheckTimeoutMinutes * time.Minute
+	}
+	return time.Duration(config.AccountCheckTimeoutMinutes) * time.Minute
+}
+
+// checkAccountWithDeadline 在独立的期限内检查账户，结束后报告结果
+func (es *EmailService) checkAccountWithDeadline(account *models.EmailAccount, timeout time.Duration) {
+	result, ok := es.checkAccountBounded(account, timeout)
+	if ok && es.onAccountChecked != nil {
+		es.onAccountChecked(result)
+	}
+}
+
+// checkAccountBounded 在独立的期限内检查账户，超过期限时强制断开该账户的连接并返回超时结果
+// 上一次检查仍未结束的账户本次跳过，避免卡住的账户堆积检查；跳过或服务关闭时返回false
+func (es *EmailService) checkAccountBounded(account *models.EmailAccount, timeout time.Duration) (models.EmailCheckResult, bool) {
+	if _, busy := es.checkingAccounts.LoadOrStore(account.ID, struct{}{}); busy {
+		es.logger.Warnf("账户%d上一次检查尚未结束，跳过本次检查", account.ID)
+		return models.EmailCheckResult{}, false
+	}
+	
+	start := time.Now()
+	done := make(chan models.EmailCheckResult, 1)
+	go func() {
+		defer es.checkingAccounts.Delete(account.ID)
+		defer func() {
+			// 重试后仍未恢复的异常只让本账户本次检查失败，丢弃连接以便下次检查重新建立
+			if r := recover(); r != nil {
+				es.logger.Errorf("账户%d检查时发生异常: %v", account.ID, r)
+				es.abortConnection(account.ID)
+				done <- models.EmailCheckResult{
+					Account: account,
+					Error:   fmt.Sprintf("%v: %v", errIMAPPanic, r),
+				}
+			}
+		}()
+		done <- es.checkAccount(account)
+	}()
+	
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	
+	var result models.EmailCheckResult
+	select {
+	case result = <-done:
+		es.logger.Debugf("账户%d检查结束，用时 %v", account.ID, time.Since(start).Round(time.Millisecond))
+	case <-timer.C:
+		// 断开连接使卡住的IMAP命令返回错误，检查协程随之结束
+		es.logger.Warnf("账户%d检查超过期限（%v），已强制中止", account.ID, timeout)
+		es.abortConnection(account.ID)
+		result = models.EmailCheckResult{
+			Account: account,
+			Error:   fmt.Sprintf("检查超过期限（%v），已中止", timeout),
+		}
+	case <-es.ctx.Done():
+		es.abortConnection(account.ID)
+		return models.EmailCheckResult{}, false
+	}
+	return result, true
+}
+
+// checkWorkers 同时检查的账户数，沿用下载的最大并发数配置
+func (es *EmailService) checkWorkers() int {
+	config, err := es.getDownloadConfig()
+	if err != nil || config.MaxConcurrent <= 0 {
+		return defaultCheckWorkers
+	}
+	return config.MaxConcurrent
+}
+
+// CheckAccounts 以有限的并发数检查多个账户，结果顺序与账户顺序一致
+// 每个账户有独立的期限，卡住的账户超时后单独中止；失败、超时或跳过的账户只在自己的结果中记录错误
+func (es *EmailService) CheckAccounts(accounts []models.EmailAccount) []models.EmailCheckResult {
+	results := make([]models.EmailCheckResult, len(accounts))
+	if len(accounts) == 0 {
+		return results
+	}
+	
+	timeout := es.accountCheckTimeout()
+	workers := es.checkWorkers()
+	if workers > len(accounts) {
+		workers = len(accounts)
+	}
+	
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				account := &accounts[i]
+				result, ok := es.checkAccountBounded(account, timeout)
+				if !ok {
+					result = models.EmailCheckResult{
+						Account: account,
+						Error:   "账户正在检查中或服务正在关闭，已跳过本次检查",
+					}
+				}
+				results[i] = result
+			}
+		}()
+	}
+	
+	for i := range accounts {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	
+	es.logger.Infof("%d 个账户检查结束（并发数 %d）", len(accounts), workers)
+	return results
+}
+
+// abortConnection 立即断开并移除账户的缓存连接，不等待正在执行的命令释放连接锁
+func (es *EmailService) abortConnection(accountID uint) {
+	es.connectionsMutex.Lock()
+	conn, exists := es.connections[accountID]
+	if exists {
+		es.removeConnectionLocked(accountID)
+	}
+	es.connectionsMutex.Unlock()
+	
+	if !exists {
+		return
+	}
+	conn.terminate()
+	go conn.close()
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"emaild/backend/models"
+
+	"github.com/emersion/go-imap"
+)
+
+// previewConnectTimeout 预览时建立连接的期限
+const previewConnectTimeout = 30 * time.Second
+
+// PreviewAccount 预览检查账户时会下载哪些文件：按与后台检查相同的条件搜索未读邮件并分析附件和链接，
+// 跳过已处理过的邮件。使用独立连接只读打开文件夹并以 BODY.PEEK 获取内容，
+// 不会将邮件标记为已读，也不会保存邮件记录或创建下载任务
+func (es *EmailService) PreviewAccount(account *models.EmailAccount) ([]models.SourcePreview, error) {
+	config, err := es.getDownloadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("获取配置失败: %v", err)
+	}
+	since, err := HistorySince(config.CheckScope, config.CheckScopeDays, account)
+	if err != nil {
+		since, _ = HistorySince(models.HistoryScopeSinceAccountAdded, 0, account)
+	}
+	criteria, err := ParseSearchCriteria(account.SearchCriteria)
+	if err != nil {
+		return nil, fmt.Errorf("自定义搜索条件无效: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(es.ctx, previewConnectTimeout)
+	defer cancel()
+	conn, err := es.createConnectionWithTimeout(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("连接失败: %v", err)
+	}
+	defer conn.close()
+
+	mailbox := conn.mainMailbox()
+	if _, err := conn.selectFolder(mailbox, true); err != nil {
+		return nil, fmt.Errorf("打开文件夹 %s 失败: %v", mailbox, err)
+	}
+
+	messages, err := conn.previewMessages(criteria, since, scanWindowSince(config))
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件失败: %v", err)
+	}
+
+	previews := []models.SourcePreview{}
+	for _, msg := range messages {
+		messageID := ""
+		if msg.Envelope != nil {
+			messageID = msg.Envelope.MessageId
+		}
+		gmailMessageID, _ := gmailMessageIDs(msg)
+		if es.isMessageProcessed(account, messageID, gmailMessageID) {
+			continue
+		}
+
+		subject, sender := "", ""
+		if msg.Envelope != nil {
+			subject = msg.Envelope.Subject
+			if len(msg.Envelope.From) > 0 {
+				sender = msg.Envelope.From[0].Address()
+			}
+		}
+		for _, source := range es.analyzePDFSources(account, msg) {
+			previews = append(previews, models.SourcePreview{
+				MessageID: messageID,
+				Subject:   subject,
+				Sender:    sender,
+				Type:      source.Type,
+				Source:    source.Source,
+				FileName:  source.FileName,
+				FileSize:  source.FileSize,
+				LocalPath: source.LocalPath,
+			})
+		}
+	}
+
+	es.logger.Infof("账户%d预览: %d封邮件，%d个待下载文件", account.ID, len(messages), len(previews))
+	return previews, nil
+}
+
+// previewMessages 按后台检查的搜索方式查找邮件，使用PEEK获取内容
+// 自定义搜索条件优先，否则搜索未读邮件；数量限制与后台检查一致
+func (conn *IMAPConnection) previewMessages(criteria *imap.SearchCriteria, since, window time.Time) ([]*imap.Message, error) {
+	if criteria != nil {
+		var uids []uint32
+		err := conn.withLock(func() error {
+			if !conn.IsConnected {
+				return fmt.Errorf("连接已断开")
+			}
+			var err error
+			uids, err = conn.Client.UidSearch(criteria)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(uids) == 0 {
+			return nil, nil
+		}
+		if len(uids) > maxMessagesPerSearch {
+			uids = uids[len(uids)-maxMessagesPerSearch:]
+		}
+		return conn.fetchMessagesWithItems(uids, historyFetchItems)
+	}
+
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+
+	seqNums, err := conn.searchWithFallback(since, window)
+	if err != nil {
+		return nil, err
+	}
+	if len(seqNums) == 0 {
+		return nil, nil
+	}
+	if len(seqNums) > maxMessagesPerSearch {
+		seqNums = seqNums[:maxMessagesPerSearch]
+	}
+
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(seqNums...)
+	fetched := make(chan *imap.Message, len(seqNums))
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.Client.Fetch(seqset, conn.withGmailItems(historyFetchItems), fetched)
+	}()
+
+	var messages []*imap.Message
+	for msg := range fetched {
+		if msg.Uid != 0 && conn.isMessageUnread(msg) {
+			messages = append(messages, msg)
+		}
+	}
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("获取邮件详情失败: %v", err)
+	}
+	return messages, nil
+}
@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// defaultAttachmentConnectionsPerAccount 每个账户同时用于获取附件的连接数上限，配置的IMAP连接数上限更小时按配置减少
+	defaultAttachmentConnectionsPerAccount = 3
+	// attachmentConnectionIdleTimeout 附件连接空闲超过该时间后关闭
+	attachmentConnectionIdleTimeout = 2 * time.Minute
+)
+
+// attachmentLimitRetryDelay 账户没有附件连接、服务器仍因连接数过多拒绝登录时，等待检查连接释放后重试的间隔
+var attachmentLimitRetryDelay = 5 * time.Second
+
+// connectionLimitErrors 服务器因同一账户连接数过多拒绝登录时的错误信息（小写）
+var connectionLimitErrors = []string{
+	"too many simultaneous connections",
+	"too many connections",
+	"too many concurrent connections",
+	"maximum number of connections",
+	"max_userip_connections",
+	"connection limit",
+	"[limit]",
+}
+
+// isConnectionLimitError 判断登录失败是否因为账户的连接数超过服务器限制
+func isConnectionLimitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, pattern := range connectionLimitErrors {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachmentPool 按账户复用获取附件的IMAP连接，同一账户的多个附件可以并行获取
+// 服务器拒绝额外登录时，该账户的连接数上限降为已建立的连接数，最少保留一个连接
+type attachmentPool struct {
+	ds       *DownloadService
+	dial     func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) // 建立新连接
+	mu       sync.Mutex
+	accounts map[uint]*accountAttachmentConns
+	closed   bool
+}
+
+// accountAttachmentConns 单个账户的附件连接，由 attachmentPool.mu 保护
+type accountAttachmentConns struct {
+	limit    int                          // 服务器允许的连接数上限，拒绝登录后降低
+	open     map[*IMAPConnection]struct{} // 已建立且未关闭的连接（含使用中和空闲的）
+	idle     []*IMAPConnection            // 空闲连接，最近放回的在最后
+	dialing  int                          // 正在建立的连接数
+	released chan struct{}                // 有连接空闲或关闭时关闭并替换，唤醒等待连接的任务
+}
+
+// newAttachmentPool 创建附件连接池，连接数达到全局上限时空闲的附件连接可被回收
+func newAttachmentPool(ds *DownloadService) *attachmentPool {
+	p := &attachmentPool{ds: ds, accounts: make(map[uint]*accountAttachmentConns)}
+	p.dial = func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+		return ds.createEmailServiceForDownload(ctx).createConnectionWithTimeout(ctx, account)
+	}
+	ds.connLimiter.addReclaimer(p.reclaimIdle)
+	return p
+}
+
+// configuredLimit 按配置的IMAP连接数上限计算每个账户的附件连接数，为该账户的检查连接留出一个名额
+func (p *attachmentPool) configuredLimit() int {
+	config, err := p.ds.db.GetConfig()
+	if err != nil || config.MaxIMAPConnections <= 0 {
+		return defaultAttachmentConnectionsPerAccount
+	}
+	return max(1, min(defaultAttachmentConnectionsPerAccount, config.MaxIMAPConnections-1))
+}
+
+// signal 唤醒等待该账户连接的任务，调用方需持有 attachmentPool.mu
+func (ac *accountAttachmentConns) signal() {
+	close(ac.released)
+	ac.released = make(chan struct{})
+}
+
+// accountLocked 返回账户的连接记录，不存在时创建，调用方需持有 mu
+func (p *attachmentPool) accountLocked(accountID uint) *accountAttachmentConns {
+	ac, exists := p.accounts[accountID]
+	if !exists {
+		ac = &accountAttachmentConns{
+			limit:    defaultAttachmentConnectionsPerAccount,
+			open:     make(map[*IMAPConnection]struct{}),
+			released: make(chan struct{}),
+		}
+		p.accounts[accountID] = ac
+	}
+	return ac
+}
+
+// acquire 取得账户的附件连接：优先复用空闲连接，未达到上限时建立新连接，否则等待其他任务放回连接
+// 账户还没有附件连接时服务器就拒绝登录，说明连接被该账户的检查连接等占用，稍后重试直到等待超时
+func (p *attachmentPool) acquire(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+	configured := p.configuredLimit()
+	var retryUntil time.Time
+
+	for {
+		var retry <-chan time.Time
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("下载服务已停止")
+		}
+		ac := p.accountLocked(account.ID)
+
+		if n := len(ac.idle); n > 0 {
+			conn := ac.idle[n-1]
+			ac.idle = ac.idle[:n-1]
+			p.mu.Unlock()
+			if conn.isAlive() {
+				return conn, nil
+			}
+			p.release(conn, false)
+			continue
+		}
+
+		if len(ac.open)+ac.dialing < min(ac.limit, configured) {
+			ac.dialing++
+			p.mu.Unlock()
+
+			conn, err := p.dial(ctx, account)
+
+			p.mu.Lock()
+			ac.dialing--
+			if err == nil {
+				ac.open[conn] = struct{}{}
+				p.mu.Unlock()
+				return conn, nil
+			}
+			if isConnectionLimitError(err) && len(ac.open) > 0 {
+				// 服务器不允许更多连接，之后只使用已建立的连接
+				if ac.limit > len(ac.open) {
+					ac.limit = len(ac.open)
+					p.ds.logger.Warnf("账户%d的服务器拒绝更多IMAP连接，附件下载连接数上限调整为 %d: %v", account.ID, ac.limit, err)
+				}
+				p.mu.Unlock()
+				continue
+			}
+			if isConnectionLimitError(err) && len(ac.open) == 0 {
+				if retryUntil.IsZero() {
+					retryUntil = time.Now().Add(connectionSlotTimeout)
+				}
+				if time.Now().Before(retryUntil) {
+					// 先只尝试一个连接，其他任务等待该连接建立后复用
+					ac.limit = 1
+					p.ds.logger.Warnf("账户%d的服务器拒绝附件连接，%v后重试: %v", account.ID, attachmentLimitRetryDelay, err)
+					retry = time.After(attachmentLimitRetryDelay)
+				}
+			}
+			if retry == nil {
+				ac.signal()
+				p.mu.Unlock()
+				return nil, err
+			}
+		}
+
+		released := ac.released
+		p.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-retry:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待账户空闲连接时中止: %v", ctx.Err())
+		}
+	}
+}
+
+// release 归还连接：reusable 为 true 时放回连接池供后续任务复用，否则关闭连接
+// 同一连接可以重复归还，已关闭或不属于连接池的连接只会被关闭
+func (p *attachmentPool) release(conn *IMAPConnection, reusable bool) {
+	if conn == nil {
+		return
+	}
+
+	p.mu.Lock()
+	ac := p.accounts[conn.ID]
+	if ac == nil {
+		p.mu.Unlock()
+		conn.close()
+		return
+	}
+	if _, exists := ac.open[conn]; !exists {
+		p.mu.Unlock()
+		conn.close()
+		return
+	}
+	if reusable && !p.closed {
+		conn.touch()
+		ac.idle = append(ac.idle, conn)
+		ac.signal()
+		p.mu.Unlock()
+		p.ds.connLimiter.signal()
+		return
+	}
+	delete(ac.open, conn)
+	ac.signal()
+	p.mu.Unlock()
+	conn.close()
+}
+
+// run 定期关闭空闲过久的连接，服务停止时关闭全部空闲连接
+func (p *attachmentPool) run() {
+	defer p.ds.wg.Done()
+
+	ticker := time.NewTicker(attachmentConnectionIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.closeIdle(time.Now().Add(-attachmentConnectionIdleTimeout))
+		case <-p.ds.ctx.Done():
+			p.mu.Lock()
+			p.closed = true
+			p.mu.Unlock()
+			p.closeIdle(time.Now())
+			return
+		}
+	}
+}
+
+// closeIdle 关闭在 cutoff 之前放回的空闲连接，使用中的连接由任务结束时关闭
+func (p *attachmentPool) closeIdle(cutoff time.Time) {
+	var expired []*IMAPConnection
+
+	p.mu.Lock()
+	for accountID, ac := range p.accounts {
+		kept := ac.idle[:0]
+		for _, conn := range ac.idle {
+			if conn.lastUsedAt().After(cutoff) {
+				kept = append(kept, conn)
+				continue
+			}
+			delete(ac.open, conn)
+			expired = append(expired, conn)
+		}
+		ac.idle = kept
+		if len(ac.open) == 0 && ac.dialing == 0 {
+			// 没有连接的账户重新按默认上限尝试，服务器的限制可能已经变化
+			delete(p.accounts, accountID)
+			ac.signal()
+		}
+	}
+	p.mu.Unlock()
+
+	for _, conn := range expired {
+		conn.close()
+	}
+}
+
+// reclaimIdle 关闭最久未使用的空闲附件连接，为其他IMAP连接腾出名额；没有空闲连接时返回false
+func (p *attachmentPool) reclaimIdle() bool {
+	p.mu.Lock()
+	var (
+		victim *IMAPConnection
+		owner  *accountAttachmentConns
+		index  int
+	)
+	for _, ac := range p.accounts {
+		for i, conn := range ac.idle {
+			if victim == nil || conn.lastUsedAt().Before(victim.lastUsedAt()) {
+				victim, owner, index = conn, ac, i
+			}
+		}
+	}
+	if victim == nil {
+		p.mu.Unlock()
+		return false
+	}
+	owner.idle = append(owner.idle[:index], owner.idle[index+1:]...)
+	delete(owner.open, victim)
+	owner.signal()
+	p.mu.Unlock()
+
+	victim.close()
+	return true
+}
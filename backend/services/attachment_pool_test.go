@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// newTestAttachmentPool 创建按 dial 建立连接的附件连接池，maxConnections 为配置的IMAP连接数上限
+func newTestAttachmentPool(t *testing.T, maxConnections int, dial func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error)) *attachmentPool {
+	t.Helper()
+
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.MaxIMAPConnections = maxConnections
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	ds := &DownloadService{db: db, logger: newTestLogger()}
+	pool := newAttachmentPool(ds)
+	pool.dial = dial
+	return pool
+}
+
+// TestAttachmentPoolLimitFromConfig 每个账户的附件连接数按配置的IMAP连接数上限减少，为检查连接留出名额
+func TestAttachmentPoolLimitFromConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConnections int
+		want           int
+	}{
+		{name: "不限制时使用默认值", maxConnections: 0, want: defaultAttachmentConnectionsPerAccount},
+		{name: "上限较大时使用默认值", maxConnections: 10, want: defaultAttachmentConnectionsPerAccount},
+		{name: "留出检查连接", maxConnections: 3, want: 2},
+		{name: "至少一个连接", maxConnections: 1, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dials := 0
+			pool := newTestAttachmentPool(t, tt.maxConnections, func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+				dials++
+				return &IMAPConnection{ID: account.ID, IsConnected: true}, nil
+			})
+			account := &models.EmailAccount{ID: 1}
+
+			for i := 0; i < tt.want; i++ {
+				if _, err := pool.acquire(context.Background(), account); err != nil {
+					t.Fatalf("获取第 %d 个连接失败: %v", i+1, err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := pool.acquire(ctx, account); err == nil {
+				t.Fatalf("达到 %d 个连接后应等待其他任务放回连接", tt.want)
+			}
+			if dials != tt.want {
+				t.Errorf("建立了 %d 个连接，期望 %d 个", dials, tt.want)
+			}
+		})
+	}
+}
+
+// TestAttachmentPoolRetriesFirstConnection 账户还没有附件连接时服务器因连接数过多拒绝登录，等待后重试而不是直接失败
+func TestAttachmentPoolRetriesFirstConnection(t *testing.T) {
+	delay := attachmentLimitRetryDelay
+	attachmentLimitRetryDelay = 10 * time.Millisecond
+	defer func() { attachmentLimitRetryDelay = delay }()
+
+	dials := 0
+	pool := newTestAttachmentPool(t, 0, func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+		dials++
+		if dials < 3 {
+			return nil, errors.New("IMAP登录失败: [LIMIT] Too many simultaneous connections")
+		}
+		return &IMAPConnection{ID: account.ID, IsConnected: true}, nil
+	})
+
+	conn, err := pool.acquire(context.Background(), &models.EmailAccount{ID: 1})
+	if err != nil {
+		t.Fatalf("检查连接释放后应能建立附件连接: %v", err)
+	}
+	if conn == nil || dials != 3 {
+		t.Errorf("尝试建立连接 %d 次，期望 3 次", dials)
+	}
+
+	// 其他错误不重试
+	dials = 0
+	pool.dial = func(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+		dials++
+		return nil, errors.New("IMAP登录失败: authentication failed")
+	}
+	if _, err := pool.acquire(context.Background(), &models.EmailAccount{ID: 2}); err == nil || dials != 1 {
+		t.Errorf("登录失败时应直接返回错误，尝试了 %d 次（%v）", dials, err)
+	}
+}
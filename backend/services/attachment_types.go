@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+
+	"github.com/emersion/go-imap"
+)
+
+// fileExtension 返回小写的扩展名（不含点），没有扩展名时返回空字符串
+func fileExtension(fileName string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(strings.TrimSpace(fileName)), "."))
+}
+
+// isPDFTarget 目标文件是否按PDF处理（没有扩展名的附件也按PDF处理）
+func isPDFTarget(fileName string) bool {
+	ext := fileExtension(fileName)
+	return ext == "" || ext == models.DefaultExtension
+}
+
+// savedExtensions 保存文件时保留的扩展名：账户或全局允许的附件类型，开启ZIP解压时还包括zip
+func savedExtensions(config *models.AppConfig, account *models.EmailAccount) []string {
+	extensions := config.ExtensionsFor(account)
+	if config.ExtractZipAttachments {
+		extensions = append(extensions, "zip")
+	}
+	return extensions
+}
+
+// taskExtensions 返回任务保存文件时保留的扩展名，读取配置失败时只保留PDF
+func (ds *DownloadService) taskExtensions(task *models.DownloadTask) []string {
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return []string{models.DefaultExtension}
+	}
+	account, err := ds.db.GetEmailAccountByID(task.EmailID)
+	if err != nil {
+		account = nil
+	}
+	return savedExtensions(&config, account)
+}
+
+// attachmentFilter 根据配置判断邮件中的哪些附件需要下载
+type attachmentFilter struct {
+	extensions map[string]bool // 允许的扩展名
+	extractZip bool            // 开启ZIP解压时ZIP附件总是下载
+}
+
+// NormalizeAccountExtensions 校验账户的附件类型设置，空列表表示使用全局设置
+func NormalizeAccountExtensions(extensions []string) ([]string, error) {
+	extensions = models.NormalizeExtensions(extensions)
+	for _, ext := range extensions {
+		if !utils.IsValidExtension(ext) {
+			return nil, fmt.Errorf("账户附件类型无效: %s（只能包含字母和数字）", ext)
+		}
+	}
+	return extensions, nil
+}
+
+// newAttachmentFilter 按账户或全局配置的附件类型创建过滤器，都未配置时只下载PDF
+func newAttachmentFilter(config *models.AppConfig, account *models.EmailAccount) attachmentFilter {
+	filter := attachmentFilter{
+		extensions: make(map[string]bool),
+		extractZip: config.ExtractZipAttachments,
+	}
+	for _, ext := range config.ExtensionsFor(account) {
+		filter.extensions[ext] = true
+	}
+	return filter
+}
+
+// matches 判断附件部分是否需要下载（MIME类型需为小写）
+// 有扩展名的附件按扩展名判断；没有扩展名的附件沿用原来的PDF判断（PDF或通用二进制类型）
+func (f attachmentFilter) matches(mimeType, mimeSubType, fileName string) bool {
+	if isZipPart(mimeType, mimeSubType, fileName) {
+		return f.extractZip || f.extensions["zip"]
+	}
+
+	if ext := fileExtension(fileName); ext != "" {
+		if f.extensions[ext] {
+			return true
+		}
+		// 文件名扩展名不规范但声明为PDF的附件
+		return f.extensions[models.DefaultExtension] && mimeType == "application" && mimeSubType == "pdf"
+	}
+
+	return f.extensions[models.DefaultExtension] && mimeType == "application" &&
+		(mimeSubType == "pdf" || mimeSubType == "octet-stream" || mimeSubType == "binary")
+}
+
+// partMatchesTargetType 下载时判断邮件部分的类型是否与任务的目标文件一致
+// ZIP任务只匹配ZIP部分，PDF任务匹配PDF部分（不含ZIP），其他类型按扩展名匹配
+func (ds *DownloadService) partMatchesTargetType(bs *imap.BodyStructure, fileName, targetFileName string) bool {
+	isZip := isZipPart(strings.ToLower(bs.MIMEType), strings.ToLower(bs.MIMESubType), fileName)
+	switch {
+	case isZipFileName(targetFileName):
+		return isZip
+	case isPDFTarget(targetFileName):
+		return !isZip && ds.isPDFPart(bs)
+	default:
+		return fileExtension(fileName) == fileExtension(targetFileName)
+	}
+}
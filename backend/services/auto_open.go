@@ -0,0 +1,99 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/skratchdot/open-golang/open"
+)
+
+const (
+	// autoOpenDigestWindow 第一个下载完成后等待的汇总时间，窗口内完成的下载合并为一批处理
+	autoOpenDigestWindow = 5 * time.Second
+	// maxAutoOpenFiles 每批最多自动打开的文件数，超过时改为打开所在目录
+	maxAutoOpenFiles = 3
+	// maxAutoOpenFolders 每批最多自动打开的目录数
+	maxAutoOpenFolders = 3
+)
+
+// autoOpener 下载完成后按配置自动打开文件或所在目录
+// 同一汇总窗口内完成的下载合并处理，避免批量下载时弹出大量窗口
+type autoOpener struct {
+	ds      *DownloadService
+	mutex   sync.Mutex
+	pending []string    // 当前窗口内完成的文件路径
+	timer   *time.Timer // 当前窗口的计时器，为nil表示没有等待中的批次
+}
+
+// newAutoOpener 创建自动打开处理器
+func newAutoOpener(ds *DownloadService) *autoOpener {
+	return &autoOpener{ds: ds}
+}
+
+// add 记录一个完成的下载，窗口结束后统一处理
+func (o *autoOpener) add(path string) {
+	if path == "" {
+		return
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.pending = append(o.pending, path)
+	if o.timer == nil {
+		o.timer = time.AfterFunc(autoOpenDigestWindow, o.flush)
+	}
+}
+
+// flush 处理当前窗口内完成的下载
+func (o *autoOpener) flush() {
+	o.mutex.Lock()
+	files := o.pending
+	o.pending = nil
+	o.timer = nil
+	o.mutex.Unlock()
+
+	if len(files) == 0 || o.ds.ctx.Err() != nil {
+		return
+	}
+
+	config, err := o.ds.db.GetConfig()
+	if err != nil || (!config.AutoOpenFile && !config.AutoOpenFolder) {
+		return
+	}
+
+	// 文件较少时逐个打开；一批完成的文件过多时只打开所在目录
+	openFiles := config.AutoOpenFile && len(files) <= maxAutoOpenFiles
+	if openFiles {
+		for _, file := range files {
+			o.run(file)
+		}
+	}
+
+	if config.AutoOpenFolder || (config.AutoOpenFile && !openFiles) {
+		var folders []string
+		seen := make(map[string]bool)
+		for _, file := range files {
+			dir := filepath.Dir(file)
+			if !seen[dir] {
+				seen[dir] = true
+				folders = append(folders, dir)
+			}
+		}
+		if len(folders) > maxAutoOpenFolders {
+			o.ds.logger.Infof("本批 %d 个下载分布在 %d 个目录中，只自动打开前 %d 个", len(files), len(folders), maxAutoOpenFolders)
+			folders = folders[:maxAutoOpenFolders]
+		}
+		for _, folder := range folders {
+			o.run(folder)
+		}
+	}
+}
+
+// run 使用系统默认程序打开文件或目录
+func (o *autoOpener) run(path string) {
+	if err := open.Run(path); err != nil {
+		o.ds.logger.Warnf("自动打开 %s 失败: %v", path, err)
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// cancelAllWaitTimeout 全部取消时等待正在下载的工作者退出的最长时间
+const cancelAllWaitTimeout = 10 * time.Second
+
+// CancelAllDownloads 取消所有等待中和下载中的任务，返回取消的任务数
+// 任务状态在同一事务中更新；正在下载的任务由工作者结束时删除临时文件并写入最终状态，
+// 在取消前已经下载完成的任务保留完成状态和文件，不计入取消的任务数
+func (ds *DownloadService) CancelAllDownloads() (int, error) {
+	tasks, err := ds.db.CancelActiveTasks()
+	if err != nil {
+		return 0, fmt.Errorf("取消全部任务失败: %v", err)
+	}
+	if len(tasks) == 0 {
+		return 0, nil
+	}
+
+	cancelled := make(map[uint]bool, len(tasks))
+	for _, task := range tasks {
+		cancelled[task.ID] = true
+	}
+
+	// 停止正在下载的任务，工作者结束时不算失败也不自动重试
+	running := make(map[uint]*DownloadWorker)
+	ds.workerMutex.RLock()
+	for taskID, worker := range ds.workers {
+		if cancelled[taskID] {
+			worker.cancelled.Store(true)
+			worker.Cancel()
+			running[taskID] = worker
+		}
+	}
+	ds.workerMutex.RUnlock()
+
+	// 从调度器的待处理队列中移出；仍在任务通道中的任务启动时会因已取消而跳过
+	select {
+	case ds.cancelledTasks <- cancelled:
+	case <-ds.ctx.Done():
+	}
+
+	for _, task := range tasks {
+		if running[task.ID] != nil {
+			continue
+		}
+		// 删除暂停或失败时保留的临时文件，去重任务使用其他任务的文件，不删除
+		if task.LocalPath != "" && task.DuplicateOf == 0 {
+			if err := os.Remove(task.LocalPath + tempFileSuffix); err != nil && !os.IsNotExist(err) {
+				ds.logger.Warnf("删除临时文件失败 %s: %v", task.LocalPath+tempFileSuffix, err)
+			}
+		}
+		ds.logStatusChange(task.ID, models.StatusCancelled, "")
+		ds.refreshMessageExtraction(task.ID)
+	}
+
+	kept := ds.waitCancelledWorkers(running)
+	ds.logger.Infof("已取消全部任务，共 %d 个，其中 %d 个正在下载", len(tasks)-kept, len(running)-kept)
+	return len(tasks) - kept, nil
+}
+
+// waitCancelledWorkers 等待被取消的工作者写入最终状态后退出，返回在取消前已下载完成、未保持取消的任务数
+// 超时仍未退出的工作者按已取消计算
+func (ds *DownloadService) waitCancelledWorkers(running map[uint]*DownloadWorker) int {
+	timeout := time.NewTimer(cancelAllWaitTimeout)
+	defer timeout.Stop()
+
+	kept := 0
+	for taskID, worker := range running {
+		select {
+		case <-worker.done:
+		case <-timeout.C:
+			ds.logger.Warnf("等待正在下载的任务退出超时，按已取消计算")
+			return kept
+		case <-ds.ctx.Done():
+			return kept
+		}
+		task, err := ds.db.GetDownloadTaskByID(taskID)
+		if err != nil {
+			ds.logger.Warnf("读取任务 %d 失败: %v", taskID, err)
+			continue
+		}
+		if task.Status != models.StatusCancelled {
+			ds.logger.Infof("任务 %d 在取消前已结束，保留%s状态", taskID, task.Status)
+			kept++
+		}
+	}
+	return kept
+}
+
+// finishCancelledTask 写入被取消或作为重复来源跳过的工作者的最终状态，返回任务是否保持已取消
+// 之后被重新排队的任务不会被改回已取消；下载函数已写入的完成状态从统计中扣除
+func (ds *DownloadService) finishCancelledTask(taskID uint, errorMsg string) (bool, error) {
+	var cancelled bool
+	err := ds.db.WithRetry(func() error {
+		var err error
+		cancelled, err = ds.db.FinishCancelledTask(taskID, errorMsg)
+		return err
+	}, 3)
+	if err != nil {
+		return false, fmt.Errorf("更新任务状态失败: %v", err)
+	}
+	if cancelled {
+		ds.logStatusChange(taskID, models.StatusCancelled, errorMsg)
+		ds.recordTaskStatistics(taskID)
+		ds.refreshMessageExtraction(taskID)
+	}
+	return cancelled, nil
+}
+
+// discardQueuedRetries 放弃已排入重试队列、调度器尚未记录的自动重试，由调度器在全部取消时调用
+func (ds *DownloadService) discardQueuedRetries() {
+	for {
+		select {
+		case <-ds.retryQueue:
+		default:
+			return
+		}
+	}
+}
+
+// dropCancelledTasks 从待处理队列中移除已取消的任务，并清除它们的等待记录
+func dropCancelledTasks(pending []*models.DownloadTask, cancelled map[uint]bool, heldBySchedule map[uint]bool, enqueuedAt map[uint]time.Time) []*models.DownloadTask {
+	var kept []*models.DownloadTask
+	for _, task := range pending {
+		if cancelled[task.ID] {
+			delete(heldBySchedule, task.ID)
+			delete(enqueuedAt, task.ID)
+			continue
+		}
+		kept = append(kept, task)
+	}
+	return kept
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"emaild/backend/models"
+)
+
+// TestFinishCancelledTask 工作者的最终取消状态不覆盖之后重新排队的任务
+func TestFinishCancelledTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		status models.DownloadStatus
+		want   models.DownloadStatus
+	}{
+		{name: "已取消", status: models.StatusCancelled, want: models.StatusCancelled},
+		{name: "排队的进度更新改回下载中", status: models.StatusDownloading, want: models.StatusCancelled},
+		{name: "下载函数已写入完成", status: models.StatusCompleted, want: models.StatusCancelled},
+		{name: "取消后重新排队", status: models.StatusPending, want: models.StatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+			task := &models.DownloadTask{Subject: "账单", FileName: "a.pdf", Status: tt.status, Type: models.TypeAttachment}
+			createTestTask(t, db, task)
+
+			if err := (dbProgressReporter{ds: ds}).Report(ProgressUpdate{TaskID: task.ID, Status: models.StatusCancelled, Final: true}); err != nil {
+				t.Fatalf("写入最终状态失败: %v", err)
+			}
+			got, err := db.GetDownloadTaskByID(task.ID)
+			if err != nil {
+				t.Fatalf("读取任务失败: %v", err)
+			}
+			if got.Status != tt.want {
+				t.Errorf("任务状态为 %s，期望 %s", got.Status, tt.want)
+			}
+		})
+	}
+}
+
+// TestWaitCancelledWorkers 全部取消只计算工作者退出后仍为已取消的任务
+func TestWaitCancelledWorkers(t *testing.T) {
+	db := newTestDatabase(t)
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+
+	running := make(map[uint]*DownloadWorker)
+	for _, status := range []models.DownloadStatus{models.StatusCancelled, models.StatusCompleted} {
+		task := &models.DownloadTask{Subject: "账单", FileName: string(status) + ".pdf", Status: status, Type: models.TypeAttachment}
+		createTestTask(t, db, task)
+		worker := &DownloadWorker{ID: task.ID, done: make(chan struct{})}
+		close(worker.done)
+		running[task.ID] = worker
+	}
+
+	if kept := ds.waitCancelledWorkers(running); kept != 1 {
+		t.Errorf("有 %d 个任务未保持取消，期望只有取消前已完成的 1 个", kept)
+	}
+}
+
+// TestDiscardQueuedRetries 全部取消时放弃重试队列中尚未被调度器记录的自动重试
+func TestDiscardQueuedRetries(t *testing.T) {
+	ds := &DownloadService{retryQueue: make(chan taskRetry, 10)}
+	for i := 0; i < 3; i++ {
+		ds.retryQueue <- taskRetry{taskID: uint(i + 1)}
+	}
+
+	ds.discardQueuedRetries()
+	if len(ds.retryQueue) != 0 {
+		t.Errorf("重试队列中仍有 %d 个任务", len(ds.retryQueue))
+	}
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"emaild/backend/models"
+)
+
+// ErrCertificateChanged 服务器证书与账户记录的指纹不一致
+var ErrCertificateChanged = errors.New("IMAP服务器证书已变更，可能存在中间人攻击")
+
+// certPin 在TLS握手中记录服务器证书指纹，并按证书固定模式与账户保存的指纹比对
+type certPin struct {
+	mode        string
+	expected    string // 账户已保存的指纹，为空表示尚未记录
+	fingerprint string // 本次握手得到的指纹
+}
+
+// certFingerprint 计算证书的SHA-256指纹（大写十六进制，冒号分隔）
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// newCertPin 根据配置创建账户的证书检查，未开启证书固定时返回 nil
+func (es *EmailService) newCertPin(account *models.EmailAccount) *certPin {
+	config, err := es.getDownloadConfig()
+	if err != nil || config.CertPinningMode == "" || config.CertPinningMode == models.CertPinningOff {
+		return nil
+	}
+	return &certPin{mode: config.CertPinningMode, expected: account.CertFingerprint}
+}
+
+// changed 本次握手的证书是否与已保存的指纹不同
+func (p *certPin) changed() bool {
+	return p.expected != "" && p.fingerprint != "" && !strings.EqualFold(p.expected, p.fingerprint)
+}
+
+// verifyConnection 作为 tls.Config.VerifyConnection 使用，跳过证书链验证时同样会被调用
+// enforce 模式下证书变更会直接中止握手，不会向服务器发送登录凭据
+func (p *certPin) verifyConnection(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	p.fingerprint = certFingerprint(state.PeerCertificates[0])
+
+	if p.mode == models.CertPinningEnforce && p.changed() {
+		return fmt.Errorf("%w（已记录: %s，当前: %s）", ErrCertificateChanged, p.expected, p.fingerprint)
+	}
+	return nil
+}
+
+// recordCertificate 处理握手后的证书指纹：首次连接时保存，证书变更时记录待确认的新指纹
+func (es *EmailService) recordCertificate(account *models.EmailAccount, pin *certPin) {
+	// 测试连接时账户尚未保存
+	if pin == nil || pin.fingerprint == "" || account.ID == 0 {
+		return
+	}
+
+	if pin.expected == "" {
+		if err := es.db.SetCertFingerprint(account.ID, pin.fingerprint); err != nil {
+			es.logger.Warnf("保存账户 %s 的证书指纹失败: %v", account.Email, err)
+			return
+		}
+		account.CertFingerprint = pin.fingerprint
+		es.logger.Infof("已记录账户 %s 的服务器证书指纹: %s", account.Email, pin.fingerprint)
+		return
+	}
+
+	if !pin.changed() || account.PendingCertFingerprint == pin.fingerprint {
+		return
+	}
+
+	if err := es.db.SetPendingCertFingerprint(account.ID, pin.fingerprint); err != nil {
+		es.logger.Warnf("保存账户 %s 的新证书指纹失败: %v", account.Email, err)
+	}
+	account.PendingCertFingerprint = pin.fingerprint
+	es.logger.Warnf("账户 %s 的服务器证书已变更（已记录: %s，当前: %s），确认无误后请接受新证书",
+		account.Email, pin.expected, pin.fingerprint)
+}
+
+// AcceptNewCertificate 将账户待确认的新证书指纹设为信任的指纹
+func (es *EmailService) AcceptNewCertificate(accountID uint) error {
+	account, err := es.getAccountByID(accountID)
+	if err != nil {
+		return fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	if account.PendingCertFingerprint == "" {
+		return fmt.Errorf("账户 %s 没有待确认的新证书", account.Email)
+	}
+
+	if err := es.db.SetCertFingerprint(accountID, account.PendingCertFingerprint); err != nil {
+		return fmt.Errorf("保存证书指纹失败: %v", err)
+	}
+
+	// 旧连接使用的是变更前的证书判断，重新连接
+	es.dropConnection(accountID)
+	es.logger.Infof("账户 %s 已接受新的服务器证书: %s", account.Email, account.PendingCertFingerprint)
+	return nil
+}
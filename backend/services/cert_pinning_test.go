@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// newTestCertificate 生成 127.0.0.1 的自签名证书
+func newTestCertificate(t *testing.T) *tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("生成密钥失败: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("生成证书失败: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("解析证书失败: %v", err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// TestCertPinningEnforceRefusesChangedCertificate 服务器更换证书后，enforce 模式拒绝连接且不发送登录凭据
+func TestCertPinningEnforceRefusesChangedCertificate(t *testing.T) {
+	original, replaced := newTestCertificate(t), newTestCertificate(t)
+	var current atomic.Pointer[tls.Certificate]
+	current.Store(original)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return current.Load(), nil },
+	})
+	if err != nil {
+		t.Fatalf("启动测试服务器失败: %v", err)
+	}
+	defer listener.Close()
+
+	var logins atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeIMAP(conn, "OK", "", func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool {
+				if cmd.Name == "LOGIN" || cmd.Name == "AUTHENTICATE" {
+					logins.Add(1)
+				}
+				return false
+			})
+		}
+	}()
+
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.CertPinningMode = models.CertPinningEnforce
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	account := &models.EmailAccount{Name: "test", Email: "user@example.com", Password: "secret", IMAPServer: "127.0.0.1", IMAPPort: port, UseSSL: true, IsActive: true}
+	if err := db.CreateEmailAccount(account); err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+	es := NewEmailService(db, nil, newTestLogger())
+
+	// 首次连接记录证书指纹
+	conn, err := es.dialConnection(context.Background(), account)
+	if err != nil {
+		t.Fatalf("首次连接失败: %v", err)
+	}
+	conn.close()
+	stored, err := db.GetEmailAccountByID(account.ID)
+	if err != nil {
+		t.Fatalf("读取账户失败: %v", err)
+	}
+	if stored.CertFingerprint != certFingerprint(original.Leaf) {
+		t.Fatalf("记录的指纹为 %q，期望首次连接时的证书指纹", stored.CertFingerprint)
+	}
+
+	// 服务器更换证书后拒绝连接，即使跳过证书链验证也不退回宽松模式
+	current.Store(replaced)
+	loginsBefore := logins.Load()
+	if _, err := es.dialConnection(context.Background(), stored); !errors.Is(err, ErrCertificateChanged) {
+		t.Fatalf("证书变更时返回 %v，期望 ErrCertificateChanged", err)
+	}
+	if logins.Load() != loginsBefore {
+		t.Error("证书变更时不应向服务器发送登录凭据")
+	}
+	stored, err = db.GetEmailAccountByID(account.ID)
+	if err != nil {
+		t.Fatalf("读取账户失败: %v", err)
+	}
+	if stored.CertFingerprint != certFingerprint(original.Leaf) || stored.PendingCertFingerprint != certFingerprint(replaced.Leaf) {
+		t.Errorf("信任的指纹为 %q、待确认的指纹为 %q，期望保留原指纹并记录新证书待确认", stored.CertFingerprint, stored.PendingCertFingerprint)
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// completionAlertWindow 最近一个下载完成后等待的时间，期间没有新的完成且队列已空时视为一批结束
+const completionAlertWindow = 5 * time.Second
+
+// completionAlert 汇总一批下载的完成情况，整批结束后只提醒一次，避免批量下载时逐个提示
+type completionAlert struct {
+	ds      *DownloadService
+	mutex   sync.Mutex
+	count   int         // 本批已完成的下载数
+	timer   *time.Timer // 等待本批结束的计时器，为nil表示没有进行中的批次
+	handler func(count int)
+}
+
+// newCompletionAlert 创建完成提醒处理器
+func newCompletionAlert(ds *DownloadService) *completionAlert {
+	return &completionAlert{ds: ds}
+}
+
+// SetBatchCompletedHandler 设置一批下载全部完成时的回调，参数为本批完成的下载数
+func (ds *DownloadService) SetBatchCompletedHandler(handler func(count int)) {
+	ds.completion.mutex.Lock()
+	defer ds.completion.mutex.Unlock()
+	ds.completion.handler = handler
+}
+
+// add 记录一个完成的下载，每次完成都重新开始等待
+func (a *completionAlert) add() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.count++
+	if a.timer == nil {
+		a.timer = time.AfterFunc(completionAlertWindow, a.flush)
+	} else {
+		a.timer.Reset(completionAlertWindow)
+	}
+}
+
+// flush 等待时间到达后仍有下载进行或排队时继续等待，否则结束本批并触发回调
+func (a *completionAlert) flush() {
+	if a.ds.GetActiveDownloads() > 0 || len(a.ds.taskQueue) > 0 {
+		a.mutex.Lock()
+		if a.timer != nil {
+			a.timer.Reset(completionAlertWindow)
+		}
+		a.mutex.Unlock()
+		return
+	}
+
+	a.mutex.Lock()
+	count, handler := a.count, a.handler
+	a.count = 0
+	a.timer = nil
+	a.mutex.Unlock()
+
+	if count == 0 || handler == nil || a.ds.ctx.Err() != nil {
+		return
+	}
+	a.ds.logger.Infof("本批 %d 个下载已全部完成", count)
+	handler(count)
+}
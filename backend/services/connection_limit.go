@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// connectionSlotTimeout 连接数达到上限且没有空闲连接可回收时，等待名额的最长时间
+const connectionSlotTimeout = 2 * time.Minute
+
+// connectionLimiter 全部IMAP连接共用的连接数上限：缓存的检查连接、IDLE连接和附件连接各占一个名额
+// 名额在建立连接前占用，连接关闭时归还；nil 表示不限制
+type connectionLimiter struct {
+	mu         sync.Mutex
+	limit      int           // 0表示不限制
+	open       int           // 已建立和正在建立的连接数
+	released   chan struct{} // 有名额归还或连接空闲时关闭并替换，等待名额的连接据此重新尝试
+	reclaimers []func() bool // 达到上限时依次尝试关闭一个空闲连接，关闭了连接时返回true
+}
+
+// newConnectionLimiter 创建不限制连接数的连接数上限，之后由 setLimit 设置
+func newConnectionLimiter() *connectionLimiter {
+	return &connectionLimiter{released: make(chan struct{})}
+}
+
+// setLimit 设置连接数上限，0表示不限制；已建立的连接不受影响，新上限在下次建立连接时生效
+func (l *connectionLimiter) setLimit(limit int) {
+	if l == nil {
+		return
+	}
+	if limit < 0 {
+		limit = 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.limit != limit {
+		l.limit = limit
+		l.signalLocked()
+	}
+}
+
+// count 返回已建立和正在建立的连接数
+func (l *connectionLimiter) count() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.open
+}
+
+// addReclaimer 注册达到上限时回收空闲连接的方法，方法在不持有 connectionLimiter 锁时调用
+func (l *connectionLimiter) addReclaimer(reclaim func() bool) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reclaimers = append(l.reclaimers, reclaim)
+}
+
+// acquire 为新连接占用一个名额，reserve 为占用后至少还需剩余的名额数（长期占用连接的IDLE监听为检查留出名额）
+// 达到上限时先回收空闲连接，没有可回收的连接时等待其他连接关闭或空闲；返回的函数归还名额，可重复调用
+func (l *connectionLimiter) acquire(ctx context.Context, reserve int) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	timeout := time.NewTimer(connectionSlotTimeout)
+	defer timeout.Stop()
+
+	for {
+		l.mu.Lock()
+		if l.limit <= 0 || l.open+reserve < l.limit {
+			l.open++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(l.release) }, nil
+		}
+		limit, released, reclaimers := l.limit, l.released, l.reclaimers
+		l.mu.Unlock()
+
+		if reserve >= limit {
+			// 回收空闲连接也无法留出足够的名额
+			return nil, fmt.Errorf("IMAP连接数上限（%d）过小，无法再保持长期占用的连接", limit)
+		}
+
+		if reclaimAny(reclaimers) {
+			continue
+		}
+
+		select {
+		case <-released:
+		case <-timeout.C:
+			return nil, fmt.Errorf("IMAP连接数已达上限（%d），等待空闲连接超时", limit)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待IMAP连接名额时中止: %v", ctx.Err())
+		}
+	}
+}
+
+// reclaimAny 依次尝试回收一个空闲连接
+func reclaimAny(reclaimers []func() bool) bool {
+	for _, reclaim := range reclaimers {
+		if reclaim() {
+			return true
+		}
+	}
+	return false
+}
+
+// release 归还一个名额
+func (l *connectionLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.open--
+	l.signalLocked()
+}
+
+// signal 有连接变为空闲时唤醒等待名额的连接，由其重新尝试回收
+func (l *connectionLimiter) signal() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.signalLocked()
+}
+
+// signalLocked 唤醒所有等待名额的连接，调用方需持有 mu
+func (l *connectionLimiter) signalLocked() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// SetMaxConnections 设置全部IMAP连接（检查、IDLE和附件下载）的数量上限，0表示不限制；新上限在下次建立连接时生效
+func (es *EmailService) SetMaxConnections(limit int) {
+	es.limiter.setLimit(limit)
+}
+
+// ConnectionCount 返回当前的IMAP连接数，包括缓存的检查连接、IDLE监听和附件下载使用的连接
+func (es *EmailService) ConnectionCount() int {
+	return es.limiter.count()
+}
+
+// reclaimIdleConnection 关闭没有操作在使用、最久未使用的缓存连接，为新连接腾出名额
+func (es *EmailService) reclaimIdleConnection() bool {
+	es.connectionsMutex.Lock()
+	accountID, victim := es.leastRecentlyUsedLocked()
+	if victim != nil {
+		es.removeConnectionLocked(accountID)
+	}
+	es.connectionsMutex.Unlock()
+
+	if victim == nil {
+		return false
+	}
+	es.logger.Infof("IMAP连接数达到上限，关闭账户%d最久未使用的连接", accountID)
+	victim.close()
+	return true
+}
+
+// useConnection 记录缓存连接被一次操作使用；连接已被移除时返回false
+func (es *EmailService) useConnection(accountID uint, conn *IMAPConnection) bool {
+	es.connectionsMutex.Lock()
+	defer es.connectionsMutex.Unlock()
+	if es.connections[accountID] != conn {
+		return false
+	}
+	conn.users++
+	return true
+}
+
+// leastRecentlyUsedLocked 找出没有操作在使用、最久未使用的缓存连接，调用方需持有 connectionsMutex
+// 只读取原子记录的使用时间，不等待正在进行长时间操作的连接锁
+func (es *EmailService) leastRecentlyUsedLocked() (uint, *IMAPConnection) {
+	var (
+		victimID uint
+		victim   *IMAPConnection
+		oldest   time.Time
+	)
+	for accountID, conn := range es.connections {
+		if conn.users > 0 {
+			continue
+		}
+		lastUsed := conn.lastUsedAt()
+		if victim == nil || lastUsed.Before(oldest) {
+			victimID, victim, oldest = accountID, conn, lastUsed
+		}
+	}
+	return victimID, victim
+}
+
+// removeConnectionLocked 从缓存中移除账户的连接，不关闭连接，调用方需持有 connectionsMutex
+func (es *EmailService) removeConnectionLocked(accountID uint) {
+	delete(es.connections, accountID)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newLimitedConnection 建立占用 limiter 名额的测试连接，关闭时归还名额
+func newLimitedConnection(t *testing.T, limiter *connectionLimiter) *IMAPConnection {
+	t.Helper()
+
+	release, err := limiter.acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("占用连接名额失败: %v", err)
+	}
+	conn := newFakeIMAPConnection(t, "", nil)
+	conn.releaseSlot = release
+	return conn
+}
+
+// TestConnectionLimiterSharedAcrossPools 检查连接和附件连接共用一个上限，达到上限时回收最久未使用的空闲连接
+func TestConnectionLimiterSharedAcrossPools(t *testing.T) {
+	ds := &DownloadService{logger: newTestLogger(), connLimiter: newConnectionLimiter()}
+	ds.attachmentPool = newAttachmentPool(ds)
+	es := NewEmailService(nil, ds, newTestLogger())
+	ds.connLimiter.setLimit(2)
+
+	// 附件连接先空闲，比缓存的检查连接更早使用
+	attachment := newLimitedConnection(t, ds.connLimiter)
+	ds.attachmentPool.mu.Lock()
+	ac := ds.attachmentPool.accountLocked(attachment.ID)
+	ac.open[attachment] = struct{}{}
+	ds.attachmentPool.mu.Unlock()
+	ds.attachmentPool.release(attachment, true)
+	attachment.lastUsed.Store(time.Now().Add(-time.Hour).UnixNano())
+
+	cached := newLimitedConnection(t, ds.connLimiter)
+	cached.ID = 2
+	es.connections[cached.ID] = cached
+	if got := es.ConnectionCount(); got != 2 {
+		t.Fatalf("连接数为 %d，期望检查连接和附件连接共计 2", got)
+	}
+
+	// 第三个连接回收最久未使用的附件连接
+	release, err := ds.connLimiter.acquire(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("达到上限时应回收空闲连接: %v", err)
+	}
+	if attachment.isAlive() || !cached.isAlive() {
+		t.Errorf("应关闭最久未使用的附件连接，附件连接存活: %v，检查连接存活: %v", attachment.isAlive(), cached.isAlive())
+	}
+	if got := es.ConnectionCount(); got != 2 {
+		t.Errorf("回收后连接数为 %d，期望 2", got)
+	}
+
+	// 再下一个连接回收缓存的检查连接
+	if _, err := ds.connLimiter.acquire(context.Background(), 0); err != nil {
+		t.Fatalf("达到上限时应回收空闲的检查连接: %v", err)
+	}
+	if _, exists := es.connections[cached.ID]; exists {
+		t.Error("被回收的检查连接仍在缓存中")
+	}
+
+	// 没有可回收的连接时等待名额
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := ds.connLimiter.acquire(ctx, 0); err == nil {
+		t.Fatal("全部连接都在使用中时应等待并超时")
+	}
+	release()
+	release() // 重复归还只计一次
+	if got := es.ConnectionCount(); got != 1 {
+		t.Errorf("归还名额后连接数为 %d，期望 1", got)
+	}
+}
+
+// TestReclaimIgnoresBusyConnectionLock 回收空闲连接时不等待正被其他操作持有的连接锁
+func TestReclaimIgnoresBusyConnectionLock(t *testing.T) {
+	es := NewEmailService(nil, nil, newTestLogger())
+	conn := newLimitedConnection(t, es.limiter)
+	es.connections[conn.ID] = conn
+
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+
+	done := make(chan bool, 1)
+	go func() {
+		es.connectionsMutex.Lock()
+		_, victim := es.leastRecentlyUsedLocked()
+		es.connectionsMutex.Unlock()
+		done <- victim == conn
+	}()
+	select {
+	case found := <-done:
+		if !found {
+			t.Error("应选出唯一的空闲连接")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("查找最久未使用的连接时卡在连接锁上")
+	}
+}
+
+// TestConnectionLimiterReserve IDLE连接为检查留出名额，上限过小时直接返回错误
+func TestConnectionLimiterReserve(t *testing.T) {
+	limiter := newConnectionLimiter()
+	limiter.setLimit(1)
+	start := time.Now()
+	if _, err := limiter.acquire(context.Background(), 1); err == nil {
+		t.Fatal("上限只有一个名额时IDLE连接应为检查留出")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("无论如何都无法留出名额时不应等待")
+	}
+
+	limiter.setLimit(2)
+	release, err := limiter.acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("还有两个名额时IDLE连接应能占用一个: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.acquire(ctx, 1); err == nil {
+		t.Fatal("只剩一个名额时IDLE连接应等待，不占用留给检查的名额")
+	}
+	if _, err := limiter.acquire(context.Background(), 0); err != nil {
+		t.Errorf("检查连接应能使用留出的名额: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"os"
+
+	"emaild/backend/models"
+)
+
+// contentHash 计算下载内容的SHA-256
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// deduplicateEnabled 是否开启了按内容去重
+func (ds *DownloadService) deduplicateEnabled() bool {
+	config, err := ds.db.GetConfig()
+	return err == nil && config.DeduplicateDownloads
+}
+
+// reuseDuplicate 开启去重时查找内容相同的已完成任务，找到且文件仍在时把任务指向已有文件，
+// 返回true表示不需要再保存本次下载的内容；未开启、没有重复或已有文件不存在时返回false
+func (ds *DownloadService) reuseDuplicate(task *models.DownloadTask, hash string) bool {
+	if hash == "" || !ds.deduplicateEnabled() {
+		return false
+	}
+
+	original, err := ds.db.FindCompletedTaskByHash(task, hash)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			ds.logger.Warnf("任务 %d 查找内容相同的任务失败: %v", task.ID, err)
+		}
+		return false
+	}
+	if _, err := os.Stat(original.LocalPath); err != nil {
+		return false
+	}
+
+	if err := ds.db.MarkTaskDuplicate(task.ID, original.ID, hash, original.LocalPath); err != nil {
+		ds.logger.Warnf("任务 %d 记录重复文件失败: %v", task.ID, err)
+		return false
+	}
+	task.Hash = hash
+	task.DuplicateOf = original.ID
+	task.LocalPath = original.LocalPath
+	ds.taskInfof(task.ID, "内容与任务 %d 相同，不再重复保存，使用已有文件: %s", original.ID, original.LocalPath)
+	return true
+}
+
+// saveContentHash 保存已写入文件的内容哈希，供之后的下载去重
+func (ds *DownloadService) saveContentHash(task *models.DownloadTask, hash string) {
+	if hash == "" {
+		return
+	}
+	task.Hash = hash
+	if err := ds.db.UpdateTaskHash(task.ID, hash); err != nil {
+		ds.logger.Warnf("任务 %d 保存内容哈希失败: %v", task.ID, err)
+	}
+}
+
+// fileInUse 去重后其他任务是否仍在使用该文件，删除任务文件前检查
+func (ds *DownloadService) fileInUse(path string) bool {
+	count, err := ds.db.CountTasksUsingFile(path)
+	return err != nil || count > 0
+}
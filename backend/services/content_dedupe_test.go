@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// TestFindCompletedTaskByHashByMessage 同一封邮件中的任务不互相去重，主题和发件人相同的另一封邮件照常去重
+func TestFindCompletedTaskByHashByMessage(t *testing.T) {
+	db := newTestDatabase(t)
+	const hash = "0123abcd"
+
+	account := &models.EmailAccount{Name: "test", Email: "user@example.com", IMAPServer: "imap.example.com", IMAPPort: 993, UseSSL: true, IsActive: true}
+	if err := db.CreateEmailAccount(account); err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+
+	// 同一张发票先后发送两次，主题和发件人相同
+	messages := make([]*models.EmailMessage, 2)
+	for i, messageID := range []string{"<invoice-1@example.com>", "<invoice-2@example.com>"} {
+		messages[i] = &models.EmailMessage{EmailID: account.ID, MessageID: messageID, Subject: "发票", Sender: "billing@example.com", Date: models.TimeToString(time.Now())}
+		if err := db.CreateEmailMessage(messages[i]); err != nil {
+			t.Fatalf("创建邮件记录失败: %v", err)
+		}
+	}
+
+	original := &models.DownloadTask{FileName: "invoice.pdf", Subject: "发票", Sender: "billing@example.com", Status: models.StatusCompleted, Type: models.TypeAttachment, MessageID: messages[0].ID}
+	createTestTask(t, db, original)
+	if err := db.UpdateTaskHash(original.ID, hash); err != nil {
+		t.Fatalf("保存内容哈希失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		messageID uint
+		wantFound bool
+	}{
+		{name: "重新发送的同一张发票", messageID: messages[1].ID, wantFound: true},
+		{name: "同一封邮件中的另一来源", messageID: messages[0].ID, wantFound: false},
+		{name: "未关联邮件记录", messageID: 0, wantFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &models.DownloadTask{FileName: "invoice.pdf", Subject: "发票", Sender: "billing@example.com", Status: models.StatusDownloading, Type: models.TypeLink, MessageID: tt.messageID}
+			createTestTask(t, db, task)
+
+			found, err := db.FindCompletedTaskByHash(task, hash)
+			if tt.wantFound {
+				if err != nil || found.ID != original.ID {
+					t.Fatalf("应找到任务 %d，实际为 %v（%v）", original.ID, found, err)
+				}
+			} else if err != sql.ErrNoRows {
+				t.Fatalf("不应找到重复任务，实际为 %v（%v）", found, err)
+			}
+		})
+	}
+}
@@ -3,19 +3,24 @@ package services
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"mime/quotedprintable"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/emersion/go-imap"
@@ -37,7 +42,32 @@ type DownloadService struct {
 	ctx               context.Context          // 服务上下文
 	cancel            context.CancelFunc       // 取消函数
 	taskQueue         chan *models.DownloadTask // 任务队列
+	retryQueue        chan taskRetry           // 等待自动重试的任务
+	cancelledTasks    chan map[uint]bool       // 全部取消后需要移出待处理队列的任务（见 cancel_all.go）
 	logger            *logrus.Logger           // 日志记录器
+	telemetry         *TelemetryService        // 匿名错误遥测（可选）
+	autoOpen          *autoOpener              // 下载完成后自动打开文件或目录
+	completion        *completionAlert         // 一批下载全部完成后的提醒
+	pdfIndex          *pdfIndexer              // 下载完成后在后台建立PDF元数据索引
+	pdfMerge          *pdfMerger               // 同一邮件的分卷PDF全部完成后合并为一个文件
+	attachmentPool    *attachmentPool          // 按账户复用获取附件的IMAP连接
+	connLimiter       *connectionLimiter       // 全部IMAP连接共用的连接数上限，与邮件服务共用
+	lowMemory         bool                     // 可用内存低于设定下限（见 memory_guard.go），只在任务调度器中访问
+	
+	// 进度更新的接收方，第一个为数据库（见 progress_reporter.go）
+	reporters     []ProgressReporter
+	reporterMutex sync.RWMutex
+	metrics       *metricsProgressReporter // 本次运行的下载指标，同时也是一个接收方
+	
+	// 下载链接使用的 http.Transport，按代理设置缓存以复用连接（见 proxy.go）
+	transports     map[linkTransportKey]*http.Transport
+	transportMutex sync.Mutex
+	
+	// 下载时间窗口（独立于邮件检查，窗口外的任务保持等待）
+	windowEnabled bool
+	windowStart   int // 开始时间（从零点开始的分钟数）
+	windowEnd     int // 结束时间（从零点开始的分钟数）
+	windowMutex   sync.RWMutex
 	
 	// 优雅关闭相关
 	wg              sync.WaitGroup    // 等待所有goroutine完成
@@ -55,16 +85,38 @@ type DownloadWorker struct {
 	Cancel       context.CancelFunc
 	Progress     chan ProgressUpdate
 	progressOnce sync.Once  // 确保progress channel只关闭一次
+	
+	// 实时网络状态（由monitorProgress根据进度更新维护）
+	activityMutex  sync.Mutex
+	host           string    // 正在连接的主机
+	fileName       string    // 开始下载时的文件名
+	downloaded     int64     // 已下载字节数
+	totalSize      int64     // 总字节数，未知时为0
+	bytesPerSecond float64   // 最近一次进度间隔内的速度
+	lastUpdate     time.Time // 最近一次进度更新时间
+	lastData       time.Time // 最近一次收到数据的时间
+	
+	// 已写入临时文件的字节数（原子访问），关闭服务时用于保存准确的下载进度
+	written   int64
+	validator string      // 链接续传时 If-Range 使用的校验值，来自开始下载时的响应
+	paused    atomic.Bool // 由用户暂停而取消，保留临时文件以便续传
+	cancelled atomic.Bool // 由用户取消，结束时不算失败也不自动重试
+	done      chan struct{} // 工作者退出、最终状态已写入后关闭
 }
 
-// ProgressUpdate 进度更新
+// ProgressUpdate 进度更新，同时作为下载进度事件发送给前端
 type ProgressUpdate struct {
-	TaskID           uint
-	DownloadedSize   int64
-	Progress         float64
-	Speed            string
-	Status           models.DownloadStatus
-	Error            string
+	TaskID           uint                  `json:"task_id"`
+	DownloadedSize   int64                 `json:"downloaded_size"`
+	TotalSize        int64                 `json:"total_size"` // 文件总大小，未知时为0
+	Progress         float64               `json:"progress"`
+	Speed            string                `json:"speed"`
+	BytesPerSecond   float64               `json:"bytes_per_second"` // 距上一次进度更新期间的速度，下载中的更新都有，没有收到数据时为0
+	Status           models.DownloadStatus `json:"status"`
+	Error            string                `json:"error"`
+	// 任务本次下载的最终结果（完成、失败或用户取消），由 performDownload 在校验、重命名等全部步骤结束后发送；
+	// 下载函数在读完数据时发送的完成更新不是最终结果，之后的校验仍可能失败
+	Final bool `json:"final"`
 }
 
 // PDFPartInfo PDF部分信息
@@ -84,6 +136,9 @@ func NewDownloadService(db *database.Database) *DownloadService {
 	logger.SetFormatter(&logrus.TextFormatter{
 		FullTimestamp: true,
 	})
+	if err := utils.SetupLogger(logger); err != nil {
+		logger.Warnf("启用文件日志失败: %v", err)
+	}
 	
 	service := &DownloadService{
 		db:              db,
@@ -92,8 +147,23 @@ func NewDownloadService(db *database.Database) *DownloadService {
 		ctx:             ctx,
 		cancel:          cancel,
 		taskQueue:       make(chan *models.DownloadTask, 100), // 缓冲队列
+		retryQueue:      make(chan taskRetry, 100),
+		cancelledTasks:  make(chan map[uint]bool),
 		logger:          logger,
 		isShuttingDown:  false,
+		connLimiter:     newConnectionLimiter(),
+	}
+	service.autoOpen = newAutoOpener(service)
+	service.completion = newCompletionAlert(service)
+	service.pdfIndex = newPDFIndexer(service)
+	service.pdfMerge = newPDFMerger(service)
+	service.attachmentPool = newAttachmentPool(service)
+	service.metrics = newMetricsProgressReporter()
+	service.reporters = []ProgressReporter{
+		dbProgressReporter{ds: service},
+		speedSampleReporter{db: db},
+		webhookProgressReporter{ds: service},
+		service.metrics,
 	}
 	
 	// 启动服务组件
@@ -102,6 +172,11 @@ func NewDownloadService(db *database.Database) *DownloadService {
 	return service
 }
 
+// SetTelemetry 设置错误遥测服务
+func (ds *DownloadService) SetTelemetry(telemetry *TelemetryService) {
+	ds.telemetry = telemetry
+}
+
 // startServiceComponents 启动服务组件
 func (ds *DownloadService) startServiceComponents() {
 	// 恢复未完成的任务
@@ -111,65 +186,53 @@ func (ds *DownloadService) startServiceComponents() {
 	// 启动任务调度器
 	ds.wg.Add(1)
 	go ds.taskScheduler()
+	
+	// 清理崩溃遗留的临时文件
+	ds.wg.Add(1)
+	go ds.tempFileCleaner()
+	
+	// 关闭空闲过久的附件连接
+	ds.wg.Add(1)
+	go ds.attachmentPool.run()
+	
+	// 为下载完成的PDF建立元数据索引
+	ds.pdfIndex.start()
+	
+	// 分卷PDF全部完成后在后台合并
+	ds.pdfMerge.start()
 }
 
 // recoverUnfinishedTasks 恢复未完成的任务
+// 按配置的速率逐个重新排队，避免启动时同时开始大量下载
 func (ds *DownloadService) recoverUnfinishedTasks() {
 	defer ds.wg.Done()
 	
+	settings := ds.taskRecoverySettings()
+	
 	// 等待服务完全初始化
 	select {
-	case <-time.After(2 * time.Second):
+	case <-time.After(settings.delay):
 	case <-ds.ctx.Done():
 		return
 	}
 	
+	// 排队过久的等待任务直接过期，不参与恢复
+	ds.expireAbandonedTasks()
+	
 	// 查找所有未完成的任务
-	query := `
-		SELECT 
-			dt.id, dt.email_id, dt.subject, dt.sender, dt.file_name, 
-			dt.file_size, dt.downloaded_size, dt.status, dt.type, 
-			dt.source, dt.local_path, dt.error, dt.progress, dt.speed,
-			dt.created_at, dt.updated_at,
-			ea.id, ea.name, ea.email, ea.password, ea.imap_server, 
-			ea.imap_port, ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
-		FROM download_tasks dt
-		LEFT JOIN email_accounts ea ON dt.email_id = ea.id
-		WHERE dt.status IN ('downloading', 'pending')
-		ORDER BY dt.created_at ASC
-	`
-	
-	rows, err := ds.db.DB.Query(query)
+	tasks, err := ds.db.GetUnfinishedDownloadTasks()
 	if err != nil {
 		ds.logger.Errorf("查询未完成任务失败: %v", err)
 		return
 	}
-	defer rows.Close()
 	
 	var recoveredTasks []*models.DownloadTask
 	
-	for rows.Next() {
-		task := &models.DownloadTask{}
-		account := &models.EmailAccount{}
-		
-		err := rows.Scan(
-			&task.ID, &task.EmailID, &task.Subject, &task.Sender, &task.FileName,
-			&task.FileSize, &task.DownloadedSize, &task.Status, &task.Type,
-			&task.Source, &task.LocalPath, &task.Error, &task.Progress, &task.Speed,
-			&task.CreatedAt, &task.UpdatedAt,
-			&account.ID, &account.Name, &account.Email, &account.Password, &account.IMAPServer,
-			&account.IMAPPort, &account.UseSSL, &account.IsActive, &account.CreatedAt, &account.UpdatedAt,
-		)
-		
-		if err != nil {
-			ds.logger.Errorf("扫描任务数据失败: %v", err)
-			continue
-		}
-		
-		task.EmailAccount = *account
+	for i := range tasks {
+		task := &tasks[i]
 		
 		// 检查任务是否应该恢复
-		if ds.shouldRecoverTask(task) {
+		if ds.shouldRecoverTask(task, settings.maxAge) {
 			recoveredTasks = append(recoveredTasks, task)
 		} else {
 			// 任务过期或有问题，标记为失败
@@ -178,7 +241,11 @@ func (ds *DownloadService) recoverUnfinishedTasks() {
 	}
 	
 	// 重新将恢复的任务放入队列
-	for _, task := range recoveredTasks {
+	for i, task := range recoveredTasks {
+		if i > 0 && !ds.waitRecoveryInterval(settings.interval) {
+			return
+		}
+		
 		// 重置任务状态为pending
 		ds.updateTaskStatus(task.ID, models.StatusPending, "", task.DownloadedSize, 0, "")
 		
@@ -197,19 +264,20 @@ func (ds *DownloadService) recoverUnfinishedTasks() {
 	ds.logger.Infof("成功恢复 %d 个未完成任务", len(recoveredTasks))
 }
 
-// shouldRecoverTask 判断是否应当恢复任务
-func (ds *DownloadService) shouldRecoverTask(task *models.DownloadTask) bool {
-	// 检查任务创建时间（超过24小时的任务不恢复）
-	if createdAt, err := time.Parse("2006-01-02 15:04:05", task.CreatedAt); err == nil {
-		if time.Since(createdAt) > 24*time.Hour {
-			ds.logger.Infof("任务 %d 创建时间过久，不恢复", task.ID)
-			return false
-		}
+// shouldRecoverTask 判断是否应当恢复任务，排队超过 maxAge 的任务不恢复
+func (ds *DownloadService) shouldRecoverTask(task *models.DownloadTask, maxAge time.Duration) bool {
+	// 检查任务排队时间（重试的任务从重新排队时算起）
+	queuedAt, err := models.StringToTime(queuedSince(task))
+	if err != nil {
+		ds.logger.Warnf("任务 %d 的排队时间无法解析，跳过过期检查: %v", task.ID, err)
+	} else if !queuedAt.IsZero() && time.Since(queuedAt) > maxAge {
+		ds.taskInfof(task.ID, "排队时间超过 %s，不恢复", maxAge)
+		return false
 	}
 	
 	// 检查账户是否仍然有效
 	if !task.EmailAccount.IsActive {
-		ds.logger.Infof("任务 %d 对应的邮箱账户已禁用，不恢复", task.ID)
+		ds.taskInfof(task.ID, "对应的邮箱账户已禁用，不恢复")
 		return false
 	}
 	
@@ -235,7 +303,10 @@ func (ds *DownloadService) taskScheduler() {
 	retryTicker := time.NewTicker(5 * time.Second) // 每5秒检查一次待处理任务
 	defer retryTicker.Stop()
 	
-	var pendingTasks []*models.DownloadTask // 待处理任务队列
+	var pendingTasks []*models.DownloadTask      // 待处理任务队列
+	heldBySchedule := make(map[uint]bool)        // 因下载时间窗口而等待的任务，不参与排队超时
+	scheduledRetries := make(map[uint]time.Time) // 等待自动重试的任务及重新排队的时间
+	enqueuedAt := make(map[uint]time.Time)       // 任务进入待处理队列的时间，用于排队超时
 	
 	for {
 		select {
@@ -253,30 +324,79 @@ func (ds *DownloadService) taskScheduler() {
 			}
 			ds.shutdownMutex.RUnlock()
 			
-			// 检查是否可以启动新任务
+			// 不在下载时间窗口内，保持等待直到窗口开启
+			if ds.IsPausedBySchedule() {
+				heldBySchedule[task.ID] = true
+				enqueuedAt[task.ID] = time.Now()
+				pendingTasks = append(pendingTasks, task)
+				ds.logger.Debugf("任务 %d 不在下载时间窗口内，等待窗口开启", task.ID)
+				continue
+			}
+			
+			// 检查是否可以启动新任务，内存不足时交给定期检查按内存情况启动
 			ds.activeWorkerMutex.RLock()
 			canStart := ds.activeWorkers < ds.maxConcurrent
 			ds.activeWorkerMutex.RUnlock()
+			if canStart && ds.memoryLow() {
+				canStart = false
+			}
 			
 			if canStart {
 				ds.wg.Add(1)
 				go ds.startDownload(task)
 			} else {
 				// 加入待处理队列
+				enqueuedAt[task.ID] = time.Now()
 				pendingTasks = append(pendingTasks, task)
 				ds.logger.Debugf("任务 %d 加入待处理队列，当前队列长度: %d", task.ID, len(pendingTasks))
 			}
 			
+		case retry := <-ds.retryQueue:
+			scheduledRetries[retry.taskID] = retry.due
+			
+		case cancelled := <-ds.cancelledTasks:
+			// 全部取消：移出已取消的待处理任务，并放弃所有等待中的自动重试
+			pendingTasks = dropCancelledTasks(pendingTasks, cancelled, heldBySchedule, enqueuedAt)
+			for taskID := range scheduledRetries {
+				delete(scheduledRetries, taskID)
+			}
+			ds.discardQueuedRetries()
+			
 		case <-retryTicker.C:
+			// 到达重试时间的任务重新加入待处理队列
+			for _, task := range ds.dueRetries(scheduledRetries) {
+				if ds.IsPausedBySchedule() {
+					heldBySchedule[task.ID] = true
+				}
+				enqueuedAt[task.ID] = time.Now()
+				pendingTasks = append(pendingTasks, task)
+			}
+			
 			// 定期检查待处理任务
 			if len(pendingTasks) == 0 {
 				continue
 			}
 			
+			// 窗口外不启动任何任务
+			if ds.IsPausedBySchedule() {
+				continue
+			}
+			
 			ds.activeWorkerMutex.RLock()
 			availableSlots := ds.maxConcurrent - ds.activeWorkers
+			activeWorkers := ds.activeWorkers
 			ds.activeWorkerMutex.RUnlock()
 			
+			// 内存不足时只在没有进行中的下载时启动一个任务，队列仍能缓慢推进
+			memoryLow := availableSlots > 0 && ds.memoryLow()
+			if memoryLow {
+				if activeWorkers > 0 {
+					availableSlots = 0
+				} else {
+					availableSlots = 1
+				}
+			}
+			
 			if availableSlots > 0 {
 				// 启动尽可能多的任务
 				toStart := availableSlots
@@ -285,6 +405,8 @@ func (ds *DownloadService) taskScheduler() {
 				}
 				
 				for i := 0; i < toStart; i++ {
+					delete(heldBySchedule, pendingTasks[i].ID)
+					delete(enqueuedAt, pendingTasks[i].ID)
 					ds.wg.Add(1)
 					go ds.startDownload(pendingTasks[i])
 				}
@@ -294,20 +416,23 @@ func (ds *DownloadService) taskScheduler() {
 				ds.logger.Debugf("启动了 %d 个待处理任务，剩余队列长度: %d", toStart, len(pendingTasks))
 			}
 			
-			// 清理过期的待处理任务（超过10分钟）
+			// 清理过期的待处理任务（在队列中等待超过10分钟）
+			// 按进入队列的时间计算，启动时恢复的旧任务和重试的任务不会一进队列就超时
 			now := time.Now()
 			var validTasks []*models.DownloadTask
 			for _, task := range pendingTasks {
-				if createdAt, err := time.Parse("2006-01-02 15:04:05", task.CreatedAt); err == nil {
-					if now.Sub(createdAt) < 10*time.Minute {
-						validTasks = append(validTasks, task)
-					} else {
-						// 任务过期，标记为失败
-						ds.updateTaskStatus(task.ID, models.StatusFailed, "任务排队超时", 0, 0, "")
-						ds.logger.Warnf("任务 %d 排队超时，已标记为失败", task.ID)
-					}
+				if heldBySchedule[task.ID] || memoryLow {
+					enqueuedAt[task.ID] = now // 等待窗口或等待内存期间不计入排队时间
+					validTasks = append(validTasks, task)
+					continue
+				}
+				if now.Sub(enqueuedAt[task.ID]) < 10*time.Minute {
+					validTasks = append(validTasks, task)
 				} else {
-					validTasks = append(validTasks, task) // 保留无法解析时间的任务
+					// 任务过期，标记为失败
+					delete(enqueuedAt, task.ID)
+					ds.updateTaskStatus(task.ID, models.StatusFailed, "任务排队超时", 0, 0, "")
+					ds.logger.Warnf("任务 %d 排队超时，已标记为失败", task.ID)
 				}
 			}
 			pendingTasks = validTasks
@@ -331,6 +456,14 @@ func (ds *DownloadService) StartDownload(taskID uint) error {
 		return fmt.Errorf("获取任务失败: %v", err)
 	}
 	
+	// 暂停的任务恢复为等待状态，保留已下载的进度以便续传
+	if task.Status == models.StatusPaused {
+		if err := ds.updateTaskStatus(task.ID, models.StatusPending, "", task.DownloadedSize, task.Progress, ""); err != nil {
+			return err
+		}
+		task.Status = models.StatusPending
+	}
+	
 	if task.Status != models.StatusPending {
 		return fmt.Errorf("任务状态不正确: %s", task.Status)
 	}
@@ -348,45 +481,24 @@ func (ds *DownloadService) StartDownload(taskID uint) error {
 
 // getTaskByIDOptimized 优化的任务查询
 func (ds *DownloadService) getTaskByIDOptimized(taskID uint) (*models.DownloadTask, error) {
-	query := `
-		SELECT 
-			dt.id, dt.email_id, dt.subject, dt.sender, dt.file_name, 
-			dt.file_size, dt.downloaded_size, dt.status, dt.type, 
-			dt.source, dt.local_path, dt.error, dt.progress, dt.speed,
-			dt.created_at, dt.updated_at,
-			ea.id, ea.name, ea.email, ea.password, ea.imap_server, 
-			ea.imap_port, ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
-		FROM download_tasks dt
-		LEFT JOIN email_accounts ea ON dt.email_id = ea.id
-		WHERE dt.id = ?
-	`
-	
-	row := ds.db.DB.QueryRow(query, taskID)
-	
-	task := &models.DownloadTask{}
-	account := &models.EmailAccount{}
-	
-	err := row.Scan(
-		&task.ID, &task.EmailID, &task.Subject, &task.Sender, &task.FileName,
-		&task.FileSize, &task.DownloadedSize, &task.Status, &task.Type,
-		&task.Source, &task.LocalPath, &task.Error, &task.Progress, &task.Speed,
-		&task.CreatedAt, &task.UpdatedAt,
-		&account.ID, &account.Name, &account.Email, &account.Password, &account.IMAPServer,
-		&account.IMAPPort, &account.UseSSL, &account.IsActive, &account.CreatedAt, &account.UpdatedAt,
-	)
-	
-	if err != nil {
-		return nil, err
-	}
-	
-	task.EmailAccount = *account
-	return task, nil
+	return ds.db.GetDownloadTaskByID(taskID)
 }
 
 // startDownload 启动下载
 func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 	defer ds.wg.Done()
 	
+	// 排队期间被删除或取消的任务不再下载
+	current, err := ds.getTaskByIDOptimized(task.ID)
+	if err == sql.ErrNoRows {
+		ds.logger.Infof("任务 %d 已被删除，跳过下载", task.ID)
+		return
+	}
+	if err == nil && current.Status == models.StatusCancelled {
+		ds.logger.Infof("任务 %d 已取消，跳过下载", task.ID)
+		return
+	}
+	
 	// 增加活跃工作者计数
 	ds.activeWorkerMutex.Lock()
 	ds.activeWorkers++
@@ -416,11 +528,16 @@ func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 	worker := &DownloadWorker{
 		ID:       task.ID,
 		Task:     task,
-		Client:   &http.Client{Timeout: 30 * time.Second},
+		Client:   ds.newLinkClient(ds.linkTransport(false)),
 		Context:  workerCtx,
 		Cancel:   workerCancel,
 		Progress: make(chan ProgressUpdate, 10),
+		host:     taskHost(task),
+		fileName: task.FileName,
+		done:     make(chan struct{}),
 	}
+	worker.lastUpdate = time.Now()
+	worker.lastData = worker.lastUpdate
 	
 	// 注册工作者
 	ds.workerMutex.Lock()
@@ -437,6 +554,7 @@ func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 		worker.progressOnce.Do(func() {
 			close(worker.Progress)
 		})
+		close(worker.done)
 	}()
 	
 	// 启动进度监控（带恢复机制）
@@ -466,6 +584,7 @@ func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 					TaskID: task.ID,
 					Status: models.StatusFailed,
 					Error:  fmt.Sprintf("下载执行出错: %v", r),
+					Final:  true,
 				}:
 				default:
 					// 如果progress channel已满或已关闭，直接更新数据库
@@ -477,6 +596,11 @@ func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 		ds.performDownload(worker)
 	}()
 	
+	// 下载结束后关闭progress channel，让进度监控处理完剩余更新后退出
+	worker.progressOnce.Do(func() {
+		close(worker.Progress)
+	})
+	
 	// 等待进度监控完成
 	monitorWg.Wait()
 }
@@ -485,10 +609,15 @@ func (ds *DownloadService) startDownload(task *models.DownloadTask) {
 func (ds *DownloadService) performDownload(worker *DownloadWorker) {
 	task := worker.Task
 	
-	ds.logger.Infof("开始下载任务 %d: %s", task.ID, task.FileName)
+	ds.taskInfof(task.ID, "开始下载: %s", task.FileName)
+	
+	// 更新状态为下载中，续传的任务保留已下载的进度
+	ds.updateTaskStatus(task.ID, models.StatusDownloading, "", task.DownloadedSize, task.Progress, "")
 	
-	// 更新状态为下载中
-	ds.updateTaskStatus(task.ID, models.StatusDownloading, "", 0, 0, "")
+	// 之前失败的尝试留下的速度采样不再保留，避免与本次下载的曲线混在一起
+	if err := ds.db.ClearSpeedSamples(task.ID); err != nil {
+		ds.logger.Warnf("清除任务 %d 的速度采样失败: %v", task.ID, err)
+	}
 	
 	// 确保目录存在
 	if err := os.MkdirAll(filepath.Dir(task.LocalPath), 0755); err != nil {
@@ -496,6 +625,18 @@ func (ds *DownloadService) performDownload(worker *DownloadWorker) {
 			TaskID: task.ID,
 			Status: models.StatusFailed,
 			Error:  fmt.Sprintf("创建目录失败: %v", err),
+			Final:  true,
+		}
+		return
+	}
+	
+	// 下载前检查目录是否可写、空间是否足够，尽早给出明确的错误
+	if check := CheckDownloadPath(filepath.Dir(task.LocalPath), task.FileSize); check.Error != "" {
+		worker.Progress <- ProgressUpdate{
+			TaskID: task.ID,
+			Status: models.StatusFailed,
+			Error:  check.Error,
+			Final:  true,
 		}
 		return
 	}
@@ -507,69 +648,262 @@ func (ds *DownloadService) performDownload(worker *DownloadWorker) {
 		err = ds.downloadAttachment(worker)
 	case models.TypeLink:
 		err = ds.downloadFromURL(worker)
+	case models.TypePartial:
+		err = ds.downloadPartial(worker)
 	default:
 		err = fmt.Errorf("不支持的下载类型: %s", task.Type)
 	}
 	
-	if err != nil {
-		ds.logger.Errorf("任务 %d 下载失败: %v", task.ID, err)
+	if errors.Is(err, ErrPartialIncomplete) {
+		// 缺少片段不算失败，收到剩余片段后会重新开始
+		ds.logger.Infof("任务 %d 等待剩余片段: %v", task.ID, err)
 		worker.Progress <- ProgressUpdate{
 			TaskID: task.ID,
-			Status: models.StatusFailed,
+			Status: models.StatusIncomplete,
 			Error:  err.Error(),
 		}
+	} else if err != nil && ds.isStopping() {
+		// 应用关闭导致的中断不算失败，保存进度后在下次启动时恢复
+		worker.Progress <- ds.interruptedUpdate(worker)
+	} else if err != nil && worker.cancelled.Load() {
+		// 用户取消导致的中断，最后写入取消状态，避免之前排队的进度更新覆盖取消
+		worker.Progress <- ProgressUpdate{TaskID: task.ID, Status: models.StatusCancelled, Final: true}
+	} else if err != nil && worker.paused.Load() {
+		// 用户暂停导致的中断，记录已下载的字节数，恢复时从该位置继续
+		ds.logger.Infof("任务 %d 已暂停", task.ID)
+		worker.Progress <- ds.savedProgressUpdate(worker, models.StatusPaused, "")
+	} else if err != nil {
+		ds.logger.Errorf("任务 %d 下载失败: %v", task.ID, err)
+		if task.Type == models.TypeLink {
+			ds.telemetry.RecordError(TelemetrySourceLinkDownload, err, task.Source)
+		} else {
+			ds.telemetry.RecordError(TelemetrySourceAttachmentDownload, err, task.EmailAccount.IMAPServer)
+		}
+		// 链接下载保留了已写入的临时文件，重试时可以续传
+		update := ds.savedProgressUpdate(worker, models.StatusFailed, err.Error())
+		update.Final = true
+		worker.Progress <- update
+		ds.scheduleAutoRetry(task, err)
 	} else {
 		ds.logger.Infof("任务 %d 下载成功: %s", task.ID, task.FileName)
+		// 同一邮件中另一种来源已保存了相同文件时，本任务的文件已删除，以取消作为最终结果
+		if task.DuplicateOf == 0 {
+			if message, skipped := ds.skipDuplicateSource(worker); skipped {
+				worker.Progress <- ProgressUpdate{
+					TaskID: task.ID,
+					Status: models.StatusCancelled,
+					Error:  message,
+					Final:  true,
+				}
+				return
+			}
+		}
+		ds.recordChecksum(task)
+		ds.pdfIndex.add(task)
+		// 内容重复的任务使用已有文件，不再打开
+		if task.DuplicateOf == 0 {
+			ds.autoOpen.add(task.LocalPath)
+			ds.completion.add()
+		}
+		// 完成状态已由下载函数写入，这里只通知接收方文件已校验并保存到最终位置
+		worker.Progress <- ProgressUpdate{
+			TaskID:   task.ID,
+			Status:   models.StatusCompleted,
+			Progress: 100,
+			Final:    true,
+		}
 	}
 }
 
-// downloadFromURL 从URL下载文件（增强版，支持各种邮件服务商）
-func (ds *DownloadService) downloadFromURL(worker *DownloadWorker) error {
+// skipDuplicateSource 下载完成后按内容哈希检查同一邮件中另一种来源的PDF
+// 同一文件同时以附件和链接出现时，只保留偏好的来源，删除另一份
+// 当前任务作为重复项被跳过时返回跳过的原因和true，由调用方写入最终的取消状态
+func (ds *DownloadService) skipDuplicateSource(worker *DownloadWorker) (string, bool) {
 	task := worker.Task
+	if task.Type != models.TypeAttachment && task.Type != models.TypeLink {
+		return "", false
+	}
 	
-	// 创建请求
-	req, err := http.NewRequestWithContext(worker.Context, "GET", task.Source, nil)
+	siblings, err := ds.db.GetCompletedSiblingTasks(task)
+	if err != nil || len(siblings) == 0 {
+		return "", false
+	}
+	
+	hash, err := utils.FileChecksum(task.LocalPath, utils.ChecksumSHA256)
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
+		return "", false
 	}
 	
-	// 设置通用的请求头，模拟浏览器行为
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "application/pdf,application/octet-stream,*/*")
-	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
-	req.Header.Set("Cache-Control", "no-cache")
-	req.Header.Set("Pragma", "no-cache")
+	preferred := models.TypeAttachment
+	if config, err := ds.db.GetConfig(); err == nil && config.DuplicateSourcePreference == models.PreferLinkSource {
+		preferred = models.TypeLink
+	}
 	
-	// 特殊处理不同邮件服务商的请求头
-	ds.setServiceSpecificHeaders(req, task.Source)
+	for i := range siblings {
+		sibling := &siblings[i]
+		if sibling.Type == task.Type || (sibling.Type != models.TypeAttachment && sibling.Type != models.TypeLink) {
+			continue
+		}
+		if siblingHash, err := utils.FileChecksum(sibling.LocalPath, utils.ChecksumSHA256); err != nil || siblingHash != hash {
+			continue
+		}
+		
+		duplicate, kept := task, sibling
+		if task.Type == preferred {
+			duplicate, kept = sibling, task
+		}
+		
+		// 两个来源保存为同一文件、或按内容去重的其他任务仍指向该文件时不能删除
+		if duplicate.LocalPath != kept.LocalPath {
+			if count, err := ds.db.CountTasksUsingFile(duplicate.LocalPath); err != nil || count > 1 {
+				ds.logger.Infof("文件 %s 仍被其他任务使用，未删除", duplicate.LocalPath)
+			} else {
+				os.Remove(duplicate.LocalPath)
+			}
+		}
+		
+		message := fmt.Sprintf("与任务 %d 内容相同，已跳过重复的%s来源", kept.ID, duplicate.Type)
+		ds.taskInfof(duplicate.ID, "%s", message)
+		if duplicate == task {
+			return message, true
+		}
+		if err := ds.updateTaskStatus(duplicate.ID, models.StatusCancelled, message, 0, 0, ""); err != nil {
+			ds.logger.Warnf("任务 %d 更新为已跳过失败: %v", duplicate.ID, err)
+		}
+		ds.recordTaskStatistics(duplicate.ID)
+		return "", false
+	}
+	return "", false
+}
+
+// CheckDownloadPath 检测下载目录是否存在、是否可写以及可用空间
+// requiredBytes 大于0时同时检查剩余空间是否足够
+func CheckDownloadPath(path string, requiredBytes int64) models.PathCheck {
+	check := models.PathCheck{Path: path, FreeBytes: -1}
+	if absPath, err := filepath.Abs(path); err == nil {
+		check.Path = absPath
+	}
 	
-	ds.logger.Infof("开始下载URL: %s", task.Source)
+	info, err := os.Stat(check.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			check.Error = "目录不存在，将在首次下载时创建"
+		} else {
+			check.Error = fmt.Sprintf("无法访问目录: %v", err)
+		}
+		return check
+	}
+	check.Exists = true
+	check.IsDir = info.IsDir()
+	if !check.IsDir {
+		check.Error = "路径不是目录"
+		return check
+	}
 	
-	// 发送请求
-	resp, err := worker.Client.Do(req)
+	if free, err := utils.DiskFreeBytes(check.Path); err == nil {
+		check.FreeBytes = free
+	}
+	
+	if err := utils.ProbeWritable(check.Path); err != nil {
+		check.Error = fmt.Sprintf("目录不可写: %v", err)
+		return check
+	}
+	check.Writable = true
+	
+	if requiredBytes > 0 && check.FreeBytes >= 0 && check.FreeBytes < requiredBytes {
+		check.Error = fmt.Sprintf("磁盘空间不足（需要 %s，可用 %s）",
+			utils.FormatBytes(requiredBytes), utils.FormatBytes(check.FreeBytes))
+	}
+	return check
+}
+
+// NormalizeAccountDownloadPath 校验账户专用下载目录，空字符串表示使用全局下载目录
+// 目录必须是绝对路径；已存在时必须是可写的目录，不存在时在首次下载时创建
+func NormalizeAccountDownloadPath(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("账户下载目录必须是绝对路径: %s", path)
+	}
+	path = filepath.Clean(path)
+	
+	check := CheckDownloadPath(path, 0)
+	if check.Exists && (!check.IsDir || !check.Writable) {
+		return "", fmt.Errorf("账户下载目录 %s 不可用: %s", path, check.Error)
+	}
+	return path, nil
+}
+
+// recordChecksum 按配置的算法计算已完成文件的校验和并保存到任务
+func (ds *DownloadService) recordChecksum(task *models.DownloadTask) {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.ChecksumAlgorithm == "" {
+		return
+	}
+	
+	if _, err := ds.computeChecksum(task, config.ChecksumAlgorithm); err != nil {
+		ds.logger.Warnf("任务 %d 计算校验和失败: %v", task.ID, err)
+	}
+}
+
+// computeChecksum 计算任务文件的校验和并保存
+func (ds *DownloadService) computeChecksum(task *models.DownloadTask, algorithm string) (string, error) {
+	checksum, err := utils.FileChecksum(task.LocalPath, algorithm)
 	if err != nil {
-		return fmt.Errorf("请求失败: %v", err)
+		return "", err
 	}
-	defer resp.Body.Close()
 	
-	ds.logger.Infof("服务器响应状态: %d, Content-Type: %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	if err := ds.db.UpdateTaskChecksum(task.ID, algorithm, checksum); err != nil {
+		return "", fmt.Errorf("保存校验和失败: %v", err)
+	}
+	return checksum, nil
+}
+
+// GetTaskChecksum 获取已完成任务的文件校验和，尚未计算时按配置的算法（默认SHA-256）即时计算
+func (ds *DownloadService) GetTaskChecksum(taskID uint) (string, string, error) {
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		return "", "", fmt.Errorf("获取任务失败: %v", err)
+	}
+	if task.Status != models.StatusCompleted {
+		return "", "", fmt.Errorf("任务尚未完成下载")
+	}
+	if task.Checksum != "" {
+		return task.ChecksumAlgorithm, task.Checksum, nil
+	}
 	
-	// 处理重定向和特殊状态码
-	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently {
-		location := resp.Header.Get("Location")
-		if location != "" {
-			ds.logger.Infof("处理重定向到: %s", location)
-			// 递归处理重定向（最多3次）
-			return ds.handleRedirect(worker, location, 0)
-		}
+	algorithm := utils.ChecksumSHA256
+	if config, err := ds.db.GetConfig(); err == nil && config.ChecksumAlgorithm != "" {
+		algorithm = config.ChecksumAlgorithm
 	}
 	
-	if resp.StatusCode != http.StatusOK {
-		// 读取错误响应内容
-		body, _ := io.ReadAll(resp.Body)
-		ds.logger.Errorf("服务器响应错误: %d, 内容: %s", resp.StatusCode, string(body[:min(len(body), 500)]))
-		return fmt.Errorf("服务器响应错误: %d", resp.StatusCode)
+	checksum, err := ds.computeChecksum(task, algorithm)
+	if err != nil {
+		return "", "", err
+	}
+	return algorithm, checksum, nil
+}
+
+// downloadFromURL 从URL下载文件（增强版，支持各种邮件服务商）
+func (ds *DownloadService) downloadFromURL(worker *DownloadWorker) error {
+	task := worker.Task
+	
+	// 暂停或失败后保留的临时文件按已记录的进度续传
+	tempPath := task.LocalPath + tempFileSuffix
+	offset := ds.resumeOffset(task, tempPath)
+	if offset > 0 {
+		ds.logger.Infof("开始续传URL: %s（已下载 %s）", task.Source, utils.FormatBytes(offset))
+	} else {
+		ds.logger.Infof("开始下载URL: %s", task.Source)
+	}
+	
+	resp, offset, err := ds.requestLink(worker, task.Source, offset)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 	
 	// 验证内容类型
 	contentType := resp.Header.Get("Content-Type")
@@ -577,11 +911,12 @@ func (ds *DownloadService) downloadFromURL(worker *DownloadWorker) error {
 		ds.logger.Warnf("可疑的内容类型: %s，继续尝试下载", contentType)
 	}
 	
-	// 获取文件大小
-	contentLength := resp.ContentLength
-	if contentLength > 0 {
-		task.FileSize = contentLength
-		ds.logger.Infof("文件大小: %s", utils.FormatBytes(contentLength))
+	// 获取文件大小，续传时为已下载部分加上剩余部分
+	if resp.ContentLength > 0 {
+		task.FileSize = offset + resp.ContentLength
+	}
+	if task.FileSize > 0 {
+		ds.logger.Infof("文件大小: %s", utils.FormatBytes(task.FileSize))
 	}
 	
 	// 创建目录
@@ -589,25 +924,56 @@ func (ds *DownloadService) downloadFromURL(worker *DownloadWorker) error {
 		return fmt.Errorf("创建目录失败: %v", err)
 	}
 	
-	// 创建临时文件
-	tempPath := task.LocalPath + ".tmp"
-	file, err := os.Create(tempPath)
+	// 续传时以追加方式打开临时文件，否则重新创建
+	file, err := openLinkTempFile(tempPath, offset)
 	if err != nil {
 		return fmt.Errorf("创建临时文件失败: %v", err)
 	}
 	defer file.Close()
-	
-	// 下载文件并监控进度
-	err = ds.downloadWithProgress(worker, resp.Body, file)
+	atomic.StoreInt64(&worker.written, offset)
+	
+	// 下载文件并监控进度，连接中途断开时原地续传几次再放弃
+	err = ds.downloadWithProgress(worker, resp.Body, file, offset)
+	attempts := ds.quickRetryAttempts()
+	for attempt := 1; attempt <= attempts && errors.Is(err, errStreamInterrupted) && worker.Context.Err() == nil; attempt++ {
+		err = ds.resumeLinkDownload(worker, resp.Request.URL.String(), file, attempt, err)
+	}
+	if errors.Is(err, errFileTooLarge) {
+		// 超过大小上限的文件重试也不会成功，不保留临时文件
+		os.Remove(tempPath)
+		atomic.StoreInt64(&worker.written, 0)
+		return err
+	}
 	if err != nil {
+		// 应用关闭、用户暂停或下载失败时保留已写入的临时文件，下次从该位置续传
+		cancelled := worker.Context.Err() != nil && !worker.paused.Load()
+		if ds.isStopping() || (!cancelled && atomic.LoadInt64(&worker.written) > 0) {
+			file.Sync()
+			return err
+		}
 		os.Remove(tempPath) // 清理临时文件
 		return err
 	}
 	
 	// 验证下载的文件是否为有效PDF
-	if err := utils.ValidatePDFFile(tempPath); err != nil {
-		os.Remove(tempPath) // 删除无效文件
-		return fmt.Errorf("下载的文件不是有效的PDF: %v", err)
+	if err := ds.validatePDF(tempPath); err != nil {
+		file.Close()
+		ds.discardInvalidFile(task, tempPath, err) // 删除或隔离无效文件
+		return fmt.Errorf("下载的文件不是有效的PDF: %w", err)
+	}
+	
+	// 服务器通过 Content-Disposition 给出的文件名比从URL推断的更准确，
+	// 没有时按 Content-Type 补全URL文件名的扩展名
+	ds.applyResponseFilename(task, resp.Header)
+	
+	// 与已完成任务的内容相同时不再保存
+	hash, err := utils.FileChecksum(tempPath, utils.ChecksumSHA256)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 计算内容哈希失败: %v", task.ID, err)
+	}
+	if ds.reuseDuplicate(task, hash) {
+		os.Remove(tempPath)
+		return nil
 	}
 	
 	// 原子性重命名文件
@@ -615,11 +981,63 @@ func (ds *DownloadService) downloadFromURL(worker *DownloadWorker) error {
 		os.Remove(tempPath) // 清理临时文件
 		return fmt.Errorf("完成文件写入失败: %v", err)
 	}
+	ds.saveContentHash(task, hash)
 	
 	ds.logger.Infof("成功下载文件: %s", task.LocalPath)
 	return nil
 }
 
+// applyResponseFilename 使用响应头中的文件名作为最终文件名，同名文件已存在时自动添加序号
+// 没有 Content-Disposition 文件名时，按 Content-Type 修正从URL推断的扩展名（如 download.php 改为 download.pdf）
+func (ds *DownloadService) applyResponseFilename(task *models.DownloadTask, header http.Header) {
+	extensions := ds.taskExtensions(task)
+	var fileName string
+	if name := utils.FilenameFromContentDisposition(header.Get("Content-Disposition")); name != "" {
+		fileName = utils.CleanFilenameFor(name, extensions)
+	} else {
+		fileName = utils.FilenameFromURLAndType(task.Source, header.Get("Content-Type"), extensions)
+		if fileName == "" || fileName == utils.FilenameFromURLAndType(task.Source, "", extensions) {
+			return // 内容类型没有改变从URL推断的文件名
+		}
+	}
+	if fileName == task.FileName {
+		return
+	}
+	
+	localPath, err := utils.UniqueFilePath(filepath.Dir(task.LocalPath), fileName)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 无法使用服务器提供的文件名 %s: %v", task.ID, fileName, err)
+		return
+	}
+	fileName = filepath.Base(localPath)
+	
+	if err := ds.db.UpdateTaskFile(task.ID, fileName, localPath); err != nil {
+		ds.logger.Warnf("任务 %d 更新文件名失败: %v", task.ID, err)
+		return
+	}
+	ds.taskInfof(task.ID, "使用服务器提供的文件名: %s", fileName)
+	task.FileName, task.LocalPath = fileName, localPath
+}
+
+// newLinkRequest 创建下载链接的请求，设置模拟浏览器的通用请求头和服务商特定的请求头
+func (ds *DownloadService) newLinkRequest(worker *DownloadWorker, source string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(worker.Context, "GET", source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	
+	// 设置通用的请求头，模拟浏览器行为
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Accept", "application/pdf,application/octet-stream,*/*")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Pragma", "no-cache")
+	
+	// 特殊处理不同邮件服务商的请求头
+	ds.setServiceSpecificHeaders(req, source)
+	return req, nil
+}
+
 // setServiceSpecificHeaders 为不同邮件服务商设置特定的请求头
 func (ds *DownloadService) setServiceSpecificHeaders(req *http.Request, url string) {
 	urlLower := strings.ToLower(url)
@@ -643,23 +1061,74 @@ func (ds *DownloadService) setServiceSpecificHeaders(req *http.Request, url stri
 	}
 }
 
-// handleRedirect 处理重定向
-func (ds *DownloadService) handleRedirect(worker *DownloadWorker, location string, depth int) error {
-	if depth >= 3 {
-		return fmt.Errorf("重定向次数过多")
+const (
+	// defaultMaxRedirects 默认最多跟随的重定向次数
+	defaultMaxRedirects = 5
+	// MaxRedirectsLimit 可配置的最大重定向次数上限
+	MaxRedirectsLimit = 10
+)
+
+// maxRedirects 返回配置的最大重定向次数，未配置或超出范围时使用默认值
+func (ds *DownloadService) maxRedirects() int {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.MaxRedirects <= 0 || config.MaxRedirects > MaxRedirectsLimit {
+		return defaultMaxRedirects
 	}
-	
-	// 更新任务源地址
-	originalSource := worker.Task.Source
-	worker.Task.Source = location
-	
-	// 递归下载
-	err := ds.downloadFromURL(worker)
-	
-	// 恢复原始源地址
-	worker.Task.Source = originalSource
-	
-	return err
+	return config.MaxRedirects
+}
+
+// redirectPolicy 返回 http.Client 的重定向检查函数：限制跳转次数、发现循环时中止，并记录每一跳
+// 网页邮箱的下载链接常经过 登录 → 令牌 → CDN → 文件 多次跳转
+func (ds *DownloadService) redirectPolicy() func(req *http.Request, via []*http.Request) error {
+	maxHops := ds.maxRedirects()
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxHops {
+			return fmt.Errorf("重定向次数过多（超过 %d 次）", maxHops)
+		}
+		
+		// 设置Cookie后跳回原地址是常见的登录流程，同一地址第三次出现才视为循环
+		target := req.URL.String()
+		visits := 0
+		for _, previous := range via {
+			if previous.URL.String() == target {
+				visits++
+			}
+		}
+		if visits >= 2 {
+			return fmt.Errorf("检测到重定向循环: %s", target)
+		}
+		
+		// 每一跳都按最初的链接设置服务商需要的请求头（http.Client 在跳转到 http 地址时会去掉 Referer）
+		ds.setServiceSpecificHeaders(req, via[0].URL.String())
+		
+		status := 0
+		if req.Response != nil {
+			status = req.Response.StatusCode
+		}
+		ds.logger.Infof("重定向 %d/%d（状态码 %d）: %s -> %s", len(via), maxHops, status, via[len(via)-1].URL, target)
+		return nil
+	}
+}
+
+// newLinkClient 创建下载链接使用的 http.Client：跟随重定向，并用独立的Cookie容器保存中间跳转设置的Cookie
+// QQ邮箱等服务的下载链接在中间跳转时通过 Set-Cookie 下发访问凭据，丢失后最终地址会拒绝下载
+func (ds *DownloadService) newLinkClient(transport http.RoundTripper) *http.Client {
+	// 每个下载使用自己的Cookie容器，不同任务之间不共享Cookie
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{
+		Timeout:       30 * time.Second,
+		CheckRedirect: ds.redirectPolicy(),
+		Jar:           jar,
+		Transport:     transport,
+	}
+}
+
+// validatePDF 验证下载的PDF文件，开启严格验证时同时检查交叉引用表
+func (ds *DownloadService) validatePDF(path string) error {
+	if config, err := ds.db.GetConfig(); err == nil && config.StrictPDFValidation {
+		return utils.ValidatePDFStrict(path)
+	}
+	return utils.ValidatePDFFile(path)
 }
 
 // isValidPDFContentType 检查内容类型是否可能是PDF
@@ -699,48 +1168,136 @@ func min(a, b int) int {
 func (ds *DownloadService) downloadAttachment(worker *DownloadWorker) error {
 	task := worker.Task
 	
-	// 获取邮箱账户信息
-	account := &task.EmailAccount
-	if account.ID == 0 {
+	// 获取完整的邮箱账户信息（任务查询只关联了基本字段）
+	if task.EmailAccount.ID == 0 {
 		return fmt.Errorf("无效的邮箱账户信息")
 	}
+	account, err := ds.db.GetEmailAccountByID(task.EmailID)
+	if err != nil {
+		return fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
 	
-	// 创建安全的邮件服务来获取附件
-	emailService := ds.createEmailServiceForDownload(worker.Context)
+	// 附件获取设置整体期限，避免卡住的大附件传输一直占用并发槽位
+	deadline := ds.getAttachmentDeadline()
+	fetchCtx, cancelFetch := context.WithTimeout(worker.Context, deadline)
+	defer cancelFetch()
 	
-	// 连接到邮箱
-	conn, err := emailService.createConnectionWithTimeout(worker.Context, account)
+	// 从连接池取得账户的连接，服务器允许时同一账户的多个附件并行获取
+	conn, err := ds.attachmentPool.acquire(fetchCtx, account)
 	if err != nil {
 		return fmt.Errorf("连接邮箱失败: %v", err)
 	}
+	reusable := false
 	defer func() {
-		// 安全关闭连接
+		// 成功获取附件的连接放回连接池，出错的连接可能处于未知状态，直接关闭
 		defer func() {
 			if r := recover(); r != nil {
 				// 忽略关闭连接时的panic
 			}
 		}()
-		conn.close()
+		ds.attachmentPool.release(conn, reusable)
 	}()
 	
-	// 选择收件箱
-	if err := conn.selectInbox(); err != nil {
-		return fmt.Errorf("选择收件箱失败: %v", err)
+	// 选择邮件所在的文件夹
+	if err := selectTaskFolder(conn, task); err != nil {
+		return err
 	}
 	
-	// 搜索包含指定附件的邮件
-	attachmentData, err := ds.findAndDownloadAttachment(conn, task)
+	// 搜索包含指定附件的邮件，连接发生异常时重新连接后再试一次
+	var attachmentData []byte
+	err = recycleAfterPanic(ds.logger, &conn, func() (*IMAPConnection, error) {
+		ds.attachmentPool.release(conn, false)
+		newConn, connErr := ds.attachmentPool.acquire(fetchCtx, account)
+		if connErr != nil {
+			return nil, connErr
+		}
+		if selectErr := selectTaskFolder(newConn, task); selectErr != nil {
+			ds.attachmentPool.release(newConn, false)
+			return nil, selectErr
+		}
+		return newConn, nil
+	}, func(c *IMAPConnection) error {
+		var findErr error
+		attachmentData, findErr = ds.findAttachmentWithDeadline(fetchCtx, c, task, deadline)
+		return findErr
+	})
+	attempts := ds.quickRetryAttempts()
+	for attempt := 1; attempt <= attempts && err != nil && fetchCtx.Err() == nil && (isTransientIMAPError(err) || !conn.isAlive()); attempt++ {
+		// 连接在搜索或获取过程中失效，稍等后重新连接再试（UID需要重新搜索）
+		ds.taskWarnf(task.ID, "获取附件时连接中断，第 %d/%d 次重新连接后重试: %v", attempt, attempts, err)
+		ds.attachmentPool.release(conn, false)
+		if !waitQuickRetry(fetchCtx, attempt) {
+			break
+		}
+		
+		newConn, connErr := ds.attachmentPool.acquire(fetchCtx, account)
+		if connErr != nil {
+			err = fmt.Errorf("重新连接邮箱失败: %v", connErr)
+			continue
+		}
+		conn = newConn
+		if err = selectTaskFolder(conn, task); err != nil {
+			continue
+		}
+		attachmentData, err = ds.findAttachmentWithDeadline(fetchCtx, conn, task, deadline)
+	}
 	if err != nil {
 		return fmt.Errorf("下载附件失败: %v", err)
 	}
+	reusable = true
 	
 	if len(attachmentData) == 0 {
 		return fmt.Errorf("未找到指定的附件")
 	}
 	
-	// 验证是否为有效的PDF文件
-	if !utils.IsPDFContent(attachmentData) {
-		return fmt.Errorf("附件不是有效的PDF文件")
+	if isZipTask(task) && ds.shouldExtractZip() {
+		return ds.saveZipData(worker, attachmentData)
+	}
+	return ds.saveAttachmentData(worker, attachmentData)
+}
+
+// selectTaskFolder 选择任务邮件所在的文件夹，额外扫描的文件夹以只读方式打开
+func selectTaskFolder(conn *IMAPConnection, task *models.DownloadTask) error {
+	if task.Folder == "" {
+		if err := conn.selectInbox(); err != nil {
+			return fmt.Errorf("选择收件箱失败: %v", err)
+		}
+		return nil
+	}
+	
+	if _, err := conn.selectFolder(task.Folder, true); err != nil {
+		return fmt.Errorf("选择文件夹 %s 失败: %v", task.Folder, err)
+	}
+	return nil
+}
+
+// saveAttachmentData 验证并原子性地保存下载到内存中的附件内容，完成后发送进度
+// 按任务文件的扩展名验证内容类型，没有扩展名时按PDF处理
+func (ds *DownloadService) saveAttachmentData(worker *DownloadWorker, attachmentData []byte) error {
+	task := worker.Task
+	
+	ext := fileExtension(task.FileName)
+	if ext == "" {
+		ext = models.DefaultExtension
+	}
+	
+	// 验证内容是否与文件类型相符
+	if !utils.MatchesFileType(attachmentData, ext) {
+		err := fmt.Errorf("附件不是有效的%s文件", strings.ToUpper(ext))
+		ds.quarantineData(task, attachmentData, err)
+		return err
+	}
+	
+	// 与已完成任务的内容相同时不再保存，直接完成
+	hash := contentHash(attachmentData)
+	if ds.reuseDuplicate(task, hash) {
+		worker.Progress <- ProgressUpdate{
+			TaskID:         task.ID,
+			DownloadedSize: int64(len(attachmentData)),
+			Progress:       100,
+			Status:         models.StatusCompleted,
+		}
+		return nil
 	}
 	
 	// 创建目录
@@ -749,15 +1306,17 @@ func (ds *DownloadService) downloadAttachment(worker *DownloadWorker) error {
 	}
 	
 	// 原子性写入文件
-	tempPath := task.LocalPath + ".tmp"
+	tempPath := task.LocalPath + tempFileSuffix
 	if err := os.WriteFile(tempPath, attachmentData, 0644); err != nil {
 		return fmt.Errorf("写入临时文件失败: %v", err)
 	}
 	
-	// 验证写入的文件
-	if err := utils.ValidatePDFFile(tempPath); err != nil {
-		os.Remove(tempPath) // 删除无效文件
-		return fmt.Errorf("PDF文件验证失败: %v", err)
+	// 验证写入的PDF文件
+	if ext == models.DefaultExtension {
+		if err := ds.validatePDF(tempPath); err != nil {
+			ds.discardInvalidFile(task, tempPath, err) // 删除或隔离无效文件
+			return fmt.Errorf("PDF文件验证失败: %w", err)
+		}
 	}
 	
 	// 原子性重命名文件
@@ -765,6 +1324,7 @@ func (ds *DownloadService) downloadAttachment(worker *DownloadWorker) error {
 		os.Remove(tempPath) // 清理临时文件
 		return fmt.Errorf("完成文件写入失败: %v", err)
 	}
+	ds.saveContentHash(task, hash)
 	
 	// 发送完成进度
 	worker.Progress <- ProgressUpdate{
@@ -774,51 +1334,227 @@ func (ds *DownloadService) downloadAttachment(worker *DownloadWorker) error {
 		Status:         models.StatusCompleted,
 	}
 	
-	return nil
-}
-
-// createEmailServiceForDownload 创建用于下载的安全EmailService实例
-func (ds *DownloadService) createEmailServiceForDownload(ctx context.Context) *EmailService {
-	// 创建专用的logger
-	logger := logrus.New()
-	logger.SetLevel(logrus.WarnLevel) // 下载时使用较低的日志级别
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp: true,
+	return nil
+}
+
+// createEmailServiceForDownload 创建用于下载的安全EmailService实例
+func (ds *DownloadService) createEmailServiceForDownload(ctx context.Context) *EmailService {
+	// 创建专用的logger
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel) // 下载时使用较低的日志级别
+	logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+	utils.SetupLogger(logger)
+	
+	// 创建带超时的上下文
+	downloadCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	
+	return &EmailService{
+		db:               ds.db,
+		connections:      make(map[uint]*IMAPConnection),
+		connectionsMutex: sync.RWMutex{},
+		limiter:          ds.connLimiter,
+		downloadService:  nil, // 避免循环引用
+		ctx:              downloadCtx,
+		cancel:           cancel,
+		checkInterval:    time.Hour, // 不需要定期检查
+		isRunning:        false,
+		runningMutex:     sync.RWMutex{},
+		logger:           logger,
+	}
+}
+
+// defaultDownloadDeadline 未配置时单个附件下载的整体期限
+const defaultDownloadDeadline = 5 * time.Minute
+
+// getAttachmentDeadline 获取配置的附件下载整体期限
+func (ds *DownloadService) getAttachmentDeadline() time.Duration {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.DownloadDeadlineMinutes <= 0 {
+		return defaultDownloadDeadline
+	}
+	return time.Duration(config.DownloadDeadlineMinutes) * time.Minute
+}
+
+// findAttachmentWithDeadline 在期限内查找并获取附件，超时后强制断开连接使阻塞的FETCH返回
+func (ds *DownloadService) findAttachmentWithDeadline(ctx context.Context, conn *IMAPConnection, task *models.DownloadTask, deadline time.Duration) ([]byte, error) {
+	type fetchResult struct {
+		data []byte
+		err  error
+	}
+	
+	done := make(chan fetchResult, 1)
+	go func() {
+		// 获取在独立的协程中进行，panic转换为错误返回，由调用方重新建立连接
+		var data []byte
+		err := recoverIMAPPanic(func() error {
+			var findErr error
+			data, findErr = ds.findAndDownloadAttachment(conn, task)
+			return findErr
+		})
+		done <- fetchResult{data: data, err: err}
+	}()
+	
+	select {
+	case result := <-done:
+		return result.data, result.err
+	case <-ctx.Done():
+		conn.terminate()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("附件下载超过期限（%v），已中止", deadline)
+		}
+		return nil, fmt.Errorf("附件下载已取消")
+	}
+}
+
+// validateUID 验证获取到的邮件UID是否与请求的一致
+func (ds *DownloadService) validateUID(expectedUID, actualUID uint32, operation string) bool {
+	if actualUID == 0 {
+		ds.logger.Errorf("UID验证失败 - %s: UID为0，可能是Fetch操作缺少imap.FetchUid", operation)
+		return false
+	}
+	if expectedUID != actualUID {
+		// UID不匹配通常是因为：
+		// 1. 邮箱状态在搜索和获取之间发生了变化
+		// 2. IMAP服务器实现差异
+		// 3. 搜索使用的是序列号而不是UID
+		// 返回的邮件不一定是目标邮件，直接使用可能下载到错误的附件
+		ds.logger.Warnf("UID不匹配 - %s: 期望=%d, 实际=%d", operation, expectedUID, actualUID)
+		return false
+	}
+	ds.logger.Debugf("UID验证成功 - %s: UID=%d", operation, actualUID)
+	return true
+}
+
+// uidMismatchTolerated 服务器已知会返回不一致的UID时，返回的邮件与任务匹配即可直接使用
+func (ds *DownloadService) uidMismatchTolerated(conn *IMAPConnection, task *models.DownloadTask, msg *imap.Message) bool {
+	return conn.Quirks.TolerateUIDMismatch && msg.Uid != 0 && msg.Envelope != nil && envelopeMatchesTask(msg.Envelope, task)
+}
+
+// remapStaleUID UID失效时重新定位目标邮件的正确UID，而不是直接使用服务器返回的邮件
+// 返回的邮件就是目标邮件时按Message-ID定位，否则重新搜索并按主题、发件人和日期匹配
+func (ds *DownloadService) remapStaleUID(conn *IMAPConnection, task *models.DownloadTask, returned *imap.Message) (uint32, error) {
+	if returned.Envelope != nil && returned.Envelope.MessageId != "" && envelopeMatchesTask(returned.Envelope, task) {
+		uids, err := ds.searchByMessageID(conn, returned.Envelope.MessageId)
+		if err == nil && len(uids) == 1 {
+			ds.logger.Infof("按Message-ID重新定位邮件 - 新UID: %d", uids[0])
+			return uids[0], nil
+		}
+		ds.logger.Debugf("按Message-ID重新定位邮件失败（结果: %d 封）: %v", len(uids), err)
+	}
+	
+	uids, err := ds.searchEmailsSafely(conn, task.Subject, task.Sender)
+	if err != nil {
+		return 0, fmt.Errorf("重新搜索邮件失败: %v", err)
+	}
+	
+	envelopes, err := ds.fetchEnvelopesByUID(conn, uids)
+	if err != nil {
+		return 0, err
+	}
+	
+	bestUID := closestMatchingUID(envelopes, task)
+	if bestUID == 0 {
+		return 0, fmt.Errorf("重新搜索后未找到与任务匹配的邮件")
+	}
+	ds.logger.Infof("按主题和发件人重新定位邮件 - 新UID: %d", bestUID)
+	return bestUID, nil
+}
+
+// closestMatchingUID 在重新搜索到的邮件中选择与任务匹配的邮件，没有匹配时返回0
+// 同一主题和发件人可能有多封邮件，优先选择日期最接近任务创建时间的
+func closestMatchingUID(envelopes map[uint32]*imap.Envelope, task *models.DownloadTask) uint32 {
+	createdAt, parseErr := models.StringToTime(task.CreatedAt)
+	if parseErr == nil && createdAt.IsZero() {
+		parseErr = fmt.Errorf("任务创建时间为空")
+	}
+	var bestUID uint32
+	bestDiff := time.Duration(math.MaxInt64)
+	for uid, envelope := range envelopes {
+		if !envelopeMatchesTask(envelope, task) {
+			continue
+		}
+		
+		// 无法比较日期时选择最新的邮件（UID最大）
+		if parseErr != nil || envelope.Date.IsZero() {
+			if bestDiff == math.MaxInt64 && uid > bestUID {
+				bestUID = uid
+			}
+			continue
+		}
+		
+		diff := envelope.Date.Sub(createdAt)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			bestUID, bestDiff = uid, diff
+		}
+	}
+	return bestUID
+}
+
+// searchByMessageID 按Message-ID头搜索邮件UID
+func (ds *DownloadService) searchByMessageID(conn *IMAPConnection, messageID string) ([]uint32, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+	
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Set("Message-ID", messageID)
+	return conn.Client.UidSearch(criteria)
+}
+
+// fetchEnvelopesByUID 获取邮件信封，返回UID到信封的映射
+func (ds *DownloadService) fetchEnvelopesByUID(conn *IMAPConnection, uids []uint32) (map[uint32]*imap.Envelope, error) {
+	envelopes := make(map[uint32]*imap.Envelope)
+	if len(uids) == 0 {
+		return envelopes, nil
+	}
+	
+	// 限制检查的邮件数量
+	if len(uids) > 50 {
+		uids = uids[len(uids)-50:]
+	}
+	
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	messages := make(chan *imap.Message, len(uids))
+	
+	err := conn.withLock(func() error {
+		return conn.Client.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope}, messages)
 	})
 	
-	// 创建带超时的上下文
-	downloadCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	if err != nil {
+		return nil, fmt.Errorf("获取邮件信息失败: %v", err)
+	}
 	
-	return &EmailService{
-		db:               ds.db,
-		connections:      make(map[uint]*IMAPConnection),
-		connectionsMutex: sync.RWMutex{},
-		downloadService:  nil, // 避免循环引用
-		ctx:              downloadCtx,
-		cancel:           cancel,
-		checkInterval:    time.Hour, // 不需要定期检查
-		isRunning:        false,
-		runningMutex:     sync.RWMutex{},
-		logger:           logger,
+	for msg := range messages {
+		if msg != nil && msg.Uid != 0 && msg.Envelope != nil {
+			envelopes[msg.Uid] = msg.Envelope
+		}
 	}
+	return envelopes, nil
 }
 
-// validateUID 验证并记录UID信息，用于调试UID问题（改进版）
-func (ds *DownloadService) validateUID(expectedUID, actualUID uint32, operation string) {
-	if actualUID == 0 {
-		ds.logger.Errorf("UID验证失败 - %s: UID为0，可能是Fetch操作缺少imap.FetchUid", operation)
-	} else if expectedUID != actualUID {
-		ds.logger.Warnf("UID不匹配 - %s: 期望=%d, 实际=%d", operation, expectedUID, actualUID)
-		// 注意：UID不匹配在某些IMAP服务器中是正常的，特别是在搜索和获取操作之间
-		// 这可能是由于：
-		// 1. 邮箱状态在搜索和获取之间发生了变化
-		// 2. IMAP服务器实现差异
-		// 3. 搜索使用的是序列号而不是UID
-		// 我们记录警告但允许下载继续进行，使用实际获取到的UID
-		ds.logger.Infof("UID不匹配被容忍，继续使用实际UID: %d", actualUID)
-	} else {
-		ds.logger.Debugf("UID验证成功 - %s: UID=%d", operation, actualUID)
+// envelopeMatchesTask 检查邮件的主题和发件人是否与任务一致
+func envelopeMatchesTask(envelope *imap.Envelope, task *models.DownloadTask) bool {
+	if task.Subject != "" && utils.NormalizeSubject(envelope.Subject) != utils.NormalizeSubject(task.Subject) {
+		return false
+	}
+	if task.Sender == "" {
+		return true
+	}
+	for _, addr := range envelope.From {
+		if addr != nil && strings.EqualFold(addr.Address(), task.Sender) {
+			return true
+		}
 	}
+	return false
 }
 
 // findAndDownloadAttachment 查找并下载指定的附件（重构版，支持PDF链接和传统附件）
@@ -842,7 +1578,7 @@ func (ds *DownloadService) findAndDownloadAttachment(conn *IMAPConnection, task
 		ds.logger.Infof("处理邮件 %d/%d (搜索UID: %d)", i+1, len(uids), uid)
 		
 		// 首先尝试从邮件内容中提取PDF链接
-		pdfData, err := ds.extractPDFFromEmail(conn, uid, task.FileName)
+		pdfData, err := ds.extractPDFFromEmail(conn, uid, task)
 		if err == nil && len(pdfData) > 0 {
 			ds.logger.Infof("成功从邮件 UID %d 提取PDF (大小: %d bytes)", uid, len(pdfData))
 			return pdfData, nil
@@ -854,24 +1590,76 @@ func (ds *DownloadService) findAndDownloadAttachment(conn *IMAPConnection, task
 }
 
 // extractPDFFromEmail 从邮件中提取PDF（支持附件和链接）
-func (ds *DownloadService) extractPDFFromEmail(conn *IMAPConnection, uid uint32, targetFileName string) ([]byte, error) {
-	// 获取完整的邮件内容
+// 获取到的邮件UID与请求的不一致时，重新定位目标邮件后再获取
+func (ds *DownloadService) extractPDFFromEmail(conn *IMAPConnection, uid uint32, task *models.DownloadTask) ([]byte, error) {
+	targetFileName := task.FileName
+	
+	msg, err := ds.fetchEmailContent(conn, uid)
+	if err != nil {
+		return nil, err
+	}
+	
+	uidMatches := ds.validateUID(uid, msg.Uid, "邮件内容获取")
+	if !uidMatches && ds.uidMismatchTolerated(conn, task, msg) {
+		ds.logger.Infof("服务器返回的UID %d 与请求的 %d 不一致，邮件与任务匹配，按兼容处理直接使用", msg.Uid, uid)
+		uidMatches = true
+	}
+	if !uidMatches {
+		newUID, err := ds.remapStaleUID(conn, task, msg)
+		if err != nil {
+			return nil, fmt.Errorf("UID %d 已失效且无法重新定位: %v", uid, err)
+		}
+		
+		msg, err = ds.fetchEmailContent(conn, newUID)
+		if err != nil {
+			return nil, err
+		}
+		if !ds.validateUID(newUID, msg.Uid, "重新定位后获取邮件内容") {
+			return nil, fmt.Errorf("重新定位后UID仍不一致 (期望: %d, 实际: %d)", newUID, msg.Uid)
+		}
+	}
+	
+	ds.logger.Infof("成功获取邮件内容 (UID: %d)", msg.Uid)
+	
+	// 方法1: 尝试从邮件内容中提取PDF链接（其他类型的附件只能从附件部分获取）
+	if isPDFTarget(targetFileName) {
+		if pdfData, err := ds.extractPDFFromEmailContent(msg, targetFileName); err == nil && len(pdfData) > 0 {
+			return pdfData, nil
+		}
+	}
+	
+	// 方法2: 尝试从传统附件中提取PDF
+	if msg.BodyStructure != nil {
+		if pdfData, err := ds.extractPDFFromAttachment(conn, msg.Uid, msg.BodyStructure, targetFileName); err == nil && len(pdfData) > 0 {
+			return pdfData, nil
+		}
+	}
+	
+	return nil, fmt.Errorf("未找到PDF内容")
+}
+
+// fetchEmailContent 按UID获取完整的邮件内容
+func (ds *DownloadService) fetchEmailContent(conn *IMAPConnection, uid uint32) (*imap.Message, error) {
 	seqset := new(imap.SeqSet)
 	seqset.AddNum(uid)
 	
 	messages := make(chan *imap.Message, 1)
 	
-	conn.Mutex.Lock()
-	// 关键修复：使用UidFetch而不是Fetch，确保UID一致性
-	err := conn.Client.UidFetch(seqset, []imap.FetchItem{
+	items := []imap.FetchItem{
 		imap.FetchUid,          
 		imap.FetchBodyStructure,
 		imap.FetchEnvelope,
-		"BODY[TEXT]",  // 获取邮件正文
-		"BODY[1]",     // 获取第一个body部分
-		"BODY[]",      // 获取完整邮件内容
-	}, messages)
-	conn.Mutex.Unlock()
+		"BODY.PEEK[TEXT]", // 获取邮件正文，PEEK不改变已读状态
+		"BODY.PEEK[1]",    // 获取第一个body部分
+	}
+	if !conn.Quirks.AvoidFullBody {
+		items = append(items, "BODY.PEEK[]") // 获取完整邮件内容
+	}
+	
+	// 关键修复：使用UidFetch而不是Fetch，确保UID一致性
+	err := conn.withLock(func() error {
+		return conn.Client.UidFetch(seqset, items, messages)
+	})
 	
 	if err != nil {
 		return nil, fmt.Errorf("获取邮件内容失败: %v", err)
@@ -887,24 +1675,7 @@ func (ds *DownloadService) extractPDFFromEmail(conn *IMAPConnection, uid uint32,
 		return nil, fmt.Errorf("获取邮件内容超时")
 	}
 	
-	// 验证UID是否正确获取
-	ds.validateUID(uid, msg.Uid, "邮件内容获取")
-	
-	ds.logger.Infof("成功获取邮件内容 (UID: %d)", msg.Uid)
-	
-	// 方法1: 尝试从邮件内容中提取PDF链接
-	if pdfData, err := ds.extractPDFFromEmailContent(msg, targetFileName); err == nil && len(pdfData) > 0 {
-		return pdfData, nil
-	}
-	
-	// 方法2: 尝试从传统附件中提取PDF
-	if msg.BodyStructure != nil {
-		if pdfData, err := ds.extractPDFFromAttachment(conn, msg.Uid, msg.BodyStructure, targetFileName); err == nil && len(pdfData) > 0 {
-			return pdfData, nil
-		}
-	}
-	
-	return nil, fmt.Errorf("未找到PDF内容")
+	return msg, nil
 }
 
 // extractPDFFromEmailContent 从邮件内容中提取PDF（支持PDF链接）
@@ -914,7 +1685,7 @@ func (ds *DownloadService) extractPDFFromEmailContent(msg *imap.Message, targetF
 	
 	// 尝试从不同的body部分获取内容
 	for section, body := range msg.Body {
-		ds.logger.Debugf("处理邮件部分: %s", section)
+		ds.logger.Debugf("处理邮件部分: %v", section)
 		
 		if body != nil {
 			content, err := ioutil.ReadAll(body)
@@ -930,13 +1701,13 @@ func (ds *DownloadService) extractPDFFromEmailContent(msg *imap.Message, targetF
 	
 	ds.logger.Debugf("邮件内容长度: %d", len(bodyContent))
 	
-	// 从邮件内容中提取PDF链接
-	pdfLinks := ds.extractPDFLinksFromContent(bodyContent)
+	// 从邮件内容中提取PDF链接，按可能性从高到低排序
+	pdfLinks := utils.RankLinks(ds.extractPDFLinksFromContent(bodyContent))
 	ds.logger.Infof("从邮件内容中提取到 %d 个PDF链接", len(pdfLinks))
 	
 	// 尝试下载每个PDF链接
 	for i, link := range pdfLinks {
-		ds.logger.Infof("尝试下载PDF链接 %d/%d: %s", i+1, len(pdfLinks), link)
+		ds.logger.Infof("尝试下载PDF链接 %d/%d（得分: %d）: %s", i+1, len(pdfLinks), utils.ScoreLink(link), link)
 		
 		pdfData, err := ds.downloadPDFFromURL(link, targetFileName)
 		if err == nil && len(pdfData) > 0 {
@@ -996,12 +1767,7 @@ func (ds *DownloadService) extractPDFLinksFromContent(content string) []string {
 // downloadPDFFromURL 从URL下载PDF
 func (ds *DownloadService) downloadPDFFromURL(url, targetFileName string) ([]byte, error) {
 	// 创建HTTP客户端
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
+	client := ds.newLinkClient(ds.linkTransport(true))
 	
 	// 创建请求
 	req, err := http.NewRequest("GET", url, nil)
@@ -1162,23 +1928,98 @@ func (ds *DownloadService) isPDFData(data []byte) bool {
 	return bytes.HasPrefix(data, []byte("%PDF-"))
 }
 
-// monitorProgress 监控下载进度
+// monitorProgress 监控下载进度，维护实时网络状态并分发给进度接收方
 func (ds *DownloadService) monitorProgress(worker *DownloadWorker) {
 	for update := range worker.Progress {
-		ds.updateTaskStatus(
-			update.TaskID,
-			update.Status,
-			update.Error,
-			update.DownloadedSize,
-			update.Progress,
-			update.Speed,
-		)
+		worker.recordActivity(update)
+		ds.reportProgress(update)
+	}
+}
+
+// recordActivity 根据进度更新计算当前速度
+func (w *DownloadWorker) recordActivity(update ProgressUpdate) {
+	if update.Status != models.StatusDownloading {
+		return
+	}
+	
+	w.activityMutex.Lock()
+	defer w.activityMutex.Unlock()
+	
+	now := time.Now()
+	if delta := update.DownloadedSize - w.downloaded; delta > 0 {
+		if elapsed := now.Sub(w.lastUpdate).Seconds(); elapsed > 0 {
+			w.bytesPerSecond = float64(delta) / elapsed
+		}
+		w.lastData = now
+	}
+	w.downloaded = update.DownloadedSize
+	if update.TotalSize > 0 {
+		w.totalSize = update.TotalSize
+	}
+	w.lastUpdate = now
+}
+
+// activity 返回工作者当前的网络状态
+func (w *DownloadWorker) activity() models.TaskNetworkActivity {
+	w.activityMutex.Lock()
+	defer w.activityMutex.Unlock()
+	
+	stalled := time.Since(w.lastData).Seconds()
+	speed := w.bytesPerSecond
+	// 长时间没有新数据时速度视为0，避免显示过期的速度
+	if stalled > 5 {
+		speed = 0
+	}
+	
+	return models.TaskNetworkActivity{
+		TaskID:         w.Task.ID,
+		FileName:       w.fileName,
+		Type:           string(w.Task.Type),
+		Host:           w.host,
+		BytesPerSecond: speed,
+		DownloadedSize: w.downloaded,
+		TotalSize:      w.totalSize,
+		StalledSeconds: stalled,
+	}
+}
+
+// taskHost 返回任务下载时连接的主机
+func taskHost(task *models.DownloadTask) string {
+	if task.Type == models.TypeLink {
+		if parsed, err := url.Parse(task.Source); err == nil {
+			return parsed.Host
+		}
+		return ""
+	}
+	return task.EmailAccount.IMAPServer
+}
+
+// GetNetworkActivity 获取所有下载中任务的实时网络状态及总吞吐量
+func (ds *DownloadService) GetNetworkActivity() models.NetworkActivity {
+	ds.workerMutex.RLock()
+	workers := make([]*DownloadWorker, 0, len(ds.workers))
+	for _, worker := range ds.workers {
+		workers = append(workers, worker)
+	}
+	ds.workerMutex.RUnlock()
+	
+	activity := models.NetworkActivity{Tasks: []models.TaskNetworkActivity{}}
+	for _, worker := range workers {
+		taskActivity := worker.activity()
+		activity.Tasks = append(activity.Tasks, taskActivity)
+		activity.TotalBytesPerSecond += taskActivity.BytesPerSecond
 	}
+	sort.Slice(activity.Tasks, func(i, j int) bool {
+		return activity.Tasks[i].TaskID < activity.Tasks[j].TaskID
+	})
+	activity.ActiveTasks = len(activity.Tasks)
+	
+	return activity
 }
 
 // updateTaskStatus 更新任务状态（使用统一事务处理）
 func (ds *DownloadService) updateTaskStatus(taskID uint, status models.DownloadStatus, errorMsg string, downloadedSize int64, progress float64, speed string) error {
-	return ds.db.WithRetry(func() error {
+	err := ds.db.WithRetry(func() error {
 		return ds.db.WithTransaction(func(tx *sql.Tx) error {
 			query := `
 				UPDATE download_tasks 
@@ -1194,6 +2035,30 @@ func (ds *DownloadService) updateTaskStatus(taskID uint, status models.DownloadS
 			return nil
 		})
 	}, 3) // 最多重试3次
+	if err != nil {
+		return err
+	}
+	ds.logStatusChange(taskID, status, errorMsg)
+	
+	// 任务结束时同步更新统计
+	if status == models.StatusCompleted || status == models.StatusFailed {
+		ds.recordTaskStatistics(taskID)
+	}
+	if status == models.StatusCompleted || status == models.StatusFailed || status == models.StatusCancelled {
+		ds.refreshMessageExtraction(taskID)
+	}
+	// 分卷合并在状态写入数据库之后检查，最后完成的分卷能看到其他分卷均已完成
+	if status == models.StatusCompleted {
+		ds.pdfMerge.add(taskID)
+	}
+	return nil
+}
+
+// refreshMessageExtraction 任务结束或重新排队后更新所属邮件的提取结果（全部成功、部分成功或失败）
+func (ds *DownloadService) refreshMessageExtraction(taskID uint) {
+	if err := ds.db.RefreshTaskMessageExtraction(taskID); err != nil {
+		ds.logger.Warnf("任务 %d 更新邮件提取结果失败: %v", taskID, err)
+	}
 }
 
 // PauseDownload 暂停下载
@@ -1206,8 +2071,10 @@ func (ds *DownloadService) PauseDownload(taskID uint) error {
 		return fmt.Errorf("任务不存在或未在下载中")
 	}
 	
+	worker.paused.Store(true)
 	worker.Cancel()
-	return ds.updateTaskStatus(taskID, models.StatusPaused, "", 0, 0, "")
+	update := ds.savedProgressUpdate(worker, models.StatusPaused, "")
+	return ds.updateTaskStatus(taskID, models.StatusPaused, "", update.DownloadedSize, update.Progress, "")
 }
 
 // CancelDownload 取消下载
@@ -1217,15 +2084,17 @@ func (ds *DownloadService) CancelDownload(taskID uint) error {
 	ds.workerMutex.RUnlock()
 	
 	if exists {
+		worker.cancelled.Store(true)
 		worker.Cancel()
 	}
 	
-	// 删除未完成的文件
+	// 删除未完成的文件，包括暂停时保留的临时文件
 	task, err := ds.getTaskByIDOptimized(taskID)
-	if err == nil && task.LocalPath != "" {
+	if err == nil && task.LocalPath != "" && task.DuplicateOf == 0 {
 		if _, err := os.Stat(task.LocalPath); err == nil {
 			os.Remove(task.LocalPath)
 		}
+		os.Remove(task.LocalPath + tempFileSuffix)
 	}
 	
 	return ds.updateTaskStatus(taskID, models.StatusCancelled, "", 0, 0, "")
@@ -1238,48 +2107,7 @@ func (ds *DownloadService) GetDownloadStatus(taskID uint) (*models.DownloadTask,
 
 // GetAllTasks 获取所有任务
 func (ds *DownloadService) GetAllTasks() ([]models.DownloadTask, error) {
-	query := `
-		SELECT 
-			dt.id, dt.email_id, dt.subject, dt.sender, dt.file_name, 
-			dt.file_size, dt.downloaded_size, dt.status, dt.type, 
-			dt.source, dt.local_path, dt.error, dt.progress, dt.speed,
-			dt.created_at, dt.updated_at,
-			ea.id, ea.name, ea.email, ea.password, ea.imap_server, 
-			ea.imap_port, ea.use_ssl, ea.is_active, ea.created_at, ea.updated_at
-		FROM download_tasks dt
-		LEFT JOIN email_accounts ea ON dt.email_id = ea.id
-		ORDER BY dt.created_at DESC
-	`
-	
-	rows, err := ds.db.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var tasks []models.DownloadTask
-	for rows.Next() {
-		task := models.DownloadTask{}
-		account := models.EmailAccount{}
-		
-		err := rows.Scan(
-			&task.ID, &task.EmailID, &task.Subject, &task.Sender, &task.FileName,
-			&task.FileSize, &task.DownloadedSize, &task.Status, &task.Type,
-			&task.Source, &task.LocalPath, &task.Error, &task.Progress, &task.Speed,
-			&task.CreatedAt, &task.UpdatedAt,
-			&account.ID, &account.Name, &account.Email, &account.Password, &account.IMAPServer,
-			&account.IMAPPort, &account.UseSSL, &account.IsActive, &account.CreatedAt, &account.UpdatedAt,
-		)
-		
-		if err != nil {
-			return nil, err
-		}
-		
-		task.EmailAccount = account
-		tasks = append(tasks, task)
-	}
-	
-	return tasks, nil
+	return ds.db.GetAllDownloadTasks()
 }
 
 // SetMaxConcurrent 设置最大并发数
@@ -1289,6 +2117,43 @@ func (ds *DownloadService) SetMaxConcurrent(max int) {
 	ds.maxConcurrent = max
 }
 
+// SetDownloadWindow 设置允许下载的时间窗口（HH:MM），任一为空时不限制
+func (ds *DownloadService) SetDownloadWindow(start, end string) error {
+	ds.windowMutex.Lock()
+	defer ds.windowMutex.Unlock()
+	
+	if start == "" || end == "" {
+		ds.windowEnabled = false
+		return nil
+	}
+	
+	startMinute, err := utils.ParseTimeOfDay(start)
+	if err != nil {
+		return err
+	}
+	endMinute, err := utils.ParseTimeOfDay(end)
+	if err != nil {
+		return err
+	}
+	
+	ds.windowEnabled = true
+	ds.windowStart = startMinute
+	ds.windowEnd = endMinute
+	ds.logger.Infof("下载时间窗口已设置为 %s - %s", start, end)
+	return nil
+}
+
+// IsPausedBySchedule 当前是否因不在下载时间窗口内而暂停启动新下载
+func (ds *DownloadService) IsPausedBySchedule() bool {
+	ds.windowMutex.RLock()
+	defer ds.windowMutex.RUnlock()
+	
+	if !ds.windowEnabled {
+		return false
+	}
+	return !utils.InTimeWindow(time.Now(), ds.windowStart, ds.windowEnd)
+}
+
 // GetActiveDownloads 获取活跃下载数
 func (ds *DownloadService) GetActiveDownloads() int {
 	ds.activeWorkerMutex.RLock()
@@ -1330,6 +2195,23 @@ func (ds *DownloadService) Stop() {
 			ds.logger.Warn("等待goroutine退出超时，强制退出")
 		}
 		
+		// 超时未退出的工作者来不及通过进度通道保存进度，直接写入数据库
+		ds.workerMutex.Lock()
+		for _, worker := range ds.workers {
+			update := ds.interruptedUpdate(worker)
+			if err := ds.updateTaskStatus(update.TaskID, update.Status, update.Error, update.DownloadedSize, update.Progress, ""); err != nil {
+				ds.logger.Errorf("保存任务 %d 的下载进度失败: %v", worker.ID, err)
+			}
+		}
+		ds.workerMutex.Unlock()
+		
+		ds.closeLinkTransports()
+		
+		// 数据库关闭前写入当天的下载统计
+		if err := ds.db.RecordDailyStatistics(time.Now(), ds.statisticsByReceivedDate()); err != nil {
+			ds.logger.Errorf("保存下载统计失败: %v", err)
+		}
+		
 		// 清理资源
 		ds.workerMutex.Lock()
 		for taskID, worker := range ds.workers {
@@ -1344,6 +2226,35 @@ func (ds *DownloadService) Stop() {
 	})
 }
 
+// isStopping 服务是否正在关闭
+func (ds *DownloadService) isStopping() bool {
+	ds.shutdownMutex.RLock()
+	defer ds.shutdownMutex.RUnlock()
+	return ds.isShuttingDown
+}
+
+// interruptedUpdate 关闭服务时中断的任务恢复为等待状态，并记录已写入的字节数
+func (ds *DownloadService) interruptedUpdate(worker *DownloadWorker) ProgressUpdate {
+	return ds.savedProgressUpdate(worker, models.StatusPending, "应用关闭时中断，将在下次启动时恢复")
+}
+
+// savedProgressUpdate 下载中止时的状态更新，记录已写入临时文件的字节数供续传使用
+func (ds *DownloadService) savedProgressUpdate(worker *DownloadWorker, status models.DownloadStatus, message string) ProgressUpdate {
+	written := atomic.LoadInt64(&worker.written)
+	var progress float64
+	if worker.Task.FileSize > 0 {
+		progress = math.Min(float64(written)/float64(worker.Task.FileSize)*100, 100)
+	}
+	return ProgressUpdate{
+		TaskID:         worker.ID,
+		DownloadedSize: written,
+		TotalSize:      worker.Task.FileSize,
+		Progress:       progress,
+		Status:         status,
+		Error:          message,
+	}
+}
+
 // findPDFPartInStructure 在邮件结构中查找PDF附件部分
 func (ds *DownloadService) findPDFPartInStructure(bs *imap.BodyStructure, targetFileName string) *PDFPartInfo {
 	// 首先尝试精确匹配
@@ -1351,6 +2262,11 @@ func (ds *DownloadService) findPDFPartInStructure(bs *imap.BodyStructure, target
 		return pdfPart
 	}
 	
+	// 非PDF附件必须按文件名匹配，不能用其他附件代替
+	if !isPDFTarget(targetFileName) {
+		return nil
+	}
+	
 	// 如果精确匹配失败，尝试找任何PDF附件
 	ds.logger.Infof("精确匹配失败，尝试查找任何PDF附件")
 	return ds.findPDFPartRecursive(bs, "", "")
@@ -1362,9 +2278,9 @@ func (ds *DownloadService) findPDFPartRecursive(bs *imap.BodyStructure, targetFi
 		return nil
 	}
 	
-	// 检查当前部分是否为PDF
-	if ds.isPDFPart(bs) {
-		fileName := ds.extractFileName(bs)
+	// 检查当前部分的类型是否与目标文件一致
+	fileName := ds.extractFileName(bs)
+	if ds.partMatchesTargetType(bs, fileName, targetFileName) {
 		ds.logger.Infof("找到PDF部分 - 节点: %s, 文件名: '%s', 目标: '%s', MIME: %s/%s", 
 			section, fileName, targetFileName, bs.MIMEType, bs.MIMESubType)
 		
@@ -1467,23 +2383,36 @@ func (ds *DownloadService) fetchPDFPartContent(conn *IMAPConnection, uid uint32,
 	seqset := new(imap.SeqSet)
 	seqset.AddNum(uid)
 	
-	// 构建部分标识符
+	// 构建部分标识符，使用PEEK避免下载附件时把邮件标记为已读
 	var fetchItem imap.FetchItem
-	if pdfPart.Section == "" {
-		fetchItem = "BODY[]"
+	section := pdfPart.Section
+	if pdfPart.Section == "" && conn.Quirks.AvoidFullBody {
+		fetchItem = "BODY.PEEK[1]" // 单部分邮件的正文即第1部分
+		section = "1"
+	} else if pdfPart.Section == "" {
+		fetchItem = "BODY.PEEK[]"
 	} else {
-		fetchItem = imap.FetchItem(fmt.Sprintf("BODY[%s]", pdfPart.Section))
+		fetchItem = imap.FetchItem(fmt.Sprintf("BODY.PEEK[%s]", pdfPart.Section))
+	}
+	
+	// 服务器支持BINARY时由服务器解码，省去本地Base64解码；失败时改用 BODY 获取后本地解码
+	if conn.Binary && section != "" {
+		content, err := ds.fetchBinaryPart(conn, uid, section)
+		if err == nil {
+			return content, nil
+		}
+		ds.logger.Debugf("邮件 %d 第 %s 部分使用BINARY获取失败，改用BODY获取: %v", uid, section, err)
 	}
 	
 	messages := make(chan *imap.Message, 1)
 	
-	conn.Mutex.Lock()
 	// 关键修复：使用UidFetch确保UID一致性
-	err := conn.Client.UidFetch(seqset, []imap.FetchItem{
-		imap.FetchUid, 
-		fetchItem,
-	}, messages)
-	conn.Mutex.Unlock()
+	err := conn.withLock(func() error {
+		return conn.Client.UidFetch(seqset, []imap.FetchItem{
+			imap.FetchUid, 
+			fetchItem,
+		}, messages)
+	})
 	
 	if err != nil {
 		return nil, fmt.Errorf("获取PDF部分内容失败: %v", err)
@@ -1499,8 +2428,10 @@ func (ds *DownloadService) fetchPDFPartContent(conn *IMAPConnection, uid uint32,
 		return nil, fmt.Errorf("获取PDF内容超时")
 	}
 	
-	// 验证UID匹配
-	ds.validateUID(uid, msg.Uid, "PDF部分内容获取")
+	// 验证UID匹配，不一致时返回的内容属于其他邮件
+	if !ds.validateUID(uid, msg.Uid, "PDF部分内容获取") {
+		return nil, fmt.Errorf("PDF部分内容UID不一致 (期望: %d, 实际: %d)", uid, msg.Uid)
+	}
 	
 	// 从Body中提取内容
 	var rawContent []byte
@@ -1560,6 +2491,11 @@ func (ds *DownloadService) decodeContent(content []byte, encoding string) ([]byt
 
 // searchEmailsSafely 安全地搜索邮件（使用UID搜索修复版本）
 func (ds *DownloadService) searchEmailsSafely(conn *IMAPConnection, subject, sender string) ([]uint32, error) {
+	window := scanWindowSince(nil)
+	if config, err := ds.db.GetConfig(); err == nil {
+		window = scanWindowSince(&config)
+	}
+	
 	conn.Mutex.Lock()
 	defer conn.Mutex.Unlock()
 	
@@ -1572,7 +2508,7 @@ func (ds *DownloadService) searchEmailsSafely(conn *IMAPConnection, subject, sen
 	// 策略1: 如果没有搜索条件，搜索最近的邮件
 	if subject == "" && sender == "" {
 		criteria := imap.NewSearchCriteria()
-		since := time.Now().AddDate(0, 0, -7) // 最近7天
+		since := window // 配置的最近邮件窗口
 		criteria.Since = since
 		// 关键修复：使用UidSearch而不是Search
 		uids, err := conn.Client.UidSearch(criteria)
@@ -1594,23 +2530,24 @@ func (ds *DownloadService) searchEmailsSafely(conn *IMAPConnection, subject, sen
 		ds.logger.Debugf("添加发件人搜索条件: %s", sender)
 	}
 	
-	// 对于主题，如果包含非ASCII字符，则不使用Header搜索
+	// 对于主题，如果包含非ASCII字符或过长，则不使用Header搜索
 	// 而是搜索最近的邮件，然后在客户端过滤
-	if subject != "" && ds.isASCII(subject) {
+	clientFilter := subject != "" && (!ds.isASCII(subject) || len(subject) > maxServerSubjectSearchLen)
+	if subject != "" && !clientFilter {
 		criteria.Header.Set("Subject", subject)
 		hasValidCriteria = true
 		ds.logger.Debugf("添加主题搜索条件: %s", subject)
 	} else if subject != "" {
 		// 包含非ASCII字符的主题，搜索最近的邮件
-		since := time.Now().AddDate(0, 0, -7) // 最近7天
+		since := window // 配置的最近邮件窗口
 		criteria.Since = since
 		hasValidCriteria = true
-		ds.logger.Debugf("主题包含非ASCII字符，使用时间范围搜索")
+		ds.logger.Debugf("主题包含非ASCII字符或过长，使用时间范围搜索")
 	}
 	
 	// 如果没有任何有效的搜索条件，搜索最近的邮件
 	if !hasValidCriteria {
-		since := time.Now().AddDate(0, 0, -7)
+		since := window
 		criteria.Since = since
 		ds.logger.Debugf("使用默认时间范围搜索")
 	}
@@ -1621,7 +2558,7 @@ func (ds *DownloadService) searchEmailsSafely(conn *IMAPConnection, subject, sen
 		// 如果搜索失败，尝试最基本的搜索
 		ds.logger.Warnf("UID搜索失败，尝试基本搜索: %v", err)
 		criteria = imap.NewSearchCriteria()
-		since := time.Now().AddDate(0, 0, -7)
+		since := window
 		criteria.Since = since
 		uids, err = conn.Client.UidSearch(criteria)
 		if err != nil {
@@ -1631,8 +2568,8 @@ func (ds *DownloadService) searchEmailsSafely(conn *IMAPConnection, subject, sen
 	
 	ds.logger.Infof("初始UID搜索完成 - 找到 %d 封邮件", len(uids))
 	
-	// 如果主题包含非ASCII字符，需要在客户端进行过滤
-	if subject != "" && !ds.isASCII(subject) {
+	// 如果主题包含非ASCII字符或过长，需要在客户端进行过滤
+	if clientFilter {
 		ds.logger.Infof("开始客户端主题过滤 - 目标主题: '%s'", subject)
 		filteredUIDs, err := ds.filterEmailsBySubjectUID(conn, uids, subject)
 		if err != nil {
@@ -1655,18 +2592,67 @@ func (ds *DownloadService) isASCII(s string) bool {
 	return true
 }
 
+// maxServerSubjectSearchLen 超过此长度的主题不交给服务器搜索（折行的长主题在服务器端常常匹配失败）
+const maxServerSubjectSearchLen = 200
+
+// maxSubjectFilterCandidates 客户端过滤主题时最多检查的邮件数
+const maxSubjectFilterCandidates = 1000
+
 // filterEmailsBySubjectUID 在客户端过滤邮件主题（使用UID版本）
+// 候选邮件按配置的批大小分批获取信封，避免一次FETCH返回过大的响应
 func (ds *DownloadService) filterEmailsBySubjectUID(conn *IMAPConnection, uids []uint32, targetSubject string) ([]uint32, error) {
 	if len(uids) == 0 {
 		return uids, nil
 	}
 	
-	// 限制检查的邮件数量
-	maxCheck := 50
-	if len(uids) > maxCheck {
-		uids = uids[:maxCheck]
+	// 限制检查的邮件数量，优先检查最新的邮件
+	if len(uids) > maxSubjectFilterCandidates {
+		uids = uids[len(uids)-maxSubjectFilterCandidates:]
+	}
+	
+	batchSize := defaultFetchBatchSize
+	if config, err := ds.db.GetConfig(); err == nil {
+		batchSize = fetchBatchSize(&config)
+	}
+	
+	// 部分服务器返回的主题仍是MIME编码，比较前统一解码并规范化
+	normalizedTarget := utils.NormalizeSubject(targetSubject)
+	
+	var matchedUIDs []uint32
+	for start := 0; start < len(uids); start += batchSize {
+		select {
+		case <-ds.ctx.Done():
+			return nil, fmt.Errorf("下载服务已停止")
+		default:
+		}
+		
+		end := start + batchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		
+		messages, err := ds.fetchEnvelopes(conn, uids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		
+		for _, msg := range messages {
+			if msg.Envelope != nil && msg.Envelope.Subject != "" {
+				// 比较主题（忽略大小写）
+				if strings.Contains(utils.NormalizeSubject(msg.Envelope.Subject), normalizedTarget) {
+					matchedUIDs = append(matchedUIDs, msg.Uid)
+					ds.logger.Debugf("主题匹配成功 - UID: %d, 主题: %s", msg.Uid, msg.Envelope.Subject)
+				}
+			}
+		}
 	}
 	
+	ds.logger.Infof("主题过滤完成 - 输入: %d 封邮件, 匹配: %d 封邮件", len(uids), len(matchedUIDs))
+	return matchedUIDs, nil
+}
+
+// fetchEnvelopes 按UID获取一批邮件的信封
+func (ds *DownloadService) fetchEnvelopes(conn *IMAPConnection, uids []uint32) ([]*imap.Message, error) {
 	seqset := new(imap.SeqSet)
 	seqset.AddNum(uids...)
 	
@@ -1681,23 +2667,15 @@ func (ds *DownloadService) filterEmailsBySubjectUID(conn *IMAPConnection, uids [
 		}, messages)
 	}()
 	
-	var matchedUIDs []uint32
+	var msgs []*imap.Message
 	for msg := range messages {
-		if msg.Envelope != nil && msg.Envelope.Subject != "" {
-			// 比较主题（忽略大小写）
-			if strings.Contains(strings.ToLower(msg.Envelope.Subject), strings.ToLower(targetSubject)) {
-				matchedUIDs = append(matchedUIDs, msg.Uid)
-				ds.logger.Debugf("主题匹配成功 - UID: %d, 主题: %s", msg.Uid, msg.Envelope.Subject)
-			}
-		}
+		msgs = append(msgs, msg)
 	}
 	
 	if err := <-done; err != nil {
 		return nil, fmt.Errorf("获取邮件信息失败: %v", err)
 	}
-	
-	ds.logger.Infof("主题过滤完成 - 输入: %d 封邮件, 匹配: %d 封邮件", len(uids), len(matchedUIDs))
-	return matchedUIDs, nil
+	return msgs, nil
 }
 
 // 保持原有方法的兼容性
@@ -1742,16 +2720,28 @@ func (ds *DownloadService) isFileNameMatch(actualName, targetName string) bool {
 }
 
 // downloadWithProgress 带进度的下载
-func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.Reader, dst io.Writer) error {
+// offset 为续传时已写入的字节数，进度从该位置继续计算
+func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.Reader, dst io.Writer, offset int64) error {
 	task := worker.Task
 	
 	// 动态调整缓冲区大小
 	bufferSize := ds.calculateOptimalBufferSize(task.FileSize)
 	buffer := make([]byte, bufferSize)
 	
-	var downloaded int64
+	downloaded := offset
 	startTime := time.Now()
 	lastProgressUpdate := time.Now()
+	lastDownloaded := offset
+	
+	// 服务器声明的大小已超过上限时不再下载
+	maxSize := ds.maxFileSize()
+	if maxSize > 0 && task.FileSize > maxSize {
+		return fileTooLargeError(task.FileSize, maxSize)
+	}
+	
+	// 读取阻塞期间也定时发送进度更新，速度曲线可以显示停滞
+	stopStalls := ds.startStallReporter(worker)
+	defer stopStalls()
 	
 	for {
 		select {
@@ -1760,16 +2750,26 @@ func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.R
 		default:
 			n, err := src.Read(buffer)
 			if n > 0 {
+				if maxSize > 0 && downloaded+int64(n) > maxSize {
+					return fileTooLargeError(downloaded+int64(n), maxSize)
+				}
 				if _, writeErr := dst.Write(buffer[:n]); writeErr != nil {
 					return fmt.Errorf("写入文件失败: %v", writeErr)
 				}
 				
 				downloaded += int64(n)
+				atomic.StoreInt64(&worker.written, downloaded)
 				
 				// 限制进度更新频率，避免过多的数据库写入
 				now := time.Now()
-				if now.Sub(lastProgressUpdate) >= 500*time.Millisecond || err == io.EOF {
+				if interval := now.Sub(lastProgressUpdate); interval >= progressUpdateInterval || err == io.EOF {
+					// 本次间隔内的速度，作为速度曲线的采样点
+					var sampleRate float64
+					if interval > 0 {
+						sampleRate = float64(downloaded-lastDownloaded) / interval.Seconds()
+					}
 					lastProgressUpdate = now
+					lastDownloaded = downloaded
 					
 					// 计算进度和速度
 					var progress float64
@@ -1783,7 +2783,7 @@ func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.R
 					elapsed := now.Sub(startTime).Seconds()
 					speed := ""
 					if elapsed > 0 {
-						bytesPerSecond := float64(downloaded) / elapsed
+						bytesPerSecond := float64(downloaded-offset) / elapsed
 						speed = utils.FormatBytes(int64(bytesPerSecond)) + "/s"
 					}
 					
@@ -1792,8 +2792,10 @@ func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.R
 					case worker.Progress <- ProgressUpdate{
 						TaskID:         task.ID,
 						DownloadedSize: downloaded,
+						TotalSize:      task.FileSize,
 						Progress:       progress,
 						Speed:          speed,
+						BytesPerSecond: sampleRate,
 						Status:         models.StatusDownloading,
 					}:
 					default:
@@ -1803,7 +2805,8 @@ func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.R
 			}
 			
 			if err == io.EOF {
-				// 下载完成
+				// 下载完成，先停止停滞更新，避免其排在完成状态之后
+				stopStalls()
 				select {
 				case worker.Progress <- ProgressUpdate{
 					TaskID:   task.ID,
@@ -1818,7 +2821,70 @@ func (ds *DownloadService) downloadWithProgress(worker *DownloadWorker, src io.R
 			}
 			
 			if err != nil {
-				return fmt.Errorf("读取数据失败: %v", err)
+				return fmt.Errorf("%w: %v", errStreamInterrupted, err)
+			}
+		}
+	}
+}
+
+// progressUpdateInterval 下载数据时发送进度更新的最小间隔，也是速度采样的间隔
+const progressUpdateInterval = 500 * time.Millisecond
+
+// startStallReporter 启动停滞检查协程，返回的函数停止协程并等待其退出，可重复调用
+func (ds *DownloadService) startStallReporter(worker *DownloadWorker) func() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ds.reportStalls(worker, done)
+	}()
+	
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// reportStalls 每个进度间隔检查一次已写入的字节数，期间没有收到数据时发送速度为0的进度更新
+// 下载数据时的进度更新由 downloadWithProgress 发送，这里只补上读取阻塞的间隔
+func (ds *DownloadService) reportStalls(worker *DownloadWorker, done <-chan struct{}) {
+	ticker := time.NewTicker(progressUpdateInterval)
+	defer ticker.Stop()
+	
+	task := worker.Task
+	last := atomic.LoadInt64(&worker.written)
+	for {
+		select {
+		case <-done:
+			return
+		case <-worker.Context.Done():
+			return
+		case <-ticker.C:
+			written := atomic.LoadInt64(&worker.written)
+			if written != last {
+				last = written
+				continue
+			}
+			
+			var progress float64
+			if task.FileSize > 0 {
+				progress = float64(written) / float64(task.FileSize) * 100
+			}
+			select {
+			case worker.Progress <- ProgressUpdate{
+				TaskID:         task.ID,
+				DownloadedSize: written,
+				TotalSize:      task.FileSize,
+				Progress:       progress,
+				Speed:          utils.FormatBytes(0) + "/s",
+				Status:         models.StatusDownloading,
+			}:
+			default:
+				// progress channel已满时跳过这次更新
 			}
 		}
 	}
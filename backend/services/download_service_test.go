@@ -0,0 +1,433 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"emaild/backend/models"
+)
+
+func testEnvelope(subject, sender string, date time.Time) *imap.Envelope {
+	return &imap.Envelope{
+		Subject: subject,
+		Date:    date,
+		From:    []*imap.Address{{MailboxName: "billing", HostName: sender}},
+	}
+}
+
+// 搜索后邮箱发生变化：原UID现在是另一封邮件，目标邮件移到了新的UID
+func TestClosestMatchingUIDAfterUIDShift(t *testing.T) {
+	created := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	task := &models.DownloadTask{
+		Subject:   "三月账单",
+		Sender:    "billing@example.com",
+		CreatedAt: models.TimeToString(created),
+	}
+	envelopes := map[uint32]*imap.Envelope{
+		100: testEnvelope("Newsletter", "example.com", created),
+		205: testEnvelope("=?UTF-8?B?5LiJ5pyI6LSm5Y2V?=", "example.com", created.Add(-time.Hour)),
+		230: testEnvelope("三月账单", "example.com", created.AddDate(0, 1, 0)),
+		240: testEnvelope("三月账单", "other.example", created),
+	}
+
+	if got := closestMatchingUID(envelopes, task); got != 205 {
+		t.Fatalf("重新定位的UID = %d，期望 205", got)
+	}
+}
+
+func TestClosestMatchingUIDNoMatch(t *testing.T) {
+	task := &models.DownloadTask{Subject: "三月账单", Sender: "billing@example.com"}
+	envelopes := map[uint32]*imap.Envelope{
+		100: testEnvelope("Newsletter", "example.com", time.Now()),
+	}
+
+	if got := closestMatchingUID(envelopes, task); got != 0 {
+		t.Fatalf("没有匹配的邮件时应返回0，实际为 %d", got)
+	}
+}
+
+func TestClosestMatchingUIDWithoutDates(t *testing.T) {
+	task := &models.DownloadTask{Subject: "三月账单", Sender: "billing@example.com"}
+	envelopes := map[uint32]*imap.Envelope{
+		205: testEnvelope("三月账单", "example.com", time.Time{}),
+		230: testEnvelope("三月账单", "example.com", time.Time{}),
+	}
+
+	if got := closestMatchingUID(envelopes, task); got != 230 {
+		t.Fatalf("无法比较日期时应选择最新的邮件，实际为 %d", got)
+	}
+}
+
+// 服务器找到邮件后极慢地发送内容，附件下载应在期限到达时失败并断开连接，释放并发名额
+func TestFindAttachmentWithDeadlineSlowFetch(t *testing.T) {
+	conn := newFakeIMAPConnection(t, "", func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool {
+		switch cmd.Name {
+		case "UID SEARCH":
+			reply.line("* SEARCH 7")
+			reply.ok(cmd)
+		case "UID FETCH":
+			// 声明1MB的内容，每次只发送一个字节
+			reply.line("* 1 FETCH (UID 7 BODY[] {1048576}")
+			for {
+				time.Sleep(20 * time.Millisecond)
+				if reply.raw([]byte("x")) != nil {
+					return true
+				}
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	ds := &DownloadService{db: newTestDatabase(t), logger: newTestLogger()}
+	task := &models.DownloadTask{Subject: "Invoice", Sender: "billing@example.com", FileName: "invoice.pdf"}
+
+	deadline := 300 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ds.findAttachmentWithDeadline(ctx, conn, task, deadline)
+	if err == nil || !strings.Contains(err.Error(), "超过期限") {
+		t.Fatalf("期望超过期限的错误，实际为 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("期限为 %v，实际用时 %v", deadline, elapsed)
+	}
+
+	// 连接被强制断开，阻塞的FETCH随之返回
+	select {
+	case <-conn.Client.LoggedOut():
+	case <-time.After(2 * time.Second):
+		t.Fatal("期限到达后连接没有被断开")
+	}
+}
+
+// 500封候选邮件按配置的批大小分批获取信封，合并各批的匹配结果
+func TestFilterEmailsBySubjectUIDBatches(t *testing.T) {
+	const candidates = 500
+	const batchSize = 80
+
+	var mu sync.Mutex
+	var batches []int
+	conn := newFakeIMAPConnection(t, "", func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool {
+		if cmd.Name != "UID FETCH" {
+			return false
+		}
+		seqset, err := imap.ParseSeqSet(strings.Fields(cmd.Args)[0])
+		if err != nil {
+			t.Errorf("无效的UID集合 %q: %v", cmd.Args, err)
+			reply.line("%s BAD invalid sequence set", cmd.Tag)
+			return true
+		}
+		count := 0
+		for uid := uint32(1); uid <= candidates; uid++ {
+			if !seqset.Contains(uid) {
+				continue
+			}
+			count++
+			subject := "Newsletter"
+			if uid%7 == 0 {
+				subject = fmt.Sprintf("Invoice %d", uid)
+			}
+			reply.line(`* %d FETCH (UID %d ENVELOPE (NIL "%s" NIL NIL NIL NIL NIL NIL NIL NIL))`, uid, uid, subject)
+		}
+		mu.Lock()
+		batches = append(batches, count)
+		mu.Unlock()
+		reply.ok(cmd)
+		return true
+	})
+
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.FetchBatchSize = batchSize
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatal(err)
+	}
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+
+	uids := make([]uint32, candidates)
+	for i := range uids {
+		uids[i] = uint32(i + 1)
+	}
+	matched, err := ds.filterEmailsBySubjectUID(conn, uids, "invoice")
+	if err != nil {
+		t.Fatalf("过滤主题失败: %v", err)
+	}
+
+	if len(matched) != candidates/7 {
+		t.Fatalf("匹配了 %d 封邮件，期望 %d", len(matched), candidates/7)
+	}
+	for _, uid := range matched {
+		if uid%7 != 0 {
+			t.Fatalf("UID %d 的主题不匹配却被选中", uid)
+		}
+	}
+
+	total := 0
+	for _, size := range batches {
+		if size > batchSize {
+			t.Fatalf("一次获取了 %d 封邮件，超过批大小 %d", size, batchSize)
+		}
+		total += size
+	}
+	if want := (candidates + batchSize - 1) / batchSize; len(batches) != want {
+		t.Fatalf("分 %d 批获取，期望 %d 批", len(batches), want)
+	}
+	if total != candidates {
+		t.Fatalf("共获取 %d 封邮件，期望 %d", total, candidates)
+	}
+}
+
+// TestStopPersistsDownloadOffset 下载中途关闭服务后，任务回到等待状态，记录的进度与临时文件一致，可用于续传
+func TestStopPersistsDownloadOffset(t *testing.T) {
+	const total = 1 << 20
+	const sent = 64 << 10
+
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Length", fmt.Sprint(total))
+		w.Write(make([]byte, sent))
+		w.(http.Flusher).Flush()
+		// 发送部分内容后停住，模拟关闭时仍在下载
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+
+	db := newTestDatabase(t)
+	account := &models.EmailAccount{Name: "test", Email: "user@example.com", IMAPServer: "imap.example.com", IMAPPort: 993, UseSSL: true}
+	if err := db.CreateEmailAccount(account); err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+	localPath := filepath.Join(t.TempDir(), "report.pdf")
+	task := &models.DownloadTask{
+		EmailID:   account.ID,
+		FileName:  "report.pdf",
+		Status:    models.StatusPending,
+		Type:      models.TypeLink,
+		Source:    server.URL + "/report.pdf",
+		LocalPath: localPath,
+	}
+	if err := db.CreateDownloadTask(task); err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+
+	ds := NewDownloadService(db)
+	ds.logger.SetOutput(io.Discard)
+	if err := ds.StartDownload(task.ID); err != nil {
+		t.Fatalf("启动下载失败: %v", err)
+	}
+
+	tempPath := localPath + tempFileSuffix
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if info, err := os.Stat(tempPath); err == nil && info.Size() == sent {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待写入临时文件超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ds.Stop()
+
+	saved, err := db.GetDownloadTaskByID(task.ID)
+	if err != nil {
+		t.Fatalf("读取任务失败: %v", err)
+	}
+	if saved.Status != models.StatusPending {
+		t.Errorf("关闭后任务状态为 %s，期望 %s", saved.Status, models.StatusPending)
+	}
+	info, err := os.Stat(tempPath)
+	if err != nil {
+		t.Fatalf("关闭后临时文件不存在: %v", err)
+	}
+	if saved.DownloadedSize != info.Size() || saved.DownloadedSize != sent {
+		t.Errorf("记录的已下载大小为 %d，临时文件大小为 %d，期望 %d", saved.DownloadedSize, info.Size(), sent)
+	}
+	if offset := ds.resumeOffset(saved, tempPath); offset != sent {
+		t.Errorf("续传位置为 %d，期望 %d", offset, sent)
+	}
+}
+
+// TestLinkClientKeepsCookiesAcrossRedirects 中间跳转设置的Cookie在之后的跳转中带上，最终地址凭Cookie允许下载
+func TestLinkClientKeepsCookiesAcrossRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/token", http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "ticket", Value: "abc", Path: "/"})
+		http.Redirect(w, r, "/cdn", http.StatusFound)
+	})
+	mux.HandleFunc("/cdn", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/file.pdf", http.StatusFound)
+	})
+	mux.HandleFunc("/file.pdf", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("ticket"); err != nil || cookie.Value != "abc" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("%PDF-1.4"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ds := &DownloadService{db: newTestDatabase(t), logger: newTestLogger(), ctx: context.Background()}
+	resp, err := ds.newLinkClient(nil).Get(server.URL + "/start")
+	if err != nil {
+		t.Fatalf("请求下载链接失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != "%PDF-1.4" {
+		t.Errorf("最终响应为 %d %q，期望带Cookie下载成功", resp.StatusCode, body)
+	}
+	if got := resp.Request.URL.Path; got != "/file.pdf" {
+		t.Errorf("最终地址为 %s，期望 /file.pdf", got)
+	}
+}
+
+// TestFetchUsesPeek 分析邮件和下载附件时使用 BODY.PEEK / BINARY.PEEK 获取内容，不改变邮件的已读状态
+func TestFetchUsesPeek(t *testing.T) {
+	ds := &DownloadService{logger: newTestLogger()}
+
+	tests := []struct {
+		name     string
+		response string
+		fetch    func(conn *IMAPConnection) error
+	}{
+		{
+			name:     "分析邮件",
+			response: "* 1 FETCH (UID 7 FLAGS () BODY[TEXT] {5}\r\nhello)",
+			fetch: func(conn *IMAPConnection) error {
+				msgs, err := conn.fetchMessagesByUID([]uint32{7})
+				if err == nil && len(msgs) != 1 {
+					err = fmt.Errorf("获取到 %d 封邮件", len(msgs))
+				}
+				return err
+			},
+		},
+		{
+			name:     "BODY获取附件",
+			response: "* 1 FETCH (UID 7 BODY[2] {5}\r\nhello)",
+			fetch: func(conn *IMAPConnection) error {
+				_, err := ds.fetchPDFPartContent(conn, 7, &PDFPartInfo{Section: "2"})
+				return err
+			},
+		},
+		{
+			name:     "BINARY获取附件",
+			response: "* 1 FETCH (UID 7 BINARY[2] {5}\r\nhello)",
+			fetch: func(conn *IMAPConnection) error {
+				_, err := ds.fetchBinaryPart(conn, 7, "2")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args string
+			conn := newFakeIMAPConnection(t, "BINARY", func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool {
+				if cmd.Name != "UID FETCH" {
+					return false
+				}
+				args = cmd.Args
+				reply.line("%s", tt.response)
+				reply.ok(cmd)
+				return true
+			})
+
+			if err := tt.fetch(conn); err != nil {
+				t.Fatalf("获取失败: %v", err)
+			}
+			if !strings.Contains(args, ".PEEK[") || strings.Contains(args, "BODY[") || strings.Contains(args, "BINARY[") {
+				t.Errorf("FETCH 参数为 %q，期望只使用PEEK获取内容", args)
+			}
+		})
+	}
+}
+
+// TestSkipDuplicateSource 同一邮件的附件和链接内容相同时跳过非偏好的来源，按内容去重的任务仍在使用的文件不删除
+func TestSkipDuplicateSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		shared   bool // 另一封邮件的任务按内容去重后指向链接下载的文件
+		wantFile bool
+	}{
+		{name: "删除重复来源的文件", wantFile: false},
+		{name: "保留仍被使用的文件", shared: true, wantFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+			dir := t.TempDir()
+
+			newTask := func(taskType models.DownloadType, name string) *models.DownloadTask {
+				localPath := filepath.Join(dir, name)
+				if err := os.WriteFile(localPath, []byte(testIndexedPDF), 0644); err != nil {
+					t.Fatalf("写入测试文件失败: %v", err)
+				}
+				task := &models.DownloadTask{Subject: "账单", Sender: "billing@example.com", FileName: name, Status: models.StatusCompleted, Type: taskType, LocalPath: localPath}
+				createTestTask(t, db, task)
+				return task
+			}
+			attachment := newTask(models.TypeAttachment, "attachment.pdf")
+			link := newTask(models.TypeLink, "link.pdf")
+			if tt.shared {
+				other := &models.DownloadTask{Subject: "另一封", FileName: "other.pdf", Status: models.StatusCompleted, Type: models.TypeAttachment}
+				createTestTask(t, db, other)
+				if err := db.MarkTaskDuplicate(other.ID, link.ID, "hash", link.LocalPath); err != nil {
+					t.Fatalf("记录重复任务失败: %v", err)
+				}
+			}
+
+			message, skipped := ds.skipDuplicateSource(&DownloadWorker{Task: link})
+			if !skipped || !strings.Contains(message, fmt.Sprint(attachment.ID)) {
+				t.Fatalf("链接来源应作为重复项跳过，返回 %q、%v", message, skipped)
+			}
+			if err := (dbProgressReporter{ds: ds}).Report(ProgressUpdate{TaskID: link.ID, Status: models.StatusCancelled, Error: message, Final: true}); err != nil {
+				t.Fatalf("写入最终状态失败: %v", err)
+			}
+
+			got, err := db.GetDownloadTaskByID(link.ID)
+			if err != nil {
+				t.Fatalf("读取任务失败: %v", err)
+			}
+			if got.Status != models.StatusCancelled || got.Error != message {
+				t.Errorf("任务状态为 %s（%q），期望已取消并记录原因", got.Status, got.Error)
+			}
+			if _, err := os.Stat(link.LocalPath); (err == nil) != tt.wantFile {
+				t.Errorf("重复来源的文件存在: %v，期望 %v", err == nil, tt.wantFile)
+			}
+			if _, err := os.Stat(attachment.LocalPath); err != nil {
+				t.Errorf("保留的来源文件被删除: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"emaild/backend/models"
+)
+
+const (
+	// duplicateProbeMessages 比对邮箱时读取的最新邮件数
+	duplicateProbeMessages = 5
+	// duplicateCheckTimeout 检查重复邮箱的整体期限
+	duplicateCheckTimeout = 45 * time.Second
+)
+
+// mailboxFingerprint 邮箱的识别信息：UIDVALIDITY 加上最新几封邮件的UID和 Message-ID
+type mailboxFingerprint struct {
+	uidValidity uint32
+	messageIDs  []string // 按字符串排序的 "UID Message-ID"
+}
+
+// matches 两个邮箱是否为同一个：UIDVALIDITY 相同且最新邮件的 Message-ID 完全一致
+// 空邮箱无法区分，不视为重复
+func (f *mailboxFingerprint) matches(other *mailboxFingerprint) bool {
+	if f.uidValidity != other.uidValidity || len(f.messageIDs) == 0 || len(f.messageIDs) != len(other.messageIDs) {
+		return false
+	}
+	for i := range f.messageIDs {
+		if f.messageIDs[i] != other.messageIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FindDuplicateMailbox 检查是否有其他账户读取的是同一个邮箱（如同一邮箱的别名地址），
+// 找到时返回提示信息，重复的账户会把同样的PDF下载两次；检查失败时返回空字符串，不影响添加账户
+// 只比对同一IMAP服务器上的账户，比较主文件夹的 UIDVALIDITY 和最新几封邮件的 Message-ID
+func (es *EmailService) FindDuplicateMailbox(account *models.EmailAccount) string {
+	accounts, err := es.db.GetEmailAccounts()
+	if err != nil {
+		es.logger.Warnf("检查重复邮箱时获取账户列表失败: %v", err)
+		return ""
+	}
+
+	var candidates []models.EmailAccount
+	for _, other := range accounts {
+		if other.ID != account.ID && strings.EqualFold(strings.TrimSpace(other.IMAPServer), strings.TrimSpace(account.IMAPServer)) {
+			candidates = append(candidates, other)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(es.ctx, duplicateCheckTimeout)
+	defer cancel()
+
+	fingerprint, err := es.probeMailboxFingerprint(ctx, account)
+	if err != nil {
+		es.logger.Warnf("检查账户 %s 是否重复失败: %v", account.Email, err)
+		return ""
+	}
+
+	for i := range candidates {
+		other := &candidates[i]
+		otherFingerprint, err := es.probeMailboxFingerprint(ctx, other)
+		if err != nil {
+			es.logger.Debugf("检查重复邮箱时无法读取账户 %s: %v", other.Email, err)
+			continue
+		}
+		if fingerprint.matches(otherFingerprint) {
+			es.logger.Warnf("账户 %s 与账户 %s 读取的是同一个邮箱", account.Email, other.Email)
+			return fmt.Sprintf("该账户与已有账户 %s 读取的是同一个邮箱（%s），同时启用会重复下载相同的文件", other.Email, account.MainFolder())
+		}
+	}
+	return ""
+}
+
+// probeMailboxFingerprint 使用独立连接只读打开账户的主文件夹，读取邮箱识别信息
+func (es *EmailService) probeMailboxFingerprint(ctx context.Context, account *models.EmailAccount) (*mailboxFingerprint, error) {
+	conn, err := es.createConnectionWithTimeout(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.close()
+
+	status, err := conn.selectFolder(conn.mainMailbox(), true)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件夹 %s 失败: %v", conn.mainMailbox(), err)
+	}
+
+	fingerprint := &mailboxFingerprint{uidValidity: status.UidValidity}
+	if status.Messages == 0 {
+		return fingerprint, nil
+	}
+
+	from := uint32(1)
+	if status.Messages > duplicateProbeMessages {
+		from = status.Messages - duplicateProbeMessages + 1
+	}
+	seqset := new(imap.SeqSet)
+	seqset.AddRange(from, status.Messages)
+
+	messages := make(chan *imap.Message, duplicateProbeMessages)
+	err = conn.withLock(func() error {
+		return conn.Client.Fetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope}, messages)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取邮件信息失败: %v", err)
+	}
+
+	// 同一邮箱在不同连接中的UID相同，记录 "UID Message-ID" 并排序后逐个比较
+	for msg := range messages {
+		if msg.Envelope == nil {
+			continue
+		}
+		fingerprint.messageIDs = append(fingerprint.messageIDs, fmt.Sprintf("%d %s", msg.Uid, msg.Envelope.MessageId))
+	}
+	sort.Strings(fingerprint.messageIDs)
+	return fingerprint, nil
+}
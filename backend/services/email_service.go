@@ -1,16 +1,20 @@
 package services
 
 import (
+	"bufio"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"emaild/backend/database"
@@ -30,6 +34,10 @@ type EmailService struct {
 	db               *database.Database
 	connections      map[uint]*IMAPConnection    // 按邮箱ID管理连接
 	connectionsMutex sync.RWMutex               // 保护连接映射的读写锁
+	
+	// 全部IMAP连接共用的连接数上限（见 connection_limit.go），与下载服务的附件连接共用
+	limiter *connectionLimiter
+	
 	downloadService  *DownloadService           // 下载服务
 	ctx              context.Context            // 服务上下文
 	cancel           context.CancelFunc         // 取消函数
@@ -37,6 +45,24 @@ type EmailService struct {
 	isRunning        bool                       // 是否正在运行
 	runningMutex     sync.RWMutex               // 保护运行状态的锁
 	logger           *logrus.Logger
+	telemetry        *TelemetryService          // 匿名错误遥测（可选）
+	
+	// 各账户检查相互独立，卡住的账户在自己的期限到达时被中止
+	checkingAccounts sync.Map                         // 正在检查的账户ID，避免上一次未结束时重复检查
+	onAccountChecked func(models.EmailCheckResult)    // 每个账户检查结束时的回调（可选）
+	
+	// 开启IDLE的账户通过独立连接接收新邮件推送，不再依赖定时检查
+	idleWatchers      map[uint]*idleWatcher
+	idleWatchersMutex sync.Mutex
+	
+	// OAuth2 账户的访问令牌缓存
+	oauthTokens oauthTokenCache
+	
+	// 免打扰时段（见 quiet_hours.go），时段内后台不检查邮件
+	quietEnabled bool
+	quietStart   int // 开始时间（从零点开始的分钟数）
+	quietEnd     int // 结束时间（从零点开始的分钟数）
+	quietMutex   sync.RWMutex
 	
 	// 优雅关闭相关
 	wg              sync.WaitGroup    // 等待所有goroutine完成
@@ -47,15 +73,32 @@ type EmailService struct {
 
 // IMAPConnection IMAP连接管理
 type IMAPConnection struct {
-	ID          uint
-	Account     *models.EmailAccount
-	Client      *client.Client
-	LastUsed    time.Time
-	IsConnected bool
-	Mutex       sync.Mutex // 连接级别的锁
-	ctx         context.Context
-	cancel      context.CancelFunc
-	closeOnce   sync.Once  // 确保连接只关闭一次
+	ID            uint
+	Account       *models.EmailAccount
+	Client        *client.Client
+	lastUsed      atomic.Int64 // 最近使用时间（UnixNano），不加连接锁读写，回收连接时不必等待正在进行的操作
+	IsConnected   bool
+	AuthMechanism string       // 实际使用的认证机制
+	IsGmail       bool         // 服务器支持Gmail扩展（X-GM-EXT-1）
+	Binary        bool         // 服务器支持BINARY扩展（RFC 3516），附件内容可由服务器解码
+	Quirks        ServerQuirks // 服务器需要的兼容处理
+	Mutex         sync.Mutex   // 连接级别的锁
+	users         int          // 正在使用该缓存连接的操作数，由 EmailService.connectionsMutex 保护，为0时才能被回收
+	ctx           context.Context
+	cancel        context.CancelFunc
+	closeOnce     sync.Once  // 确保连接只关闭一次
+	tunnel        *sshTunnel // 通过SSH隧道连接时的隧道，随连接一起关闭
+	releaseSlot   func()     // 归还占用的连接数名额，连接关闭时调用
+}
+
+// touch 记录连接被使用
+func (conn *IMAPConnection) touch() {
+	conn.lastUsed.Store(time.Now().UnixNano())
+}
+
+// lastUsedAt 返回连接最近被使用的时间
+func (conn *IMAPConnection) lastUsedAt() time.Time {
+	return time.Unix(0, conn.lastUsed.Load())
 }
 
 // 使用backend包中的EmailCheckResult定义
@@ -64,9 +107,17 @@ type IMAPConnection struct {
 func NewEmailService(db *database.Database, downloadService *DownloadService, logger *logrus.Logger) *EmailService {
 	ctx, cancel := context.WithCancel(context.Background())
 	
-	return &EmailService{
+	// 与下载服务共用连接数上限，附件连接和检查连接一起计数
+	limiter := newConnectionLimiter()
+	if downloadService != nil {
+		limiter = downloadService.connLimiter
+	}
+	
+	es := &EmailService{
 		db:               db,
 		connections:      make(map[uint]*IMAPConnection),
+		limiter:          limiter,
+		idleWatchers:     make(map[uint]*idleWatcher),
 		downloadService:  downloadService,
 		ctx:              ctx,
 		cancel:           cancel,
@@ -75,6 +126,8 @@ func NewEmailService(db *database.Database, downloadService *DownloadService, lo
 		logger:           logger,
 		isShuttingDown:   false,
 	}
+	limiter.addReclaimer(es.reclaimIdleConnection)
+	return es
 }
 
 // SetCheckInterval 设置检查间隔
@@ -86,6 +139,11 @@ func (es *EmailService) SetCheckInterval(interval time.Duration) {
 	es.logger.Infof("邮件检查间隔已设置为: %v", interval)
 }
 
+// SetTelemetry 设置错误遥测服务
+func (es *EmailService) SetTelemetry(telemetry *TelemetryService) {
+	es.telemetry = telemetry
+}
+
 // StartEmailMonitoring 启动邮件监控
 func (es *EmailService) StartEmailMonitoring() error {
 	es.runningMutex.Lock()
@@ -163,6 +221,9 @@ func (es *EmailService) emailChecker() {
 	ticker := time.NewTicker(es.checkInterval)
 	defer ticker.Stop()
 	
+	// 开启IDLE的账户立即建立推送连接，之后每个检查周期按账户设置同步
+	es.refreshIdleWatchers()
+	
 	for {
 		select {
 		case <-es.ctx.Done():
@@ -177,6 +238,11 @@ func (es *EmailService) emailChecker() {
 			}
 			es.shutdownMutex.RUnlock()
 			
+			es.refreshIdleWatchers()
+			if es.InQuietHours() {
+				es.logger.Debug("处于免打扰时段，跳过本次邮件检查")
+				continue
+			}
 			es.checkAllAccounts()
 		}
 	}
@@ -209,28 +275,38 @@ func (es *EmailService) connectionCleaner() {
 }
 
 // cleanupIdleConnections 清理空闲连接
+// 检测连接需要等待连接锁，在连接映射的锁之外进行，只清理没有操作在使用的连接
 func (es *EmailService) cleanupIdleConnections() {
-	es.connectionsMutex.Lock()
-	defer es.connectionsMutex.Unlock()
-	
 	cutoff := time.Now().Add(-30 * time.Minute) // 30分钟未使用则清理
-	var toDelete []uint
 	
+	es.connectionsMutex.RLock()
+	idle := make(map[uint]*IMAPConnection)
 	for accountID, conn := range es.connections {
-		if conn.LastUsed.Before(cutoff) || !conn.isAlive() {
-			conn.close()
-			toDelete = append(toDelete, accountID)
+		if conn.users == 0 {
+			idle[accountID] = conn
 		}
 	}
+	es.connectionsMutex.RUnlock()
 	
-	// 删除已关闭的连接
-	for _, accountID := range toDelete {
-		delete(es.connections, accountID)
-		es.logger.Debugf("清理了账户 %d 的空闲连接", accountID)
+	var expired []*IMAPConnection
+	for accountID, conn := range idle {
+		if conn.lastUsedAt().After(cutoff) && conn.isAlive() {
+			continue
+		}
+		es.connectionsMutex.Lock()
+		if es.connections[accountID] == conn && conn.users == 0 {
+			es.removeConnectionLocked(accountID)
+			expired = append(expired, conn)
+			es.logger.Debugf("清理了账户 %d 的空闲连接", accountID)
+		}
+		es.connectionsMutex.Unlock()
 	}
 	
-	if len(toDelete) > 0 {
-		es.logger.Infof("清理了 %d 个空闲连接", len(toDelete))
+	for _, conn := range expired {
+		conn.close()
+	}
+	if len(expired) > 0 {
+		es.logger.Infof("清理了 %d 个空闲连接", len(expired))
 	}
 }
 
@@ -244,7 +320,8 @@ func (es *EmailService) checkAllAccounts() {
 	
 	es.logger.Debugf("开始检查 %d 个活跃邮箱账户", len(accounts))
 	
-	// 使用WaitGroup等待所有检查完成
+	// 每个账户有独立的期限，正常的账户完成后立即报告结果，不必等待卡住的账户
+	timeout := es.accountCheckTimeout()
 	var checkWg sync.WaitGroup
 	for _, account := range accounts {
 		// 检查是否正在关闭
@@ -255,56 +332,32 @@ func (es *EmailService) checkAllAccounts() {
 		}
 		es.shutdownMutex.RUnlock()
 		
+		// 暂停监控的账户只跳过后台检查，手动检查不受影响
+		if account.MonitoringPaused {
+			es.logger.Debugf("账户 %s 已暂停监控，跳过", account.Email)
+			continue
+		}
+		
+		// 通过IDLE接收推送的账户不需要定时检查，IDLE不可用或连接中断时恢复定时检查
+		if es.isIdleActive(account.ID) {
+			continue
+		}
+		
 		checkWg.Add(1)
 		go func(acc models.EmailAccount) {
 			defer checkWg.Done()
-			es.checkAccount(&acc)
+			es.checkAccountWithDeadline(&acc, timeout)
 		}(account)
 	}
 	
-	// 等待所有检查完成或超时
-	done := make(chan struct{})
-	go func() {
-		checkWg.Wait()
-		close(done)
-	}()
-	
-	select {
-	case <-done:
-		es.logger.Debug("所有邮箱账户检查完成")
-	case <-time.After(5 * time.Minute):
-		es.logger.Warn("邮箱账户检查超时")
-	case <-es.ctx.Done():
-		es.logger.Info("邮箱检查被中断")
-	}
+	// 每个账户最多等待到自己的期限，本轮等待时间不超过单个账户的期限
+	checkWg.Wait()
+	es.logger.Debug("本轮邮箱账户检查结束")
 }
 
 // getActiveAccounts 获取活跃的邮箱账户
 func (es *EmailService) getActiveAccounts() ([]models.EmailAccount, error) {
-	query := `SELECT id, name, email, password, imap_server, imap_port, use_ssl, is_active, created_at, updated_at 
-			  FROM email_accounts WHERE is_active = 1`
-	
-	rows, err := es.db.DB.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var accounts []models.EmailAccount
-	for rows.Next() {
-		var account models.EmailAccount
-		err := rows.Scan(
-			&account.ID, &account.Name, &account.Email, &account.Password,
-			&account.IMAPServer, &account.IMAPPort, &account.UseSSL, &account.IsActive,
-			&account.CreatedAt, &account.UpdatedAt,
-		)
-		if err != nil {
-			continue
-		}
-		accounts = append(accounts, account)
-	}
-	
-	return accounts, nil
+	return es.db.GetActiveEmailAccounts()
 }
 
 // CheckAccountWithResult 检查指定账户并返回详细结果
@@ -320,36 +373,121 @@ func (es *EmailService) CheckAccountWithResult(account *models.EmailAccount) mod
 	if err != nil {
 		result.Error = fmt.Sprintf("获取连接失败: %v", err)
 		es.logger.Errorf("账户%d连接失败: %v", account.ID, err)
+		es.telemetry.RecordError(TelemetrySourceEmailCheck, err, account.IMAPServer)
 		return result
 	}
-	defer es.releaseConnection(account.ID)
+	// 重试时 conn 会被替换为新连接，释放最终使用的连接
+	defer func() { es.releaseConnection(conn) }()
 
-	// 选择收件箱
-	if err := conn.selectInbox(); err != nil {
-		result.Error = fmt.Sprintf("选择收件箱失败: %v", err)
-		es.logger.Errorf("账户%d选择收件箱失败: %v", account.ID, err)
+	// 按配置的历史范围搜索未读邮件，避免首次检查处理大量旧邮件
+	config, err := es.getDownloadConfig()
+	if err != nil {
+		result.Error = fmt.Sprintf("获取配置失败: %v", err)
 		return result
 	}
-
-	// 搜索未读邮件
-	messages, err := conn.searchUnreadMessages()
+	since, err := HistorySince(config.CheckScope, config.CheckScopeDays, account)
+	if err != nil {
+		es.logger.Warnf("账户%d历史范围配置无效，使用默认范围: %v", account.ID, err)
+		since, _ = HistorySince(models.HistoryScopeSinceAccountAdded, 0, account)
+	}
+	
+	criteria, parseErr := ParseSearchCriteria(account.SearchCriteria)
+	if parseErr != nil {
+		// 自定义条件无效时回退到内置搜索，并在结果中提示
+		es.logger.Warnf("账户%d自定义搜索条件无效，使用默认搜索: %v", account.ID, parseErr)
+		result.Error = fmt.Sprintf("自定义搜索条件无效，已使用默认搜索: %v", parseErr)
+		criteria = nil
+	}
+	
+	// 选择收件箱并搜索邮件，网络抖动等临时性错误时重新连接后重试
+	var messages []*imap.Message
+	retries, err := es.withIMAPRetry(&conn, "选择收件箱并搜索邮件", func(c *IMAPConnection) error {
+		if err := c.selectInbox(); err != nil {
+			return fmt.Errorf("选择收件箱失败: %w", err)
+		}
+		
+		var searchErr error
+		if criteria != nil {
+			messages, searchErr = c.searchWithCriteria(criteria)
+		} else {
+			messages, searchErr = c.searchUnreadMessages(since, scanWindowSince(config))
+		}
+		if searchErr != nil {
+			return fmt.Errorf("搜索邮件失败: %w", searchErr)
+		}
+		return nil
+	})
+	result.Retries = retries
 	if err != nil {
-		result.Error = fmt.Sprintf("搜索邮件失败: %v", err)
-		es.logger.Errorf("账户%d搜索邮件失败: %v", account.ID, err)
+		result.Error = err.Error()
+		es.logger.Errorf("账户%d检查失败（重试%d次）: %v", account.ID, retries, err)
+		es.telemetry.RecordError(TelemetrySourceEmailCheck, err, account.IMAPServer)
 		return result
 	}
 
 	result.NewEmails = len(messages)
 	es.logger.Infof("账户%d发现%d封未读邮件", account.ID, len(messages))
 
+	// 上次检查因任务数限制推迟的邮件优先处理
+	messages = append(es.loadDeferredMessages(conn), messages...)
+
+	maxTasks := config.MaxTasksPerCheck
+
 	// 处理每封邮件并统计PDF数量
 	pdfCount := 0
-	for _, msg := range messages {
-		pdfSources := es.analyzePDFSources(account, msg)
-		if len(pdfSources) > 0 {
-			pdfCount += len(pdfSources)
-			// 处理邮件（保存记录和创建下载任务）
-			es.processMessage(account, msg)
+	tasksCreated := 0
+	var deferred, processed []uint32
+	for i, msg := range messages {
+		// 达到单次任务上限后，剩余邮件推迟到下次检查
+		if maxTasks > 0 && tasksCreated >= maxTasks {
+			deferred = uniqueUIDs(messages[i:])
+			es.logger.Infof("账户%d本次检查已创建%d个任务，达到上限，%d封邮件推迟到下次检查",
+				account.ID, tasksCreated, len(deferred))
+			break
+		}
+		
+		pdfs, tasks := es.handleMessage(account, msg, conn.mainTaskFolder())
+		pdfCount += pdfs
+		tasksCreated += tasks
+		if tasks > 0 {
+			processed = append(processed, msg.Uid)
+		}
+	}
+	if config.MarkAsRead && len(processed) > 0 {
+		es.markMessagesSeen(conn, processed)
+	}
+	
+	// 额外扫描的文件夹（如已发送、草稿箱）按UID增量处理，共享本次检查的任务数上限
+	for _, folder := range account.ScanFolders {
+		if maxTasks > 0 && tasksCreated >= maxTasks {
+			break
+		}
+		remaining := 0
+		if maxTasks > 0 {
+			remaining = maxTasks - tasksCreated
+		}
+		
+		found, pdfs, tasks, err := es.scanFolderIncremental(conn, account, folder, since, remaining)
+		if err != nil {
+			es.logger.Warnf("账户%d扫描文件夹 %s 失败: %v", account.ID, folder, err)
+			continue
+		}
+		result.NewEmails += found
+		pdfCount += pdfs
+		tasksCreated += tasks
+	}
+
+	if err := es.db.SetDeferredMessageUIDs(account.ID, deferred); err != nil {
+		es.logger.Warnf("账户%d保存推迟邮件列表失败: %v", account.ID, err)
+	}
+
+	// 首次检查时按配置额外导入一次更早的邮件（包括已读邮件），失败时下次检查再尝试
+	if criteria == nil && needsInitialScan(config, account) {
+		tasks, err := es.ImportHistory(account, models.HistoryScopeLastNDays, config.InitialScanDays)
+		if err != nil {
+			es.logger.Warnf("账户%d首次检查导入最近%d天的邮件失败: %v", account.ID, config.InitialScanDays, err)
+		} else {
+			es.logger.Infof("账户%d首次检查导入最近%d天的邮件，创建%d个任务", account.ID, config.InitialScanDays, tasks)
 		}
 	}
 
@@ -360,33 +498,322 @@ func (es *EmailService) CheckAccountWithResult(account *models.EmailAccount) mod
 	return result
 }
 
-func (es *EmailService) checkAccount(account *models.EmailAccount) {
+// handleMessage 分析并处理单封邮件，返回发现的PDF数和创建的任务数
+// folder 为邮件所在文件夹，空字符串表示收件箱
+func (es *EmailService) handleMessage(account *models.EmailAccount, msg *imap.Message, folder string) (int, int) {
+	// 分段邮件片段中没有可直接识别的PDF，交给processMessage保存片段
+	if _, ok := parsePartialMessage(msg); ok {
+		return 0, es.processMessage(account, msg, folder)
+	}
+	
+	pdfSources := es.analyzePDFSources(account, msg)
+	if len(pdfSources) == 0 {
+		return 0, 0
+	}
+	// 处理邮件（保存记录和创建下载任务）
+	return len(pdfSources), es.processMessage(account, msg, folder)
+}
+
+// scanFolderIncremental 扫描额外的文件夹，只处理上次记录的UID之后的新邮件，不按未读状态过滤
+// 首次扫描或UIDVALIDITY变化时按历史范围从头开始；maxTasks 为0表示不限制
+// 返回获取的邮件数、发现的PDF数和创建的任务数
+func (es *EmailService) scanFolderIncremental(conn *IMAPConnection, account *models.EmailAccount, folder string, since time.Time, maxTasks int) (int, int, int, error) {
+	mailbox := ResolveFolder(conn.Account, folder)
+	status, err := conn.selectFolder(mailbox, true)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("选择文件夹失败: %v", err)
+	}
+	
+	uidValidity, lastUID, err := es.db.GetFolderState(account.ID, mailbox)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("读取文件夹扫描状态失败: %v", err)
+	}
+	if uidValidity != status.UidValidity {
+		// 文件夹被重建，之前的UID不再有效
+		lastUID = 0
+	}
+	
+	uids, err := conn.searchUIDsAfter(lastUID, since)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if len(uids) == 0 {
+		if uidValidity != status.UidValidity {
+			es.db.SaveFolderState(account.ID, mailbox, status.UidValidity, lastUID)
+		}
+		return 0, 0, 0, nil
+	}
+	
+	// 从最早的新邮件开始处理，剩余的留到下次检查
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	if len(uids) > maxMessagesPerSearch {
+		uids = uids[:maxMessagesPerSearch]
+	}
+	
+	messages, err := conn.fetchMessagesWithItems(uids, historyFetchItems)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Uid < messages[j].Uid })
+	
+	pdfCount, tasksCreated := 0, 0
+	for _, msg := range messages {
+		if maxTasks > 0 && tasksCreated >= maxTasks {
+			es.logger.Infof("账户%d文件夹 %s 达到本次任务上限，剩余邮件推迟到下次检查", account.ID, mailbox)
+			break
+		}
+		pdfs, tasks := es.handleMessage(account, msg, mailbox)
+		pdfCount += pdfs
+		tasksCreated += tasks
+		lastUID = msg.Uid
+	}
+	
+	if err := es.db.SaveFolderState(account.ID, mailbox, status.UidValidity, lastUID); err != nil {
+		es.logger.Warnf("账户%d保存文件夹 %s 扫描状态失败: %v", account.ID, mailbox, err)
+	}
+	es.logger.Infof("账户%d文件夹 %s 扫描完成: %d封邮件, %d个PDF", account.ID, mailbox, len(messages), pdfCount)
+	return len(messages), pdfCount, tasksCreated, nil
+}
+
+// HistorySince 根据历史范围计算需要处理的最早邮件日期，零值表示不限制
+func HistorySince(scope string, days int, account *models.EmailAccount) (time.Time, error) {
+	switch scope {
+	case "", models.HistoryScopeSinceAccountAdded:
+		createdAt, err := models.StringToTime(account.CreatedAt)
+		if err != nil || createdAt.IsZero() {
+			return time.Now(), nil
+		}
+		return createdAt, nil
+	case models.HistoryScopeLastNDays:
+		if days <= 0 {
+			return time.Time{}, fmt.Errorf("历史范围天数必须大于0")
+		}
+		return time.Now().AddDate(0, 0, -days), nil
+	case models.HistoryScopeAll:
+		return time.Time{}, nil
+	}
+	return time.Time{}, fmt.Errorf("不支持的历史范围: %s", scope)
+}
+
+// maxMessagesPerSearch 每次检查最多获取的邮件数，避免超时
+const maxMessagesPerSearch = 50
+
+const (
+	// defaultFetchBatchSize 默认每批获取的邮件数
+	defaultFetchBatchSize = 50
+	// MaxFetchBatchSize 每批获取邮件数的上限
+	MaxFetchBatchSize = 500
+)
+
+// fetchBatchSize 返回配置的每批获取邮件数，未配置或超出范围时使用默认值
+func fetchBatchSize(config *models.AppConfig) int {
+	if config == nil || config.FetchBatchSize <= 0 || config.FetchBatchSize > MaxFetchBatchSize {
+		return defaultFetchBatchSize
+	}
+	return config.FetchBatchSize
+}
+
+// ImportHistory 按历史范围导入账户中的全部邮件（包括已读邮件），返回创建的任务数
+// 与后台检查不同，不受单次任务数限制，且使用PEEK获取以免将历史邮件标记为已读
+func (es *EmailService) ImportHistory(account *models.EmailAccount, scope string, days int) (int, error) {
+	since, err := HistorySince(scope, days, account)
+	if err != nil {
+		return 0, err
+	}
+	
+	conn, err := es.getConnection(account.ID)
+	if err != nil {
+		return 0, fmt.Errorf("获取连接失败: %v", err)
+	}
+	defer es.releaseConnection(conn)
+	
+	if err := conn.selectInbox(); err != nil {
+		return 0, fmt.Errorf("选择文件夹 %s 失败: %v", conn.mainMailbox(), err)
+	}
+	
+	uids, err := conn.searchAllSince(since)
+	if err != nil {
+		return 0, fmt.Errorf("搜索邮件失败: %v", err)
+	}
+	es.logger.Infof("账户%d历史导入（范围: %s）共%d封邮件", account.ID, scope, len(uids))
+	
+	config, _ := es.getDownloadConfig()
+	batchSize := fetchBatchSize(config)
+	
+	tasksCreated := 0
+	for start := 0; start < len(uids); start += batchSize {
+		select {
+		case <-es.ctx.Done():
+			return tasksCreated, fmt.Errorf("历史导入已取消")
+		default:
+		}
+		
+		end := start + batchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		
+		messages, err := conn.fetchMessagesWithItems(uids[start:end], historyFetchItems)
+		if err != nil {
+			return tasksCreated, err
+		}
+		for _, msg := range messages {
+			_, tasks := es.handleMessage(account, msg, conn.mainTaskFolder())
+			tasksCreated += tasks
+		}
+	}
+	
+	if err := es.db.SetHistoricalImportDone(account.ID, true); err != nil {
+		es.logger.Warnf("账户%d保存历史导入状态失败: %v", account.ID, err)
+	}
+	es.logger.Infof("账户%d历史导入完成，创建%d个任务", account.ID, tasksCreated)
+	return tasksCreated, nil
+}
+
+// ScanAccountRange 扫描账户主文件夹中接收日期在 [since, before) 内的全部邮件（不论是否已读），
+// 按正常流程处理并创建下载任务，已处理过的邮件会被跳过；before 为零值时扫描到最新的邮件
+// 按配置的批量大小分批获取邮件，避免一次获取大量邮件
+func (es *EmailService) ScanAccountRange(account *models.EmailAccount, since, before time.Time) models.EmailCheckResult {
+	result := models.EmailCheckResult{Account: account}
+	
+	conn, err := es.getConnection(account.ID)
+	if err != nil {
+		result.Error = fmt.Sprintf("获取连接失败: %v", err)
+		return result
+	}
+	defer func() { es.releaseConnection(conn) }()
+	
+	var uids []uint32
+	retries, err := es.withIMAPRetry(&conn, "按日期范围搜索邮件", func(c *IMAPConnection) error {
+		if err := c.selectInbox(); err != nil {
+			return fmt.Errorf("选择文件夹 %s 失败: %w", c.mainMailbox(), err)
+		}
+		
+		var searchErr error
+		uids, searchErr = c.searchDateRange(since, before)
+		if searchErr != nil {
+			return fmt.Errorf("搜索邮件失败: %w", searchErr)
+		}
+		return nil
+	})
+	result.Retries = retries
+	if err != nil {
+		result.Error = err.Error()
+		es.logger.Errorf("账户%d按日期范围扫描失败: %v", account.ID, err)
+		return result
+	}
+	result.NewEmails = len(uids)
+	until := "最新"
+	if !before.IsZero() {
+		until = before.Format("2006-01-02") + " 之前"
+	}
+	es.logger.Infof("账户%d按日期范围扫描（%s 至%s）共%d封邮件", account.ID, since.Format("2006-01-02"), until, len(uids))
+	
+	config, _ := es.getDownloadConfig()
+	batchSize := fetchBatchSize(config)
+	
+	tasksCreated := 0
+	for start := 0; start < len(uids); start += batchSize {
+		select {
+		case <-es.ctx.Done():
+			result.Error = "扫描已取消"
+			return result
+		default:
+		}
+		
+		end := start + batchSize
+		if end > len(uids) {
+			end = len(uids)
+		}
+		
+		messages, err := conn.fetchMessagesWithItems(uids[start:end], historyFetchItems)
+		if err != nil {
+			result.Error = fmt.Sprintf("获取邮件失败: %v", err)
+			return result
+		}
+		for _, msg := range messages {
+			pdfs, tasks := es.handleMessage(account, msg, conn.mainTaskFolder())
+			result.PDFsFound += pdfs
+			tasksCreated += tasks
+		}
+	}
+	
+	result.Success = true
+	es.logger.Infof("账户%d按日期范围扫描完成: %d封邮件, %d个PDF, 创建%d个任务",
+		account.ID, result.NewEmails, result.PDFsFound, tasksCreated)
+	return result
+}
+
+// loadDeferredMessages 获取上次检查推迟处理的邮件
+// 这些邮件在上次获取正文时已被标记为已读，无法再通过未读搜索找到，需按UID重新获取
+func (es *EmailService) loadDeferredMessages(conn *IMAPConnection) []*imap.Message {
+	uids, err := es.db.GetDeferredMessageUIDs(conn.Account.ID)
+	if err != nil {
+		es.logger.Warnf("账户%d读取推迟邮件列表失败: %v", conn.Account.ID, err)
+		return nil
+	}
+	if len(uids) == 0 {
+		return nil
+	}
+	
+	messages, err := conn.fetchMessagesByUID(uids)
+	if err != nil {
+		es.logger.Warnf("账户%d获取推迟邮件失败: %v", conn.Account.ID, err)
+		return nil
+	}
+	
+	es.logger.Infof("账户%d继续处理上次推迟的%d封邮件", conn.Account.ID, len(messages))
+	return messages
+}
+
+// uniqueUIDs 提取邮件UID并去重，保持原有顺序
+func uniqueUIDs(messages []*imap.Message) []uint32 {
+	seen := make(map[uint32]bool)
+	var uids []uint32
+	for _, msg := range messages {
+		if msg.Uid == 0 || seen[msg.Uid] {
+			continue
+		}
+		seen[msg.Uid] = true
+		uids = append(uids, msg.Uid)
+	}
+	return uids
+}
+
+func (es *EmailService) checkAccount(account *models.EmailAccount) models.EmailCheckResult {
 	// 使用新的CheckAccountWithResult方法
 	result := es.CheckAccountWithResult(account)
 	if !result.Success {
 		es.logger.Errorf("账户%d检查失败: %s", account.ID, result.Error)
 	}
+	return result
 }
 
 // getConnection 获取连接（支持连接复用和重连）
+// 建立连接和检测连接时不持有连接映射的锁，一个账户的网络卡顿不会阻塞其他账户
 func (es *EmailService) getConnection(accountID uint) (*IMAPConnection, error) {
-	es.connectionsMutex.Lock()
-	defer es.connectionsMutex.Unlock()
+	es.connectionsMutex.RLock()
+	conn, exists := es.connections[accountID]
+	es.connectionsMutex.RUnlock()
 	
 	// 检查是否已有连接
-	if conn, exists := es.connections[accountID]; exists {
-		conn.Mutex.Lock()
-		defer conn.Mutex.Unlock()
-		
-		// 检查连接是否仍然有效
-		if conn.IsConnected && conn.isAlive() {
-			conn.LastUsed = time.Now()
-			return conn, nil
+	if exists {
+		// 检查连接是否仍然有效（isAlive 自行加连接锁）
+		if conn.isAlive() {
+			conn.touch()
+			// 检测期间连接可能因连接数达到上限被回收，此时重新建立
+			if es.useConnection(accountID, conn) {
+				return conn, nil
+			}
+		} else {
+			// 连接失效，关闭并重新创建
+			es.connectionsMutex.Lock()
+			if es.connections[accountID] == conn {
+				es.removeConnectionLocked(accountID)
+			}
+			es.connectionsMutex.Unlock()
+			conn.close()
 		}
-		
-		// 连接失效，关闭并重新创建
-		conn.close()
-		delete(es.connections, accountID)
 	}
 	
 	// 创建新连接
@@ -395,100 +822,554 @@ func (es *EmailService) getConnection(accountID uint) (*IMAPConnection, error) {
 		return nil, err
 	}
 	
-	conn, err := es.createConnection(account)
+	conn, err = es.createConnection(account)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 首次连接时探测文件夹结构和账户权限
+	es.ensureFolderCache(conn)
+	es.probePermissions(conn)
+	
+	es.connectionsMutex.Lock()
+	defer es.connectionsMutex.Unlock()
+	if existing, exists := es.connections[accountID]; exists {
+		// 其他检查同时建立了连接，使用已缓存的连接
+		go conn.close()
+		existing.users++
+		return existing, nil
+	}
+	conn.users = 1
+	es.connections[accountID] = conn
+	return conn, nil
+}
+
+// releaseConnection 结束一次操作对连接的使用，缓存中的连接不关闭，供之后的检查复用
+// 空闲连接由连接清理器定期清理，或在连接数达到上限时被回收；已被移出缓存的连接在最后一个使用者释放时关闭
+func (es *EmailService) releaseConnection(conn *IMAPConnection) {
+	es.connectionsMutex.Lock()
+	if conn.users > 0 {
+		conn.users--
+	}
+	idle := conn.users == 0
+	retired := idle && es.connections[conn.ID] != conn
+	es.connectionsMutex.Unlock()
+	
+	if retired {
+		conn.close()
+	} else if idle {
+		// 空闲的缓存连接可以被回收，唤醒等待连接名额的操作
+		es.limiter.signal()
+	}
+}
+
+// CloseConnection 关闭并移除账户的缓存连接和IDLE连接，账户被删除、停用或修改检查文件夹后调用
+func (es *EmailService) CloseConnection(accountID uint) {
+	es.dropConnection(accountID)
+	es.stopIdleWatcher(accountID)
+}
+
+// getAccountByID 根据ID获取邮箱账户
+func (es *EmailService) getAccountByID(accountID uint) (*models.EmailAccount, error) {
+	return es.db.GetEmailAccountByID(accountID)
+}
+
+// createConnection 创建IMAP连接
+func (es *EmailService) createConnection(account *models.EmailAccount) (*IMAPConnection, error) {
+	return es.createConnectionWithTimeout(es.ctx, account)
+}
+
+// createConnectionWithTimeout 创建带超时的IMAP连接，连接占用连接数上限的一个名额，关闭时归还
+func (es *EmailService) createConnectionWithTimeout(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+	return es.createReservedConnection(ctx, account, 0)
+}
+
+// createReservedConnection 占用连接数名额后建立连接，reserve 为占用后至少还需剩余的名额数
+func (es *EmailService) createReservedConnection(ctx context.Context, account *models.EmailAccount, reserve int) (*IMAPConnection, error) {
+	release, err := es.limiter.acquire(ctx, reserve)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := es.dialConnection(ctx, account)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	conn.releaseSlot = release
+	return conn, nil
+}
+
+// dialConnection 连接服务器并登录
+func (es *EmailService) dialConnection(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
+	// 连接到IMAP服务器
+	var c *client.Client
+	var err error
+	
+	serverAddr := fmt.Sprintf("%s:%d", account.IMAPServer, account.IMAPPort)
+	es.logger.Infof("正在连接到 %s (SSL: %v)", serverAddr, account.UseSSL)
+	
+	// OAuth2 账户先获取访问令牌，授权失效时不必连接服务器
+	var accessToken string
+	if account.UsesOAuth2() {
+		accessToken, err = es.oauthAccessToken(ctx, account)
+		if err != nil {
+			return nil, fmt.Errorf("IMAP登录失败 %s: %w", account.Email, err)
+		}
+	}
+	
+	// 配置了SSH隧道时改为连接隧道的本地端口，TLS仍按IMAP服务器名称验证
+	dialAddr := serverAddr
+	var tunnel *sshTunnel
+	if account.UsesSSHTunnel() {
+		tunnel, err = es.openSSHTunnel(account)
+		if err != nil {
+			return nil, err
+		}
+		dialAddr = tunnel.addr()
+	}
+	
+	// 证书固定只适用于SSL连接
+	var pin *certPin
+	if account.UseSSL {
+		pin = es.newCertPin(account)
+	}
+	
+	if account.UseSSL {
+		// SSL连接 - 添加更灵活的TLS配置
+		tlsConfig := &tls.Config{
+			ServerName:         account.IMAPServer,
+			InsecureSkipVerify: false,
+		}
+		if pin != nil {
+			tlsConfig.VerifyConnection = pin.verifyConnection
+		}
+		
+		c, err = dialIMAP(dialAddr, tlsConfig)
+		if err != nil && !errors.Is(err, ErrCertificateChanged) {
+			// 如果严格验证失败，尝试宽松模式
+			es.logger.Warnf("严格SSL验证失败，尝试跳过证书验证: %v", err)
+			tlsConfig.InsecureSkipVerify = true
+			c, err = dialIMAP(dialAddr, tlsConfig)
+		}
+	} else {
+		// 普通连接
+		c, err = dialIMAP(dialAddr, nil)
+	}
+	
 	if err != nil {
+		tunnel.close()
+	}
+	if errors.Is(err, ErrCertificateChanged) {
+		// 证书变更时拒绝连接，记录新指纹等待用户确认
+		es.recordCertificate(account, pin)
 		return nil, err
 	}
+	if err != nil {
+		return nil, fmt.Errorf("连接IMAP服务器失败 %s: %v", serverAddr, err)
+	}
+	
+	// 登录
+	es.logger.Infof("正在登录账户 %s", account.Email)
+	var mechanism string
+	if account.UsesOAuth2() {
+		mechanism, err = authenticateOAuth2(c, account, accessToken)
+	} else {
+		mechanism, err = authenticate(c, account)
+	}
+	if err != nil {
+		c.Close()
+		tunnel.close()
+		if errors.Is(err, ErrOAuthTokenRejected) {
+			// 令牌可能已被撤销，下次连接时重新刷新
+			es.forgetOAuthToken(account.ID)
+		}
+		return nil, fmt.Errorf("IMAP登录失败 %s: %w", account.Email, err)
+	}
+	
+	// 登录成功后才记录首次连接的证书，避免记录错误服务器的证书
+	es.recordCertificate(account, pin)
+	
+	// 按服务器地址和能力确定兼容处理（如网易邮箱需要先发送ID）
+	quirks := es.resolveServerQuirks(c, account.IMAPServer)
+	
+	connCtx, cancel := context.WithCancel(ctx)
+	
+	conn := &IMAPConnection{
+		ID:            account.ID,
+		Account:       account,
+		Client:        c,
+		IsConnected:   true,
+		AuthMechanism: mechanism,
+		IsGmail:       supportsGmailExtensions(c),
+		Binary:        supportsBinary(c),
+		Quirks:        quirks,
+		ctx:           connCtx,
+		cancel:        cancel,
+		tunnel:        tunnel,
+	}
+	conn.touch()
+	
+	es.logger.Infof("成功创建连接 %s（认证方式: %s）", account.Email, mechanism)
+	return conn, nil
+}
+
+// specialUseAttrs SPECIAL-USE属性与文件夹角色的对应关系
+var specialUseAttrs = map[string]string{
+	imap.AllAttr:     models.FolderRoleAll,
+	imap.ArchiveAttr: models.FolderRoleArchive,
+	imap.DraftsAttr:  models.FolderRoleDrafts,
+	imap.FlaggedAttr: models.FolderRoleFlagged,
+	imap.JunkAttr:    models.FolderRoleJunk,
+	imap.SentAttr:    models.FolderRoleSent,
+	imap.TrashAttr:   models.FolderRoleTrash,
+}
+
+// specialFolderNames 服务器不支持SPECIAL-USE时，按常见文件夹名识别特殊用途文件夹
+var specialFolderNames = map[string][]string{
+	models.FolderRoleSent:    {"sent", "sent messages", "sent items", "sent mail", "已发送", "已发送邮件"},
+	models.FolderRoleDrafts:  {"drafts", "draft", "草稿箱", "草稿"},
+	models.FolderRoleJunk:    {"junk", "spam", "junk e-mail", "junk email", "bulk mail", "垃圾邮件", "垃圾箱"},
+	models.FolderRoleTrash:   {"trash", "deleted messages", "deleted items", "bin", "已删除", "已删除邮件"},
+	models.FolderRoleArchive: {"archive", "archives", "归档"},
+}
+
+// resolveSpecialFolders 根据LIST结果解析层级分隔符和特殊用途文件夹
+func resolveSpecialFolders(mailboxes []*imap.MailboxInfo) (string, map[string]string) {
+	delimiter := ""
+	folders := make(map[string]string)
+	
+	// 优先使用服务器声明的SPECIAL-USE属性
+	for _, mbox := range mailboxes {
+		if delimiter == "" && mbox.Delimiter != "" {
+			delimiter = mbox.Delimiter
+		}
+		for _, attr := range mbox.Attributes {
+			for specialAttr, role := range specialUseAttrs {
+				if strings.EqualFold(attr, specialAttr) {
+					if _, exists := folders[role]; !exists {
+						folders[role] = mbox.Name
+					}
+				}
+			}
+		}
+	}
+	
+	// 未声明的角色按最后一级文件夹名匹配
+	for role, names := range specialFolderNames {
+		if _, exists := folders[role]; exists {
+			continue
+		}
+		for _, mbox := range mailboxes {
+			leaf := mbox.Name
+			if mbox.Delimiter != "" {
+				parts := strings.Split(mbox.Name, mbox.Delimiter)
+				leaf = parts[len(parts)-1]
+			}
+			if containsFold(names, leaf) {
+				folders[role] = mbox.Name
+				break
+			}
+		}
+	}
+	
+	return delimiter, folders
+}
+
+// containsFold 忽略大小写检查字符串是否在列表中
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFolder 将文件夹角色（如 "sent"）或以 "/" 分隔的路径解析为服务器上的实际文件夹名
+func ResolveFolder(account *models.EmailAccount, folder string) string {
+	if name, ok := account.SpecialFolders[strings.ToLower(folder)]; ok {
+		return name
+	}
+	if account.FolderDelimiter != "" && account.FolderDelimiter != "/" {
+		return strings.ReplaceAll(folder, "/", account.FolderDelimiter)
+	}
+	return folder
+}
+
+// NormalizeAccountFolder 整理账户的主文件夹：去除空白和首尾的 "/"，为空时使用收件箱
+func NormalizeAccountFolder(folder string) string {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if folder == "" || strings.EqualFold(folder, models.DefaultFolder) {
+		return models.DefaultFolder
+	}
+	return folder
+}
+
+// NormalizeScanFolders 整理额外扫描的文件夹列表：去除空白和重复项，主文件夹始终会扫描因此忽略
+func NormalizeScanFolders(folders []string, mainFolder string) []string {
+	var result []string
+	for _, folder := range folders {
+		folder = strings.Trim(strings.TrimSpace(folder), "/")
+		if folder == "" || strings.EqualFold(folder, mainFolder) || containsFold(result, folder) {
+			continue
+		}
+		result = append(result, folder)
+	}
+	return result
+}
+
+// ensureFolderCache 账户尚未缓存文件夹结构时进行探测
+func (es *EmailService) ensureFolderCache(conn *IMAPConnection) {
+	if conn.Account.SpecialFolders != nil {
+		return
+	}
+	if err := es.refreshFolderCache(conn); err != nil {
+		// 探测失败不影响正常检查，下次连接时重试
+		es.logger.Warnf("账户%d探测文件夹结构失败: %v", conn.Account.ID, err)
+	}
+}
+
+// refreshFolderCache 重新探测并缓存账户的文件夹分隔符和特殊用途文件夹
+func (es *EmailService) refreshFolderCache(conn *IMAPConnection) error {
+	mailboxes, err := conn.listFolders()
+	if err != nil {
+		return err
+	}
+	
+	delimiter, folders := resolveSpecialFolders(mailboxes)
+	if err := es.db.UpdateAccountFolderCache(conn.Account.ID, delimiter, folders); err != nil {
+		return fmt.Errorf("保存文件夹缓存失败: %v", err)
+	}
+	
+	conn.Account.FolderDelimiter = delimiter
+	conn.Account.SpecialFolders = folders
+	es.logger.Infof("账户%d文件夹探测完成: 分隔符 %q, 特殊用途文件夹 %v", conn.Account.ID, delimiter, folders)
+	return nil
+}
+
+// markMessagesSeen 把已创建下载任务的邮件标记为已读，账户没有修改标志的权限时跳过，失败只记录日志
+func (es *EmailService) markMessagesSeen(conn *IMAPConnection, uids []uint32) {
+	if !conn.Account.CanModifyFlags || conn.Quirks.UseExamine {
+		es.logger.Debugf("账户%d不能修改邮件标志，跳过标记已读", conn.Account.ID)
+		return
+	}
+	if err := conn.markSeen(uids); err != nil {
+		es.logger.Warnf("账户%d标记%d封邮件为已读失败: %v", conn.Account.ID, len(uids), err)
+		return
+	}
+	es.logger.Infof("账户%d已将%d封邮件标记为已读", conn.Account.ID, len(uids))
+}
+
+// probePermissions 根据SELECT主文件夹返回的只读状态和PERMANENTFLAGS判断账户能否修改标志和移动邮件
+// 只读账户上标记已读、移动邮件等操作会静默失败，记录下来供界面禁用相关选项
+func (es *EmailService) probePermissions(conn *IMAPConnection) {
+	// 只能只读打开文件夹的服务器无法通过SELECT判断权限，保留原来的结果
+	if conn.Quirks.UseExamine {
+		es.logger.Debugf("账户%d的服务器只允许只读打开文件夹，跳过权限探测", conn.Account.ID)
+		return
+	}
+	
+	status, err := conn.selectFolder(conn.mainMailbox(), false)
+	if err != nil {
+		es.logger.Warnf("账户%d探测权限失败: %v", conn.Account.ID, err)
+		return
+	}
+	
+	canModifyFlags, canMoveMessages := mailboxPermissions(status)
+	if !canModifyFlags || !canMoveMessages {
+		es.logger.Warnf("账户%d权限受限（修改标志: %v，移动邮件: %v），标记已读和移动邮件功能不可用",
+			conn.Account.ID, canModifyFlags, canMoveMessages)
+	}
+	
+	if canModifyFlags == conn.Account.CanModifyFlags && canMoveMessages == conn.Account.CanMoveMessages {
+		return
+	}
+	if err := es.db.SetAccountPermissions(conn.Account.ID, canModifyFlags, canMoveMessages); err != nil {
+		es.logger.Warnf("账户%d保存权限探测结果失败: %v", conn.Account.ID, err)
+		return
+	}
+	conn.Account.CanModifyFlags = canModifyFlags
+	conn.Account.CanMoveMessages = canMoveMessages
+}
+
+// mailboxPermissions 根据邮箱状态判断能否修改标志和移动邮件
+// 服务器未返回PERMANENTFLAGS时按RFC 3501视为所有标志均可永久修改
+func mailboxPermissions(status *imap.MailboxStatus) (canModifyFlags, canMoveMessages bool) {
+	if status.ReadOnly {
+		return false, false
+	}
+	
+	permanent := func(flag string) bool {
+		if status.PermanentFlags == nil {
+			return true
+		}
+		for _, f := range status.PermanentFlags {
+			if f == imap.TryCreateFlag || strings.EqualFold(f, flag) {
+				return true
+			}
+		}
+		return false
+	}
+	
+	// 移动邮件需要在原文件夹中标记 \Deleted（MOVE 扩展在服务器内部也依赖该权限）
+	return permanent(imap.SeenFlag), permanent(imap.DeletedFlag)
+}
+
+// GetSpecialFolders 获取账户的特殊用途文件夹，refresh 为 true 时重新向服务器探测
+func (es *EmailService) GetSpecialFolders(accountID uint, refresh bool) (map[string]string, error) {
+	if !refresh {
+		account, err := es.getAccountByID(accountID)
+		if err != nil {
+			return nil, err
+		}
+		if account.SpecialFolders != nil {
+			return account.SpecialFolders, nil
+		}
+	}
+	
+	conn, err := es.getConnection(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("获取连接失败: %v", err)
+	}
+	defer es.releaseConnection(conn)
+	
+	if err := es.refreshFolderCache(conn); err != nil {
+		return nil, err
+	}
+	return conn.Account.SpecialFolders, nil
+}
+
+// IMAP连接方法
+func (conn *IMAPConnection) listFolders() ([]*imap.MailboxInfo, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+	
+	mailboxes := make(chan *imap.MailboxInfo, 20)
+	done := make(chan error, 1)
+	go func() {
+		done <- conn.Client.List("", "*", mailboxes)
+	}()
+	
+	var infos []*imap.MailboxInfo
+	for mbox := range mailboxes {
+		infos = append(infos, mbox)
+	}
+	
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("列出文件夹失败: %v", err)
+	}
+	return infos, nil
+}
+
+// selectInbox 选择账户配置的主文件夹（默认收件箱）
+func (conn *IMAPConnection) selectInbox() error {
+	mailbox := conn.mainMailbox()
+	
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	
+	if !conn.IsConnected {
+		return fmt.Errorf("连接已断开")
+	}
 	
-	es.connections[accountID] = conn
-	return conn, nil
-}
-
-// releaseConnection 释放连接（不实际关闭，只是标记为可用）
-func (es *EmailService) releaseConnection(accountID uint) {
-	// 连接复用，不在这里关闭连接
-	// 连接将由连接清理器定期清理
+	_, err := conn.Client.Select(mailbox, false)
+	return err
 }
 
-// getAccountByID 根据ID获取邮箱账户
-func (es *EmailService) getAccountByID(accountID uint) (*models.EmailAccount, error) {
-	return es.db.GetEmailAccountByID(accountID)
+// mainMailbox 账户主文件夹在服务器上的名称
+func (conn *IMAPConnection) mainMailbox() string {
+	folder := conn.Account.MainFolder()
+	if strings.EqualFold(folder, models.DefaultFolder) {
+		return models.DefaultFolder
+	}
+	return ResolveFolder(conn.Account, folder)
 }
 
-// createConnection 创建IMAP连接
-func (es *EmailService) createConnection(account *models.EmailAccount) (*IMAPConnection, error) {
-	return es.createConnectionWithTimeout(es.ctx, account)
+// mainTaskFolder 主文件夹中的邮件创建任务时记录的文件夹，收件箱记为空字符串
+// 记录实际的文件夹名，之后修改主文件夹时已有任务仍能找到邮件
+func (conn *IMAPConnection) mainTaskFolder() string {
+	mailbox := conn.mainMailbox()
+	if mailbox == models.DefaultFolder {
+		return ""
+	}
+	return mailbox
 }
 
-// createConnectionWithTimeout 创建带超时的IMAP连接
-func (es *EmailService) createConnectionWithTimeout(ctx context.Context, account *models.EmailAccount) (*IMAPConnection, error) {
-	// 连接到IMAP服务器
-	var c *client.Client
-	var err error
-	
-	serverAddr := fmt.Sprintf("%s:%d", account.IMAPServer, account.IMAPPort)
-	es.logger.Infof("正在连接到 %s (SSL: %v)", serverAddr, account.UseSSL)
+// selectFolder 选择指定文件夹，名称为空时选择收件箱；服务器要求时总是只读打开
+func (conn *IMAPConnection) selectFolder(name string, readOnly bool) (*imap.MailboxStatus, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
 	
-	if account.UseSSL {
-		// SSL连接 - 添加更灵活的TLS配置
-		tlsConfig := &tls.Config{
-			ServerName:         account.IMAPServer,
-			InsecureSkipVerify: false,
-		}
-		
-		c, err = client.DialTLS(serverAddr, tlsConfig)
-		if err != nil {
-			// 如果严格验证失败，尝试宽松模式
-			es.logger.Warnf("严格SSL验证失败，尝试跳过证书验证: %v", err)
-			tlsConfig.InsecureSkipVerify = true
-			c, err = client.DialTLS(serverAddr, tlsConfig)
-		}
-	} else {
-		// 普通连接
-		c, err = client.Dial(serverAddr)
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
 	}
-	
-	if err != nil {
-		return nil, fmt.Errorf("连接IMAP服务器失败 %s: %v", serverAddr, err)
+	if name == "" {
+		name = "INBOX"
 	}
 	
-	// 登录
-	es.logger.Infof("正在登录账户 %s", account.Email)
-	if err := c.Login(account.Email, account.Password); err != nil {
-		c.Close()
-		return nil, fmt.Errorf("IMAP登录失败 %s: %v", account.Email, err)
-	}
+	return conn.Client.Select(name, readOnly || conn.Quirks.UseExamine)
+}
+
+// markSeen 为主文件夹中的邮件添加 \Seen 标志
+func (conn *IMAPConnection) markSeen(uids []uint32) error {
+	mailbox := conn.mainMailbox()
 	
-	connCtx, cancel := context.WithCancel(ctx)
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
 	
-	conn := &IMAPConnection{
-		ID:          account.ID,
-		Account:     account,
-		Client:      c,
-		LastUsed:    time.Now(),
-		IsConnected: true,
-		ctx:         connCtx,
-		cancel:      cancel,
+	if !conn.IsConnected {
+		return fmt.Errorf("连接已断开")
+	}
+	// 处理邮件期间可能选择过其他文件夹，重新以读写方式打开主文件夹
+	if _, err := conn.Client.Select(mailbox, false); err != nil {
+		return fmt.Errorf("选择文件夹失败: %v", err)
 	}
 	
-	es.logger.Infof("成功创建连接 %s", account.Email)
-	return conn, nil
+	seqSet := new(imap.SeqSet)
+	seqSet.AddNum(uids...)
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	return conn.Client.UidStore(seqSet, item, []interface{}{imap.SeenFlag}, nil)
 }
 
-// IMAP连接方法
-func (conn *IMAPConnection) selectInbox() error {
+// searchUIDsAfter 搜索UID大于 lastUID 的邮件（包括已读），lastUID 为0时按 since 搜索
+func (conn *IMAPConnection) searchUIDsAfter(lastUID uint32, since time.Time) ([]uint32, error) {
 	conn.Mutex.Lock()
 	defer conn.Mutex.Unlock()
 	
 	if !conn.IsConnected {
-		return fmt.Errorf("连接已断开")
+		return nil, fmt.Errorf("连接已断开")
 	}
 	
-	_, err := conn.Client.Select("INBOX", false)
-	return err
+	criteria := imap.NewSearchCriteria()
+	if lastUID > 0 {
+		criteria.Uid = new(imap.SeqSet)
+		criteria.Uid.AddRange(lastUID+1, 0)
+	} else {
+		criteria.Since = since
+	}
+	
+	uids, err := conn.Client.UidSearch(criteria)
+	if err != nil {
+		return nil, fmt.Errorf("搜索邮件失败: %v", err)
+	}
+	
+	// "n:*" 在没有更大UID时仍会返回最后一封邮件，需要过滤
+	var result []uint32
+	for _, uid := range uids {
+		if uid > lastUID {
+			result = append(result, uid)
+		}
+	}
+	return result, nil
 }
 
-func (conn *IMAPConnection) searchUnreadMessages() ([]*imap.Message, error) {
+// searchUnreadMessages 搜索未读邮件，since 非零时只搜索该日期之后的邮件
+// window 为最近邮件搜索窗口的起始时间，前两种搜索策略均无结果时使用
+func (conn *IMAPConnection) searchUnreadMessages(since, window time.Time) ([]*imap.Message, error) {
 	conn.Mutex.Lock()
 	defer conn.Mutex.Unlock()
 	
@@ -497,7 +1378,7 @@ func (conn *IMAPConnection) searchUnreadMessages() ([]*imap.Message, error) {
 	}
 	
 	// 使用统一的搜索策略
-	uids, err := conn.searchWithFallback()
+	uids, err := conn.searchWithFallback(since, window)
 	if err != nil {
 		return nil, err
 	}
@@ -510,11 +1391,65 @@ func (conn *IMAPConnection) searchUnreadMessages() ([]*imap.Message, error) {
 	return conn.fetchAndFilterMessages(uids)
 }
 
+// ParseSearchCriteria 解析IMAP SEARCH语法的搜索条件（如 SINCE 1-Jan-2024 FROM billing@acme.com），
+// 条件为空时返回nil
+func ParseSearchCriteria(raw string) (*imap.SearchCriteria, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if strings.ContainsAny(raw, "\r\n") {
+		return nil, fmt.Errorf("搜索条件不能包含换行")
+	}
+	
+	reader := imap.NewReader(bufio.NewReader(strings.NewReader(raw + "\r\n")))
+	fields, err := reader.ReadLine()
+	if err != nil {
+		return nil, fmt.Errorf("搜索条件语法错误: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("搜索条件为空")
+	}
+	
+	criteria := imap.NewSearchCriteria()
+	if err := criteria.ParseWithCharset(fields, nil); err != nil {
+		return nil, fmt.Errorf("搜索条件语法错误: %v", err)
+	}
+	return criteria, nil
+}
+
+// searchWithCriteria 使用自定义搜索条件搜索邮件，匹配的邮件不再按未读状态过滤
+func (conn *IMAPConnection) searchWithCriteria(criteria *imap.SearchCriteria) ([]*imap.Message, error) {
+	var uids []uint32
+	err := conn.withLock(func() error {
+		if !conn.IsConnected {
+			return fmt.Errorf("连接已断开")
+		}
+		var err error
+		uids, err = conn.Client.UidSearch(criteria)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("自定义搜索失败: %v", err)
+	}
+	
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	
+	// 与默认搜索一样限制单次处理的邮件数量，优先处理最新的邮件
+	if len(uids) > maxMessagesPerSearch {
+		uids = uids[len(uids)-maxMessagesPerSearch:]
+	}
+	return conn.fetchMessagesByUID(uids)
+}
+
 // searchWithFallback 统一的搜索策略（重用逻辑）
-func (conn *IMAPConnection) searchWithFallback() ([]uint32, error) {
+func (conn *IMAPConnection) searchWithFallback(since, window time.Time) ([]uint32, error) {
 	// 策略1: 搜索未读邮件（标准方式）
 	criteria := imap.NewSearchCriteria()
 	criteria.WithoutFlags = []string{"\\Seen"}
+	criteria.Since = since
 	
 	uids, err := conn.Client.Search(criteria)
 	if err == nil && len(uids) > 0 {
@@ -524,6 +1459,7 @@ func (conn *IMAPConnection) searchWithFallback() ([]uint32, error) {
 	// 策略2: 使用UNSEEN标志
 	criteria = imap.NewSearchCriteria()
 	criteria.WithFlags = []string{"\\Recent"}
+	criteria.Since = since
 	uids, err = conn.Client.Search(criteria)
 	if err == nil && len(uids) > 0 {
 		return uids, nil
@@ -531,8 +1467,10 @@ func (conn *IMAPConnection) searchWithFallback() ([]uint32, error) {
 	
 	// 策略3: 搜索最近的邮件（最后的备选方案）
 	criteria = imap.NewSearchCriteria()
-	since := time.Now().AddDate(0, 0, -7) // 最近7天
-	criteria.Since = since
+	criteria.Since = window // 配置的最近邮件窗口
+	if since.After(criteria.Since) {
+		criteria.Since = since
+	}
 	uids, err = conn.Client.Search(criteria)
 	if err != nil {
 		return nil, fmt.Errorf("所有搜索策略均失败: %v", err)
@@ -541,12 +1479,92 @@ func (conn *IMAPConnection) searchWithFallback() ([]uint32, error) {
 	return uids, nil
 }
 
+// messageFetchItems 分析邮件时需要获取的内容
+// 使用PEEK获取正文，不改变已读状态；是否标记为已读只由 markSeen 按配置决定
+var messageFetchItems = []imap.FetchItem{
+	imap.FetchUid,          // 关键修复：确保获取UID
+	imap.FetchEnvelope, 
+	imap.FetchInternalDate,
+	imap.FetchBodyStructure,
+	imap.FetchFlags,
+	"BODY.PEEK[TEXT]", // 获取邮件正文内容
+	"BODY.PEEK[1]",    // 获取第一个body部分
+}
+
+// historyFetchItems 历史导入时获取的内容，使用PEEK避免将邮件标记为已读
+var historyFetchItems = []imap.FetchItem{
+	imap.FetchUid,
+	imap.FetchEnvelope,
+	imap.FetchInternalDate,
+	imap.FetchBodyStructure,
+	imap.FetchFlags,
+	"BODY.PEEK[TEXT]",
+	"BODY.PEEK[1]",
+}
+
+// searchAllSince 搜索指定日期之后的全部邮件UID（包括已读），since 为零值时搜索全部
+func (conn *IMAPConnection) searchAllSince(since time.Time) ([]uint32, error) {
+	return conn.searchDateRange(since, time.Time{})
+}
+
+// searchDateRange 搜索接收日期在 [since, before) 内的全部邮件UID（包括已读），零值表示不限制
+func (conn *IMAPConnection) searchDateRange(since, before time.Time) ([]uint32, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+	
+	criteria := imap.NewSearchCriteria()
+	criteria.Since = since
+	criteria.Before = before
+	return conn.Client.UidSearch(criteria)
+}
+
+// fetchMessagesByUID 按UID获取邮件详情（不过滤已读状态）
+func (conn *IMAPConnection) fetchMessagesByUID(uids []uint32) ([]*imap.Message, error) {
+	return conn.fetchMessagesWithItems(uids, messageFetchItems)
+}
+
+// fetchMessagesWithItems 按UID获取指定内容的邮件详情
+func (conn *IMAPConnection) fetchMessagesWithItems(uids []uint32, items []imap.FetchItem) ([]*imap.Message, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+	
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	
+	messages := make(chan *imap.Message, len(uids))
+	done := make(chan error, 1)
+	
+	go func() {
+		done <- conn.Client.UidFetch(seqset, conn.withGmailItems(items), messages)
+	}()
+	
+	var msgs []*imap.Message
+	for msg := range messages {
+		if msg.Uid != 0 {
+			msgs = append(msgs, msg)
+		}
+	}
+	
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("获取邮件详情失败: %v", err)
+	}
+	
+	return msgs, nil
+}
+
 // fetchAndFilterMessages 获取邮件详情并过滤（重用逻辑）
 func (conn *IMAPConnection) fetchAndFilterMessages(uids []uint32) ([]*imap.Message, error) {
 	// 限制批量获取的邮件数量，避免超时
-	maxMessages := 50
-	if len(uids) > maxMessages {
-		uids = uids[:maxMessages]
+	if len(uids) > maxMessagesPerSearch {
+		uids = uids[:maxMessagesPerSearch]
 	}
 	
 	// 获取邮件详情
@@ -557,14 +1575,7 @@ func (conn *IMAPConnection) fetchAndFilterMessages(uids []uint32) ([]*imap.Messa
 	done := make(chan error, 1)
 	
 	go func() {
-		done <- conn.Client.Fetch(seqset, []imap.FetchItem{
-			imap.FetchUid,          // 关键修复：确保获取UID
-			imap.FetchEnvelope, 
-			imap.FetchBodyStructure,
-			imap.FetchFlags,
-			"BODY[TEXT]", // 获取邮件正文内容
-			"BODY[1]",    // 获取第一个body部分
-		}, messages)
+		done <- conn.Client.Fetch(seqset, conn.withGmailItems(messageFetchItems), messages)
 	}()
 	
 	var msgs []*imap.Message
@@ -638,9 +1649,46 @@ func (conn *IMAPConnection) isAlive() bool {
 	}
 }
 
+// terminate 立即断开底层网络连接，不等待正在执行的命令，用于中止卡住的传输
+func (conn *IMAPConnection) terminate() {
+	defer func() {
+		if r := recover(); r != nil {
+			// 忽略断开时的panic
+		}
+	}()
+	
+	if conn.Client != nil {
+		conn.Client.Terminate()
+	}
+	conn.tunnel.close()
+}
+
+// withLock 持有连接锁执行IMAP操作，操作中发生panic时锁也会释放，之后断开连接不会卡在锁上
+func (conn *IMAPConnection) withLock(fn func() error) error {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	return fn()
+}
+
+// close 关闭连接，不等待连接锁：持锁的操作可能正卡在网络读写上，
+// 此时直接断开底层连接让其出错返回，连接状态在锁释放后再更新
 func (conn *IMAPConnection) close() {
 	conn.closeOnce.Do(func() {
-		conn.Mutex.Lock()
+		if conn.releaseSlot != nil {
+			conn.releaseSlot()
+		}
+		if !conn.Mutex.TryLock() {
+			conn.terminate()
+			if conn.cancel != nil {
+				conn.cancel()
+			}
+			go func() {
+				conn.Mutex.Lock()
+				conn.IsConnected = false
+				conn.Mutex.Unlock()
+			}()
+			return
+		}
 		defer conn.Mutex.Unlock()
 		
 		if conn.IsConnected && conn.Client != nil {
@@ -652,9 +1700,12 @@ func (conn *IMAPConnection) close() {
 					}
 				}()
 				conn.Client.Close()
+				conn.tunnel.close()
 			}()
-			conn.IsConnected = false
+		} else {
+			conn.tunnel.close()
 		}
+		conn.IsConnected = false
 		
 		if conn.cancel != nil {
 			conn.cancel()
@@ -662,15 +1713,20 @@ func (conn *IMAPConnection) close() {
 	})
 }
 
-// processMessage 处理邮件消息
-func (es *EmailService) processMessage(account *models.EmailAccount, msg *imap.Message) {
+// processMessage 处理邮件消息，返回创建的下载任务数
+// folder 为邮件所在文件夹，记录到下载任务中以便下载时定位邮件，空字符串表示收件箱
+func (es *EmailService) processMessage(account *models.EmailAccount, msg *imap.Message, folder string) int {
 	// 检查是否已处理过
 	messageID := ""
 	if msg.Envelope != nil && len(msg.Envelope.MessageId) > 0 {
 		messageID = msg.Envelope.MessageId
-		if es.isMessageProcessed(messageID) {
-			return
-		}
+	}
+	gmailMessageID, gmailThreadID := gmailMessageIDs(msg)
+	if es.isMessageProcessed(account, messageID, gmailMessageID) {
+		return 0
+	}
+	if messageID == "" && gmailMessageID != "" {
+		messageID = gmailMessageKey(gmailMessageID)
 	}
 	
 	now := time.Now()
@@ -686,6 +1742,9 @@ func (es *EmailService) processMessage(account *models.EmailAccount, msg *imap.M
 		IsProcessed: false,
 		CreatedAt:   models.TimeToString(now),
 		UpdatedAt:   models.TimeToString(now),
+
+		GmailMessageID: gmailMessageID,
+		GmailThreadID:  gmailThreadID,
 	}
 	
 	if msg.Envelope != nil {
@@ -705,18 +1764,45 @@ func (es *EmailService) processMessage(account *models.EmailAccount, msg *imap.M
 		}
 	}
 	
+	// 发件人黑白名单不允许的邮件只保存记录，不创建下载任务
+	if config, err := es.getDownloadConfig(); err == nil {
+		if allowed, reason := senderAllowed(config, emailMsg.Sender); !allowed {
+			emailMsg.HasPDF = len(es.analyzePDFSources(account, msg)) > 0
+			emailMsg.IsProcessed = true
+			if err := es.saveEmailMessage(emailMsg); err != nil {
+				return 0
+			}
+			es.logger.Infof("账户%d邮件 %q 的发件人 %s %s，不创建下载任务", account.ID, emailMsg.Subject, emailMsg.Sender, reason)
+			return 0
+		}
+	}
+	
+	// 分段邮件片段单独保存，收齐后再重组
+	if info, ok := parsePartialMessage(msg); ok {
+		if err := es.saveEmailMessage(emailMsg); err != nil {
+			return 0
+		}
+		created := es.processPartialFragment(account, emailMsg, msg, info)
+		emailMsg.IsProcessed = true
+		es.updateEmailMessage(emailMsg)
+		return created
+	}
+	
 	// 分析邮件内容，查找PDF附件和链接
 	pdfSources := es.analyzePDFSources(account, msg)
 	if len(pdfSources) > 0 {
 		emailMsg.HasPDF = true
 	}
+	pdfSources = es.applyRules(account, emailMsg, pdfSources)
 	
 	// 保存邮件记录
 	if err := es.saveEmailMessage(emailMsg); err != nil {
-		return
+		return 0
 	}
 	
 	// 创建下载任务
+	created := 0
+	receivedDate := models.TimeToString(messageReceivedDate(msg))
 	for _, source := range pdfSources {
 		now := time.Now()
 		task := &models.DownloadTask{
@@ -732,21 +1818,34 @@ func (es *EmailService) processMessage(account *models.EmailAccount, msg *imap.M
 			LocalPath:      source.LocalPath,
 			Progress:       0,
 			Speed:          "",
+			Folder:         folder,
+			EmailDate:      receivedDate,
+			MessageID:      emailMsg.ID,
 			CreatedAt:      models.TimeToString(now),
 			UpdatedAt:      models.TimeToString(now),
 		}
 		
+		// 单个文件创建任务失败不影响同一邮件中的其他文件，失败的来源计入邮件的提取结果
 		if err := es.createDownloadTask(task); err != nil {
+			es.logger.Warnf("账户%d邮件 %q 的文件 %s 创建下载任务失败，继续处理其他文件: %v",
+				account.ID, emailMsg.Subject, source.FileName, err)
 			continue
 		}
+		created++
 		
 		// 启动下载
-		es.downloadService.StartDownload(task.ID)
+		if err := es.downloadService.StartDownload(task.ID); err != nil {
+			es.logger.Warnf("任务 %d 启动下载失败: %v", task.ID, err)
+		}
 	}
 	
-	// 标记邮件为已处理
+	// 标记邮件为已处理，记录提取结果，之后随各任务结束更新
 	emailMsg.IsProcessed = true
 	es.updateEmailMessage(emailMsg)
+	if err := es.db.SetMessageExtraction(emailMsg.ID, len(pdfSources), created); err != nil {
+		es.logger.Warnf("账户%d保存邮件提取结果失败: %v", account.ID, err)
+	}
+	return created
 }
 
 // PDFSource PDF源信息
@@ -767,13 +1866,15 @@ func (es *EmailService) analyzePDFSources(account *models.EmailAccount, msg *ima
 	if err != nil {
 		return sources
 	}
+	downloadDir := es.resolveDownloadDir(config, account, msg)
 	
 	// 分析PDF附件
 	if msg.BodyStructure != nil {
-		attachments := es.findPDFAttachments(msg.BodyStructure)
+		attachments := es.findPDFAttachments(msg.BodyStructure, newAttachmentFilter(config, account))
+		extensions := savedExtensions(config, account)
 		for _, att := range attachments {
-			fileName := utils.CleanFilename(att.FileName)
-			localPath := filepath.Join(config.DownloadPath, fileName)
+			fileName := utils.CleanFilenameFor(att.FileName, extensions)
+			localPath := filepath.Join(downloadDir, fileName)
 			
 			sources = append(sources, PDFSource{
 				Type:      models.TypeAttachment,
@@ -794,7 +1895,7 @@ func (es *EmailService) analyzePDFSources(account *models.EmailAccount, msg *ima
 			fileName = fmt.Sprintf("download_%d.pdf", time.Now().Unix())
 		}
 		fileName = utils.CleanFilename(fileName)
-		localPath := filepath.Join(config.DownloadPath, fileName)
+		localPath := filepath.Join(downloadDir, fileName)
 		
 		sources = append(sources, PDFSource{
 			Type:      models.TypeLink,
@@ -805,7 +1906,102 @@ func (es *EmailService) analyzePDFSources(account *models.EmailAccount, msg *ima
 		})
 	}
 	
-	return sources
+	return es.dropDuplicateSources(sources, config.DuplicateSourcePreference)
+}
+
+// dropDuplicateSources 同名PDF同时以附件和链接出现时只保留偏好的来源（默认附件）
+// 文件名不同但内容相同的情况在下载完成后按哈希处理
+func (es *EmailService) dropDuplicateSources(sources []PDFSource, preference string) []PDFSource {
+	preferred := models.TypeAttachment
+	if preference == models.PreferLinkSource {
+		preferred = models.TypeLink
+	}
+	
+	hasPreferred := make(map[string]bool)
+	for _, source := range sources {
+		if source.Type == preferred {
+			hasPreferred[strings.ToLower(source.FileName)] = true
+		}
+	}
+	
+	var result []PDFSource
+	for _, source := range sources {
+		if source.Type != preferred && hasPreferred[strings.ToLower(source.FileName)] {
+			es.logger.Infof("PDF %s 同时以附件和链接出现，跳过%s来源", source.FileName, source.Type)
+			continue
+		}
+		result = append(result, source)
+	}
+	return result
+}
+
+// unknownSender 邮件没有发件人时使用的目录名
+const unknownSender = "unknown-sender"
+
+// unknownAccount 没有账户信息（如导入的邮件文件）时使用的目录名
+const unknownAccount = "imported"
+
+// accountSenderTemplate 按账户和发件人整理时使用的路径模板
+const accountSenderTemplate = "{account}/{sender_domain}"
+
+// effectivePathTemplate 返回实际使用的路径模板：按账户和发件人整理时使用固定模板，否则使用配置的模板
+func effectivePathTemplate(config *models.AppConfig) string {
+	if config.OrganizeBy == models.OrganizeByAccountSender {
+		return accountSenderTemplate
+	}
+	return config.PathTemplate
+}
+
+// resolveDownloadDir 根据账户下载目录和路径模板计算邮件附件的保存目录
+// 账户设置了专用下载目录时替代全局下载目录，路径模板对两者同样生效
+func (es *EmailService) resolveDownloadDir(config *models.AppConfig, account *models.EmailAccount, msg *imap.Message) string {
+	root := config.DownloadPath
+	if account != nil && strings.TrimSpace(account.DownloadPath) != "" {
+		root = account.DownloadPath
+	}
+	template := effectivePathTemplate(config)
+	if template == "" {
+		return root
+	}
+	
+	return filepath.Join(root, utils.ExpandPathTemplate(template, pathTemplateValues(account, msg)))
+}
+
+// accountFolderName 账户在路径模板中的目录名：优先使用账户名称，没有名称时使用邮箱地址
+func accountFolderName(account *models.EmailAccount) string {
+	if account == nil {
+		return unknownAccount
+	}
+	if name := strings.TrimSpace(account.Name); name != "" {
+		return name
+	}
+	if email := strings.TrimSpace(account.Email); email != "" {
+		return strings.ToLower(email)
+	}
+	return unknownAccount
+}
+
+// pathTemplateValues 从账户和邮件中提取路径模板变量，多个发件人时使用第一个
+func pathTemplateValues(account *models.EmailAccount, msg *imap.Message) map[string]string {
+	sender, senderDomain := unknownSender, unknownSender
+	if msg.Envelope != nil && len(msg.Envelope.From) > 0 {
+		from := msg.Envelope.From[0]
+		if from.MailboxName != "" && from.HostName != "" {
+			sender = strings.ToLower(from.Address())
+			senderDomain = strings.ToLower(from.HostName)
+		}
+	}
+	
+	return senderTemplateValues(accountFolderName(account), sender, senderDomain)
+}
+
+// senderTemplateValues 路径模板中与账户和发件人相关的变量
+func senderTemplateValues(account, sender, senderDomain string) map[string]string {
+	return map[string]string{
+		"account":       account,
+		"sender":        sender,
+		"sender_domain": senderDomain,
+	}
 }
 
 // extractPDFLinksFromMessage 从邮件消息中提取PDF链接（完整解析）
@@ -832,7 +2028,8 @@ func (es *EmailService) extractPDFLinksFromMessage(msg *imap.Message) []string {
 		}
 	}
 	
-	return uniqueLinks
+	// 最可能是PDF文件的链接排在前面，先创建任务
+	return utils.RankLinks(uniqueLinks)
 }
 
 // extractPDFLinksFromBody 从邮件正文中提取PDF链接
@@ -849,18 +2046,18 @@ func (es *EmailService) extractPDFLinksFromBody(msg *imap.Message) []string {
 	// 遍历所有Body部分
 	for i, body := range msg.Body {
 		if body == nil {
-			es.logger.Debugf("Body部分 %d 为空", i)
+			es.logger.Debugf("Body部分 %v 为空", i)
 			continue
 		}
 		
 		// 读取正文内容
 		content, err := io.ReadAll(body)
 		if err != nil {
-			es.logger.Debugf("读取Body部分 %d 失败: %v", i, err)
+			es.logger.Debugf("读取Body部分 %v 失败: %v", i, err)
 			continue
 		}
 		
-		es.logger.Debugf("Body部分 %d 内容长度: %d 字节", i, len(content))
+		es.logger.Debugf("Body部分 %v 内容长度: %d 字节", i, len(content))
 		
 		// 尝试不同的编码解析
 		textContent := es.decodeBodyContent(content)
@@ -871,20 +2068,20 @@ func (es *EmailService) extractPDFLinksFromBody(msg *imap.Message) []string {
 			if len(preview) > 500 {
 				preview = preview[:500] + "..."
 			}
-			es.logger.Debugf("Body部分 %d 解码后内容预览: %s", i, preview)
+			es.logger.Debugf("Body部分 %v 解码后内容预览: %s", i, preview)
 		}
 		
 		// 从文本内容中提取PDF链接
 		bodyLinks := es.extractPDFLinks(textContent)
 		if len(bodyLinks) > 0 {
-			es.logger.Infof("从Body部分 %d 提取到PDF链接: %v", i, bodyLinks)
+			es.logger.Infof("从Body部分 %v 提取到PDF链接: %v", i, bodyLinks)
 		}
 		links = append(links, bodyLinks...)
 		
 		// 特殊处理：查找QQ邮箱等服务商的下载链接
 		specialLinks := es.extractSpecialDownloadLinks(textContent)
 		if len(specialLinks) > 0 {
-			es.logger.Infof("从Body部分 %d 提取到特殊下载链接: %v", i, specialLinks)
+			es.logger.Infof("从Body部分 %v 提取到特殊下载链接: %v", i, specialLinks)
 		}
 		links = append(links, specialLinks...)
 	}
@@ -999,12 +2196,12 @@ type AttachmentInfo struct {
 	Size     int64
 }
 
-// findPDFAttachments 查找PDF附件（使用统一的逻辑）
-func (es *EmailService) findPDFAttachments(bodyStructure *imap.BodyStructure) []AttachmentInfo {
+// findPDFAttachments 查找需要下载的附件（使用统一的逻辑），默认只查找PDF，按配置的附件类型过滤
+func (es *EmailService) findPDFAttachments(bodyStructure *imap.BodyStructure, filter attachmentFilter) []AttachmentInfo {
 	var attachments []AttachmentInfo
 	
 	// 使用统一的PDF搜索逻辑
-	es.searchPDFPartsRecursively(bodyStructure, func(fileName string, size int64) {
+	es.searchPDFPartsRecursively(bodyStructure, filter, func(fileName string, size int64) {
 		if fileName != "" {
 			attachments = append(attachments, AttachmentInfo{
 				FileName: fileName,
@@ -1017,41 +2214,41 @@ func (es *EmailService) findPDFAttachments(bodyStructure *imap.BodyStructure) []
 }
 
 // searchPDFPartsRecursively 递归搜索PDF部分（统一逻辑，避免重复代码）
-func (es *EmailService) searchPDFPartsRecursively(bs *imap.BodyStructure, callback func(string, int64), depth int) {
+func (es *EmailService) searchPDFPartsRecursively(bs *imap.BodyStructure, filter attachmentFilter, callback func(string, int64), depth int) {
 	// 防止无限递归
 	if depth > 10 || bs == nil {
 		return
 	}
 	
-	// 检查当前部分是否为PDF附件（与下载服务保持一致的逻辑）
-	mimeType := strings.ToLower(bs.MIMEType)
-	mimeSubType := strings.ToLower(bs.MIMESubType)
-	
-	isPDF := (mimeType == "application" && mimeSubType == "pdf") ||
-			 (mimeType == "application" && mimeSubType == "octet-stream") ||
-			 (mimeType == "application" && mimeSubType == "binary")
-	
-	// 如果MIME类型不明确，检查文件名
-	if !isPDF {
-		fileName := es.extractFileNameFromBodyStructure(bs)
-		if fileName != "" && strings.HasSuffix(strings.ToLower(fileName), ".pdf") {
-			isPDF = true
-		}
-	}
-	
-	if isPDF {
-		fileName := es.extractFileNameFromBodyStructure(bs)
-		es.logger.Infof("邮件服务发现PDF附件 - 文件名: '%s', MIME: %s/%s, 大小: %d", 
-			fileName, bs.MIMEType, bs.MIMESubType, bs.Size)
-		callback(fileName, int64(bs.Size))
-	}
+	// 按配置的附件类型检查当前部分，单个部分异常时跳过，不影响其他部分
+	es.matchPDFPart(bs, filter, callback)
 	
 	// 递归搜索子部分
 	for i, part := range bs.Parts {
 		if i > 20 { // 限制搜索数量
 			break
 		}
-		es.searchPDFPartsRecursively(part, callback, depth+1)
+		es.searchPDFPartsRecursively(part, filter, callback, depth+1)
+	}
+}
+
+// matchPDFPart 检查单个部分是否需要下载（未配置时只匹配PDF，与下载服务保持一致的逻辑）
+// 部分的结构异常（如参数解码出错）导致panic时只跳过该部分，同一邮件中的其他附件照常创建任务
+func (es *EmailService) matchPDFPart(bs *imap.BodyStructure, filter attachmentFilter, callback func(string, int64)) {
+	defer func() {
+		if r := recover(); r != nil {
+			es.logger.Warnf("邮件部分 %s/%s 解析异常，已跳过: %v", bs.MIMEType, bs.MIMESubType, r)
+		}
+	}()
+	
+	mimeType := strings.ToLower(bs.MIMEType)
+	mimeSubType := strings.ToLower(bs.MIMESubType)
+	fileName := es.extractFileNameFromBodyStructure(bs)
+	
+	if filter.matches(mimeType, mimeSubType, fileName) {
+		es.logger.Infof("邮件服务发现附件 - 文件名: '%s', MIME: %s/%s, 大小: %d", 
+			fileName, bs.MIMEType, bs.MIMESubType, bs.Size)
+		callback(fileName, int64(bs.Size))
 	}
 }
 
@@ -1103,12 +2300,6 @@ func (es *EmailService) extractPDFLinks(text string) []string {
 	return validLinks
 }
 
-// isMessageProcessed 检查消息是否已处理
-func (es *EmailService) isMessageProcessed(messageID string) bool {
-	_, err := es.db.GetEmailMessageByMessageID(messageID)
-	return err == nil
-}
-
 // saveEmailMessage 保存邮件消息
 func (es *EmailService) saveEmailMessage(msg *models.EmailMessage) error {
 	return es.db.CreateEmailMessage(msg)
@@ -1125,18 +2316,7 @@ func (es *EmailService) createDownloadTask(task *models.DownloadTask) error {
 }
 
 func (es *EmailService) getDownloadConfig() (*models.AppConfig, error) {
-	query := `SELECT id, download_path, max_concurrent, check_interval, auto_check, minimize_to_tray, start_minimized, enable_notification, theme, language, created_at, updated_at FROM app_configs LIMIT 1`
-	
-	row := es.db.DB.QueryRow(query)
-	
-	var config models.AppConfig
-	err := row.Scan(
-		&config.ID, &config.DownloadPath, &config.MaxConcurrent, &config.CheckInterval,
-		&config.AutoCheck, &config.MinimizeToTray, &config.StartMinimized,
-		&config.EnableNotification, &config.Theme, &config.Language,
-		&config.CreatedAt, &config.UpdatedAt,
-	)
-	
+	config, err := es.db.GetConfig()
 	if err != nil {
 		// 返回默认配置
 		homeDir, _ := os.UserHomeDir()
@@ -1149,8 +2329,6 @@ func (es *EmailService) getDownloadConfig() (*models.AppConfig, error) {
 	return &config, nil
 }
 
-
-
 // CheckAccountNow 立即检查指定账户
 func (es *EmailService) CheckAccountNow(accountID uint) error {
 	account, err := es.getAccountByID(accountID)
@@ -1158,7 +2336,7 @@ func (es *EmailService) CheckAccountNow(accountID uint) error {
 		return err
 	}
 	
-	go es.checkAccount(account)
+	go es.checkAccountWithDeadline(account, es.accountCheckTimeout())
 	return nil
 }
 
@@ -1212,27 +2390,66 @@ func (es *EmailService) TestConnection(account *models.EmailAccount) error {
 	conn, err := es.createConnectionWithTimeout(ctx, account)
 	if err != nil {
 		es.logger.Errorf("创建连接失败 %s: %v", account.Email, err)
+		// OAuth2 授权失效需要重新授权，与密码错误区分开
+		if errors.Is(err, ErrOAuthTokenExpired) {
+			return fmt.Errorf("授权已失效: %w", err)
+		}
+		if errors.Is(err, ErrOAuthTokenRejected) {
+			return fmt.Errorf("访问令牌无效，请检查授权的权限范围（需要IMAP访问权限）: %w", err)
+		}
 		return fmt.Errorf("连接失败: %v", err)
 	}
 	defer conn.close()
 	
-	// 尝试选择收件箱来验证连接
+	// 配置了其他主文件夹时先确认文件夹存在，拼写错误时给出相近的名称
+	if !strings.EqualFold(account.MainFolder(), models.DefaultFolder) {
+		if err := es.checkMainFolder(conn); err != nil {
+			es.logger.Errorf("检查主文件夹失败 %s: %v", account.Email, err)
+			return err
+		}
+	}
+	
+	// 尝试选择主文件夹来验证连接
+	mailbox := conn.mainMailbox()
 	if err := conn.selectInbox(); err != nil {
-		es.logger.Errorf("选择收件箱失败 %s: %v", account.Email, err)
-		return fmt.Errorf("无法访问收件箱: %v", err)
+		es.logger.Errorf("选择文件夹 %s 失败 %s: %v", mailbox, account.Email, err)
+		return fmt.Errorf("无法访问文件夹 %s: %v", mailbox, err)
 	}
 	
 	// 尝试获取邮箱状态确认连接正常
-	if status, err := conn.Client.Status("INBOX", []imap.StatusItem{imap.StatusMessages}); err != nil {
+	if status, err := conn.Client.Status(mailbox, []imap.StatusItem{imap.StatusMessages}); err != nil {
 		es.logger.Errorf("获取邮箱状态失败 %s: %v", account.Email, err)
 		return fmt.Errorf("无法获取邮箱状态: %v", err)
 	} else {
-		es.logger.Infof("连接测试成功 %s: 邮箱中有%d封邮件", account.Email, status.Messages)
+		es.logger.Infof("连接测试成功 %s: 认证方式 %s，邮箱中有%d封邮件", account.Email, conn.AuthMechanism, status.Messages)
+		if conn.tunnel != nil {
+			es.logger.Infof("账户 %s 的SSH隧道可用，跳板机主机密钥: %s", account.Email, account.SSHHostKey)
+		}
 	}
 	
 	return nil
 }
 
+// TestConnectionAndUpdate 测试已保存账户的连接并记录结果，测试时间总会更新
+// 成功时清除错误信息，失败时保存错误信息及其分类；尚未保存的账户使用 TestConnection，不写入数据库
+func (es *EmailService) TestConnectionAndUpdate(accountID uint) error {
+	account, err := es.db.GetEmailAccountByID(accountID)
+	if err != nil {
+		return fmt.Errorf("获取账户信息失败: %v", err)
+	}
+	
+	testErr := es.TestConnection(account)
+	lastError, category := "", ""
+	if testErr != nil {
+		lastError = testErr.Error()
+		category = classifyFailure(lastError)
+	}
+	if err := es.db.SetAccountTestResult(accountID, lastError, category); err != nil {
+		es.logger.Warnf("账户%d保存连接测试结果失败: %v", accountID, err)
+	}
+	return testErr
+}
+
 // Start 启动邮件服务
 func (es *EmailService) Start() error {
 	return es.StartEmailMonitoring()
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// maxEMLFileSize 导入的 .eml 文件大小上限
+const maxEMLFileSize = 100 * 1024 * 1024
+
+// mimePDFPart 从MIME内容中找到的PDF附件
+type mimePDFPart struct {
+	fileName string
+	data     []byte
+}
+
+// ImportEMLFile 解析本地 .eml 文件，将其中的PDF附件保存到下载目录，不需要邮箱账户
+// 导入结果不关联任何账户，因此不写入下载任务表，只返回已保存文件的任务信息
+func (ds *DownloadService) ImportEMLFile(path string) ([]models.DownloadTask, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取EML文件失败: %v", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s 是目录，不是EML文件", path)
+	}
+	if info.Size() > maxEMLFileSize {
+		return nil, fmt.Errorf("EML文件过大（%s），上限为 %s", utils.FormatBytes(info.Size()), utils.FormatBytes(maxEMLFileSize))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取EML文件失败: %v", err)
+	}
+	defer file.Close()
+
+	message, err := mail.ReadMessage(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("解析EML文件失败: %v", err)
+	}
+
+	parts, err := ds.collectPDFParts(textproto.MIMEHeader(message.Header), message.Body, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("EML文件中未找到PDF附件")
+	}
+
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("获取配置失败: %v", err)
+	}
+
+	subject := utils.DecodeMimeHeader(message.Header.Get("Subject"))
+	sender, senderDomain := unknownSender, unknownSender
+	if from, err := message.Header.AddressList("From"); err == nil && len(from) > 0 {
+		if at := strings.LastIndex(from[0].Address, "@"); at > 0 {
+			sender = strings.ToLower(from[0].Address)
+			senderDomain = sender[at+1:]
+		}
+	}
+
+	downloadDir := config.DownloadPath
+	if template := effectivePathTemplate(&config); template != "" {
+		downloadDir = filepath.Join(downloadDir, utils.ExpandPathTemplate(template, senderTemplateValues(accountFolderName(nil), sender, senderDomain)))
+	}
+
+	var tasks []models.DownloadTask
+	for i, part := range parts {
+		fileName := utils.CleanFilename(part.fileName)
+		if fileName == "" {
+			fileName = fmt.Sprintf("attachment_%d.pdf", i+1)
+		}
+
+		if !utils.IsPDFContent(part.data) {
+			ds.logger.Warnf("EML文件中的附件 %s 不是有效的PDF文件，已跳过", fileName)
+			continue
+		}
+
+		// 同名文件已存在时自动添加序号
+		localPath, err := utils.SaveFile(part.data, fileName, downloadDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.ValidatePDFFile(localPath); err != nil {
+			os.Remove(localPath)
+			ds.logger.Warnf("EML文件中的附件 %s 验证失败，已跳过: %v", fileName, err)
+			continue
+		}
+
+		now := models.TimeToString(time.Now())
+		task := models.DownloadTask{
+			Subject:        subject,
+			Sender:         sender,
+			FileName:       filepath.Base(localPath),
+			FileSize:       int64(len(part.data)),
+			DownloadedSize: int64(len(part.data)),
+			Status:         models.StatusCompleted,
+			Type:           models.TypeAttachment,
+			Source:         part.fileName,
+			LocalPath:      localPath,
+			Progress:       100,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if config.ChecksumAlgorithm != "" {
+			if checksum, err := utils.FileChecksum(localPath, config.ChecksumAlgorithm); err == nil {
+				task.Checksum, task.ChecksumAlgorithm = checksum, config.ChecksumAlgorithm
+			}
+		}
+		tasks = append(tasks, task)
+	}
+
+	if len(tasks) == 0 {
+		return nil, fmt.Errorf("EML文件中的PDF附件均无效")
+	}
+
+	ds.logger.Infof("从EML文件 %s 导入 %d 个PDF附件", path, len(tasks))
+	return tasks, nil
+}
+
+// collectPDFParts 递归查找MIME内容中的全部PDF附件，包括作为附件转发的邮件中的PDF
+func (ds *DownloadService) collectPDFParts(header textproto.MIMEHeader, body io.Reader, depth int) ([]mimePDFPart, error) {
+	if depth >= maxMIMEDepth {
+		return nil, fmt.Errorf("MIME嵌套层数过多")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		var parts []mimePDFPart
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("解析MIME部分失败: %v", err)
+			}
+
+			found, err := ds.collectPDFParts(part.Header, part, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, found...)
+		}
+		return parts, nil
+
+	case mediaType == "message/rfc822":
+		inner, err := mail.ReadMessage(body)
+		if err != nil {
+			ds.logger.Debugf("解析内嵌邮件失败，已跳过: %v", err)
+			return nil, nil
+		}
+		return ds.collectPDFParts(textproto.MIMEHeader(inner.Header), inner.Body, depth+1)
+	}
+
+	fileName, ok := pdfPartName(header, mediaType, params)
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := ds.readMIMEPart(header, body)
+	if err != nil {
+		ds.logger.Warnf("解码PDF附件 %s 失败，已跳过: %v", fileName, err)
+		return nil, nil
+	}
+	return []mimePDFPart{{fileName: fileName, data: data}}, nil
+}
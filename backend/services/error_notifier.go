@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// DefaultErrorNotifyCooldown 同一账户同类错误的通知冷却时间，期间即使账户短暂恢复后再次失败也不重复通知
+	DefaultErrorNotifyCooldown = 30 * time.Minute
+	// DefaultErrorReminderInterval 账户持续失败时发送“仍然失败”提醒的间隔
+	DefaultErrorReminderInterval = 6 * time.Hour
+)
+
+// errorNotifyKey 通知去重的键：账户和错误类别
+type errorNotifyKey struct {
+	accountID uint
+	category  string
+}
+
+// errorNotifyState 一类错误的通知状态
+type errorNotifyState struct {
+	failing      bool      // 是否仍在失败
+	failingSince time.Time // 本次连续失败的开始时间
+	lastSent     time.Time // 最近一次发送通知的时间
+	suppressed   int       // 最近一次通知之后被抑制的失败次数
+}
+
+// ErrorNotifier 按（账户，错误类别）对错误通知去重和限流
+// 首次失败立即通知；持续失败期间不再重复通知，只按提醒间隔发送一次“仍然失败”提醒
+type ErrorNotifier struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	reminder time.Duration
+	states   map[errorNotifyKey]*errorNotifyState
+	now      func() time.Time
+}
+
+// NewErrorNotifier 创建错误通知限流器，参数不大于0时使用默认值
+func NewErrorNotifier(cooldown, reminder time.Duration) *ErrorNotifier {
+	if cooldown <= 0 {
+		cooldown = DefaultErrorNotifyCooldown
+	}
+	if reminder <= 0 {
+		reminder = DefaultErrorReminderInterval
+	}
+	return &ErrorNotifier{
+		cooldown: cooldown,
+		reminder: reminder,
+		states:   make(map[errorNotifyKey]*errorNotifyState),
+		now:      time.Now,
+	}
+}
+
+// Failed 记录账户的一次失败，需要通知时返回通知标题和内容，否则返回false
+func (n *ErrorNotifier) Failed(account *models.EmailAccount, errMsg string) (string, string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	now := n.now()
+	n.prune(now)
+
+	key := errorNotifyKey{accountID: account.ID, category: classifyFailure(errMsg)}
+	state, exists := n.states[key]
+	if !exists {
+		n.states[key] = &errorNotifyState{failing: true, failingSince: now, lastSent: now}
+		return "邮箱检查失败", fmt.Sprintf("%s: %s", account.Email, errMsg), true
+	}
+
+	if !state.failing {
+		// 账户恢复后再次失败，冷却时间内视为同一问题的反复
+		state.failing = true
+		state.failingSince = now
+		if now.Sub(state.lastSent) < n.cooldown {
+			state.suppressed++
+			return "", "", false
+		}
+		state.lastSent = now
+		state.suppressed = 0
+		return "邮箱检查失败", fmt.Sprintf("%s: %s", account.Email, errMsg), true
+	}
+
+	if now.Sub(state.lastSent) < n.reminder {
+		state.suppressed++
+		return "", "", false
+	}
+
+	message := fmt.Sprintf("%s 仍然检查失败（已持续 %s，期间 %d 次失败未提醒）: %s",
+		account.Email, now.Sub(state.failingSince).Round(time.Minute), state.suppressed, errMsg)
+	state.lastSent = now
+	state.suppressed = 0
+	return "邮箱仍然检查失败", message, true
+}
+
+// Succeeded 账户检查成功，结束该账户所有类别的连续失败；冷却记录保留到冷却时间结束
+func (n *ErrorNotifier) Succeeded(accountID uint) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for key, state := range n.states {
+		if key.accountID == accountID {
+			state.failing = false
+			state.suppressed = 0
+		}
+	}
+}
+
+// prune 清除已恢复且冷却时间已过的记录，调用方需持有锁
+func (n *ErrorNotifier) prune(now time.Time) {
+	for key, state := range n.states {
+		if !state.failing && now.Sub(state.lastSent) >= n.cooldown {
+			delete(n.states, key)
+		}
+	}
+}
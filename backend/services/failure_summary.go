@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"emaild/backend/models"
+)
+
+// failureKeywords 错误信息关键字到失败分类的映射（按顺序匹配，转为小写后比较）
+var failureKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{models.FailureAuth, []string{"auth", "login", "password", "认证", "登录", "密码", "授权", "服务器响应错误: 401", "服务器响应错误: 403", "http状态错误: 401", "http状态错误: 403"}},
+	{models.FailureNotFound, []string{"未找到", "not found", "不存在", "服务器响应错误: 404", "服务器响应错误: 410", "http状态错误: 404", "http状态错误: 410"}},
+	{models.FailureSize, []string{"过大", "超过限制", "超过大小", "空间不足", "too large", "no space"}},
+	{models.FailureInvalidPDF, []string{"有效的pdf", "pdf文件验证失败", "eof标记", "文件头", "html"}},
+	{models.FailureNetwork, []string{"超时", "timeout", "deadline", "tls", "x509", "证书", "connection", "network", "no such host", "eof",
+		"连接", "网络", "请求失败", "重定向", "服务器响应错误", "http状态错误"}},
+}
+
+// failureCategoryOrder 数量相同时各分类的显示顺序
+var failureCategoryOrder = map[string]int{
+	models.FailureAuth:       0,
+	models.FailureNetwork:    1,
+	models.FailureNotFound:   2,
+	models.FailureInvalidPDF: 3,
+	models.FailureSize:       4,
+	models.FailureOther:      5,
+}
+
+// classifyFailure 根据任务保存的错误信息判断失败分类
+func classifyFailure(errMsg string) string {
+	lower := strings.ToLower(errMsg)
+	for _, entry := range failureKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(lower, keyword) {
+				return entry.category
+			}
+		}
+	}
+	return models.FailureOther
+}
+
+// GetFailedTasksSummary 按错误分类汇总失败的任务，按数量从多到少排列
+// 每个分类附带任务ID和最近的一个失败任务作为示例
+func (ds *DownloadService) GetFailedTasksSummary() ([]models.FailureCategorySummary, error) {
+	tasks, err := ds.db.GetDownloadTasksByStatus(models.StatusFailed)
+	if err != nil {
+		return nil, fmt.Errorf("获取失败任务失败: %v", err)
+	}
+
+	// 任务按创建时间倒序，每个分类遇到的第一个任务即最近的失败
+	byCategory := make(map[string]*models.FailureCategorySummary)
+	for _, task := range tasks {
+		category := classifyFailure(task.Error)
+		summary, ok := byCategory[category]
+		if !ok {
+			summary = &models.FailureCategorySummary{Category: category, Sample: task}
+			byCategory[category] = summary
+		}
+		summary.Count++
+		summary.TaskIDs = append(summary.TaskIDs, task.ID)
+	}
+
+	result := make([]models.FailureCategorySummary, 0, len(byCategory))
+	for _, summary := range byCategory {
+		result = append(result, *summary)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return failureCategoryOrder[result[i].Category] < failureCategoryOrder[result[j].Category]
+	})
+	return result, nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/sirupsen/logrus"
+
+	"emaild/backend/database"
+	"emaild/backend/models"
+)
+
+// fakeIMAPCommand 测试服务器收到的一条命令
+type fakeIMAPCommand struct {
+	Tag  string
+	Name string // 命令名，UID命令包含 "UID " 前缀，如 "UID FETCH"
+	Args string
+}
+
+// fakeIMAPReply 向客户端写入响应
+type fakeIMAPReply struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// line 写入一行响应，自动追加CRLF
+func (r *fakeIMAPReply) line(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(r.w, format+"\r\n", args...)
+}
+
+// raw 原样写入数据，用于逐段发送字面量
+func (r *fakeIMAPReply) raw(data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.w.Write(data)
+	return err
+}
+
+// ok 写入命令完成响应
+func (r *fakeIMAPReply) ok(cmd fakeIMAPCommand) {
+	r.line("%s OK %s completed", cmd.Tag, cmd.Name)
+}
+
+// fakeIMAPHandler 处理一条命令，返回false时使用默认处理
+type fakeIMAPHandler func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool
+
+// newFakeIMAPConnection 建立连接到测试服务器的已登录IMAP连接并选中INBOX，服务器按 handler 响应命令
+// 服务器不理解字面量参数，测试只能使用ASCII的命令参数
+func newFakeIMAPConnection(t *testing.T, capabilities string, handler fakeIMAPHandler) *IMAPConnection {
+	t.Helper()
+
+	c := newFakeIMAPClient(t, "PREAUTH", capabilities, handler)
+	if _, err := c.Select("INBOX", false); err != nil {
+		t.Fatalf("选择测试文件夹失败: %v", err)
+	}
+
+	return &IMAPConnection{
+		ID:          1,
+		Account:     &models.EmailAccount{ID: 1, Email: "user@example.com", IMAPServer: "imap.example.com", IMAPPort: 993},
+		Client:      c,
+		IsConnected: true,
+	}
+}
+
+// newFakeIMAPClient 建立连接到测试服务器的IMAP客户端，greeting 为 OK（未登录）或 PREAUTH（已登录）
+func newFakeIMAPClient(t *testing.T, greeting, capabilities string, handler fakeIMAPHandler) *client.Client {
+	t.Helper()
+
+	serverConn, clientConn := net.Pipe()
+	go serveFakeIMAP(serverConn, greeting, capabilities, handler)
+
+	c, err := client.New(clientConn)
+	if err != nil {
+		t.Fatalf("连接测试IMAP服务器失败: %v", err)
+	}
+	c.ErrorLog = log.New(io.Discard, "", 0)
+	t.Cleanup(func() {
+		c.Terminate()
+		serverConn.Close()
+	})
+	return c
+}
+
+// serveFakeIMAP 在连接上运行测试服务器直到连接关闭，greeting 为 OK（未登录）或 PREAUTH（已登录）
+func serveFakeIMAP(serverConn net.Conn, greeting, capabilities string, handler fakeIMAPHandler) {
+	defer serverConn.Close()
+	reply := &fakeIMAPReply{w: serverConn}
+	reply.line("* %s [CAPABILITY IMAP4rev1 %s] fake server ready", greeting, capabilities)
+
+	reader := bufio.NewReader(serverConn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.SplitN(strings.TrimRight(line, "\r\n"), " ", 3)
+		if len(fields) < 2 {
+			continue
+		}
+		cmd := fakeIMAPCommand{Tag: fields[0], Name: strings.ToUpper(fields[1])}
+		if len(fields) == 3 {
+			cmd.Args = fields[2]
+		}
+		if cmd.Name == "UID" {
+			parts := strings.SplitN(cmd.Args, " ", 2)
+			cmd.Name = "UID " + strings.ToUpper(parts[0])
+			cmd.Args = ""
+			if len(parts) == 2 {
+				cmd.Args = parts[1]
+			}
+		}
+
+		if handler != nil && handler(cmd, reply) {
+			continue
+		}
+		switch cmd.Name {
+		case "CAPABILITY":
+			reply.line("* CAPABILITY IMAP4rev1 %s", capabilities)
+			reply.ok(cmd)
+		case "SELECT", "EXAMINE":
+			reply.line("* 10 EXISTS")
+			reply.line("* OK [UIDVALIDITY 1] UIDs valid")
+			reply.ok(cmd)
+		case "LOGOUT":
+			reply.line("* BYE")
+			reply.ok(cmd)
+			return
+		default:
+			reply.ok(cmd)
+		}
+	}
+}
+
+// newTestDatabase 创建测试用的内存数据库
+func newTestDatabase(t *testing.T) *database.Database {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	db, err := database.NewDatabase(database.DatabaseOptions{InMemory: true})
+	if err != nil {
+		t.Fatalf("创建内存数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestTask 在测试数据库中创建任务，未指定账户时使用测试账户（不存在时先创建）
+func createTestTask(t *testing.T, db *database.Database, task *models.DownloadTask) {
+	t.Helper()
+
+	if task.EmailID == 0 {
+		accounts, err := db.GetEmailAccounts()
+		if err != nil {
+			t.Fatalf("读取账户失败: %v", err)
+		}
+		if len(accounts) > 0 {
+			task.EmailID = accounts[0].ID
+		} else {
+			account := &models.EmailAccount{Name: "test", Email: "user@example.com", IMAPServer: "imap.example.com", IMAPPort: 993, UseSSL: true, IsActive: true}
+			if err := db.CreateEmailAccount(account); err != nil {
+				t.Fatalf("创建账户失败: %v", err)
+			}
+			task.EmailID = account.ID
+		}
+	}
+	if err := db.CreateDownloadTask(task); err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+}
+
+// newTestLogger 创建不输出内容的日志记录器
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// maxFolderSuggestions 每个不存在的文件夹最多给出的相近名称数
+const maxFolderSuggestions = 3
+
+// ValidateFolders 列出服务器上的文件夹，检查账户配置的额外扫描文件夹是否都存在
+// 手动输入的文件夹名拼写错误时扫描结果总是为空，这里给出名称相近的文件夹供用户修正
+func (es *EmailService) ValidateFolders(accountID uint) ([]models.FolderCheck, error) {
+	account, err := es.getAccountByID(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+	if len(account.ScanFolders) == 0 {
+		return []models.FolderCheck{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(es.ctx, 30*time.Second)
+	defer cancel()
+
+	conn, err := es.createConnectionWithTimeout(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("连接失败: %v", err)
+	}
+	defer conn.close()
+
+	mailboxes, err := conn.listFolders()
+	if err != nil {
+		return nil, err
+	}
+
+	// 使用本次列出的结果解析文件夹角色，不依赖可能过期的缓存
+	account.FolderDelimiter, account.SpecialFolders = resolveSpecialFolders(mailboxes)
+	names := make([]string, 0, len(mailboxes))
+	for _, mbox := range mailboxes {
+		names = append(names, mbox.Name)
+	}
+
+	checks := make([]models.FolderCheck, 0, len(account.ScanFolders))
+	for _, folder := range account.ScanFolders {
+		check := models.FolderCheck{Folder: folder, Resolved: ResolveFolder(account, folder), Suggestions: []string{}}
+		for _, name := range names {
+			if name == check.Resolved {
+				check.Exists = true
+				break
+			}
+		}
+		if !check.Exists {
+			check.Suggestions = similarFolders(check.Resolved, names)
+			es.logger.Warnf("账户%d配置的文件夹 %q 在服务器上不存在，相近的文件夹: %v", accountID, folder, check.Suggestions)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkMainFolder 通过 LIST 确认账户配置的主文件夹在服务器上存在，不存在时返回带相近文件夹名的错误
+func (es *EmailService) checkMainFolder(conn *IMAPConnection) error {
+	mailboxes, err := conn.listFolders()
+	if err != nil {
+		return err
+	}
+
+	// 使用本次列出的结果解析文件夹角色和分隔符
+	conn.Account.FolderDelimiter, conn.Account.SpecialFolders = resolveSpecialFolders(mailboxes)
+	resolved := conn.mainMailbox()
+	names := make([]string, 0, len(mailboxes))
+	for _, mbox := range mailboxes {
+		if mbox.Name == resolved {
+			return nil
+		}
+		names = append(names, mbox.Name)
+	}
+
+	if suggestions := similarFolders(resolved, names); len(suggestions) > 0 {
+		return fmt.Errorf("文件夹 %q 在服务器上不存在，是否要使用: %s", conn.Account.Folder, strings.Join(suggestions, "、"))
+	}
+	return fmt.Errorf("文件夹 %q 在服务器上不存在，请检查名称（子文件夹以 / 分隔，如 Invoices/2024）", conn.Account.Folder)
+}
+
+// similarFolders 按编辑距离找出与 target 相近的文件夹名，大小写不同或包含关系的名称优先
+func similarFolders(target string, names []string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	lowerTarget := strings.ToLower(target)
+	limit := len([]rune(lowerTarget))/3 + 1
+	if limit < 2 {
+		limit = 2
+	}
+
+	var candidates []candidate
+	for _, name := range names {
+		lowerName := strings.ToLower(name)
+		distance := editDistance(lowerTarget, lowerName)
+		if lowerName == lowerTarget || strings.Contains(lowerName, lowerTarget) || strings.Contains(lowerTarget, lowerName) {
+			distance = 0
+		}
+		if distance <= limit {
+			candidates = append(candidates, candidate{name, distance})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+	suggestions := []string{}
+	for i := 0; i < len(candidates) && i < maxFolderSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// editDistance 计算两个字符串的编辑距离（按字符）
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(min(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"emaild/backend/models"
+)
+
+const (
+	// gmailCapability Gmail IMAP扩展能力，支持时可获取 X-GM-MSGID 和 X-GM-THRID
+	gmailCapability = "X-GM-EXT-1"
+	// gmailMessageIDItem Gmail邮件ID，在账户内唯一，移动文件夹或修改标签后保持不变
+	gmailMessageIDItem imap.FetchItem = "X-GM-MSGID"
+	// gmailThreadIDItem Gmail会话ID
+	gmailThreadIDItem imap.FetchItem = "X-GM-THRID"
+)
+
+// supportsGmailExtensions 检查服务器是否支持Gmail扩展
+func supportsGmailExtensions(c *client.Client) bool {
+	ok, err := c.Support(gmailCapability)
+	return err == nil && ok
+}
+
+// withGmailItems Gmail连接在获取内容中追加 X-GM-MSGID 和 X-GM-THRID
+func (conn *IMAPConnection) withGmailItems(items []imap.FetchItem) []imap.FetchItem {
+	if !conn.IsGmail {
+		return items
+	}
+	result := make([]imap.FetchItem, 0, len(items)+2)
+	result = append(result, items...)
+	return append(result, gmailMessageIDItem, gmailThreadIDItem)
+}
+
+// gmailMessageIDs 从FETCH结果中读取 X-GM-MSGID 和 X-GM-THRID，不存在或格式不正确时返回空字符串
+func gmailMessageIDs(msg *imap.Message) (msgID, threadID string) {
+	return gmailFetchValue(msg, gmailMessageIDItem), gmailFetchValue(msg, gmailThreadIDItem)
+}
+
+// gmailFetchValue 读取64位无符号整数形式的扩展字段
+func gmailFetchValue(msg *imap.Message, item imap.FetchItem) string {
+	value, ok := msg.Items[item]
+	if !ok || value == nil {
+		return ""
+	}
+	raw := strings.TrimSpace(fmt.Sprint(value))
+	if _, err := strconv.ParseUint(raw, 10, 64); err != nil {
+		return ""
+	}
+	return raw
+}
+
+// gmailMessageKey 没有 Message-ID 的Gmail邮件使用 X-GM-MSGID 作为记录的唯一标识
+func gmailMessageKey(gmailMessageID string) string {
+	return "X-GM-MSGID:" + gmailMessageID
+}
+
+// isMessageProcessed 检查邮件是否已处理
+// Gmail邮件优先按 X-GM-MSGID 判断，同一封邮件出现在多个标签（文件夹）中时也只处理一次；
+// 旧记录没有保存 X-GM-MSGID，因此仍回退到 Message-ID
+func (es *EmailService) isMessageProcessed(account *models.EmailAccount, messageID, gmailMessageID string) bool {
+	if gmailMessageID != "" {
+		processed, err := es.db.HasGmailMessage(account.ID, gmailMessageID)
+		if err == nil && processed {
+			return true
+		}
+	}
+	if messageID == "" {
+		return false
+	}
+	_, err := es.db.GetEmailMessageByMessageID(messageID)
+	return err == nil
+}
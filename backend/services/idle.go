@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/emersion/go-imap/client"
+)
+
+const (
+	// idleRestartInterval 重新发出IDLE命令的间隔，避免服务器按空闲超时断开（RFC 2177 要求不超过29分钟）
+	idleRestartInterval = 20 * time.Minute
+	// idleCheckDelay 收到新邮件通知后稍等片刻再检查，合并同时到达的多封邮件
+	idleCheckDelay = 2 * time.Second
+	// idleStopTimeout 结束IDLE后等待服务器确认的时间，超时视为连接已失效
+	idleStopTimeout = 10 * time.Second
+	// idleReconnectDelay IDLE连接中断后重新连接前的等待时间
+	idleReconnectDelay = 30 * time.Second
+)
+
+// idleWatcher 单个账户的IDLE推送协程，使用独立连接，与检查和下载的连接一起计入连接数上限
+type idleWatcher struct {
+	cancel context.CancelFunc
+	active atomic.Bool // 正在通过IDLE等待推送，后台定时检查跳过该账户
+}
+
+// refreshIdleWatchers 按账户设置启动或停止IDLE协程
+// 开启IDLE、已启用且未暂停监控的账户各保持一个协程，其他账户的协程被停止；免打扰时段内停止全部协程
+func (es *EmailService) refreshIdleWatchers() {
+	wanted := make(map[uint]bool)
+	if !es.InQuietHours() {
+		accounts, err := es.getActiveAccounts()
+		if err != nil {
+			es.logger.Errorf("获取活跃账户失败: %v", err)
+			return
+		}
+		for _, account := range accounts {
+			if account.UseIdle && !account.MonitoringPaused {
+				wanted[account.ID] = true
+			}
+		}
+	}
+
+	es.idleWatchersMutex.Lock()
+	defer es.idleWatchersMutex.Unlock()
+
+	for accountID, watcher := range es.idleWatchers {
+		if !wanted[accountID] {
+			watcher.cancel()
+			delete(es.idleWatchers, accountID)
+			es.logger.Infof("账户%d已停止IDLE推送", accountID)
+		}
+	}
+	for accountID := range wanted {
+		if _, exists := es.idleWatchers[accountID]; exists {
+			continue
+		}
+		ctx, cancel := context.WithCancel(es.ctx)
+		watcher := &idleWatcher{cancel: cancel}
+		es.idleWatchers[accountID] = watcher
+		es.wg.Add(1)
+		go es.runIdle(ctx, watcher, accountID)
+	}
+}
+
+// stopIdleWatcher 停止账户的IDLE协程，账户设置仍开启IDLE时下次检查周期按新设置重新启动
+func (es *EmailService) stopIdleWatcher(accountID uint) {
+	es.idleWatchersMutex.Lock()
+	defer es.idleWatchersMutex.Unlock()
+
+	if watcher, exists := es.idleWatchers[accountID]; exists {
+		watcher.cancel()
+		delete(es.idleWatchers, accountID)
+	}
+}
+
+// isIdleActive 账户当前是否通过IDLE接收推送
+func (es *EmailService) isIdleActive(accountID uint) bool {
+	es.idleWatchersMutex.Lock()
+	defer es.idleWatchersMutex.Unlock()
+
+	watcher, exists := es.idleWatchers[accountID]
+	return exists && watcher.active.Load()
+}
+
+// runIdle 保持账户的IDLE连接，连接中断时稍后重连
+// 服务器不支持IDLE时退出，该账户继续由定时检查处理
+func (es *EmailService) runIdle(ctx context.Context, watcher *idleWatcher, accountID uint) {
+	defer es.wg.Done()
+
+	for {
+		supported, err := es.idleSession(ctx, watcher, accountID)
+		if ctx.Err() != nil {
+			return
+		}
+		if !supported {
+			es.logger.Infof("账户%d的服务器不支持IDLE，继续使用定时检查", accountID)
+			return
+		}
+		es.logger.Warnf("账户%d的IDLE连接中断，%v后重新连接: %v", accountID, idleReconnectDelay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(idleReconnectDelay):
+		}
+	}
+}
+
+// idleSession 建立IDLE连接并在收到新邮件通知时检查账户，连接出错或上下文取消时返回
+// 服务器不支持IDLE时 supported 为 false
+func (es *EmailService) idleSession(ctx context.Context, watcher *idleWatcher, accountID uint) (supported bool, err error) {
+	account, err := es.getAccountByID(accountID)
+	if err != nil {
+		return true, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+
+	// IDLE连接长期占用名额，至少为检查和下载留出一个名额
+	conn, err := es.createReservedConnection(ctx, account, 1)
+	if err != nil {
+		return true, fmt.Errorf("连接失败: %v", err)
+	}
+	defer conn.close()
+
+	if ok, err := conn.Client.Support("IDLE"); err != nil || !ok {
+		return false, err
+	}
+
+	// 只读打开主文件夹，IDLE连接不会修改邮件标志
+	es.ensureFolderCache(conn)
+	mailbox := conn.mainMailbox()
+	if _, err := conn.selectFolder(mailbox, true); err != nil {
+		return true, fmt.Errorf("选择文件夹 %s 失败: %v", mailbox, err)
+	}
+
+	updates := make(chan client.Update, 16)
+	conn.Client.Updates = updates
+	watcher.active.Store(true)
+	defer watcher.active.Store(false)
+	es.logger.Infof("账户%d已开启IDLE推送（文件夹: %s）", accountID, mailbox)
+
+	for {
+		newMail, err := es.idleUntilNewMail(ctx, conn, updates)
+		if err != nil || !newMail {
+			return true, err
+		}
+
+		if es.InQuietHours() {
+			// 进入免打扰时段后协程在下个检查周期停止，期间的推送留到时段结束后检查
+			es.logger.Infof("账户%d收到新邮件推送，处于免打扰时段，暂不检查", accountID)
+			continue
+		}
+
+		// 每次检查前重新读取账户，会话期间修改的文件夹、搜索条件和暂停设置立即生效
+		current, err := es.getAccountByID(accountID)
+		if err != nil {
+			return true, fmt.Errorf("获取邮箱账户失败: %v", err)
+		}
+		if !current.IsActive || current.MonitoringPaused || !current.UseIdle {
+			// 协程在下个检查周期按新设置停止
+			es.logger.Infof("账户%d已停用、暂停监控或关闭IDLE，忽略新邮件推送", accountID)
+			continue
+		}
+		es.logger.Infof("账户%d收到新邮件推送，开始检查", accountID)
+		es.checkAccountWithDeadline(current, es.accountCheckTimeout())
+
+		// 主文件夹已修改时重新连接，在新的文件夹上等待推送
+		if current.MainFolder() != account.MainFolder() {
+			return true, fmt.Errorf("主文件夹已修改为 %s", current.MainFolder())
+		}
+	}
+}
+
+// idleUntilNewMail 发出IDLE并等待新邮件通知，收到通知后稍等片刻再结束IDLE
+// 上下文取消时返回 false
+func (es *EmailService) idleUntilNewMail(ctx context.Context, conn *IMAPConnection, updates <-chan client.Update) (bool, error) {
+	stop := make(chan struct{})
+	idleErr := make(chan error, 1)
+	go func() {
+		idleErr <- conn.Client.Idle(stop, &client.IdleOptions{LogoutTimeout: idleRestartInterval})
+	}()
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case update := <-updates:
+			// EXISTS 响应表示有新邮件到达，标志变化和删除不需要检查
+			if _, ok := update.(*client.MailboxUpdate); ok && delay == nil {
+				delay = time.After(idleCheckDelay)
+			}
+		case <-delay:
+			return true, stopIdle(conn, stop, idleErr, updates)
+		case err := <-idleErr:
+			if err == nil {
+				err = fmt.Errorf("IDLE意外结束")
+			}
+			return false, err
+		case <-ctx.Done():
+			stopIdle(conn, stop, idleErr, updates)
+			return false, nil
+		}
+	}
+}
+
+// stopIdle 结束IDLE并等待服务器确认，等待期间继续读取通知避免阻塞连接
+// 超时未确认时断开连接
+func stopIdle(conn *IMAPConnection, stop chan struct{}, idleErr <-chan error, updates <-chan client.Update) error {
+	close(stop)
+	timeout := time.NewTimer(idleStopTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-updates:
+		case err := <-idleErr:
+			return err
+		case <-timeout.C:
+			conn.terminate()
+			return fmt.Errorf("结束IDLE超时")
+		}
+	}
+}
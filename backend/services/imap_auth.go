@@ -0,0 +1,121 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-sasl"
+
+	"emaild/backend/models"
+)
+
+// cramMD5Client CRAM-MD5 SASL客户端（go-sasl未提供）
+type cramMD5Client struct {
+	username string
+	password string
+}
+
+// Start 开始CRAM-MD5认证，无初始响应
+func (a *cramMD5Client) Start() (string, []byte, error) {
+	return "CRAM-MD5", nil, nil
+}
+
+// Next 使用密码对服务器挑战做HMAC-MD5签名
+func (a *cramMD5Client) Next(challenge []byte) ([]byte, error) {
+	mac := hmac.New(md5.New, []byte(a.password))
+	mac.Write(challenge)
+	return []byte(a.username + " " + hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+// saslMechanisms 认证机制与SASL名称的对应关系
+var saslMechanisms = map[string]string{
+	models.AuthMechanismPlain:   sasl.Plain,
+	models.AuthMechanismLogin:   sasl.Login,
+	models.AuthMechanismCRAMMD5: "CRAM-MD5",
+}
+
+// autoMechanismOrder 服务器禁用LOGIN命令时自动选择SASL机制的优先顺序（不明文传输密码的优先）
+var autoMechanismOrder = []string{
+	models.AuthMechanismCRAMMD5,
+	models.AuthMechanismPlain,
+	models.AuthMechanismLogin,
+}
+
+// newSASLClient 创建指定机制的SASL客户端
+func newSASLClient(mechanism string, account *models.EmailAccount) sasl.Client {
+	switch mechanism {
+	case models.AuthMechanismPlain:
+		return sasl.NewPlainClient("", account.Email, account.Password)
+	case models.AuthMechanismLogin:
+		return sasl.NewLoginClient(account.Email, account.Password)
+	case models.AuthMechanismCRAMMD5:
+		return &cramMD5Client{username: account.Email, password: account.Password}
+	}
+	return nil
+}
+
+// advertisedAuthMechanisms 服务器在CAPABILITY中声明的AUTH=机制
+func advertisedAuthMechanisms(c *client.Client) []string {
+	caps, err := c.Capability()
+	if err != nil {
+		return nil
+	}
+
+	var mechanisms []string
+	for capability := range caps {
+		if strings.HasPrefix(strings.ToUpper(capability), "AUTH=") {
+			mechanisms = append(mechanisms, strings.ToUpper(capability[5:]))
+		}
+	}
+	sort.Strings(mechanisms)
+	return mechanisms
+}
+
+// authenticate 按账户配置的认证机制登录，返回实际使用的机制
+// auto 模式下优先使用LOGIN命令，服务器声明LOGINDISABLED时从其支持的SASL机制中选择
+func authenticate(c *client.Client, account *models.EmailAccount) (string, error) {
+	mechanism := strings.ToLower(account.AuthMechanism)
+	if mechanism == "" {
+		mechanism = models.AuthMechanismAuto
+	}
+
+	if mechanism == models.AuthMechanismAuto {
+		loginDisabled, _ := c.Support("LOGINDISABLED")
+		if !loginDisabled {
+			if err := c.Login(account.Email, account.Password); err != nil {
+				return "", err
+			}
+			return "LOGIN命令", nil
+		}
+
+		for _, candidate := range autoMechanismOrder {
+			if ok, _ := c.SupportAuth(saslMechanisms[candidate]); ok {
+				mechanism = candidate
+				break
+			}
+		}
+		if mechanism == models.AuthMechanismAuto {
+			return "", fmt.Errorf("服务器禁用了LOGIN命令且没有双方都支持的认证机制（服务器支持: %s）",
+				strings.Join(advertisedAuthMechanisms(c), ", "))
+		}
+	}
+
+	saslName, ok := saslMechanisms[mechanism]
+	if !ok {
+		return "", fmt.Errorf("不支持的认证机制: %s", account.AuthMechanism)
+	}
+	if supported, _ := c.SupportAuth(saslName); !supported {
+		return "", fmt.Errorf("服务器不支持认证机制 %s（服务器支持: %s）",
+			saslName, strings.Join(advertisedAuthMechanisms(c), ", "))
+	}
+
+	if err := c.Authenticate(newSASLClient(mechanism, account)); err != nil {
+		return "", err
+	}
+	return saslName, nil
+}
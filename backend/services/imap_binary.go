@@ -0,0 +1,209 @@
+package services
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// binaryCapability 服务器支持 BINARY 扩展（RFC 3516）时声明的能力
+const binaryCapability = "BINARY"
+
+// literal8Conn 把服务器返回的 literal8（~{n}）改写为普通字面量（{n}），供 go-imap 解析
+// go-imap 不认识 literal8，而服务器用 BINARY 返回含有NUL字节的内容（如PDF）时必须使用它。
+// 合法的IMAP响应中 "~{" 只会出现在 literal8 的开头，引号字符串和字面量内容原样传递
+type literal8Conn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	state      literalScanState
+	escaped    bool  // 引号字符串中上一个字符是反斜杠
+	literalLen int64 // 正在读取的字面量长度
+	remaining  int64 // 字面量内容剩余的字节数，期间原样传递
+}
+
+// literalScanState 扫描响应时所处的位置
+type literalScanState int
+
+const (
+	scanNormal       literalScanState = iota
+	scanQuoted                        // 引号字符串中
+	scanLiteralSize                   // {n} 的花括号中
+	scanLiteralStart                  // {n} 之后等待换行，换行后是字面量内容
+)
+
+// newLiteral8Conn 包装到IMAP服务器的连接
+func newLiteral8Conn(conn net.Conn) *literal8Conn {
+	return &literal8Conn{Conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// Read 读取服务器响应，去掉 literal8 开头的 "~"
+func (c *literal8Conn) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		// 已读到数据时不再等待网络
+		if n > 0 && c.reader.Buffered() == 0 {
+			break
+		}
+
+		if c.remaining > 0 {
+			chunk := p[n:]
+			if int64(len(chunk)) > c.remaining {
+				chunk = chunk[:c.remaining]
+			}
+			m, err := c.reader.Read(chunk)
+			n += m
+			c.remaining -= int64(m)
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		b, err := c.reader.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b == '~' && c.state == scanNormal {
+			// "~" 之后的字节属于同一行，服务器不会停在这里，等待它不会阻塞
+			if next, err := c.reader.Peek(1); err == nil && next[0] == '{' {
+				continue
+			}
+		}
+		c.scan(b)
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// scan 根据读到的字节更新扫描状态；引号字符串和字面量长度都不会跨行，遇到换行时回到普通状态
+func (c *literal8Conn) scan(b byte) {
+	switch c.state {
+	case scanNormal:
+		switch b {
+		case '"':
+			c.state = scanQuoted
+			c.escaped = false
+		case '{':
+			c.state = scanLiteralSize
+			c.literalLen = 0
+		}
+	case scanQuoted:
+		switch {
+		case c.escaped:
+			c.escaped = false
+		case b == '\\':
+			c.escaped = true
+		case b == '"':
+			c.state = scanNormal
+		case b == '\n':
+			c.state = scanNormal
+		}
+	case scanLiteralSize:
+		switch {
+		case b >= '0' && b <= '9':
+			c.literalLen = c.literalLen*10 + int64(b-'0')
+		case b == '}':
+			c.state = scanLiteralStart
+		case b == '\n':
+			c.state = scanNormal
+		}
+	case scanLiteralStart:
+		if b == '\n' {
+			c.state = scanNormal
+			c.remaining = c.literalLen
+		}
+	}
+}
+
+// dialIMAP 连接IMAP服务器并读取问候，tlsConfig 为 nil 时使用普通连接
+// 与 client.DialWithDialerTLS 相同，只是连接经过 literal8Conn 包装，以便使用 BINARY 获取附件
+func dialIMAP(addr string, tlsConfig *tls.Config) (*client.Client, error) {
+	dialer := imapDialer()
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if tlsConfig != nil {
+		if tlsConfig.ServerName == "" {
+			serverName, _, _ := net.SplitHostPort(addr)
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName = serverName
+		}
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	// 问候在返回前读取，用连接期限限制等待时间，之后每个命令会重新设置期限
+	if err := conn.SetDeadline(time.Now().Add(dialer.Timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c, err := client.New(newLiteral8Conn(conn))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// supportsBinary 服务器是否支持 BINARY 扩展
+func supportsBinary(c *client.Client) bool {
+	ok, err := c.Support(binaryCapability)
+	return err == nil && ok
+}
+
+// fetchBinaryPart 用 BINARY[section] 获取邮件部分，服务器负责解码传输编码，返回的就是文件内容
+// 服务器无法解码（如未知编码）时返回错误，由调用方改用 BODY[section] 并在本地解码
+func (ds *DownloadService) fetchBinaryPart(conn *IMAPConnection, uid uint32, section string) ([]byte, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+
+	// 请求使用PEEK不改变已读状态，服务器响应中的名称不带PEEK
+	fetchItem := imap.FetchItem(fmt.Sprintf("BINARY.PEEK[%s]", section))
+	responseItem := imap.FetchItem(fmt.Sprintf("BINARY[%s]", section))
+	messages := make(chan *imap.Message, 1)
+
+	err := conn.withLock(func() error {
+		return conn.Client.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, fetchItem}, messages)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("BINARY获取失败: %v", err)
+	}
+
+	var msg *imap.Message
+	select {
+	case msg = <-messages:
+		if msg == nil {
+			return nil, fmt.Errorf("获取的邮件为空")
+		}
+	case <-time.After(30 * time.Second):
+		return nil, fmt.Errorf("获取PDF内容超时")
+	}
+
+	if !ds.validateUID(uid, msg.Uid, "BINARY内容获取") {
+		return nil, fmt.Errorf("BINARY内容UID不一致 (期望: %d, 实际: %d)", uid, msg.Uid)
+	}
+
+	// go-imap 不把 BINARY[...] 当作正文部分，内容保存在 Items 中
+	literal, ok := msg.Items[responseItem].(imap.Literal)
+	if !ok || literal == nil {
+		return nil, fmt.Errorf("服务器未返回BINARY内容")
+	}
+	content, err := io.ReadAll(literal)
+	if err != nil {
+		return nil, fmt.Errorf("读取BINARY内容失败: %v", err)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("PDF部分内容为空")
+	}
+	return content, nil
+}
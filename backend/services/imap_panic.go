@@ -0,0 +1,42 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// errIMAPPanic IMAP库在处理异常响应时发生panic，说明连接的协议状态已不可信，需要重新建立连接
+var errIMAPPanic = errors.New("IMAP连接异常")
+
+// recoverIMAPPanic 执行IMAP操作，把其中发生的panic转换为 errIMAPPanic 错误
+func recoverIMAPPanic(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errIMAPPanic, r)
+		}
+	}()
+	return fn()
+}
+
+// recycleAfterPanic 使用连接执行IMAP操作，发生panic时断开并通过 reconnect 重新建立连接后再执行一次
+// conn 指向当前连接，重连后更新为新连接；再次panic或重连失败时返回错误，其他结果原样返回
+func recycleAfterPanic(logger *logrus.Logger, conn **IMAPConnection, reconnect func() (*IMAPConnection, error), fn func(*IMAPConnection) error) error {
+	current := *conn
+	err := recoverIMAPPanic(func() error { return fn(current) })
+	if !errors.Is(err, errIMAPPanic) {
+		return err
+	}
+
+	logger.Warnf("账户%d的IMAP连接发生异常，重新建立连接后重试: %v", current.Account.ID, err)
+	current.terminate()
+	current.close()
+
+	newConn, connErr := reconnect()
+	if connErr != nil {
+		return fmt.Errorf("%v（重新连接失败: %v）", err, connErr)
+	}
+	*conn = newConn
+	return recoverIMAPPanic(func() error { return fn(newConn) })
+}
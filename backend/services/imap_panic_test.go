@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// TestRecycleAfterPanicReleasesLock IMAP操作中发生panic时释放连接锁，断开旧连接不会卡住，重连后在新连接上重试
+func TestRecycleAfterPanicReleasesLock(t *testing.T) {
+	// 没有客户端的连接在获取内容时panic，模拟IMAP库处理异常响应时的panic
+	broken := &IMAPConnection{ID: 1, Account: &models.EmailAccount{ID: 1}, IsConnected: true}
+	fresh := newFakeIMAPConnection(t, "BINARY", nil)
+	ds := &DownloadService{logger: newTestLogger()}
+
+	conn := broken
+	done := make(chan error, 1)
+	go func() {
+		done <- recycleAfterPanic(ds.logger, &conn, func() (*IMAPConnection, error) {
+			return fresh, nil
+		}, func(c *IMAPConnection) error {
+			_, err := ds.fetchBinaryPart(c, 7, "2")
+			return err
+		})
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic后断开连接卡在连接锁上")
+	}
+	if err != nil && strings.Contains(err.Error(), errIMAPPanic.Error()) {
+		t.Fatalf("重连后仍然panic: %v", err)
+	}
+	if conn != fresh {
+		t.Error("重连后没有使用新连接")
+	}
+	if !broken.Mutex.TryLock() {
+		t.Fatal("panic后连接锁没有释放")
+	}
+	broken.Mutex.Unlock()
+	if broken.IsConnected {
+		t.Error("panic的连接没有被关闭")
+	}
+}
+
+// TestRecoverIMAPPanicWithinLock 持锁的操作panic时转换为错误，锁随之释放
+func TestRecoverIMAPPanicWithinLock(t *testing.T) {
+	conn := &IMAPConnection{ID: 1, Account: &models.EmailAccount{ID: 1}, IsConnected: true}
+
+	err := recoverIMAPPanic(func() error {
+		return conn.withLock(func() error {
+			return conn.Client.Noop()
+		})
+	})
+	if !errors.Is(err, errIMAPPanic) {
+		t.Fatalf("返回 %v，期望 errIMAPPanic", err)
+	}
+	if !conn.Mutex.TryLock() {
+		t.Fatal("panic后连接锁没有释放")
+	}
+	conn.Mutex.Unlock()
+}
+
+// TestCloseDoesNotWaitForLock 其他操作持有连接锁（如卡在网络读写上）时，关闭连接不等待锁，锁释放后更新连接状态
+func TestCloseDoesNotWaitForLock(t *testing.T) {
+	conn := newFakeIMAPConnection(t, "", nil)
+	conn.Mutex.Lock()
+
+	closed := make(chan struct{})
+	go func() {
+		conn.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		conn.Mutex.Unlock()
+		t.Fatal("关闭连接时等待了其他操作持有的连接锁")
+	}
+	conn.Mutex.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn.Mutex.Lock()
+		connected := conn.IsConnected
+		conn.Mutex.Unlock()
+		if !connected {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("锁释放后连接仍标记为已连接")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// imapRetryAttempts IMAP操作遇到临时性错误时的最大重试次数
+	imapRetryAttempts = 2
+	// imapRetryBackoff 每次重试前的等待时间（按重试次数递增）
+	imapRetryBackoff = 2 * time.Second
+)
+
+// transientIMAPErrors 临时性错误的特征文本（网络中断、超时、服务器暂时不可用）
+var transientIMAPErrors = []string{
+	"连接已断开",
+	"connection closed",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"use of closed network connection",
+	"timeout",
+	"timed out",
+	"eof",
+	"try again",
+	"temporarily",
+	"unavailable",
+}
+
+// isTransientIMAPError 判断IMAP错误是否为临时性错误，重新连接后可能恢复
+// 服务器明确拒绝的 NO/BAD 响应（如文件夹不存在、语法错误）视为永久错误，不重试
+func isTransientIMAPError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range transientIMAPErrors {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// withIMAPRetry 使用账户连接执行IMAP操作，遇到临时性错误时丢弃连接、重新连接后重试
+// 操作发生panic时立即重新建立连接再试一次；conn 指向当前连接，重连后更新为新连接；返回实际重试的次数
+func (es *EmailService) withIMAPRetry(conn **IMAPConnection, operation string, fn func(*IMAPConnection) error) (int, error) {
+	accountID := (*conn).Account.ID
+
+	err := recycleAfterPanic(es.logger, conn, func() (*IMAPConnection, error) {
+		return es.replaceConnection(*conn)
+	}, fn)
+	retries := 0
+	for err != nil && retries < imapRetryAttempts && isTransientIMAPError(err) {
+		retries++
+		es.logger.Warnf("账户%d%s遇到临时性错误，第%d次重新连接后重试: %v", accountID, operation, retries, err)
+
+		select {
+		case <-time.After(time.Duration(retries) * imapRetryBackoff):
+		case <-es.ctx.Done():
+			return retries, err
+		}
+
+		newConn, connErr := es.replaceConnection(*conn)
+		if connErr != nil {
+			// 重连本身失败也可能是临时性的，继续按重试次数处理
+			err = connErr
+			continue
+		}
+		*conn = newConn
+		err = recoverIMAPPanic(func() error { return fn(newConn) })
+	}
+
+	if err == nil && retries > 0 {
+		es.logger.Infof("账户%d%s在第%d次重试后成功", accountID, operation, retries)
+	}
+	return retries, err
+}
+
+// replaceConnection 丢弃操作出错时使用的连接并重新获取账户连接，重连成功后结束对旧连接的使用
+func (es *EmailService) replaceConnection(old *IMAPConnection) (*IMAPConnection, error) {
+	es.discardConnection(old)
+	newConn, err := es.getConnection(old.ID)
+	if err != nil {
+		return nil, err
+	}
+	es.releaseConnection(old)
+	return newConn, nil
+}
+
+// discardConnection 把出错的连接移出缓存，下次获取时重新建立
+// 只移除调用方持有的这个连接，其他操作已重新建立的连接不受影响；
+// 仍有其他操作在使用时不立即关闭，由最后一个使用者释放时关闭
+func (es *EmailService) discardConnection(conn *IMAPConnection) {
+	es.connectionsMutex.Lock()
+	if es.connections[conn.ID] == conn {
+		es.removeConnectionLocked(conn.ID)
+	}
+	shared := conn.users > 1
+	es.connectionsMutex.Unlock()
+
+	if !shared {
+		conn.close()
+	}
+}
+
+// dropConnection 关闭并移除账户的缓存连接，下次获取时重新建立
+func (es *EmailService) dropConnection(accountID uint) {
+	es.connectionsMutex.Lock()
+	defer es.connectionsMutex.Unlock()
+
+	if conn, exists := es.connections[accountID]; exists {
+		conn.close()
+		es.removeConnectionLocked(accountID)
+	}
+}
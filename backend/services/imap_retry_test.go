@@ -0,0 +1,58 @@
+package services
+
+import "testing"
+
+func newTestEmailService(conns ...*IMAPConnection) *EmailService {
+	es := &EmailService{
+		connections: make(map[uint]*IMAPConnection),
+		logger:      newTestLogger(),
+	}
+	for _, conn := range conns {
+		es.connections[conn.ID] = conn
+	}
+	return es
+}
+
+// 其他操作已经重新建立了缓存连接时，丢弃旧连接不影响新连接
+func TestDiscardConnectionKeepsReplacement(t *testing.T) {
+	stale := newFakeIMAPConnection(t, "", nil)
+	fresh := newFakeIMAPConnection(t, "", nil)
+	fresh.users = 1
+	es := newTestEmailService(fresh)
+
+	es.discardConnection(stale)
+
+	if es.connections[fresh.ID] != fresh {
+		t.Fatal("新建立的缓存连接被移除")
+	}
+	if !fresh.IsConnected {
+		t.Fatal("新建立的缓存连接被关闭")
+	}
+	if stale.IsConnected {
+		t.Fatal("出错的连接没有被关闭")
+	}
+}
+
+// 同一连接仍被其他操作使用时，丢弃只移出缓存，由最后一个使用者释放时关闭
+func TestDiscardSharedConnectionClosesOnLastRelease(t *testing.T) {
+	conn := newFakeIMAPConnection(t, "", nil)
+	conn.users = 2
+	es := newTestEmailService(conn)
+
+	es.discardConnection(conn)
+	if _, cached := es.connections[conn.ID]; cached {
+		t.Fatal("出错的连接仍在缓存中")
+	}
+	if !conn.IsConnected {
+		t.Fatal("连接仍在被其他操作使用时被关闭")
+	}
+
+	es.releaseConnection(conn)
+	if !conn.IsConnected {
+		t.Fatal("还有一个使用者时连接被关闭")
+	}
+	es.releaseConnection(conn)
+	if conn.IsConnected {
+		t.Fatal("最后一个使用者释放后连接没有关闭")
+	}
+}
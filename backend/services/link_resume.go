@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// resumeOffset 返回链接任务可以续传的位置：临时文件存在且不短于已记录的进度时从该位置继续，否则从头下载
+func (ds *DownloadService) resumeOffset(task *models.DownloadTask, tempPath string) int64 {
+	if task.DownloadedSize <= 0 {
+		return 0
+	}
+	info, err := os.Stat(tempPath)
+	if err != nil || info.Size() < task.DownloadedSize {
+		return 0
+	}
+	return task.DownloadedSize
+}
+
+// requestLink 请求下载链接，offset 大于0时使用Range请求续传，并用 If-Range 带上开始下载时记录的校验值
+// 服务器忽略Range或文件已变化而返回200、或返回的范围与续传位置不一致时从头下载，返回实际的起始位置
+func (ds *DownloadService) requestLink(worker *DownloadWorker, source string, offset int64) (*http.Response, int64, error) {
+	task := worker.Task
+	
+	req, err := ds.newLinkRequest(worker, source)
+	if err != nil {
+		return nil, 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// 服务器上的文件已变化时 If-Range 使服务器返回完整的新文件，避免拼接到旧的部分上
+		if validator, err := ds.db.GetTaskResumeValidator(task.ID); err == nil && validator != "" {
+			worker.validator = validator
+			req.Header.Set("If-Range", validator)
+		}
+	}
+	
+	// 发送请求
+	resp, err := worker.Client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("请求失败: %v", err)
+	}
+	
+	ds.logger.Infof("服务器响应状态: %d, Content-Type: %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, _, total, err := utils.ParseContentRange(resp.Header.Get("Content-Range"))
+		if err == nil && start == offset {
+			if total > 0 {
+				task.FileSize = total
+			}
+			ds.taskInfof(task.ID, "从 %s 处继续下载", utils.FormatBytes(offset))
+			return resp, offset, nil
+		}
+		resp.Body.Close()
+		ds.taskWarnf(task.ID, "续传响应的范围与已下载的位置不一致（%s），重新开始下载", resp.Header.Get("Content-Range"))
+		return ds.requestLink(worker, source, 0)
+		
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 服务器上的文件已变化，已下载的部分不再可用
+		resp.Body.Close()
+		ds.taskWarnf(task.ID, "服务器拒绝续传范围，重新开始下载")
+		return ds.requestLink(worker, source, 0)
+		
+	case resp.StatusCode == http.StatusOK:
+		if offset > 0 {
+			// 服务器忽略了Range请求或文件已变化，丢弃已下载的部分重新开始
+			ds.taskInfof(task.ID, "服务器不支持断点续传或文件已变化，重新开始下载")
+		}
+		ds.rememberResumeValidator(worker, resp.Header)
+		return resp, 0, nil
+	}
+	
+	// 读取错误响应内容
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	ds.logger.Errorf("服务器响应错误: %d, 内容: %s", resp.StatusCode, string(body[:min(len(body), 500)]))
+	return nil, 0, fmt.Errorf("服务器响应错误: %d", resp.StatusCode)
+}
+
+// resumeValidator 返回响应中可用于 If-Range 的校验值：优先使用强ETag，其次是Last-Modified，都没有时返回空字符串
+// 弱ETag不能用于 If-Range
+func resumeValidator(header http.Header) string {
+	if etag := strings.TrimSpace(header.Get("ETag")); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return strings.TrimSpace(header.Get("Last-Modified"))
+}
+
+// rememberResumeValidator 记录完整下载响应的校验值，之后续传时用 If-Range 确认服务器上的文件没有变化
+func (ds *DownloadService) rememberResumeValidator(worker *DownloadWorker, header http.Header) {
+	worker.validator = resumeValidator(header)
+	if err := ds.db.UpdateTaskResumeValidator(worker.Task.ID, worker.validator); err != nil {
+		ds.logger.Warnf("任务 %d 保存续传校验值失败: %v", worker.Task.ID, err)
+	}
+}
+
+// openLinkTempFile 打开链接下载的临时文件：续传时截断到续传位置后以追加方式打开，否则重新创建
+func openLinkTempFile(tempPath string, offset int64) (*os.File, error) {
+	if offset <= 0 {
+		return os.Create(tempPath)
+	}
+	if err := os.Truncate(tempPath, offset); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(tempPath, os.O_WRONLY|os.O_APPEND, 0644)
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"emaild/backend/models"
+)
+
+// TestRequestLinkIfRange 续传时带上 If-Range，服务器上的文件未变化时继续下载，已变化时从头下载并记录新的校验值
+func TestRequestLinkIfRange(t *testing.T) {
+	content := strings.Repeat("x", 1000)
+	currentETag := `"v2"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", currentETag)
+		rangeHeader := r.Header.Get("Range")
+		if rangeHeader == "" || r.Header.Get("If-Range") != currentETag {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.Write([]byte(content))
+			return
+		}
+		start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rangeHeader, "bytes="), "-"))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(content)-1, len(content)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(content[start:]))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		validator  string
+		wantOffset int64
+	}{
+		{"文件未变化时续传", `"v2"`, 400},
+		{"文件已变化时从头下载", `"v1"`, 0},
+		{"没有校验值时从头下载", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			task := &models.DownloadTask{FileName: "a.pdf", Status: models.StatusDownloading, Type: models.TypeLink, Source: server.URL + "/a.pdf"}
+			createTestTask(t, db, task)
+			if err := db.UpdateTaskResumeValidator(task.ID, tt.validator); err != nil {
+				t.Fatalf("保存校验值失败: %v", err)
+			}
+
+			ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+			worker := &DownloadWorker{Task: task, Client: server.Client(), Context: context.Background()}
+			resp, offset, err := ds.requestLink(worker, task.Source, 400)
+			if err != nil {
+				t.Fatalf("请求链接失败: %v", err)
+			}
+			resp.Body.Close()
+
+			if offset != tt.wantOffset {
+				t.Errorf("起始位置为 %d，期望 %d", offset, tt.wantOffset)
+			}
+			stored, err := db.GetTaskResumeValidator(task.ID)
+			if err != nil {
+				t.Fatalf("读取校验值失败: %v", err)
+			}
+			if stored != currentETag {
+				t.Errorf("记录的校验值为 %q，期望 %q", stored, currentETag)
+			}
+		})
+	}
+}
+
+// TestResumeValidator 优先使用强ETag，弱ETag时使用Last-Modified
+func TestResumeValidator(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string
+	}{
+		{"强ETag", http.Header{"Etag": {`"abc"`}, "Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, `"abc"`},
+		{"弱ETag使用Last-Modified", http.Header{"Etag": {`W/"abc"`}, "Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, "Mon, 02 Jan 2006 15:04:05 GMT"},
+		{"都没有", http.Header{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resumeValidator(tt.header); got != tt.want {
+				t.Errorf("resumeValidator() = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
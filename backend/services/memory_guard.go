@@ -0,0 +1,28 @@
+package services
+
+import (
+	"emaild/backend/utils"
+)
+
+// memoryLow 判断系统可用内存是否低于设定的下限，低于时调度器暂缓启动新的下载任务
+// 进入和离开内存不足状态时各记录一次日志；无法获取可用内存时不限制。只在任务调度器协程中调用
+func (ds *DownloadService) memoryLow() bool {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.MinFreeMemoryMB <= 0 {
+		ds.lowMemory = false
+		return false
+	}
+	available, err := utils.AvailableMemoryBytes()
+	if err != nil {
+		return false
+	}
+
+	low := available < int64(config.MinFreeMemoryMB)<<20
+	if low && !ds.lowMemory {
+		ds.logger.Warnf("可用内存 %s 低于设定的 %d MB，暂缓启动新的下载任务", utils.FormatBytes(available), config.MinFreeMemoryMB)
+	} else if !low && ds.lowMemory {
+		ds.logger.Infof("可用内存恢复到 %s，继续启动等待中的下载任务", utils.FormatBytes(available))
+	}
+	ds.lowMemory = low
+	return low
+}
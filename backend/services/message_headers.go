@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+)
+
+// messageHeadersTimeout 获取邮件头的整体期限
+const messageHeadersTimeout = 30 * time.Second
+
+// GetMessageHeaders 获取邮件的原始头信息，用于编写按邮件头匹配的规则以及排查检测规则未生效的原因
+// 在账户的主文件夹和额外扫描的文件夹中按 Message-ID 查找邮件，只读打开文件夹并使用 BODY.PEEK[HEADER]，不会将邮件标记为已读
+// 返回的头名称按 MIME 规范化（如 Content-Type），头的值保持原样，不解码 RFC 2047 编码
+func (es *EmailService) GetMessageHeaders(messageID string) (map[string][]string, error) {
+	messageID = strings.TrimSpace(messageID)
+	if messageID == "" {
+		return nil, fmt.Errorf("邮件ID不能为空")
+	}
+
+	record, err := es.db.GetEmailMessageByMessageID(messageID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("邮件记录不存在: %s", messageID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取邮件记录失败: %v", err)
+	}
+	if strings.HasPrefix(record.MessageID, gmailMessageKey("")) {
+		return nil, fmt.Errorf("该邮件没有 Message-ID 头，无法在服务器上定位")
+	}
+
+	account, err := es.getAccountByID(record.EmailID)
+	if err != nil {
+		return nil, fmt.Errorf("获取邮箱账户失败: %v", err)
+	}
+
+	// 使用独立连接，避免切换文件夹影响正在进行的检查
+	ctx, cancel := context.WithTimeout(es.ctx, messageHeadersTimeout)
+	defer cancel()
+	conn, err := es.createConnectionWithTimeout(ctx, account)
+	if err != nil {
+		return nil, fmt.Errorf("连接失败: %v", err)
+	}
+	defer conn.close()
+	es.ensureFolderCache(conn)
+
+	mailboxes := []string{conn.mainMailbox()}
+	for _, folder := range account.ScanFolders {
+		mailboxes = append(mailboxes, ResolveFolder(conn.Account, folder))
+	}
+	for _, mailbox := range mailboxes {
+		if _, err := conn.selectFolder(mailbox, true); err != nil {
+			es.logger.Warnf("账户%d打开文件夹 %s 失败: %v", account.ID, mailbox, err)
+			continue
+		}
+
+		uids, err := conn.searchMessageID(record.MessageID)
+		if err != nil {
+			return nil, fmt.Errorf("搜索邮件失败: %v", err)
+		}
+		if len(uids) == 0 {
+			continue
+		}
+		return conn.fetchHeaders(uids[len(uids)-1])
+	}
+
+	return nil, fmt.Errorf("在主文件夹和扫描的文件夹中未找到该邮件，邮件可能已被移动或删除")
+}
+
+// searchMessageID 在当前文件夹中按 Message-ID 头搜索邮件UID
+func (conn *IMAPConnection) searchMessageID(messageID string) ([]uint32, error) {
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+
+	if !conn.IsConnected {
+		return nil, fmt.Errorf("连接已断开")
+	}
+
+	criteria := imap.NewSearchCriteria()
+	criteria.Header.Set("Message-ID", messageID)
+	return conn.Client.UidSearch(criteria)
+}
+
+// fetchHeaders 获取指定UID邮件的全部头信息，使用PEEK避免将邮件标记为已读
+func (conn *IMAPConnection) fetchHeaders(uid uint32) (map[string][]string, error) {
+	section := &imap.BodySectionName{
+		BodyPartName: imap.BodyPartName{Specifier: imap.HeaderSpecifier},
+		Peek:         true,
+	}
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+	messages := make(chan *imap.Message, 1)
+
+	err := conn.withLock(func() error {
+		if !conn.IsConnected {
+			return fmt.Errorf("连接已断开")
+		}
+		return conn.Client.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, section.FetchItem()}, messages)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取邮件头失败: %v", err)
+	}
+
+	msg := <-messages
+	if msg == nil {
+		return nil, fmt.Errorf("服务器未返回邮件头")
+	}
+	body := msg.GetBody(section)
+	if body == nil {
+		return nil, fmt.Errorf("服务器未返回邮件头")
+	}
+
+	header, err := textproto.NewReader(bufio.NewReader(body)).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return nil, fmt.Errorf("解析邮件头失败: %v", err)
+	}
+	return map[string][]string(header), nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// reportRow 月度报表中一个账户下一个发件人的汇总
+type reportRow struct {
+	AccountID uint
+	Account   string
+	Sender    string
+	Count     int
+	TotalSize int64
+}
+
+// MonthlyReportFileName 返回月度报表的默认文件名
+func MonthlyReportFileName(year, month int, format string) string {
+	return fmt.Sprintf("发票汇总_%04d-%02d.%s", year, month, format)
+}
+
+// GenerateMonthlyReport 汇总指定月份完成的下载（按账户和发件人分组，统计文件数和总大小），写入 path
+// 完成时间以任务最后更新时间为准，按本地时间划分月份
+func (ds *DownloadService) GenerateMonthlyReport(year, month int, format, path string) error {
+	if month < 1 || month > 12 {
+		return fmt.Errorf("月份无效: %d", month)
+	}
+	if format != models.ReportFormatCSV && format != models.ReportFormatICal {
+		return fmt.Errorf("不支持的报表格式: %s", format)
+	}
+
+	tasks, err := ds.db.GetAllDownloadTasks()
+	if err != nil {
+		return fmt.Errorf("获取下载任务失败: %v", err)
+	}
+
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 1, 0)
+	rows := groupReportRows(tasks, start, end)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建报表文件失败: %v", err)
+	}
+	defer file.Close()
+
+	switch format {
+	case models.ReportFormatCSV:
+		err = writeReportCSV(file, rows)
+	case models.ReportFormatICal:
+		err = writeReportICal(file, rows, start, end)
+	}
+	if err != nil {
+		return fmt.Errorf("写入报表失败: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("写入报表失败: %v", err)
+	}
+
+	ds.logger.Infof("已生成 %04d-%02d 月度报表（%d 行）: %s", year, month, len(rows), path)
+	return nil
+}
+
+// groupReportRows 按账户和发件人汇总 [start, end) 内完成的任务，按账户、文件数排序
+func groupReportRows(tasks []models.DownloadTask, start, end time.Time) []reportRow {
+	grouped := make(map[string]*reportRow)
+	for _, task := range tasks {
+		if task.Status != models.StatusCompleted {
+			continue
+		}
+		completedAt, err := models.StringToTime(task.UpdatedAt)
+		if err != nil || completedAt.Before(start) || !completedAt.Before(end) {
+			continue
+		}
+
+		account := task.EmailAccount.Email
+		if task.EmailAccount.Name != "" {
+			account = fmt.Sprintf("%s <%s>", task.EmailAccount.Name, task.EmailAccount.Email)
+		}
+		if account == "" {
+			account = "本地导入"
+		}
+		sender := strings.TrimSpace(task.Sender)
+		if sender == "" {
+			sender = "未知发件人"
+		}
+
+		key := fmt.Sprintf("%d\x00%s", task.EmailID, strings.ToLower(sender))
+		row, ok := grouped[key]
+		if !ok {
+			row = &reportRow{AccountID: task.EmailID, Account: account, Sender: sender}
+			grouped[key] = row
+		}
+		row.Count++
+		row.TotalSize += task.FileSize
+	}
+
+	rows := make([]reportRow, 0, len(grouped))
+	for _, row := range grouped {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Account != rows[j].Account {
+			return rows[i].Account < rows[j].Account
+		}
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Sender < rows[j].Sender
+	})
+	return rows
+}
+
+// writeReportCSV 以CSV格式写入报表，带UTF-8 BOM以便Excel正确识别中文
+func writeReportCSV(file *os.File, rows []reportRow) error {
+	if _, err := file.WriteString("\ufeff"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"邮箱账户", "发件人", "文件数", "总大小（字节）", "总大小"})
+
+	var totalCount int
+	var totalSize int64
+	for _, row := range rows {
+		writer.Write([]string{
+			row.Account,
+			row.Sender,
+			fmt.Sprintf("%d", row.Count),
+			fmt.Sprintf("%d", row.TotalSize),
+			utils.FormatBytes(row.TotalSize),
+		})
+		totalCount += row.Count
+		totalSize += row.TotalSize
+	}
+	writer.Write([]string{"合计", "", fmt.Sprintf("%d", totalCount), fmt.Sprintf("%d", totalSize), utils.FormatBytes(totalSize)})
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeReportICal 以iCalendar格式写入报表，每个账户一个位于当月最后一天的全天事件，描述中列出各发件人的明细
+func writeReportICal(file *os.File, rows []reportRow, start, end time.Time) error {
+	lastDay := end.AddDate(0, 0, -1)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//emaild//月度发票汇总//ZH",
+		"CALSCALE:GREGORIAN",
+	}
+
+	for i := 0; i < len(rows); {
+		// rows 已按账户排序，同一账户的行相邻
+		j := i
+		var count int
+		var size int64
+		var details []string
+		for ; j < len(rows) && rows[j].Account == rows[i].Account; j++ {
+			count += rows[j].Count
+			size += rows[j].TotalSize
+			details = append(details, fmt.Sprintf("%s: %d 个文件，%s", rows[j].Sender, rows[j].Count, utils.FormatBytes(rows[j].TotalSize)))
+		}
+
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:emaild-report-%s-%d@emaild", start.Format("200601"), rows[i].AccountID),
+			"DTSTAMP:"+stamp,
+			"DTSTART;VALUE=DATE:"+lastDay.Format("20060102"),
+			"DTEND;VALUE=DATE:"+end.Format("20060102"),
+			"SUMMARY:"+escapeICalText(fmt.Sprintf("%s 发票汇总 %s：%d 个文件，%s", start.Format("2006-01"), rows[i].Account, count, utils.FormatBytes(size))),
+			"DESCRIPTION:"+escapeICalText(strings.Join(details, "\n")),
+			"END:VEVENT",
+		)
+		i = j
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var builder strings.Builder
+	for _, line := range lines {
+		builder.WriteString(foldICalLine(line))
+		builder.WriteString("\r\n")
+	}
+	_, err := file.WriteString(builder.String())
+	return err
+}
+
+// escapeICalText 按RFC 5545转义文本值中的反斜杠、分号、逗号和换行
+func escapeICalText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(text)
+}
+
+// foldICalLine 将超过75字节的行折叠为多行（续行以空格开头），不拆分多字节字符
+func foldICalLine(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+
+	var builder strings.Builder
+	lineLen := 0
+	for _, r := range line {
+		size := len(string(r))
+		if lineLen+size > limit {
+			builder.WriteString("\r\n ")
+			lineLen = 1
+		}
+		builder.WriteRune(r)
+		lineLen += size
+	}
+	return builder.String()
+}
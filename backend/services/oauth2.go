@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+
+	"emaild/backend/models"
+)
+
+const (
+	// xoauth2Mechanism SASL XOAUTH2 机制名称（Gmail、Outlook 使用）
+	xoauth2Mechanism = "XOAUTH2"
+	// oauthRefreshTimeout 刷新访问令牌的超时时间
+	oauthRefreshTimeout = 20 * time.Second
+	// oauthExpiryMargin 访问令牌在到期前这么久即视为过期，避免连接过程中失效
+	oauthExpiryMargin = 2 * time.Minute
+)
+
+// 常见邮件服务商的OAuth2令牌端点
+const (
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	microsoftTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+)
+
+var (
+	// ErrOAuthTokenExpired 刷新令牌已过期或被撤销，需要用户重新授权
+	ErrOAuthTokenExpired = errors.New("OAuth2授权已过期或被撤销，请重新授权")
+	// ErrOAuthTokenRejected 服务器拒绝了访问令牌（权限范围不足或令牌无效）
+	ErrOAuthTokenRejected = errors.New("服务器拒绝了OAuth2访问令牌")
+)
+
+// oauthToken 缓存的访问令牌
+type oauthToken struct {
+	accessToken  string
+	refreshToken string // 换取该令牌时使用的刷新令牌，账户更换授权后缓存失效
+	expiresAt    time.Time
+}
+
+// oauthTokenCache 按账户缓存访问令牌，避免每次连接都刷新
+// mutex 只保护两个映射；刷新令牌时只持有账户自己的锁，一个令牌端点响应慢不影响其他账户
+type oauthTokenCache struct {
+	tokens map[uint]oauthToken
+	locks  map[uint]*sync.Mutex
+	mutex  sync.Mutex
+}
+
+// accountLock 返回账户的刷新锁，同一账户同时只刷新一次令牌
+func (c *oauthTokenCache) accountLock(accountID uint) *sync.Mutex {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.locks == nil {
+		c.locks = make(map[uint]*sync.Mutex)
+	}
+	lock, ok := c.locks[accountID]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[accountID] = lock
+	}
+	return lock
+}
+
+// cached 返回账户仍然有效的缓存令牌，账户更换了刷新令牌或令牌即将过期时返回 false
+func (c *oauthTokenCache) cached(account *models.EmailAccount) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	token, ok := c.tokens[account.ID]
+	if !ok || token.refreshToken != account.OAuthRefreshToken || !time.Now().Add(oauthExpiryMargin).Before(token.expiresAt) {
+		return "", false
+	}
+	return token.accessToken, true
+}
+
+// store 缓存账户的访问令牌
+func (c *oauthTokenCache) store(accountID uint, token oauthToken) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.tokens == nil {
+		c.tokens = make(map[uint]oauthToken)
+	}
+	c.tokens[accountID] = token
+}
+
+// oauthTokenResponse 令牌端点的响应
+type oauthTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OAuthTokenURL 返回账户的令牌端点，未配置时按IMAP服务器识别Gmail和Outlook
+func OAuthTokenURL(account *models.EmailAccount) string {
+	if tokenURL := strings.TrimSpace(account.OAuthTokenURL); tokenURL != "" {
+		return tokenURL
+	}
+
+	server := strings.ToLower(account.IMAPServer)
+	switch {
+	case strings.Contains(server, "gmail") || strings.Contains(server, "googlemail"):
+		return googleTokenURL
+	case strings.Contains(server, "outlook") || strings.Contains(server, "office365") || strings.Contains(server, "hotmail"):
+		return microsoftTokenURL
+	}
+	return ""
+}
+
+// NormalizeOAuthSettings 规范化账户的认证方式，OAuth2 账户必须提供客户端ID、刷新令牌和可识别的令牌端点
+func NormalizeOAuthSettings(account *models.EmailAccount) error {
+	if !account.UsesOAuth2() {
+		account.AuthType = models.AuthTypePassword
+		return nil
+	}
+
+	account.AuthType = models.AuthTypeOAuth2
+	account.OAuthClientID = strings.TrimSpace(account.OAuthClientID)
+	account.OAuthClientSecret = strings.TrimSpace(account.OAuthClientSecret)
+	account.OAuthRefreshToken = strings.TrimSpace(account.OAuthRefreshToken)
+	account.OAuthTokenURL = strings.TrimSpace(account.OAuthTokenURL)
+
+	if account.OAuthClientID == "" || account.OAuthRefreshToken == "" {
+		return fmt.Errorf("使用OAuth2时客户端ID和刷新令牌不能为空")
+	}
+	if OAuthTokenURL(account) == "" {
+		return fmt.Errorf("无法根据IMAP服务器识别OAuth2令牌端点，请填写令牌地址")
+	}
+	if account.OAuthTokenURL != "" {
+		if parsed, err := url.Parse(account.OAuthTokenURL); err != nil || parsed.Scheme != "https" {
+			return fmt.Errorf("OAuth2令牌地址必须是https地址")
+		}
+	}
+	return nil
+}
+
+// oauthAccessToken 返回账户可用的访问令牌，缓存的令牌即将过期时使用刷新令牌重新获取
+// 未保存的账户（测试连接）不缓存令牌
+func (es *EmailService) oauthAccessToken(ctx context.Context, account *models.EmailAccount) (string, error) {
+	if account.ID != 0 {
+		lock := es.oauthTokens.accountLock(account.ID)
+		lock.Lock()
+		defer lock.Unlock()
+
+		if token, ok := es.oauthTokens.cached(account); ok {
+			return token, nil
+		}
+	}
+
+	response, err := refreshOAuthToken(ctx, account)
+	if err != nil {
+		return "", err
+	}
+
+	// 部分服务商（如Outlook）每次刷新都会轮换刷新令牌，旧令牌随后失效
+	if response.RefreshToken != "" && response.RefreshToken != account.OAuthRefreshToken {
+		account.OAuthRefreshToken = response.RefreshToken
+		if account.ID != 0 {
+			if err := es.db.SetOAuthRefreshToken(account.ID, response.RefreshToken); err != nil {
+				es.logger.Warnf("保存账户 %s 的新刷新令牌失败: %v", account.Email, err)
+			}
+		}
+	}
+
+	expiresIn := time.Duration(response.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		expiresIn = time.Hour
+	}
+	if account.ID != 0 {
+		es.oauthTokens.store(account.ID, oauthToken{
+			accessToken:  response.AccessToken,
+			refreshToken: account.OAuthRefreshToken,
+			expiresAt:    time.Now().Add(expiresIn),
+		})
+	}
+
+	es.logger.Infof("已刷新账户 %s 的OAuth2访问令牌，有效期 %s", account.Email, expiresIn)
+	return response.AccessToken, nil
+}
+
+// forgetOAuthToken 丢弃账户缓存的访问令牌，下次连接时重新刷新
+func (es *EmailService) forgetOAuthToken(accountID uint) {
+	es.oauthTokens.mutex.Lock()
+	defer es.oauthTokens.mutex.Unlock()
+	delete(es.oauthTokens.tokens, accountID)
+}
+
+// refreshOAuthToken 使用刷新令牌向令牌端点换取新的访问令牌
+func refreshOAuthToken(ctx context.Context, account *models.EmailAccount) (*oauthTokenResponse, error) {
+	tokenURL := OAuthTokenURL(account)
+	if tokenURL == "" {
+		return nil, fmt.Errorf("未配置OAuth2令牌地址")
+	}
+
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {account.OAuthClientID},
+		"refresh_token": {account.OAuthRefreshToken},
+	}
+	if account.OAuthClientSecret != "" {
+		form.Set("client_secret", account.OAuthClientSecret)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oauthRefreshTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("创建令牌请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("刷新OAuth2访问令牌失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取令牌响应失败: %v", err)
+	}
+
+	var response oauthTokenResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析令牌响应失败（状态码 %d）: %v", resp.StatusCode, err)
+	}
+
+	// invalid_grant 表示刷新令牌已过期、被撤销或用户修改了密码
+	if response.Error == "invalid_grant" {
+		return nil, fmt.Errorf("%w: %s", ErrOAuthTokenExpired, response.ErrorDescription)
+	}
+	if response.Error != "" || resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("刷新OAuth2访问令牌失败（状态码 %d）: %s %s", resp.StatusCode, response.Error, response.ErrorDescription)
+	}
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("令牌响应中没有访问令牌")
+	}
+	return &response, nil
+}
+
+// xoauth2Client SASL XOAUTH2 客户端（go-sasl未提供）
+type xoauth2Client struct {
+	username    string
+	accessToken string
+	failure     string // 服务器在认证失败时返回的错误详情（JSON）
+}
+
+// Start 发送包含用户名和访问令牌的初始响应
+func (a *xoauth2Client) Start() (string, []byte, error) {
+	response := "user=" + a.username + "\x01auth=Bearer " + a.accessToken + "\x01\x01"
+	return xoauth2Mechanism, []byte(response), nil
+}
+
+// Next 认证失败时服务器返回错误详情，按协议回复空响应后服务器结束认证
+func (a *xoauth2Client) Next(challenge []byte) ([]byte, error) {
+	a.failure = string(challenge)
+	return []byte{}, nil
+}
+
+// authenticateOAuth2 使用访问令牌通过SASL XOAUTH2登录
+func authenticateOAuth2(c *client.Client, account *models.EmailAccount, accessToken string) (string, error) {
+	if supported, _ := c.SupportAuth(xoauth2Mechanism); !supported {
+		return "", fmt.Errorf("服务器不支持认证机制 %s（服务器支持: %s）",
+			xoauth2Mechanism, strings.Join(advertisedAuthMechanisms(c), ", "))
+	}
+
+	sasl := &xoauth2Client{username: account.Email, accessToken: accessToken}
+	if err := c.Authenticate(sasl); err != nil {
+		if sasl.failure != "" {
+			return "", fmt.Errorf("%w: %v（%s）", ErrOAuthTokenRejected, err, sasl.failure)
+		}
+		// 部分服务器不返回错误详情，直接以 NO 结束认证；连接仍然可用时说明是服务器拒绝而不是网络错误
+		if c.State() == imap.NotAuthenticatedState && !isTransientIMAPError(err) {
+			return "", fmt.Errorf("%w: %v", ErrOAuthTokenRejected, err)
+		}
+		return "", err
+	}
+	return xoauth2Mechanism, nil
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// TestAuthenticateOAuth2PlainNo 服务器不返回错误详情、直接以 NO 拒绝 AUTHENTICATE 时识别为令牌被拒绝
+func TestAuthenticateOAuth2PlainNo(t *testing.T) {
+	c := newFakeIMAPClient(t, "OK", "AUTH=XOAUTH2 SASL-IR", func(cmd fakeIMAPCommand, reply *fakeIMAPReply) bool {
+		if cmd.Name != "AUTHENTICATE" {
+			return false
+		}
+		reply.line("%s NO [AUTHENTICATIONFAILED] Invalid credentials", cmd.Tag)
+		return true
+	})
+
+	account := &models.EmailAccount{Email: "user@example.com"}
+	_, err := authenticateOAuth2(c, account, "token")
+	if !errors.Is(err, ErrOAuthTokenRejected) {
+		t.Fatalf("期望识别为令牌被拒绝，得到 %v", err)
+	}
+}
+
+// TestOAuthAccessTokenPerAccountLock 一个账户的令牌端点响应慢时，其他账户仍能刷新令牌
+func TestOAuthAccessTokenPerAccountLock(t *testing.T) {
+	release := make(chan struct{})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		r.ParseForm()
+		if r.Form.Get("refresh_token") == "slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		json.NewEncoder(w).Encode(oauthTokenResponse{AccessToken: "access-" + r.Form.Get("refresh_token"), ExpiresIn: 3600})
+	}))
+	defer server.Close()
+	defer close(release)
+
+	es := &EmailService{logger: newTestLogger()}
+	slow := &models.EmailAccount{ID: 1, Email: "slow@example.com", OAuthClientID: "id", OAuthRefreshToken: "slow", OAuthTokenURL: server.URL}
+	fast := &models.EmailAccount{ID: 2, Email: "fast@example.com", OAuthClientID: "id", OAuthRefreshToken: "fast", OAuthTokenURL: server.URL}
+
+	go es.oauthAccessToken(context.Background(), slow)
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan string, 1)
+	go func() {
+		token, _ := es.oauthAccessToken(context.Background(), fast)
+		done <- token
+	}()
+	select {
+	case token := <-done:
+		if token != "access-fast" {
+			t.Errorf("访问令牌为 %q，期望 %q", token, "access-fast")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("其他账户刷新令牌被慢的令牌端点阻塞")
+	}
+
+	// 缓存的令牌不再请求令牌端点
+	before := requests.Load()
+	if token, err := es.oauthAccessToken(context.Background(), fast); err != nil || token != "access-fast" {
+		t.Errorf("缓存的访问令牌为 %q（%v），期望 %q", token, err, "access-fast")
+	}
+	if requests.Load() != before {
+		t.Errorf("使用缓存的令牌时仍然请求了令牌端点")
+	}
+}
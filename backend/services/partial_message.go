@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// ErrPartialIncomplete 分段附件的片段尚未收齐
+var ErrPartialIncomplete = errors.New("分段附件不完整")
+
+// maxMIMEDepth 查找PDF时允许的最大MIME嵌套层数
+const maxMIMEDepth = 10
+
+// partialInfo message/partial 片段参数（RFC 2046 5.2.2）
+type partialInfo struct {
+	id     string
+	number int
+	total  int // 只有最后一段必须携带
+}
+
+// parsePartialMessage 判断邮件是否为 message/partial 片段并解析其参数
+func parsePartialMessage(msg *imap.Message) (*partialInfo, bool) {
+	bs := msg.BodyStructure
+	if bs == nil || !strings.EqualFold(bs.MIMEType, "message") || !strings.EqualFold(bs.MIMESubType, "partial") {
+		return nil, false
+	}
+
+	info := &partialInfo{id: strings.TrimSpace(bs.Params["id"])}
+	info.number, _ = strconv.Atoi(bs.Params["number"])
+	info.total, _ = strconv.Atoi(bs.Params["total"])
+	if info.id == "" || info.number <= 0 {
+		return nil, false
+	}
+	return info, true
+}
+
+// partialFileName 重组前使用的临时文件名，重组后替换为原始附件名
+func partialFileName(subject string) string {
+	name := utils.CleanFilename(utils.DecodeMimeHeader(subject))
+	if name == "" {
+		name = "partial_attachment"
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		name += ".pdf"
+	}
+	return name
+}
+
+// processPartialFragment 保存分段邮件片段并创建或更新对应的下载任务，返回新建的任务数
+// 片段未收齐时任务处于 incomplete 状态，收齐后转为等待下载，由下载服务重组后保存
+func (es *EmailService) processPartialFragment(account *models.EmailAccount, emailMsg *models.EmailMessage, msg *imap.Message, info *partialInfo) int {
+	section, _ := imap.ParseBodySectionName("BODY[TEXT]")
+	body := msg.GetBody(section)
+	if body == nil {
+		es.logger.Warnf("分段邮件片段内容为空（分段ID: %s，序号: %d）", info.id, info.number)
+		return 0
+	}
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		es.logger.Warnf("读取分段邮件片段失败: %v", err)
+		return 0
+	}
+
+	fragment := &models.PartialFragment{
+		EmailID:   account.ID,
+		PartialID: info.id,
+		Number:    info.number,
+		Total:     info.total,
+		Content:   content,
+	}
+	if err := es.db.SavePartialFragment(fragment); err != nil {
+		es.logger.Errorf("保存分段邮件片段失败: %v", err)
+		return 0
+	}
+
+	received, total, err := es.db.GetPartialFragmentCount(account.ID, info.id)
+	if err != nil {
+		es.logger.Errorf("统计分段邮件片段失败: %v", err)
+		return 0
+	}
+	complete := total > 0 && received >= total
+	es.logger.Infof("收到分段邮件片段 %d（分段ID: %s，已收到 %d 段，共 %d 段）", info.number, info.id, received, total)
+
+	task, err := es.db.GetDownloadTaskBySource(account.ID, models.TypePartial, info.id)
+	if err == sql.ErrNoRows {
+		config, err := es.getDownloadConfig()
+		if err != nil {
+			return 0
+		}
+
+		status := models.StatusIncomplete
+		if complete {
+			status = models.StatusPending
+		}
+
+		fileName := partialFileName(emailMsg.Subject)
+		now := time.Now()
+		task = &models.DownloadTask{
+			EmailID:   account.ID,
+			Subject:   emailMsg.Subject,
+			Sender:    emailMsg.Sender,
+			FileName:  fileName,
+			Status:    status,
+			Type:      models.TypePartial,
+			Source:    info.id,
+			LocalPath: filepath.Join(es.resolveDownloadDir(config, account, msg), fileName),
+			Error:     fmt.Sprintf("已收到 %d 段", received),
+			EmailDate: models.TimeToString(messageReceivedDate(msg)),
+			MessageID: emailMsg.ID,
+			CreatedAt: models.TimeToString(now),
+			UpdatedAt: models.TimeToString(now),
+		}
+		if err := es.createDownloadTask(task); err != nil {
+			es.logger.Errorf("创建分段附件下载任务失败: %v", err)
+			return 0
+		}
+
+		if complete {
+			es.downloadService.StartDownload(task.ID)
+		}
+		return 1
+	}
+	if err != nil {
+		es.logger.Errorf("查询分段附件下载任务失败: %v", err)
+		return 0
+	}
+
+	// 剩余片段到齐，重新开始下载
+	if complete && task.Status == models.StatusIncomplete {
+		es.downloadService.updateTaskStatus(task.ID, models.StatusPending, "", 0, 0, "")
+		es.downloadService.StartDownload(task.ID)
+	}
+	return 0
+}
+
+// reassemblePartial 按序号拼接所有片段得到原始邮件，缺少片段时返回 ErrPartialIncomplete
+func reassemblePartial(fragments []models.PartialFragment) ([]byte, error) {
+	total := 0
+	for _, fragment := range fragments {
+		if fragment.Total > total {
+			total = fragment.Total
+		}
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w（尚未收到最后一段，已收到 %d 段）", ErrPartialIncomplete, len(fragments))
+	}
+
+	var buf bytes.Buffer
+	for i := 1; i <= total; i++ {
+		if i > len(fragments) || fragments[i-1].Number != i {
+			return nil, fmt.Errorf("%w（缺少第 %d 段，共 %d 段）", ErrPartialIncomplete, i, total)
+		}
+		buf.Write(fragments[i-1].Content)
+	}
+	return buf.Bytes(), nil
+}
+
+// downloadPartial 重组分段附件，提取其中的PDF并保存
+func (ds *DownloadService) downloadPartial(worker *DownloadWorker) error {
+	task := worker.Task
+
+	fragments, err := ds.db.GetPartialFragments(task.EmailID, task.Source)
+	if err != nil {
+		return fmt.Errorf("获取分段邮件片段失败: %v", err)
+	}
+
+	raw, err := reassemblePartial(fragments)
+	if err != nil {
+		return err
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return fmt.Errorf("解析重组后的邮件失败: %v", err)
+	}
+
+	fileName, pdfData, err := ds.findPDFInMIMEPart(textproto.MIMEHeader(message.Header), message.Body, 0)
+	if err != nil {
+		return err
+	}
+
+	// 使用原始附件名保存
+	if fileName = utils.CleanFilename(fileName); fileName != "" && fileName != task.FileName {
+		localPath := filepath.Join(filepath.Dir(task.LocalPath), fileName)
+		if err := ds.db.UpdateTaskFile(task.ID, fileName, localPath); err != nil {
+			ds.logger.Warnf("任务 %d 更新文件名失败: %v", task.ID, err)
+		} else {
+			task.FileName, task.LocalPath = fileName, localPath
+		}
+	}
+
+	if err := ds.saveAttachmentData(worker, pdfData); err != nil {
+		return err
+	}
+
+	// 已成功保存，片段不再需要
+	if err := ds.db.DeletePartialFragments(task.EmailID, task.Source); err != nil {
+		ds.logger.Warnf("清理分段邮件片段失败: %v", err)
+	}
+	return nil
+}
+
+// findPDFInMIMEPart 递归查找MIME内容中的第一个PDF附件，返回文件名和解码后的内容
+func (ds *DownloadService) findPDFInMIMEPart(header textproto.MIMEHeader, body io.Reader, depth int) (string, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth {
+			return "", nil, fmt.Errorf("MIME嵌套层数过多")
+		}
+
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", nil, fmt.Errorf("解析MIME部分失败: %v", err)
+			}
+
+			if fileName, data, err := ds.findPDFInMIMEPart(part.Header, part, depth+1); err == nil {
+				return fileName, data, nil
+			}
+		}
+		return "", nil, fmt.Errorf("重组后的邮件中未找到PDF附件")
+	}
+
+	fileName, ok := pdfPartName(header, mediaType, params)
+	if !ok {
+		return "", nil, fmt.Errorf("重组后的邮件中未找到PDF附件")
+	}
+
+	data, err := ds.readMIMEPart(header, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return fileName, data, nil
+}
+
+// pdfPartName 判断MIME叶子部分是否为PDF附件，返回解码后的文件名
+func pdfPartName(header textproto.MIMEHeader, mediaType string, params map[string]string) (string, bool) {
+	fileName := params["name"]
+	if _, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dispositionParams["filename"] != "" {
+		fileName = dispositionParams["filename"]
+	}
+	fileName = utils.DecodeMimeHeader(fileName)
+
+	if mediaType != "application/pdf" && !strings.HasSuffix(strings.ToLower(fileName), ".pdf") {
+		return "", false
+	}
+	return fileName, true
+}
+
+// readMIMEPart 读取MIME部分并按传输编码解码
+func (ds *DownloadService) readMIMEPart(header textproto.MIMEHeader, body io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("读取PDF附件失败: %v", err)
+	}
+
+	return ds.decodeContent(content, header.Get("Content-Transfer-Encoding"))
+}
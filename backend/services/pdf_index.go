@@ -0,0 +1,147 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+const (
+	// pdfIndexWorkers 同时建立PDF索引的协程数，索引在下载流程之外进行，数量较少以免拖慢正在进行的下载
+	pdfIndexWorkers = 2
+	// pdfIndexQueueSize 等待建立索引的任务数上限，队列已满时跳过，查询时再建立
+	pdfIndexQueueSize = 200
+	// pdfThumbnailSize 缩略图最长边的像素数
+	pdfThumbnailSize = 240
+	// maxIndexedPDFSize 建立索引时读取的PDF大小上限
+	maxIndexedPDFSize = 200 << 20
+)
+
+// pdfIndexer 在后台为下载完成的PDF建立元数据索引
+// 队列中是下载完成时的任务副本：完成状态由进度协程异步写入数据库，索引时不再按数据库中的状态判断
+type pdfIndexer struct {
+	ds    *DownloadService
+	queue chan *models.DownloadTask
+}
+
+// newPDFIndexer 创建PDF索引器
+func newPDFIndexer(ds *DownloadService) *pdfIndexer {
+	return &pdfIndexer{ds: ds, queue: make(chan *models.DownloadTask, pdfIndexQueueSize)}
+}
+
+// start 启动固定数量的索引协程
+func (ix *pdfIndexer) start() {
+	for i := 0; i < pdfIndexWorkers; i++ {
+		ix.ds.wg.Add(1)
+		go ix.run()
+	}
+}
+
+// run 逐个处理索引队列中的任务，服务关闭时退出
+func (ix *pdfIndexer) run() {
+	defer ix.ds.wg.Done()
+
+	for {
+		select {
+		case task := <-ix.queue:
+			if _, err := ix.ds.indexTaskPDF(task); err != nil {
+				ix.ds.logger.Warnf("任务 %d 建立PDF索引失败: %v", task.ID, err)
+			}
+		case <-ix.ds.ctx.Done():
+			return
+		}
+	}
+}
+
+// add 下载完成后把PDF任务加入索引队列，未开启索引或不是PDF时忽略，不阻塞下载流程
+// task 必须已下载完成，入队的是副本，之后对任务的修改不影响索引
+func (ix *pdfIndexer) add(task *models.DownloadTask) {
+	if task.IsContainer || !isPDFTarget(task.FileName) {
+		return
+	}
+	config, err := ix.ds.db.GetConfig()
+	if err != nil || !config.IndexPDFMetadata {
+		return
+	}
+
+	snapshot := *task
+	select {
+	case ix.queue <- &snapshot:
+	default:
+		ix.ds.logger.Warnf("PDF索引队列已满，任务 %d 在查询时再建立索引", task.ID)
+	}
+}
+
+// indexPDF 按数据库中的任务为已完成的PDF建立索引，用于查询时尚未建立索引的任务
+func (ds *DownloadService) indexPDF(taskID uint) (*models.PDFMetadata, error) {
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		return nil, fmt.Errorf("获取任务失败: %v", err)
+	}
+	if task.Status != models.StatusCompleted {
+		return nil, fmt.Errorf("任务尚未完成下载")
+	}
+	return ds.indexTaskPDF(task)
+}
+
+// indexTaskPDF 读取已下载完成任务PDF的标题、作者和页数，按配置生成缩略图后保存
+func (ds *DownloadService) indexTaskPDF(task *models.DownloadTask) (*models.PDFMetadata, error) {
+	taskID := task.ID
+	if task.IsContainer || !isPDFTarget(task.FileName) {
+		return nil, fmt.Errorf("任务文件不是PDF")
+	}
+
+	info, err := os.Stat(task.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+	if info.Size() > maxIndexedPDFSize {
+		return nil, fmt.Errorf("文件过大（%s），不建立索引", utils.FormatBytes(info.Size()))
+	}
+	data, err := os.ReadFile(task.LocalPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %v", err)
+	}
+
+	parsed, err := utils.ReadPDFMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	metadata := &models.PDFMetadata{
+		TaskID:    taskID,
+		Title:     parsed.Title,
+		Author:    parsed.Author,
+		PageCount: parsed.PageCount,
+	}
+
+	if config, err := ds.db.GetConfig(); err == nil && config.PDFThumbnails {
+		thumbnail, err := utils.PDFThumbnail(data, pdfThumbnailSize)
+		if err != nil {
+			ds.logger.Debugf("任务 %d 未生成缩略图: %v", taskID, err)
+		} else {
+			metadata.Thumbnail = "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(thumbnail)
+		}
+	}
+
+	if err := ds.db.SavePDFMetadata(metadata); err != nil {
+		return nil, fmt.Errorf("保存PDF索引失败: %v", err)
+	}
+	return metadata, nil
+}
+
+// GetPDFMetadata 获取已完成PDF的元数据索引，尚未建立索引时立即读取文件建立
+func (ds *DownloadService) GetPDFMetadata(taskID uint) (*models.PDFMetadata, error) {
+	metadata, err := ds.db.GetPDFMetadata(taskID)
+	if err == nil {
+		return metadata, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("获取PDF索引失败: %v", err)
+	}
+	return ds.indexPDF(taskID)
+}
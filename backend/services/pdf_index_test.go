@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emaild/backend/models"
+)
+
+// testIndexedPDF 带标题的单页PDF
+const testIndexedPDF = "%PDF-1.4\n" +
+	"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+	"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+	"3 0 obj\n<< /Type /Page /Parent 2 0 R >>\nendobj\n" +
+	"4 0 obj\n<< /Title (March Invoice) >>\nendobj\n" +
+	"trailer\n<< /Root 1 0 R /Info 4 0 R >>\n%%EOF\n"
+
+// TestPDFIndexerIndexesQueuedTask 下载完成后入队的任务直接建立索引，不因数据库中的完成状态尚未写入而跳过
+func TestPDFIndexerIndexesQueuedTask(t *testing.T) {
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.IndexPDFMetadata = true
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	localPath := filepath.Join(t.TempDir(), "invoice.pdf")
+	if err := os.WriteFile(localPath, []byte(testIndexedPDF), 0644); err != nil {
+		t.Fatalf("写入测试文件失败: %v", err)
+	}
+	// 数据库中仍是下载中：完成状态由进度协程异步写入
+	task := &models.DownloadTask{FileName: "invoice.pdf", Status: models.StatusDownloading, Type: models.TypeAttachment, LocalPath: localPath}
+	createTestTask(t, db, task)
+
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+	ix := newPDFIndexer(ds)
+	ix.add(task)
+	// 入队的是副本，之后对任务的修改不影响索引
+	task.LocalPath = filepath.Join(t.TempDir(), "moved.pdf")
+
+	queued := <-ix.queue
+	if _, err := ds.indexTaskPDF(queued); err != nil {
+		t.Fatalf("建立索引失败: %v", err)
+	}
+	metadata, err := db.GetPDFMetadata(task.ID)
+	if err != nil {
+		t.Fatalf("读取索引失败: %v", err)
+	}
+	if metadata.Title != "March Invoice" || metadata.PageCount != 1 {
+		t.Errorf("索引为 %+v，期望标题 March Invoice、1 页", metadata)
+	}
+
+	// 查询时建立索引仍按数据库中的状态判断
+	if _, err := ds.indexPDF(task.ID); err == nil {
+		t.Error("未完成的任务在查询时不应建立索引")
+	}
+}
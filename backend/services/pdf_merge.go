@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+const (
+	// maxMergedPDFSize 参与合并的分卷文件总大小上限，合并在内存中进行
+	maxMergedPDFSize = 200 << 20
+	// pdfMergeQueueSize 等待检查分卷合并的任务数上限，队列已满时跳过
+	pdfMergeQueueSize = 100
+)
+
+// ValidateMergePattern 校验分卷PDF合并规则的正则表达式，为空表示不合并
+func ValidateMergePattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if _, err := compileMergePattern(pattern); err != nil {
+		return fmt.Errorf("分卷PDF合并规则无效: %v", err)
+	}
+	return nil
+}
+
+// compileMergePattern 编译合并规则，匹配文件名时不区分大小写
+func compileMergePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// mergeGroupKey 去掉文件名中的分卷标记，同一组分卷得到相同的结果；文件名不含标记时返回false
+func mergeGroupKey(pattern *regexp.Regexp, fileName string) (string, bool) {
+	if !pattern.MatchString(fileName) {
+		return "", false
+	}
+	return strings.ToLower(pattern.ReplaceAllString(fileName, "")), true
+}
+
+// pdfMerger 在后台把同一邮件的分卷PDF全部下载完成后合并为一个文件
+// 只有一个合并协程：多个分卷可能同时下载完成，逐个检查避免同一组被合并两次
+type pdfMerger struct {
+	ds    *DownloadService
+	queue chan uint
+}
+
+// newPDFMerger 创建分卷PDF合并器
+func newPDFMerger(ds *DownloadService) *pdfMerger {
+	return &pdfMerger{ds: ds, queue: make(chan uint, pdfMergeQueueSize)}
+}
+
+// start 启动合并协程
+func (m *pdfMerger) start() {
+	m.ds.wg.Add(1)
+	go m.run()
+}
+
+// run 逐个检查队列中的任务，服务关闭时退出
+func (m *pdfMerger) run() {
+	defer m.ds.wg.Done()
+
+	for {
+		select {
+		case taskID := <-m.queue:
+			m.check(taskID)
+		case <-m.ds.ctx.Done():
+			return
+		}
+	}
+}
+
+// add 任务下载完成后加入合并检查队列，未配置合并规则时忽略，不阻塞状态更新
+func (m *pdfMerger) add(taskID uint) {
+	config, err := m.ds.db.GetConfig()
+	if err != nil || config.MergePDFPattern == "" {
+		return
+	}
+
+	select {
+	case m.queue <- taskID:
+	default:
+		m.ds.logger.Warnf("分卷合并队列已满，跳过任务 %d 的合并检查", taskID)
+	}
+}
+
+// check 检查任务所在邮件中的同组分卷，全部完成时按邮件中的顺序合并
+// 文件名不含分卷标记时忽略；合并失败只记录日志，各分卷文件保持不变
+func (m *pdfMerger) check(taskID uint) {
+	config, err := m.ds.db.GetConfig()
+	if err != nil || config.MergePDFPattern == "" {
+		return
+	}
+	pattern, err := compileMergePattern(config.MergePDFPattern)
+	if err != nil {
+		return
+	}
+	task, err := m.ds.getTaskByIDOptimized(taskID)
+	if err != nil || task.Type == models.TypeMerged || task.IsContainer || !isPDFTarget(task.FileName) {
+		return
+	}
+	key, ok := mergeGroupKey(pattern, task.FileName)
+	if !ok {
+		return
+	}
+
+	tasks, err := m.ds.db.GetMessageTasks(task)
+	if err != nil {
+		m.ds.logger.Warnf("任务 %d 获取同一邮件的任务失败: %v", task.ID, err)
+		return
+	}
+	var parts []models.DownloadTask
+	for _, t := range tasks {
+		if t.IsContainer || !isPDFTarget(t.FileName) {
+			continue
+		}
+		if k, ok := mergeGroupKey(pattern, t.FileName); !ok || k != key {
+			continue
+		}
+		if t.MergedInto != 0 || t.Status != models.StatusCompleted {
+			// 已经合并过，或还有分卷未下载完成
+			return
+		}
+		parts = append(parts, t)
+	}
+	if len(parts) < 2 {
+		return
+	}
+
+	merged, err := m.merge(parts, pattern)
+	if err != nil {
+		m.ds.logger.Warnf("邮件「%s」的 %d 个分卷PDF合并失败，保留各分卷文件: %v", task.Subject, len(parts), err)
+		return
+	}
+	m.ds.logger.Infof("已将邮件「%s」的 %d 个分卷PDF合并为 %s", task.Subject, len(parts), merged.FileName)
+	m.ds.recordChecksum(merged)
+	m.ds.pdfIndex.add(merged)
+}
+
+// merge 合并分卷文件，保存到第一个分卷所在目录并创建已完成的合并任务，各分卷记录合并后的任务ID
+func (m *pdfMerger) merge(parts []models.DownloadTask, pattern *regexp.Regexp) (*models.DownloadTask, error) {
+	documents := make([][]byte, 0, len(parts))
+	var total int64
+	for _, part := range parts {
+		data, err := os.ReadFile(part.LocalPath)
+		if err != nil {
+			return nil, fmt.Errorf("读取分卷 %s 失败: %v", part.FileName, err)
+		}
+		if total += int64(len(data)); total > maxMergedPDFSize {
+			return nil, fmt.Errorf("分卷总大小超过 %s", utils.FormatBytes(maxMergedPDFSize))
+		}
+		documents = append(documents, data)
+	}
+	data, err := utils.MergePDFs(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	first := parts[0]
+	name := strings.TrimSpace(pattern.ReplaceAllString(first.FileName, ""))
+	if strings.TrimSuffix(strings.ToLower(name), ".pdf") == "" {
+		name = "merged.pdf"
+	} else if !strings.HasSuffix(strings.ToLower(name), ".pdf") {
+		name += ".pdf"
+	}
+	path, err := utils.SaveFile(data, name, filepath.Dir(first.LocalPath))
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.ValidatePDFFile(path); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("合并结果校验失败: %v", err)
+	}
+
+	ids := make([]uint, len(parts))
+	sources := make([]string, len(parts))
+	for i, part := range parts {
+		ids[i] = part.ID
+		sources[i] = strconv.FormatUint(uint64(part.ID), 10)
+	}
+	merged := &models.DownloadTask{
+		EmailID:        first.EmailID,
+		Subject:        first.Subject,
+		Sender:         first.Sender,
+		FileName:       filepath.Base(path),
+		FileSize:       int64(len(data)),
+		DownloadedSize: int64(len(data)),
+		Status:         models.StatusCompleted,
+		Type:           models.TypeMerged,
+		Source:         strings.Join(sources, ","),
+		LocalPath:      path,
+		Progress:       100,
+		Folder:         first.Folder,
+		EmailDate:      first.EmailDate,
+		MessageID:      first.MessageID,
+	}
+	if err := m.ds.db.CreateDownloadTask(merged); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("创建合并任务失败: %v", err)
+	}
+	if err := m.ds.db.MarkTasksMerged(ids, merged.ID); err != nil {
+		m.ds.db.DeleteDownloadTask(merged.ID)
+		os.Remove(path)
+		return nil, fmt.Errorf("记录分卷关联失败: %v", err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emaild/backend/database"
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// createMergeParts 为一封邮件创建两个已下载完成的分卷任务
+func createMergeParts(t *testing.T, db *database.Database, message *models.EmailMessage, dir string) []*models.DownloadTask {
+	t.Helper()
+
+	if err := db.CreateEmailMessage(message); err != nil {
+		t.Fatalf("创建邮件失败: %v", err)
+	}
+	var parts []*models.DownloadTask
+	for _, name := range []string{"invoice_part1.pdf", "invoice_part2.pdf"} {
+		localPath := filepath.Join(dir, name)
+		if err := os.WriteFile(localPath, []byte(testIndexedPDF), 0644); err != nil {
+			t.Fatalf("写入测试文件失败: %v", err)
+		}
+		task := &models.DownloadTask{
+			EmailID:   message.EmailID,
+			Subject:   message.Subject,
+			Sender:    message.Sender,
+			FileName:  name,
+			Status:    models.StatusCompleted,
+			Type:      models.TypeAttachment,
+			LocalPath: localPath,
+			MessageID: message.ID,
+		}
+		createTestTask(t, db, task)
+		parts = append(parts, task)
+	}
+	return parts
+}
+
+// TestPDFMergerGroupsByMessage 同主题、同发件人的上一封邮件的分卷已合并时，本封邮件的分卷仍然合并
+func TestPDFMergerGroupsByMessage(t *testing.T) {
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.MergePDFPattern = `_part\d+`
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	account := &models.EmailAccount{Name: "test", Email: "user@example.com", IMAPServer: "imap.example.com", IMAPPort: 993, UseSSL: true, IsActive: true}
+	if err := db.CreateEmailAccount(account); err != nil {
+		t.Fatalf("创建账户失败: %v", err)
+	}
+
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+	ds.pdfIndex = newPDFIndexer(ds)
+	ds.pdfMerge = newPDFMerger(ds)
+
+	previous := createMergeParts(t, db, &models.EmailMessage{EmailID: account.ID, MessageID: "<march@example.com>", Subject: "月度账单", Sender: "billing@example.com"}, t.TempDir())
+	ds.pdfMerge.check(previous[1].ID)
+
+	current := &models.EmailMessage{EmailID: account.ID, MessageID: "<april@example.com>", Subject: "月度账单", Sender: "billing@example.com"}
+	parts := createMergeParts(t, db, current, t.TempDir())
+	ds.pdfMerge.check(parts[1].ID)
+
+	for _, group := range [][]*models.DownloadTask{previous, parts} {
+		var mergedInto uint
+		for _, part := range group {
+			task, err := db.GetDownloadTaskByID(part.ID)
+			if err != nil {
+				t.Fatalf("读取任务失败: %v", err)
+			}
+			if task.MergedInto == 0 || (mergedInto != 0 && task.MergedInto != mergedInto) {
+				t.Fatalf("邮件 %d 的分卷 %s 合并到任务 %d，期望两个分卷合并到同一个任务", part.MessageID, part.FileName, task.MergedInto)
+			}
+			mergedInto = task.MergedInto
+		}
+
+		merged, err := db.GetDownloadTaskByID(mergedInto)
+		if err != nil {
+			t.Fatalf("读取合并任务失败: %v", err)
+		}
+		if merged.Type != models.TypeMerged || merged.MessageID != group[0].MessageID {
+			t.Errorf("合并任务类型为 %s、邮件为 %d，期望邮件 %d 的合并任务", merged.Type, merged.MessageID, group[0].MessageID)
+		}
+		data, err := os.ReadFile(merged.LocalPath)
+		if err != nil {
+			t.Fatalf("读取合并文件失败: %v", err)
+		}
+		if metadata, err := utils.ReadPDFMetadata(data); err != nil || metadata.PageCount != 2 {
+			t.Errorf("合并文件有 %d 页（%v），期望 2 页", metadata.PageCount, err)
+		}
+	}
+}
+
+// TestPDFMergerAddQueues 下载完成时只把任务加入合并队列，合并在后台协程中进行
+func TestPDFMergerAddQueues(t *testing.T) {
+	db := newTestDatabase(t)
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+	ds.pdfMerge = newPDFMerger(ds)
+
+	ds.pdfMerge.add(1)
+	if len(ds.pdfMerge.queue) != 0 {
+		t.Fatal("未配置合并规则时不应入队")
+	}
+
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.MergePDFPattern = `_part\d+`
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+	for i := 0; i < pdfMergeQueueSize+1; i++ {
+		ds.pdfMerge.add(uint(i + 1))
+	}
+	if len(ds.pdfMerge.queue) != pdfMergeQueueSize {
+		t.Errorf("队列中有 %d 个任务，期望 %d 个且已满时不阻塞", len(ds.pdfMerge.queue), pdfMergeQueueSize)
+	}
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"emaild/backend/database"
+	"emaild/backend/models"
+)
+
+// ProgressReporter 下载进度的接收方，下载服务把每条进度更新依次分发给所有已注册的接收方
+// Report 在任务的进度协程中同步调用，耗时的处理（如网络请求）应自行异步执行，避免拖慢进度处理
+type ProgressReporter interface {
+	Report(update ProgressUpdate) error
+}
+
+// ProgressReporterFunc 将普通函数适配为 ProgressReporter
+type ProgressReporterFunc func(update ProgressUpdate) error
+
+// Report 调用函数本身
+func (f ProgressReporterFunc) Report(update ProgressUpdate) error {
+	return f(update)
+}
+
+// dbProgressReporter 默认的接收方，将任务状态和进度写入数据库，任务结束时同步更新统计
+type dbProgressReporter struct {
+	ds *DownloadService
+}
+
+// Report 写入任务状态，最终的完成更新只是通知，状态已在下载函数读完数据时写入
+// 取消的最终更新不覆盖之后重新排队的任务
+func (r dbProgressReporter) Report(update ProgressUpdate) error {
+	if update.Final && update.Status == models.StatusCompleted {
+		return nil
+	}
+	if update.Final && update.Status == models.StatusCancelled {
+		_, err := r.ds.finishCancelledTask(update.TaskID, update.Error)
+		return err
+	}
+	return r.ds.updateTaskStatus(
+		update.TaskID,
+		update.Status,
+		update.Error,
+		update.DownloadedSize,
+		update.Progress,
+		update.Speed,
+	)
+}
+
+// speedSampleReporter 将下载中的进度更新记录为速度采样，采样间隔与进度更新一致（约500毫秒）
+// 没有收到数据的间隔记录为0，速度曲线可以显示停滞
+type speedSampleReporter struct {
+	db *database.Database
+}
+
+// Report 记录速度采样
+func (r speedSampleReporter) Report(update ProgressUpdate) error {
+	if update.Status != models.StatusDownloading {
+		return nil
+	}
+	return r.db.AddSpeedSample(update.TaskID, time.Now(), update.BytesPerSecond)
+}
+
+// webhookProgressReporter 任务最终完成或失败时向配置的 Webhook 发送通知，请求在单独的协程中发送
+// 只处理最终结果，通知发出时文件已保存到本地路径，每次下载只通知一次
+type webhookProgressReporter struct {
+	ds *DownloadService
+}
+
+// Report 发送任务结束通知
+func (r webhookProgressReporter) Report(update ProgressUpdate) error {
+	if !update.Final {
+		return nil
+	}
+	if update.Status == models.StatusCompleted || update.Status == models.StatusFailed {
+		r.ds.notifyWebhook(update.TaskID, update.Status, update.Error)
+	}
+	return nil
+}
+
+// metricsProgressReporter 统计本次运行以来的下载指标：最终完成或失败的任务数和下载中收到的字节数
+type metricsProgressReporter struct {
+	mutex      sync.Mutex
+	metrics    models.DownloadMetrics
+	downloaded map[uint]int64 // 下载中任务上一次更新时的已下载字节数
+}
+
+// newMetricsProgressReporter 创建下载指标接收方
+func newMetricsProgressReporter() *metricsProgressReporter {
+	return &metricsProgressReporter{downloaded: make(map[uint]int64)}
+}
+
+// Report 累计下载指标
+func (r *metricsProgressReporter) Report(update ProgressUpdate) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.metrics.ProgressUpdates++
+	switch update.Status {
+	case models.StatusDownloading:
+		// 续传的任务从续传位置开始计算，只累计本次运行收到的字节
+		if previous, ok := r.downloaded[update.TaskID]; ok && update.DownloadedSize > previous {
+			r.metrics.BytesDownloaded += update.DownloadedSize - previous
+		}
+		r.downloaded[update.TaskID] = update.DownloadedSize
+	case models.StatusCompleted:
+		if update.Final {
+			r.metrics.Completed++
+		}
+		delete(r.downloaded, update.TaskID)
+	case models.StatusFailed:
+		if update.Final {
+			r.metrics.Failed++
+		}
+		delete(r.downloaded, update.TaskID)
+	default:
+		delete(r.downloaded, update.TaskID)
+	}
+	return nil
+}
+
+// snapshot 返回当前的下载指标
+func (r *metricsProgressReporter) snapshot() models.DownloadMetrics {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.metrics
+}
+
+// GetMetrics 返回本次运行以来的下载指标
+func (ds *DownloadService) GetMetrics() models.DownloadMetrics {
+	if ds.metrics == nil {
+		return models.DownloadMetrics{}
+	}
+	return ds.metrics.snapshot()
+}
+
+// AddProgressReporter 注册进度接收方，对之后的进度更新生效
+// 数据库接收方总是第一个收到更新，之后注册的接收方收到更新时数据库中的任务状态已是最新
+func (ds *DownloadService) AddProgressReporter(reporter ProgressReporter) {
+	if reporter == nil {
+		return
+	}
+	ds.reporterMutex.Lock()
+	defer ds.reporterMutex.Unlock()
+	ds.reporters = append(ds.reporters, reporter)
+}
+
+// reportProgress 将进度更新分发给所有接收方，某个接收方出错不影响其他接收方
+func (ds *DownloadService) reportProgress(update ProgressUpdate) {
+	ds.reporterMutex.RLock()
+	reporters := ds.reporters
+	ds.reporterMutex.RUnlock()
+
+	for _, reporter := range reporters {
+		if err := reporter.Report(update); err != nil {
+			ds.logger.Warnf("任务 %d 的进度更新处理失败: %v", update.TaskID, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// mockProgressReporter 记录收到的进度更新，可以指定返回的错误
+type mockProgressReporter struct {
+	updates []ProgressUpdate
+	err     error
+}
+
+// Report 记录进度更新
+func (m *mockProgressReporter) Report(update ProgressUpdate) error {
+	m.updates = append(m.updates, update)
+	return m.err
+}
+
+// TestReportProgressFansOut 进度更新按注册顺序分发给所有接收方，某个接收方出错不影响其他接收方
+func TestReportProgressFansOut(t *testing.T) {
+	failing := &mockProgressReporter{err: errors.New("接收失败")}
+	second := &mockProgressReporter{}
+	ds := &DownloadService{logger: newTestLogger()}
+	ds.AddProgressReporter(failing)
+	ds.AddProgressReporter(nil)
+	ds.AddProgressReporter(second)
+
+	updates := []ProgressUpdate{
+		{TaskID: 1, Status: models.StatusDownloading, DownloadedSize: 100},
+		{TaskID: 1, Status: models.StatusCompleted, Progress: 100},
+	}
+	for _, update := range updates {
+		ds.reportProgress(update)
+	}
+
+	for name, reporter := range map[string]*mockProgressReporter{"出错的接收方": failing, "其他接收方": second} {
+		if len(reporter.updates) != len(updates) {
+			t.Fatalf("%s收到 %d 条更新，期望 %d 条", name, len(reporter.updates), len(updates))
+		}
+		for i := range updates {
+			if reporter.updates[i] != updates[i] {
+				t.Errorf("%s第 %d 条更新为 %+v，期望 %+v", name, i+1, reporter.updates[i], updates[i])
+			}
+		}
+	}
+}
+
+// TestMetricsProgressReporter 累计本次运行收到的字节数和结束的任务数
+func TestMetricsProgressReporter(t *testing.T) {
+	metrics := newMetricsProgressReporter()
+	for _, update := range []ProgressUpdate{
+		{TaskID: 1, Status: models.StatusDownloading, DownloadedSize: 500}, // 从续传位置开始，不计入
+		{TaskID: 1, Status: models.StatusDownloading, DownloadedSize: 800},
+		{TaskID: 2, Status: models.StatusDownloading, DownloadedSize: 0},
+		{TaskID: 2, Status: models.StatusDownloading, DownloadedSize: 200},
+		{TaskID: 1, Status: models.StatusCompleted, Progress: 100}, // 读完数据，校验前不计入
+		{TaskID: 1, Status: models.StatusCompleted, Progress: 100, Final: true},
+		{TaskID: 2, Status: models.StatusFailed, Error: "连接中断", Final: true},
+	} {
+		metrics.Report(update)
+	}
+
+	want := models.DownloadMetrics{Completed: 1, Failed: 1, BytesDownloaded: 500, ProgressUpdates: 7}
+	if got := metrics.snapshot(); got != want {
+		t.Errorf("下载指标为 %+v，期望 %+v", got, want)
+	}
+}
+
+// stallingReader 先返回一段数据，停顿指定时间后再返回剩余数据
+type stallingReader struct {
+	chunks [][]byte
+	stall  time.Duration
+}
+
+// Read 每次调用返回一段数据，第一段之后的每段前都停顿
+func (r *stallingReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	chunk := r.chunks[0]
+	r.chunks = r.chunks[1:]
+	if len(r.chunks) == 0 {
+		time.Sleep(r.stall)
+	}
+	return copy(p, chunk), nil
+}
+
+// TestDownloadWithProgressReportsStalls 读取阻塞期间发送速度为0的进度更新，并记录为速度采样
+func TestDownloadWithProgressReportsStalls(t *testing.T) {
+	db := newTestDatabase(t)
+	task := &models.DownloadTask{FileName: "report.pdf", Status: models.StatusDownloading, Type: models.TypeLink, FileSize: 20}
+	createTestTask(t, db, task)
+	// 上一次尝试留下的采样在重新开始时清除
+	if err := db.AddSpeedSample(task.ID, time.Now(), 1024); err != nil {
+		t.Fatalf("记录速度采样失败: %v", err)
+	}
+	if err := db.ClearSpeedSamples(task.ID); err != nil {
+		t.Fatalf("清除速度采样失败: %v", err)
+	}
+
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+	worker := &DownloadWorker{Task: task, Context: context.Background(), Progress: make(chan ProgressUpdate, 100)}
+	src := &stallingReader{chunks: [][]byte{make([]byte, 10), make([]byte, 10)}, stall: 3 * progressUpdateInterval}
+	if err := ds.downloadWithProgress(worker, src, io.Discard, 0); err != nil {
+		t.Fatalf("下载失败: %v", err)
+	}
+	close(worker.Progress)
+
+	var updates []ProgressUpdate
+	for update := range worker.Progress {
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 || updates[len(updates)-1].Status != models.StatusCompleted {
+		t.Fatalf("最后一条更新应为完成状态: %+v", updates)
+	}
+
+	reporter := speedSampleReporter{db: db}
+	stalls := 0
+	for _, update := range updates {
+		if update.Status == models.StatusDownloading && update.BytesPerSecond == 0 {
+			stalls++
+			if update.DownloadedSize != 10 {
+				t.Errorf("停滞时的已下载字节数为 %d，期望 10", update.DownloadedSize)
+			}
+		}
+		if err := reporter.Report(update); err != nil {
+			t.Fatalf("记录速度采样失败: %v", err)
+		}
+	}
+	if stalls == 0 {
+		t.Fatalf("停滞期间没有发送速度为0的更新: %+v", updates)
+	}
+
+	samples, err := db.GetSpeedSamples(task.ID)
+	if err != nil {
+		t.Fatalf("获取速度采样失败: %v", err)
+	}
+	zero := 0
+	for _, sample := range samples {
+		if sample.BytesPerSecond == 0 {
+			zero++
+		}
+	}
+	if zero != stalls {
+		t.Errorf("速度为0的采样有 %d 个，期望 %d 个", zero, stalls)
+	}
+	for _, sample := range samples {
+		if sample.BytesPerSecond == 1024 {
+			t.Errorf("上一次尝试的采样没有被清除: %+v", samples)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// proxyTestTimeout 测试代理时经代理请求的超时时间
+const proxyTestTimeout = 10 * time.Second
+
+// ParseProxyURL 解析下载链接使用的代理地址，支持 http、https 和 socks5，
+// 为空时返回 nil 表示不使用配置的代理
+func ParseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("代理地址格式错误: %v", err)
+	}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %q（支持 http、https、socks5）", proxyURL.Scheme)
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("代理地址缺少主机名: %s", raw)
+	}
+	return proxyURL, nil
+}
+
+// proxyAddress 代理服务器的 host:port，未写端口时按协议补上默认端口
+func proxyAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if port == "" {
+		switch strings.ToLower(proxyURL.Scheme) {
+		case "https":
+			port = "443"
+		case "socks5":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(proxyURL.Hostname(), port)
+}
+
+// proxyTestURL 测试代理时经代理请求的地址，只要代理转发了请求就算可用，不关心返回的内容
+var proxyTestURL = "https://www.bing.com/"
+
+// TestProxy 经代理发送一次请求，检查代理能否转发（HTTPS 地址经 HTTP 代理时会发送 CONNECT），
+// 保存设置前提示用户，避免下载时才失败
+func TestProxy(raw string) error {
+	return testProxy(raw, proxyTestURL)
+}
+
+// testProxy 经代理请求指定地址，代理要求认证或无法连接目标时返回错误
+func testProxy(raw, target string) error {
+	proxyURL, err := ParseProxyURL(raw)
+	if err != nil {
+		return err
+	}
+	if proxyURL == nil {
+		return fmt.Errorf("未填写代理地址")
+	}
+
+	transport := newLinkTransport(proxyURL, false)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Timeout:   proxyTestTimeout,
+		Transport: transport,
+		// 只测试代理，不跟随目标地址的重定向
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Head(target)
+	if err != nil {
+		return fmt.Errorf("无法通过代理服务器 %s 访问 %s: %v", proxyAddress(proxyURL), target, err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		return fmt.Errorf("代理服务器 %s 要求认证，请在代理地址中填写用户名和密码", proxyAddress(proxyURL))
+	case resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout:
+		return fmt.Errorf("代理服务器 %s 无法访问 %s: %s", proxyAddress(proxyURL), target, resp.Status)
+	}
+	return nil
+}
+
+// newLinkTransport 创建下载链接使用的 http.Transport
+// 配置了代理时所有请求经该代理发出，未配置时使用系统环境变量中的代理（HTTP_PROXY 等），
+// 任务下载和邮件正文中的链接下载都按此规则选择代理
+func newLinkTransport(proxyURL *url.URL, insecure bool) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	} else {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// linkTransportKey 缓存 Transport 的键，代理地址为空表示未配置代理
+type linkTransportKey struct {
+	proxy    string
+	insecure bool
+}
+
+// linkTransport 返回下载链接使用的 http.Transport，相同代理设置的下载共用一个，复用空闲连接
+// insecure 为 true 时不校验证书（邮件正文中的链接下载）
+// 代理设置修改后，旧设置的 Transport 关闭空闲连接后不再使用
+func (ds *DownloadService) linkTransport(insecure bool) *http.Transport {
+	var proxyURL *url.URL
+	if config, err := ds.db.GetConfig(); err == nil {
+		if proxyURL, err = ParseProxyURL(config.ProxyURL); err != nil {
+			ds.logger.Warnf("代理设置无效，下载不使用配置的代理: %v", err)
+		}
+	}
+
+	key := linkTransportKey{insecure: insecure}
+	if proxyURL != nil {
+		key.proxy = proxyURL.String()
+	}
+
+	ds.transportMutex.Lock()
+	defer ds.transportMutex.Unlock()
+
+	if transport, ok := ds.transports[key]; ok {
+		return transport
+	}
+	if ds.transports == nil {
+		ds.transports = make(map[linkTransportKey]*http.Transport)
+	}
+	for cachedKey, transport := range ds.transports {
+		if cachedKey.proxy != key.proxy {
+			transport.CloseIdleConnections()
+			delete(ds.transports, cachedKey)
+		}
+	}
+	transport := newLinkTransport(proxyURL, insecure)
+	ds.transports[key] = transport
+	return transport
+}
+
+// closeLinkTransports 关闭所有缓存的 Transport 的空闲连接，服务停止时调用
+func (ds *DownloadService) closeLinkTransports() {
+	ds.transportMutex.Lock()
+	defer ds.transportMutex.Unlock()
+	for key, transport := range ds.transports {
+		transport.CloseIdleConnections()
+		delete(ds.transports, key)
+	}
+}
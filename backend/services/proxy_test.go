@@ -0,0 +1,107 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTestProxyForwardsRequest 测试代理时经代理发送请求，代理要求认证或无法访问目标时返回错误
+func TestTestProxyForwardsRequest(t *testing.T) {
+	const target = "http://files.example.com/probe"
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "代理转发成功", status: http.StatusNoContent},
+		{name: "目标返回404也算代理可用", status: http.StatusNotFound},
+		{name: "代理要求认证", status: http.StatusProxyAuthRequired, wantErr: true},
+		{name: "代理无法访问目标", status: http.StatusBadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested string
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 经HTTP代理请求 http 地址时，请求行是完整的目标地址
+				requested = r.URL.String()
+				w.WriteHeader(tt.status)
+			}))
+			defer proxy.Close()
+
+			err := testProxy(proxy.URL, target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("testProxy 返回 %v，期望出错: %v", err, tt.wantErr)
+			}
+			if requested != target {
+				t.Errorf("代理收到的请求地址为 %q，期望 %q", requested, target)
+			}
+		})
+	}
+}
+
+// TestTestProxyConnect HTTPS 地址经HTTP代理时发送 CONNECT，代理拒绝时返回错误
+func TestTestProxyConnect(t *testing.T) {
+	var method, host string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, host = r.Method, r.Host
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer proxy.Close()
+
+	if err := testProxy(proxy.URL, "https://files.example.com/probe"); err == nil {
+		t.Fatal("代理拒绝 CONNECT 时测试应失败")
+	}
+	if method != http.MethodConnect || host != "files.example.com:443" {
+		t.Errorf("代理收到 %s %s，期望 CONNECT files.example.com:443", method, host)
+	}
+}
+
+// TestTestProxyUnreachable 代理服务器无法连接时返回错误
+func TestTestProxyUnreachable(t *testing.T) {
+	proxy := httptest.NewServer(http.NotFoundHandler())
+	proxyURL := proxy.URL
+	proxy.Close()
+
+	if err := testProxy(proxyURL, "http://files.example.com/probe"); err == nil {
+		t.Fatal("代理服务器已关闭，测试应失败")
+	}
+}
+
+// TestLinkTransportCachedPerProxy 相同代理设置的下载共用一个 Transport，代理设置修改后使用新的 Transport
+func TestLinkTransportCachedPerProxy(t *testing.T) {
+	db := newTestDatabase(t)
+	ds := &DownloadService{db: db, logger: newTestLogger()}
+
+	direct := ds.linkTransport(false)
+	if ds.linkTransport(false) != direct {
+		t.Fatal("相同代理设置应复用同一个 Transport")
+	}
+	insecure := ds.linkTransport(true)
+	if insecure == direct {
+		t.Fatal("不校验证书的下载应使用单独的 Transport")
+	}
+
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.ProxyURL = "http://127.0.0.1:8080"
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	proxied := ds.linkTransport(false)
+	if proxied == direct {
+		t.Fatal("代理设置修改后应使用新的 Transport")
+	}
+	if len(ds.transports) != 1 {
+		t.Errorf("缓存了 %d 个 Transport，旧代理设置的应已移除", len(ds.transports))
+	}
+	req, _ := http.NewRequest("GET", "https://files.example.com/a.pdf", nil)
+	if proxyURL, err := proxied.Proxy(req); err != nil || proxyURL == nil || proxyURL.Host != "127.0.0.1:8080" {
+		t.Errorf("请求使用的代理为 %v（%v），期望 127.0.0.1:8080", proxyURL, err)
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// discardInvalidFile 处理未通过PDF验证的文件：开启隔离时移入隔离目录，否则直接删除
+func (ds *DownloadService) discardInvalidFile(task *models.DownloadTask, path string, reason error) {
+	defer os.Remove(path)
+
+	if !ds.quarantineEnabled() {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 读取待隔离文件失败: %v", task.ID, err)
+		return
+	}
+	ds.saveToQuarantine(task, data, reason)
+}
+
+// quarantineData 开启隔离时将未通过验证的内存数据保存到隔离目录
+func (ds *DownloadService) quarantineData(task *models.DownloadTask, data []byte, reason error) {
+	if len(data) == 0 || !ds.quarantineEnabled() {
+		return
+	}
+	ds.saveToQuarantine(task, data, reason)
+}
+
+// quarantineEnabled 是否开启了隔离模式
+func (ds *DownloadService) quarantineEnabled() bool {
+	config, err := ds.db.GetConfig()
+	return err == nil && config.QuarantineInvalidFiles
+}
+
+// saveToQuarantine 以原始文件名保存到隔离目录，并在同名 .reason.txt 中记录失败原因和来源
+func (ds *DownloadService) saveToQuarantine(task *models.DownloadTask, data []byte, reason error) {
+	fileName := task.FileName
+	if fileName == "" {
+		fileName = filepath.Base(task.LocalPath)
+	}
+
+	savedPath, err := utils.SaveFile(data, fileName, utils.QuarantineDir())
+	if err != nil {
+		ds.logger.Warnf("任务 %d 隔离无效文件失败: %v", task.ID, err)
+		return
+	}
+
+	details := []string{
+		fmt.Sprintf("原因: %v", reason),
+		fmt.Sprintf("任务: %d", task.ID),
+		fmt.Sprintf("类型: %s", task.Type),
+		fmt.Sprintf("来源: %s", task.Source),
+		fmt.Sprintf("发件人: %s", task.Sender),
+		fmt.Sprintf("主题: %s", task.Subject),
+		fmt.Sprintf("大小: %d 字节", len(data)),
+		fmt.Sprintf("时间: %s", models.TimeToString(time.Now())),
+	}
+	if err := os.WriteFile(savedPath+".reason.txt", []byte(strings.Join(details, "\n")+"\n"), 0644); err != nil {
+		ds.logger.Warnf("任务 %d 写入隔离原因失败: %v", task.ID, err)
+	}
+
+	ds.taskWarnf(task.ID, "无效文件已移入隔离目录: %s", savedPath)
+}
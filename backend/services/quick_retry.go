@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"emaild/backend/utils"
+)
+
+const (
+	// defaultQuickRetryAttempts 默认的原地重试次数
+	defaultQuickRetryAttempts = 2
+	// MaxQuickRetryAttempts 可配置的原地重试次数上限
+	MaxQuickRetryAttempts = 5
+	// quickRetryBackoff 每次原地重试前的等待时间（按重试次数递增）
+	quickRetryBackoff = 2 * time.Second
+)
+
+// errStreamInterrupted 下载过程中读取数据失败（连接断开、超时），可以原地续传
+var errStreamInterrupted = errors.New("读取数据失败")
+
+// quickRetryAttempts 返回配置的原地重试次数，0表示不重试
+// 原地重试在同一次下载中重新连接并继续，与任务重新排队是两回事
+func (ds *DownloadService) quickRetryAttempts() int {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.QuickRetryAttempts < 0 {
+		return defaultQuickRetryAttempts
+	}
+	if config.QuickRetryAttempts > MaxQuickRetryAttempts {
+		return MaxQuickRetryAttempts
+	}
+	return config.QuickRetryAttempts
+}
+
+// waitQuickRetry 原地重试前等待一段时间，期间任务被取消时返回false
+func waitQuickRetry(ctx context.Context, attempt int) bool {
+	select {
+	case <-time.After(time.Duration(attempt) * quickRetryBackoff):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// resumeLinkDownload 链接下载中断后使用Range请求从已写入的位置继续下载
+// 服务器不支持Range时从头重新下载；返回 errStreamInterrupted 表示仍可再次重试
+func (ds *DownloadService) resumeLinkDownload(worker *DownloadWorker, source string, file *os.File, attempt int, cause error) error {
+	task := worker.Task
+	offset := atomic.LoadInt64(&worker.written)
+	ds.taskWarnf(task.ID, "下载中断（已下载 %s），第 %d 次原地重试: %v", utils.FormatBytes(offset), attempt, cause)
+	
+	if !waitQuickRetry(worker.Context, attempt) {
+		return cause
+	}
+	
+	req, err := ds.newLinkRequest(worker, source)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if worker.validator != "" {
+			req.Header.Set("If-Range", worker.validator)
+		}
+	}
+	
+	resp, err := worker.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errStreamInterrupted, err)
+	}
+	defer resp.Body.Close()
+	
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if start, _, _, err := utils.ParseContentRange(resp.Header.Get("Content-Range")); err != nil || start != offset {
+			return fmt.Errorf("续传响应的范围与已下载的位置不一致: %s", resp.Header.Get("Content-Range"))
+		}
+		ds.taskInfof(task.ID, "从 %s 处继续下载", utils.FormatBytes(offset))
+		
+	case resp.StatusCode == http.StatusOK:
+		// 服务器忽略了Range请求或文件已变化，丢弃已下载的部分重新开始
+		ds.taskInfof(task.ID, "服务器不支持断点续传或文件已变化，重新开始下载")
+		ds.rememberResumeValidator(worker, resp.Header)
+		offset = 0
+		if resp.ContentLength > 0 {
+			task.FileSize = resp.ContentLength
+		}
+		
+	case resp.StatusCode >= http.StatusInternalServerError:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%w: 服务器响应错误: %d", errStreamInterrupted, resp.StatusCode)
+		
+	default:
+		return fmt.Errorf("服务器响应错误: %d", resp.StatusCode)
+	}
+	
+	if err := file.Truncate(offset); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("写入文件失败: %v", err)
+	}
+	atomic.StoreInt64(&worker.written, offset)
+	
+	return ds.downloadWithProgress(worker, resp.Body, file, offset)
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"emaild/backend/utils"
+)
+
+// SetQuietHours 设置免打扰时段（HH:MM，可跨午夜），时段内后台不检查邮件、暂停IDLE推送；任一为空时不限制
+func (es *EmailService) SetQuietHours(start, end string) error {
+	es.quietMutex.Lock()
+	defer es.quietMutex.Unlock()
+
+	if start == "" || end == "" {
+		es.quietEnabled = false
+		return nil
+	}
+
+	startMinute, err := utils.ParseTimeOfDay(start)
+	if err != nil {
+		return err
+	}
+	endMinute, err := utils.ParseTimeOfDay(end)
+	if err != nil {
+		return err
+	}
+	if startMinute == endMinute {
+		return fmt.Errorf("免打扰时段的开始和结束时间不能相同")
+	}
+
+	es.quietEnabled = true
+	es.quietStart = startMinute
+	es.quietEnd = endMinute
+	es.logger.Infof("免打扰时段已设置为 %s - %s", start, end)
+	return nil
+}
+
+// InQuietHours 当前是否处于免打扰时段；手动检查不受影响
+func (es *EmailService) InQuietHours() bool {
+	es.quietMutex.RLock()
+	defer es.quietMutex.RUnlock()
+
+	if !es.quietEnabled {
+		return false
+	}
+	return utils.InTimeWindow(time.Now(), es.quietStart, es.quietEnd)
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"emaild/backend/database"
+	"emaild/backend/models"
+)
+
+// ValidateRule 整理并验证下载规则：字段和动作有效、正则表达式可以编译
+func ValidateRule(rule *models.Rule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	label := rule.Name
+	if label == "" {
+		label = rule.Pattern
+	}
+
+	switch rule.Field {
+	case models.RuleFieldSubject, models.RuleFieldSender, models.RuleFieldFileName:
+	default:
+		return fmt.Errorf("规则「%s」的匹配字段无效: %s", label, rule.Field)
+	}
+	switch rule.Action {
+	case models.RuleActionSkip, models.RuleActionDownload:
+	default:
+		return fmt.Errorf("规则「%s」的动作无效: %s", label, rule.Action)
+	}
+	if rule.Pattern == "" {
+		return fmt.Errorf("规则「%s」的匹配条件不能为空", label)
+	}
+	if _, err := compileRulePattern(rule.Pattern); err != nil {
+		return fmt.Errorf("规则「%s」的正则表达式无效: %v", label, err)
+	}
+	return nil
+}
+
+// compileRulePattern 编译规则的正则表达式，匹配时不区分大小写
+func compileRulePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// compiledRule 已编译正则表达式的下载规则
+type compiledRule struct {
+	rule    models.Rule
+	pattern *regexp.Regexp
+}
+
+// ruleSet 处理一封邮件时使用的启用中的规则，按顺序排列
+type ruleSet []compiledRule
+
+// loadRuleSet 读取并编译启用中的规则，读取失败或正则无效的规则跳过，不影响正常下载
+func loadRuleSet(db *database.Database) ruleSet {
+	rules, err := db.GetRules()
+	if err != nil {
+		return nil
+	}
+
+	var set ruleSet
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		pattern, err := compileRulePattern(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		set = append(set, compiledRule{rule: rule, pattern: pattern})
+	}
+	return set
+}
+
+// allows 判断是否为邮件中的文件创建下载任务，由第一条匹配的规则决定，不允许时返回规则名称
+func (set ruleSet) allows(accountID uint, subject, sender, fileName string) (bool, string) {
+	for _, c := range set {
+		if c.rule.AccountID != 0 && c.rule.AccountID != accountID {
+			continue
+		}
+
+		var value string
+		switch c.rule.Field {
+		case models.RuleFieldSubject:
+			value = subject
+		case models.RuleFieldSender:
+			value = sender
+		case models.RuleFieldFileName:
+			value = fileName
+		}
+		if c.pattern.MatchString(value) {
+			return c.rule.Action == models.RuleActionDownload, c.rule.Name
+		}
+	}
+	return true, ""
+}
+
+// applyRules 按下载规则过滤邮件中的文件来源，被规则跳过的文件不创建任务，也不计入提取结果
+func (es *EmailService) applyRules(account *models.EmailAccount, emailMsg *models.EmailMessage, sources []PDFSource) []PDFSource {
+	set := loadRuleSet(es.db)
+	if len(set) == 0 {
+		return sources
+	}
+
+	var kept []PDFSource
+	for _, source := range sources {
+		if allowed, rule := set.allows(account.ID, emailMsg.Subject, emailMsg.Sender, source.FileName); !allowed {
+			es.logger.Infof("账户%d邮件 %q 的文件 %s 匹配规则「%s」，不创建下载任务", account.ID, emailMsg.Subject, source.FileName, rule)
+			continue
+		}
+		kept = append(kept, source)
+	}
+	return kept
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// defaultScanWindowDays 默认搜索最近多少天的邮件
+	defaultScanWindowDays = 7
+	// MaxScanWindowDays 可配置的搜索时间窗口和首次检查导入天数的上限
+	MaxScanWindowDays = 365
+)
+
+// scanWindowDays 返回配置的搜索时间窗口（天），未配置或超出范围时使用默认值
+func scanWindowDays(config *models.AppConfig) int {
+	if config == nil || config.ScanWindowDays <= 0 || config.ScanWindowDays > MaxScanWindowDays {
+		return defaultScanWindowDays
+	}
+	return config.ScanWindowDays
+}
+
+// scanWindowSince 返回搜索时间窗口的起始时间
+func scanWindowSince(config *models.AppConfig) time.Time {
+	return time.Now().AddDate(0, 0, -scanWindowDays(config))
+}
+
+// needsInitialScan 账户是否需要在本次检查时导入更早的邮件
+// 首次检查导入与手动历史导入共用完成标记，已导入过的账户不再导入
+func needsInitialScan(config *models.AppConfig, account *models.EmailAccount) bool {
+	return config.InitialScanDays > 0 && config.InitialScanDays <= MaxScanWindowDays && !account.HistoricalImportDone
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"emaild/backend/models"
+)
+
+// ParseSenderPatterns 解析逗号（或换行、分号）分隔的发件人规则，忽略空项，统一转为小写
+func ParseSenderPatterns(list string) []string {
+	fields := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == '，' || r == ';' || r == '\n' || r == '\r'
+	})
+
+	var patterns []string
+	for _, field := range fields {
+		if pattern := strings.ToLower(strings.TrimSpace(field)); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// ValidateSenderPatterns 验证发件人规则列表中的通配符语法
+func ValidateSenderPatterns(list string) error {
+	for _, pattern := range ParseSenderPatterns(list) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("发件人规则无效: %s", pattern)
+		}
+	}
+	return nil
+}
+
+// matchSenderPattern 判断发件人地址是否匹配规则（均为小写）
+// 含 * 或 ? 的规则按通配符匹配整个地址（如 *@vendor.com）；@vendor.com 或 vendor.com 匹配该域名及其子域名；其他按完整地址匹配
+func matchSenderPattern(sender, pattern string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, _ := path.Match(pattern, sender)
+		return matched
+	}
+
+	at := strings.LastIndex(sender, "@")
+	if domain := strings.TrimPrefix(pattern, "@"); !strings.Contains(domain, "@") {
+		if at < 0 {
+			return false
+		}
+		senderDomain := sender[at+1:]
+		return senderDomain == domain || strings.HasSuffix(senderDomain, "."+domain)
+	}
+	return sender == pattern
+}
+
+// senderAllowed 按配置的黑白名单判断是否为该发件人的邮件自动创建下载任务，不允许时返回原因
+// 黑名单优先；白名单不为空时只允许匹配的发件人
+func senderAllowed(config *models.AppConfig, sender string) (bool, string) {
+	sender = strings.ToLower(strings.TrimSpace(sender))
+
+	for _, pattern := range ParseSenderPatterns(config.SenderBlocklist) {
+		if matchSenderPattern(sender, pattern) {
+			return false, fmt.Sprintf("匹配发件人黑名单 %s", pattern)
+		}
+	}
+
+	allowlist := ParseSenderPatterns(config.SenderAllowlist)
+	if len(allowlist) == 0 {
+		return true, ""
+	}
+	for _, pattern := range allowlist {
+		if matchSenderPattern(sender, pattern) {
+			return true, ""
+		}
+	}
+	return false, "不在发件人白名单中"
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+)
+
+// ServerQuirks 部分IMAP服务器需要的兼容处理，连接建立后按服务器地址和能力确定
+type ServerQuirks struct {
+	SendID              bool // 登录后发送 ID 命令（RFC 2971）表明客户端身份，否则服务器拒绝打开文件夹
+	TolerateUIDMismatch bool // 服务器返回的UID与请求的不一致但邮件本身正确时直接使用，不重新定位
+	AvoidFullBody       bool // 不获取 BODY[] 整封邮件（大邮件容易超时或被截断），只获取需要的部分
+	UseExamine          bool // 总是只读打开文件夹（EXAMINE），服务器对读写打开有限制或会改变已读状态
+}
+
+// merge 合并另一组兼容处理，任一规则开启的处理都会开启
+func (q ServerQuirks) merge(other ServerQuirks) ServerQuirks {
+	q.SendID = q.SendID || other.SendID
+	q.TolerateUIDMismatch = q.TolerateUIDMismatch || other.TolerateUIDMismatch
+	q.AvoidFullBody = q.AvoidFullBody || other.AvoidFullBody
+	q.UseExamine = q.UseExamine || other.UseExamine
+	return q
+}
+
+// ServerQuirkRule 兼容处理规则：服务器地址匹配任一域名，或服务器声明了指定能力时生效
+type ServerQuirkRule struct {
+	Name       string       // 规则名称，用于日志
+	Domains    []string     // IMAP服务器地址等于或以 "."+域名 结尾时匹配
+	Capability string       // 服务器声明该能力时匹配，为空表示不按能力匹配
+	Quirks     ServerQuirks // 匹配时开启的处理
+}
+
+// matches 规则是否适用于该服务器
+func (r ServerQuirkRule) matches(host string, capabilities map[string]bool) bool {
+	for _, domain := range r.Domains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return r.Capability != "" && capabilities[strings.ToUpper(r.Capability)]
+}
+
+// serverQuirkRules 已知服务商的兼容处理，其他服务器遇到问题时可通过 RegisterServerQuirkRule 追加
+var (
+	serverQuirkRules = []ServerQuirkRule{
+		{
+			// 网易邮箱未发送ID时 SELECT 返回 "Unsafe Login"
+			Name:    "网易邮箱",
+			Domains: []string{"163.com", "126.com", "yeah.net", "188.com", "vip.163.com", "vip.126.com"},
+			Quirks:  ServerQuirks{SendID: true},
+		},
+		{
+			// QQ邮箱获取大邮件的整封 BODY[] 很慢，容易超过下载期限
+			Name:    "QQ邮箱",
+			Domains: []string{"qq.com", "exmail.qq.com", "foxmail.com"},
+			Quirks:  ServerQuirks{AvoidFullBody: true},
+		},
+		{
+			// 服务器声明支持ID时发送客户端标识，与常见邮件客户端的行为一致
+			Name:       "ID扩展",
+			Capability: "ID",
+			Quirks:     ServerQuirks{SendID: true},
+		},
+	}
+	serverQuirkMutex sync.RWMutex
+)
+
+// RegisterServerQuirkRule 追加兼容处理规则，对之后建立的连接生效
+func RegisterServerQuirkRule(rule ServerQuirkRule) {
+	serverQuirkMutex.Lock()
+	defer serverQuirkMutex.Unlock()
+	serverQuirkRules = append(serverQuirkRules, rule)
+}
+
+// LookupServerQuirks 返回服务器适用的兼容处理和匹配的规则名称
+func LookupServerQuirks(host string, capabilities map[string]bool) (ServerQuirks, []string) {
+	host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+
+	serverQuirkMutex.RLock()
+	defer serverQuirkMutex.RUnlock()
+
+	var quirks ServerQuirks
+	var names []string
+	for _, rule := range serverQuirkRules {
+		if rule.matches(host, capabilities) {
+			quirks = quirks.merge(rule.Quirks)
+			names = append(names, rule.Name)
+		}
+	}
+	return quirks, names
+}
+
+// idCommand RFC 2971 ID命令，go-imap未提供
+type idCommand struct {
+	fields []interface{}
+}
+
+// Command 生成 ID ("name" "emaild" "version" "x.y.z") 命令
+func (cmd *idCommand) Command() *imap.Command {
+	return &imap.Command{Name: "ID", Arguments: []interface{}{cmd.fields}}
+}
+
+// sendClientID 向服务器发送客户端标识，失败时只记录日志，由后续的文件夹操作给出明确错误
+func (es *EmailService) sendClientID(c *client.Client, host string) {
+	cmd := &idCommand{fields: []interface{}{"name", "emaild", "version", telemetryAppVersion, "vendor", "emaild"}}
+	status, err := c.Execute(cmd, nil)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		es.logger.Warnf("向 %s 发送ID命令失败: %v", host, err)
+	}
+}
+
+// resolveServerQuirks 登录后读取服务器能力并确定兼容处理，需要时发送ID命令
+func (es *EmailService) resolveServerQuirks(c *client.Client, host string) ServerQuirks {
+	capabilities, err := c.Capability()
+	if err != nil {
+		es.logger.Debugf("读取 %s 的服务器能力失败: %v", host, err)
+		capabilities = nil
+	}
+
+	quirks, names := LookupServerQuirks(host, capabilities)
+	if len(names) > 0 {
+		es.logger.Debugf("服务器 %s 适用兼容处理 %v: %+v", host, names, quirks)
+	}
+	if quirks.SendID {
+		es.sendClientID(c, host)
+	}
+	return quirks
+}
@@ -0,0 +1,25 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"emaild/backend/utils"
+)
+
+// errFileTooLarge 下载的文件超过配置的大小上限，不续传也不自动重试
+var errFileTooLarge = errors.New("文件大小超过限制")
+
+// maxFileSize 返回链接下载的文件大小上限（字节），0表示不限制
+func (ds *DownloadService) maxFileSize() int64 {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.MaxFileSizeMB <= 0 {
+		return 0
+	}
+	return int64(config.MaxFileSizeMB) * 1024 * 1024
+}
+
+// fileTooLargeError 超过大小上限时的错误，size 为已下载或服务器声明的大小
+func fileTooLargeError(size, limit int64) error {
+	return fmt.Errorf("%w: 已达到 %s，上限为 %s", errFileTooLarge, utils.FormatBytes(size), utils.FormatBytes(limit))
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"emaild/backend/models"
+)
+
+// ErrSSHHostKeyChanged SSH跳板机的主机密钥与账户记录的指纹不一致
+var ErrSSHHostKeyChanged = errors.New("SSH跳板机主机密钥已变更，可能存在中间人攻击")
+
+// ErrSSHHostKeyUnknown 账户尚未确认SSH跳板机的主机密钥指纹
+var ErrSSHHostKeyUnknown = errors.New("尚未确认SSH跳板机的主机密钥")
+
+// errSSHHostKeyRead 读取主机密钥后中止握手，不进行认证
+var errSSHHostKeyRead = errors.New("已读取主机密钥")
+
+// sshDialTimeout 连接SSH跳板机的超时时间
+const sshDialTimeout = 15 * time.Second
+
+// sshTunnel 本地端口转发：监听 127.0.0.1 的随机端口，把每个连接经SSH转发到IMAP服务器
+type sshTunnel struct {
+	client    *ssh.Client
+	listener  net.Listener
+	remote    string
+	closeOnce sync.Once
+}
+
+// openSSHTunnel 为账户建立SSH隧道，返回的隧道在连接关闭时一并关闭
+func (es *EmailService) openSSHTunnel(account *models.EmailAccount) (*sshTunnel, error) {
+	config, err := sshClientConfig(account)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel := &sshTunnel{remote: fmt.Sprintf("%s:%d", account.IMAPServer, account.IMAPPort)}
+	expected := strings.TrimSpace(account.SSHHostKey)
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		return verifySSHHostKey(expected, key)
+	}
+
+	sshAddr := sshAddress(account)
+	es.logger.Infof("正在通过SSH隧道 %s@%s 连接 %s", account.SSHUser, sshAddr, tunnel.remote)
+	client, err := ssh.Dial("tcp", sshAddr, config)
+	if err != nil {
+		return nil, fmt.Errorf("连接SSH跳板机失败 %s: %w", sshAddr, err)
+	}
+	tunnel.client = client
+
+	// 先确认跳板机能访问IMAP服务器，避免隧道建立后才在IMAP握手时失败
+	probe, err := client.Dial("tcp", tunnel.remote)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SSH跳板机无法访问IMAP服务器 %s: %v", tunnel.remote, err)
+	}
+	probe.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("创建SSH隧道本地端口失败: %v", err)
+	}
+	tunnel.listener = listener
+
+	go tunnel.serve(es)
+	return tunnel, nil
+}
+
+// verifySSHHostKey 校验跳板机的主机密钥与用户确认的指纹一致，在认证之前调用
+// 未确认或不一致时中止握手，不会向跳板机发送认证信息
+func verifySSHHostKey(expected string, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	if expected == "" {
+		return fmt.Errorf("%w（当前: %s），请核对并确认指纹后再连接", ErrSSHHostKeyUnknown, fingerprint)
+	}
+	if expected != fingerprint {
+		return fmt.Errorf("%w（已记录: %s，当前: %s）", ErrSSHHostKeyChanged, expected, fingerprint)
+	}
+	return nil
+}
+
+// SSHHostKeyFingerprint 连接账户的SSH跳板机并返回其主机密钥指纹，不进行认证
+// 指纹由用户核对后保存到账户中，之后建立隧道时只接受该指纹
+func (es *EmailService) SSHHostKeyFingerprint(account *models.EmailAccount) (string, error) {
+	if strings.TrimSpace(account.SSHHost) == "" {
+		return "", fmt.Errorf("SSH跳板机地址不能为空")
+	}
+
+	var fingerprint string
+	config := &ssh.ClientConfig{
+		User:    strings.TrimSpace(account.SSHUser),
+		Timeout: sshDialTimeout,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint = ssh.FingerprintSHA256(key)
+			return errSSHHostKeyRead
+		},
+	}
+
+	sshAddr := sshAddress(account)
+	client, err := ssh.Dial("tcp", sshAddr, config)
+	if err == nil {
+		client.Close()
+	}
+	if fingerprint == "" {
+		return "", fmt.Errorf("读取SSH跳板机主机密钥失败 %s: %v", sshAddr, err)
+	}
+	es.logger.Infof("SSH跳板机 %s 的主机密钥指纹: %s", sshAddr, fingerprint)
+	return fingerprint, nil
+}
+
+// sshClientConfig 根据账户设置创建SSH客户端配置（使用私钥认证）
+func sshClientConfig(account *models.EmailAccount) (*ssh.ClientConfig, error) {
+	if strings.TrimSpace(account.SSHUser) == "" {
+		return nil, fmt.Errorf("SSH用户名不能为空")
+	}
+	if strings.TrimSpace(account.SSHKeyPath) == "" {
+		return nil, fmt.Errorf("SSH私钥文件不能为空")
+	}
+
+	keyData, err := os.ReadFile(strings.TrimSpace(account.SSHKeyPath))
+	if err != nil {
+		return nil, fmt.Errorf("读取SSH私钥失败: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("SSH私钥设置了密码，暂不支持带密码的私钥")
+		}
+		return nil, fmt.Errorf("解析SSH私钥失败: %v", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:    strings.TrimSpace(account.SSHUser),
+		Auth:    []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		Timeout: sshDialTimeout,
+	}, nil
+}
+
+// sshAddress 返回账户SSH跳板机的连接地址
+func sshAddress(account *models.EmailAccount) string {
+	return net.JoinHostPort(strings.TrimSpace(account.SSHHost), fmt.Sprintf("%d", sshPort(account)))
+}
+
+// sshPort 返回账户的SSH端口，未设置时为默认端口
+func sshPort(account *models.EmailAccount) int {
+	if account.SSHPort <= 0 {
+		return models.DefaultSSHPort
+	}
+	return account.SSHPort
+}
+
+// addr 隧道的本地地址，IMAP客户端连接该地址
+func (t *sshTunnel) addr() string {
+	return t.listener.Addr().String()
+}
+
+// serve 接受本地连接并逐个转发，监听关闭后退出
+func (t *sshTunnel) serve(es *EmailService) {
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		remote, err := t.client.Dial("tcp", t.remote)
+		if err != nil {
+			es.logger.Warnf("SSH隧道转发到 %s 失败: %v", t.remote, err)
+			local.Close()
+			continue
+		}
+		go pipeTunnel(local, remote)
+	}
+}
+
+// pipeTunnel 在本地连接和远端连接之间双向复制数据，任一方向结束时关闭两端
+func pipeTunnel(local, remote net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		local.Close()
+		remote.Close()
+	}
+	go func() {
+		io.Copy(remote, local)
+		once.Do(closeBoth)
+	}()
+	io.Copy(local, remote)
+	once.Do(closeBoth)
+}
+
+// close 关闭本地监听和SSH连接，正在转发的连接随之断开
+func (t *sshTunnel) close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() {
+		if t.listener != nil {
+			t.listener.Close()
+		}
+		if t.client != nil {
+			t.client.Close()
+		}
+	})
+}
+
+// NormalizeSSHTunnel 规范化账户的SSH隧道设置，未填写跳板机地址时视为不使用隧道
+// 使用隧道时需要用户已确认跳板机的主机密钥指纹
+func NormalizeSSHTunnel(account *models.EmailAccount) error {
+	account.SSHHost = strings.TrimSpace(account.SSHHost)
+	account.SSHUser = strings.TrimSpace(account.SSHUser)
+	account.SSHKeyPath = strings.TrimSpace(account.SSHKeyPath)
+	account.SSHHostKey = strings.TrimSpace(account.SSHHostKey)
+	if account.SSHHost == "" {
+		account.SSHPort = models.DefaultSSHPort
+		account.SSHHostKey = ""
+		return nil
+	}
+
+	if account.SSHPort == 0 {
+		account.SSHPort = models.DefaultSSHPort
+	}
+	if account.SSHPort < 1 || account.SSHPort > 65535 {
+		return fmt.Errorf("SSH端口无效: %d", account.SSHPort)
+	}
+	if account.SSHUser == "" || account.SSHKeyPath == "" {
+		return fmt.Errorf("使用SSH隧道时SSH用户名和私钥文件不能为空")
+	}
+	if account.SSHHostKey == "" {
+		return fmt.Errorf("使用SSH隧道时需要先获取并确认跳板机的主机密钥指纹")
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"emaild/backend/models"
+)
+
+// testSSHServer 测试用的SSH跳板机，只接受 clientKey 认证，支持转发到本机的 direct-tcpip 通道
+type testSSHServer struct {
+	addr      string
+	hostKey   ssh.PublicKey
+	authTries atomic.Int32 // 收到的认证请求数
+}
+
+// newTestSSHServer 启动测试SSH跳板机，返回跳板机和客户端私钥文件路径
+func newTestSSHServer(t *testing.T) (*testSSHServer, string) {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成主机密钥失败: %v", err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("创建主机密钥失败: %v", err)
+	}
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("生成客户端密钥失败: %v", err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatalf("创建客户端公钥失败: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatalf("编码客户端私钥失败: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("写入客户端私钥失败: %v", err)
+	}
+
+	server := &testSSHServer{hostKey: hostSigner.PublicKey()}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			server.authTries.Add(1)
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, fmt.Errorf("未授权的密钥")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动测试SSH服务器失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server, keyPath
+}
+
+// serve 完成SSH握手后把 direct-tcpip 通道转发到请求的地址
+func (s *testSSHServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "不支持的通道")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.Prohibited, "无效的转发请求")
+			continue
+		}
+		remote, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			remote.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			defer channel.Close()
+			defer remote.Close()
+			go io.Copy(remote, channel)
+			io.Copy(channel, remote)
+		}()
+	}
+}
+
+// TestSSHTunnelHostKey 只接受用户确认的主机密钥：未确认或不一致时在认证前拒绝，一致时建立隧道
+func TestSSHTunnelHostKey(t *testing.T) {
+	server, keyPath := newTestSSHServer(t)
+
+	// 隧道转发的目标，只回显收到的数据
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动回显服务器失败: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(server.addr)
+	var sshPort int
+	fmt.Sscan(port, &sshPort)
+	echoAddr := echo.Addr().(*net.TCPAddr)
+	newAccount := func(hostKey string) *models.EmailAccount {
+		return &models.EmailAccount{
+			Email: "user@example.com", IMAPServer: "127.0.0.1", IMAPPort: echoAddr.Port,
+			SSHHost: host, SSHPort: sshPort, SSHUser: "tunnel", SSHKeyPath: keyPath, SSHHostKey: hostKey,
+		}
+	}
+	es := NewEmailService(nil, nil, newTestLogger())
+
+	// 读取指纹时不进行认证
+	fingerprint, err := es.SSHHostKeyFingerprint(newAccount(""))
+	if err != nil {
+		t.Fatalf("读取主机密钥指纹失败: %v", err)
+	}
+	if fingerprint != ssh.FingerprintSHA256(server.hostKey) {
+		t.Fatalf("读取的指纹为 %s，期望 %s", fingerprint, ssh.FingerprintSHA256(server.hostKey))
+	}
+
+	tests := []struct {
+		name    string
+		hostKey string
+		wantErr error
+	}{
+		{name: "尚未确认", hostKey: "", wantErr: ErrSSHHostKeyUnknown},
+		{name: "主机密钥不一致", hostKey: "SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", wantErr: ErrSSHHostKeyChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := server.authTries.Load()
+			account := newAccount(tt.hostKey)
+			if _, err := es.openSSHTunnel(account); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("建立隧道返回 %v，期望 %v", err, tt.wantErr)
+			}
+			if server.authTries.Load() != before {
+				t.Error("主机密钥未通过校验时不应向跳板机认证")
+			}
+			if account.SSHHostKey != tt.hostKey {
+				t.Errorf("账户的主机密钥被改为 %q", account.SSHHostKey)
+			}
+		})
+	}
+
+	// 确认的指纹一致时隧道可以转发数据
+	tunnel, err := es.openSSHTunnel(newAccount(fingerprint))
+	if err != nil {
+		t.Fatalf("建立隧道失败: %v", err)
+	}
+	defer tunnel.close()
+	conn, err := net.Dial("tcp", tunnel.addr())
+	if err != nil {
+		t.Fatalf("连接隧道本地端口失败: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "ping\n")
+	reply := make([]byte, 5)
+	if _, err := io.ReadFull(conn, reply); err != nil || !strings.HasPrefix(string(reply), "ping") {
+		t.Errorf("经隧道收到 %q（%v），期望回显 ping", reply, err)
+	}
+}
+
+// TestNormalizeSSHTunnelRequiresHostKey 使用SSH隧道时必须填写已确认的主机密钥指纹
+func TestNormalizeSSHTunnelRequiresHostKey(t *testing.T) {
+	account := &models.EmailAccount{SSHHost: "bastion.example.com", SSHUser: "tunnel", SSHKeyPath: "/tmp/id_ed25519"}
+	if err := NormalizeSSHTunnel(account); err == nil {
+		t.Error("未确认主机密钥时应返回错误")
+	}
+
+	account.SSHHostKey = " SHA256:abc "
+	if err := NormalizeSSHTunnel(account); err != nil || account.SSHHostKey != "SHA256:abc" {
+		t.Errorf("返回 %v，主机密钥为 %q", err, account.SSHHostKey)
+	}
+
+	direct := &models.EmailAccount{SSHHostKey: "SHA256:abc"}
+	if err := NormalizeSSHTunnel(direct); err != nil || direct.SSHHostKey != "" {
+		t.Errorf("不使用隧道时应清除主机密钥，返回 %v，主机密钥为 %q", err, direct.SSHHostKey)
+	}
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"time"
+
+	"emaild/backend/models"
+
+	"github.com/emersion/go-imap"
+)
+
+// messageReceivedDate 返回邮件的接收时间：优先使用服务器记录的内部日期，缺失时使用信头日期
+func messageReceivedDate(msg *imap.Message) time.Time {
+	if !msg.InternalDate.IsZero() {
+		return msg.InternalDate.Local()
+	}
+	if msg.Envelope != nil && !msg.Envelope.Date.IsZero() {
+		return msg.Envelope.Date.Local()
+	}
+	return time.Time{}
+}
+
+// statisticsByReceivedDate 下载统计是否按邮件接收日期归档
+func (ds *DownloadService) statisticsByReceivedDate() bool {
+	config, err := ds.db.GetConfig()
+	return err == nil && config.StatisticsDateSource == models.StatisticsDateReceived
+}
+
+// recordTaskStatistics 任务结束后更新其所属日期的统计行
+// 按完成日期统计时更新当天，按接收日期统计时更新邮件接收的那一天
+func (ds *DownloadService) recordTaskStatistics(taskID uint) {
+	byReceivedDate := ds.statisticsByReceivedDate()
+	date := time.Now()
+	if byReceivedDate {
+		if task, err := ds.db.GetDownloadTaskByID(taskID); err == nil && task.EmailDate != "" {
+			if received, err := models.StringToTime(task.EmailDate); err == nil && !received.IsZero() {
+				date = received
+			}
+		}
+	}
+
+	if err := ds.db.RecordDailyStatistics(date, byReceivedDate); err != nil {
+		ds.logger.Errorf("更新任务 %d 的下载统计失败: %v", taskID, err)
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emaild/backend/models"
+)
+
+// DeleteTask 删除下载任务，deleteFile 为true时同时删除已下载的文件（包括ZIP附件解压出的文件）
+// 正在下载的任务需要先取消
+func (ds *DownloadService) DeleteTask(taskID uint, deleteFile bool) error {
+	ds.workerMutex.RLock()
+	_, running := ds.workers[taskID]
+	ds.workerMutex.RUnlock()
+	if running {
+		return fmt.Errorf("任务 %d 正在下载，请先取消后再删除", taskID)
+	}
+
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		return fmt.Errorf("获取任务失败: %v", err)
+	}
+
+	// 文件记录随任务一起删除，需要先取出
+	var files []models.TaskFile
+	if deleteFile && task.IsContainer {
+		if files, err = ds.db.GetTaskFiles(taskID); err != nil {
+			ds.logger.Warnf("获取任务 %d 的解压文件失败: %v", taskID, err)
+		}
+	}
+
+	if err := ds.db.DeleteDownloadTask(taskID); err != nil {
+		return err
+	}
+	ds.logger.Infof("已删除任务 %d: %s", taskID, task.FileName)
+
+	if deleteFile {
+		ds.removeTaskFiles(task, files)
+	}
+	return nil
+}
+
+// removeTaskFiles 删除任务的本地文件和未完成的临时文件，以及ZIP附件解压出的文件和空的解压目录
+func (ds *DownloadService) removeTaskFiles(task *models.DownloadTask, files []models.TaskFile) {
+	if task.LocalPath == "" {
+		return
+	}
+
+	paths := []string{task.LocalPath + tempFileSuffix}
+	if ds.fileInUse(task.LocalPath) {
+		// 去重后其他任务仍指向该文件，保留文件
+		ds.logger.Infof("文件 %s 仍被其他任务使用，未删除", task.LocalPath)
+	} else {
+		paths = append(paths, task.LocalPath)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			ds.logger.Warnf("删除文件失败 %s: %v", path, err)
+		}
+	}
+
+	for _, file := range files {
+		if err := os.Remove(file.LocalPath); err != nil && !os.IsNotExist(err) {
+			ds.logger.Warnf("删除解压文件失败 %s: %v", file.LocalPath, err)
+		}
+	}
+	if len(files) > 0 {
+		// 目录中还有其他文件时删除失败，保留目录
+		os.Remove(strings.TrimSuffix(task.LocalPath, filepath.Ext(task.LocalPath)))
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// exportedTask 导出的一条下载任务
+type exportedTask struct {
+	ID        uint   `json:"id"`
+	Subject   string `json:"subject"`
+	Sender    string `json:"sender"`
+	Account   string `json:"account"`
+	FileName  string `json:"file_name"`
+	FileSize  int64  `json:"file_size"`
+	Status    string `json:"status"`
+	Type      string `json:"type"`
+	LocalPath string `json:"local_path"`
+	Error     string `json:"error,omitempty"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// TaskExportFileName 返回任务明细导出的文件名，from、to 为日期字符串，为空表示不限制
+func TaskExportFileName(from, to, format string) string {
+	if from == "" {
+		from = "开始"
+	}
+	if to == "" {
+		to = time.Now().Format("2006-01-02")
+	}
+	return fmt.Sprintf("下载任务_%s_%s.%s", from, to, format)
+}
+
+// ExportTasks 把创建时间在 [from, to) 内的下载任务写入 path（CSV或JSON），返回导出的任务数，零值表示不限制
+func (ds *DownloadService) ExportTasks(format string, from, to time.Time, path string) (int, error) {
+	if format != models.ReportFormatCSV && format != models.ReportFormatJSON {
+		return 0, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+
+	tasks, err := ds.db.GetDownloadTasksCreatedBetween(from, to)
+	if err != nil {
+		return 0, fmt.Errorf("获取下载任务失败: %v", err)
+	}
+
+	rows := make([]exportedTask, 0, len(tasks))
+	for _, task := range tasks {
+		rows = append(rows, exportedTask{
+			ID:        task.ID,
+			Subject:   task.Subject,
+			Sender:    task.Sender,
+			Account:   task.EmailAccount.Email,
+			FileName:  task.FileName,
+			FileSize:  task.FileSize,
+			Status:    string(task.Status),
+			Type:      string(task.Type),
+			LocalPath: task.LocalPath,
+			Error:     task.Error,
+			CreatedAt: task.CreatedAt,
+			UpdatedAt: task.UpdatedAt,
+		})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("创建导出文件失败: %v", err)
+	}
+	defer file.Close()
+
+	switch format {
+	case models.ReportFormatCSV:
+		err = writeTasksCSV(file, rows)
+	case models.ReportFormatJSON:
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(rows)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("写入导出文件失败: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("写入导出文件失败: %v", err)
+	}
+
+	ds.logger.Infof("已导出 %d 个下载任务: %s", len(rows), path)
+	return len(rows), nil
+}
+
+// csvSafe 防止CSV公式注入：主题、发件人等来自邮件的内容以 = + - @（或制表符、回车）开头时，
+// Excel 等软件会当作公式执行，在前面加单引号使其按文本显示
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// writeTasksCSV 以CSV格式写入任务明细，带UTF-8 BOM以便Excel正确识别中文
+func writeTasksCSV(file *os.File, rows []exportedTask) error {
+	if _, err := file.WriteString("\ufeff"); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"ID", "主题", "发件人", "邮箱账户", "文件名", "大小（字节）", "状态", "类型", "本地路径", "错误", "创建时间", "更新时间"})
+	for _, row := range rows {
+		writer.Write([]string{
+			fmt.Sprintf("%d", row.ID),
+			csvSafe(row.Subject),
+			csvSafe(row.Sender),
+			csvSafe(row.Account),
+			csvSafe(row.FileName),
+			fmt.Sprintf("%d", row.FileSize),
+			row.Status,
+			row.Type,
+			csvSafe(row.LocalPath),
+			csvSafe(row.Error),
+			row.CreatedAt,
+			row.UpdatedAt,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
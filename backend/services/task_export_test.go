@@ -0,0 +1,64 @@
+package services
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCSVSafe 以公式字符开头的内容加单引号按文本显示，其他内容不变
+func TestCSVSafe(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"+1+cmd", "'+1+cmd"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"三月发票", "三月发票"},
+		{"billing@example.com", "billing@example.com"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := csvSafe(tt.value); got != tt.want {
+			t.Errorf("csvSafe(%q) = %q，期望 %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestWriteTasksCSVEscapesFormulas 导出的主题和发件人以公式字符开头时按文本写入
+func TestWriteTasksCSVEscapesFormulas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("创建文件失败: %v", err)
+	}
+	rows := []exportedTask{{ID: 1, Subject: "=cmd|' /C calc'!A0", Sender: "@attacker", FileName: "invoice.pdf", Status: "completed", Type: "attachment"}}
+	if err := writeTasksCSV(file, rows); err != nil {
+		t.Fatalf("写入CSV失败: %v", err)
+	}
+	file.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取CSV失败: %v", err)
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatalf("解析CSV失败: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("CSV有 %d 行，期望 2 行", len(records))
+	}
+	if subject, sender := records[1][1], records[1][2]; subject != "'=cmd|' /C calc'!A0" || sender != "'@attacker" {
+		t.Errorf("主题为 %q、发件人为 %q，应加单引号按文本显示", subject, sender)
+	}
+	if records[1][4] != "invoice.pdf" {
+		t.Errorf("文件名为 %q，普通内容不应改变", records[1][4])
+	}
+}
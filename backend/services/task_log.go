@@ -0,0 +1,71 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"emaild/backend/models"
+)
+
+// taskLogLevels 日志级别对应的任务日志级别
+var taskLogLevels = map[logrus.Level]string{
+	logrus.InfoLevel:  models.TaskLogInfo,
+	logrus.WarnLevel:  models.TaskLogWarn,
+	logrus.ErrorLevel: models.TaskLogError,
+}
+
+// taskInfof 记录任务事件：写入服务日志，同时保存到任务日志供界面查看任务经过
+func (ds *DownloadService) taskInfof(taskID uint, format string, args ...interface{}) {
+	ds.taskLog(taskID, logrus.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// taskWarnf 记录任务的警告事件，如连接中断后重试、续传失败后重新下载
+func (ds *DownloadService) taskWarnf(taskID uint, format string, args ...interface{}) {
+	ds.taskLog(taskID, logrus.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+// taskLog 写入服务日志并保存到任务日志
+func (ds *DownloadService) taskLog(taskID uint, level logrus.Level, message string) {
+	ds.logger.Logf(level, "任务 %d %s", taskID, message)
+	ds.saveTaskLog(taskID, level, message)
+}
+
+// saveTaskLog 只保存到任务日志，用于调用方已另外写入服务日志的事件；保存失败不影响下载
+func (ds *DownloadService) saveTaskLog(taskID uint, level logrus.Level, message string) {
+	name, ok := taskLogLevels[level]
+	if !ok {
+		name = models.TaskLogInfo
+	}
+	if err := ds.db.AddTaskLog(taskID, name, message); err != nil {
+		ds.logger.Debugf("任务 %d 保存任务日志失败: %v", taskID, err)
+	}
+}
+
+// logStatusChange 任务状态写入数据库后记录状态变化，下载中的进度更新不记录
+func (ds *DownloadService) logStatusChange(taskID uint, status models.DownloadStatus, errorMsg string) {
+	level := logrus.InfoLevel
+	var message string
+	switch status {
+	case models.StatusPending:
+		message = "进入等待队列"
+	case models.StatusCompleted:
+		message = "下载完成"
+	case models.StatusPaused:
+		message = "已暂停"
+	case models.StatusCancelled:
+		message = "已取消"
+	case models.StatusFailed:
+		level, message = logrus.ErrorLevel, "下载失败"
+	case models.StatusIncomplete:
+		level, message = logrus.WarnLevel, "等待剩余片段"
+	case models.StatusExpired:
+		level, message = logrus.WarnLevel, "已过期"
+	default:
+		return
+	}
+	if errorMsg != "" {
+		message += ": " + errorMsg
+	}
+	ds.saveTaskLog(taskID, level, message)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultRecoveryDelay 启动后等待多久开始恢复未完成的任务
+	defaultRecoveryDelay = 2 * time.Second
+	// MaxRecoveryDelaySeconds 可配置的恢复等待时间上限（秒）
+	MaxRecoveryDelaySeconds = 600
+	// defaultRecoveryTasksPerMinute 默认每分钟重新排队的恢复任务数
+	defaultRecoveryTasksPerMinute = 30
+	// MaxRecoveryTasksPerMinute 可配置的每分钟恢复任务数上限
+	MaxRecoveryTasksPerMinute = 600
+	// defaultRecoveryMaxAge 超过这么久的未完成任务不再恢复
+	defaultRecoveryMaxAge = 24 * time.Hour
+	// MaxRecoveryMaxAgeHours 可配置的恢复期限上限（小时）
+	MaxRecoveryMaxAgeHours = 24 * 30
+	// MaxPendingExpiryHours 可配置的等待任务过期时间上限（小时）
+	MaxPendingExpiryHours = 24 * 30
+)
+
+// recoverySettings 启动时恢复未完成任务的节奏
+type recoverySettings struct {
+	delay    time.Duration // 启动后开始恢复前的等待时间
+	interval time.Duration // 相邻两个恢复任务重新排队的间隔，0表示一次全部排队
+	maxAge   time.Duration // 超过该时长的任务不恢复
+}
+
+// taskRecoverySettings 返回配置的恢复节奏，未配置或超出范围时使用默认值
+// 每分钟恢复任务数为0表示不限速，一次把全部任务重新排队
+func (ds *DownloadService) taskRecoverySettings() recoverySettings {
+	settings := recoverySettings{
+		delay:    defaultRecoveryDelay,
+		interval: time.Minute / defaultRecoveryTasksPerMinute,
+		maxAge:   defaultRecoveryMaxAge,
+	}
+
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return settings
+	}
+
+	if config.RecoveryDelaySeconds >= 0 && config.RecoveryDelaySeconds <= MaxRecoveryDelaySeconds {
+		settings.delay = time.Duration(config.RecoveryDelaySeconds) * time.Second
+	}
+	if config.RecoveryTasksPerMinute == 0 {
+		settings.interval = 0
+	} else if config.RecoveryTasksPerMinute > 0 && config.RecoveryTasksPerMinute <= MaxRecoveryTasksPerMinute {
+		settings.interval = time.Minute / time.Duration(config.RecoveryTasksPerMinute)
+	}
+	if config.RecoveryMaxAgeHours > 0 && config.RecoveryMaxAgeHours <= MaxRecoveryMaxAgeHours {
+		settings.maxAge = time.Duration(config.RecoveryMaxAgeHours) * time.Hour
+	}
+	return settings
+}
+
+// waitRecoveryInterval 相邻两个恢复任务之间等待一段时间，服务关闭时返回false
+func (ds *DownloadService) waitRecoveryInterval(interval time.Duration) bool {
+	if interval <= 0 {
+		return ds.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ds.ctx.Done():
+		return false
+	}
+}
+
+// expireAbandonedTasks 启动时清理排队过久的等待任务：应用关闭前没来得及开始下载的任务
+// 超过配置的时长后标记为已过期并记录原因，不再恢复，需要时可手动重试
+func (ds *DownloadService) expireAbandonedTasks() {
+	config, err := ds.db.GetConfig()
+	if err != nil || config.PendingExpiryHours <= 0 || config.PendingExpiryHours > MaxPendingExpiryHours {
+		return
+	}
+
+	age := time.Duration(config.PendingExpiryHours) * time.Hour
+	reason := fmt.Sprintf("排队超过 %d 小时仍未开始下载，已过期", config.PendingExpiryHours)
+	expired, err := ds.db.ExpirePendingTasks(time.Now().Add(-age), reason)
+	if err != nil {
+		ds.logger.Errorf("清理过期的等待任务失败: %v", err)
+		return
+	}
+	if expired > 0 {
+		ds.logger.Infof("已将 %d 个排队超过 %s 的等待任务标记为已过期", expired, age)
+		if err := ds.db.RefreshPendingMessageExtractions(); err != nil {
+			ds.logger.Warnf("更新邮件提取结果失败: %v", err)
+		}
+	}
+}
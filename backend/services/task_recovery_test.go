@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// TestRecoverUnfinishedTasksStaggered 按配置的速率逐个重新排队恢复的任务
+func TestRecoverUnfinishedTasksStaggered(t *testing.T) {
+	db := newTestDatabase(t)
+	config, err := db.GetConfig()
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	config.RecoveryDelaySeconds = 0
+	config.RecoveryTasksPerMinute = 600 // 每 100ms 一个
+	if err := db.UpdateConfig(&config); err != nil {
+		t.Fatalf("保存配置失败: %v", err)
+	}
+
+	const count = 4
+	for i := 0; i < count; i++ {
+		createTestTask(t, db, &models.DownloadTask{FileName: "a.pdf", Status: models.StatusPending, Type: models.TypeLink, Source: "https://example.com/a.pdf"})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := &DownloadService{db: db, logger: newTestLogger(), ctx: ctx, cancel: cancel, taskQueue: make(chan *models.DownloadTask, count)}
+	ds.wg.Add(1)
+	go ds.recoverUnfinishedTasks()
+
+	var arrivals []time.Time
+	for len(arrivals) < count {
+		select {
+		case <-ds.taskQueue:
+			arrivals = append(arrivals, time.Now())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("只恢复了 %d 个任务，期望 %d 个", len(arrivals), count)
+		}
+	}
+	ds.wg.Wait()
+
+	for i := 1; i < len(arrivals); i++ {
+		if gap := arrivals[i].Sub(arrivals[i-1]); gap < 80*time.Millisecond {
+			t.Errorf("第 %d 个任务与上一个间隔 %v，期望约 100ms", i+1, gap)
+		}
+	}
+	if total := arrivals[count-1].Sub(arrivals[0]); total > 2*time.Second {
+		t.Errorf("恢复 %d 个任务用了 %v，速率限制过慢", count, total)
+	}
+}
+
+// TestExpireAbandonedTasks 启动时把排队超过配置时长的等待任务标记为已过期，其他任务不受影响
+func TestExpireAbandonedTasks(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiryHours int
+		wantExpired []bool // 依次对应：排队过久、刚排队、重试后刚重新排队、排队过久但正在下载
+	}{
+		{name: "按24小时清理", expiryHours: 24, wantExpired: []bool{true, false, false, false}},
+		{name: "为0时不清理", expiryHours: 0, wantExpired: []bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			config, err := db.GetConfig()
+			if err != nil {
+				t.Fatalf("读取配置失败: %v", err)
+			}
+			config.PendingExpiryHours = tt.expiryHours
+			if err := db.UpdateConfig(&config); err != nil {
+				t.Fatalf("保存配置失败: %v", err)
+			}
+
+			old := time.Now().Add(-48 * time.Hour)
+			tasks := []struct {
+				task       models.DownloadTask
+				createdAt  time.Time
+				retryCount int
+			}{
+				{models.DownloadTask{FileName: "a.pdf", Status: models.StatusPending, Type: models.TypeLink}, old, 0},
+				{models.DownloadTask{FileName: "b.pdf", Status: models.StatusPending, Type: models.TypeLink}, time.Now(), 0},
+				{models.DownloadTask{FileName: "c.pdf", Status: models.StatusPending, Type: models.TypeLink}, old, 1},
+				{models.DownloadTask{FileName: "d.pdf", Status: models.StatusDownloading, Type: models.TypeLink}, old, 0},
+			}
+			for i := range tasks {
+				createTestTask(t, db, &tasks[i].task)
+				// 创建时间回拨到排队开始的时间，重试过的任务从最近一次重新排队（updated_at）算起
+				if _, err := db.DB.Exec(`UPDATE download_tasks SET created_at = ?, updated_at = ?, retry_count = ? WHERE id = ?`,
+					tasks[i].createdAt, time.Now(), tasks[i].retryCount, tasks[i].task.ID); err != nil {
+					t.Fatalf("修改创建时间失败: %v", err)
+				}
+			}
+
+			ds := &DownloadService{db: db, logger: newTestLogger(), ctx: context.Background()}
+			ds.expireAbandonedTasks()
+
+			for i, want := range tt.wantExpired {
+				task, err := db.GetDownloadTaskByID(tasks[i].task.ID)
+				if err != nil {
+					t.Fatalf("读取任务失败: %v", err)
+				}
+				if expired := task.Status == models.StatusExpired; expired != want {
+					t.Errorf("任务 %s 的状态为 %s，期望已过期: %v", task.FileName, task.Status, want)
+				}
+				if want && task.Error == "" {
+					t.Errorf("过期任务 %s 没有记录原因", task.FileName)
+				}
+			}
+		})
+	}
+}
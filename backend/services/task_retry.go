@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// defaultMaxTaskRetries 默认的任务最大重试次数
+	defaultMaxTaskRetries = 3
+	// MaxTaskRetriesLimit 可配置的任务最大重试次数上限
+	MaxTaskRetriesLimit = 10
+	// defaultTaskRetryBackoff 默认的首次自动重试等待时间
+	defaultTaskRetryBackoff = 30 * time.Second
+	// MinTaskRetryBackoffSeconds 可配置的自动重试等待时间下限（秒）
+	MinTaskRetryBackoffSeconds = 5
+	// MaxTaskRetryBackoffSeconds 可配置的自动重试等待时间上限（秒）
+	MaxTaskRetryBackoffSeconds = 3600
+	// maxTaskRetryDelay 指数退避后单次等待的上限
+	maxTaskRetryDelay = 6 * time.Hour
+)
+
+// taskRetry 等待自动重试的任务
+type taskRetry struct {
+	taskID uint
+	due    time.Time // 到达该时间后重新排队
+}
+
+// taskRetrySettings 返回配置的最大重试次数和首次自动重试的等待时间
+func (ds *DownloadService) taskRetrySettings() (int, time.Duration) {
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return defaultMaxTaskRetries, defaultTaskRetryBackoff
+	}
+
+	maxRetries := config.MaxTaskRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	} else if maxRetries > MaxTaskRetriesLimit {
+		maxRetries = MaxTaskRetriesLimit
+	}
+
+	backoff := defaultTaskRetryBackoff
+	if config.TaskRetryBackoffSeconds >= MinTaskRetryBackoffSeconds && config.TaskRetryBackoffSeconds <= MaxTaskRetryBackoffSeconds {
+		backoff = time.Duration(config.TaskRetryBackoffSeconds) * time.Second
+	}
+	return maxRetries, backoff
+}
+
+// taskRetryDelay 第 retryCount+1 次自动重试前的等待时间：首次为 backoff，之后每次翻倍
+func taskRetryDelay(backoff time.Duration, retryCount int) time.Duration {
+	delay := backoff
+	for i := 0; i < retryCount && delay < maxTaskRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxTaskRetryDelay {
+		delay = maxTaskRetryDelay
+	}
+	return delay
+}
+
+// RetryTask 手动重试失败、已取消或已过期的任务：重置为等待状态并重新排队，超过最大重试次数时拒绝
+func (ds *DownloadService) RetryTask(taskID uint) error {
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		return fmt.Errorf("获取任务失败: %v", err)
+	}
+	if task.Status != models.StatusFailed && task.Status != models.StatusCancelled && task.Status != models.StatusExpired {
+		return fmt.Errorf("只能重试失败、已取消或已过期的任务，当前状态: %s", task.Status)
+	}
+
+	maxRetries, _ := ds.taskRetrySettings()
+	if task.RetryCount >= maxRetries {
+		return fmt.Errorf("任务已重试 %d 次，达到最大重试次数 %d", task.RetryCount, maxRetries)
+	}
+
+	reset, err := ds.db.ResetTaskForRetry(taskID, maxRetries)
+	if err != nil {
+		return fmt.Errorf("重置任务失败: %v", err)
+	}
+	if !reset {
+		return fmt.Errorf("任务状态已变化，无法重试")
+	}
+	ds.refreshMessageExtraction(taskID)
+
+	ds.taskInfof(taskID, "手动重试（第 %d 次）", task.RetryCount+1)
+	return ds.StartDownload(taskID)
+}
+
+// scheduleAutoRetry 网络错误导致失败的任务按指数退避安排自动重试，达到最大重试次数后不再重试
+// 其他类型的失败（认证、文件不存在、无效PDF等）重试也不会成功，留给用户处理
+func (ds *DownloadService) scheduleAutoRetry(task *models.DownloadTask, err error) {
+	if classifyFailure(err.Error()) != models.FailureNetwork {
+		return
+	}
+
+	maxRetries, backoff := ds.taskRetrySettings()
+	if task.RetryCount >= maxRetries {
+		ds.taskInfof(task.ID, "已重试 %d 次，不再自动重试", task.RetryCount)
+		return
+	}
+
+	delay := taskRetryDelay(backoff, task.RetryCount)
+	select {
+	case ds.retryQueue <- taskRetry{taskID: task.ID, due: time.Now().Add(delay)}:
+		ds.taskInfof(task.ID, "将在 %s 后自动重试（第 %d 次）", delay, task.RetryCount+1)
+	default:
+		ds.logger.Warnf("自动重试队列已满，任务 %d 不再自动重试", task.ID)
+	}
+}
+
+// dueRetries 取出已到重试时间的任务，重置为等待状态后返回
+// 等待期间被用户手动重试、删除或重试次数已用完的任务被跳过
+func (ds *DownloadService) dueRetries(scheduled map[uint]time.Time) []*models.DownloadTask {
+	now := time.Now()
+	var tasks []*models.DownloadTask
+	for taskID, due := range scheduled {
+		if now.Before(due) {
+			continue
+		}
+		delete(scheduled, taskID)
+
+		maxRetries, _ := ds.taskRetrySettings()
+		reset, err := ds.db.ResetTaskForRetry(taskID, maxRetries)
+		if err != nil {
+			ds.logger.Errorf("重置任务 %d 失败，取消自动重试: %v", taskID, err)
+			continue
+		}
+		if !reset {
+			ds.logger.Debugf("任务 %d 状态已变化，跳过自动重试", taskID)
+			continue
+		}
+		ds.refreshMessageExtraction(taskID)
+
+		task, err := ds.getTaskByIDOptimized(taskID)
+		if err != nil {
+			ds.logger.Errorf("获取任务 %d 失败，取消自动重试: %v", taskID, err)
+			continue
+		}
+		ds.taskInfof(taskID, "开始自动重试（第 %d 次）", task.RetryCount)
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// queuedSince 任务开始排队的时间：重试的任务从重置为等待状态时算起，否则为创建时间
+func queuedSince(task *models.DownloadTask) string {
+	if task.RetryCount > 0 {
+		return task.UpdatedAt
+	}
+	return task.CreatedAt
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// RevalidateTask 重新检查已完成任务的本地文件：文件是否存在、内容是否完整，
+// 下载时记录了校验和的还会比对校验和，容器任务同时检查解压出的文件
+// 文件缺失或损坏时任务标记为失败，之后可以重试下载；文件本身保留不动
+func (ds *DownloadService) RevalidateTask(taskID uint) (models.ValidationResult, error) {
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		return models.ValidationResult{}, fmt.Errorf("获取任务失败: %v", err)
+	}
+	if task.Status != models.StatusCompleted {
+		return models.ValidationResult{}, fmt.Errorf("只能验证已完成的任务，当前状态: %s", task.Status)
+	}
+
+	result := models.ValidationResult{
+		TaskID:    task.ID,
+		LocalPath: task.LocalPath,
+		Status:    task.Status,
+	}
+	var problems []string
+
+	info, err := os.Stat(task.LocalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			problems = append(problems, "文件不存在")
+		} else {
+			problems = append(problems, fmt.Sprintf("无法读取文件: %v", err))
+		}
+	} else {
+		result.Exists = true
+		result.FileSize = info.Size()
+
+		if err := validateTaskContent(task.LocalPath, task.FileName); err != nil {
+			result.ContentError = err.Error()
+			problems = append(problems, result.ContentError)
+		}
+
+		if task.Checksum != "" {
+			result.ChecksumAlgorithm = task.ChecksumAlgorithm
+			checksum, err := utils.FileChecksum(task.LocalPath, task.ChecksumAlgorithm)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("计算校验和失败: %v", err))
+			} else if result.ChecksumMatches = strings.EqualFold(checksum, task.Checksum); !result.ChecksumMatches {
+				problems = append(problems, "文件校验和与下载完成时不一致，文件已被修改或损坏")
+			}
+		}
+	}
+
+	if task.IsContainer {
+		files, err := ds.db.GetTaskFiles(task.ID)
+		if err != nil {
+			return result, fmt.Errorf("获取解压文件失败: %v", err)
+		}
+		result.ExtractedFiles = len(files)
+		for _, file := range files {
+			if err := validateTaskContent(file.LocalPath, file.FileName); err != nil {
+				result.ExtractedErrors = append(result.ExtractedErrors, fmt.Sprintf("%s: %v", file.FileName, err))
+			}
+		}
+		if len(result.ExtractedErrors) > 0 {
+			problems = append(problems, fmt.Sprintf("%d 个解压出的文件未通过检查", len(result.ExtractedErrors)))
+		}
+	}
+
+	if len(problems) == 0 {
+		result.Valid = true
+		ds.taskInfof(task.ID, "文件重新验证通过: %s", task.LocalPath)
+		return result, nil
+	}
+
+	result.Error = strings.Join(problems, "；")
+	ds.taskWarnf(task.ID, "文件重新验证未通过: %s", result.Error)
+	if err := ds.updateTaskStatus(task.ID, models.StatusFailed, "重新验证失败: "+result.Error, 0, 0, ""); err != nil {
+		return result, err
+	}
+	result.Status = models.StatusFailed
+	return result, nil
+}
+
+// validateTaskContent 按文件名的扩展名检查文件内容：PDF检查完整结构，其他类型检查文件头
+func validateTaskContent(path, fileName string) error {
+	if fileName == "" {
+		fileName = filepath.Base(path)
+	}
+	if isPDFTarget(fileName) {
+		return utils.ValidatePDFFile(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("无法读取文件头: %v", err)
+	}
+	ext := fileExtension(fileName)
+	if !utils.MatchesFileType(header[:n], ext) {
+		return fmt.Errorf("文件内容不是有效的%s文件", strings.ToUpper(ext))
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"emaild/backend/database"
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+// 遥测事件来源
+const (
+	TelemetrySourceLinkDownload       = "link_download"
+	TelemetrySourceAttachmentDownload = "attachment_download"
+	TelemetrySourceEmailCheck         = "email_check"
+)
+
+// 遥测错误分类（只上报这些固定值，从不上报原始错误信息）
+const (
+	TelemetryCategoryTimeout    = "timeout"
+	TelemetryCategoryNetwork    = "network"
+	TelemetryCategoryTLS        = "tls"
+	TelemetryCategoryAuth       = "auth"
+	TelemetryCategoryHTTPStatus = "http_status"
+	TelemetryCategoryHTMLPage   = "html_page"
+	TelemetryCategoryNotPDF     = "not_pdf"
+	TelemetryCategoryNotFound   = "not_found"
+	TelemetryCategoryFileSystem = "filesystem"
+	TelemetryCategoryCancelled  = "cancelled"
+	TelemetryCategoryOther      = "other"
+)
+
+const (
+	telemetryMaxBufferedEvents = 1000             // 本地最多缓存的事件数，超出丢弃最旧的
+	telemetryBatchSize         = 100              // 每批上报的事件数
+	telemetryFlushInterval     = 30 * time.Minute // 上报间隔
+	telemetryAppVersion        = "1.0.0"
+)
+
+// telemetryDomainOther 不在常见服务商列表中的服务器统一上报的域名
+const telemetryDomainOther = "other"
+
+// telemetryProviderDomains 可以上报的常见邮箱、网盘和文件分享服务商的主域名
+// 自建服务器的域名会暴露用户所在的公司或个人域名，不在列表中的一律上报为 other
+var telemetryProviderDomains = map[string]bool{
+	// 邮箱服务商
+	"gmail.com": true, "googlemail.com": true, "outlook.com": true, "hotmail.com": true,
+	"live.com": true, "office365.com": true, "yahoo.com": true, "icloud.com": true,
+	"me.com": true, "aol.com": true, "zoho.com": true, "gmx.com": true, "gmx.net": true,
+	"mail.ru": true, "yandex.ru": true, "yandex.com": true, "fastmail.com": true, "proton.me": true,
+	"qq.com": true, "foxmail.com": true, "163.com": true, "126.com": true, "yeah.net": true,
+	"sina.com": true, "sina.cn": true, "sohu.com": true, "aliyun.com": true, "139.com": true,
+	"189.cn": true, "21cn.com": true, "tom.com": true,
+	// 网盘和文件分享
+	"google.com": true, "googleusercontent.com": true, "dropbox.com": true, "sharepoint.com": true,
+	"amazonaws.com": true, "baidu.com": true,
+}
+
+// telemetryKeywords 错误信息关键字到分类的映射（按顺序匹配）
+var telemetryKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{TelemetryCategoryCancelled, []string{"取消", "canceled", "cancelled"}},
+	{TelemetryCategoryTimeout, []string{"超时", "timeout", "deadline"}},
+	{TelemetryCategoryTLS, []string{"tls", "x509", "certificate", "证书"}},
+	{TelemetryCategoryAuth, []string{"auth", "login", "password", "认证", "登录", "密码"}},
+	{TelemetryCategoryHTTPStatus, []string{"http状态", "服务器响应错误", "重定向"}},
+	{TelemetryCategoryNotPDF, []string{"pdf"}},
+	{TelemetryCategoryNotFound, []string{"未找到", "not found"}},
+	{TelemetryCategoryFileSystem, []string{"文件", "目录", "permission", "no space"}},
+	{TelemetryCategoryNetwork, []string{"no such host", "connection", "network", "eof", "连接", "网络", "请求失败"}},
+}
+
+// TelemetryService 匿名错误遥测服务（默认关闭，需用户主动开启）
+// 只记录错误分类和服务商域名，不包含邮件内容、邮箱地址、文件名或凭据
+type TelemetryService struct {
+	db       *database.Database
+	logger   *logrus.Logger
+	client   *http.Client
+	enabled  bool
+	endpoint string
+	mutex    sync.RWMutex
+
+	// 优雅关闭相关
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	shutdownOnce sync.Once
+}
+
+// NewTelemetryService 创建遥测服务
+func NewTelemetryService(db *database.Database, logger *logrus.Logger) *TelemetryService {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	service := &TelemetryService{
+		db:     db,
+		logger: logger,
+		client: &http.Client{Timeout: 15 * time.Second},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	if config, err := db.GetConfig(); err == nil {
+		service.enabled = config.TelemetryEnabled
+		service.endpoint = config.TelemetryEndpoint
+	}
+
+	service.wg.Add(1)
+	go service.flushLoop()
+
+	return service
+}
+
+// Configure 更新遥测开关和上报地址，关闭时清空本地缓存的事件
+func (ts *TelemetryService) Configure(enabled bool, endpoint string) {
+	ts.mutex.Lock()
+	ts.enabled = enabled
+	ts.endpoint = strings.TrimSpace(endpoint)
+	ts.mutex.Unlock()
+
+	if enabled {
+		ts.logger.Info("错误遥测已开启")
+		return
+	}
+
+	// 用户撤回同意后不再保留任何未上报的事件
+	if err := ts.db.ClearTelemetryEvents(); err != nil {
+		ts.logger.Warnf("清空遥测缓存失败: %v", err)
+	}
+	ts.logger.Info("错误遥测已关闭")
+}
+
+// IsEnabled 遥测是否开启
+func (ts *TelemetryService) IsEnabled() bool {
+	if ts == nil {
+		return false
+	}
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	return ts.enabled
+}
+
+// RecordError 记录一次匿名错误事件，host 可以是服务器地址或URL，只保留其主域名
+func (ts *TelemetryService) RecordError(source string, err error, host string) {
+	if err == nil || !ts.IsEnabled() {
+		return
+	}
+
+	event := &models.TelemetryEvent{
+		Source:   source,
+		Category: ClassifyTelemetryError(err),
+		Domain:   telemetryDomain(host),
+		Date:     time.Now().UTC().Format("2006-01-02"),
+	}
+
+	if err := ts.db.AddTelemetryEvent(event, telemetryMaxBufferedEvents); err != nil {
+		ts.logger.Debugf("缓存遥测事件失败: %v", err)
+	}
+}
+
+// ClassifyTelemetryError 将错误归类为固定的分类，避免原始错误信息中的隐私数据外泄
+func ClassifyTelemetryError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, utils.ErrHTMLNotPDF) {
+		return TelemetryCategoryHTMLPage
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return TelemetryCategoryTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return TelemetryCategoryCancelled
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, entry := range telemetryKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(msg, keyword) {
+				return entry.category
+			}
+		}
+	}
+	return TelemetryCategoryOther
+}
+
+// telemetryDomain 从服务器地址、URL或邮箱地址中提取主域名，只上报常见服务商的主域名
+// 子域名可能包含用户信息，只保留主域名；IP地址和自建服务器的域名上报为 other
+func telemetryDomain(raw string) string {
+	host := strings.ToLower(strings.TrimSpace(raw))
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			host = u.Hostname()
+		}
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, ".")
+
+	if host == "" {
+		return ""
+	}
+	if net.ParseIP(host) != nil {
+		return telemetryDomainOther
+	}
+
+	labels := strings.Split(host, ".")
+	keep := 2
+	if len(labels) >= 3 {
+		switch labels[len(labels)-2] {
+		case "com", "net", "org", "edu", "gov", "co", "ac":
+			keep = 3 // 如 example.com.cn
+		}
+	}
+	if len(labels) > keep {
+		labels = labels[len(labels)-keep:]
+	}
+	domain := strings.Join(labels, ".")
+	if !telemetryProviderDomains[domain] {
+		return telemetryDomainOther
+	}
+	return domain
+}
+
+// flushLoop 定期上报缓存的事件
+func (ts *TelemetryService) flushLoop() {
+	defer ts.wg.Done()
+
+	ticker := time.NewTicker(telemetryFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ts.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ts.Flush(); err != nil {
+				ts.logger.Debugf("上报遥测事件失败: %v", err)
+			}
+		}
+	}
+}
+
+// Flush 将缓存的事件分批上报到配置的地址，未开启或未配置地址时不做任何事
+func (ts *TelemetryService) Flush() error {
+	ts.mutex.RLock()
+	enabled, endpoint := ts.enabled, ts.endpoint
+	ts.mutex.RUnlock()
+
+	if !enabled || endpoint == "" {
+		return nil
+	}
+
+	for {
+		events, err := ts.db.GetTelemetryEvents(telemetryBatchSize)
+		if err != nil {
+			return fmt.Errorf("读取遥测缓存失败: %v", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		if err := ts.post(endpoint, events); err != nil {
+			return err
+		}
+
+		if err := ts.db.DeleteTelemetryEvents(events[len(events)-1].ID); err != nil {
+			return fmt.Errorf("清理已上报事件失败: %v", err)
+		}
+	}
+}
+
+// post 上报一批事件，只包含应用版本、操作系统和事件本身
+func (ts *TelemetryService) post(endpoint string, events []models.TelemetryEvent) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"app_version": telemetryAppVersion,
+		"os":          runtime.GOOS,
+		"events":      events,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ts.ctx, "POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建上报请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("上报请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("上报服务器响应错误: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Stop 停止遥测服务
+func (ts *TelemetryService) Stop() {
+	ts.shutdownOnce.Do(func() {
+		ts.cancel()
+		ts.wg.Wait()
+	})
+}
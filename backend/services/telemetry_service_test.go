@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+// TestTelemetryDomain 只上报常见服务商的主域名，自建服务器和IP地址上报为 other
+func TestTelemetryDomain(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "imap.gmail.com", want: "gmail.com"},
+		{raw: "outlook.office365.com:993", want: "office365.com"},
+		{raw: "imap.exmail.qq.com", want: "qq.com"},
+		{raw: "user@163.com", want: "163.com"},
+		{raw: "https://www.dropbox.com/s/abc/invoice.pdf?dl=1", want: "dropbox.com"},
+		{raw: "mail.mycompany.com", want: telemetryDomainOther},
+		{raw: "https://billing.example.com.cn/invoice.pdf", want: telemetryDomainOther},
+		{raw: "192.168.1.10:993", want: telemetryDomainOther},
+		{raw: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := telemetryDomain(tt.raw); got != tt.want {
+			t.Errorf("telemetryDomain(%q) = %q，期望 %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// tempFileSuffix 下载过程中使用的临时文件后缀
+	tempFileSuffix = ".tmp"
+	// tempFileMinAge 临时文件至少存在这么久才视为遗留，避免误删刚创建的文件
+	tempFileMinAge = 10 * time.Minute
+	// tempCleanupInterval 定期清理遗留临时文件的间隔
+	tempCleanupInterval = 6 * time.Hour
+)
+
+// tempFileCleaner 启动时及定期清理遗留的临时文件
+func (ds *DownloadService) tempFileCleaner() {
+	defer ds.wg.Done()
+
+	// 等待任务恢复完成，避免与恢复中的任务竞争
+	select {
+	case <-time.After(30 * time.Second):
+	case <-ds.ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(tempCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := ds.CleanOrphanedTempFiles(); err != nil {
+			ds.logger.Warnf("清理遗留临时文件失败: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ds.ctx.Done():
+			return
+		}
+	}
+}
+
+// CleanOrphanedTempFiles 删除下载目录中没有对应进行中或可恢复任务的 .tmp 文件，返回清理的文件数
+// 下载中断后崩溃会留下临时文件，没有对应任务可以续传的文件不会再被使用
+func (ds *DownloadService) CleanOrphanedTempFiles() (int, error) {
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	// 仍在使用的临时文件：正在下载的任务以及等待恢复的任务
+	inUse := make(map[string]bool)
+	roots := []string{config.DownloadPath}
+	// 只清理本程序产生的临时文件（允许下载的文件类型加 .tmp），不动用户的其他文件
+	extensions := make(map[string]bool)
+	for _, ext := range savedExtensions(&config, nil) {
+		extensions[ext] = true
+	}
+	if accounts, err := ds.db.GetEmailAccounts(); err == nil {
+		for i, account := range accounts {
+			if account.DownloadPath != "" {
+				roots = append(roots, account.DownloadPath)
+			}
+			for _, ext := range savedExtensions(&config, &accounts[i]) {
+				extensions[ext] = true
+			}
+		}
+	}
+
+	ds.workerMutex.RLock()
+	for _, worker := range ds.workers {
+		inUse[filepath.Clean(worker.Task.LocalPath+tempFileSuffix)] = true
+	}
+	ds.workerMutex.RUnlock()
+
+	tasks, err := ds.db.GetUnfinishedDownloadTasks()
+	if err != nil {
+		return 0, err
+	}
+	// 暂停或失败的链接任务保留了已下载的部分，重试时续传
+	for _, status := range []models.DownloadStatus{models.StatusPaused, models.StatusFailed} {
+		resumable, err := ds.db.GetDownloadTasksByStatus(status)
+		if err != nil {
+			return 0, err
+		}
+		for _, task := range resumable {
+			if task.Type == models.TypeLink && task.DownloadedSize > 0 {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	for _, task := range tasks {
+		if task.LocalPath == "" {
+			continue
+		}
+		inUse[filepath.Clean(task.LocalPath+tempFileSuffix)] = true
+		roots = append(roots, filepath.Dir(task.LocalPath))
+	}
+
+	cleaned := 0
+	visited := make(map[string]bool)
+	for _, root := range roots {
+		if root == "" || visited[filepath.Clean(root)] {
+			continue
+		}
+		visited[filepath.Clean(root)] = true
+
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// 目录不存在或无权限时跳过
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				// 子目录在其他根目录中已经遍历过
+				if path != root && visited[filepath.Clean(path)] {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isOrphanTempName(d.Name(), extensions) || inUse[filepath.Clean(path)] {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil || time.Since(info.ModTime()) < tempFileMinAge {
+				return nil
+			}
+
+			if err := os.Remove(path); err != nil {
+				ds.logger.Warnf("删除遗留临时文件失败: %s, %v", path, err)
+				return nil
+			}
+			ds.logger.Debugf("已删除遗留临时文件: %s", path)
+			cleaned++
+			return nil
+		})
+	}
+
+	if cleaned > 0 {
+		ds.logger.Infof("已清理 %d 个遗留临时文件", cleaned)
+	}
+	return cleaned, nil
+}
+
+// isOrphanTempName 文件名是否为本程序产生的临时文件：去掉 .tmp 后的扩展名是允许下载的类型
+func isOrphanTempName(name string, extensions map[string]bool) bool {
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, tempFileSuffix) {
+		return false
+	}
+	return extensions[fileExtension(strings.TrimSuffix(lower, tempFileSuffix))]
+}
@@ -16,6 +16,9 @@ import (
 //go:embed icon.ico
 var iconData []byte
 
+// trayReadyTimeout 等待托盘就绪的时间，超时视为当前桌面不支持系统托盘
+const trayReadyTimeout = 5 * time.Second
+
 // TrayService 系统托盘服务
 type TrayService struct {
 	db     *database.Database
@@ -32,6 +35,13 @@ type TrayService struct {
 	isVisible bool
 	mutex     sync.RWMutex
 	
+	// 托盘可用性：就绪后才可用，不支持时记录原因；starting 在启动结束时关闭
+	starting          chan struct{}
+	ready             chan struct{}
+	readyOnce         *sync.Once
+	available         bool
+	unsupportedReason string
+	
 	// 回调函数
 	onShow     func()
 	onHide     func()
@@ -62,17 +72,98 @@ func NewTrayService(db *database.Database, logger *logrus.Logger) *TrayService {
 	}
 }
 
-// Start 启动系统托盘
-func (ts *TrayService) Start() error {
+// Start 在后台启动系统托盘，不阻塞调用方；返回时已标记为正在启动，启动失败时调用 onError
+// 先检测桌面是否支持托盘，再等待托盘就绪；不支持或超时未就绪时托盘标记为不可用
+func (ts *TrayService) Start(onError func(error)) {
+	finish := ts.beginStart()
+	go func() {
+		defer finish()
+		if err := ts.start(); err != nil && onError != nil {
+			onError(err)
+		}
+	}()
+}
+
+// beginStart 标记托盘正在启动，返回启动结束时调用的函数
+func (ts *TrayService) beginStart() func() {
+	starting := make(chan struct{})
+	ts.mutex.Lock()
+	ts.starting = starting
+	ts.mutex.Unlock()
+	return func() { close(starting) }
+}
+
+// WaitAvailable 等待正在进行的启动结束后返回托盘是否可用，最多等待托盘初始化超时时间
+func (ts *TrayService) WaitAvailable() bool {
+	ts.mutex.RLock()
+	starting := ts.starting
+	ts.mutex.RUnlock()
+	if starting != nil {
+		<-starting
+	}
+	return ts.IsAvailable()
+}
+
+// start 检测并启动托盘，等待托盘就绪，不支持或超时未就绪时返回错误
+func (ts *TrayService) start() error {
 	ts.logger.Info("启动系统托盘服务")
 	
+	if reason := detectTrayUnsupported(); reason != "" {
+		return ts.markUnsupported(reason)
+	}
+	
+	ready := make(chan struct{})
+	exited := make(chan struct{})
+	ts.mutex.Lock()
+	ts.ready = ready
+	ts.readyOnce = &sync.Once{}
+	ts.mutex.Unlock()
+	
 	ts.wg.Add(1)
 	go func() {
 		defer ts.wg.Done()
+		defer close(exited)
+		defer func() {
+			if r := recover(); r != nil {
+				ts.logger.Errorf("系统托盘异常退出: %v", r)
+			}
+		}()
 		systray.Run(ts.onReady, ts.onExit)
 	}()
 	
-	return nil
+	select {
+	case <-ready:
+		return nil
+	case <-exited:
+		return ts.markUnsupported("系统托盘启动失败")
+	case <-time.After(trayReadyTimeout):
+		return ts.markUnsupported("系统托盘初始化超时")
+	}
+}
+
+// markUnsupported 记录托盘不可用的原因并返回对应的错误
+func (ts *TrayService) markUnsupported(reason string) error {
+	ts.mutex.Lock()
+	ts.available = false
+	ts.unsupportedReason = reason
+	ts.mutex.Unlock()
+	
+	ts.logger.Warnf("系统托盘不可用: %s，最小化到托盘已停用", reason)
+	return fmt.Errorf("系统托盘不可用: %s", reason)
+}
+
+// IsAvailable 托盘是否已就绪，不可用时关闭和最小化窗口按普通窗口处理
+func (ts *TrayService) IsAvailable() bool {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	return ts.available
+}
+
+// UnsupportedReason 托盘不可用的原因，托盘可用或未检测时为空
+func (ts *TrayService) UnsupportedReason() string {
+	ts.mutex.RLock()
+	defer ts.mutex.RUnlock()
+	return ts.unsupportedReason
 }
 
 // Stop 停止系统托盘
@@ -88,6 +179,10 @@ func (ts *TrayService) Stop() {
 		// 取消上下文
 		ts.cancel()
 		
+		ts.mutex.Lock()
+		ts.available = false
+		ts.mutex.Unlock()
+		
 		// 退出系统托盘
 		systray.Quit()
 		
@@ -129,6 +224,16 @@ func (ts *TrayService) onReady() {
 	// 启动菜单事件处理
 	ts.wg.Add(1)
 	go ts.handleMenuEvents()
+	
+	// 标记托盘可用，初始化超时后才就绪的托盘同样恢复可用
+	ts.mutex.Lock()
+	ts.available = true
+	ts.unsupportedReason = ""
+	ready, readyOnce := ts.ready, ts.readyOnce
+	ts.mutex.Unlock()
+	if readyOnce != nil {
+		readyOnce.Do(func() { close(ready) })
+	}
 }
 
 // onExit 托盘退出回调
@@ -263,5 +368,8 @@ func (ts *TrayService) ShowNotification(title, message string) {
 
 // UpdateStatus 更新状态
 func (ts *TrayService) UpdateStatus(status string) {
+	if !ts.IsAvailable() {
+		return
+	}
 	systray.SetTooltip(fmt.Sprintf("邮件附件下载器 - %s", status))
 } 
\ No newline at end of file
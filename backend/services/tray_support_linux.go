@@ -0,0 +1,45 @@
+//go:build linux
+
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// statusNotifierWatcher 桌面环境提供 StatusNotifier/AppIndicator 托盘时注册的D-Bus名称
+const statusNotifierWatcher = "org.kde.StatusNotifierWatcher"
+
+// detectTrayUnsupported 检测当前桌面是否能显示托盘图标，不支持时返回原因
+// 没有 AppIndicator 的桌面（如未安装扩展的GNOME）上 systray 会阻塞或静默失败
+func detectTrayUnsupported() string {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return "未检测到图形桌面环境"
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		if _, err := os.Stat(fmt.Sprintf("/run/user/%d/bus", os.Getuid())); err != nil {
+			return "未检测到D-Bus会话总线"
+		}
+	}
+
+	// 没有 dbus-send 时无法确认，交给托盘就绪超时判断
+	if _, err := exec.LookPath("dbus-send"); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "dbus-send", "--session", "--print-reply",
+		"--dest=org.freedesktop.DBus", "/org/freedesktop/DBus",
+		"org.freedesktop.DBus.NameHasOwner", "string:"+statusNotifierWatcher).Output()
+	if err != nil {
+		return ""
+	}
+	if strings.Contains(string(output), "boolean false") {
+		return "桌面环境未提供系统托盘（AppIndicator）服务"
+	}
+	return ""
+}
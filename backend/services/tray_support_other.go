@@ -0,0 +1,8 @@
+//go:build !linux
+
+package services
+
+// detectTrayUnsupported Windows 和 macOS 始终提供系统托盘，失败时由托盘就绪超时判断
+func detectTrayUnsupported() string {
+	return ""
+}
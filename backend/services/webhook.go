@@ -0,0 +1,130 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"emaild/backend/models"
+)
+
+const (
+	// webhookTimeout 单次 Webhook 请求的超时时间
+	webhookTimeout = 10 * time.Second
+	// WebhookSignatureHeader 配置了密钥时携带请求体 HMAC-SHA256 签名的请求头，格式为 "sha256=十六进制"
+	WebhookSignatureHeader = "X-Emaild-Signature"
+	// WebhookEventHeader 携带事件类型（completed/failed）的请求头
+	WebhookEventHeader = "X-Emaild-Event"
+)
+
+// WebhookPayload 任务完成或失败时发送给 Webhook 的内容
+type WebhookPayload struct {
+	Event     string `json:"event"` // completed 或 failed
+	TaskID    uint   `json:"task_id"`
+	FileName  string `json:"file_name"`
+	Sender    string `json:"sender"`
+	Subject   string `json:"subject"`
+	LocalPath string `json:"local_path"`
+	FileSize  int64  `json:"file_size"`
+	Error     string `json:"error,omitempty"`
+	Timestamp string `json:"timestamp"` // RFC 3339
+}
+
+// ValidateWebhookURL 检查 Webhook 地址，只支持 http 和 https，为空表示不发送
+func ValidateWebhookURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("Webhook地址格式错误: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Webhook地址只支持 http 和 https: %s", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("Webhook地址缺少主机名: %s", raw)
+	}
+	return nil
+}
+
+// signWebhookPayload 计算请求体的 HMAC-SHA256 签名
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhook 任务完成或失败时向配置的 Webhook 发送通知
+// 请求在单独的协程中发送，不重试，不阻塞下载
+func (ds *DownloadService) notifyWebhook(taskID uint, status models.DownloadStatus, errorMsg string) {
+	config, err := ds.db.GetConfig()
+	if err != nil || strings.TrimSpace(config.WebhookURL) == "" {
+		return
+	}
+
+	task, err := ds.getTaskByIDOptimized(taskID)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 获取Webhook通知内容失败: %v", taskID, err)
+		return
+	}
+
+	payload := WebhookPayload{
+		Event:     string(status),
+		TaskID:    task.ID,
+		FileName:  task.FileName,
+		Sender:    task.Sender,
+		Subject:   task.Subject,
+		LocalPath: task.LocalPath,
+		FileSize:  task.FileSize,
+		Error:     errorMsg,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	go ds.sendWebhook(strings.TrimSpace(config.WebhookURL), config.WebhookSecret, payload)
+}
+
+// sendWebhook 发送一次 Webhook 请求，失败只记录日志
+func (ds *DownloadService) sendWebhook(endpoint, secret string, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 的Webhook内容编码失败: %v", payload.TaskID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ds.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		ds.logger.Warnf("任务 %d 创建Webhook请求失败: %v", payload.TaskID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "emaild/"+telemetryAppVersion)
+	req.Header.Set(WebhookEventHeader, payload.Event)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, signWebhookPayload(secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ds.logger.Warnf("任务 %d 的Webhook请求失败: %v", payload.TaskID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		ds.logger.Warnf("任务 %d 的Webhook响应错误: %d", payload.TaskID, resp.StatusCode)
+		return
+	}
+	ds.logger.Debugf("任务 %d 的Webhook通知已发送（%s）", payload.TaskID, payload.Event)
+}
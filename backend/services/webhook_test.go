@@ -0,0 +1,107 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"emaild/backend/models"
+)
+
+// testPDFContent 能通过基本校验的最小PDF内容
+func testPDFContent() []byte {
+	return []byte("%PDF-1.4\n" + strings.Repeat("% padding\n", 20) + "%%EOF\n")
+}
+
+// webhookDelivery Webhook 接收方收到的一次通知
+type webhookDelivery struct {
+	payload    WebhookPayload
+	fileExists bool // 收到通知时本地文件是否已存在
+}
+
+// TestWebhookFiresOnFinalResult 链接下载只在最终结果确定后发送一次 Webhook：
+// 完成时文件已保存到本地路径，读完数据后校验失败时只发送失败通知
+func TestWebhookFiresOnFinalResult(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []byte
+		wantEvent models.DownloadStatus
+	}{
+		{name: "下载完成", body: testPDFContent(), wantEvent: models.StatusCompleted},
+		{name: "读完数据后校验失败", body: []byte("%PDF-1.4 truncated"), wantEvent: models.StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deliveries := make(chan webhookDelivery, 10)
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var payload WebhookPayload
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("解析Webhook内容失败: %v", err)
+					return
+				}
+				_, err := os.Stat(payload.LocalPath)
+				deliveries <- webhookDelivery{payload: payload, fileExists: err == nil}
+			}))
+			defer receiver.Close()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/pdf")
+				w.Write(tt.body)
+			}))
+			defer server.Close()
+
+			db := newTestDatabase(t)
+			config, err := db.GetConfig()
+			if err != nil {
+				t.Fatalf("读取配置失败: %v", err)
+			}
+			config.WebhookURL = receiver.URL
+			if err := db.UpdateConfig(&config); err != nil {
+				t.Fatalf("保存配置失败: %v", err)
+			}
+
+			task := &models.DownloadTask{
+				FileName:  "report.pdf",
+				Status:    models.StatusPending,
+				Type:      models.TypeLink,
+				Source:    server.URL + "/report.pdf",
+				LocalPath: filepath.Join(t.TempDir(), "report.pdf"),
+			}
+			createTestTask(t, db, task)
+
+			ds := NewDownloadService(db)
+			ds.logger.SetOutput(io.Discard)
+			defer ds.Stop()
+			if err := ds.StartDownload(task.ID); err != nil {
+				t.Fatalf("启动下载失败: %v", err)
+			}
+
+			var delivery webhookDelivery
+			select {
+			case delivery = <-deliveries:
+			case <-time.After(10 * time.Second):
+				t.Fatal("等待Webhook通知超时")
+			}
+			if models.DownloadStatus(delivery.payload.Event) != tt.wantEvent || delivery.payload.TaskID != task.ID {
+				t.Fatalf("收到任务 %d 的 %s 通知，期望任务 %d 的 %s 通知", delivery.payload.TaskID, delivery.payload.Event, task.ID, tt.wantEvent)
+			}
+			if tt.wantEvent == models.StatusCompleted && !delivery.fileExists {
+				t.Errorf("收到完成通知时文件 %s 尚不存在", delivery.payload.LocalPath)
+			}
+
+			// 每次下载只通知一次
+			select {
+			case extra := <-deliveries:
+				t.Errorf("收到多余的 %s 通知", extra.payload.Event)
+			case <-time.After(300 * time.Millisecond):
+			}
+		})
+	}
+}
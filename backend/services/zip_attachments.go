@@ -0,0 +1,218 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"emaild/backend/models"
+	"emaild/backend/utils"
+)
+
+const (
+	// maxZipEntries ZIP附件允许的最大条目数，防止构造大量条目的压缩包
+	maxZipEntries = 1000
+	// maxZipUncompressedSize 解压内容的总大小上限，防止压缩炸弹
+	maxZipUncompressedSize = 512 * 1024 * 1024
+)
+
+// errZipTooLarge 解压过程中实际大小超过上限（声明的大小可能被伪造）
+var errZipTooLarge = fmt.Errorf("ZIP文件解压后超过大小限制（%s）", utils.FormatBytes(maxZipUncompressedSize))
+
+// isZipPart 根据MIME类型或文件名判断附件是否为ZIP压缩包（MIME类型需为小写）
+func isZipPart(mimeType, mimeSubType, fileName string) bool {
+	if mimeType == "application" {
+		switch mimeSubType {
+		case "zip", "x-zip", "x-zip-compressed":
+			return true
+		}
+	}
+	return isZipFileName(fileName)
+}
+
+// isZipFileName 文件名是否为ZIP压缩包
+func isZipFileName(fileName string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(fileName)), ".zip")
+}
+
+// shouldExtractZip 是否开启了ZIP附件解压，未开启时ZIP附件（如果在允许的类型中）按普通文件保存
+func (ds *DownloadService) shouldExtractZip() bool {
+	config, err := ds.db.GetConfig()
+	return err == nil && config.ExtractZipAttachments
+}
+
+// isZipTask 任务是否为ZIP附件
+func isZipTask(task *models.DownloadTask) bool {
+	return task.Type == models.TypeAttachment && (isZipFileName(task.Source) || isZipFileName(task.FileName))
+}
+
+// zipExtractExtensions 返回ZIP附件中需要解压的扩展名，与附件相同按账户或全局配置的附件类型，都未配置时只解压PDF
+func (ds *DownloadService) zipExtractExtensions(task *models.DownloadTask) map[string]bool {
+	config, err := ds.db.GetConfig()
+	if err != nil {
+		return map[string]bool{models.DefaultExtension: true}
+	}
+	account, err := ds.db.GetEmailAccountByID(task.EmailID)
+	if err != nil {
+		account = nil
+	}
+	return newAttachmentFilter(&config, account).extensions
+}
+
+// zipEntries 检查ZIP内容并返回其中扩展名在 extensions 中的条目
+// 加密的压缩包、条目过多或解压后总大小超过上限时返回错误
+func zipEntries(data []byte, extensions map[string]bool) ([]*zip.File, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("附件不是有效的ZIP文件: %v", err)
+	}
+	if len(reader.File) > maxZipEntries {
+		return nil, fmt.Errorf("ZIP文件条目过多（%d 个，上限 %d 个）", len(reader.File), maxZipEntries)
+	}
+
+	var entries []*zip.File
+	var declared uint64
+	for _, f := range reader.File {
+		if f.Flags&0x1 != 0 {
+			return nil, fmt.Errorf("ZIP文件已加密，无法解压: %s", f.Name)
+		}
+		if f.FileInfo().IsDir() || !extensions[fileExtension(f.Name)] {
+			continue
+		}
+		// 跳过 macOS 压缩时附带的资源文件
+		base := path.Base(f.Name)
+		if strings.HasPrefix(f.Name, "__MACOSX/") || strings.HasPrefix(base, "._") {
+			continue
+		}
+
+		declared += f.UncompressedSize64
+		if declared > maxZipUncompressedSize {
+			return nil, errZipTooLarge
+		}
+		entries = append(entries, f)
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("ZIP文件中未找到允许类型的文件")
+	}
+	return entries, nil
+}
+
+// saveZipData 保存ZIP附件并解压其中允许类型的文件到同名文件夹，解压出的文件记录为该任务的文件
+func (ds *DownloadService) saveZipData(worker *DownloadWorker, data []byte) error {
+	task := worker.Task
+
+	entries, err := zipEntries(data, ds.zipExtractExtensions(task))
+	if err != nil {
+		return err
+	}
+
+	// 保存压缩包本身，校验和等后续处理都针对压缩包
+	if err := os.MkdirAll(filepath.Dir(task.LocalPath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+	tempPath := task.LocalPath + tempFileSuffix
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		return fmt.Errorf("写入临时文件失败: %v", err)
+	}
+	if err := os.Rename(tempPath, task.LocalPath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("完成文件写入失败: %v", err)
+	}
+
+	// 重试时删除上一次解压出的文件，避免产生重复文件
+	if previous, err := ds.db.GetTaskFiles(task.ID); err == nil {
+		for _, file := range previous {
+			os.Remove(file.LocalPath)
+		}
+	}
+
+	extractDir := strings.TrimSuffix(task.LocalPath, filepath.Ext(task.LocalPath))
+	if err := os.MkdirAll(extractDir, 0755); err != nil {
+		return fmt.Errorf("创建解压目录失败: %v", err)
+	}
+
+	var files []models.TaskFile
+	var remaining int64 = maxZipUncompressedSize
+	for _, entry := range entries {
+		file, written, err := ds.extractZipEntry(entry, extractDir, remaining)
+		if err != nil {
+			if errors.Is(err, errZipTooLarge) {
+				return err
+			}
+			ds.taskWarnf(task.ID, "解压 %s 失败，已跳过: %v", entry.Name, err)
+			continue
+		}
+		remaining -= written
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return fmt.Errorf("ZIP文件中没有有效的文件")
+	}
+	if err := ds.db.SaveTaskFiles(task.ID, files); err != nil {
+		return fmt.Errorf("记录解压文件失败: %v", err)
+	}
+	task.IsContainer = true
+	ds.taskInfof(task.ID, "ZIP附件已解压 %d 个文件到 %s", len(files), extractDir)
+
+	worker.Progress <- ProgressUpdate{
+		TaskID:         task.ID,
+		DownloadedSize: int64(len(data)),
+		Progress:       100,
+		Status:         models.StatusCompleted,
+	}
+	return nil
+}
+
+// extractZipEntry 将ZIP中的一个文件解压到目录中，按扩展名检查内容，最多读取 limit 字节，返回记录和实际写入的字节数
+func (ds *DownloadService) extractZipEntry(entry *zip.File, dir string, limit int64) (models.TaskFile, int64, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return models.TaskFile{}, 0, err
+	}
+	defer reader.Close()
+
+	// 只使用条目的文件名，忽略压缩包内的目录结构，避免路径穿越
+	targetPath, err := utils.UniqueFilePath(dir, path.Base(entry.Name))
+	if err != nil {
+		return models.TaskFile{}, 0, err
+	}
+	tempPath := targetPath + tempFileSuffix
+	out, err := os.Create(tempPath)
+	if err != nil {
+		return models.TaskFile{}, 0, err
+	}
+
+	written, err := io.Copy(out, io.LimitReader(reader, limit+1))
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil && written > limit {
+		err = errZipTooLarge
+	}
+	if err == nil {
+		err = validateTaskContent(tempPath, path.Base(entry.Name))
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return models.TaskFile{}, 0, err
+	}
+
+	if err := os.Rename(tempPath, targetPath); err != nil {
+		os.Remove(tempPath)
+		return models.TaskFile{}, 0, err
+	}
+	return models.TaskFile{
+		FileName:  filepath.Base(targetPath),
+		LocalPath: targetPath,
+		FileSize:  written,
+	}, written, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+// TestZipEntriesAllowedExtensions ZIP附件只解压允许类型的条目，跳过目录和 macOS 资源文件
+func TestZipEntriesAllowedExtensions(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range []string{"a.pdf", "b.XLSX", "c.txt", "docs/", "__MACOSX/._a.pdf", "d"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("创建条目失败: %v", err)
+		}
+		f.Write([]byte("content"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("写入ZIP失败: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		extensions map[string]bool
+		want       []string
+	}{
+		{"只允许PDF", map[string]bool{"pdf": true}, []string{"a.pdf"}},
+		{"允许PDF和xlsx", map[string]bool{"pdf": true, "xlsx": true}, []string{"a.pdf", "b.XLSX"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := zipEntries(buf.Bytes(), tt.extensions)
+			if err != nil {
+				t.Fatalf("读取ZIP条目失败: %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("解压的条目为 %v，期望 %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("解压的条目为 %v，期望 %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := zipEntries(buf.Bytes(), map[string]bool{"docx": true}); err == nil {
+		t.Errorf("没有允许类型的条目时应返回错误")
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LinkScoreRule 下载链接评分规则，链接（转为小写后）匹配时累加分数
+type LinkScoreRule struct {
+	Name    string         // 规则名称，便于调试
+	Pattern *regexp.Regexp // 匹配规则
+	Score   int            // 匹配时增加的分数，负数表示降低优先级
+}
+
+// LinkScoreRules 下载链接评分规则，分数高的链接优先尝试
+// 服务商附件域名和 .pdf 直链最可能是正确的文件，预览页和跟踪跳转链接通常下载失败
+var LinkScoreRules = []LinkScoreRule{
+	{"provider-attachment", regexp.MustCompile(`^https?://[^/?#]*(?:ftn\.qq\.com|dfsdown|mail\.qq\.com|mail\.163\.com|mail\.126\.com|googleusercontent\.com|mail\.google\.com|attachments\.office\.net|outlook\.office365\.com)`), 50},
+	{"pdf-path", regexp.MustCompile(`^https?://[^?#]*\.pdf(?:[?#]|$)`), 40},
+	{"pdf-query", regexp.MustCompile(`[?&][^#]*\.pdf`), 15},
+	{"download-path", regexp.MustCompile(`^https?://[^?#]*/[^?#]*(?:download|attachment|attach|getfile)`), 10},
+	{"preview", regexp.MustCompile(`preview|inline|thumbnail|viewer`), -20},
+	{"tracking", regexp.MustCompile(`/click|/track|/redirect|utm_[a-z]+=|/ls/click|[?&]redirect=|[?&]url=`), -40},
+	{"unsubscribe", regexp.MustCompile(`unsubscribe|optout|opt-out`), -100},
+	{"insecure", regexp.MustCompile(`^http://`), -5},
+}
+
+// ScoreLink 按 LinkScoreRules 计算链接得分
+func ScoreLink(link string) int {
+	lower := strings.ToLower(link)
+	score := 0
+	for _, rule := range LinkScoreRules {
+		if rule.Pattern.MatchString(lower) {
+			score += rule.Score
+		}
+	}
+	return score
+}
+
+// RankLinks 按得分从高到低排列链接，得分相同时保持原有顺序，不修改传入的切片
+func RankLinks(links []string) []string {
+	scores := make(map[string]int, len(links))
+	for _, link := range links {
+		scores[link] = ScoreLink(link)
+	}
+
+	ranked := make([]string, len(links))
+	copy(ranked, links)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return scores[ranked[i]] > scores[ranked[j]]
+	})
+	return ranked
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGlobalMemoryStatusEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GlobalMemoryStatusEx")
+
+// memoryStatusEx 对应 Windows 的 MEMORYSTATUSEX 结构
+type memoryStatusEx struct {
+	length               uint32
+	memoryLoad           uint32
+	totalPhys            uint64
+	availPhys            uint64
+	totalPageFile        uint64
+	availPageFile        uint64
+	totalVirtual         uint64
+	availVirtual         uint64
+	availExtendedVirtual uint64
+}
+
+// AvailableMemoryBytes 获取系统当前可用的物理内存（字节）
+func AvailableMemoryBytes() (int64, error) {
+	status := memoryStatusEx{}
+	status.length = uint32(unsafe.Sizeof(status))
+	ret, _, callErr := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status)))
+	if ret == 0 {
+		return 0, callErr
+	}
+	return int64(status.availPhys), nil
+}
@@ -0,0 +1,499 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pdfEncryptRef 文件尾中的加密字典，加密文件的对象内容无法直接复制
+var pdfEncryptRef = regexp.MustCompile(`/Encrypt\s*(\d+\s+\d+\s+R|<<)`)
+
+// pdfPageInherited 页面可以从上级页面树节点继承的属性，合并时复制到页面本身
+var pdfPageInherited = []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+
+// pdfEntry 字典中的一个键值对，值为原始PDF语法
+type pdfEntry struct {
+	key   string
+	value []byte
+}
+
+// pdfPage 页面树中的一个页面：原对象编号和已合并继承属性、去掉 /Parent 的字典项
+type pdfPage struct {
+	num     int
+	entries []pdfEntry
+}
+
+// pdfWriter 按新编号收集合并后PDF的对象，编号0不使用
+type pdfWriter struct {
+	objects [][]byte
+}
+
+// MergePDFs 按给定顺序把多个PDF的页面合并为一个新的PDF
+// 只复制页面及其引用的资源，书签、表单和文档信息等文档级内容不保留；加密或无法解析的文件返回错误
+func MergePDFs(documents [][]byte) (_ []byte, err error) {
+	defer recoverPDFPanic(&err)
+
+	if len(documents) < 2 {
+		return nil, fmt.Errorf("至少需要两个PDF文件")
+	}
+
+	w := &pdfWriter{objects: make([][]byte, 1)}
+	catalog := w.reserve()
+	root := w.reserve()
+
+	var kids []string
+	for index, data := range documents {
+		if !IsPDFContent(data) {
+			return nil, fmt.Errorf("第 %d 个文件不是有效的PDF", index+1)
+		}
+		if pdfEncryptRef.Match(data) {
+			return nil, fmt.Errorf("第 %d 个文件已加密，无法合并", index+1)
+		}
+		f := parsePDF(data)
+		pages, err := f.pages()
+		if err != nil {
+			return nil, fmt.Errorf("读取第 %d 个文件的页面失败: %v", index+1, err)
+		}
+		nums, err := w.copyDocument(f, pages, root)
+		if err != nil {
+			return nil, fmt.Errorf("复制第 %d 个文件失败: %v", index+1, err)
+		}
+		for _, num := range nums {
+			kids = append(kids, fmt.Sprintf("%d 0 R", num))
+		}
+	}
+
+	w.objects[catalog] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", root))
+	w.objects[root] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	merged := w.bytes(catalog)
+
+	// 合并结果必须能被重新解析出全部页面，否则视为失败，不输出可能损坏的文件
+	if count := parsePDF(merged).pageCount(); count != len(kids) {
+		return nil, fmt.Errorf("合并结果页数不符（%d/%d）", count, len(kids))
+	}
+	return merged, nil
+}
+
+// pages 按页面树顺序返回全部页面
+func (f *pdfFile) pages() ([]pdfPage, error) {
+	catalog, ok := pdfDictEntries(f.trailerObject(pdfRootRef))
+	if !ok {
+		return nil, fmt.Errorf("未找到文档目录")
+	}
+	num, _, ok := pdfRefAt(pdfEntryValue(catalog, "Pages"), 0)
+	if !ok {
+		return nil, fmt.Errorf("未找到页面树")
+	}
+
+	var pages []pdfPage
+	if err := f.collectPages(num, map[string][]byte{}, 0, &pages); err != nil {
+		return nil, err
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("文件没有页面")
+	}
+	return pages, nil
+}
+
+// collectPages 深度优先遍历页面树，把上级节点的可继承属性复制到页面中
+func (f *pdfFile) collectPages(num int, inherited map[string][]byte, depth int, pages *[]pdfPage) error {
+	if depth > maxPageTreeDepth {
+		return fmt.Errorf("页面树层级过深")
+	}
+	entries, ok := pdfDictEntries(f.objects[num])
+	if !ok {
+		return fmt.Errorf("页面树节点 %d 无法解析", num)
+	}
+
+	kids := pdfEntryValue(entries, "Kids")
+	if kids == nil {
+		page := pdfPage{num: num}
+		for _, entry := range entries {
+			if entry.key != "Parent" {
+				page.entries = append(page.entries, entry)
+			}
+		}
+		for _, key := range pdfPageInherited {
+			if pdfEntryValue(entries, key) == nil && inherited[key] != nil {
+				page.entries = append(page.entries, pdfEntry{key: key, value: inherited[key]})
+			}
+		}
+		*pages = append(*pages, page)
+		return nil
+	}
+
+	next := make(map[string][]byte, len(pdfPageInherited))
+	for _, key := range pdfPageInherited {
+		next[key] = inherited[key]
+		if value := pdfEntryValue(entries, key); value != nil {
+			next[key] = value
+		}
+	}
+	for _, m := range pdfRefPattern.FindAllSubmatch(f.resolve(kids), -1) {
+		kid, err := strconv.Atoi(string(m[1]))
+		if err != nil || kid == num {
+			continue
+		}
+		if err := f.collectPages(kid, next, depth+1, pages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reserve 分配一个新的对象编号
+func (w *pdfWriter) reserve() int {
+	w.objects = append(w.objects, nil)
+	return len(w.objects) - 1
+}
+
+// copyDocument 复制文档的页面和页面引用到的全部对象，返回新的页面对象编号
+func (w *pdfWriter) copyDocument(f *pdfFile, pages []pdfPage, parent int) ([]int, error) {
+	mapping := make(map[int]int)
+	var queue []int
+	renumber := func(num int) int {
+		if n, ok := mapping[num]; ok {
+			return n
+		}
+		n := w.reserve()
+		mapping[num] = n
+		queue = append(queue, num)
+		return n
+	}
+
+	// 先为页面分配编号，其他对象（如注释的 /P）引用原页面时指向复制后的页面
+	nums := make([]int, len(pages))
+	isPage := make(map[int]bool, len(pages))
+	for i, page := range pages {
+		nums[i] = renumber(page.num)
+		isPage[page.num] = true
+	}
+	for i, page := range pages {
+		dict := rewritePDFRefs(formatPDFDict(page.entries), renumber)
+		w.objects[nums[i]] = append(dict[:len(dict)-2], []byte(fmt.Sprintf(" /Parent %d 0 R >>", parent))...)
+	}
+
+	for i := 0; i < len(queue); i++ {
+		num := queue[i]
+		if isPage[num] {
+			continue
+		}
+		body, err := f.copyObject(num, renumber)
+		if err != nil {
+			return nil, err
+		}
+		w.objects[mapping[num]] = body
+	}
+	return nums, nil
+}
+
+// copyObject 复制一个对象并改写其中的引用；流对象按实际数据重写 /Length，流数据原样保留
+func (f *pdfFile) copyObject(num int, renumber func(int) int) ([]byte, error) {
+	body, ok := f.objects[num]
+	if !ok {
+		return []byte("null"), nil
+	}
+	end := pdfValueEnd(body, 0)
+	if end < 0 {
+		return nil, fmt.Errorf("对象 %d 语法无效", num)
+	}
+	rest := bytes.TrimLeft(body[end:], pdfWhitespace)
+	if !bytes.HasPrefix(rest, []byte("stream")) {
+		return rewritePDFRefs(body[:end], renumber), nil
+	}
+
+	entries, ok := pdfDictEntries(body[:end])
+	if !ok {
+		return nil, fmt.Errorf("流对象 %d 的字典无效", num)
+	}
+	_, data, _ := splitPDFStream(rest)
+	data = f.trimStream(data, pdfEntryValue(entries, "Length"))
+
+	var kept []pdfEntry
+	for _, entry := range entries {
+		if entry.key != "Length" {
+			kept = append(kept, entry)
+		}
+	}
+	dict := rewritePDFRefs(formatPDFDict(kept), renumber)
+
+	var buf bytes.Buffer
+	buf.Write(dict[:len(dict)-2])
+	fmt.Fprintf(&buf, " /Length %d >>\nstream\n", len(data))
+	buf.Write(data)
+	buf.WriteString("\nendstream")
+	return buf.Bytes(), nil
+}
+
+// bytes 按编号顺序输出对象、交叉引用表和文件尾
+func (w *pdfWriter) bytes(catalog int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(w.objects))
+	for num := 1; num < len(w.objects); num++ {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", num)
+		if w.objects[num] == nil {
+			buf.WriteString("null")
+		} else {
+			buf.Write(w.objects[num])
+		}
+		buf.WriteString("\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.objects))
+	for num := 1; num < len(w.objects); num++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[num])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.objects), catalog, xref)
+	return buf.Bytes()
+}
+
+// formatPDFDict 把键值对重新组成字典，结尾固定为 ">>"
+func formatPDFDict(entries []pdfEntry) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("<<")
+	for _, entry := range entries {
+		buf.WriteString(" /")
+		buf.WriteString(entry.key)
+		buf.WriteByte(' ')
+		buf.Write(entry.value)
+	}
+	buf.WriteString(" >>")
+	return buf.Bytes()
+}
+
+// pdfDictEntries 解析字典最外层的键值对，保持原有顺序；不是字典或语法无效时返回false
+func pdfDictEntries(dict []byte) ([]pdfEntry, bool) {
+	i := pdfSkipSpace(dict, 0)
+	if !bytes.HasPrefix(dict[i:], []byte("<<")) {
+		return nil, false
+	}
+
+	var entries []pdfEntry
+	for i += 2; ; {
+		i = pdfSkipSpace(dict, i)
+		if bytes.HasPrefix(dict[i:], []byte(">>")) {
+			return entries, true
+		}
+		if i >= len(dict) || dict[i] != '/' {
+			return nil, false
+		}
+		keyEnd := pdfValueEnd(dict, i)
+		valueStart := pdfSkipSpace(dict, keyEnd)
+		valueEnd := pdfValueEnd(dict, valueStart)
+		if valueEnd < 0 {
+			return nil, false
+		}
+		entries = append(entries, pdfEntry{key: string(dict[i+1 : keyEnd]), value: dict[valueStart:valueEnd]})
+		i = valueEnd
+	}
+}
+
+// pdfEntryValue 返回键对应的值，键不存在时返回nil
+func pdfEntryValue(entries []pdfEntry, key string) []byte {
+	for _, entry := range entries {
+		if entry.key == key {
+			return entry.value
+		}
+	}
+	return nil
+}
+
+// pdfSkipSpace 跳过空白和注释，返回下一个有效字符的位置
+func pdfSkipSpace(b []byte, i int) int {
+	for i < len(b) {
+		switch {
+		case isPDFSpace(b[i]):
+			i++
+		case b[i] == '%':
+			for i < len(b) && b[i] != '\n' && b[i] != '\r' {
+				i++
+			}
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// pdfValueEnd 返回从位置 i 开始的一个完整值（字典、数组、字符串、名称、引用、数字或关键字）的结束位置，语法无效时返回-1
+func pdfValueEnd(b []byte, i int) int {
+	i = pdfSkipSpace(b, i)
+	if i >= len(b) {
+		return -1
+	}
+
+	switch c := b[i]; {
+	case c == '<' && i+1 < len(b) && b[i+1] == '<':
+		for i += 2; ; {
+			i = pdfSkipSpace(b, i)
+			if bytes.HasPrefix(b[i:], []byte(">>")) {
+				return i + 2
+			}
+			if i = pdfValueEnd(b, i); i < 0 {
+				return -1
+			}
+		}
+	case c == '[':
+		for i++; ; {
+			i = pdfSkipSpace(b, i)
+			if i < len(b) && b[i] == ']' {
+				return i + 1
+			}
+			if i = pdfValueEnd(b, i); i < 0 {
+				return -1
+			}
+		}
+	case c == '(':
+		return pdfStringEnd(b, i)
+	case c == '<':
+		if end := bytes.IndexByte(b[i:], '>'); end >= 0 {
+			return i + end + 1
+		}
+		return -1
+	case c == '/':
+		for i++; i < len(b) && !isPDFDelimiter(b[i]); i++ {
+		}
+		return i
+	case isPDFDelimiter(c):
+		return -1
+	}
+
+	if _, end, ok := pdfRefAt(b, i); ok {
+		return end
+	}
+	start := i
+	for i < len(b) && !isPDFDelimiter(b[i]) {
+		i++
+	}
+	if i == start {
+		return -1
+	}
+	return i
+}
+
+// pdfStringEnd 返回从位置 i 的 "(" 开始的字面字符串的结束位置，未闭合时返回-1
+func pdfStringEnd(b []byte, i int) int {
+	depth := 0
+	for i++; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// pdfRefAt 判断位置 i 是否为间接引用 "编号 版本 R"，返回对象编号和引用的结束位置
+func pdfRefAt(b []byte, i int) (int, int, bool) {
+	digits := func(j int) int {
+		for j < len(b) && b[j] >= '0' && b[j] <= '9' {
+			j++
+		}
+		return j
+	}
+	spaces := func(j int) int {
+		for j < len(b) && isPDFSpace(b[j]) {
+			j++
+		}
+		return j
+	}
+
+	numEnd := digits(i)
+	genStart := spaces(numEnd)
+	genEnd := digits(genStart)
+	r := spaces(genEnd)
+	if numEnd == i || genStart == numEnd || genEnd == genStart || r == genEnd || r >= len(b) || b[r] != 'R' {
+		return 0, 0, false
+	}
+	if r+1 < len(b) && !isPDFDelimiter(b[r+1]) {
+		return 0, 0, false
+	}
+	num, err := strconv.Atoi(string(b[i:numEnd]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return num, r + 1, true
+}
+
+// rewritePDFRefs 按 renumber 改写内容中字符串以外的间接引用
+func rewritePDFRefs(b []byte, renumber func(int) int) []byte {
+	var out bytes.Buffer
+	last := 0
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '(':
+			if i = pdfStringEnd(b, i); i < 0 {
+				i = len(b)
+			}
+		case c == '<' && i+1 < len(b) && b[i+1] == '<':
+			i += 2
+		case c == '<':
+			if end := bytes.IndexByte(b[i:], '>'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(b)
+			}
+		case c >= '0' && c <= '9' && (i == 0 || isPDFDelimiter(b[i-1])):
+			if num, end, ok := pdfRefAt(b, i); ok {
+				out.Write(b[last:i])
+				fmt.Fprintf(&out, "%d 0 R", renumber(num))
+				last, i = end, end
+				continue
+			}
+			for i < len(b) && b[i] >= '0' && b[i] <= '9' {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	out.Write(b[last:])
+	return out.Bytes()
+}
+
+// directStreamEnd 流对象的 /Length 为直接数值时返回流数据结束的位置
+// 解析时据此跳过流数据，避免二进制内容中形似 "obj" 或 "endobj" 的字节被误认
+func directStreamEnd(body []byte) (int, bool) {
+	end := pdfValueEnd(body, 0)
+	if end < 0 {
+		return 0, false
+	}
+	rest := bytes.TrimLeft(body[end:], pdfWhitespace)
+	if !bytes.HasPrefix(rest, []byte("stream")) {
+		return 0, false
+	}
+	entries, ok := pdfDictEntries(body[:end])
+	if !ok {
+		return 0, false
+	}
+	length := pdfEntryValue(entries, "Length")
+	if _, _, isRef := pdfRefAt(length, 0); isRef {
+		return 0, false
+	}
+	n, ok := pdfInt(length)
+	_, data, _ := splitPDFStream(rest)
+	if !ok || n > len(data) {
+		return 0, false
+	}
+	return len(body) - len(data) + n, true
+}
+
+// isPDFSpace 判断字符是否为PDF空白字符
+func isPDFSpace(c byte) bool {
+	return strings.IndexByte(pdfWhitespace, c) >= 0
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testPageDocument 生成每页内容流分别为 texts 的PDF，页面直接挂在根页面树节点下
+func testPageDocument(texts ...string) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for _, text := range texts {
+		page := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Contents %d 0 R >>", page+1),
+			testStream("", []byte("BT ("+text+") Tj ET"), true))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(texts))
+	return buildTestPDF("/Root 1 0 R", objects...)
+}
+
+// mergedPageTexts 重新解析合并结果，按页面顺序返回各页内容流解码后的内容
+func mergedPageTexts(t *testing.T, merged []byte) []string {
+	t.Helper()
+
+	f := parsePDF(merged)
+	pages, err := f.pages()
+	if err != nil {
+		t.Fatalf("重新解析合并结果失败: %v", err)
+	}
+	texts := make([]string, len(pages))
+	for i, page := range pages {
+		content, err := f.streamContent(f.resolve(pdfEntryValue(page.entries, "Contents")))
+		if err != nil {
+			t.Fatalf("读取第 %d 页内容失败: %v", i+1, err)
+		}
+		texts[i] = string(content)
+	}
+	return texts
+}
+
+// checkXref 检查交叉引用表中每个对象的偏移都指向该对象的开头
+func checkXref(t *testing.T, data []byte) {
+	t.Helper()
+
+	start := bytes.LastIndex(data, []byte("\nxref\n"))
+	if start < 0 {
+		t.Fatal("合并结果没有交叉引用表")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[start:], -1)
+	if len(entries) == 0 {
+		t.Fatal("交叉引用表为空")
+	}
+	for i, m := range entries {
+		offset, _ := strconv.Atoi(string(m[1]))
+		want := fmt.Sprintf("%d 0 obj", i+1)
+		if !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("对象 %d 的偏移 %d 处不是 %q", i+1, offset, want)
+		}
+	}
+}
+
+// TestMergePDFsMultiPage 多页文档按顺序合并，页面内容和交叉引用表保持正确
+func TestMergePDFsMultiPage(t *testing.T) {
+	merged, err := MergePDFs([][]byte{testPageDocument("A1", "A2"), testPageDocument("B1"), testPageDocument("C1", "C2")})
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	if !bytes.HasPrefix(merged, []byte("%PDF-")) || !bytes.HasSuffix(bytes.TrimSpace(merged), []byte("%%EOF")) {
+		t.Fatal("合并结果缺少PDF文件头或结束标记")
+	}
+	checkXref(t, merged)
+
+	want := []string{"A1", "A2", "B1", "C1", "C2"}
+	texts := mergedPageTexts(t, merged)
+	if len(texts) != len(want) {
+		t.Fatalf("合并结果有 %d 页，期望 %d 页", len(texts), len(want))
+	}
+	for i, text := range texts {
+		if !strings.Contains(text, "("+want[i]+")") {
+			t.Errorf("第 %d 页内容为 %q，期望包含 %s", i+1, text, want[i])
+		}
+	}
+	if metadata, err := ReadPDFMetadata(merged); err != nil || metadata.PageCount != len(want) {
+		t.Errorf("合并结果页数为 %d（%v），期望 %d", metadata.PageCount, err, len(want))
+	}
+}
+
+// TestMergePDFsObjectStreams 目录、页面树和页面压缩在对象流中的文档可以合并
+func TestMergePDFsObjectStreams(t *testing.T) {
+	// 对象3、4、5只出现在对象流中
+	compressed := buildTestPDF("/Root 3 0 R",
+		testStream("", []byte("BT (Packed) Tj ET"), true),
+		testObjectStream(map[int]string{
+			3: "<< /Type /Catalog /Pages 4 0 R >>",
+			4: "<< /Type /Pages /Kids [5 0 R] /Count 1 >>",
+			5: "<< /Type /Page /Parent 4 0 R /MediaBox [0 0 200 200] /Contents 1 0 R >>",
+		}, []int{3, 4, 5}))
+
+	merged, err := MergePDFs([][]byte{testPageDocument("Plain"), compressed})
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	checkXref(t, merged)
+
+	texts := mergedPageTexts(t, merged)
+	if len(texts) != 2 || !strings.Contains(texts[0], "(Plain)") || !strings.Contains(texts[1], "(Packed)") {
+		t.Errorf("合并结果的页面内容为 %q，期望 Plain、Packed", texts)
+	}
+	if bytes.Contains(merged, []byte("/ObjStm")) {
+		t.Error("对象流本身不应复制到合并结果中")
+	}
+}
+
+// TestMergePDFsInheritedResources 从上级页面树节点继承的资源和页面尺寸复制到合并后的页面中
+func TestMergePDFsInheritedResources(t *testing.T) {
+	inherited := buildTestPDF("/Root 1 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /MediaBox [0 0 300 400] /Resources << /Font << /F1 5 0 R >> >> >>",
+		"<< /Type /Pages /Parent 2 0 R /Kids [4 0 R] /Count 1 /Rotate 90 >>",
+		"<< /Type /Page /Parent 3 0 R /Contents 6 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		testStream("", []byte("BT /F1 12 Tf (Inherited) Tj ET"), false))
+
+	merged, err := MergePDFs([][]byte{inherited, testPageDocument("Other")})
+	if err != nil {
+		t.Fatalf("合并失败: %v", err)
+	}
+	checkXref(t, merged)
+
+	f := parsePDF(merged)
+	pages, err := f.pages()
+	if err != nil || len(pages) != 2 {
+		t.Fatalf("重新解析合并结果失败: %d 页（%v）", len(pages), err)
+	}
+	page := pages[0].entries
+	if box := string(pdfEntryValue(page, "MediaBox")); box != "[0 0 300 400]" {
+		t.Errorf("页面尺寸为 %q，期望继承 [0 0 300 400]", box)
+	}
+	if rotate := string(pdfEntryValue(page, "Rotate")); rotate != "90" {
+		t.Errorf("旋转角度为 %q，期望继承 90", rotate)
+	}
+	fonts := f.resolve(pdfKeyValue(pdfEntryValue(page, "Resources"), "Font"))
+	font := f.resolve(pdfKeyValue(fonts, "F1"))
+	if !bytes.Contains(font, []byte("/BaseFont /Helvetica")) {
+		t.Errorf("继承的字体资源没有复制到合并结果中: %q", font)
+	}
+	if text := mergedPageTexts(t, merged)[0]; !strings.Contains(text, "(Inherited)") {
+		t.Errorf("第 1 页内容为 %q", text)
+	}
+}
+
+// TestMergePDFsRejectsInvalidInput 加密、不是PDF或只有一个文件时返回错误
+func TestMergePDFsRejectsInvalidInput(t *testing.T) {
+	encrypted := buildTestPDF("/Root 1 0 R /Encrypt 5 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R >>",
+		"",
+		"<< /Filter /Standard /V 2 /R 3 >>")
+
+	tests := []struct {
+		name      string
+		documents [][]byte
+		wantErr   string
+	}{
+		{name: "加密文件", documents: [][]byte{testPageDocument("A"), encrypted}, wantErr: "加密"},
+		{name: "不是PDF", documents: [][]byte{testPageDocument("A"), []byte("<html></html>")}, wantErr: "不是有效的PDF"},
+		{name: "没有页面树", documents: [][]byte{testPageDocument("A"), buildTestPDF("", "<< /Title (x) >>")}, wantErr: "文档目录"},
+		{name: "只有一个文件", documents: [][]byte{testPageDocument("A")}, wantErr: "至少需要两个"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MergePDFs(tt.documents)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("返回 %v，期望包含 %q 的错误", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,494 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PDFMetadata PDF文档信息字典中的标题、作者和页数
+type PDFMetadata struct {
+	Title     string
+	Author    string
+	PageCount int
+}
+
+const (
+	// pdfWhitespace PDF语法中的空白字符
+	pdfWhitespace = " \t\r\n\f\x00"
+	// maxObjectStreamSize 解压对象流的大小上限，避免异常文件占用过多内存
+	maxObjectStreamSize = 32 << 20
+	// maxPageTreeDepth 查找第一页时页面树的最大深度
+	maxPageTreeDepth = 32
+)
+
+var (
+	// pdfObjectPattern 间接对象的开头 "编号 版本 obj"
+	pdfObjectPattern = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	// pdfRefPattern 间接引用 "编号 版本 R"
+	pdfRefPattern = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	// pdfInfoRef 文件尾（或交叉引用流）中指向文档信息字典的引用
+	pdfInfoRef = regexp.MustCompile(`/Info\s+(\d+\s+\d+\s+R)`)
+	// pdfRootRef 文件尾（或交叉引用流）中指向文档目录的引用
+	pdfRootRef = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	// pdfPagesType 页面树的中间节点
+	pdfPagesType = regexp.MustCompile(`/Type\s*/Pages\b`)
+	// pdfPageType 页面对象（不匹配 /Pages）
+	pdfPageType = regexp.MustCompile(`/Type\s*/Page\b`)
+	// pdfObjStmType 压缩了其他对象的对象流
+	pdfObjStmType = regexp.MustCompile(`/Type\s*/ObjStm\b`)
+	// pdfImageSubtype 图像类型的XObject
+	pdfImageSubtype = regexp.MustCompile(`/Subtype\s*/Image\b`)
+)
+
+// pdfFile 按对象编号索引的PDF内容，只做读取元数据所需的简单解析
+type pdfFile struct {
+	data    []byte
+	objects map[int][]byte
+}
+
+// parsePDF 收集PDF中的间接对象，包括压缩在对象流中的对象
+// 增量更新追加的同号对象覆盖前面的版本
+func parsePDF(data []byte) *pdfFile {
+	f := &pdfFile{data: data, objects: make(map[int][]byte)}
+
+	skipUntil := 0
+	for _, m := range pdfObjectPattern.FindAllSubmatchIndex(data, -1) {
+		if m[0] < skipUntil {
+			// 位于上一个对象的流数据中
+			continue
+		}
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil {
+			continue
+		}
+		body := data[m[1]:]
+		start := 0
+		if streamEnd, ok := directStreamEnd(body); ok {
+			start = streamEnd
+			skipUntil = m[1] + streamEnd
+		}
+		if end := bytes.Index(body[start:], []byte("endobj")); end >= 0 {
+			body = body[:start+end]
+		}
+		f.objects[num] = bytes.TrimLeft(body, pdfWhitespace)
+	}
+
+	var streams [][]byte
+	for _, body := range f.objects {
+		if dict, _, ok := splitPDFStream(body); ok && pdfObjStmType.Match(dict) {
+			streams = append(streams, body)
+		}
+	}
+	for _, body := range streams {
+		f.expandObjectStream(body)
+	}
+	return f
+}
+
+// expandObjectStream 解压对象流，把其中尚未出现的对象加入索引
+func (f *pdfFile) expandObjectStream(body []byte) {
+	content, err := f.streamContent(body)
+	if err != nil {
+		return
+	}
+	dict, _, _ := splitPDFStream(body)
+	count, _ := pdfInt(f.resolve(pdfKeyValue(dict, "N")))
+	first, ok := pdfInt(f.resolve(pdfKeyValue(dict, "First")))
+	if !ok || first > len(content) {
+		return
+	}
+
+	header := bytes.Fields(content[:first])
+	for i := 0; i+1 < len(header) && i/2 < count; i += 2 {
+		num, err1 := strconv.Atoi(string(header[i]))
+		offset, err2 := strconv.Atoi(string(header[i+1]))
+		if err1 != nil || err2 != nil || offset < 0 {
+			continue
+		}
+		start, end := first+offset, len(content)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(string(header[i+3])); err == nil {
+				end = first + next
+			}
+		}
+		if start < end && end <= len(content) {
+			if _, exists := f.objects[num]; !exists {
+				f.objects[num] = bytes.TrimLeft(content[start:end], pdfWhitespace)
+			}
+		}
+	}
+}
+
+// splitPDFStream 把流对象分为字典和原始流数据，不是流对象时返回false
+func splitPDFStream(body []byte) ([]byte, []byte, bool) {
+	i := bytes.Index(body, []byte("stream"))
+	if i < 0 {
+		return body, nil, false
+	}
+	data := body[i+len("stream"):]
+	if bytes.HasPrefix(data, []byte("\r\n")) {
+		data = data[2:]
+	} else if len(data) > 0 && (data[0] == '\n' || data[0] == '\r') {
+		data = data[1:]
+	}
+	return body[:i], data, true
+}
+
+// streamData 返回流对象的原始数据，优先按 /Length 截取，长度无效时截取到 endstream
+func (f *pdfFile) streamData(body []byte) ([]byte, bool) {
+	dict, data, ok := splitPDFStream(body)
+	if !ok {
+		return nil, false
+	}
+	return f.trimStream(data, pdfKeyValue(dict, "Length")), true
+}
+
+// trimStream 按 /Length 截取流数据，长度无效时截取到 endstream 之前的换行
+func (f *pdfFile) trimStream(data, length []byte) []byte {
+	if n, ok := pdfInt(f.resolve(length)); ok && n <= len(data) {
+		return data[:n]
+	}
+	if end := bytes.LastIndex(data, []byte("endstream")); end >= 0 {
+		data = bytes.TrimSuffix(bytes.TrimSuffix(data[:end], []byte("\n")), []byte("\r"))
+	}
+	return data
+}
+
+// streamContent 返回流对象解码后的内容，只支持未压缩和 FlateDecode 的流
+func (f *pdfFile) streamContent(body []byte) ([]byte, error) {
+	dict, _, _ := splitPDFStream(body)
+	data, ok := f.streamData(body)
+	if !ok {
+		return nil, fmt.Errorf("不是流对象")
+	}
+
+	filter := pdfKeyValue(dict, "Filter")
+	if filter == nil {
+		return data, nil
+	}
+	if !bytes.HasPrefix(filter, []byte("/FlateDecode")) {
+		return nil, fmt.Errorf("不支持的流压缩方式")
+	}
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxObjectStreamSize))
+}
+
+// resolve 值为间接引用时返回引用的对象内容，否则原样返回；引用的对象不存在时返回nil
+func (f *pdfFile) resolve(value []byte) []byte {
+	if m := pdfRefPattern.FindSubmatchIndex(value); m != nil && m[0] == 0 {
+		num, err := strconv.Atoi(string(value[m[2]:m[3]]))
+		if err != nil {
+			return nil
+		}
+		return f.objects[num]
+	}
+	return value
+}
+
+// trailerObject 按文件尾中最后一个（最新版本的）引用查找对象
+func (f *pdfFile) trailerObject(pattern *regexp.Regexp) []byte {
+	matches := pattern.FindAllSubmatch(f.data, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	return f.resolve(matches[len(matches)-1][1])
+}
+
+// pageCount 返回页面树根节点的 /Count，无法读取时按页面对象计数
+func (f *pdfFile) pageCount() int {
+	if catalog := f.trailerObject(pdfRootRef); catalog != nil {
+		pages := f.resolve(pdfKeyValue(catalog, "Pages"))
+		if count, ok := pdfInt(f.resolve(pdfKeyValue(pages, "Count"))); ok && count > 0 {
+			return count
+		}
+	}
+
+	count := 0
+	for _, body := range f.objects {
+		dict, _, _ := splitPDFStream(body)
+		if pdfPageType.Match(dict) {
+			count++
+		}
+	}
+	return count
+}
+
+// firstPage 沿页面树的第一个子节点查找第一页
+func (f *pdfFile) firstPage() []byte {
+	catalog := f.trailerObject(pdfRootRef)
+	if catalog == nil {
+		return nil
+	}
+
+	node := f.resolve(pdfKeyValue(catalog, "Pages"))
+	for depth := 0; node != nil && depth < maxPageTreeDepth; depth++ {
+		if !pdfPagesType.Match(node) {
+			if pdfPageType.Match(node) {
+				return node
+			}
+			return nil
+		}
+		kids := pdfKeyValue(node, "Kids")
+		if end := bytes.IndexByte(kids, ']'); end >= 0 {
+			kids = kids[:end]
+		}
+		kids = bytes.TrimLeft(kids, "["+pdfWhitespace)
+		node = f.resolve(kids)
+	}
+	return nil
+}
+
+// recoverPDFPanic 把解析异常PDF时发生的panic转换为错误，PDF来自邮件，内容不可信
+func recoverPDFPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("解析PDF失败: %v", r)
+	}
+}
+
+// ReadPDFMetadata 从PDF内容中读取文档信息字典的标题、作者和页数
+func ReadPDFMetadata(data []byte) (metadata PDFMetadata, err error) {
+	defer recoverPDFPanic(&err)
+
+	if !IsPDFContent(data) {
+		return PDFMetadata{}, fmt.Errorf("不是有效的PDF文件")
+	}
+
+	f := parsePDF(data)
+	metadata = PDFMetadata{PageCount: f.pageCount()}
+	if info := f.trailerObject(pdfInfoRef); info != nil {
+		metadata.Title = f.textValue(info, "Title")
+		metadata.Author = f.textValue(info, "Author")
+	}
+	return metadata, nil
+}
+
+// textValue 读取字典中的文本字符串值
+func (f *pdfFile) textValue(dict []byte, key string) string {
+	raw, ok := pdfString(f.resolve(pdfKeyValue(dict, key)))
+	if !ok {
+		return ""
+	}
+	return decodePDFText(raw)
+}
+
+// PDFThumbnail 生成PDF第一页图像的JPEG缩略图，最长边不超过 maxSide 像素
+// 只处理第一页中以JPEG（DCTDecode）嵌入的图像，适用于扫描件等整页为图片的文档，没有这样的图像时返回错误
+func PDFThumbnail(data []byte, maxSide int) (thumbnail []byte, err error) {
+	defer recoverPDFPanic(&err)
+
+	f := parsePDF(data)
+	page := f.firstPage()
+	if page == nil {
+		return nil, fmt.Errorf("未找到第一页")
+	}
+
+	resources := f.resolve(pdfKeyValue(page, "Resources"))
+	xobjects := f.resolve(pdfKeyValue(resources, "XObject"))
+	if end := bytes.Index(xobjects, []byte(">>")); end >= 0 {
+		xobjects = xobjects[:end]
+	}
+
+	for _, m := range pdfRefPattern.FindAll(xobjects, -1) {
+		body := f.resolve(m)
+		dict, _, ok := splitPDFStream(body)
+		if !ok || !pdfImageSubtype.Match(dict) {
+			continue
+		}
+		filter := pdfKeyValue(dict, "Filter")
+		if !bytes.HasPrefix(bytes.TrimLeft(filter, "["+pdfWhitespace), []byte("/DCTDecode")) {
+			continue
+		}
+		stream, _ := f.streamData(body)
+		img, err := jpeg.Decode(bytes.NewReader(stream))
+		if err != nil {
+			continue
+		}
+		return encodeThumbnail(img, maxSide)
+	}
+	return nil, fmt.Errorf("第一页没有可用于缩略图的图像")
+}
+
+// encodeThumbnail 按比例缩小图像（不放大）并编码为JPEG
+func encodeThumbnail(img image.Image, maxSide int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("图像尺寸无效")
+	}
+
+	targetWidth, targetHeight := width, height
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			targetWidth, targetHeight = maxSide, height*maxSide/width
+		} else {
+			targetWidth, targetHeight = width*maxSide/height, maxSide
+		}
+		if targetWidth < 1 {
+			targetWidth = 1
+		}
+		if targetHeight < 1 {
+			targetHeight = 1
+		}
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	for y := 0; y < targetHeight; y++ {
+		for x := 0; x < targetWidth; x++ {
+			thumbnail.Set(x, y, img.At(bounds.Min.X+x*width/targetWidth, bounds.Min.Y+y*height/targetHeight))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pdfKeyValue 返回字典中键之后的内容（从值的开头到字典末尾），键不存在时返回nil
+func pdfKeyValue(dict []byte, key string) []byte {
+	name := []byte("/" + key)
+	for start := 0; start < len(dict); {
+		i := bytes.Index(dict[start:], name)
+		if i < 0 {
+			return nil
+		}
+		end := start + i + len(name)
+		if end >= len(dict) || isPDFDelimiter(dict[end]) {
+			return bytes.TrimLeft(dict[end:], pdfWhitespace)
+		}
+		start = end
+	}
+	return nil
+}
+
+// isPDFDelimiter 判断字符是否为空白或PDF分隔符
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte(pdfWhitespace+"()<>[]{}/%"), c) >= 0
+}
+
+// pdfInt 读取值开头的非负整数
+func pdfInt(value []byte) (int, bool) {
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	if end == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(value[:end]))
+	return n, err == nil
+}
+
+// pdfString 解析值开头的字面字符串 (...) 或十六进制字符串 <...>，返回原始字节
+func pdfString(value []byte) ([]byte, bool) {
+	if len(value) == 0 {
+		return nil, false
+	}
+
+	switch value[0] {
+	case '(':
+		var out []byte
+		depth := 0
+		for i := 1; i < len(value); i++ {
+			c := value[i]
+			switch {
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch e := value[i]; e {
+				case 'n':
+					out = append(out, '\n')
+				case 'r':
+					out = append(out, '\r')
+				case 't':
+					out = append(out, '\t')
+				case 'b':
+					out = append(out, '\b')
+				case 'f':
+					out = append(out, '\f')
+				case '\r':
+					// 行尾的反斜杠表示续行
+					if i+1 < len(value) && value[i+1] == '\n' {
+						i++
+					}
+				case '\n':
+				case '0', '1', '2', '3', '4', '5', '6', '7':
+					n := int(e - '0')
+					for k := 0; k < 2 && i+1 < len(value) && value[i+1] >= '0' && value[i+1] <= '7'; k++ {
+						i++
+						n = n*8 + int(value[i]-'0')
+					}
+					out = append(out, byte(n))
+				default:
+					out = append(out, e)
+				}
+			case c == '(':
+				depth++
+				out = append(out, c)
+			case c == ')':
+				if depth == 0 {
+					return out, true
+				}
+				depth--
+				out = append(out, c)
+			default:
+				out = append(out, c)
+			}
+		}
+		return nil, false
+	case '<':
+		end := bytes.IndexByte(value, '>')
+		if end < 0 || (len(value) > 1 && value[1] == '<') {
+			return nil, false
+		}
+		digits := bytes.Map(func(r rune) rune {
+			if bytes.ContainsRune([]byte(pdfWhitespace), r) {
+				return -1
+			}
+			return r
+		}, value[1:end])
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		out, err := hex.DecodeString(string(digits))
+		return out, err == nil
+	}
+	return nil, false
+}
+
+// decodePDFText 解码PDF文本字符串：带BOM的UTF-16BE或UTF-8，否则按UTF-8或Latin-1（近似PDFDocEncoding）处理
+func decodePDFText(raw []byte) string {
+	var text string
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xfe, 0xff}):
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		text = string(utf16.Decode(units))
+	case bytes.HasPrefix(raw, []byte{0xef, 0xbb, 0xbf}):
+		text = string(raw[3:])
+	case utf8.Valid(raw):
+		text = string(raw)
+	default:
+		runes := make([]rune, len(raw))
+		for i, b := range raw {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	return string(bytes.Trim([]byte(text), pdfWhitespace))
+}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// buildTestPDF 按顺序写入间接对象（第 i 个对象编号为 i+1）、交叉引用表和文件尾，生成测试用的PDF
+func buildTestPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return b.Bytes()
+}
+
+// testStream 生成流对象，compress 为 true 时使用 FlateDecode 压缩
+func testStream(dict string, content []byte, compress bool) string {
+	if compress {
+		var b bytes.Buffer
+		w := zlib.NewWriter(&b)
+		w.Write(content)
+		w.Close()
+		content = b.Bytes()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(content), content)
+}
+
+// testObjectStream 生成包含指定对象的对象流，键为对象编号
+func testObjectStream(objects map[int]string, order []int) string {
+	var header, body bytes.Buffer
+	for _, num := range order {
+		fmt.Fprintf(&header, "%d %d ", num, body.Len())
+		body.WriteString(objects[num] + "\n")
+	}
+	dict := fmt.Sprintf("/Type /ObjStm /N %d /First %d", len(order), header.Len())
+	return testStream(dict, append(header.Bytes(), body.Bytes()...), true)
+}
+
+// TestReadPDFMetadata 读取文档信息字典的标题、作者和页数
+func TestReadPDFMetadata(t *testing.T) {
+	catalog := "<< /Type /Catalog /Pages 2 0 R >>"
+	page := "<< /Type /Page /Parent 2 0 R >>"
+
+	tests := []struct {
+		name string
+		data []byte
+		want PDFMetadata
+	}{
+		{
+			name: "信息字典中的字面和UTF-16字符串",
+			data: buildTestPDF("/Root 1 0 R /Info 6 0 R",
+				catalog,
+				"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 >>",
+				page, page, page,
+				`<< /Title (Invoice \(March\)) /Author <FEFF5F204E09> >>`),
+			want: PDFMetadata{Title: "Invoice (March)", Author: "张三", PageCount: 3},
+		},
+		{
+			name: "八进制转义按Latin-1解码",
+			data: buildTestPDF("/Root 1 0 R /Info 4 0 R",
+				catalog,
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				page,
+				`<< /Title (Caf\351) >>`),
+			want: PDFMetadata{Title: "Café", PageCount: 1},
+		},
+		{
+			name: "压缩在对象流中的信息字典",
+			data: buildTestPDF("/Root 1 0 R /Info 5 0 R",
+				catalog,
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				page,
+				testObjectStream(map[int]string{5: "<< /Title (Compressed) /Author (Bot) >>"}, []int{5})),
+			want: PDFMetadata{Title: "Compressed", Author: "Bot", PageCount: 1},
+		},
+		{
+			name: "增量更新后使用新版本的对象",
+			data: append(buildTestPDF("/Root 1 0 R /Info 4 0 R",
+				catalog,
+				"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+				page,
+				"<< /Title (Old) >>"),
+				[]byte("4 0 obj\n<< /Title (New) >>\nendobj\ntrailer\n<< /Root 1 0 R /Info 4 0 R >>\n%%EOF\n")...),
+			want: PDFMetadata{Title: "New", PageCount: 1},
+		},
+		{
+			name: "没有目录时按页面对象计数",
+			data: buildTestPDF("", "<< /Type /Pages /Kids [2 0 R 3 0 R] >>", page, page),
+			want: PDFMetadata{PageCount: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadPDFMetadata(tt.data)
+			if err != nil {
+				t.Fatalf("读取元数据失败: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("元数据为 %+v，期望 %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ReadPDFMetadata([]byte("<html>not a pdf</html>")); err == nil {
+		t.Error("不是PDF的内容应返回错误")
+	}
+}
+
+// TestReadPDFMetadataMalformedInput 异常或截断的PDF不会导致panic
+func TestReadPDFMetadataMalformedInput(t *testing.T) {
+	valid := buildTestPDF("/Root 1 0 R /Info 5 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 4 0 R >> >> >>",
+		testStream("/Type /XObject /Subtype /Image /Filter /DCTDecode", []byte("not a jpeg"), false),
+		testObjectStream(map[int]string{6: "<< /Title (X) >>"}, []int{6}))
+
+	inputs := [][]byte{
+		// 对象流中的偏移为负数或超出范围
+		buildTestPDF("/Root 1 0 R", testStream("/Type /ObjStm /N 2 /First 8", []byte("7 -5 8 999 << >>"), true)),
+		// 引用自身的页面树
+		buildTestPDF("/Root 1 0 R", "<< /Type /Catalog /Pages 2 0 R >>", "<< /Type /Pages /Kids [2 0 R] /Count -1 >>"),
+		// 长度超出流数据、没有结束标记的字符串和流
+		[]byte("%PDF-1.4\n1 0 obj\n<< /Length 99999 >>\nstream\nabc"),
+		[]byte("%PDF-1.4\n1 0 obj\n<< /Title (unterminated"),
+	}
+	for i := 0; i < len(valid); i += 7 {
+		inputs = append(inputs, valid[:i])
+	}
+
+	for i, input := range inputs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("第 %d 个输入导致panic: %v", i, r)
+				}
+			}()
+			ReadPDFMetadata(input)
+			PDFThumbnail(input, 240)
+		}()
+	}
+}
+
+// TestRecoverPDFPanic 解析中的panic转换为错误
+func TestRecoverPDFPanic(t *testing.T) {
+	parse := func() (err error) {
+		defer recoverPDFPanic(&err)
+		var objects map[int][]byte
+		objects[1] = nil // 向nil map写入导致panic
+		return nil
+	}
+	if err := parse(); err == nil {
+		t.Fatal("panic应转换为错误")
+	}
+}
+
+// TestPDFThumbnail 第一页以JPEG嵌入的图像按比例缩小为缩略图
+func TestPDFThumbnail(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatalf("编码测试图像失败: %v", err)
+	}
+
+	data := buildTestPDF("/Root 1 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 4 0 R >> >> >>",
+		testStream("/Type /XObject /Subtype /Image /Width 400 /Height 200 /Filter /DCTDecode", encoded.Bytes(), false))
+
+	thumbnail, err := PDFThumbnail(data, 240)
+	if err != nil {
+		t.Fatalf("生成缩略图失败: %v", err)
+	}
+	decoded, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	if err != nil {
+		t.Fatalf("缩略图不是有效的JPEG: %v", err)
+	}
+	if bounds := decoded.Bounds(); bounds.Dx() != 240 || bounds.Dy() != 120 {
+		t.Errorf("缩略图尺寸为 %dx%d，期望 240x120", bounds.Dx(), bounds.Dy())
+	}
+
+	noImage := buildTestPDF("/Root 1 0 R",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R >>")
+	if _, err := PDFThumbnail(noImage, 240); err == nil {
+		t.Error("第一页没有图像时应返回错误")
+	}
+}
@@ -1,13 +1,20 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"mime"
 	"mime/quotedprintable"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -17,11 +24,35 @@ import (
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/simplifiedchinese"
 	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/unicode/norm"
 	"unicode/utf8"
 )
 
 // CleanFilename 清理文件名，移除非法字符并确保有PDF扩展名
 func CleanFilename(filename string) string {
+	return CleanFilenameFor(filename, nil)
+}
+
+// CleanFilenameFor 清理文件名，扩展名在 extensions（小写，不含点）中时保留原扩展名，其他情况补充PDF扩展名
+func CleanFilenameFor(filename string, extensions []string) string {
+	filename = sanitizeFilename(filename)
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if ext == "pdf" {
+		return filename
+	}
+	if ext != "" {
+		for _, allowed := range extensions {
+			if ext == allowed {
+				return filename
+			}
+		}
+	}
+	return filename + ".pdf"
+}
+
+// sanitizeFilename 移除文件名中的非法字符并限制长度，保留原有扩展名
+func sanitizeFilename(filename string) string {
 	if filename == "" {
 		filename = GenerateFilename("pdf", ".pdf")
 	}
@@ -42,12 +73,38 @@ func CleanFilename(filename string) string {
 		filename = nameWithoutExt[:200-len(ext)] + ext
 	}
 
-	// 确保有PDF扩展名
-	if !strings.HasSuffix(strings.ToLower(filename), ".pdf") {
-		filename += ".pdf"
+	return filename
+}
+
+// SanitizePathSegment 清理单级目录名中的非法字符，返回空字符串表示该级目录无效
+func SanitizePathSegment(segment string) string {
+	segment = SanitizeString(segment)
+	segment = regexp.MustCompile(`[\\/*?:"<>|\t\r\n]`).ReplaceAllString(segment, "_")
+	// Windows不允许目录名以点或空格结尾
+	segment = strings.TrimRight(strings.TrimSpace(segment), ". ")
+
+	if segment == "" || segment == "." || segment == ".." {
+		return ""
+	}
+	if len(segment) > 100 {
+		segment = strings.ToValidUTF8(segment[:100], "")
 	}
+	return segment
+}
 
-	return filename
+// ExpandPathTemplate 展开下载子目录模板，模板以 "/" 分隔多级目录，{name} 替换为 values 中的值
+// 每级目录都会单独清理，展开后为空的目录级别会被忽略，因此结果不会越出下载目录
+func ExpandPathTemplate(template string, values map[string]string) string {
+	var segments []string
+	for _, part := range strings.Split(strings.ReplaceAll(template, "\\", "/"), "/") {
+		for name, value := range values {
+			part = strings.ReplaceAll(part, "{"+name+"}", value)
+		}
+		if segment := SanitizePathSegment(part); segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return filepath.Join(segments...)
 }
 
 // DecodeMimeHeader 解码MIME编码的头部信息
@@ -67,6 +124,17 @@ func DecodeMimeHeader(header string) string {
 	return decodeManually(header)
 }
 
+// NormalizeSubject 规范化邮件主题以便比较
+// 解码MIME编码词、修正非UTF-8字节、合并折行空白、NFC规范化并转为小写
+func NormalizeSubject(subject string) string {
+	decoded := DecodeMimeHeader(subject)
+	if !utf8.ValidString(decoded) {
+		decoded = DecodeText([]byte(decoded), "gbk")
+	}
+	decoded = strings.Join(strings.Fields(decoded), " ")
+	return strings.ToLower(norm.NFC.String(decoded))
+}
+
 // decodeManually 手动解码各种编码格式
 func decodeManually(s string) string {
 	// 处理 =?charset?encoding?encoded_text?= 格式
@@ -184,6 +252,48 @@ func IsPDFContent(data []byte) bool {
 	return string(data[:4]) == "%PDF" || string(data[:5]) == "%PDF-"
 }
 
+// IsValidExtension 检查扩展名（不含点）是否只包含字母和数字且长度合理
+func IsValidExtension(ext string) bool {
+	if ext == "" || len(ext) > 10 {
+		return false
+	}
+	for _, r := range ext {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesFileType 按扩展名检查内容的文件头是否与类型相符，未知类型只排除空内容和HTML页面
+func MatchesFileType(data []byte, ext string) bool {
+	switch strings.ToLower(strings.TrimLeft(ext, ".")) {
+	case "", "pdf":
+		return IsPDFContent(data)
+	case "zip", "docx", "xlsx", "pptx", "odt", "ods", "odp":
+		// ZIP容器格式（Office Open XML、OpenDocument）
+		return bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte("PK\x05\x06"))
+	case "doc", "xls", "ppt":
+		// 旧版Office的OLE复合文档格式
+		return bytes.HasPrefix(data, []byte("\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"))
+	default:
+		return len(data) > 0 && !IsHTMLContent(data)
+	}
+}
+
+// ErrHTMLNotPDF 下载得到的是HTML页面（如登录页、拒绝访问页）而不是PDF文件
+var ErrHTMLNotPDF = errors.New("收到的是HTML页面而不是PDF文件")
+
+// IsHTMLContent 检查数据是否为HTML页面（忽略开头的空白和BOM）
+func IsHTMLContent(data []byte) bool {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(trimmed) > 16 {
+		trimmed = trimmed[:16]
+	}
+	lower := bytes.ToLower(trimmed)
+	return bytes.HasPrefix(lower, []byte("<!doctype")) || bytes.HasPrefix(lower, []byte("<html"))
+}
+
 // ValidatePDFFile 验证PDF文件完整性
 func ValidatePDFFile(filePath string) error {
 	file, err := os.Open(filePath)
@@ -204,11 +314,17 @@ func ValidatePDFFile(filePath string) error {
 	}
 
 	// 读取文件头
-	header := make([]byte, 10)
-	_, err = file.Read(header)
+	header := make([]byte, 512)
+	n, err := file.Read(header)
 	if err != nil {
 		return fmt.Errorf("无法读取文件头: %v", err)
 	}
+	header = header[:n]
+
+	// 服务器返回的错误页面常被当作PDF保存，单独识别以便给出明确提示
+	if IsHTMLContent(header) {
+		return ErrHTMLNotPDF
+	}
 
 	// 验证PDF文件头
 	if !IsPDFContent(header) {
@@ -237,33 +353,317 @@ func ValidatePDFFile(filePath string) error {
 	return nil
 }
 
+// pdfTailScanSize 严格验证时在文件末尾查找 startxref 的范围
+const pdfTailScanSize = 2048
+
+// pdfObjectHeader 交叉引用流以 "编号 版本 obj" 开头
+var pdfObjectHeader = regexp.MustCompile(`^\d+\s+\d+\s+obj`)
+
+// ValidatePDFStrict 在 ValidatePDFFile 的基础上检查交叉引用表：
+// 文件末尾必须有 startxref 和其后的 %%EOF，startxref 指向的偏移必须在文件内，
+// 且该位置是 xref 表或交叉引用流对象，用于发现保留了文件尾但中间被截断或拼接的文件
+func ValidatePDFStrict(filePath string) error {
+	if err := ValidatePDFFile(filePath); err != nil {
+		return err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("无法获取文件信息: %v", err)
+	}
+	size := info.Size()
+
+	tailSize := int64(pdfTailScanSize)
+	if size < tailSize {
+		tailSize = size
+	}
+	tail := make([]byte, tailSize)
+	if _, err := file.ReadAt(tail, size-tailSize); err != nil && err != io.EOF {
+		return fmt.Errorf("无法读取文件尾: %v", err)
+	}
+
+	keyword := bytes.LastIndex(tail, []byte("startxref"))
+	if keyword < 0 {
+		return fmt.Errorf("文件可能不完整，缺少startxref")
+	}
+	rest := tail[keyword+len("startxref"):]
+	eof := bytes.Index(rest, []byte("%%EOF"))
+	if eof < 0 {
+		return fmt.Errorf("文件可能不完整，startxref之后缺少EOF标记")
+	}
+	offset, err := strconv.ParseInt(string(bytes.TrimSpace(rest[:eof])), 10, 64)
+	if err != nil {
+		return fmt.Errorf("startxref的偏移无效: %q", bytes.TrimSpace(rest[:eof]))
+	}
+	if offset <= 0 || offset >= size {
+		return fmt.Errorf("交叉引用表的偏移 %d 超出文件范围（文件大小 %d 字节），文件可能被截断", offset, size)
+	}
+
+	target := make([]byte, 64)
+	n, err := file.ReadAt(target, offset)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("无法读取交叉引用表: %v", err)
+	}
+	target = bytes.TrimLeft(target[:n], " \t\r\n\f\x00")
+	if !bytes.HasPrefix(target, []byte("xref")) && !pdfObjectHeader.Match(target) {
+		return fmt.Errorf("偏移 %d 处不是交叉引用表，文件可能被截断或损坏", offset)
+	}
+	return nil
+}
+
+// 支持的文件校验和算法
+const (
+	ChecksumMD5    = "md5"
+	ChecksumSHA256 = "sha256"
+)
+
+// FileChecksum 计算文件的校验和，返回十六进制字符串
+func FileChecksum(filePath, algorithm string) (string, error) {
+	var h hash.Hash
+	switch strings.ToLower(algorithm) {
+	case ChecksumMD5:
+		h = md5.New()
+	case ChecksumSHA256:
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("不支持的校验和算法: %s", algorithm)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("无法打开文件: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("读取文件失败: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ParseTimeOfDay 解析 "HH:MM" 格式的时间，返回从零点开始的分钟数
+func ParseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间格式 %q，应为 HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// InTimeWindow 判断时间是否在 [start, end) 时间段内（从零点开始的分钟数）
+// 支持跨午夜的时间段，start 等于 end 时视为全天
+func InTimeWindow(now time.Time, start, end int) bool {
+	if start == end {
+		return true
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
 // ExtractFilenameFromURL 从URL中提取文件名
 func ExtractFilenameFromURL(rawURL string) string {
+	filename := rawFilenameFromURL(rawURL)
+	if filename == "" {
+		filename = GenerateFilename("pdf", ".pdf")
+	}
+
+	// 清理并确保扩展名
+	return CleanFilename(filename)
+}
+
+// FilenameFromURLAndType 从URL中提取文件名，并按响应的 Content-Type 补全或修正扩展名，URL中没有文件名时返回空字符串
+// 例如 application/pdf 的 .../get 保存为 get.pdf，.../download.php 保存为 download.pdf；
+// 扩展名在 extensions 中时保留，其他扩展名补充 .pdf
+func FilenameFromURLAndType(rawURL, contentType string, extensions []string) string {
+	filename := rawFilenameFromURL(rawURL)
+	if filename == "" {
+		return ""
+	}
+	return CleanFilenameFor(ApplyMimeExtension(filename, contentType), extensions)
+}
+
+// rawFilenameFromURL 从URL路径（或 filename 查询参数）中取出未经清理的文件名，没有时返回空字符串
+func rawFilenameFromURL(rawURL string) string {
 	if rawURL == "" {
-		return GenerateFilename("pdf", ".pdf")
+		return ""
 	}
 
 	// 解析URL
 	parsedURL, err := url.Parse(rawURL)
 	if err != nil {
-		return GenerateFilename("pdf", ".pdf")
+		return ""
 	}
 
 	// 从路径中提取文件名
 	filename := filepath.Base(parsedURL.Path)
-	
-	// 如果没有文件名或文件名无效，生成默认名称
 	if filename == "" || filename == "." || filename == "/" {
 		// 尝试从查询参数中获取文件名
-		if queryFilename := parsedURL.Query().Get("filename"); queryFilename != "" {
-			filename = queryFilename
-		} else {
-			filename = GenerateFilename("pdf", ".pdf")
+		filename = parsedURL.Query().Get("filename")
+	}
+	return filename
+}
+
+// mimeExtensions MIME类型对应的扩展名，用于保存没有扩展名的下载文件
+var mimeExtensions = map[string]string{
+	"application/pdf":              ".pdf",
+	"application/x-pdf":            ".pdf",
+	"application/zip":              ".zip",
+	"application/x-zip-compressed": ".zip",
+	"application/rtf":              ".rtf",
+	"application/json":             ".json",
+	"application/xml":              ".xml",
+	"text/xml":                     ".xml",
+	"text/csv":                     ".csv",
+	"text/plain":                   ".txt",
+	"text/html":                    ".html",
+	"image/jpeg":                   ".jpg",
+	"image/png":                    ".png",
+	"image/gif":                    ".gif",
+	"image/tiff":                   ".tif",
+
+	// Office文档
+	"application/msword":            ".doc",
+	"application/vnd.ms-excel":      ".xls",
+	"application/vnd.ms-powerpoint": ".ppt",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ".docx",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ".xlsx",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ".pptx",
+}
+
+// scriptExtensions 动态页面的扩展名，下载地址以它们结尾时说明不是真实的文件扩展名
+var scriptExtensions = map[string]bool{
+	".php": true, ".asp": true, ".aspx": true, ".jsp": true, ".cgi": true, ".do": true, ".action": true,
+}
+
+// ExtensionForMimeType 返回MIME类型对应的扩展名（含点），忽略参数和大小写
+// 未知类型和 application/octet-stream 等通用类型返回空字符串
+func ExtensionForMimeType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return mimeExtensions[strings.ToLower(mediaType)]
+}
+
+// ApplyMimeExtension 按MIME类型补全文件名的扩展名：没有扩展名或以动态页面扩展名结尾时使用类型对应的扩展名，
+// 已有其他扩展名或类型未知时保持不变
+func ApplyMimeExtension(filename, contentType string) string {
+	ext := ExtensionForMimeType(contentType)
+	if ext == "" {
+		return filename
+	}
+
+	current := strings.ToLower(filepath.Ext(filename))
+	switch {
+	case current == "":
+		return filename + ext
+	case scriptExtensions[current]:
+		return strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+	default:
+		return filename
+	}
+}
+
+var (
+	// extFilenamePattern RFC 5987/2231 扩展形式 filename*=charset'lang'value
+	extFilenamePattern = regexp.MustCompile(`(?i)(?:^|;)\s*filename\*\s*=\s*"?([^;"]+)"?`)
+	// plainFilenamePattern 格式不规范时的普通 filename 参数
+	plainFilenamePattern = regexp.MustCompile(`(?i)(?:^|;)\s*filename\s*=\s*("[^"]*"|[^;]*)`)
+)
+
+// FilenameFromContentDisposition 从响应的 Content-Disposition 头中提取文件名，没有文件名时返回空字符串
+// 优先使用 filename* 扩展形式（支持 UTF-8、ISO-8859-1 和 GBK 字符集），其次是普通 filename 参数
+func FilenameFromContentDisposition(header string) string {
+	if strings.TrimSpace(header) == "" {
+		return ""
+	}
+
+	// mime.ParseMediaType 只能解码 UTF-8 字符集的 filename*，其他字符集手动处理
+	if m := extFilenamePattern.FindStringSubmatch(header); m != nil {
+		if name := decodeExtendedValue(strings.TrimSpace(m[1])); name != "" {
+			return name
 		}
 	}
 
-	// 清理并确保扩展名
-	return CleanFilename(filename)
+	var name string
+	if _, params, err := mime.ParseMediaType(header); err == nil {
+		name = params["filename"]
+	} else if m := plainFilenamePattern.FindStringSubmatch(header); m != nil {
+		name = strings.Trim(strings.TrimSpace(m[1]), `"`)
+	}
+	if name == "" {
+		return ""
+	}
+
+	// 部分服务器直接在 filename 中使用百分号编码或MIME编码词
+	if strings.Contains(name, "%") {
+		if unescaped, err := url.PathUnescape(name); err == nil && utf8.ValidString(unescaped) {
+			name = unescaped
+		}
+	}
+	name = DecodeMimeHeader(name)
+	if !utf8.ValidString(name) {
+		if decoded, err := simplifiedchinese.GBK.NewDecoder().String(name); err == nil {
+			name = decoded
+		}
+	}
+	return baseFilename(name)
+}
+
+// decodeExtendedValue 解码 RFC 5987 扩展参数值 charset'lang'percent-encoded
+func decodeExtendedValue(value string) string {
+	parts := strings.SplitN(value, "'", 3)
+	if len(parts) != 3 {
+		return ""
+	}
+	raw, err := url.PathUnescape(parts[2])
+	if err != nil || raw == "" {
+		return ""
+	}
+
+	var name string
+	switch charset := strings.ToLower(parts[0]); charset {
+	case "utf-8", "us-ascii", "":
+		if !utf8.ValidString(raw) {
+			return ""
+		}
+		name = raw
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(raw))
+		for i := 0; i < len(raw); i++ {
+			runes[i] = rune(raw[i])
+		}
+		name = string(runes)
+	default:
+		enc := getEncoding(charset)
+		if enc == nil {
+			return ""
+		}
+		decoded, err := enc.NewDecoder().String(raw)
+		if err != nil {
+			return ""
+		}
+		name = decoded
+	}
+	return baseFilename(name)
+}
+
+// baseFilename 去掉文件名中可能携带的目录部分，避免写到下载目录之外
+func baseFilename(name string) string {
+	base := strings.TrimSpace(path.Base(strings.ReplaceAll(name, "\\", "/")))
+	if base == "." || base == "/" || base == ".." {
+		return ""
+	}
+	return base
 }
 
 // SaveFile 保存文件到指定目录
@@ -273,8 +673,24 @@ func SaveFile(data []byte, filename, dir string) (string, error) {
 		return "", fmt.Errorf("创建目录失败: %v", err)
 	}
 
+	filePath, err := UniqueFilePath(dir, filename)
+	if err != nil {
+		return "", err
+	}
+
+	// 写入文件
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return "", fmt.Errorf("写入文件失败: %v", err)
+	}
+
+	return filePath, nil
+}
+
+// UniqueFilePath 返回目录中不与已有文件冲突的路径，同名文件已存在时添加序号
+// 文件名只移除非法字符，扩展名由调用方决定
+func UniqueFilePath(dir, filename string) (string, error) {
 	// 清理文件名
-	filename = CleanFilename(filename)
+	filename = sanitizeFilename(filename)
 	filePath := filepath.Join(dir, filename)
 
 	// 处理文件名冲突
@@ -297,11 +713,6 @@ func SaveFile(data []byte, filename, dir string) (string, error) {
 		}
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return "", fmt.Errorf("写入文件失败: %v", err)
-	}
-
 	return filePath, nil
 }
 
@@ -417,6 +828,24 @@ func EnsureDir(dir string) error {
 	return os.MkdirAll(dir, 0755)
 }
 
+// QuarantineDir 获取隔离目录路径，用于保存未通过验证的下载文件
+func QuarantineDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".emaild", "quarantine")
+}
+
+// ProbeWritable 在目录中创建并删除一个临时文件，检测目录是否可写
+// 网络驱动器等情况下权限位并不可靠，实际写入是唯一可信的检测方式
+func ProbeWritable(dir string) error {
+	file, err := os.CreateTemp(dir, ".emaild-write-probe-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
 // GetMimeType 根据文件扩展名获取MIME类型
 func GetMimeType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
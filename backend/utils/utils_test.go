@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSubjectMatchesEncodedChinese(t *testing.T) {
+	encoded := "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte("【发票】三月 账单通知")) + "?="
+	query := NormalizeSubject("三月 账单")
+
+	if !strings.Contains(NormalizeSubject(encoded), query) {
+		t.Fatalf("编码的主题 %q 规范化后为 %q，应包含查询 %q", encoded, NormalizeSubject(encoded), query)
+	}
+}
+
+func TestNormalizeSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"纯文本转小写", "Invoice MARCH", "invoice march"},
+		{"分段编码词", "=?UTF-8?B?5Y+R56Wo?= =?UTF-8?B?6YCa55+l?=", "发票通知"},
+		{"Q编码", "=?UTF-8?Q?Caf=C3=A9_menu?=", "café menu"},
+		{"NFD组合字符转为NFC", "Cafe\u0301", "caf\u00e9"},
+		{"折行空白合并", "Monthly\r\n\t  report", "monthly report"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeSubject(tt.subject); got != tt.want {
+				t.Errorf("NormalizeSubject(%q) = %q，期望 %q", tt.subject, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSubjectLongSubject(t *testing.T) {
+	long := strings.Repeat("很长的主题", 2000)
+	encoded := "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(long)) + "?="
+
+	if got := NormalizeSubject(encoded); got != long {
+		t.Fatalf("长主题解码结果长度为 %d，期望 %d", len(got), len(long))
+	}
+}
+
+func TestCleanFilenameFor(t *testing.T) {
+	extensions := []string{"pdf", "xlsx", "zip"}
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+	}{
+		{"PDF保持不变", "invoice.PDF", "invoice.PDF"},
+		{"允许的扩展名保持不变", "report.xlsx", "report.xlsx"},
+		{"允许的ZIP保持不变", "bundle.zip", "bundle.zip"},
+		{"没有扩展名补充PDF", "invoice", "invoice.pdf"},
+		{"不允许的扩展名补充PDF", "notes.docx", "notes.docx.pdf"},
+		{"移除非法字符", "a/b:c.xlsx", "a_b_c.xlsx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CleanFilenameFor(tt.filename, extensions); got != tt.want {
+				t.Errorf("CleanFilenameFor(%q) = %q，期望 %q", tt.filename, got, tt.want)
+			}
+		})
+	}
+
+	if got := CleanFilename("report.xlsx"); got != "report.xlsx.pdf" {
+		t.Errorf("CleanFilename 只保留PDF扩展名，得到 %q", got)
+	}
+}
+
+func TestFilenameFromURLAndTypeKeepsAllowedExtension(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		contentType string
+		extensions  []string
+		want        string
+	}{
+		{"按类型补全PDF", "https://example.com/get", "application/pdf", nil, "get.pdf"},
+		{"动态页面改为PDF", "https://example.com/download.php", "application/pdf", nil, "download.pdf"},
+		{"允许ZIP时保留", "https://example.com/get", "application/zip", []string{"pdf", "zip"}, "get.zip"},
+		{"不允许ZIP时补充PDF", "https://example.com/get", "application/zip", []string{"pdf"}, "get.zip.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilenameFromURLAndType(tt.url, tt.contentType, tt.extensions); got != tt.want {
+				t.Errorf("FilenameFromURLAndType(%q, %q) = %q，期望 %q", tt.url, tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestExtensionForMimeType MIME类型按表转换为扩展名，忽略参数和大小写，通用或未知类型返回空字符串
+func TestExtensionForMimeType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"application/pdf", ".pdf"},
+		{"application/x-pdf", ".pdf"},
+		{"Application/PDF; charset=binary", ".pdf"},
+		{"application/zip", ".zip"},
+		{"application/x-zip-compressed", ".zip"},
+		{"text/csv; charset=utf-8", ".csv"},
+		{"text/xml", ".xml"},
+		{"image/jpeg", ".jpg"},
+		{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", ".xlsx"},
+		{"application/msword", ".doc"},
+		{"application/pdf;;", ".pdf"}, // 参数格式错误时仍按类型部分匹配
+		{"application/octet-stream", ""},
+		{"application/x-unknown", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtensionForMimeType(tt.contentType); got != tt.want {
+			t.Errorf("ExtensionForMimeType(%q) = %q，期望 %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// TestApplyMimeExtension 没有扩展名或以动态页面扩展名结尾时按类型补全，已有扩展名或类型未知时不变
+func TestApplyMimeExtension(t *testing.T) {
+	tests := []struct {
+		filename    string
+		contentType string
+		want        string
+	}{
+		{"get", "application/pdf", "get.pdf"},
+		{"download.php", "application/pdf", "download.pdf"},
+		{"export.ASPX", "text/csv", "export.csv"},
+		{"report.xlsx", "application/pdf", "report.xlsx"},
+		{"invoice", "application/octet-stream", "invoice"},
+		{"archive", "application/zip", "archive.zip"},
+		{"invoice.pdf", "", "invoice.pdf"},
+	}
+
+	for _, tt := range tests {
+		if got := ApplyMimeExtension(tt.filename, tt.contentType); got != tt.want {
+			t.Errorf("ApplyMimeExtension(%q, %q) = %q，期望 %q", tt.filename, tt.contentType, got, tt.want)
+		}
+	}
+}
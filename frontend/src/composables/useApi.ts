@@ -8,8 +8,25 @@ type EmailAccount = models.EmailAccount
 type DownloadTask = models.DownloadTask
 type AppConfig = models.AppConfig
 type DownloadStatistics = models.DownloadStatistics
+type PathCheck = models.PathCheck
+type NetworkActivity = models.NetworkActivity
+type BulkAccountResult = models.BulkAccountResult
+type FailureCategorySummary = models.FailureCategorySummary
+type FolderCheck = models.FolderCheck
+type SourcePreview = models.SourcePreview
+type TaskFile = models.TaskFile
+type SpeedSample = models.SpeedSample
+type PDFMetadata = models.PDFMetadata
+type Rule = models.Rule
+type TaskLogEntry = models.TaskLogEntry
+type ConnectionDiagnostics = models.ConnectionDiagnostics
+type TaskDetail = models.TaskDetail
+type EmailMessage = models.EmailMessage
+type ValidationResult = models.ValidationResult
+type DownloadTaskFilter = models.DownloadTaskFilter
 type EmailCheckResult = backend.EmailCheckResult
 type GetDownloadTasksResponse = backend.GetDownloadTasksResponse
+type AccountCreateResult = backend.AccountCreateResult
 
 // 检查Wails运行时是否可用
 const isWailsReady = (): boolean => {
@@ -83,6 +100,27 @@ export function useApi() {
         () => WailsApp.UpdateConfig(config),
         '更新配置'
       )
+    },
+
+    async testProxy(proxyUrl: string): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.TestProxy(proxyUrl),
+        '测试代理'
+      )
+    },
+
+    async getRules(): Promise<Rule[]> {
+      return safeApiCall(
+        () => WailsApp.GetRules(),
+        '获取下载规则'
+      )
+    },
+
+    async setRules(rules: Rule[]): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.SetRules(rules),
+        '保存下载规则'
+      )
     }
   }
 
@@ -95,7 +133,7 @@ export function useApi() {
       )
     },
 
-    async createAccount(account: EmailAccount): Promise<void> {
+    async createAccount(account: EmailAccount): Promise<AccountCreateResult> {
       return safeApiCall(
         () => WailsApp.CreateEmailAccount(account),
         '创建邮箱账户'
@@ -123,6 +161,83 @@ export function useApi() {
       )
     },
 
+    async testConnectionAndUpdate(accountId: number): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.TestConnectionAndUpdate(accountId),
+        '测试邮箱连接'
+      )
+    },
+
+    async diagnoseConnection(account: EmailAccount): Promise<ConnectionDiagnostics> {
+      return safeApiCall(
+        () => WailsApp.DiagnoseConnection(account),
+        '诊断邮箱连接'
+      )
+    },
+
+    async getSSHHostKeyFingerprint(account: EmailAccount): Promise<string> {
+      return safeApiCall(
+        () => WailsApp.GetSSHHostKeyFingerprint(account),
+        '获取SSH主机密钥'
+      )
+    },
+
+    async bulkSetActive(ids: number[], active: boolean): Promise<BulkAccountResult[]> {
+      return safeApiCall(
+        () => WailsApp.BulkSetAccountsActive(ids, active),
+        active ? '批量启用邮箱账户' : '批量停用邮箱账户'
+      )
+    },
+
+    async bulkDelete(ids: number[]): Promise<BulkAccountResult[]> {
+      return safeApiCall(
+        () => WailsApp.BulkDeleteAccounts(ids),
+        '批量删除邮箱账户'
+      )
+    },
+
+    async acceptNewCertificate(accountId: number): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.AcceptNewCertificate(accountId),
+        '接受新证书'
+      )
+    },
+
+    async resetSyncState(accountId: number): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.ResetAccountSyncState(accountId),
+        '重置扫描状态'
+      )
+    },
+
+    async validateFolders(accountId: number): Promise<FolderCheck[]> {
+      return safeApiCall(
+        () => WailsApp.ValidateFolders(accountId),
+        '检查扫描文件夹'
+      )
+    },
+
+    async previewAccount(accountId: number): Promise<SourcePreview[]> {
+      return safeApiCall(
+        () => WailsApp.PreviewAccount(accountId),
+        '预览待下载文件'
+      )
+    },
+
+    async getMessageHeaders(messageId: string): Promise<Record<string, string[]>> {
+      return safeApiCall(
+        () => WailsApp.GetMessageHeaders(messageId),
+        '获取邮件头'
+      )
+    },
+
+    async searchMessages(query: string, page = 1, pageSize = 20): Promise<EmailMessage[]> {
+      return safeApiCall(
+        () => WailsApp.SearchEmailMessages(query, page, pageSize),
+        '搜索邮件'
+      )
+    },
+
     async checkAllEmails(): Promise<EmailCheckResult[]> {
       return safeApiCall(
         () => WailsApp.CheckAllEmails(),
@@ -137,6 +252,13 @@ export function useApi() {
       )
     },
 
+    async scanAccountRange(accountId: number, since: string, before: string): Promise<EmailCheckResult> {
+      return safeApiCall(
+        () => WailsApp.ScanAccountRange(accountId, since, before),
+        '按日期范围扫描邮件'
+      )
+    },
+
     async startMonitoring(): Promise<void> {
       return safeApiCall(
         () => WailsApp.StartEmailMonitoring(),
@@ -161,6 +283,13 @@ export function useApi() {
       )
     },
 
+    async getTasksFiltered(filter: Partial<DownloadTaskFilter>): Promise<GetDownloadTasksResponse> {
+      return safeApiCall(
+        () => WailsApp.GetDownloadTasksFiltered(models.DownloadTaskFilter.createFrom(filter)),
+        '筛选下载任务'
+      )
+    },
+
     async getTasksByStatus(status: string): Promise<DownloadTask[]> {
       return safeApiCall(
         () => WailsApp.GetDownloadTasksByStatus(status),
@@ -175,6 +304,83 @@ export function useApi() {
       )
     },
 
+    async importEMLFile(path: string): Promise<DownloadTask[]> {
+      return safeApiCall(
+        () => WailsApp.ImportEMLFile(path),
+        '导入邮件文件'
+      )
+    },
+
+    async generateMonthlyReport(year: number, month: number, format: string): Promise<string> {
+      return safeApiCall(
+        () => WailsApp.GenerateMonthlyReport(year, month, format),
+        '生成月度报表'
+      )
+    },
+
+    async exportTasks(format: string, fromDate: string, toDate: string): Promise<string> {
+      return safeApiCall(
+        () => WailsApp.ExportTasks(format, fromDate, toDate),
+        '导出下载任务'
+      )
+    },
+
+    async getTaskFiles(taskId: number): Promise<TaskFile[]> {
+      return safeApiCall(
+        () => WailsApp.GetTaskFiles(taskId),
+        '获取解压文件'
+      )
+    },
+
+    async getSpeedSamples(taskId: number): Promise<SpeedSample[]> {
+      return safeApiCall(
+        () => WailsApp.GetTaskSpeedSamples(taskId),
+        '获取下载速度记录'
+      )
+    },
+
+    async getTaskLog(taskId: number): Promise<TaskLogEntry[]> {
+      return safeApiCall(
+        () => WailsApp.GetTaskLog(taskId),
+        '获取任务日志'
+      )
+    },
+
+    async getPDFMetadata(taskId: number): Promise<PDFMetadata> {
+      return safeApiCall(
+        () => WailsApp.GetPDFMetadata(taskId),
+        '获取PDF信息'
+      )
+    },
+
+    async getTaskDetail(taskId: number): Promise<TaskDetail> {
+      return safeApiCall(
+        () => WailsApp.GetTaskDetail(taskId),
+        '获取任务详情'
+      )
+    },
+
+    async revalidateTask(taskId: number): Promise<ValidationResult> {
+      return safeApiCall(
+        () => WailsApp.RevalidateTask(taskId),
+        '重新验证文件'
+      )
+    },
+
+    async getFailedTasksSummary(): Promise<FailureCategorySummary[]> {
+      return safeApiCall(
+        () => WailsApp.GetFailedTasksSummary(),
+        '获取失败任务汇总'
+      )
+    },
+
+    async getNetworkActivity(): Promise<NetworkActivity> {
+      return safeApiCall(
+        () => WailsApp.GetNetworkActivity(),
+        '获取网络活动'
+      )
+    },
+
     async pauseTask(taskId: number): Promise<void> {
       return safeApiCall(
         () => WailsApp.PauseDownloadTask(taskId),
@@ -189,11 +395,32 @@ export function useApi() {
       )
     },
 
+    async retryTask(taskId: number): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.RetryDownloadTask(taskId),
+        '重试下载任务'
+      )
+    },
+
     async cancelTask(taskId: number): Promise<void> {
       return safeApiCall(
         () => WailsApp.CancelDownloadTask(taskId),
         '取消下载任务'
       )
+    },
+
+    async cancelAll(): Promise<number> {
+      return safeApiCall(
+        () => WailsApp.CancelAllDownloads(),
+        '取消全部下载任务'
+      )
+    },
+
+    async deleteTask(taskId: number, deleteFile: boolean): Promise<void> {
+      return safeApiCall(
+        () => WailsApp.DeleteDownloadTask(taskId, deleteFile),
+        '删除下载任务'
+      )
     }
   }
 
@@ -230,6 +457,27 @@ export function useApi() {
       )
     },
 
+    async selectEMLFile(): Promise<string> {
+      return safeApiCall(
+        () => WailsApp.SelectEMLFile(),
+        '选择邮件文件'
+      )
+    },
+
+    async validateDownloadPath(path: string): Promise<PathCheck> {
+      return safeApiCall(
+        () => WailsApp.ValidateDownloadPath(path),
+        '检测下载目录'
+      )
+    },
+
+    async cleanOrphanedTempFiles(): Promise<number> {
+      return safeApiCall(
+        () => WailsApp.CleanOrphanedTempFiles(),
+        '清理临时文件'
+      )
+    },
+
     async showNotification(title: string, message: string): Promise<void> {
       return safeApiCall(
         () => WailsApp.ShowNotification(title, message),
@@ -237,7 +485,7 @@ export function useApi() {
       )
     },
 
-    async getServiceStatus(): Promise<Record<string, boolean>> {
+    async getServiceStatus(): Promise<Record<string, boolean | number>> {
       return safeApiCall(
         () => WailsApp.GetServiceStatus(),
         '获取服务状态'
@@ -302,4 +550,4 @@ export function useApi() {
 }
 
 // 导出类型以供其他组件使用
-export type { EmailAccount, DownloadTask, AppConfig, DownloadStatistics, EmailCheckResult, GetDownloadTasksResponse } 
\ No newline at end of file
+export type { EmailAccount, DownloadTask, AppConfig, DownloadStatistics, EmailCheckResult, GetDownloadTasksResponse, NetworkActivity, BulkAccountResult, FailureCategorySummary, FolderCheck, TaskFile, DownloadTaskFilter, AccountCreateResult, Rule, ConnectionDiagnostics, TaskLogEntry } 
\ No newline at end of file
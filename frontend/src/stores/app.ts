@@ -7,7 +7,9 @@ import type {
   DownloadTask, 
   AppConfig, 
   DownloadStatistics,
-  EmailCheckResult 
+  EmailCheckResult,
+  Rule,
+  ConnectionDiagnostics
 } from '../composables/useApi'
 
 // 应用状态管理
@@ -22,7 +24,8 @@ export const useAppStore = defineStore('app', () => {
   const statistics = ref<DownloadStatistics[]>([])
   const serviceStatus = ref({
     email: false,
-    download: false
+    download: false,
+    imapConnections: 0
   })
 
   // 计算属性
@@ -82,6 +85,15 @@ export const useAppStore = defineStore('app', () => {
     }
   }
 
+  // 下载规则整体读取和保存，保存失败时原有规则保持不变
+  const getRules = async () => {
+    return (await safeCall(() => api.config.getRules())) || []
+  }
+
+  const setRules = async (rules: Rule[]) => {
+    await safeCall(() => api.config.setRules(rules), true)
+  }
+
   // Actions - 邮箱账户管理
   const loadEmailAccounts = async () => {
     const result = await safeCall(() => api.email.getAccounts())
@@ -94,6 +106,7 @@ export const useAppStore = defineStore('app', () => {
     if (result !== null) {
       await loadEmailAccounts()
     }
+    return result
   }
 
   const updateEmailAccount = async (account: EmailAccount) => {
@@ -114,6 +127,64 @@ export const useAppStore = defineStore('app', () => {
     return await safeCall(() => api.email.testConnection(account as EmailAccount), true)
   }
 
+  // 逐个阶段诊断连接，失败的阶段记录在结果中，无法开始诊断时抛出错误
+  const diagnoseConnection = async (account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<ConnectionDiagnostics | null> => {
+    return await safeCall(() => api.email.diagnoseConnection(account as EmailAccount), true)
+  }
+
+  // 读取SSH跳板机的主机密钥指纹，由用户核对后确认
+  const getSSHHostKeyFingerprint = async (account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<string | null> => {
+    return await safeCall(() => api.email.getSSHHostKeyFingerprint(account as EmailAccount))
+  }
+
+  // 测试已保存账户的连接，结果（错误信息和测试时间）记录在账户中，完成后刷新账户列表
+  const testAccountConnection = async (accountId: number) => {
+    try {
+      return await safeCall(() => api.email.testConnectionAndUpdate(accountId), true)
+    } finally {
+      await loadEmailAccounts()
+    }
+  }
+
+  // 批量操作返回每个账户的结果，部分失败时其余账户仍会处理
+  const bulkSetAccountsActive = async (ids: number[], active: boolean) => {
+    const result = await safeCall(() => api.email.bulkSetActive(ids, active))
+    if (result !== null) {
+      await loadEmailAccounts()
+    }
+    return result
+  }
+
+  const bulkDeleteEmailAccounts = async (ids: number[]) => {
+    const result = await safeCall(() => api.email.bulkDelete(ids))
+    if (result !== null) {
+      await loadEmailAccounts()
+    }
+    return result
+  }
+
+  const acceptNewCertificate = async (id: number) => {
+    const result = await safeCall(() => api.email.acceptNewCertificate(id))
+    if (result !== null) {
+      await loadEmailAccounts()
+    }
+  }
+
+  // 清除账户的增量扫描状态，下次检查时重新扫描，成功时返回 true
+  const resetAccountSyncState = async (id: number) => {
+    const result = await safeCall(() => api.email.resetSyncState(id))
+    return result !== null
+  }
+
+  // 检查账户配置的额外扫描文件夹是否存在
+  const validateFolders = async (id: number) => {
+    return await safeCall(() => api.email.validateFolders(id))
+  }
+
+  const previewAccount = async (id: number) => {
+    return await safeCall(() => api.email.previewAccount(id))
+  }
+
   const checkAllEmails = async (): Promise<EmailCheckResult[]> => {
     const result = await safeCall(() => api.email.checkAllEmails())
     return result || []
@@ -124,6 +195,11 @@ export const useAppStore = defineStore('app', () => {
     return result || backend.EmailCheckResult.createFrom({ account: null, new_emails: 0, pdfs_found: 0, error: '', success: false })
   }
 
+  // 扫描账户中接收日期在 [since, before) 内的全部邮件（包括已读），before 为空表示到最新的邮件
+  const scanAccountRange = async (accountId: number, since: string, before: string) => {
+    return await safeCall(() => api.email.scanAccountRange(accountId, since, before))
+  }
+
   const startEmailMonitoring = async () => {
     await safeCall(() => api.email.startMonitoring())
   }
@@ -158,6 +234,14 @@ export const useAppStore = defineStore('app', () => {
     }
   }
 
+  // 重试失败或已取消的任务，超过最大重试次数时后端拒绝
+  const retryTask = async (taskId: number) => {
+    const result = await safeCall(() => api.download.retryTask(taskId))
+    if (result !== null) {
+      await loadDownloadTasks()
+    }
+  }
+
   const cancelTask = async (taskId: number) => {
     const result = await safeCall(() => api.download.cancelTask(taskId))
     if (result !== null) {
@@ -165,6 +249,38 @@ export const useAppStore = defineStore('app', () => {
     }
   }
 
+  // 取消所有正在下载和等待中的任务，返回取消的数量
+  const cancelAllDownloads = async () => {
+    const cancelled = await safeCall(() => api.download.cancelAll())
+    await loadDownloadTasks()
+    return cancelled
+  }
+
+  // 删除下载任务，deleteFile 为 true 时同时删除本地文件
+  const deleteTask = async (taskId: number, deleteFile: boolean) => {
+    const result = await safeCall(() => api.download.deleteTask(taskId, deleteFile))
+    if (result !== null) {
+      await loadDownloadTasks()
+    }
+  }
+
+  // 从列表中删除所有已完成的任务记录，保留已下载的文件，返回删除的数量
+  const clearCompletedTasks = async () => {
+    const completed = downloadTasks.value.filter(task => task.status === 'completed')
+    let removed = 0
+    for (const task of completed) {
+      if (await safeCall(() => api.download.deleteTask(task.id, false)) !== null) {
+        removed++
+      }
+    }
+    await loadDownloadTasks()
+    return removed
+  }
+
+  const loadNetworkActivity = async () => {
+    return await safeCall(() => api.download.getNetworkActivity())
+  }
+
   // Actions - 统计数据
   const loadStatistics = async (days = 30) => {
     const result = await safeCall(() => api.stats.getStatistics(days))
@@ -177,8 +293,9 @@ export const useAppStore = defineStore('app', () => {
     const result = await safeCall(() => api.system.getServiceStatus())
     if (result) {
       serviceStatus.value = {
-        email: result.email || false,
-        download: result.download || false
+        email: Boolean(result.email),
+        download: Boolean(result.download),
+        imapConnections: Number(result.imapConnections) || 0
       }
     }
     return result || { email: false, download: false }
@@ -194,6 +311,78 @@ export const useAppStore = defineStore('app', () => {
     return result || ''
   }
 
+  const validateDownloadPath = async (path: string) => {
+    return await safeCall(() => api.system.validateDownloadPath(path))
+  }
+
+  // 测试代理服务器能否连接，失败时由API层提示原因
+  const testProxy = async (proxyUrl: string) => {
+    const result = await safeCall(async () => {
+      await api.config.testProxy(proxyUrl)
+      return true
+    })
+    return result === true
+  }
+
+  const cleanOrphanedTempFiles = async () => {
+    return await safeCall(() => api.system.cleanOrphanedTempFiles())
+  }
+
+  // 选择本地 .eml 文件并提取其中的PDF附件，取消选择时返回 null
+  const importEMLFile = async () => {
+    const path = await safeCall(() => api.system.selectEMLFile())
+    if (!path) return null
+    return await safeCall(() => api.download.importEMLFile(path))
+  }
+
+  // 生成指定月份的下载汇总报表，用户取消保存时返回空字符串
+  const generateMonthlyReport = async (year: number, month: number, format: string) => {
+    return await safeCall(() => api.download.generateMonthlyReport(year, month, format))
+  }
+
+  // 导出创建日期在范围内的下载任务（CSV或JSON），保存到下载目录，返回文件路径
+  const exportTasks = async (format: string, fromDate: string, toDate: string) => {
+    return await safeCall(() => api.download.exportTasks(format, fromDate, toDate))
+  }
+
+  // 获取ZIP附件任务解压出的文件
+  const getTaskFiles = async (taskId: number) => {
+    return await safeCall(() => api.download.getTaskFiles(taskId))
+  }
+
+  const getTaskSpeedSamples = async (taskId: number) => {
+    return await safeCall(() => api.download.getSpeedSamples(taskId))
+  }
+
+  // 获取任务的事件记录（开始、重试、失败原因等），按时间顺序
+  const getTaskLog = async (taskId: number) => {
+    return (await safeCall(() => api.download.getTaskLog(taskId))) || []
+  }
+
+  // 获取已完成PDF的标题、作者、页数和缩略图
+  const getPDFMetadata = async (taskId: number) => {
+    return await safeCall(() => api.download.getPDFMetadata(taskId))
+  }
+
+  // 获取任务详情，包含来源邮件和所属邮箱账户
+  const getTaskDetail = async (taskId: number) => {
+    return await safeCall(() => api.download.getTaskDetail(taskId))
+  }
+
+  // 重新验证任务的本地文件，未通过时任务被标记为失败
+  const revalidateTask = async (taskId: number) => {
+    const result = await safeCall(() => api.download.revalidateTask(taskId))
+    if (result && !result.valid) {
+      await loadDownloadTasks()
+    }
+    return result
+  }
+
+  // 按错误原因汇总失败的任务
+  const getFailedTasksSummary = async () => {
+    return await safeCall(() => api.download.getFailedTasksSummary())
+  }
+
   // 设置管理的便捷方法
   const saveSettings = async (settings: any) => {
     const configToSave: Partial<AppConfig> = {
@@ -205,7 +394,45 @@ export const useAppStore = defineStore('app', () => {
       start_minimized: settings.startMinimized || false,
       enable_notification: settings.enableNotification !== undefined ? settings.enableNotification : true,
       theme: settings.theme || 'light',
-      language: settings.language || 'zh-CN'
+      language: settings.language || 'zh-CN',
+      auto_open_file: settings.autoOpenFile || false,
+      auto_open_folder: settings.autoOpenFolder || false,
+      quarantine_invalid_files: settings.quarantineInvalidFiles || false,
+      fetch_batch_size: settings.fetchBatchSize || 50,
+      cert_pinning_mode: settings.certPinningMode || 'off',
+      relative_paths: settings.relativePaths || false,
+      extract_zip_attachments: settings.extractZipAttachments || false,
+      allowed_extensions: settings.allowedExtensions || [],
+      max_redirects: settings.maxRedirects || 5,
+      quick_retry_attempts: settings.quickRetryAttempts ?? 2,
+      max_task_retries: settings.maxTaskRetries ?? 3,
+      task_retry_backoff_seconds: settings.taskRetryBackoffSeconds || 30,
+      account_check_timeout_minutes: settings.accountCheckTimeoutMinutes || 5,
+      statistics_date_source: settings.statisticsDateSource || 'completed',
+      completion_sound: settings.completionSound || false,
+      organize_by: settings.organizeBy || 'none',
+      recovery_delay_seconds: settings.recoveryDelaySeconds ?? 2,
+      recovery_tasks_per_minute: settings.recoveryTasksPerMinute ?? 30,
+      recovery_max_age_hours: settings.recoveryMaxAgeHours || 24,
+      pending_expiry_hours: settings.pendingExpiryHours ?? 24,
+      scan_window_days: settings.scanWindowDays || 7,
+      initial_scan_days: settings.initialScanDays ?? 0,
+      proxy_url: (settings.proxyUrl || '').trim(),
+      strict_pdf_validation: settings.strictPdfValidation || false,
+      deduplicate_downloads: settings.deduplicateDownloads || false,
+      webhook_url: (settings.webhookUrl || '').trim(),
+      webhook_secret: settings.webhookSecret || '',
+      max_file_size_mb: settings.maxFileSizeMB ?? 0,
+      min_free_memory_mb: settings.minFreeMemoryMB ?? 0,
+      sender_allowlist: settings.senderAllowlist || '',
+      sender_blocklist: settings.senderBlocklist || '',
+      index_pdf_metadata: settings.indexPdfMetadata || false,
+      pdf_thumbnails: settings.pdfThumbnails || false,
+      merge_pdf_pattern: settings.mergePdfPattern || '',
+      mark_as_read: settings.markAsRead || false,
+      max_imap_connections: settings.maxImapConnections || 0,
+      quiet_hours_start: (settings.quietHoursStart || '').trim(),
+      quiet_hours_end: (settings.quietHoursEnd || '').trim()
     }
     
     await updateConfig(configToSave)
@@ -223,7 +450,45 @@ export const useAppStore = defineStore('app', () => {
         startMinimized: false,
         enableNotification: true,
         theme: 'light',
-        language: 'zh-CN'
+        language: 'zh-CN',
+        autoOpenFile: false,
+        autoOpenFolder: false,
+        quarantineInvalidFiles: false,
+        fetchBatchSize: 50,
+        certPinningMode: 'off',
+        relativePaths: false,
+        extractZipAttachments: false,
+        allowedExtensions: [],
+        maxRedirects: 5,
+        quickRetryAttempts: 2,
+        maxTaskRetries: 3,
+        taskRetryBackoffSeconds: 30,
+        accountCheckTimeoutMinutes: 5,
+        statisticsDateSource: 'completed',
+        completionSound: false,
+        organizeBy: 'none',
+        recoveryDelaySeconds: 2,
+        recoveryTasksPerMinute: 30,
+        recoveryMaxAgeHours: 24,
+        pendingExpiryHours: 24,
+        scanWindowDays: 7,
+        initialScanDays: 0,
+        proxyUrl: '',
+        strictPdfValidation: false,
+        deduplicateDownloads: false,
+        webhookUrl: '',
+        webhookSecret: '',
+        maxFileSizeMB: 0,
+        minFreeMemoryMB: 0,
+        senderAllowlist: '',
+        senderBlocklist: '',
+        indexPdfMetadata: false,
+        pdfThumbnails: false,
+        mergePdfPattern: '',
+        markAsRead: false,
+        maxImapConnections: 0,
+        quietHoursStart: '',
+        quietHoursEnd: ''
       }
     }
     
@@ -236,7 +501,45 @@ export const useAppStore = defineStore('app', () => {
       startMinimized: config.start_minimized || false,
       enableNotification: config.enable_notification !== undefined ? config.enable_notification : true,
       theme: config.theme || 'light',
-      language: config.language || 'zh-CN'
+      language: config.language || 'zh-CN',
+      autoOpenFile: config.auto_open_file || false,
+      autoOpenFolder: config.auto_open_folder || false,
+      quarantineInvalidFiles: config.quarantine_invalid_files || false,
+      fetchBatchSize: config.fetch_batch_size || 50,
+      certPinningMode: config.cert_pinning_mode || 'off',
+      relativePaths: config.relative_paths || false,
+      extractZipAttachments: config.extract_zip_attachments || false,
+      allowedExtensions: config.allowed_extensions || [],
+      maxRedirects: config.max_redirects || 5,
+      quickRetryAttempts: config.quick_retry_attempts ?? 2,
+      maxTaskRetries: config.max_task_retries ?? 3,
+      taskRetryBackoffSeconds: config.task_retry_backoff_seconds || 30,
+      accountCheckTimeoutMinutes: config.account_check_timeout_minutes || 5,
+      statisticsDateSource: config.statistics_date_source || 'completed',
+      completionSound: config.completion_sound || false,
+      organizeBy: config.organize_by || 'none',
+      recoveryDelaySeconds: config.recovery_delay_seconds ?? 2,
+      recoveryTasksPerMinute: config.recovery_tasks_per_minute ?? 30,
+      recoveryMaxAgeHours: config.recovery_max_age_hours || 24,
+      pendingExpiryHours: config.pending_expiry_hours ?? 24,
+      scanWindowDays: config.scan_window_days || 7,
+      initialScanDays: config.initial_scan_days ?? 0,
+      proxyUrl: config.proxy_url || '',
+      strictPdfValidation: config.strict_pdf_validation || false,
+      deduplicateDownloads: config.deduplicate_downloads || false,
+      webhookUrl: config.webhook_url || '',
+      webhookSecret: config.webhook_secret || '',
+      maxFileSizeMB: config.max_file_size_mb ?? 0,
+      minFreeMemoryMB: config.min_free_memory_mb ?? 0,
+      senderAllowlist: config.sender_allowlist || '',
+      senderBlocklist: config.sender_blocklist || '',
+      indexPdfMetadata: config.index_pdf_metadata || false,
+      pdfThumbnails: config.pdf_thumbnails || false,
+      mergePdfPattern: config.merge_pdf_pattern || '',
+      markAsRead: config.mark_as_read || false,
+      maxImapConnections: config.max_imap_connections || 0,
+      quietHoursStart: config.quiet_hours_start || '',
+      quietHoursEnd: config.quiet_hours_end || ''
     }
   }
 
@@ -260,23 +563,53 @@ export const useAppStore = defineStore('app', () => {
     // Actions
     loadConfig,
     updateConfig,
+    getRules,
+    setRules,
     loadEmailAccounts,
+    bulkSetAccountsActive,
+    bulkDeleteEmailAccounts,
+    acceptNewCertificate,
+    resetAccountSyncState,
+    validateFolders,
+    previewAccount,
     addEmailAccount,
     updateEmailAccount,
     deleteEmailAccount,
     testEmailConnection,
+    diagnoseConnection,
+    getSSHHostKeyFingerprint,
+    testAccountConnection,
     checkAllEmails,
     checkSingleEmail,
+    scanAccountRange,
     startEmailMonitoring,
     stopEmailMonitoring,
     loadDownloadTasks,
     pauseTask,
     resumeTask,
+    retryTask,
     cancelTask,
+    cancelAllDownloads,
+    deleteTask,
+    clearCompletedTasks,
+    loadNetworkActivity,
     loadStatistics,
     checkServiceStatus,
     openDownloadFolder,
     selectDownloadFolder,
+    validateDownloadPath,
+    cleanOrphanedTempFiles,
+    testProxy,
+    importEMLFile,
+    generateMonthlyReport,
+    exportTasks,
+    getTaskFiles,
+    getTaskSpeedSamples,
+    getTaskLog,
+    getPDFMetadata,
+    getTaskDetail,
+    revalidateTask,
+    getFailedTasksSummary,
     saveSettings,
     loadSettings
   }
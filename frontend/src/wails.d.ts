@@ -6,29 +6,47 @@ declare global {
         App: {
           // 邮箱账户管理
           GetEmailAccounts(): Promise<EmailAccount[]>
-          CreateEmailAccount(account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<void>
+          CreateEmailAccount(account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<{ account: EmailAccount, warning: string }>
           UpdateEmailAccount(account: EmailAccount): Promise<void>
           DeleteEmailAccount(id: number): Promise<void>
           TestEmailConnection(account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<void>
+          TestConnectionAndUpdate(accountID: number): Promise<void>
+          DiagnoseConnection(account: Omit<EmailAccount, 'id' | 'created_at' | 'updated_at'>): Promise<ConnectionDiagnostics>
           
           // 邮件检查
           CheckAllEmails(): Promise<void>
           CheckSingleEmail(accountID: number): Promise<void>
+          ScanAccountRange(accountID: number, since: string, before: string): Promise<{ account: EmailAccount | null, new_emails: number, pdfs_found: number, error?: string, success: boolean, retries: number }>
+          ResetAccountSyncState(accountID: number): Promise<void>
+          PreviewAccount(accountID: number): Promise<{ message_id: string, subject: string, sender: string, type: 'attachment' | 'link', source: string, file_name: string, file_size: number, local_path: string }[]>
+          SearchEmailMessages(query: string, page: number, pageSize: number): Promise<EmailMessage[]>
           StartEmailMonitoring(): Promise<void>
           StopEmailMonitoring(): Promise<void>
           
           // 下载任务管理
           GetDownloadTasks(page: number, pageSize: number): Promise<{ tasks: DownloadTask[], total: number }>
+          GetDownloadTasksFiltered(filter: { status?: string, account_id?: number, sort_by?: string, sort_order?: string, page?: number, page_size?: number }): Promise<{ tasks: DownloadTask[], total: number }>
           GetDownloadTasksByStatus(status: string): Promise<DownloadTask[]>
           CreateDownloadTask(task: Omit<DownloadTask, 'id' | 'created_at' | 'updated_at'>): Promise<void>
           PauseDownloadTask(taskID: number): Promise<void>
           ResumeDownloadTask(taskID: number): Promise<void>
+          RetryDownloadTask(taskID: number): Promise<void>
+          GetTaskSpeedSamples(taskID: number): Promise<{ timestamp: string, bytes_per_second: number }[]>
+          GetTaskLog(taskID: number): Promise<{ id: number, task_id: number, level: 'info' | 'warning' | 'error', message: string, created_at: string }[]>
+          GetPDFMetadata(taskID: number): Promise<{ task_id: number, title: string, author: string, page_count: number, thumbnail: string, indexed_at: string }>
+          ExportTasks(format: 'csv' | 'json', fromDate: string, toDate: string): Promise<string>
+          GetTaskDetail(taskID: number): Promise<{ task: DownloadTask, message: EmailMessage | null, account: EmailAccount | null }>
+          RevalidateTask(taskID: number): Promise<{ valid: boolean, error: string, status: string, extracted_errors: string[] }>
           CancelDownloadTask(taskID: number): Promise<void>
+          CancelAllDownloads(): Promise<number>
           GetActiveDownloads(): Promise<DownloadTask[]>
           
           // 配置管理
           GetConfig(): Promise<AppConfig>
           UpdateConfig(config: AppConfig): Promise<void>
+          TestProxy(proxyURL: string): Promise<void>
+          GetRules(): Promise<Rule[]>
+          SetRules(rules: Rule[]): Promise<void>
           
           // 统计数据
           GetStatistics(days: number): Promise<DownloadStatistics[]>
@@ -46,7 +64,7 @@ declare global {
           
           // 系统信息
           GetAppInfo(): Promise<Record<string, any>>
-          GetServiceStatus(): Promise<Record<string, boolean>>
+          GetServiceStatus(): Promise<Record<string, boolean | number>>
           IsEmailServiceRunning(): Promise<boolean>
           GetActiveDownloadsCount(): Promise<number>
         }
@@ -65,10 +83,52 @@ export interface EmailAccount {
   imap_port: number
   use_ssl: boolean
   is_active: boolean
+  search_criteria?: string
+  scan_folders?: string[]
+  can_modify_flags?: boolean
+  can_move_messages?: boolean
+  cert_fingerprint?: string
+  pending_cert_fingerprint?: string
+  download_path?: string
+  allowed_extensions?: string[]
+  folder?: string
+  use_idle?: boolean
+  ssh_host?: string
+  ssh_port?: number
+  ssh_user?: string
+  ssh_key_path?: string
+  ssh_host_key?: string
+  auth_type?: string
+  oauth_client_id?: string
+  oauth_client_secret?: string
+  oauth_refresh_token?: string
+  oauth_token_url?: string
+  last_error?: string
+  last_error_category?: string
+  last_checked_at?: string
   created_at: string
   updated_at: string
 }
 
+// 连接诊断单个阶段的结果
+export interface DiagnosticStage {
+  attempted: boolean
+  success: boolean
+  duration_ms: number
+  detail: string
+}
+
+// 连接诊断接口
+export interface ConnectionDiagnostics {
+  server: string
+  dns: DiagnosticStage
+  tcp: DiagnosticStage
+  tls: DiagnosticStage
+  login: DiagnosticStage
+  inbox: DiagnosticStage
+  failed_stage: '' | 'dns' | 'tcp' | 'tls' | 'login' | 'inbox'
+}
+
 // 下载任务接口
 export interface DownloadTask {
   id: number
@@ -79,8 +139,8 @@ export interface DownloadTask {
   file_name: string
   file_size: number
   downloaded_size: number
-  status: 'pending' | 'downloading' | 'completed' | 'failed' | 'paused' | 'cancelled'
-  type: 'attachment' | 'link'
+  status: 'pending' | 'downloading' | 'completed' | 'failed' | 'paused' | 'cancelled' | 'incomplete' | 'expired'
+  type: 'attachment' | 'link' | 'partial' | 'merged'
   source: string
   local_path: string
   error: string
@@ -88,6 +148,35 @@ export interface DownloadTask {
   speed: string
   created_at: string
   updated_at: string
+  is_container?: boolean
+  retry_count?: number
+  message_id?: number
+  hash?: string
+  duplicate_of?: number
+  merged_into?: number
+}
+
+// 邮件记录接口
+export interface EmailMessage {
+  id: number
+  email_id: number
+  email_account: EmailAccount
+  message_id: string
+  subject: string
+  sender: string
+  recipients: string
+  date: string
+  has_pdf: boolean
+  is_processed: boolean
+  created_at: string
+  updated_at: string
+  gmail_message_id: string
+  gmail_thread_id: string
+  pdf_total: number
+  tasks_created: number
+  pdf_downloaded: number
+  pdf_failed: number
+  extraction_status: '' | 'pending' | 'complete' | 'partial' | 'failed'
 }
 
 // 应用配置接口
@@ -106,6 +195,20 @@ export interface AppConfig {
   updated_at: string
 }
 
+// 下载规则接口
+export interface Rule {
+  id: number
+  name: string
+  account_id: number
+  field: 'subject' | 'sender' | 'filename'
+  pattern: string
+  action: 'skip' | 'download'
+  enabled: boolean
+  position: number
+  created_at: string
+  updated_at: string
+}
+
 // 下载统计接口
 export interface DownloadStatistics {
   id: number
@@ -116,6 +219,8 @@ export interface DownloadStatistics {
   total_size: number
   created_at: string
   updated_at: string
+  deduplicated_downloads: number
+  deduplicated_size: number
 }
 
 export {} 
\ No newline at end of file
@@ -3,40 +3,100 @@
 import {models} from '../models';
 import {backend} from '../models';
 
+export function AcceptNewCertificate(arg1:number):Promise<void>;
+
+export function BulkDeleteAccounts(arg1:Array<number>):Promise<Array<models.BulkAccountResult>>;
+
+export function BulkImport(arg1:number,arg2:string,arg3:number):Promise<number>;
+
+export function BulkSetAccountsActive(arg1:Array<number>,arg2:boolean):Promise<Array<models.BulkAccountResult>>;
+
+export function CancelAllDownloads():Promise<number>;
+
 export function CancelDownloadTask(arg1:number):Promise<void>;
 
 export function CheckAllEmails():Promise<Array<models.EmailCheckResult>>;
 
 export function CheckSingleEmail(arg1:number):Promise<models.EmailCheckResult>;
 
+export function CleanOrphanedTempFiles():Promise<number>;
+
+export function ClearLogs():Promise<void>;
+
 export function CreateConfig(arg1:models.AppConfig):Promise<void>;
 
 export function CreateDownloadTask(arg1:models.DownloadTask):Promise<void>;
 
-export function CreateEmailAccount(arg1:models.EmailAccount):Promise<void>;
+export function CreateEmailAccount(arg1:models.EmailAccount):Promise<backend.AccountCreateResult>;
+
+export function CreateEmailAccountAsync(arg1:models.EmailAccount):Promise<models.EmailAccount>;
+
+export function DeleteDownloadTask(arg1:number,arg2:boolean):Promise<void>;
 
 export function DeleteEmailAccount(arg1:number):Promise<void>;
 
+export function DiagnoseConnection(arg1:models.EmailAccount):Promise<models.ConnectionDiagnostics>;
+
+export function ExportTasks(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function GenerateMonthlyReport(arg1:number,arg2:number,arg3:string):Promise<string>;
+
+export function GetAccountSpecialFolders(arg1:number,arg2:boolean):Promise<Record<string, string>>;
+
 export function GetActiveDownloads():Promise<Array<models.DownloadTask>>;
 
 export function GetActiveDownloadsCount():Promise<number>;
 
 export function GetAppInfo():Promise<Record<string, any>>;
 
+export function GetCertificateFingerprint(arg1:number):Promise<string>;
+
 export function GetConfig():Promise<models.AppConfig>;
 
+export function GetDownloadMetrics():Promise<models.DownloadMetrics>;
+
 export function GetDownloadTasks(arg1:number,arg2:number):Promise<backend.GetDownloadTasksResponse>;
 
 export function GetDownloadTasksByStatus(arg1:models.DownloadStatus):Promise<Array<models.DownloadTask>>;
 
+export function GetDownloadTasksFiltered(arg1:models.DownloadTaskFilter):Promise<backend.GetDownloadTasksResponse>;
+
 export function GetEmailAccounts():Promise<Array<models.EmailAccount>>;
 
 export function GetEmailMessages(arg1:number,arg2:number):Promise<Array<models.EmailMessage>>;
 
-export function GetServiceStatus():Promise<Record<string, boolean>>;
+export function GetFailedTasksSummary():Promise<Array<models.FailureCategorySummary>>;
+
+export function GetMessageHeaders(arg1:string):Promise<Record<string, Array<string>>>;
+
+export function GetNetworkActivity():Promise<models.NetworkActivity>;
+
+export function GetPDFMetadata(arg1:number):Promise<models.PDFMetadata>;
+
+export function GetRecentLogs(arg1:number):Promise<Array<string>>;
+
+export function GetRules():Promise<Array<models.Rule>>;
+
+export function GetSSHHostKeyFingerprint(arg1:models.EmailAccount):Promise<string>;
+
+export function GetServiceStatus():Promise<Record<string, any>>;
 
 export function GetStatistics(arg1:number):Promise<Array<models.DownloadStatistics>>;
 
+export function GetTaskChecksum(arg1:number):Promise<backend.TaskChecksumResponse>;
+
+export function GetTaskDetail(arg1:number):Promise<models.TaskDetail>;
+
+export function GetTaskFiles(arg1:number):Promise<Array<models.TaskFile>>;
+
+export function GetTaskLog(arg1:number):Promise<Array<models.TaskLogEntry>>;
+
+export function GetTaskSpeedSamples(arg1:number):Promise<Array<models.SpeedSample>>;
+
+export function ImportEMLFile(arg1:string):Promise<Array<models.DownloadTask>>;
+
+export function IsDownloadPausedBySchedule():Promise<boolean>;
+
 export function IsEmailServiceRunning():Promise<boolean>;
 
 export function MinimizeToTray():Promise<void>;
@@ -47,24 +107,56 @@ export function OpenFile(arg1:string):Promise<void>;
 
 export function PauseDownloadTask(arg1:number):Promise<void>;
 
+export function PreviewAccount(arg1:number):Promise<Array<models.SourcePreview>>;
+
 export function QuitApp():Promise<void>;
 
+export function ReprocessAll(arg1:string,arg2:number):Promise<number>;
+
+export function ResetAccountSyncState(arg1:number):Promise<void>;
+
 export function RestoreFromTray():Promise<void>;
 
 export function ResumeDownloadTask(arg1:number):Promise<void>;
 
+export function RetryDownloadTask(arg1:number):Promise<void>;
+
+export function RevalidateTask(arg1:number):Promise<models.ValidationResult>;
+
+export function ScanAccountRange(arg1:number,arg2:string,arg3:string):Promise<models.EmailCheckResult>;
+
+export function SearchEmailMessages(arg1:string,arg2:number,arg3:number):Promise<Array<models.EmailMessage>>;
+
 export function SelectDownloadFolder():Promise<string>;
 
+export function SelectEMLFile():Promise<string>;
+
+export function SetAccountMonitoring(arg1:number,arg2:boolean):Promise<void>;
+
+export function SetRules(arg1:Array<models.Rule>):Promise<void>;
+
+export function SetTelemetryEnabled(arg1:boolean):Promise<void>;
+
 export function ShowNotification(arg1:string,arg2:string):Promise<void>;
 
 export function StartEmailMonitoring():Promise<void>;
 
 export function StopEmailMonitoring():Promise<void>;
 
+export function TestConnectionAndUpdate(arg1:number):Promise<void>;
+
 export function TestEmailConnection(arg1:models.EmailAccount):Promise<void>;
 
 export function TestEmailConnectionByID(arg1:number):Promise<void>;
 
+export function TestProxy(arg1:string):Promise<void>;
+
 export function UpdateConfig(arg1:models.AppConfig):Promise<void>;
 
 export function UpdateEmailAccount(arg1:models.EmailAccount):Promise<void>;
+
+export function ValidateDownloadPath(arg1:string):Promise<models.PathCheck>;
+
+export function ValidateFolders(arg1:number):Promise<Array<models.FolderCheck>>;
+
+export function ValidateSearchCriteria(arg1:string):Promise<void>;
@@ -2,6 +2,26 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AcceptNewCertificate(arg1) {
+  return window['go']['backend']['App']['AcceptNewCertificate'](arg1);
+}
+
+export function BulkDeleteAccounts(arg1) {
+  return window['go']['backend']['App']['BulkDeleteAccounts'](arg1);
+}
+
+export function BulkImport(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['BulkImport'](arg1, arg2, arg3);
+}
+
+export function BulkSetAccountsActive(arg1, arg2) {
+  return window['go']['backend']['App']['BulkSetAccountsActive'](arg1, arg2);
+}
+
+export function CancelAllDownloads() {
+  return window['go']['backend']['App']['CancelAllDownloads']();
+}
+
 export function CancelDownloadTask(arg1) {
   return window['go']['backend']['App']['CancelDownloadTask'](arg1);
 }
@@ -14,6 +34,14 @@ export function CheckSingleEmail(arg1) {
   return window['go']['backend']['App']['CheckSingleEmail'](arg1);
 }
 
+export function CleanOrphanedTempFiles() {
+  return window['go']['backend']['App']['CleanOrphanedTempFiles']();
+}
+
+export function ClearLogs() {
+  return window['go']['backend']['App']['ClearLogs']();
+}
+
 export function CreateConfig(arg1) {
   return window['go']['backend']['App']['CreateConfig'](arg1);
 }
@@ -26,10 +54,34 @@ export function CreateEmailAccount(arg1) {
   return window['go']['backend']['App']['CreateEmailAccount'](arg1);
 }
 
+export function CreateEmailAccountAsync(arg1) {
+  return window['go']['backend']['App']['CreateEmailAccountAsync'](arg1);
+}
+
+export function DeleteDownloadTask(arg1, arg2) {
+  return window['go']['backend']['App']['DeleteDownloadTask'](arg1, arg2);
+}
+
 export function DeleteEmailAccount(arg1) {
   return window['go']['backend']['App']['DeleteEmailAccount'](arg1);
 }
 
+export function DiagnoseConnection(arg1) {
+  return window['go']['backend']['App']['DiagnoseConnection'](arg1);
+}
+
+export function ExportTasks(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['ExportTasks'](arg1, arg2, arg3);
+}
+
+export function GenerateMonthlyReport(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['GenerateMonthlyReport'](arg1, arg2, arg3);
+}
+
+export function GetAccountSpecialFolders(arg1, arg2) {
+  return window['go']['backend']['App']['GetAccountSpecialFolders'](arg1, arg2);
+}
+
 export function GetActiveDownloads() {
   return window['go']['backend']['App']['GetActiveDownloads']();
 }
@@ -42,10 +94,18 @@ export function GetAppInfo() {
   return window['go']['backend']['App']['GetAppInfo']();
 }
 
+export function GetCertificateFingerprint(arg1) {
+  return window['go']['backend']['App']['GetCertificateFingerprint'](arg1);
+}
+
 export function GetConfig() {
   return window['go']['backend']['App']['GetConfig']();
 }
 
+export function GetDownloadMetrics() {
+  return window['go']['backend']['App']['GetDownloadMetrics']();
+}
+
 export function GetDownloadTasks(arg1, arg2) {
   return window['go']['backend']['App']['GetDownloadTasks'](arg1, arg2);
 }
@@ -54,6 +114,10 @@ export function GetDownloadTasksByStatus(arg1) {
   return window['go']['backend']['App']['GetDownloadTasksByStatus'](arg1);
 }
 
+export function GetDownloadTasksFiltered(arg1) {
+  return window['go']['backend']['App']['GetDownloadTasksFiltered'](arg1);
+}
+
 export function GetEmailAccounts() {
   return window['go']['backend']['App']['GetEmailAccounts']();
 }
@@ -62,6 +126,34 @@ export function GetEmailMessages(arg1, arg2) {
   return window['go']['backend']['App']['GetEmailMessages'](arg1, arg2);
 }
 
+export function GetFailedTasksSummary() {
+  return window['go']['backend']['App']['GetFailedTasksSummary']();
+}
+
+export function GetMessageHeaders(arg1) {
+  return window['go']['backend']['App']['GetMessageHeaders'](arg1);
+}
+
+export function GetNetworkActivity() {
+  return window['go']['backend']['App']['GetNetworkActivity']();
+}
+
+export function GetPDFMetadata(arg1) {
+  return window['go']['backend']['App']['GetPDFMetadata'](arg1);
+}
+
+export function GetRecentLogs(arg1) {
+  return window['go']['backend']['App']['GetRecentLogs'](arg1);
+}
+
+export function GetRules() {
+  return window['go']['backend']['App']['GetRules']();
+}
+
+export function GetSSHHostKeyFingerprint(arg1) {
+  return window['go']['backend']['App']['GetSSHHostKeyFingerprint'](arg1);
+}
+
 export function GetServiceStatus() {
   return window['go']['backend']['App']['GetServiceStatus']();
 }
@@ -70,6 +162,34 @@ export function GetStatistics(arg1) {
   return window['go']['backend']['App']['GetStatistics'](arg1);
 }
 
+export function GetTaskChecksum(arg1) {
+  return window['go']['backend']['App']['GetTaskChecksum'](arg1);
+}
+
+export function GetTaskDetail(arg1) {
+  return window['go']['backend']['App']['GetTaskDetail'](arg1);
+}
+
+export function GetTaskFiles(arg1) {
+  return window['go']['backend']['App']['GetTaskFiles'](arg1);
+}
+
+export function GetTaskLog(arg1) {
+  return window['go']['backend']['App']['GetTaskLog'](arg1);
+}
+
+export function GetTaskSpeedSamples(arg1) {
+  return window['go']['backend']['App']['GetTaskSpeedSamples'](arg1);
+}
+
+export function ImportEMLFile(arg1) {
+  return window['go']['backend']['App']['ImportEMLFile'](arg1);
+}
+
+export function IsDownloadPausedBySchedule() {
+  return window['go']['backend']['App']['IsDownloadPausedBySchedule']();
+}
+
 export function IsEmailServiceRunning() {
   return window['go']['backend']['App']['IsEmailServiceRunning']();
 }
@@ -90,10 +210,22 @@ export function PauseDownloadTask(arg1) {
   return window['go']['backend']['App']['PauseDownloadTask'](arg1);
 }
 
+export function PreviewAccount(arg1) {
+  return window['go']['backend']['App']['PreviewAccount'](arg1);
+}
+
 export function QuitApp() {
   return window['go']['backend']['App']['QuitApp']();
 }
 
+export function ReprocessAll(arg1, arg2) {
+  return window['go']['backend']['App']['ReprocessAll'](arg1, arg2);
+}
+
+export function ResetAccountSyncState(arg1) {
+  return window['go']['backend']['App']['ResetAccountSyncState'](arg1);
+}
+
 export function RestoreFromTray() {
   return window['go']['backend']['App']['RestoreFromTray']();
 }
@@ -102,10 +234,42 @@ export function ResumeDownloadTask(arg1) {
   return window['go']['backend']['App']['ResumeDownloadTask'](arg1);
 }
 
+export function RetryDownloadTask(arg1) {
+  return window['go']['backend']['App']['RetryDownloadTask'](arg1);
+}
+
+export function RevalidateTask(arg1) {
+  return window['go']['backend']['App']['RevalidateTask'](arg1);
+}
+
+export function ScanAccountRange(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['ScanAccountRange'](arg1, arg2, arg3);
+}
+
+export function SearchEmailMessages(arg1, arg2, arg3) {
+  return window['go']['backend']['App']['SearchEmailMessages'](arg1, arg2, arg3);
+}
+
 export function SelectDownloadFolder() {
   return window['go']['backend']['App']['SelectDownloadFolder']();
 }
 
+export function SelectEMLFile() {
+  return window['go']['backend']['App']['SelectEMLFile']();
+}
+
+export function SetAccountMonitoring(arg1, arg2) {
+  return window['go']['backend']['App']['SetAccountMonitoring'](arg1, arg2);
+}
+
+export function SetRules(arg1) {
+  return window['go']['backend']['App']['SetRules'](arg1);
+}
+
+export function SetTelemetryEnabled(arg1) {
+  return window['go']['backend']['App']['SetTelemetryEnabled'](arg1);
+}
+
 export function ShowNotification(arg1, arg2) {
   return window['go']['backend']['App']['ShowNotification'](arg1, arg2);
 }
@@ -118,6 +282,10 @@ export function StopEmailMonitoring() {
   return window['go']['backend']['App']['StopEmailMonitoring']();
 }
 
+export function TestConnectionAndUpdate(arg1) {
+  return window['go']['backend']['App']['TestConnectionAndUpdate'](arg1);
+}
+
 export function TestEmailConnection(arg1) {
   return window['go']['backend']['App']['TestEmailConnection'](arg1);
 }
@@ -126,6 +294,10 @@ export function TestEmailConnectionByID(arg1) {
   return window['go']['backend']['App']['TestEmailConnectionByID'](arg1);
 }
 
+export function TestProxy(arg1) {
+  return window['go']['backend']['App']['TestProxy'](arg1);
+}
+
 export function UpdateConfig(arg1) {
   return window['go']['backend']['App']['UpdateConfig'](arg1);
 }
@@ -133,3 +305,15 @@ export function UpdateConfig(arg1) {
 export function UpdateEmailAccount(arg1) {
   return window['go']['backend']['App']['UpdateEmailAccount'](arg1);
 }
+
+export function ValidateDownloadPath(arg1) {
+  return window['go']['backend']['App']['ValidateDownloadPath'](arg1);
+}
+
+export function ValidateFolders(arg1) {
+  return window['go']['backend']['App']['ValidateFolders'](arg1);
+}
+
+export function ValidateSearchCriteria(arg1) {
+  return window['go']['backend']['App']['ValidateSearchCriteria'](arg1);
+}
@@ -1,5 +1,37 @@
 export namespace backend {
 	
+	export class AccountCreateResult {
+	    account: models.EmailAccount;
+	    warning: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AccountCreateResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.account = this.convertValues(source["account"], models.EmailAccount);
+	        this.warning = source["warning"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class GetDownloadTasksResponse {
 	    tasks: models.DownloadTask[];
 	    total: number;
@@ -32,6 +64,22 @@ export namespace backend {
 		    return a;
 		}
 	}
+	export class TaskChecksumResponse {
+	    task_id: number;
+	    algorithm: string;
+	    checksum: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskChecksumResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.task_id = source["task_id"];
+	        this.algorithm = source["algorithm"];
+	        this.checksum = source["checksum"];
+	    }
+	}
 
 }
 
@@ -48,6 +96,55 @@ export namespace models {
 	    enable_notification: boolean;
 	    theme: string;
 	    language: string;
+	    max_tasks_per_check: number;
+	    telemetry_enabled: boolean;
+	    telemetry_endpoint: string;
+	    path_template: string;
+	    checksum_algorithm: string;
+	    download_window_start: string;
+	    download_window_end: string;
+	    quiet_hours_start: string;
+	    quiet_hours_end: string;
+	    download_deadline_minutes: number;
+	    check_scope: string;
+	    check_scope_days: number;
+	    duplicate_source_preference: string;
+	    auto_open_file: boolean;
+	    auto_open_folder: boolean;
+	    quarantine_invalid_files: boolean;
+	    fetch_batch_size: number;
+	    cert_pinning_mode: string;
+	    relative_paths: boolean;
+	    max_redirects: number;
+	    quick_retry_attempts: number;
+	    max_task_retries: number;
+	    task_retry_backoff_seconds: number;
+	    account_check_timeout_minutes: number;
+	    extract_zip_attachments: boolean;
+	    allowed_extensions: string[];
+	    statistics_date_source: string;
+	    completion_sound: boolean;
+	    organize_by: string;
+	    recovery_delay_seconds: number;
+	    recovery_tasks_per_minute: number;
+	    recovery_max_age_hours: number;
+	    pending_expiry_hours: number;
+	    scan_window_days: number;
+	    initial_scan_days: number;
+	    proxy_url: string;
+	    strict_pdf_validation: boolean;
+	    deduplicate_downloads: boolean;
+	    webhook_url: string;
+	    webhook_secret: string;
+	    max_file_size_mb: number;
+	    min_free_memory_mb: number;
+	    sender_allowlist: string;
+	    sender_blocklist: string;
+	    index_pdf_metadata: boolean;
+	    pdf_thumbnails: boolean;
+	    merge_pdf_pattern: string;
+	    mark_as_read: boolean;
+	    max_imap_connections: number;
 	    created_at: string;
 	    updated_at: string;
 	
@@ -67,10 +164,154 @@ export namespace models {
 	        this.enable_notification = source["enable_notification"];
 	        this.theme = source["theme"];
 	        this.language = source["language"];
+	        this.max_tasks_per_check = source["max_tasks_per_check"];
+	        this.telemetry_enabled = source["telemetry_enabled"];
+	        this.telemetry_endpoint = source["telemetry_endpoint"];
+	        this.path_template = source["path_template"];
+	        this.checksum_algorithm = source["checksum_algorithm"];
+	        this.download_window_start = source["download_window_start"];
+	        this.download_window_end = source["download_window_end"];
+	        this.quiet_hours_start = source["quiet_hours_start"];
+	        this.quiet_hours_end = source["quiet_hours_end"];
+	        this.download_deadline_minutes = source["download_deadline_minutes"];
+	        this.check_scope = source["check_scope"];
+	        this.check_scope_days = source["check_scope_days"];
+	        this.duplicate_source_preference = source["duplicate_source_preference"];
+	        this.auto_open_file = source["auto_open_file"];
+	        this.auto_open_folder = source["auto_open_folder"];
+	        this.quarantine_invalid_files = source["quarantine_invalid_files"];
+	        this.fetch_batch_size = source["fetch_batch_size"];
+	        this.cert_pinning_mode = source["cert_pinning_mode"];
+	        this.relative_paths = source["relative_paths"];
+	        this.max_redirects = source["max_redirects"];
+	        this.quick_retry_attempts = source["quick_retry_attempts"];
+	        this.max_task_retries = source["max_task_retries"];
+	        this.task_retry_backoff_seconds = source["task_retry_backoff_seconds"];
+	        this.account_check_timeout_minutes = source["account_check_timeout_minutes"];
+	        this.extract_zip_attachments = source["extract_zip_attachments"];
+	        this.allowed_extensions = source["allowed_extensions"];
+	        this.statistics_date_source = source["statistics_date_source"];
+	        this.completion_sound = source["completion_sound"];
+	        this.organize_by = source["organize_by"];
+	        this.recovery_delay_seconds = source["recovery_delay_seconds"];
+	        this.recovery_tasks_per_minute = source["recovery_tasks_per_minute"];
+	        this.recovery_max_age_hours = source["recovery_max_age_hours"];
+	        this.pending_expiry_hours = source["pending_expiry_hours"];
+	        this.scan_window_days = source["scan_window_days"];
+	        this.initial_scan_days = source["initial_scan_days"];
+	        this.proxy_url = source["proxy_url"];
+	        this.strict_pdf_validation = source["strict_pdf_validation"];
+	        this.deduplicate_downloads = source["deduplicate_downloads"];
+	        this.webhook_url = source["webhook_url"];
+	        this.webhook_secret = source["webhook_secret"];
+	        this.max_file_size_mb = source["max_file_size_mb"];
+	        this.min_free_memory_mb = source["min_free_memory_mb"];
+	        this.sender_allowlist = source["sender_allowlist"];
+	        this.sender_blocklist = source["sender_blocklist"];
+	        this.index_pdf_metadata = source["index_pdf_metadata"];
+	        this.pdf_thumbnails = source["pdf_thumbnails"];
+	        this.merge_pdf_pattern = source["merge_pdf_pattern"];
+	        this.mark_as_read = source["mark_as_read"];
+	        this.max_imap_connections = source["max_imap_connections"];
 	        this.created_at = source["created_at"];
 	        this.updated_at = source["updated_at"];
 	    }
 	}
+	export class BulkAccountResult {
+	    id: number;
+	    success: boolean;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BulkAccountResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.success = source["success"];
+	        this.error = source["error"];
+	    }
+	}
+	export class DiagnosticStage {
+	    attempted: boolean;
+	    success: boolean;
+	    duration_ms: number;
+	    detail: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DiagnosticStage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.attempted = source["attempted"];
+	        this.success = source["success"];
+	        this.duration_ms = source["duration_ms"];
+	        this.detail = source["detail"];
+	    }
+	}
+	export class ConnectionDiagnostics {
+	    server: string;
+	    dns: DiagnosticStage;
+	    tcp: DiagnosticStage;
+	    tls: DiagnosticStage;
+	    login: DiagnosticStage;
+	    inbox: DiagnosticStage;
+	    failed_stage: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConnectionDiagnostics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.server = source["server"];
+	        this.dns = this.convertValues(source["dns"], DiagnosticStage);
+	        this.tcp = this.convertValues(source["tcp"], DiagnosticStage);
+	        this.tls = this.convertValues(source["tls"], DiagnosticStage);
+	        this.login = this.convertValues(source["login"], DiagnosticStage);
+	        this.inbox = this.convertValues(source["inbox"], DiagnosticStage);
+	        this.failed_stage = source["failed_stage"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class DownloadMetrics {
+	    completed: number;
+	    failed: number;
+	    bytes_downloaded: number;
+	    progress_updates: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DownloadMetrics(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.completed = source["completed"];
+	        this.failed = source["failed"];
+	        this.bytes_downloaded = source["bytes_downloaded"];
+	        this.progress_updates = source["progress_updates"];
+	    }
+	}
 	export class DownloadStatistics {
 	    id: number;
 	    date: string;
@@ -80,6 +321,8 @@ export namespace models {
 	    total_size: number;
 	    created_at: string;
 	    updated_at: string;
+	    deduplicated_downloads: number;
+	    deduplicated_size: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new DownloadStatistics(source);
@@ -95,6 +338,8 @@ export namespace models {
 	        this.total_size = source["total_size"];
 	        this.created_at = source["created_at"];
 	        this.updated_at = source["updated_at"];
+	        this.deduplicated_downloads = source["deduplicated_downloads"];
+	        this.deduplicated_size = source["deduplicated_size"];
 	    }
 	}
 	export class EmailAccount {
@@ -108,6 +353,34 @@ export namespace models {
 	    is_active: boolean;
 	    created_at: string;
 	    updated_at: string;
+	    monitoring_paused: boolean;
+	    auth_mechanism: string;
+	    auth_type: string;
+	    last_error: string;
+	    search_criteria: string;
+	    last_error_category: string;
+	    last_checked_at: string;
+	    historical_import_done: boolean;
+	    can_modify_flags: boolean;
+	    can_move_messages: boolean;
+	    scan_folders: string[];
+	    cert_fingerprint: string;
+	    pending_cert_fingerprint: string;
+	    download_path: string;
+	    allowed_extensions: string[];
+	    folder: string;
+	    use_idle: boolean;
+	    ssh_host: string;
+	    ssh_port: number;
+	    ssh_user: string;
+	    ssh_key_path: string;
+	    ssh_host_key: string;
+	    oauth_client_id: string;
+	    oauth_client_secret: string;
+	    oauth_refresh_token: string;
+	    oauth_token_url: string;
+	    folder_delimiter: string;
+	    special_folders: Record<string, string>;
 	
 	    static createFrom(source: any = {}) {
 	        return new EmailAccount(source);
@@ -125,6 +398,34 @@ export namespace models {
 	        this.is_active = source["is_active"];
 	        this.created_at = source["created_at"];
 	        this.updated_at = source["updated_at"];
+	        this.monitoring_paused = source["monitoring_paused"];
+	        this.auth_mechanism = source["auth_mechanism"];
+	        this.auth_type = source["auth_type"];
+	        this.last_error = source["last_error"];
+	        this.search_criteria = source["search_criteria"];
+	        this.last_error_category = source["last_error_category"];
+	        this.last_checked_at = source["last_checked_at"];
+	        this.historical_import_done = source["historical_import_done"];
+	        this.can_modify_flags = source["can_modify_flags"];
+	        this.can_move_messages = source["can_move_messages"];
+	        this.scan_folders = source["scan_folders"];
+	        this.cert_fingerprint = source["cert_fingerprint"];
+	        this.pending_cert_fingerprint = source["pending_cert_fingerprint"];
+	        this.download_path = source["download_path"];
+	        this.allowed_extensions = source["allowed_extensions"];
+	        this.folder = source["folder"];
+	        this.use_idle = source["use_idle"];
+	        this.ssh_host = source["ssh_host"];
+	        this.ssh_port = source["ssh_port"];
+	        this.ssh_user = source["ssh_user"];
+	        this.ssh_key_path = source["ssh_key_path"];
+	        this.ssh_host_key = source["ssh_host_key"];
+	        this.oauth_client_id = source["oauth_client_id"];
+	        this.oauth_client_secret = source["oauth_client_secret"];
+	        this.oauth_refresh_token = source["oauth_refresh_token"];
+	        this.oauth_token_url = source["oauth_token_url"];
+	        this.folder_delimiter = source["folder_delimiter"];
+	        this.special_folders = source["special_folders"];
 	    }
 	}
 	export class DownloadTask {
@@ -145,6 +446,16 @@ export namespace models {
 	    speed: string;
 	    created_at: string;
 	    updated_at: string;
+	    folder: string;
+	    checksum: string;
+	    checksum_algorithm: string;
+	    is_container: boolean;
+	    email_date: string;
+	    retry_count: number;
+	    message_id: number;
+	    hash: string;
+	    duplicate_of: number;
+	    merged_into: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new DownloadTask(source);
@@ -169,6 +480,16 @@ export namespace models {
 	        this.speed = source["speed"];
 	        this.created_at = source["created_at"];
 	        this.updated_at = source["updated_at"];
+	        this.folder = source["folder"];
+	        this.checksum = source["checksum"];
+	        this.checksum_algorithm = source["checksum_algorithm"];
+	        this.is_container = source["is_container"];
+	        this.email_date = source["email_date"];
+	        this.retry_count = source["retry_count"];
+	        this.message_id = source["message_id"];
+	        this.hash = source["hash"];
+	        this.duplicate_of = source["duplicate_of"];
+	        this.merged_into = source["merged_into"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -190,12 +511,35 @@ export namespace models {
 		}
 	}
 	
+	export class DownloadTaskFilter {
+	    status: string;
+	    account_id: number;
+	    sort_by: string;
+	    sort_order: string;
+	    page: number;
+	    page_size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DownloadTaskFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.status = source["status"];
+	        this.account_id = source["account_id"];
+	        this.sort_by = source["sort_by"];
+	        this.sort_order = source["sort_order"];
+	        this.page = source["page"];
+	        this.page_size = source["page_size"];
+	    }
+	}
 	export class EmailCheckResult {
 	    account?: EmailAccount;
 	    new_emails: number;
 	    pdfs_found: number;
 	    error?: string;
 	    success: boolean;
+	    retries: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new EmailCheckResult(source);
@@ -208,6 +552,7 @@ export namespace models {
 	        this.pdfs_found = source["pdfs_found"];
 	        this.error = source["error"];
 	        this.success = source["success"];
+	        this.retries = source["retries"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -241,6 +586,13 @@ export namespace models {
 	    is_processed: boolean;
 	    created_at: string;
 	    updated_at: string;
+	    gmail_message_id: string;
+	    gmail_thread_id: string;
+	    pdf_total: number;
+	    tasks_created: number;
+	    pdf_downloaded: number;
+	    pdf_failed: number;
+	    extraction_status: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new EmailMessage(source);
@@ -260,6 +612,13 @@ export namespace models {
 	        this.is_processed = source["is_processed"];
 	        this.created_at = source["created_at"];
 	        this.updated_at = source["updated_at"];
+	        this.gmail_message_id = source["gmail_message_id"];
+	        this.gmail_thread_id = source["gmail_thread_id"];
+	        this.pdf_total = source["pdf_total"];
+	        this.tasks_created = source["tasks_created"];
+	        this.pdf_downloaded = source["pdf_downloaded"];
+	        this.pdf_failed = source["pdf_failed"];
+	        this.extraction_status = source["extraction_status"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -280,6 +639,345 @@ export namespace models {
 		    return a;
 		}
 	}
+	export class FailureCategorySummary {
+	    category: string;
+	    count: number;
+	    task_ids: number[];
+	    sample: DownloadTask;
+	
+	    static createFrom(source: any = {}) {
+	        return new FailureCategorySummary(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.category = source["category"];
+	        this.count = source["count"];
+	        this.task_ids = source["task_ids"];
+	        this.sample = this.convertValues(source["sample"], DownloadTask);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FolderCheck {
+	    folder: string;
+	    resolved: string;
+	    exists: boolean;
+	    suggestions: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FolderCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folder = source["folder"];
+	        this.resolved = source["resolved"];
+	        this.exists = source["exists"];
+	        this.suggestions = source["suggestions"];
+	    }
+	}
+	export class TaskNetworkActivity {
+	    task_id: number;
+	    file_name: string;
+	    type: string;
+	    host: string;
+	    bytes_per_second: number;
+	    downloaded_size: number;
+	    total_size: number;
+	    stalled_seconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskNetworkActivity(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.task_id = source["task_id"];
+	        this.file_name = source["file_name"];
+	        this.type = source["type"];
+	        this.host = source["host"];
+	        this.bytes_per_second = source["bytes_per_second"];
+	        this.downloaded_size = source["downloaded_size"];
+	        this.total_size = source["total_size"];
+	        this.stalled_seconds = source["stalled_seconds"];
+	    }
+	}
+	export class NetworkActivity {
+	    tasks: TaskNetworkActivity[];
+	    active_tasks: number;
+	    total_bytes_per_second: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkActivity(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tasks = this.convertValues(source["tasks"], TaskNetworkActivity);
+	        this.active_tasks = source["active_tasks"];
+	        this.total_bytes_per_second = source["total_bytes_per_second"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class PDFMetadata {
+	    task_id: number;
+	    title: string;
+	    author: string;
+	    page_count: number;
+	    thumbnail: string;
+	    indexed_at: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PDFMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.task_id = source["task_id"];
+	        this.title = source["title"];
+	        this.author = source["author"];
+	        this.page_count = source["page_count"];
+	        this.thumbnail = source["thumbnail"];
+	        this.indexed_at = source["indexed_at"];
+	    }
+	}
+	export class PathCheck {
+	    path: string;
+	    exists: boolean;
+	    is_dir: boolean;
+	    writable: boolean;
+	    free_bytes: number;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PathCheck(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.exists = source["exists"];
+	        this.is_dir = source["is_dir"];
+	        this.writable = source["writable"];
+	        this.free_bytes = source["free_bytes"];
+	        this.error = source["error"];
+	    }
+	}
+	export class Rule {
+	    id: number;
+	    name: string;
+	    account_id: number;
+	    field: string;
+	    pattern: string;
+	    action: string;
+	    enabled: boolean;
+	    position: number;
+	    created_at: string;
+	    updated_at: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Rule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.account_id = source["account_id"];
+	        this.field = source["field"];
+	        this.pattern = source["pattern"];
+	        this.action = source["action"];
+	        this.enabled = source["enabled"];
+	        this.position = source["position"];
+	        this.created_at = source["created_at"];
+	        this.updated_at = source["updated_at"];
+	    }
+	}
+	export class SourcePreview {
+	    message_id: string;
+	    subject: string;
+	    sender: string;
+	    type: string;
+	    source: string;
+	    file_name: string;
+	    file_size: number;
+	    local_path: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SourcePreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.message_id = source["message_id"];
+	        this.subject = source["subject"];
+	        this.sender = source["sender"];
+	        this.type = source["type"];
+	        this.source = source["source"];
+	        this.file_name = source["file_name"];
+	        this.file_size = source["file_size"];
+	        this.local_path = source["local_path"];
+	    }
+	}
+	export class SpeedSample {
+	    timestamp: string;
+	    bytes_per_second: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SpeedSample(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.timestamp = source["timestamp"];
+	        this.bytes_per_second = source["bytes_per_second"];
+	    }
+	}
+	export class TaskDetail {
+	    task: DownloadTask;
+	    message?: EmailMessage;
+	    account?: EmailAccount;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskDetail(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.task = this.convertValues(source["task"], DownloadTask);
+	        this.message = this.convertValues(source["message"], EmailMessage);
+	        this.account = this.convertValues(source["account"], EmailAccount);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TaskFile {
+	    id: number;
+	    task_id: number;
+	    file_name: string;
+	    local_path: string;
+	    file_size: number;
+	    created_at: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskFile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.task_id = source["task_id"];
+	        this.file_name = source["file_name"];
+	        this.local_path = source["local_path"];
+	        this.file_size = source["file_size"];
+	        this.created_at = source["created_at"];
+	    }
+	}
+	export class TaskLogEntry {
+	    id: number;
+	    task_id: number;
+	    level: string;
+	    message: string;
+	    created_at: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new TaskLogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.task_id = source["task_id"];
+	        this.level = source["level"];
+	        this.message = source["message"];
+	        this.created_at = source["created_at"];
+	    }
+	}
+	export class ValidationResult {
+	    task_id: number;
+	    local_path: string;
+	    valid: boolean;
+	    exists: boolean;
+	    file_size: number;
+	    content_error: string;
+	    checksum_algorithm: string;
+	    checksum_matches: boolean;
+	    extracted_files: number;
+	    extracted_errors: string[];
+	    error: string;
+	    status: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ValidationResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.task_id = source["task_id"];
+	        this.local_path = source["local_path"];
+	        this.valid = source["valid"];
+	        this.exists = source["exists"];
+	        this.file_size = source["file_size"];
+	        this.content_error = source["content_error"];
+	        this.checksum_algorithm = source["checksum_algorithm"];
+	        this.checksum_matches = source["checksum_matches"];
+	        this.extracted_files = source["extracted_files"];
+	        this.extracted_errors = source["extracted_errors"];
+	        this.error = source["error"];
+	        this.status = source["status"];
+	    }
+	}
 
 }
 
@@ -35,12 +35,13 @@ func main() {
 		OnStartup:        app.OnStartup,
 		OnDomReady:       app.OnDomReady,
 		OnShutdown:       app.OnShutdown,
+		OnBeforeClose:    app.OnBeforeClose, // 托盘可用时关闭窗口隐藏到托盘
 		Bind: []interface{}{
 			app, // 将App实例绑定到前端
 		},
 		Fullscreen:       false,
 		StartHidden:      false,
-		HideWindowOnClose: true, // 关闭时隐藏窗口而不是退出
+		HideWindowOnClose: false, // 是否隐藏到托盘由 OnBeforeClose 根据托盘可用性决定
 		DisableResize:    false,
 		Debug: options.Debug{
 			OpenInspectorOnStartup: false,